@@ -0,0 +1,169 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//! The transitions of executors; both the controller of the session manager
+//! and the executor manager move the executors by `transit`, so the events not
+//! allowed in the current state are rejected. `flame_rs::apis::executor` keeps
+//! the same table for the executor implementations.
+
+use crate::apis::ExecutorState;
+use crate::FlameError;
+
+#[derive(
+    Clone, Copy, Debug, PartialEq, Eq, Hash, strum_macros::Display, strum_macros::EnumIter,
+)]
+pub enum ExecutorEvent {
+    Register,
+    BindSession,
+    BindSessionCompleted,
+    LaunchTask,
+    CompleteTask,
+    Unbind,
+    UnbindCompleted,
+    Release,
+    Unregister,
+}
+
+/// The transitions of executors: (current state, event, next state).
+pub const TRANSITIONS: &[(ExecutorState, ExecutorEvent, ExecutorState)] = &[
+    (
+        ExecutorState::Void,
+        ExecutorEvent::Register,
+        ExecutorState::Idle,
+    ),
+    (
+        ExecutorState::Idle,
+        ExecutorEvent::BindSession,
+        ExecutorState::Binding,
+    ),
+    (
+        ExecutorState::Idle,
+        ExecutorEvent::Release,
+        ExecutorState::Releasing,
+    ),
+    (
+        ExecutorState::Binding,
+        ExecutorEvent::BindSession,
+        ExecutorState::Binding,
+    ),
+    (
+        ExecutorState::Binding,
+        ExecutorEvent::BindSessionCompleted,
+        ExecutorState::Bound,
+    ),
+    (
+        ExecutorState::Bound,
+        ExecutorEvent::LaunchTask,
+        ExecutorState::Bound,
+    ),
+    (
+        ExecutorState::Bound,
+        ExecutorEvent::CompleteTask,
+        ExecutorState::Bound,
+    ),
+    (
+        ExecutorState::Bound,
+        ExecutorEvent::Unbind,
+        ExecutorState::Unbinding,
+    ),
+    // The in-flight task is completed while unbinding, and no more tasks are
+    // launched.
+    (
+        ExecutorState::Unbinding,
+        ExecutorEvent::Unbind,
+        ExecutorState::Unbinding,
+    ),
+    (
+        ExecutorState::Unbinding,
+        ExecutorEvent::LaunchTask,
+        ExecutorState::Unbinding,
+    ),
+    (
+        ExecutorState::Unbinding,
+        ExecutorEvent::CompleteTask,
+        ExecutorState::Unbinding,
+    ),
+    (
+        ExecutorState::Unbinding,
+        ExecutorEvent::UnbindCompleted,
+        ExecutorState::Idle,
+    ),
+    (
+        ExecutorState::Releasing,
+        ExecutorEvent::Unregister,
+        ExecutorState::Released,
+    ),
+];
+
+/// Get the next state of the executor by the event, or an error if the event
+/// is not allowed in the current state.
+pub fn transit(state: ExecutorState, event: ExecutorEvent) -> Result<ExecutorState, FlameError> {
+    TRANSITIONS
+        .iter()
+        .find(|(from, ev, _)| *from == state && *ev == event)
+        .map(|(_, _, to)| *to)
+        .ok_or(FlameError::InvalidState(format!(
+            "event <{event}> is not allowed in executor state <{state}>"
+        )))
+}
+
+#[cfg(test)]
+mod tests {
+    use strum::IntoEnumIterator;
+
+    use super::*;
+
+    #[test]
+    fn test_executor_lifecycle() {
+        let mut state = ExecutorState::Void;
+        for (event, next) in [
+            (ExecutorEvent::Register, ExecutorState::Idle),
+            (ExecutorEvent::BindSession, ExecutorState::Binding),
+            (ExecutorEvent::BindSessionCompleted, ExecutorState::Bound),
+            (ExecutorEvent::LaunchTask, ExecutorState::Bound),
+            (ExecutorEvent::CompleteTask, ExecutorState::Bound),
+            (ExecutorEvent::Unbind, ExecutorState::Unbinding),
+            (ExecutorEvent::UnbindCompleted, ExecutorState::Idle),
+            (ExecutorEvent::Release, ExecutorState::Releasing),
+            (ExecutorEvent::Unregister, ExecutorState::Released),
+        ] {
+            state = transit(state, event).unwrap();
+            assert_eq!(state, next);
+        }
+    }
+
+    #[test]
+    fn test_invalid_transition() {
+        assert!(matches!(
+            transit(ExecutorState::Idle, ExecutorEvent::LaunchTask),
+            Err(FlameError::InvalidState(_))
+        ));
+
+        // No event is allowed after the executor was released.
+        for event in ExecutorEvent::iter() {
+            assert!(transit(ExecutorState::Released, event).is_err());
+        }
+    }
+
+    #[test]
+    fn test_transitions_are_deterministic() {
+        for (i, (from, event, _)) in TRANSITIONS.iter().enumerate() {
+            assert!(
+                !TRANSITIONS[i + 1..]
+                    .iter()
+                    .any(|(f, e, _)| f == from && e == event),
+                "duplicated transition <{from}, {event}>"
+            );
+        }
+    }
+}
@@ -15,6 +15,8 @@ use chrono::{DateTime, Duration, Utc};
 
 use rpc::flame::v1 as rpc;
 
+use super::checksum;
+use super::principal::Principal;
 use super::types::*;
 use crate::FlameError;
 
@@ -32,6 +34,7 @@ impl From<rpc::NodeInfo> for NodeInfo {
         Self {
             arch: info.arch,
             os: info.os,
+            labels: info.labels,
         }
     }
 }
@@ -81,6 +84,25 @@ impl From<rpc::Event> for Event {
     }
 }
 
+impl From<rpc::TaskProvenance> for TaskProvenance {
+    fn from(provenance: rpc::TaskProvenance) -> Self {
+        Self {
+            application_version: provenance.application_version,
+            input_checksum: provenance.input_checksum,
+        }
+    }
+}
+
+impl From<rpc::Principal> for Principal {
+    fn from(principal: rpc::Principal) -> Self {
+        Self {
+            subject: principal.subject,
+            groups: principal.groups,
+            claims: principal.claims,
+        }
+    }
+}
+
 impl TryFrom<rpc::Task> for TaskContext {
     type Error = FlameError;
 
@@ -93,10 +115,26 @@ impl TryFrom<rpc::Task> for TaskContext {
             .spec
             .ok_or(FlameError::InvalidConfig("spec".to_string()))?;
 
+        checksum::verify(
+            &format!("input of task <{}/{}>", spec.session_id, metadata.id),
+            spec.input.as_deref(),
+            spec.input_checksum.as_deref(),
+        )?;
+
         Ok(TaskContext {
             task_id: metadata.id.clone(),
             session_id: spec.session_id.to_string(),
             input: spec.input.map(TaskInput::from),
+            principal: spec.principal.map(Principal::from),
+            deadline: spec.deadline.and_then(DateTime::from_timestamp_millis),
+            // Stamped by the executor with the content type of the session.
+            content_type: None,
+            group: spec.group,
+            method: method_of(&spec.labels),
+            // Stamped by the executor with the priority and the sequence of
+            // the launch.
+            priority: None,
+            sequence: None,
         })
     }
 }
@@ -127,6 +165,7 @@ impl TryFrom<rpc::Application> for ApplicationContext {
                 .map(|e| (e.name, e.value))
                 .collect(),
             url: spec.url.clone(),
+            output_schema: spec.schema.as_ref().and_then(|s| s.output.clone()),
         })
     }
 }
@@ -149,6 +188,9 @@ impl TryFrom<(rpc::Application, rpc::Session)> for SessionContext {
             application,
             slots: spec.slots,
             common_data: spec.common_data.map(CommonData::from),
+            scratch_dir: None,
+            content_type: spec.content_type,
+            client_host: spec.client_host,
         })
     }
 }
@@ -159,6 +201,7 @@ impl From<rpc::ApplicationSchema> for ApplicationSchema {
             input: schema.input,
             output: schema.output,
             common_data: schema.common_data,
+            content_types: schema.content_types,
         }
     }
 }
@@ -187,7 +230,7 @@ impl TryFrom<&rpc::Application> for Application {
 
         Ok(Application {
             name: metadata.name.clone(),
-            version: 0,
+            version: metadata.resource_version.unwrap_or_default(),
             state: ApplicationState::from(status.state()),
             creation_time: DateTime::<Utc>::from_timestamp(status.creation_time, 0).ok_or(
                 FlameError::InvalidState("invalid creation time".to_string()),
@@ -216,6 +259,96 @@ impl TryFrom<&rpc::Application> for Application {
     }
 }
 
+impl TryFrom<&rpc::Session> for Session {
+    type Error = FlameError;
+    fn try_from(ssn: &rpc::Session) -> Result<Self, Self::Error> {
+        let metadata = ssn.metadata.clone().ok_or(FlameError::InvalidConfig(
+            "session metadata is empty".to_string(),
+        ))?;
+        let spec = ssn.spec.clone().ok_or(FlameError::InvalidConfig(
+            "session spec is empty".to_string(),
+        ))?;
+        let status = ssn.status.clone().ok_or(FlameError::InvalidConfig(
+            "session status is empty".to_string(),
+        ))?;
+
+        Ok(Session {
+            id: metadata.id,
+            application: spec.application,
+            slots: spec.slots,
+            version: metadata.resource_version.unwrap_or_default(),
+            common_data: spec.common_data.map(CommonData::from),
+            creation_time: DateTime::<Utc>::from_timestamp(status.creation_time, 0).ok_or(
+                FlameError::InvalidState("invalid creation time".to_string()),
+            )?,
+            completion_time: status
+                .completion_time
+                .and_then(|t| DateTime::<Utc>::from_timestamp(t, 0)),
+            status: SessionStatus {
+                state: SessionState::try_from(status.state)?,
+            },
+            min_instances: spec.min_instances,
+            max_instances: spec.max_instances,
+            batch_size: spec.batch_size,
+            content_type: spec.content_type,
+            client_host: spec.client_host,
+            labels: spec.labels,
+            ..Default::default()
+        })
+    }
+}
+
+impl TryFrom<&rpc::Task> for Task {
+    type Error = FlameError;
+    fn try_from(task: &rpc::Task) -> Result<Self, Self::Error> {
+        let metadata = task.metadata.clone().ok_or(FlameError::InvalidConfig(
+            "task metadata is empty".to_string(),
+        ))?;
+        let spec = task
+            .spec
+            .clone()
+            .ok_or(FlameError::InvalidConfig("task spec is empty".to_string()))?;
+        let status = task.status.clone().ok_or(FlameError::InvalidConfig(
+            "task status is empty".to_string(),
+        ))?;
+
+        Ok(Task {
+            id: metadata.id.parse().map_err(|_| {
+                FlameError::InvalidConfig(format!("invalid task id <{}>", metadata.id))
+            })?,
+            ssn_id: spec.session_id,
+            version: metadata.resource_version.unwrap_or_default(),
+            input: spec.input.map(TaskInput::from),
+            output: spec.output.map(TaskOutput::from),
+            output_ref: spec.output_ref,
+            principal: spec.principal.map(Principal::from),
+            tags: spec.tags,
+            deadline: spec.deadline.and_then(DateTime::from_timestamp_millis),
+            group: spec.group,
+            labels: spec.labels,
+            run_at: spec.run_at.and_then(DateTime::from_timestamp_millis),
+            depends_on: spec
+                .depends_on
+                .iter()
+                .map(|id| {
+                    id.parse().map_err(|_| {
+                        FlameError::InvalidConfig(format!("invalid parent task id <{id}>"))
+                    })
+                })
+                .collect::<Result<_, _>>()?,
+            provenance: status.provenance.map(TaskProvenance::from),
+            creation_time: DateTime::<Utc>::from_timestamp(status.creation_time, 0).ok_or(
+                FlameError::InvalidState("invalid creation time".to_string()),
+            )?,
+            completion_time: status
+                .completion_time
+                .and_then(|t| DateTime::<Utc>::from_timestamp(t, 0)),
+            events: status.events.into_iter().map(Event::from).collect(),
+            state: TaskState::try_from(status.state)?,
+        })
+    }
+}
+
 impl From<rpc::ApplicationSpec> for ApplicationAttributes {
     fn from(spec: rpc::ApplicationSpec) -> Self {
         Self {
@@ -306,6 +439,8 @@ impl TryFrom<String> for Shim {
         match s.to_lowercase().as_str() {
             "host" => Ok(Self::Host),
             "wasm" => Ok(Self::Wasm),
+            "grpc" => Ok(Self::Grpc),
+            "http" => Ok(Self::Http),
             _ => Err(FlameError::InvalidConfig(format!("invalid shim: {s}"))),
         }
     }
@@ -316,6 +451,8 @@ impl From<rpc::Shim> for Shim {
         match s {
             rpc::Shim::Host => Self::Host,
             rpc::Shim::Wasm => Self::Wasm,
+            rpc::Shim::Grpc => Self::Grpc,
+            rpc::Shim::Http => Self::Http,
         }
     }
 }
@@ -372,6 +509,7 @@ impl From<rpc::TaskResult> for TaskResult {
             state,
             output: result.output.map(TaskOutput::from),
             message: result.message,
+            output_ref: result.output_ref,
         }
     }
 }
@@ -391,10 +529,14 @@ impl TryFrom<TaskResult> for rpc::TaskResult {
             }
         };
 
+        let checksum = result.output.as_deref().map(checksum::checksum);
+
         Ok(Self {
             return_code,
             output: result.output.map(TaskOutput::into),
             message: result.message,
+            checksum,
+            output_ref: result.output_ref,
         })
     }
 }
@@ -11,15 +11,21 @@ See the License for the specific language governing permissions and
 limitations under the License.
 */
 
+pub mod checksum;
+pub mod executor;
 mod from_rpc;
+pub mod principal;
 mod session;
 mod to_rpc;
 mod types;
 
+pub use principal::Principal;
 pub use types::*;
 
 #[cfg(test)]
 mod tests {
+    use std::collections::HashMap;
+
     use super::*;
 
     #[test]
@@ -52,12 +58,243 @@ mod tests {
         assert_eq!(Shim::try_from("wasm".to_string()).unwrap(), Shim::Wasm);
         assert_eq!(Shim::try_from("Wasm".to_string()).unwrap(), Shim::Wasm);
         assert_eq!(Shim::try_from("WASM".to_string()).unwrap(), Shim::Wasm);
+        assert_eq!(Shim::try_from("grpc".to_string()).unwrap(), Shim::Grpc);
+        assert_eq!(Shim::try_from("gRPC".to_string()).unwrap(), Shim::Grpc);
+        assert_eq!(Shim::try_from("http".to_string()).unwrap(), Shim::Http);
+        assert_eq!(Shim::try_from("HTTP".to_string()).unwrap(), Shim::Http);
         assert!(Shim::try_from("invalid".to_string()).is_err());
     }
 
+    #[test]
+    fn test_negotiate_content_type() {
+        let schema = ApplicationSchema {
+            content_types: vec![
+                "application/protobuf".to_string(),
+                "application/json".to_string(),
+            ],
+            ..Default::default()
+        };
+        assert_eq!(
+            schema.negotiate(None).unwrap().as_deref(),
+            Some("application/protobuf")
+        );
+        assert_eq!(
+            schema
+                .negotiate(Some("application/json"))
+                .unwrap()
+                .as_deref(),
+            Some("application/json")
+        );
+        assert!(schema.negotiate(Some("text/csv")).is_err());
+
+        let schema = ApplicationSchema::default();
+        assert!(schema.negotiate(None).unwrap().is_none());
+        assert_eq!(
+            schema.negotiate(Some("text/csv")).unwrap().as_deref(),
+            Some("text/csv")
+        );
+    }
+
     #[test]
     fn test_application_attributes_default_shim() {
         let attrs = ApplicationAttributes::default();
         assert_eq!(attrs.shim, Shim::Host);
     }
+
+    #[test]
+    fn test_tags_of_labels() {
+        let labels = HashMap::from([(LABEL_TAGS.to_string(), "huge-memory, gpu,".to_string())]);
+        assert_eq!(tags_of(&labels), vec!["huge-memory", "gpu"]);
+        assert!(tags_of(&HashMap::new()).is_empty());
+    }
+
+    #[test]
+    fn test_method_of_labels() {
+        let labels = vec!["trace-id=abc".to_string(), format!("{LABEL_METHOD}=resize")];
+        assert_eq!(method_of(&labels).as_deref(), Some("resize"));
+        assert_eq!(method_of(&["flame.io/methods=x".to_string()]), None);
+        assert_eq!(method_of(&[]), None);
+    }
+
+    #[test]
+    fn test_task_context_deadline() {
+        let mut ctx = TaskContext {
+            task_id: "1".to_string(),
+            session_id: "ssn-1".to_string(),
+            input: None,
+            principal: None,
+            deadline: None,
+            content_type: None,
+            group: None,
+            method: None,
+            priority: None,
+            sequence: None,
+        };
+        assert_eq!(ctx.remaining(), None);
+        assert!(!ctx.is_expired());
+
+        ctx.deadline = Some(chrono::Utc::now() + chrono::Duration::seconds(60));
+        assert!(ctx.remaining().unwrap() > std::time::Duration::from_secs(30));
+        assert!(!ctx.is_expired());
+
+        ctx.deadline = Some(chrono::Utc::now() - chrono::Duration::seconds(1));
+        assert_eq!(ctx.remaining(), Some(std::time::Duration::ZERO));
+        assert!(ctx.is_expired());
+    }
+
+    #[test]
+    fn test_task_from_rpc_task() {
+        let task = Task {
+            id: 7,
+            ssn_id: "ssn-1".to_string(),
+            version: 3,
+            input: Some(TaskInput::from("input")),
+            output: Some(TaskOutput::from("output")),
+            tags: vec!["gpu".to_string()],
+            deadline: chrono::DateTime::from_timestamp_millis(1_700_000_000_123),
+            group: Some("extract".to_string()),
+            labels: vec!["trace-id=abc".to_string()],
+            run_at: chrono::DateTime::from_timestamp_millis(1_700_000_000_456),
+            depends_on: vec![3, 5],
+            provenance: Some(TaskProvenance {
+                application_version: 2,
+                input_checksum: Some("xxh3:abc".to_string()),
+            }),
+            completion_time: chrono::DateTime::from_timestamp(1_700_000_001, 0),
+            state: TaskState::Succeed,
+            ..Default::default()
+        };
+
+        let copy = Task::try_from(&rpc::flame::v1::Task::from(&task)).unwrap();
+        assert_eq!(copy.id, 7);
+        assert_eq!(copy.ssn_id, "ssn-1");
+        assert_eq!(copy.version, 3);
+        assert_eq!(copy.input, task.input);
+        assert_eq!(copy.output, task.output);
+        assert_eq!(copy.tags, task.tags);
+        assert_eq!(copy.deadline, task.deadline);
+        assert_eq!(copy.group, task.group);
+        assert_eq!(copy.labels, task.labels);
+        assert_eq!(copy.run_at, task.run_at);
+        assert_eq!(copy.depends_on, task.depends_on);
+        assert_eq!(copy.provenance, task.provenance);
+        assert_eq!(copy.completion_time, task.completion_time);
+        assert_eq!(copy.state, TaskState::Succeed);
+    }
+
+    #[test]
+    fn test_session_attributes_from_session() {
+        let ssn = Session {
+            id: "ssn-1".to_string(),
+            application: "pi".to_string(),
+            slots: 2,
+            version: 2,
+            batch_size: 4,
+            status: SessionStatus {
+                state: SessionState::Closed,
+            },
+            ..Default::default()
+        };
+
+        let copy = Session::try_from(&rpc::flame::v1::Session::from(&ssn)).unwrap();
+        assert_eq!(copy.version, 2);
+        assert_eq!(copy.status.state, SessionState::Closed);
+
+        let attr = SessionAttributes::from(&copy);
+        assert_eq!(attr.id, "ssn-1");
+        assert_eq!(attr.application, "pi");
+        assert_eq!(attr.slots, 2);
+        assert_eq!(attr.batch_size, 4);
+    }
+
+    #[test]
+    fn test_pop_pending_task_by_tags() {
+        let mut ssn = Session {
+            id: "ssn-1".to_string(),
+            ..Default::default()
+        };
+        for (id, tags) in [(1, vec![]), (2, vec!["huge-memory".to_string()])] {
+            ssn.update_task(&Task {
+                id,
+                ssn_id: "ssn-1".to_string(),
+                version: 1,
+                tags,
+                ..Default::default()
+            })
+            .unwrap();
+        }
+
+        // The executor without tags only gets the untagged task.
+        let now = chrono::Utc::now();
+        let task = ssn.pop_pending_task(0, 1, &[], now).unwrap();
+        assert_eq!(task.lock().unwrap().id, 1);
+        assert!(ssn.pop_pending_task(0, 1, &[], now).is_none());
+
+        let tags = vec!["huge-memory".to_string(), "gpu".to_string()];
+        let task = ssn.pop_pending_task(0, 1, &tags, now).unwrap();
+        assert_eq!(task.lock().unwrap().id, 2);
+    }
+
+    #[test]
+    fn test_pop_pending_task_by_run_at() {
+        let now = chrono::Utc::now();
+        let mut ssn = Session {
+            id: "ssn-1".to_string(),
+            ..Default::default()
+        };
+        ssn.update_task(&Task {
+            id: 1,
+            ssn_id: "ssn-1".to_string(),
+            version: 1,
+            run_at: Some(now + chrono::Duration::seconds(60)),
+            ..Default::default()
+        })
+        .unwrap();
+
+        // The scheduled task is pending, but not launched until it's due.
+        assert_eq!(ssn.waiting_tasks(now), 1);
+        assert!(ssn.pop_pending_task(0, 1, &[], now).is_none());
+
+        let later = now + chrono::Duration::seconds(60);
+        assert_eq!(ssn.waiting_tasks(later), 0);
+        let task = ssn.pop_pending_task(0, 1, &[], later).unwrap();
+        assert_eq!(task.lock().unwrap().id, 1);
+    }
+
+    #[test]
+    fn test_pop_pending_task_by_depends_on() {
+        let now = chrono::Utc::now();
+        let mut ssn = Session {
+            id: "ssn-1".to_string(),
+            ..Default::default()
+        };
+        for (id, depends_on) in [(1, vec![]), (2, vec![1])] {
+            ssn.update_task(&Task {
+                id,
+                ssn_id: "ssn-1".to_string(),
+                version: 1,
+                depends_on,
+                ..Default::default()
+            })
+            .unwrap();
+        }
+
+        // The child is pending, but not launched until its parent succeeded.
+        assert_eq!(ssn.waiting_tasks(now), 1);
+        let task = ssn.pop_pending_task(0, 1, &[], now).unwrap();
+        assert_eq!(task.lock().unwrap().id, 1);
+        assert!(ssn.pop_pending_task(0, 1, &[], now).is_none());
+
+        ssn.update_task(&Task {
+            id: 1,
+            ssn_id: "ssn-1".to_string(),
+            version: 2,
+            state: TaskState::Succeed,
+            ..Default::default()
+        })
+        .unwrap();
+        assert_eq!(ssn.waiting_tasks(now), 0);
+        let task = ssn.pop_pending_task(0, 1, &[], now).unwrap();
+        assert_eq!(task.lock().unwrap().id, 2);
+    }
 }
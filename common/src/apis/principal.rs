@@ -0,0 +1,136 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//! The identity of the user who submitted a task, which is passed from the
+//! frontend to the services for their own authorization, e.g. row-level
+//! filtering. The frontend does not authenticate the users: the identity is
+//! taken from the headers set by the authenticating proxy in front of it, and
+//! is passed to the HTTP services by the same headers.
+
+use std::collections::HashMap;
+
+use serde_derive::{Deserialize, Serialize};
+
+/// The subject of the user, e.g. the `sub` claim of the token.
+pub const SUBJECT_HEADER: &str = "x-flame-subject";
+/// The groups of the user, separated by commas.
+pub const GROUPS_HEADER: &str = "x-flame-groups";
+/// The prefix of the other claims, e.g. `x-flame-claim-tenant`.
+pub const CLAIM_HEADER_PREFIX: &str = "x-flame-claim-";
+/// The claim of the tenant of the user, whose data is isolated from the
+/// other tenants, e.g. encrypted by its own keys.
+pub const TENANT_CLAIM: &str = "tenant";
+/// The tenant of the users without the tenant claim.
+pub const DEFAULT_TENANT: &str = "default";
+/// The group of the administrators of the cluster, e.g. to rotate the keys
+/// of the tenants.
+pub const ADMIN_GROUP: &str = "flame-admins";
+
+#[derive(Clone, Debug, Default, PartialEq, Eq, Serialize, Deserialize)]
+pub struct Principal {
+    pub subject: String,
+    #[serde(default)]
+    pub groups: Vec<String>,
+    #[serde(default)]
+    pub claims: HashMap<String, String>,
+}
+
+impl Principal {
+    /// The principal by the headers; None if there's no subject.
+    pub fn from_headers<'a>(headers: impl IntoIterator<Item = (&'a str, &'a str)>) -> Option<Self> {
+        let mut principal = Principal::default();
+
+        for (name, value) in headers {
+            let name = name.to_lowercase();
+            if name == SUBJECT_HEADER {
+                principal.subject = value.trim().to_string();
+            } else if name == GROUPS_HEADER {
+                principal.groups = value
+                    .split(',')
+                    .map(str::trim)
+                    .filter(|group| !group.is_empty())
+                    .map(str::to_string)
+                    .collect();
+            } else if let Some(claim) = name.strip_prefix(CLAIM_HEADER_PREFIX) {
+                principal
+                    .claims
+                    .insert(claim.to_string(), value.trim().to_string());
+            }
+        }
+
+        (!principal.subject.is_empty()).then_some(principal)
+    }
+
+    /// The tenant of the user by its claim.
+    pub fn tenant(&self) -> &str {
+        self.claims
+            .get(TENANT_CLAIM)
+            .map_or(DEFAULT_TENANT, String::as_str)
+    }
+
+    /// Whether the user is an administrator of the cluster by its groups.
+    pub fn is_admin(&self) -> bool {
+        self.groups.iter().any(|group| group == ADMIN_GROUP)
+    }
+
+    /// The headers of the principal, which is the reverse of `from_headers`.
+    pub fn to_headers(&self) -> Vec<(String, String)> {
+        let mut headers = vec![(SUBJECT_HEADER.to_string(), self.subject.clone())];
+        if !self.groups.is_empty() {
+            headers.push((GROUPS_HEADER.to_string(), self.groups.join(",")));
+        }
+        for (claim, value) in &self.claims {
+            headers.push((format!("{CLAIM_HEADER_PREFIX}{claim}"), value.clone()));
+        }
+
+        headers
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_principal_headers() {
+        let principal = Principal::from_headers([
+            ("content-type", "application/grpc"),
+            ("X-Flame-Subject", "alice"),
+            ("x-flame-groups", "data, ml,"),
+            ("x-flame-claim-tenant", "acme"),
+        ])
+        .unwrap();
+
+        assert_eq!(principal.subject, "alice");
+        assert_eq!(principal.groups, vec!["data", "ml"]);
+        assert_eq!(principal.claims["tenant"], "acme");
+        assert_eq!(principal.tenant(), "acme");
+        assert!(!principal.is_admin());
+
+        let headers = principal.to_headers();
+        let parsed =
+            Principal::from_headers(headers.iter().map(|(k, v)| (k.as_str(), v.as_str()))).unwrap();
+        assert_eq!(parsed, principal);
+
+        let admin = Principal::from_headers([
+            ("x-flame-subject", "bob"),
+            ("x-flame-groups", "ml,flame-admins"),
+        ])
+        .unwrap();
+        assert!(admin.is_admin());
+        assert_eq!(admin.tenant(), DEFAULT_TENANT);
+
+        // Anonymous without the subject.
+        assert!(Principal::from_headers([("x-flame-groups", "data")]).is_none());
+    }
+}
@@ -13,6 +13,7 @@ limitations under the License.
 
 use std::collections::HashMap;
 
+use chrono::{DateTime, Utc};
 use stdng::lock_ptr;
 
 use super::types::*;
@@ -83,11 +84,48 @@ impl Session {
         Ok(())
     }
 
-    pub fn pop_pending_task(&mut self, batch_index: u32, batch_size: u32) -> Option<TaskPtr> {
+    /// The pending tasks which can not be launched at the time, i.e. they're
+    /// scheduled after the time or their parents have not succeeded, see
+    /// `Task::is_due`.
+    pub fn waiting_tasks(&self, now: DateTime<Utc>) -> usize {
+        self.tasks_index
+            .get(&TaskState::Pending)
+            .map(|tasks| {
+                tasks
+                    .values()
+                    .filter(|task_ptr| {
+                        lock_ptr!(task_ptr)
+                            .map(|task| !task.is_due(now) || !is_ready(&self.tasks, &task))
+                            .unwrap_or(false)
+                    })
+                    .count()
+            })
+            .unwrap_or(0)
+    }
+
+    /// Pop a pending task which can be launched on the executor with the
+    /// tags at the time and whose parents succeeded, see `Task::is_qualified`
+    /// and `Task::is_due`.
+    pub fn pop_pending_task(
+        &mut self,
+        batch_index: u32,
+        batch_size: u32,
+        tags: &[String],
+        now: DateTime<Utc>,
+    ) -> Option<TaskPtr> {
+        let tasks = &self.tasks;
         let pending_tasks = self.tasks_index.get_mut(&TaskState::Pending)?;
+        let is_qualified = |task_ptr: &TaskPtr| {
+            lock_ptr!(task_ptr)
+                .map(|task| task.is_qualified(tags) && task.is_due(now) && is_ready(tasks, &task))
+                .unwrap_or(false)
+        };
 
         if batch_size <= 1 {
-            let task_id = *pending_tasks.keys().next()?;
+            let task_id = pending_tasks
+                .iter()
+                .find(|(_, task_ptr)| is_qualified(task_ptr))
+                .map(|(task_id, _)| *task_id)?;
             return pending_tasks.remove(&task_id);
         }
 
@@ -95,7 +133,9 @@ impl Session {
         sorted_task_ids.sort();
 
         for task_id in sorted_task_ids {
-            if (task_id as u32) % batch_size == batch_index {
+            if (task_id as u32) % batch_size == batch_index
+                && pending_tasks.get(&task_id).is_some_and(is_qualified)
+            {
                 return pending_tasks.remove(&task_id);
             }
         }
@@ -137,6 +177,17 @@ impl Session {
     }
 }
 
+/// Whether all the parents of the task succeeded; the parents are created
+/// before the task, so they're always locked after it.
+fn is_ready(tasks: &HashMap<TaskID, TaskPtr>, task: &Task) -> bool {
+    task.depends_on.iter().all(|id| {
+        tasks
+            .get(id)
+            .and_then(|parent| lock_ptr!(parent).ok())
+            .is_some_and(|parent| parent.state == TaskState::Succeed)
+    })
+}
+
 impl Clone for Session {
     fn clone(&self) -> Self {
         let mut ssn = Session {
@@ -154,6 +205,10 @@ impl Clone for Session {
             min_instances: self.min_instances,
             max_instances: self.max_instances,
             batch_size: self.batch_size,
+            content_type: self.content_type.clone(),
+            client_host: self.client_host.clone(),
+            labels: self.labels.clone(),
+            environments: self.environments.clone(),
         };
 
         for (id, t) in &self.tasks {
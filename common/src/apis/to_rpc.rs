@@ -13,6 +13,8 @@ limitations under the License.
 
 use rpc::flame::v1 as rpc;
 
+use super::checksum;
+use super::principal::Principal;
 use super::types::*;
 
 impl From<ResourceRequirement> for rpc::ResourceRequirement {
@@ -30,6 +32,7 @@ impl From<NodeInfo> for rpc::NodeInfo {
         Self {
             arch: info.arch,
             os: info.os,
+            labels: info.labels,
         }
     }
 }
@@ -69,6 +72,7 @@ impl From<Node> for rpc::Node {
             metadata: Some(rpc::Metadata {
                 id: node.name.clone(),
                 name: node.name.clone(),
+                resource_version: None,
             }),
             spec: Some(rpc::NodeSpec {
                 hostname: node.name.clone(),
@@ -94,12 +98,46 @@ impl From<Event> for rpc::Event {
     }
 }
 
+impl From<TaskProvenance> for rpc::TaskProvenance {
+    fn from(provenance: TaskProvenance) -> Self {
+        Self {
+            application_version: provenance.application_version,
+            input_checksum: provenance.input_checksum,
+        }
+    }
+}
+
+impl From<Principal> for rpc::Principal {
+    fn from(principal: Principal) -> Self {
+        Self {
+            subject: principal.subject,
+            groups: principal.groups,
+            claims: principal.claims,
+        }
+    }
+}
+
 impl From<TaskContext> for rpc::TaskContext {
     fn from(ctx: TaskContext) -> Self {
         Self {
             task_id: ctx.task_id.clone(),
             session_id: ctx.session_id.clone(),
             input: ctx.input.map(|d| d.into()),
+            principal: ctx.principal.map(rpc::Principal::from),
+            deadline: ctx.deadline.map(|d| d.timestamp_millis()),
+            content_type: ctx.content_type.clone(),
+            group: ctx.group.clone(),
+            method: ctx.method.clone(),
+            priority: ctx.priority,
+        }
+    }
+}
+
+impl From<GroupContext> for rpc::GroupContext {
+    fn from(ctx: GroupContext) -> Self {
+        Self {
+            session_id: ctx.session_id,
+            name: ctx.name,
         }
     }
 }
@@ -110,6 +148,8 @@ impl From<SessionContext> for rpc::SessionContext {
             session_id: ctx.session_id.clone(),
             application: Some(ctx.application.into()),
             common_data: ctx.common_data.map(|d| d.into()),
+            scratch_dir: ctx.scratch_dir.clone(),
+            content_type: ctx.content_type.clone(),
         }
     }
 }
@@ -138,18 +178,30 @@ impl From<&Task> for rpc::Task {
         let metadata = Some(rpc::Metadata {
             id: task.id.to_string(),
             name: task.id.to_string(),
+            resource_version: Some(task.version),
         });
 
         let spec = Some(rpc::TaskSpec {
             session_id: task.ssn_id.to_string(),
             input: task.input.clone().map(TaskInput::into),
             output: task.output.clone().map(TaskOutput::into),
+            input_checksum: task.input.as_deref().map(checksum::checksum),
+            output_checksum: task.output.as_deref().map(checksum::checksum),
+            output_ref: task.output_ref.clone(),
+            principal: task.principal.clone().map(rpc::Principal::from),
+            tags: task.tags.clone(),
+            deadline: task.deadline.map(|d| d.timestamp_millis()),
+            group: task.group.clone(),
+            labels: task.labels.clone(),
+            run_at: task.run_at.map(|t| t.timestamp_millis()),
+            depends_on: task.depends_on.iter().map(TaskID::to_string).collect(),
         });
         let status = Some(rpc::TaskStatus {
             state: task.state as i32,
             creation_time: task.creation_time.timestamp(),
             completion_time: task.completion_time.map(|s| s.timestamp()),
             events: task.events.clone().into_iter().map(Event::into).collect(),
+            provenance: task.provenance.clone().map(rpc::TaskProvenance::from),
         });
         rpc::Task {
             metadata,
@@ -192,6 +244,7 @@ impl From<&Session> for rpc::Session {
             metadata: Some(rpc::Metadata {
                 id: ssn.id.to_string(),
                 name: ssn.id.to_string(),
+                resource_version: Some(ssn.version),
             }),
             spec: Some(rpc::SessionSpec {
                 application: ssn.application.clone(),
@@ -200,6 +253,9 @@ impl From<&Session> for rpc::Session {
                 min_instances: ssn.min_instances,
                 max_instances: ssn.max_instances,
                 batch_size: ssn.batch_size,
+                content_type: ssn.content_type.clone(),
+                client_host: ssn.client_host.clone(),
+                labels: ssn.labels.clone(),
             }),
             status: Some(status),
         }
@@ -212,6 +268,7 @@ impl From<ApplicationSchema> for rpc::ApplicationSchema {
             input: schema.input,
             output: schema.output,
             common_data: schema.common_data,
+            content_types: schema.content_types,
         }
     }
 }
@@ -246,6 +303,7 @@ impl From<&Application> for rpc::Application {
         let metadata = Some(rpc::Metadata {
             id: app.name.clone(),
             name: app.name.clone(),
+            resource_version: Some(app.version),
         });
 
         let status = Some(rpc::ApplicationStatus {
@@ -313,6 +371,8 @@ impl From<Shim> for rpc::Shim {
         match s {
             Shim::Host => Self::Host,
             Shim::Wasm => Self::Wasm,
+            Shim::Grpc => Self::Grpc,
+            Shim::Http => Self::Http,
         }
     }
 }
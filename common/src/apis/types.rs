@@ -17,8 +17,12 @@ use std::{env, fmt};
 use chrono::{DateTime, Duration, Utc};
 #[cfg(target_os = "linux")]
 use rustix::system;
+use serde_derive::{Deserialize, Serialize};
 use stdng::MutexPtr;
 
+use super::principal::Principal;
+use crate::FlameError;
+
 pub const DEFAULT_MAX_INSTANCES: u32 = 1_000_000;
 pub const DEFAULT_DELAY_RELEASE: Duration = Duration::seconds(60);
 
@@ -42,6 +46,20 @@ pub struct EventOwner {
     pub session_id: SessionID,
 }
 
+/// The task id of the events owned by the session itself, e.g. the executors
+/// unbound by their failures; the ids of the tasks start from 1.
+pub const SESSION_EVENT_OWNER: TaskID = 0;
+
+impl EventOwner {
+    /// The owner of the events of the session itself.
+    pub fn session(session_id: SessionID) -> Self {
+        Self {
+            task_id: SESSION_EVENT_OWNER,
+            session_id,
+        }
+    }
+}
+
 #[derive(Clone, Debug)]
 pub struct Event {
     pub code: i32,
@@ -49,11 +67,19 @@ pub struct Event {
     pub creation_time: DateTime<Utc>,
 }
 
+/// The prefix of the reference to the output of another task of the same
+/// session, e.g. `task://3`, sent by the executors instead of a repeated
+/// output; the session manager copies the referenced output.
+pub const TASK_OUTPUT_REF_PREFIX: &str = "task://";
+
 #[derive(Clone, Debug, Default)]
 pub struct TaskResult {
     pub state: TaskState,
     pub output: Option<TaskOutput>,
     pub message: Option<String>,
+    /// The reference of the output kept on the host of the executor, instead
+    /// of the output itself.
+    pub output_ref: Option<String>,
 }
 
 #[derive(Clone, Copy, Debug, Default, Eq, PartialEq, Hash, strum_macros::Display)]
@@ -68,6 +94,8 @@ pub enum Shim {
     #[default]
     Host = 0,
     Wasm = 1,
+    Grpc = 2,
+    Http = 3,
 }
 
 #[derive(Clone, Debug, Default)]
@@ -75,6 +103,28 @@ pub struct ApplicationSchema {
     pub input: Option<String>,
     pub output: Option<String>,
     pub common_data: Option<String>,
+    /// The content types of the inputs, in the order of preference.
+    pub content_types: Vec<String>,
+}
+
+impl ApplicationSchema {
+    /// The content type of a session by the one requested by the client: the
+    /// first content type of the application by default; any content type is
+    /// accepted if the application declares none.
+    pub fn negotiate(&self, content_type: Option<&str>) -> Result<Option<String>, FlameError> {
+        match content_type {
+            None => Ok(self.content_types.first().cloned()),
+            Some(ct)
+                if self.content_types.is_empty() || self.content_types.iter().any(|c| c == ct) =>
+            {
+                Ok(Some(ct.to_string()))
+            }
+            Some(ct) => Err(FlameError::InvalidConfig(format!(
+                "content type <{ct}> is not supported, expected one of {:?}",
+                self.content_types
+            ))),
+        }
+    }
 }
 
 #[derive(Clone, Debug, Default)]
@@ -132,6 +182,25 @@ impl Default for ApplicationAttributes {
     }
 }
 
+impl From<&Application> for ApplicationAttributes {
+    fn from(app: &Application) -> Self {
+        Self {
+            shim: app.shim,
+            image: app.image.clone(),
+            description: app.description.clone(),
+            labels: app.labels.clone(),
+            command: app.command.clone(),
+            arguments: app.arguments.clone(),
+            environments: app.environments.clone(),
+            working_directory: app.working_directory.clone(),
+            max_instances: app.max_instances,
+            delay_release: app.delay_release,
+            schema: app.schema.clone(),
+            url: app.url.clone(),
+        }
+    }
+}
+
 #[derive(Clone, Debug)]
 pub struct SessionAttributes {
     pub id: SessionID,
@@ -141,6 +210,16 @@ pub struct SessionAttributes {
     pub min_instances: u32,
     pub max_instances: Option<u32>,
     pub batch_size: u32,
+    /// The content type of the inputs of the tasks, e.g. `application/json`.
+    pub content_type: Option<String>,
+    /// The host of the client which created the session; the outputs are
+    /// only kept on the host of the executor for the clients on it.
+    pub client_host: Option<String>,
+    /// The labels of the session, e.g. `team=ml`, to filter the sessions.
+    pub labels: Vec<String>,
+    /// The environments injected by the admission hooks, which are merged
+    /// into the ones of the application when the executor is bound.
+    pub environments: HashMap<String, String>,
 }
 
 impl Default for SessionAttributes {
@@ -153,6 +232,28 @@ impl Default for SessionAttributes {
             min_instances: 0,
             max_instances: None,
             batch_size: 1,
+            content_type: None,
+            client_host: None,
+            labels: vec![],
+            environments: HashMap::new(),
+        }
+    }
+}
+
+impl From<&Session> for SessionAttributes {
+    fn from(ssn: &Session) -> Self {
+        Self {
+            id: ssn.id.clone(),
+            application: ssn.application.clone(),
+            slots: ssn.slots,
+            common_data: ssn.common_data.clone(),
+            min_instances: ssn.min_instances,
+            max_instances: ssn.max_instances,
+            batch_size: ssn.batch_size,
+            content_type: ssn.content_type.clone(),
+            client_host: ssn.client_host.clone(),
+            labels: ssn.labels.clone(),
+            environments: ssn.environments.clone(),
         }
     }
 }
@@ -185,6 +286,10 @@ pub struct Session {
     pub min_instances: u32,
     pub max_instances: Option<u32>,
     pub batch_size: u32,
+    pub content_type: Option<String>,
+    pub client_host: Option<String>,
+    pub labels: Vec<String>,
+    pub environments: HashMap<String, String>,
 }
 
 #[derive(Clone, Copy, Debug, Default, Eq, PartialEq, Hash, strum_macros::Display)]
@@ -209,6 +314,47 @@ pub struct TaskGID {
     pub task_id: TaskID,
 }
 
+/// The attributes of a task given by the submitter, e.g. by CreateTask.
+#[derive(Clone, Debug, Default)]
+pub struct TaskAttributes {
+    pub input: Option<TaskInput>,
+    pub principal: Option<Principal>,
+    pub tags: Vec<String>,
+    pub deadline: Option<DateTime<Utc>>,
+    pub group: Option<String>,
+    pub labels: Vec<String>,
+    pub run_at: Option<DateTime<Utc>>,
+    pub depends_on: Vec<TaskID>,
+    /// It's recorded by the session manager when the task is created.
+    pub provenance: Option<TaskProvenance>,
+}
+
+impl From<&Task> for TaskAttributes {
+    fn from(task: &Task) -> Self {
+        Self {
+            input: task.input.clone(),
+            principal: task.principal.clone(),
+            tags: task.tags.clone(),
+            deadline: task.deadline,
+            group: task.group.clone(),
+            labels: task.labels.clone(),
+            run_at: task.run_at,
+            depends_on: task.depends_on.clone(),
+            provenance: task.provenance.clone(),
+        }
+    }
+}
+
+/// The provenance of a task, recorded when it's created.
+#[derive(Clone, Debug, Default, PartialEq, Eq, Serialize, Deserialize)]
+pub struct TaskProvenance {
+    /// The version of the application of the session when the task was
+    /// created, e.g. to find the tasks of a bad version.
+    pub application_version: u32,
+    /// The checksum of the input when the task was created.
+    pub input_checksum: Option<String>,
+}
+
 #[derive(Clone, Debug)]
 pub struct Task {
     pub id: TaskID,
@@ -216,6 +362,31 @@ pub struct Task {
     pub version: u32,
     pub input: Option<TaskInput>,
     pub output: Option<TaskOutput>,
+    /// The reference of the output kept on the host of the executor, e.g.
+    /// `file://<host>/<path>`; the output is not set if so.
+    pub output_ref: Option<String>,
+    /// The identity of the user who submitted the task.
+    pub principal: Option<Principal>,
+    /// The tags of the task; it's only launched on the executors whose node
+    /// advertises all of them.
+    pub tags: Vec<String>,
+    /// The deadline of the task, e.g. by the context of the client; it's
+    /// failed by the executor if not completed in time.
+    pub deadline: Option<DateTime<Utc>>,
+    /// The group of the task in the session, e.g. a phase of a pipeline.
+    pub group: Option<String>,
+    /// The labels of the task, e.g. `trace-id=abc`, to filter the tasks; they
+    /// do not affect the scheduling, unlike the tags.
+    pub labels: Vec<String>,
+    /// The task is not launched before the time, e.g. a delayed or periodic
+    /// work; it's pending meanwhile.
+    pub run_at: Option<DateTime<Utc>>,
+    /// The tasks of the session which the task depends on; it's not launched
+    /// until all of them succeeded.
+    pub depends_on: Vec<TaskID>,
+    /// How the task was created; with the principal and the parents of the
+    /// task, it traces how the output was produced.
+    pub provenance: Option<TaskProvenance>,
     pub creation_time: DateTime<Utc>,
     pub completion_time: Option<DateTime<Utc>>,
     pub events: Vec<Event>,
@@ -230,6 +401,15 @@ impl Default for Task {
             version: 0,
             input: None,
             output: None,
+            output_ref: None,
+            principal: None,
+            tags: Vec::new(),
+            deadline: None,
+            group: None,
+            labels: Vec::new(),
+            run_at: None,
+            depends_on: Vec::new(),
+            provenance: None,
             creation_time: Utc::now(),
             completion_time: None,
             events: Vec::new(),
@@ -249,6 +429,18 @@ impl Task {
             task_id: self.id,
         }
     }
+
+    /// Whether the task can be launched on the executor with the tags, i.e.
+    /// the executor has all the tags of the task.
+    pub fn is_qualified(&self, tags: &[String]) -> bool {
+        self.tags.iter().all(|tag| tags.contains(tag))
+    }
+
+    /// Whether the task can be launched at the time, i.e. it's not scheduled
+    /// after the time.
+    pub fn is_due(&self, now: DateTime<Utc>) -> bool {
+        self.run_at.map_or(true, |run_at| run_at <= now)
+    }
 }
 
 #[derive(Clone, Copy, Default, Debug, Eq, PartialEq, Hash, strum_macros::Display)]
@@ -269,6 +461,41 @@ pub struct TaskContext {
     pub task_id: String,
     pub session_id: String,
     pub input: Option<TaskInput>,
+    pub principal: Option<Principal>,
+    pub deadline: Option<DateTime<Utc>>,
+    /// The content type of the input, stamped by the session.
+    pub content_type: Option<String>,
+    /// The group of the task, which the service enters before the task.
+    pub group: Option<String>,
+    /// The method of the service to invoke, by the `flame.io/method` label.
+    pub method: Option<String>,
+    /// The priority of the session of the task when it's launched.
+    pub priority: Option<u32>,
+    /// The sequence of the launch of the task, echoed by its completion and
+    /// the renewals of its lease; the replayed ones of an earlier launch are
+    /// rejected by the session manager.
+    pub sequence: Option<u64>,
+}
+
+impl TaskContext {
+    /// The remaining time before the deadline of the task, which is zero if
+    /// the deadline is exceeded; None if the task has no deadline.
+    pub fn remaining(&self) -> Option<std::time::Duration> {
+        self.deadline
+            .map(|deadline| (deadline - Utc::now()).to_std().unwrap_or_default())
+    }
+
+    pub fn is_expired(&self) -> bool {
+        self.remaining()
+            .is_some_and(|remaining| remaining.is_zero())
+    }
+}
+
+/// The group of the tasks in a session, entered and left by the service.
+#[derive(Clone, Debug, PartialEq, Eq)]
+pub struct GroupContext {
+    pub session_id: String,
+    pub name: String,
 }
 
 #[derive(Clone, Debug)]
@@ -277,9 +504,15 @@ pub struct SessionContext {
     pub application: ApplicationContext,
     pub slots: u32,
     pub common_data: Option<CommonData>,
+    /// The path of the scratch directory provisioned for the session, if any.
+    pub scratch_dir: Option<String>,
+    /// The content type of the inputs of the tasks, negotiated at creation.
+    pub content_type: Option<String>,
+    /// The host of the client which created the session, if known.
+    pub client_host: Option<String>,
 }
 
-#[derive(Clone, Debug)]
+#[derive(Clone, Debug, PartialEq)]
 pub struct ApplicationContext {
     pub name: String,
     pub shim: Shim,
@@ -289,6 +522,8 @@ pub struct ApplicationContext {
     pub working_directory: Option<String>,
     pub environments: HashMap<String, String>,
     pub url: Option<String>,
+    /// The JSON schema of the task outputs, which are validated by the shim.
+    pub output_schema: Option<String>,
 }
 
 #[derive(Clone, Copy, Debug, Default, Eq, PartialEq, Hash, strum_macros::Display)]
@@ -303,6 +538,48 @@ pub enum NodeState {
 pub struct NodeInfo {
     pub arch: String,
     pub os: String,
+    /// The labels of the node, e.g. from the cloud metadata; the executors on
+    /// the node share them.
+    pub labels: HashMap<String, String>,
+}
+
+/// The well-known labels of nodes populated from the cloud metadata.
+pub const LABEL_CLOUD_PROVIDER: &str = "flame.io/cloud-provider";
+pub const LABEL_INSTANCE_TYPE: &str = "node.kubernetes.io/instance-type";
+pub const LABEL_REGION: &str = "topology.kubernetes.io/region";
+pub const LABEL_ZONE: &str = "topology.kubernetes.io/zone";
+/// "true" if the node is a spot/preemptible instance, otherwise "false".
+pub const LABEL_SPOT: &str = "flame.io/spot";
+/// The comma-separated tags advertised by the executors of the node, e.g.
+/// "huge-memory,gpu".
+pub const LABEL_TAGS: &str = "flame.io/tags";
+
+/// The tags advertised by the labels of a node.
+pub fn tags_of(labels: &HashMap<String, String>) -> Vec<String> {
+    labels
+        .get(LABEL_TAGS)
+        .map(|tags| {
+            tags.split(',')
+                .map(str::trim)
+                .filter(|tag| !tag.is_empty())
+                .map(str::to_string)
+                .collect()
+        })
+        .unwrap_or_default()
+}
+
+/// The label of a task for the method of the service to invoke, e.g.
+/// `flame.io/method=resize`, so one application serves several operations.
+pub const LABEL_METHOD: &str = "flame.io/method";
+
+/// The method of the service given by the labels of a task, if any.
+pub fn method_of(labels: &[String]) -> Option<String> {
+    labels.iter().find_map(|label| {
+        label
+            .strip_prefix(LABEL_METHOD)
+            .and_then(|m| m.strip_prefix('='))
+            .map(str::to_string)
+    })
 }
 
 #[derive(Clone, Debug, Default, PartialEq, Eq, PartialOrd, Ord)]
@@ -357,13 +634,11 @@ impl Node {
         let cpu = num_cpus::get() as u64;
         let capacity = ResourceRequirement { cpu, memory };
         let allocatable = capacity.clone();
-        let info = NodeInfo {
-            arch: env::consts::ARCH.to_string(),
-            os: env::consts::OS.to_string(),
-        };
         self.capacity = capacity;
         self.allocatable = allocatable;
-        self.info = info;
+        // The labels are kept as they're not changed after the node started.
+        self.info.arch = env::consts::ARCH.to_string();
+        self.info.os = env::consts::OS.to_string();
     }
 }
 
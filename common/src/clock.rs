@@ -0,0 +1,219 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//! The clock of the timeouts, leases and timers, so the tests and simulations
+//! can drive the time by `VirtualClock` instead of sleeping.
+
+use std::future::Future;
+use std::pin::Pin;
+use std::sync::Arc;
+use std::time::{Duration, Instant};
+
+use async_trait::async_trait;
+use chrono::{DateTime, Utc};
+use tokio::sync::watch;
+
+pub type ClockPtr = Arc<dyn Clock>;
+
+#[async_trait]
+pub trait Clock: Send + Sync + 'static {
+    /// The monotonic time, e.g. for timeouts and leases.
+    fn now(&self) -> Instant;
+
+    /// The wall time, e.g. for the creation and completion time of objects.
+    fn utc_now(&self) -> DateTime<Utc>;
+
+    async fn sleep(&self, duration: Duration);
+}
+
+/// The clock of the system.
+pub fn system() -> ClockPtr {
+    Arc::new(SystemClock)
+}
+
+/// Run the future with a timeout of the clock; None if it's timed out.
+pub async fn timeout<F: Future>(
+    clock: &dyn Clock,
+    duration: Duration,
+    future: F,
+) -> Option<F::Output> {
+    tokio::select! {
+        res = future => Some(res),
+        _ = clock.sleep(duration) => None,
+    }
+}
+
+#[derive(Clone, Copy, Debug, Default)]
+pub struct SystemClock;
+
+#[async_trait]
+impl Clock for SystemClock {
+    fn now(&self) -> Instant {
+        Instant::now()
+    }
+
+    fn utc_now(&self) -> DateTime<Utc> {
+        Utc::now()
+    }
+
+    async fn sleep(&self, duration: Duration) {
+        tokio::time::sleep(duration).await
+    }
+}
+
+/// The clock which only moves when it's advanced; the sleepers are woken up
+/// once the virtual time reaches their deadlines.
+pub struct VirtualClock {
+    start: Instant,
+    start_utc: DateTime<Utc>,
+    elapsed: watch::Sender<Duration>,
+}
+
+impl Default for VirtualClock {
+    fn default() -> Self {
+        Self::new()
+    }
+}
+
+impl VirtualClock {
+    pub fn new() -> Self {
+        Self::with_start(Utc::now())
+    }
+
+    /// Create the clock whose wall time starts at `start_utc`.
+    pub fn with_start(start_utc: DateTime<Utc>) -> Self {
+        let (elapsed, _) = watch::channel(Duration::ZERO);
+        Self {
+            start: Instant::now(),
+            start_utc,
+            elapsed,
+        }
+    }
+
+    pub fn new_ptr() -> Arc<Self> {
+        Arc::new(Self::new())
+    }
+
+    /// The virtual time elapsed since the clock was created.
+    pub fn elapsed(&self) -> Duration {
+        *self.elapsed.borrow()
+    }
+
+    /// Wait until there are at least `n` sleepers, e.g. so a test advances the
+    /// clock after the timeout of its spawned task started.
+    pub async fn wait_for_sleepers(&self, n: usize) {
+        while self.elapsed.receiver_count() < n {
+            tokio::task::yield_now().await;
+        }
+    }
+
+    /// Move the clock forward and wake up the sleepers whose deadlines are
+    /// reached.
+    pub fn advance(&self, duration: Duration) {
+        self.elapsed.send_modify(|elapsed| *elapsed += duration);
+    }
+}
+
+#[async_trait]
+impl Clock for VirtualClock {
+    fn now(&self) -> Instant {
+        self.start + self.elapsed()
+    }
+
+    fn utc_now(&self) -> DateTime<Utc> {
+        // The elapsed time is always far below the range of chrono.
+        self.start_utc + chrono::Duration::from_std(self.elapsed()).unwrap_or_default()
+    }
+
+    // Not `async`, so the deadline and the subscription are taken when the
+    // sleep is created instead of when it's first polled: the clock advanced
+    // in between doesn't delay the sleeper, which is counted by
+    // `wait_for_sleepers` at once.
+    fn sleep<'life0, 'async_trait>(
+        &'life0 self,
+        duration: Duration,
+    ) -> Pin<Box<dyn Future<Output = ()> + Send + 'async_trait>>
+    where
+        'life0: 'async_trait,
+        Self: 'async_trait,
+    {
+        let deadline = self.elapsed() + duration;
+        let mut rx = self.elapsed.subscribe();
+        Box::pin(async move {
+            // The sender lives as long as the clock, so it never fails here.
+            let _ = rx.wait_for(|elapsed| *elapsed >= deadline).await;
+        })
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[tokio::test]
+    async fn test_virtual_clock() {
+        let clock = VirtualClock::new_ptr();
+        let now = clock.now();
+        let utc_now = clock.utc_now();
+
+        let sleeper = {
+            let clock = clock.clone();
+            tokio::spawn(async move { clock.sleep(Duration::from_secs(60)).await })
+        };
+        // Let the sleeper start before the clock moves.
+        clock.wait_for_sleepers(1).await;
+
+        clock.advance(Duration::from_secs(30));
+        tokio::task::yield_now().await;
+        assert!(!sleeper.is_finished());
+
+        clock.advance(Duration::from_secs(30));
+        sleeper.await.unwrap();
+
+        assert_eq!(clock.now() - now, Duration::from_secs(60));
+        assert_eq!(clock.utc_now() - utc_now, chrono::Duration::seconds(60));
+
+        // Sleeping for zero returns immediately.
+        clock.sleep(Duration::ZERO).await;
+
+        // The deadline is taken when the sleep is created, not when it's
+        // first polled.
+        let sleep = clock.sleep(Duration::from_secs(10));
+        clock.wait_for_sleepers(1).await;
+        clock.advance(Duration::from_secs(10));
+        sleep.await;
+    }
+
+    #[tokio::test]
+    async fn test_virtual_timeout() {
+        let clock = VirtualClock::new_ptr();
+
+        let res = timeout(clock.as_ref(), Duration::from_secs(10), async { 1 }).await;
+        assert_eq!(res, Some(1));
+
+        let pending = {
+            let clock = clock.clone();
+            tokio::spawn(async move {
+                timeout(
+                    clock.as_ref(),
+                    Duration::from_secs(10),
+                    std::future::pending::<()>(),
+                )
+                .await
+            })
+        };
+        tokio::task::yield_now().await;
+        clock.advance(Duration::from_secs(10));
+        assert_eq!(pending.await.unwrap(), None);
+    }
+}
@@ -0,0 +1,54 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//! The cryptographic module of the process. With the `fips` feature, the
+//! FIPS-validated module of AWS-LC is the default provider of rustls, and the
+//! checksums are SHA-256 by default; the xxh3 checksums of the other peers
+//! are still verified, as they're not used for security.
+
+use crate::FlameError;
+
+/// Whether the process is built with the FIPS-validated module.
+pub fn is_fips() -> bool {
+    cfg!(feature = "fips")
+}
+
+/// Install the cryptographic module of the process; it must be called before
+/// any TLS connection, e.g. at the beginning of `main`.
+#[cfg(feature = "fips")]
+pub fn init() -> Result<(), FlameError> {
+    let provider = rustls::crypto::default_fips_provider();
+    if !provider.fips() {
+        return Err(FlameError::InvalidConfig(
+            "the crypto provider is not in FIPS mode".to_string(),
+        ));
+    }
+
+    // The provider may be installed by the caller, e.g. the tests.
+    if provider.install_default().is_err() {
+        let installed = rustls::crypto::CryptoProvider::get_default();
+        if !installed.is_some_and(|p| p.fips()) {
+            return Err(FlameError::InvalidConfig(
+                "a crypto provider without FIPS mode was installed".to_string(),
+            ));
+        }
+    }
+
+    tracing::info!("The FIPS-validated crypto provider is installed.");
+    Ok(())
+}
+
+#[cfg(not(feature = "fips"))]
+pub fn init() -> Result<(), FlameError> {
+    Ok(())
+}
@@ -11,16 +11,18 @@ See the License for the specific language governing permissions and
 limitations under the License.
 */
 
+use std::collections::HashMap;
 use std::fmt::{Display, Formatter};
 use std::fs;
 use std::path::Path;
+use std::time::Duration;
 
 use bytesize::ByteSize;
 use serde_derive::{Deserialize, Serialize};
 use tonic::transport::server::ServerTlsConfig;
 use tonic::transport::{Certificate, ClientTlsConfig, Identity};
 
-use crate::apis::{ResourceRequirement, Shim};
+use crate::apis::{ResourceRequirement, Shim, LABEL_INSTANCE_TYPE, LABEL_SPOT};
 use crate::FlameError;
 
 const DEFAULT_FLAME_CONF: &str = "flame-cluster.yaml";
@@ -36,6 +38,32 @@ const DEFAULT_FLAME_CACHE_ENDPOINT: &str = "http://127.0.0.1:9090";
 const DEFAULT_FLAME_CACHE_NETWORK_INTERFACE: &str = "eth0";
 const DEFAULT_EVICTION_POLICY: &str = "lru";
 const DEFAULT_MAX_MEMORY: &str = "1G";
+const DEFAULT_SCRATCH_ROOT: &str = "/var/flame/scratch";
+const DEFAULT_LOCAL_RESULTS_ROOT: &str = "/tmp/flame/results";
+const DEFAULT_LOCAL_RESULTS_MIN_SIZE: &str = "64K";
+const DEFAULT_LOCAL_RESULTS_RETENTION: u64 = 3600;
+const DEFAULT_SLOW_TASK_THRESHOLD: u64 = 300;
+const DEFAULT_SLOW_TASK_MAX_SIZE: &str = "64K";
+const DEFAULT_GRACE_PERIOD: u64 = 30;
+const DEFAULT_RESTART_BUDGET: u32 = 5;
+const DEFAULT_RESTART_BACKOFF: u64 = 1;
+const DEFAULT_RESTART_MAX_BACKOFF: u64 = 300;
+const DEFAULT_CLOUD_METADATA: &str = "auto";
+const DEFAULT_AGING_CURVE: &str = "none";
+const DEFAULT_AGING_INTERVAL: u64 = 60;
+const DEFAULT_AGING_MAX_PRIORITY: u32 = 10;
+const DEFAULT_PRICE: f64 = 1.0;
+const DEFAULT_SPOT_DISCOUNT: f64 = 0.3;
+const DEFAULT_HOOK_TIMEOUT: u64 = 3000;
+const DEFAULT_HOOK_FAILURE_POLICY: &str = "fail";
+const DEFAULT_HOOK_POLICY_QUERY: &str = "data.flame.admission";
+const DEFAULT_JOURNAL_SIZE: usize = 128;
+const DEFAULT_SYSLOG_ADDRESS: &str = "udp://127.0.0.1:514";
+const DEFAULT_JOURNAL_EXECUTORS: usize = 1024;
+const DEFAULT_CHAOS_MAX_COMPLETE_DELAY: u64 = 5000;
+const DEFAULT_TIMELINE_STORAGE: &str = "file:///var/lib/flame/timeline";
+const DEFAULT_TIMELINE_INTERVAL: u64 = 10;
+const DEFAULT_TIMELINE_RETENTION: u64 = 7 * 24 * 3600;
 
 // ============================================================
 // YAML deserialization structs (serde layer)
@@ -62,12 +90,163 @@ struct FlameClusterYaml {
     pub tls: Option<FlameTlsYaml>,
     /// Resource limits configuration
     pub limits: Option<FlameLimitsYaml>,
+    /// Priority aging of the waiting sessions
+    pub aging: Option<FlameAgingYaml>,
+    /// Price table of the cost-aware scheduling
+    pub cost: Option<FlameCostYaml>,
+    /// Admission and mutation webhooks of the sessions and tasks
+    pub hooks: Option<Vec<FlameHookYaml>>,
+    /// Journal of the backend RPCs per executor for debugging
+    pub journal: Option<FlameJournalYaml>,
+    /// Warm standby of another session manager
+    pub standby: Option<FlameStandbyYaml>,
+    /// Authentication of the replication between the leader and its standbys
+    pub replication: Option<FlameReplicationYaml>,
+    /// Time series of the scheduler metrics for the post-mortems
+    pub timeline: Option<FlameTimelineYaml>,
+    /// Names of the scheduler plugins consulted in order
+    pub plugins: Option<Vec<String>>,
+    /// Faults injected for the soak tests, only with --unsafe-chaos
+    pub chaos: Option<FlameChaosYaml>,
+    /// Encryption of the persisted task data by the keys of the tenants
+    pub encryption: Option<FlameEncryptionYaml>,
+    /// Trust the principal headers of the authenticating proxy
+    pub trust_proxy_headers: Option<bool>,
+}
+
+#[derive(Debug, Clone, Serialize, Deserialize)]
+struct FlameEncryptionYaml {
+    /// The KMS of the keys, e.g. "file:///var/lib/flame/keys"
+    pub kms: String,
+}
+
+#[derive(Debug, Clone, Serialize, Deserialize)]
+struct FlameChaosYaml {
+    /// Probability of disconnecting the node at each message of its WatchNode stream
+    pub disconnect_executor: Option<f64>,
+    /// Probability of delaying a CompleteTask
+    pub delay_complete_task: Option<f64>,
+    /// Maximum delay in milliseconds of the delayed CompleteTask
+    pub max_complete_delay: Option<u64>,
+    /// Probability of dropping an event of the tasks
+    pub drop_events: Option<f64>,
+}
+
+#[derive(Debug, Clone, Serialize, Deserialize)]
+struct FlameTimelineYaml {
+    /// The directory of the samples, e.g. "file:///var/lib/flame/timeline" (default), or "memory"
+    pub storage: Option<String>,
+    /// Interval in seconds between two samples
+    pub interval: Option<u64>,
+    /// Retention in seconds of the samples
+    pub retention: Option<u64>,
+}
+
+#[derive(Debug, Clone, Serialize, Deserialize)]
+struct FlameStandbyYaml {
+    /// The frontend endpoint of the leader to follow
+    pub leader: String,
+}
+
+#[derive(Debug, Clone, Serialize, Deserialize)]
+struct FlameReplicationYaml {
+    /// The file of the token shared by the leader and its standbys
+    pub token_file: String,
+}
+
+#[derive(Debug, Clone, Serialize, Deserialize)]
+struct FlameJournalYaml {
+    /// Maximum entries kept for each executor
+    pub size: Option<usize>,
+    /// Maximum executors kept in the journal
+    pub executors: Option<usize>,
 }
 
 #[derive(Debug, Clone, Serialize, Deserialize)]
 struct FlameExecutorsYaml {
     pub shim: Option<String>,
     pub limits: Option<FlameExecutorLimitsYaml>,
+    /// Per-session scratch directory of executors
+    pub scratch: Option<FlameScratchYaml>,
+    /// Grace period in seconds to drain the executors on SIGTERM
+    pub grace_period: Option<u64>,
+    /// Lease in seconds of a running task, renewed by the executor
+    pub task_lease: Option<u64>,
+    /// Cloud metadata to label the node: "auto", "none", "aws", "gcp" or "azure"
+    pub cloud_metadata: Option<String>,
+    /// Keep the task outputs on the host for the clients on the same host
+    pub local_results: Option<FlameLocalResultsYaml>,
+    /// Forward the logs of the services to the centralized sinks
+    pub logs: Option<Vec<FlameLogForwarderYaml>>,
+    /// Sample the stacks of the services when their tasks are slow
+    pub slow_tasks: Option<FlameSlowTasksYaml>,
+    /// The tags of the executors, e.g. "huge-memory", for the tagged tasks
+    pub tags: Option<Vec<String>>,
+    /// Seconds to keep the service warm for the next session of its application
+    pub warm_shim_ttl: Option<u64>,
+    /// The endpoint of the backend, e.g. a gateway for the nodes behind NAT
+    pub backend_endpoint: Option<String>,
+    /// Seconds between the keepalive pings of the connection to the backend
+    pub keepalive: Option<u64>,
+    /// Minimum size of the outputs deduplicated within a session (string with units: "64K", "1M")
+    pub dedup_outputs: Option<String>,
+    /// Back off the restarts of the crashing services of an application
+    pub restarts: Option<FlameRestartsYaml>,
+}
+
+#[derive(Debug, Clone, Serialize, Deserialize)]
+struct FlameRestartsYaml {
+    /// The consecutive crashes before the application is crash-looping
+    pub budget: Option<u32>,
+    /// The backoff in seconds before the first restart, doubled by each crash
+    pub backoff: Option<u64>,
+    /// Maximum backoff in seconds, also the time a crash-looping application is excluded
+    pub max_backoff: Option<u64>,
+}
+
+#[derive(Debug, Clone, Serialize, Deserialize)]
+struct FlameSlowTasksYaml {
+    /// The running time in seconds after which a task is slow
+    pub threshold: Option<u64>,
+    /// Maximum size of the sampled stacks (string with units: "64K", "1M")
+    pub max_size: Option<String>,
+}
+
+#[derive(Debug, Clone, Serialize, Deserialize)]
+struct FlameLogForwarderYaml {
+    /// The sink of the logs: "loki", "cloudwatch" or "syslog"
+    #[serde(rename = "type")]
+    pub kind: String,
+    /// The base URL of Loki, e.g. "http://loki:3100"
+    pub url: Option<String>,
+    /// The extra labels of the Loki streams
+    pub labels: Option<HashMap<String, String>>,
+    /// The log group of CloudWatch Logs
+    pub group: Option<String>,
+    /// The region of CloudWatch Logs; the region of the environment if not set
+    pub region: Option<String>,
+    /// The address of syslog: "udp://host:port" or "tcp://host:port"
+    pub address: Option<String>,
+}
+
+#[derive(Debug, Clone, Serialize, Deserialize)]
+struct FlameLocalResultsYaml {
+    /// The root directory of the task outputs
+    pub root: Option<String>,
+    /// Minimum size of the outputs kept on the host (string with units: "64K", "1M")
+    pub min_size: Option<String>,
+    /// Retention in seconds of the outputs of a session
+    pub retention: Option<u64>,
+}
+
+#[derive(Debug, Clone, Serialize, Deserialize)]
+struct FlameScratchYaml {
+    /// The root directory of the scratch directories
+    pub root: Option<String>,
+    /// Maximum size of a scratch directory (string with units: "1G", "512M")
+    pub size_limit: Option<String>,
+    /// Mount the scratch directory as tmpfs
+    pub tmpfs: Option<bool>,
 }
 
 #[derive(Debug, Clone, Serialize, Deserialize)]
@@ -81,6 +260,46 @@ struct FlameLimitsYaml {
     pub max_executors: Option<u32>,
 }
 
+#[derive(Debug, Clone, Serialize, Deserialize)]
+struct FlameAgingYaml {
+    /// Aging curve: "none", "linear" or "exponential"
+    pub curve: Option<String>,
+    /// Seconds of waiting per aging step
+    pub interval: Option<u64>,
+    /// The cap of the effective priority gained by aging
+    pub max_priority: Option<u32>,
+}
+
+#[derive(Debug, Clone, Serialize, Deserialize)]
+struct FlameCostYaml {
+    /// Price per hour of the instance types, e.g. "m5.large": 0.096
+    pub prices: Option<HashMap<String, f64>>,
+    /// Price of the nodes whose instance type is not in the table
+    pub default_price: Option<f64>,
+    /// Ratio of the spot price to the on-demand price
+    pub spot_discount: Option<f64>,
+}
+
+#[derive(Debug, Clone, Serialize, Deserialize)]
+struct FlameHookYaml {
+    pub name: String,
+    /// The URL which the reviews are posted to
+    pub url: Option<String>,
+    /// The Rego policy evaluated in place of the webhook: a .rego file, or a
+    /// bundle directory of .rego files and an optional data.json
+    pub policy: Option<String>,
+    /// The query of the decision in the policy
+    pub query: Option<String>,
+    /// The reviewed operations: "create_session" and "submit_task"; all if not set
+    pub operations: Option<Vec<String>>,
+    /// Whether the hook may mutate the session, or only validate it
+    pub mutating: Option<bool>,
+    /// Timeout in milliseconds of each review by the webhook
+    pub timeout: Option<u64>,
+    /// "fail" to reject, or "ignore" to admit, if the hook is not available
+    pub failure_policy: Option<String>,
+}
+
 #[derive(Debug, Clone, Serialize, Deserialize)]
 struct FlameTlsYaml {
     /// Path to PEM-encoded server certificate
@@ -136,11 +355,165 @@ pub struct FlameCluster {
     pub tls: Option<FlameTls>,
     /// Resource limits configuration
     pub limits: FlameLimits,
+    /// Priority aging of the waiting sessions
+    pub aging: FlameAging,
+    /// The cost-aware scheduling is only enabled if the price table is set
+    pub cost: Option<FlameCost>,
+    /// The webhooks are called in order on the reviewed operations
+    pub hooks: Vec<FlameHook>,
+    /// The backend RPCs are only journaled if configured.
+    pub journal: Option<FlameJournal>,
+    /// The session manager starts as the warm standby of the leader if set.
+    pub standby: Option<FlameStandby>,
+    /// The state is only streamed to, and the standby only promoted by, the
+    /// callers with the token of the replication; required by the standby.
+    pub replication: Option<FlameReplication>,
+    /// The scheduler metrics are only recorded if configured.
+    pub timeline: Option<FlameTimeline>,
+    /// The scheduler plugins enabled in order, e.g. the built-in and the
+    /// custom plugins built into the session manager; all of them in their
+    /// default order if not configured.
+    pub plugins: Option<Vec<String>>,
+    /// The faults are only injected if configured, see `check_chaos`.
+    pub chaos: Option<FlameChaos>,
+    /// The task data is only encrypted if configured.
+    pub encryption: Option<FlameEncryption>,
+    /// The principal of the requests is only taken from the `x-flame-*`
+    /// headers if the frontend is behind an authenticating proxy, which
+    /// strips them from the clients; they're ignored otherwise.
+    pub trust_proxy_headers: bool,
 }
 
-#[derive(Debug, Clone, Default)]
+#[derive(Debug, Clone)]
 pub struct FlameExecutors {
     pub shim: Shim,
+    /// The scratch directory is only provisioned if configured.
+    pub scratch: Option<FlameScratch>,
+    /// Grace period in seconds to wait for the in-flight tasks when draining.
+    pub grace_period: u64,
+    /// The running task is re-queued if its lease is not renewed within the
+    /// duration in seconds; no lease if not configured.
+    pub task_lease: Option<u64>,
+    /// The cloud provider whose metadata labels the node at registration.
+    pub cloud_metadata: CloudMetadata,
+    /// The task outputs are also kept on the host of the executor, and their
+    /// references are sent to the session manager with the outputs; only for
+    /// local mode, where the clients are on the same host.
+    pub local_results: Option<FlameLocalResults>,
+    /// The stdout and stderr of the services are forwarded to the sinks in
+    /// order, tagged with the executor, session and task IDs.
+    pub logs: Vec<FlameLogForwarder>,
+    /// The stacks of the services are sampled, and attached to the tasks
+    /// running longer than the threshold; only for the applications with a
+    /// stack endpoint.
+    pub slow_tasks: Option<FlameSlowTasks>,
+    /// The tags advertised by the executors of the node; the tagged tasks are
+    /// only launched on the executors with all of their tags.
+    pub tags: Vec<String>,
+    /// The service is kept alive for the duration in seconds after its
+    /// session is unbound, and reused by the next session of the same
+    /// application; only for the services resetting their state.
+    pub warm_shim_ttl: Option<u64>,
+    /// The endpoint of the backend dialed by the executor managers, e.g. the
+    /// public gateway of the session manager for the edge nodes behind NAT;
+    /// the port next to the one of the cluster endpoint if not configured.
+    pub backend_endpoint: Option<String>,
+    /// The interval in seconds of the HTTP/2 keepalive pings to the backend,
+    /// so the NAT mappings of the idle connections are kept and the dead
+    /// connections are detected; no pings if not configured.
+    pub keepalive: Option<u64>,
+    /// The outputs of at least the size in bytes are hashed, and a repeated
+    /// output of the session is sent as the reference to the task of its
+    /// first occurrence; no deduplication if not configured.
+    pub dedup_outputs: Option<u64>,
+    /// The crashes of the services are counted per application, and the
+    /// application is not bound to the executor while it's backing off or
+    /// crash-looping. The services are restarted at once if not configured.
+    pub restarts: Option<FlameRestarts>,
+}
+
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct FlameRestarts {
+    /// The consecutive crashes of the services of an application after which
+    /// it's crash-looping on the executor.
+    pub budget: u32,
+    /// The backoff in seconds before restarting the service after its first
+    /// crash, doubled by each consecutive crash.
+    pub backoff: u64,
+    /// Maximum backoff in seconds; the crash-looping application is excluded
+    /// from the executor for the duration since its last crash.
+    pub max_backoff: u64,
+}
+
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct FlameSlowTasks {
+    /// The running time in seconds after which a task is slow.
+    pub threshold: u64,
+    /// Maximum size in bytes of the stacks attached to a task; the stacks are
+    /// truncated beyond it.
+    pub max_size: u64,
+}
+
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub enum FlameLogForwarder {
+    Loki {
+        url: String,
+        labels: HashMap<String, String>,
+    },
+    CloudWatch {
+        group: String,
+        region: Option<String>,
+    },
+    Syslog {
+        address: String,
+    },
+}
+
+#[derive(Debug, Clone)]
+pub struct FlameLocalResults {
+    pub root: String,
+    /// Minimum size in bytes of the outputs kept on the host; the smaller
+    /// outputs are cheaper to send to the session manager than to read
+    /// from a file.
+    pub min_size: u64,
+    /// Retention in seconds of the outputs of a session.
+    pub retention: u64,
+}
+
+#[derive(Clone, Copy, Debug, Default, PartialEq, Eq)]
+pub enum CloudMetadata {
+    /// Do not probe the cloud metadata.
+    None,
+    /// Probe all the supported cloud providers, and use the first one found.
+    #[default]
+    Auto,
+    Aws,
+    Gcp,
+    Azure,
+}
+
+impl TryFrom<String> for CloudMetadata {
+    type Error = FlameError;
+    fn try_from(s: String) -> Result<Self, Self::Error> {
+        match s.to_lowercase().as_str() {
+            "none" => Ok(Self::None),
+            "auto" => Ok(Self::Auto),
+            "aws" => Ok(Self::Aws),
+            "gcp" => Ok(Self::Gcp),
+            "azure" => Ok(Self::Azure),
+            _ => Err(FlameError::InvalidConfig(format!(
+                "invalid cloud metadata: {s}"
+            ))),
+        }
+    }
+}
+
+#[derive(Debug, Clone)]
+pub struct FlameScratch {
+    pub root: String,
+    /// Maximum size in bytes
+    pub size_limit: Option<u64>,
+    pub tmpfs: bool,
 }
 
 #[derive(Debug, Clone)]
@@ -149,6 +522,274 @@ pub struct FlameLimits {
     pub max_executors: u32,
 }
 
+#[derive(Clone, Copy, Debug, Default, PartialEq, Eq)]
+pub enum AgingCurve {
+    /// No aging, i.e. the sessions never gain priority by waiting.
+    #[default]
+    None,
+    /// One priority per interval of waiting.
+    Linear,
+    /// The priority is doubled per interval of waiting.
+    Exponential,
+}
+
+/// The starvation avoidance of the scheduler: the sessions waiting for
+/// executors gain effective priority over time by the curve, so the
+/// low-priority sessions eventually run on busy clusters.
+#[derive(Debug, Clone)]
+pub struct FlameAging {
+    pub curve: AgingCurve,
+    /// Seconds of waiting per aging step
+    pub interval: u64,
+    /// The cap of the effective priority gained by aging
+    pub max_priority: u32,
+}
+
+impl FlameAging {
+    /// The effective priority gained by waiting for `waiting` seconds.
+    pub fn priority(&self, waiting: u64) -> u32 {
+        let steps = waiting / self.interval.max(1);
+        let priority = match self.curve {
+            AgingCurve::None => 0,
+            AgingCurve::Linear => steps,
+            AgingCurve::Exponential => match steps {
+                0 => 0,
+                // 2^(steps - 1) is far beyond any max_priority after 32 steps.
+                n => 1u64 << (n - 1).min(32),
+            },
+        };
+
+        priority.min(self.max_priority as u64) as u32
+    }
+}
+
+/// The price table of nodes for the cost-aware scheduling, by the labels of
+/// the cloud metadata.
+#[derive(Debug, Clone)]
+pub struct FlameCost {
+    /// Price per hour of the instance types
+    pub prices: HashMap<String, f64>,
+    /// Price of the nodes whose instance type is not in the table
+    pub default_price: f64,
+    /// Ratio of the spot price to the on-demand price
+    pub spot_discount: f64,
+}
+
+impl FlameCost {
+    /// Whether the node is a spot instance by its labels.
+    pub fn is_spot(labels: &HashMap<String, String>) -> bool {
+        labels.get(LABEL_SPOT).is_some_and(|spot| spot == "true")
+    }
+
+    /// The price of the node by its labels.
+    pub fn price(&self, labels: &HashMap<String, String>) -> f64 {
+        let price = labels
+            .get(LABEL_INSTANCE_TYPE)
+            .and_then(|instance_type| self.prices.get(instance_type))
+            .copied()
+            .unwrap_or(self.default_price);
+
+        if Self::is_spot(labels) {
+            price * self.spot_discount
+        } else {
+            price
+        }
+    }
+}
+
+/// The journal of the backend RPCs, kept in a bounded ring per executor to
+/// diagnose the stuck bindings, e.g. by `flmctl debug executor <id> --journal`.
+#[derive(Debug, Clone)]
+pub struct FlameJournal {
+    /// The oldest entries of an executor are dropped beyond the size.
+    pub size: usize,
+    /// The least recently journaled executor is dropped beyond the limit.
+    pub executors: usize,
+}
+
+/// The warm standby of another session manager, i.e. the leader: the state of
+/// the leader is streamed to the standby, which serves no requests but redirects
+/// the clients to the leader, until it's promoted, e.g. by `flmctl promote`.
+#[derive(Debug, Clone)]
+pub struct FlameStandby {
+    /// The frontend endpoint of the leader.
+    pub leader: String,
+}
+
+/// The authentication of the replication: the standby streams the state of
+/// the leader, and the operators promote the standby, by the token shared by
+/// the leader and its standbys.
+#[derive(Debug, Clone)]
+pub struct FlameReplication {
+    /// The file of the token, which is read on use, so it's rotated without
+    /// a restart.
+    pub token_file: String,
+}
+
+impl FlameReplication {
+    pub fn token(&self) -> Result<String, FlameError> {
+        let token = fs::read_to_string(&self.token_file).map_err(|e| {
+            FlameError::InvalidConfig(format!(
+                "failed to read replication.token_file <{}>: {e}",
+                self.token_file
+            ))
+        })?;
+        let token = token.trim();
+        if token.is_empty() {
+            return Err(FlameError::InvalidConfig(format!(
+                "replication.token_file <{}> is empty",
+                self.token_file
+            )));
+        }
+
+        Ok(token.to_string())
+    }
+}
+
+/// The time series of the scheduler metrics, e.g. the queue depth and the
+/// bind rate, sampled periodically to reconstruct what the cluster was doing
+/// during an incident, e.g. by `flmctl debug timeline`.
+#[derive(Debug, Clone)]
+pub struct FlameTimeline {
+    /// The directory of the samples by `file://<dir>`, or "memory" whose
+    /// samples are lost on the restart of the session manager.
+    pub storage: String,
+    /// The interval in seconds between two samples.
+    pub interval: u64,
+    /// The samples older than the retention in seconds are dropped.
+    pub retention: u64,
+}
+
+/// The faults injected into the session manager and the executor managers, to
+/// verify that the cluster recovers from them in the soak tests before the
+/// rollout; they're refused unless the binaries run with `--unsafe-chaos`.
+#[derive(Debug, Clone)]
+pub struct FlameChaos {
+    /// The probability of disconnecting the node at each message of its
+    /// WatchNode stream, e.g. the acks of the heartbeats; the executors of the
+    /// node are re-registered after reconnecting.
+    pub disconnect_executor: f64,
+    /// The probability of delaying a CompleteTask by the session manager.
+    pub delay_complete_task: f64,
+    /// The maximum delay in milliseconds of the delayed CompleteTask.
+    pub max_complete_delay: u64,
+    /// The probability of dropping an event of the tasks.
+    pub drop_events: f64,
+}
+
+impl FlameChaos {
+    /// Whether to disconnect the node now.
+    pub fn disconnect_executor(&self) -> bool {
+        stdng::rand::hit(self.disconnect_executor)
+    }
+
+    /// The delay of the CompleteTask, if it's delayed.
+    pub fn complete_task_delay(&self) -> Option<Duration> {
+        stdng::rand::hit(self.delay_complete_task)
+            .then(|| Duration::from_millis(stdng::rand::up_to(self.max_complete_delay)))
+    }
+
+    /// Whether to drop the event.
+    pub fn drop_event(&self) -> bool {
+        stdng::rand::hit(self.drop_events)
+    }
+}
+
+/// The encryption of the inputs and outputs of the tasks persisted by the
+/// storage, by the keys of their tenants in the KMS; the data of a tenant is
+/// not exposed by the backup of the storage without its keys.
+#[derive(Debug, Clone)]
+pub struct FlameEncryption {
+    /// The KMS of the keys, e.g. the directory by `file://<dir>`.
+    pub kms: String,
+}
+
+/// A webhook of the external controllers, which admits the sessions and
+/// tasks, or mutates the sessions, by the policy of the platform, e.g. naming,
+/// cost tags and image allowlists.
+#[derive(Debug, Clone)]
+pub struct FlameHook {
+    pub name: String,
+    pub backend: HookBackend,
+    pub operations: Vec<HookOperation>,
+    pub mutating: bool,
+    /// Timeout in milliseconds of each review by the webhook
+    pub timeout: u64,
+    pub failure_policy: HookFailurePolicy,
+}
+
+impl FlameHook {
+    pub fn reviews(&self, operation: HookOperation) -> bool {
+        self.operations.contains(&operation)
+    }
+}
+
+#[derive(Clone, Debug, PartialEq, Eq)]
+pub enum HookBackend {
+    /// The review is posted to the webhook.
+    Webhook { url: String },
+    /// The review is the input of the Rego policy, whose decision is queried
+    /// by `query`, e.g. "data.flame.admission".
+    Policy { bundle: String, query: String },
+}
+
+#[derive(Clone, Copy, Debug, PartialEq, Eq, strum_macros::Display)]
+pub enum HookOperation {
+    #[strum(serialize = "create_session")]
+    CreateSession,
+    #[strum(serialize = "submit_task")]
+    SubmitTask,
+}
+
+impl TryFrom<String> for HookOperation {
+    type Error = FlameError;
+    fn try_from(s: String) -> Result<Self, Self::Error> {
+        match s.to_lowercase().as_str() {
+            "create_session" => Ok(Self::CreateSession),
+            "submit_task" => Ok(Self::SubmitTask),
+            _ => Err(FlameError::InvalidConfig(format!(
+                "invalid hook operation: {s}"
+            ))),
+        }
+    }
+}
+
+#[derive(Clone, Copy, Debug, Default, PartialEq, Eq)]
+pub enum HookFailurePolicy {
+    /// Reject the operation if the hook is not available.
+    #[default]
+    Fail,
+    /// Admit the operation if the hook is not available.
+    Ignore,
+}
+
+impl TryFrom<String> for HookFailurePolicy {
+    type Error = FlameError;
+    fn try_from(s: String) -> Result<Self, Self::Error> {
+        match s.to_lowercase().as_str() {
+            "fail" => Ok(Self::Fail),
+            "ignore" => Ok(Self::Ignore),
+            _ => Err(FlameError::InvalidConfig(format!(
+                "invalid hook failure policy: {s}"
+            ))),
+        }
+    }
+}
+
+impl TryFrom<String> for AgingCurve {
+    type Error = FlameError;
+    fn try_from(s: String) -> Result<Self, Self::Error> {
+        match s.to_lowercase().as_str() {
+            "none" => Ok(Self::None),
+            "linear" => Ok(Self::Linear),
+            "exponential" => Ok(Self::Exponential),
+            _ => Err(FlameError::InvalidConfig(format!(
+                "invalid aging curve: {s}"
+            ))),
+        }
+    }
+}
+
 /// TLS configuration for Flame services.
 ///
 /// When this struct is present and valid (cert_file + key_file configured),
@@ -340,6 +981,22 @@ impl FlameClusterContext {
 
         FlameClusterContext::try_from(ctx)
     }
+
+    /// The chaos of the cluster is refused unless it's allowed explicitly by
+    /// `--unsafe-chaos`, so it's never injected into a production cluster by
+    /// a leftover configuration.
+    pub fn check_chaos(&self, unsafe_chaos: bool) -> Result<(), FlameError> {
+        match (&self.cluster.chaos, unsafe_chaos) {
+            (Some(_), false) => Err(FlameError::InvalidConfig(
+                "chaos is configured, but --unsafe-chaos is not set".to_string(),
+            )),
+            (Some(chaos), true) => {
+                tracing::warn!("Chaos is enabled, the faults are injected: {chaos:?}");
+                Ok(())
+            }
+            (None, _) => Ok(()),
+        }
+    }
 }
 
 impl TryFrom<FlameClusterContextYaml> for FlameClusterContext {
@@ -366,18 +1023,77 @@ impl TryFrom<FlameClusterYaml> for FlameCluster {
 
         let limits = cluster.limits.map(FlameLimits::from).unwrap_or_default();
 
+        let aging = cluster
+            .aging
+            .map(FlameAging::try_from)
+            .transpose()?
+            .unwrap_or_default();
+
+        let cost = cluster.cost.map(FlameCost::try_from).transpose()?;
+
+        let hooks = cluster
+            .hooks
+            .unwrap_or_default()
+            .into_iter()
+            .map(FlameHook::try_from)
+            .collect::<Result<Vec<_>, _>>()?;
+
+        let journal = cluster.journal.map(FlameJournal::try_from).transpose()?;
+
+        let timeline = cluster.timeline.map(FlameTimeline::try_from).transpose()?;
+
+        let chaos = cluster.chaos.map(FlameChaos::try_from).transpose()?;
+
+        let encryption = cluster.encryption.map(|encryption| FlameEncryption {
+            kms: encryption.kms,
+        });
+        let storage = cluster.storage.unwrap_or(DEFAULT_STORAGE.to_string());
+        if encryption.is_some() && storage == "none" {
+            return Err(FlameError::InvalidConfig(
+                "encryption requires a persistent storage".to_string(),
+            ));
+        }
+
+        let standby = cluster.standby.map(FlameStandby::try_from).transpose()?;
+        if let Some(standby) = &standby {
+            if standby.leader == cluster.endpoint {
+                return Err(FlameError::InvalidConfig(
+                    "standby.leader must not be the endpoint of the session manager".to_string(),
+                ));
+            }
+        }
+        let replication = cluster.replication.map(|replication| FlameReplication {
+            token_file: replication.token_file,
+        });
+        if standby.is_some() && replication.is_none() {
+            return Err(FlameError::InvalidConfig(
+                "standby requires the token of the replication".to_string(),
+            ));
+        }
+
         Ok(FlameCluster {
             name: cluster.name,
             endpoint: cluster.endpoint,
             slot: ResourceRequirement::from(&cluster.slot.unwrap_or(DEFAULT_SLOT.to_string())),
             policy: cluster.policy.unwrap_or(DEFAULT_POLICY.to_string()),
-            storage: cluster.storage.unwrap_or(DEFAULT_STORAGE.to_string()),
+            storage,
             schedule_interval: cluster
                 .schedule_interval
                 .unwrap_or(DEFAULT_SCHEDULE_INTERVAL),
             executors,
             tls,
             limits,
+            aging,
+            cost,
+            hooks,
+            journal,
+            standby,
+            replication,
+            timeline,
+            plugins: cluster.plugins,
+            chaos,
+            encryption,
+            trust_proxy_headers: cluster.trust_proxy_headers.unwrap_or_default(),
         })
     }
 }
@@ -387,6 +1103,137 @@ impl TryFrom<FlameExecutorsYaml> for FlameExecutors {
     fn try_from(executors: FlameExecutorsYaml) -> Result<Self, Self::Error> {
         Ok(FlameExecutors {
             shim: Shim::try_from(executors.shim.unwrap_or(DEFAULT_SHIM.to_string()))?,
+            scratch: executors.scratch.map(FlameScratch::try_from).transpose()?,
+            grace_period: executors.grace_period.unwrap_or(DEFAULT_GRACE_PERIOD),
+            task_lease: executors.task_lease.filter(|lease| *lease > 0),
+            cloud_metadata: CloudMetadata::try_from(
+                executors
+                    .cloud_metadata
+                    .unwrap_or(DEFAULT_CLOUD_METADATA.to_string()),
+            )?,
+            local_results: executors
+                .local_results
+                .map(FlameLocalResults::try_from)
+                .transpose()?,
+            logs: executors
+                .logs
+                .unwrap_or_default()
+                .into_iter()
+                .map(FlameLogForwarder::try_from)
+                .collect::<Result<Vec<_>, _>>()?,
+            slow_tasks: executors
+                .slow_tasks
+                .map(FlameSlowTasks::try_from)
+                .transpose()?,
+            tags: parse_tags(executors.tags.unwrap_or_default())?,
+            warm_shim_ttl: executors.warm_shim_ttl.filter(|ttl| *ttl > 0),
+            backend_endpoint: executors.backend_endpoint.filter(|e| !e.is_empty()),
+            keepalive: executors.keepalive.filter(|k| *k > 0),
+            dedup_outputs: executors
+                .dedup_outputs
+                .as_deref()
+                .map(parse_memory_size)
+                .transpose()?,
+            restarts: executors
+                .restarts
+                .map(FlameRestarts::try_from)
+                .transpose()?,
+        })
+    }
+}
+
+impl TryFrom<FlameRestartsYaml> for FlameRestarts {
+    type Error = FlameError;
+    fn try_from(restarts: FlameRestartsYaml) -> Result<Self, Self::Error> {
+        let budget = restarts.budget.unwrap_or(DEFAULT_RESTART_BUDGET);
+        let backoff = restarts.backoff.unwrap_or(DEFAULT_RESTART_BACKOFF);
+        let max_backoff = restarts.max_backoff.unwrap_or(DEFAULT_RESTART_MAX_BACKOFF);
+        if budget == 0 {
+            return Err(FlameError::InvalidConfig(
+                "the restart budget of executors must be greater than 0".to_string(),
+            ));
+        }
+        if backoff > max_backoff {
+            return Err(FlameError::InvalidConfig(format!(
+                "the restart backoff <{backoff}> is longer than the max backoff <{max_backoff}>"
+            )));
+        }
+
+        Ok(FlameRestarts {
+            budget,
+            backoff,
+            max_backoff,
+        })
+    }
+}
+
+/// The tags must be non-empty and without commas, as they're advertised by
+/// the labels of the node.
+fn parse_tags(tags: Vec<String>) -> Result<Vec<String>, FlameError> {
+    tags.into_iter()
+        .map(|tag| {
+            let tag = tag.trim().to_string();
+            if tag.is_empty() || tag.contains(',') {
+                return Err(FlameError::InvalidConfig(format!(
+                    "invalid tag <{tag}> of executors"
+                )));
+            }
+            Ok(tag)
+        })
+        .collect()
+}
+
+impl TryFrom<FlameSlowTasksYaml> for FlameSlowTasks {
+    type Error = FlameError;
+    fn try_from(slow_tasks: FlameSlowTasksYaml) -> Result<Self, Self::Error> {
+        let threshold = slow_tasks.threshold.unwrap_or(DEFAULT_SLOW_TASK_THRESHOLD);
+        if threshold == 0 {
+            return Err(FlameError::InvalidConfig(
+                "the threshold of slow tasks must be greater than 0".to_string(),
+            ));
+        }
+
+        Ok(FlameSlowTasks {
+            threshold,
+            max_size: parse_memory_size(
+                slow_tasks
+                    .max_size
+                    .as_deref()
+                    .unwrap_or(DEFAULT_SLOW_TASK_MAX_SIZE),
+            )?,
+        })
+    }
+}
+
+impl TryFrom<FlameLocalResultsYaml> for FlameLocalResults {
+    type Error = FlameError;
+    fn try_from(results: FlameLocalResultsYaml) -> Result<Self, Self::Error> {
+        Ok(FlameLocalResults {
+            root: results
+                .root
+                .unwrap_or(DEFAULT_LOCAL_RESULTS_ROOT.to_string()),
+            min_size: parse_memory_size(
+                results
+                    .min_size
+                    .as_deref()
+                    .unwrap_or(DEFAULT_LOCAL_RESULTS_MIN_SIZE),
+            )?,
+            retention: results.retention.unwrap_or(DEFAULT_LOCAL_RESULTS_RETENTION),
+        })
+    }
+}
+
+impl TryFrom<FlameScratchYaml> for FlameScratch {
+    type Error = FlameError;
+    fn try_from(scratch: FlameScratchYaml) -> Result<Self, Self::Error> {
+        Ok(FlameScratch {
+            root: scratch.root.unwrap_or(DEFAULT_SCRATCH_ROOT.to_string()),
+            size_limit: scratch
+                .size_limit
+                .as_deref()
+                .map(parse_memory_size)
+                .transpose()?,
+            tmpfs: scratch.tmpfs.unwrap_or(false),
         })
     }
 }
@@ -400,6 +1247,265 @@ impl From<FlameLimitsYaml> for FlameLimits {
     }
 }
 
+impl TryFrom<FlameAgingYaml> for FlameAging {
+    type Error = FlameError;
+    fn try_from(aging: FlameAgingYaml) -> Result<Self, Self::Error> {
+        let interval = aging.interval.unwrap_or(DEFAULT_AGING_INTERVAL);
+        if interval == 0 {
+            return Err(FlameError::InvalidConfig(
+                "aging.interval must be greater than 0".to_string(),
+            ));
+        }
+
+        Ok(FlameAging {
+            curve: AgingCurve::try_from(aging.curve.unwrap_or(DEFAULT_AGING_CURVE.to_string()))?,
+            interval,
+            max_priority: aging.max_priority.unwrap_or(DEFAULT_AGING_MAX_PRIORITY),
+        })
+    }
+}
+
+impl TryFrom<FlameLogForwarderYaml> for FlameLogForwarder {
+    type Error = FlameError;
+    fn try_from(forwarder: FlameLogForwarderYaml) -> Result<Self, Self::Error> {
+        match forwarder.kind.as_str() {
+            "loki" => {
+                let url = forwarder.url.ok_or(FlameError::InvalidConfig(
+                    "url is required by the loki log forwarder".to_string(),
+                ))?;
+                if !url.starts_with("http://") && !url.starts_with("https://") {
+                    return Err(FlameError::InvalidConfig(format!(
+                        "invalid url <{url}> of the loki log forwarder"
+                    )));
+                }
+                Ok(FlameLogForwarder::Loki {
+                    url: url.trim_end_matches('/').to_string(),
+                    labels: forwarder.labels.unwrap_or_default(),
+                })
+            }
+            "cloudwatch" => Ok(FlameLogForwarder::CloudWatch {
+                group: forwarder.group.ok_or(FlameError::InvalidConfig(
+                    "group is required by the cloudwatch log forwarder".to_string(),
+                ))?,
+                region: forwarder.region,
+            }),
+            "syslog" => {
+                let address = forwarder
+                    .address
+                    .unwrap_or(DEFAULT_SYSLOG_ADDRESS.to_string());
+                if !address.starts_with("udp://") && !address.starts_with("tcp://") {
+                    return Err(FlameError::InvalidConfig(format!(
+                        "invalid address <{address}> of the syslog log forwarder"
+                    )));
+                }
+                Ok(FlameLogForwarder::Syslog { address })
+            }
+            kind => Err(FlameError::InvalidConfig(format!(
+                "unknown log forwarder <{kind}>"
+            ))),
+        }
+    }
+}
+
+impl TryFrom<FlameHookYaml> for FlameHook {
+    type Error = FlameError;
+    fn try_from(hook: FlameHookYaml) -> Result<Self, Self::Error> {
+        let backend = match (hook.url, hook.policy) {
+            (Some(url), None) => {
+                if !url.starts_with("http://") && !url.starts_with("https://") {
+                    return Err(FlameError::InvalidConfig(format!(
+                        "invalid url <{url}> of hook <{}>",
+                        hook.name
+                    )));
+                }
+                HookBackend::Webhook { url }
+            }
+            (None, Some(bundle)) => HookBackend::Policy {
+                bundle,
+                query: hook.query.unwrap_or(DEFAULT_HOOK_POLICY_QUERY.to_string()),
+            },
+            _ => {
+                return Err(FlameError::InvalidConfig(format!(
+                    "either url or policy is required by hook <{}>",
+                    hook.name
+                )))
+            }
+        };
+
+        let operations = match hook.operations {
+            Some(operations) => operations
+                .into_iter()
+                .map(HookOperation::try_from)
+                .collect::<Result<Vec<_>, _>>()?,
+            None => vec![HookOperation::CreateSession, HookOperation::SubmitTask],
+        };
+
+        Ok(FlameHook {
+            name: hook.name,
+            backend,
+            operations,
+            mutating: hook.mutating.unwrap_or(false),
+            timeout: hook.timeout.unwrap_or(DEFAULT_HOOK_TIMEOUT),
+            failure_policy: HookFailurePolicy::try_from(
+                hook.failure_policy
+                    .unwrap_or(DEFAULT_HOOK_FAILURE_POLICY.to_string()),
+            )?,
+        })
+    }
+}
+
+impl TryFrom<FlameCostYaml> for FlameCost {
+    type Error = FlameError;
+    fn try_from(cost: FlameCostYaml) -> Result<Self, Self::Error> {
+        let cost = FlameCost {
+            prices: cost.prices.unwrap_or_default(),
+            default_price: cost.default_price.unwrap_or(DEFAULT_PRICE),
+            spot_discount: cost.spot_discount.unwrap_or(DEFAULT_SPOT_DISCOUNT),
+        };
+
+        if let Some((name, price)) = cost.prices.iter().find(|(_, price)| **price < 0.0) {
+            return Err(FlameError::InvalidConfig(format!(
+                "invalid price <{price}> of <{name}>"
+            )));
+        }
+        if cost.default_price < 0.0 {
+            return Err(FlameError::InvalidConfig(format!(
+                "invalid cost.default_price <{}>",
+                cost.default_price
+            )));
+        }
+        if cost.spot_discount <= 0.0 || cost.spot_discount > 1.0 {
+            return Err(FlameError::InvalidConfig(format!(
+                "cost.spot_discount <{}> must be in (0, 1]",
+                cost.spot_discount
+            )));
+        }
+
+        Ok(cost)
+    }
+}
+
+impl TryFrom<FlameJournalYaml> for FlameJournal {
+    type Error = FlameError;
+    fn try_from(journal: FlameJournalYaml) -> Result<Self, Self::Error> {
+        let journal = FlameJournal {
+            size: journal.size.unwrap_or(DEFAULT_JOURNAL_SIZE),
+            executors: journal.executors.unwrap_or(DEFAULT_JOURNAL_EXECUTORS),
+        };
+
+        if journal.size == 0 || journal.executors == 0 {
+            return Err(FlameError::InvalidConfig(
+                "journal.size and journal.executors must be positive".to_string(),
+            ));
+        }
+
+        Ok(journal)
+    }
+}
+
+impl TryFrom<FlameTimelineYaml> for FlameTimeline {
+    type Error = FlameError;
+    fn try_from(timeline: FlameTimelineYaml) -> Result<Self, Self::Error> {
+        let timeline = FlameTimeline {
+            storage: timeline
+                .storage
+                .unwrap_or(DEFAULT_TIMELINE_STORAGE.to_string()),
+            interval: timeline.interval.unwrap_or(DEFAULT_TIMELINE_INTERVAL),
+            retention: timeline.retention.unwrap_or(DEFAULT_TIMELINE_RETENTION),
+        };
+
+        if timeline.storage != "memory" && !timeline.storage.starts_with("file://") {
+            return Err(FlameError::InvalidConfig(format!(
+                "invalid timeline.storage <{}>",
+                timeline.storage
+            )));
+        }
+        if timeline.interval == 0 || timeline.retention < timeline.interval {
+            return Err(FlameError::InvalidConfig(
+                "timeline.interval must be positive and not longer than timeline.retention"
+                    .to_string(),
+            ));
+        }
+
+        Ok(timeline)
+    }
+}
+
+impl TryFrom<FlameChaosYaml> for FlameChaos {
+    type Error = FlameError;
+    fn try_from(chaos: FlameChaosYaml) -> Result<Self, Self::Error> {
+        let chaos = FlameChaos {
+            disconnect_executor: chaos.disconnect_executor.unwrap_or_default(),
+            delay_complete_task: chaos.delay_complete_task.unwrap_or_default(),
+            max_complete_delay: chaos
+                .max_complete_delay
+                .unwrap_or(DEFAULT_CHAOS_MAX_COMPLETE_DELAY),
+            drop_events: chaos.drop_events.unwrap_or_default(),
+        };
+
+        for (name, probability) in [
+            ("disconnect_executor", chaos.disconnect_executor),
+            ("delay_complete_task", chaos.delay_complete_task),
+            ("drop_events", chaos.drop_events),
+        ] {
+            if !(0.0..=1.0).contains(&probability) {
+                return Err(FlameError::InvalidConfig(format!(
+                    "chaos.{name} must be a probability in [0, 1]"
+                )));
+            }
+        }
+
+        Ok(chaos)
+    }
+}
+
+impl TryFrom<FlameStandbyYaml> for FlameStandby {
+    type Error = FlameError;
+    fn try_from(standby: FlameStandbyYaml) -> Result<Self, Self::Error> {
+        if !standby.leader.starts_with("http://") && !standby.leader.starts_with("https://") {
+            return Err(FlameError::InvalidConfig(format!(
+                "invalid standby.leader <{}>",
+                standby.leader
+            )));
+        }
+
+        Ok(FlameStandby {
+            leader: standby.leader,
+        })
+    }
+}
+
+impl Default for FlameAging {
+    fn default() -> Self {
+        FlameAging {
+            curve: AgingCurve::None,
+            interval: DEFAULT_AGING_INTERVAL,
+            max_priority: DEFAULT_AGING_MAX_PRIORITY,
+        }
+    }
+}
+
+impl Default for FlameExecutors {
+    fn default() -> Self {
+        FlameExecutors {
+            shim: Shim::default(),
+            scratch: None,
+            grace_period: DEFAULT_GRACE_PERIOD,
+            task_lease: None,
+            cloud_metadata: CloudMetadata::default(),
+            local_results: None,
+            logs: vec![],
+            slow_tasks: None,
+            tags: vec![],
+            warm_shim_ttl: None,
+            backend_endpoint: None,
+            keepalive: None,
+            dedup_outputs: None,
+            restarts: None,
+        }
+    }
+}
+
 impl Default for FlameLimits {
     fn default() -> Self {
         FlameLimits {
@@ -421,6 +1527,17 @@ impl Default for FlameCluster {
             executors: FlameExecutors::default(),
             tls: None,
             limits: FlameLimits::default(),
+            aging: FlameAging::default(),
+            cost: None,
+            hooks: vec![],
+            journal: None,
+            standby: None,
+            replication: None,
+            timeline: None,
+            plugins: None,
+            chaos: None,
+            encryption: None,
+            trust_proxy_headers: false,
         }
     }
 }
@@ -895,4 +2012,504 @@ cache:
         let result = FlameClusterContext::from_file(Some(tmp_file.to_string_lossy().to_string()));
         assert!(result.is_err());
     }
+
+    #[test]
+    fn test_flame_context_with_executor_scratch() -> Result<(), FlameError> {
+        let context_string = r#"---
+cluster:
+  name: flame
+  endpoint: "http://flame-session-manager:8080"
+  executors:
+    shim: host
+    scratch:
+      size_limit: "512M"
+      tmpfs: true
+    task_lease: 60
+    cloud_metadata: gcp
+    local_results:
+      min_size: "1M"
+    tags: ["huge-memory", " gpu "]
+    warm_shim_ttl: 300
+    backend_endpoint: "https://flame-gateway.example.com:443"
+    keepalive: 20
+    dedup_outputs: "4K"
+        "#;
+
+        let tmp_dir = TempDir::new().unwrap();
+        let tmp_file = tmp_dir.path().join("flame-cluster.yaml");
+        fs::write(&tmp_file, context_string).unwrap();
+
+        let ctx = FlameClusterContext::from_file(Some(tmp_file.to_string_lossy().to_string()))?;
+        let scratch = ctx.cluster.executors.scratch.unwrap();
+        assert_eq!(scratch.root, DEFAULT_SCRATCH_ROOT);
+        assert_eq!(scratch.size_limit, Some(512 * 1024 * 1024));
+        assert!(scratch.tmpfs);
+        assert_eq!(ctx.cluster.executors.grace_period, DEFAULT_GRACE_PERIOD);
+        assert_eq!(ctx.cluster.executors.task_lease, Some(60));
+        assert_eq!(ctx.cluster.executors.cloud_metadata, CloudMetadata::Gcp);
+        let local_results = ctx.cluster.executors.local_results.unwrap();
+        assert_eq!(local_results.root, DEFAULT_LOCAL_RESULTS_ROOT);
+        assert_eq!(local_results.min_size, 1024 * 1024);
+        assert_eq!(local_results.retention, DEFAULT_LOCAL_RESULTS_RETENTION);
+        assert_eq!(ctx.cluster.executors.slow_tasks, None);
+        assert_eq!(ctx.cluster.executors.tags, vec!["huge-memory", "gpu"]);
+        assert_eq!(ctx.cluster.executors.warm_shim_ttl, Some(300));
+        assert_eq!(
+            ctx.cluster.executors.backend_endpoint.as_deref(),
+            Some("https://flame-gateway.example.com:443")
+        );
+        assert_eq!(ctx.cluster.executors.keepalive, Some(20));
+        assert_eq!(ctx.cluster.executors.dedup_outputs, Some(4 * 1024));
+        assert_eq!(ctx.cluster.executors.restarts, None);
+        assert!(parse_tags(vec!["a,b".to_string()]).is_err());
+
+        Ok(())
+    }
+
+    #[test]
+    fn test_flame_context_with_restarts() -> Result<(), FlameError> {
+        let restarts = FlameRestarts::try_from(FlameRestartsYaml {
+            budget: Some(3),
+            backoff: Some(2),
+            max_backoff: None,
+        })?;
+        assert_eq!(restarts.budget, 3);
+        assert_eq!(restarts.backoff, 2);
+        assert_eq!(restarts.max_backoff, DEFAULT_RESTART_MAX_BACKOFF);
+
+        assert!(FlameRestarts::try_from(FlameRestartsYaml {
+            budget: Some(0),
+            backoff: None,
+            max_backoff: None,
+        })
+        .is_err());
+        assert!(FlameRestarts::try_from(FlameRestartsYaml {
+            budget: None,
+            backoff: Some(600),
+            max_backoff: Some(60),
+        })
+        .is_err());
+
+        Ok(())
+    }
+
+    #[test]
+    fn test_flame_context_with_slow_tasks() -> Result<(), FlameError> {
+        let slow_tasks = FlameSlowTasks::try_from(FlameSlowTasksYaml {
+            threshold: Some(60),
+            max_size: Some("1M".to_string()),
+        })?;
+        assert_eq!(slow_tasks.threshold, 60);
+        assert_eq!(slow_tasks.max_size, 1024 * 1024);
+
+        let slow_tasks = FlameSlowTasks::try_from(FlameSlowTasksYaml {
+            threshold: None,
+            max_size: None,
+        })?;
+        assert_eq!(slow_tasks.threshold, DEFAULT_SLOW_TASK_THRESHOLD);
+        assert_eq!(slow_tasks.max_size, 64 * 1024);
+
+        assert!(FlameSlowTasks::try_from(FlameSlowTasksYaml {
+            threshold: Some(0),
+            max_size: None,
+        })
+        .is_err());
+
+        Ok(())
+    }
+
+    #[test]
+    fn test_flame_context_with_aging() -> Result<(), FlameError> {
+        let context_string = r#"---
+cluster:
+  name: flame
+  endpoint: "http://flame-session-manager:8080"
+  aging:
+    curve: exponential
+    interval: 30
+        "#;
+
+        let tmp_dir = TempDir::new().unwrap();
+        let tmp_file = tmp_dir.path().join("flame-cluster.yaml");
+        fs::write(&tmp_file, context_string).unwrap();
+
+        let ctx = FlameClusterContext::from_file(Some(tmp_file.to_string_lossy().to_string()))?;
+        let aging = ctx.cluster.aging;
+        assert_eq!(aging.curve, AgingCurve::Exponential);
+        assert_eq!(aging.interval, 30);
+        assert_eq!(aging.max_priority, DEFAULT_AGING_MAX_PRIORITY);
+
+        assert_eq!(aging.priority(29), 0);
+        assert_eq!(aging.priority(30), 1);
+        assert_eq!(aging.priority(90), 4);
+        assert_eq!(aging.priority(3600), DEFAULT_AGING_MAX_PRIORITY);
+
+        let linear = FlameAging {
+            curve: AgingCurve::Linear,
+            ..aging
+        };
+        assert_eq!(linear.priority(90), 3);
+
+        // No aging by default.
+        assert_eq!(FlameAging::default().priority(3600), 0);
+
+        Ok(())
+    }
+
+    #[test]
+    fn test_flame_context_with_cost() -> Result<(), FlameError> {
+        let context_string = r#"---
+cluster:
+  name: flame
+  endpoint: "http://flame-session-manager:8080"
+  cost:
+    prices:
+      m5.large: 0.1
+      m5.xlarge: 0.2
+    spot_discount: 0.5
+        "#;
+
+        let tmp_dir = TempDir::new().unwrap();
+        let tmp_file = tmp_dir.path().join("flame-cluster.yaml");
+        fs::write(&tmp_file, context_string).unwrap();
+
+        let ctx = FlameClusterContext::from_file(Some(tmp_file.to_string_lossy().to_string()))?;
+        let cost = ctx.cluster.cost.expect("cost should be set");
+        assert_eq!(cost.default_price, DEFAULT_PRICE);
+
+        let labels = |instance_type: &str, spot: &str| {
+            HashMap::from([
+                (LABEL_INSTANCE_TYPE.to_string(), instance_type.to_string()),
+                (LABEL_SPOT.to_string(), spot.to_string()),
+            ])
+        };
+        assert_eq!(cost.price(&labels("m5.xlarge", "false")), 0.2);
+        assert_eq!(cost.price(&labels("m5.xlarge", "true")), 0.1);
+        assert_eq!(cost.price(&labels("c5.metal", "false")), DEFAULT_PRICE);
+        assert_eq!(cost.price(&HashMap::new()), DEFAULT_PRICE);
+
+        let invalid = FlameCostYaml {
+            prices: None,
+            default_price: None,
+            spot_discount: Some(0.0),
+        };
+        assert!(FlameCost::try_from(invalid).is_err());
+
+        // No cost-aware scheduling by default.
+        assert!(FlameCluster::default().cost.is_none());
+
+        Ok(())
+    }
+
+    #[test]
+    fn test_flame_context_with_journal() -> Result<(), FlameError> {
+        let context_string = r#"---
+cluster:
+  name: flame
+  endpoint: "http://flame-session-manager:8080"
+  journal:
+    size: 32
+        "#;
+
+        let tmp_dir = TempDir::new().unwrap();
+        let tmp_file = tmp_dir.path().join("flame-cluster.yaml");
+        fs::write(&tmp_file, context_string).unwrap();
+
+        let ctx = FlameClusterContext::from_file(Some(tmp_file.to_string_lossy().to_string()))?;
+        let journal = ctx.cluster.journal.expect("journal should be set");
+        assert_eq!(journal.size, 32);
+        assert_eq!(journal.executors, DEFAULT_JOURNAL_EXECUTORS);
+
+        let invalid = FlameJournalYaml {
+            size: Some(0),
+            executors: None,
+        };
+        assert!(FlameJournal::try_from(invalid).is_err());
+
+        // No journal by default.
+        assert!(FlameCluster::default().journal.is_none());
+
+        Ok(())
+    }
+
+    #[test]
+    fn test_flame_context_with_standby() -> Result<(), FlameError> {
+        let tmp_dir = TempDir::new().unwrap();
+        let token_file = tmp_dir.path().join("replication-token");
+        fs::write(&token_file, "s3cr3t\n").unwrap();
+        let context_string = format!(
+            r#"---
+cluster:
+  name: flame
+  endpoint: "http://flame-session-manager-1:8080"
+  standby:
+    leader: "http://flame-session-manager-0:8080"
+  replication:
+    token_file: "{}"
+        "#,
+            token_file.display()
+        );
+
+        let tmp_file = tmp_dir.path().join("flame-cluster.yaml");
+        fs::write(&tmp_file, &context_string).unwrap();
+
+        let ctx = FlameClusterContext::from_file(Some(tmp_file.to_string_lossy().to_string()))?;
+        let standby = ctx.cluster.standby.expect("standby should be set");
+        assert_eq!(standby.leader, "http://flame-session-manager-0:8080");
+        let replication = ctx.cluster.replication.expect("replication should be set");
+        assert_eq!(replication.token()?, "s3cr3t");
+
+        let invalid = FlameStandbyYaml {
+            leader: "flame-session-manager-0".to_string(),
+        };
+        assert!(FlameStandby::try_from(invalid).is_err());
+
+        // The empty token is rejected on use.
+        fs::write(&token_file, "\n").unwrap();
+        assert!(replication.token().is_err());
+
+        // The session manager can't follow itself.
+        fs::write(&tmp_file, context_string.replace("manager-1", "manager-0")).unwrap();
+        assert!(
+            FlameClusterContext::from_file(Some(tmp_file.to_string_lossy().to_string())).is_err()
+        );
+
+        // The standby can't follow the leader without the token.
+        let without_token = &context_string[..context_string.find("  replication:").unwrap()];
+        fs::write(&tmp_file, without_token).unwrap();
+        assert!(
+            FlameClusterContext::from_file(Some(tmp_file.to_string_lossy().to_string())).is_err()
+        );
+
+        Ok(())
+    }
+
+    #[test]
+    fn test_flame_context_with_timeline() -> Result<(), FlameError> {
+        let context_string = r#"---
+cluster:
+  name: flame
+  endpoint: "http://flame-session-manager:8080"
+  timeline:
+    storage: "file:///var/lib/flame/timeline"
+    interval: 30
+        "#;
+
+        let tmp_dir = TempDir::new().unwrap();
+        let tmp_file = tmp_dir.path().join("flame-cluster.yaml");
+        fs::write(&tmp_file, context_string).unwrap();
+
+        let ctx = FlameClusterContext::from_file(Some(tmp_file.to_string_lossy().to_string()))?;
+        let timeline = ctx.cluster.timeline.expect("timeline should be set");
+        assert_eq!(timeline.storage, "file:///var/lib/flame/timeline");
+        assert_eq!(timeline.interval, 30);
+        assert_eq!(timeline.retention, DEFAULT_TIMELINE_RETENTION);
+
+        let invalid = FlameTimelineYaml {
+            storage: Some("/var/lib/flame/timeline".to_string()),
+            interval: None,
+            retention: None,
+        };
+        assert!(FlameTimeline::try_from(invalid).is_err());
+
+        let invalid = FlameTimelineYaml {
+            storage: None,
+            interval: Some(60),
+            retention: Some(30),
+        };
+        assert!(FlameTimeline::try_from(invalid).is_err());
+
+        Ok(())
+    }
+
+    #[test]
+    fn test_flame_context_with_chaos() -> Result<(), FlameError> {
+        let context_string = r#"---
+cluster:
+  name: flame
+  endpoint: "http://flame-session-manager:8080"
+  chaos:
+    disconnect_executor: 0.01
+    drop_events: 0.5
+        "#;
+
+        let tmp_dir = TempDir::new().unwrap();
+        let tmp_file = tmp_dir.path().join("flame-cluster.yaml");
+        fs::write(&tmp_file, context_string).unwrap();
+
+        let ctx = FlameClusterContext::from_file(Some(tmp_file.to_string_lossy().to_string()))?;
+        let chaos = ctx.cluster.chaos.clone().expect("chaos should be set");
+        assert_eq!(chaos.disconnect_executor, 0.01);
+        assert_eq!(chaos.delay_complete_task, 0.0);
+        assert_eq!(chaos.max_complete_delay, DEFAULT_CHAOS_MAX_COMPLETE_DELAY);
+        assert_eq!(chaos.complete_task_delay(), None);
+
+        // The chaos is refused without the unsafe flag.
+        assert!(ctx.check_chaos(false).is_err());
+        assert!(ctx.check_chaos(true).is_ok());
+        assert!(FlameClusterContext::default().check_chaos(false).is_ok());
+
+        let invalid = FlameChaosYaml {
+            disconnect_executor: None,
+            delay_complete_task: Some(1.5),
+            max_complete_delay: None,
+            drop_events: None,
+        };
+        assert!(FlameChaos::try_from(invalid).is_err());
+
+        Ok(())
+    }
+
+    #[test]
+    fn test_flame_context_with_log_forwarders() -> Result<(), FlameError> {
+        let context_string = r#"---
+cluster:
+  name: flame
+  endpoint: "http://flame-session-manager:8080"
+  executors:
+    logs:
+      - type: loki
+        url: "http://loki:3100/"
+        labels:
+          cluster: prod
+      - type: cloudwatch
+        group: "/flame/executors"
+      - type: syslog
+        "#;
+
+        let tmp_dir = TempDir::new().unwrap();
+        let tmp_file = tmp_dir.path().join("flame-cluster.yaml");
+        fs::write(&tmp_file, context_string).unwrap();
+
+        let ctx = FlameClusterContext::from_file(Some(tmp_file.to_string_lossy().to_string()))?;
+        assert_eq!(
+            ctx.cluster.executors.logs,
+            vec![
+                FlameLogForwarder::Loki {
+                    url: "http://loki:3100".to_string(),
+                    labels: HashMap::from([("cluster".to_string(), "prod".to_string())]),
+                },
+                FlameLogForwarder::CloudWatch {
+                    group: "/flame/executors".to_string(),
+                    region: None,
+                },
+                FlameLogForwarder::Syslog {
+                    address: DEFAULT_SYSLOG_ADDRESS.to_string(),
+                },
+            ]
+        );
+
+        let forwarder = |kind: &str, address: Option<&str>| FlameLogForwarderYaml {
+            kind: kind.to_string(),
+            url: None,
+            labels: None,
+            group: None,
+            region: None,
+            address: address.map(str::to_string),
+        };
+        assert!(FlameLogForwarder::try_from(forwarder("loki", None)).is_err());
+        assert!(FlameLogForwarder::try_from(forwarder("cloudwatch", None)).is_err());
+        assert!(FlameLogForwarder::try_from(forwarder("syslog", Some("/dev/log"))).is_err());
+        assert!(FlameLogForwarder::try_from(forwarder("fluentd", None)).is_err());
+
+        // No forwarder by default.
+        assert!(FlameExecutors::default().logs.is_empty());
+
+        Ok(())
+    }
+
+    #[test]
+    fn test_flame_context_with_hooks() -> Result<(), FlameError> {
+        let context_string = r#"---
+cluster:
+  name: flame
+  endpoint: "http://flame-session-manager:8080"
+  hooks:
+    - name: naming
+      url: "https://policy.example.com/admit"
+      operations: ["create_session"]
+    - name: defaults
+      url: "http://127.0.0.1:9000/mutate"
+      mutating: true
+      timeout: 500
+      failure_policy: ignore
+    - name: opa
+      policy: /etc/flame/policies
+      operations: ["submit_task"]
+        "#;
+
+        let tmp_dir = TempDir::new().unwrap();
+        let tmp_file = tmp_dir.path().join("flame-cluster.yaml");
+        fs::write(&tmp_file, context_string).unwrap();
+
+        let ctx = FlameClusterContext::from_file(Some(tmp_file.to_string_lossy().to_string()))?;
+        let hooks = &ctx.cluster.hooks;
+        assert_eq!(hooks.len(), 3);
+
+        assert!(!hooks[0].mutating);
+        assert!(hooks[0].reviews(HookOperation::CreateSession));
+        assert!(!hooks[0].reviews(HookOperation::SubmitTask));
+        assert_eq!(hooks[0].timeout, DEFAULT_HOOK_TIMEOUT);
+        assert_eq!(hooks[0].failure_policy, HookFailurePolicy::Fail);
+
+        assert!(hooks[1].mutating);
+        assert!(hooks[1].reviews(HookOperation::SubmitTask));
+        assert_eq!(hooks[1].timeout, 500);
+        assert_eq!(hooks[1].failure_policy, HookFailurePolicy::Ignore);
+
+        assert_eq!(
+            hooks[2].backend,
+            HookBackend::Policy {
+                bundle: "/etc/flame/policies".to_string(),
+                query: DEFAULT_HOOK_POLICY_QUERY.to_string(),
+            }
+        );
+
+        let invalid = FlameHookYaml {
+            name: "invalid".to_string(),
+            url: Some("policy.example.com".to_string()),
+            policy: None,
+            query: None,
+            operations: None,
+            mutating: None,
+            timeout: None,
+            failure_policy: None,
+        };
+        assert!(FlameHook::try_from(invalid.clone()).is_err());
+
+        // Either the webhook or the policy.
+        let invalid = FlameHookYaml {
+            url: Some("http://127.0.0.1:9000/admit".to_string()),
+            policy: Some("/etc/flame/policies".to_string()),
+            ..invalid
+        };
+        assert!(FlameHook::try_from(invalid).is_err());
+
+        // No hooks by default.
+        assert!(FlameCluster::default().hooks.is_empty());
+
+        Ok(())
+    }
+
+    #[test]
+    fn test_flame_context_with_trust_proxy_headers() -> Result<(), FlameError> {
+        let context_string = r#"---
+cluster:
+  name: flame
+  endpoint: "http://flame-session-manager:8080"
+  trust_proxy_headers: true
+        "#;
+
+        let tmp_dir = TempDir::new().unwrap();
+        let tmp_file = tmp_dir.path().join("flame-cluster.yaml");
+        fs::write(&tmp_file, context_string).unwrap();
+
+        let ctx = FlameClusterContext::from_file(Some(tmp_file.to_string_lossy().to_string()))?;
+        assert!(ctx.cluster.trust_proxy_headers);
+
+        // The headers are not trusted by default.
+        assert!(!FlameCluster::default().trust_proxy_headers);
+
+        Ok(())
+    }
 }
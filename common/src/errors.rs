@@ -0,0 +1,194 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//! The catalog of the error codes, e.g. `FLAME-1101`, attached to the errors
+//! of the APIs in the `x-flame-error-code` header. The codes are stable, so
+//! the users and support tooling refer to the failure classes by the codes
+//! instead of the messages, which may be changed or translated.
+//!
+//! The codes are grouped by the hundreds:
+//!
+//! * `FLAME-10xx`: the objects, e.g. a session, are not found or exist.
+//! * `FLAME-11xx`: the requests are rejected, e.g. by an invalid argument.
+//! * `FLAME-12xx`: the failures of the session manager or the network.
+//!
+//! The catalog is mirrored by the `errors` module of the Rust SDK.
+
+use crate::FlameError;
+
+/// The header of the error code in the metadata of the gRPC status.
+pub const ERROR_CODE_HEADER: &str = "x-flame-error-code";
+
+/// A class of the errors in the catalog.
+#[derive(Clone, Copy, Debug, PartialEq, Eq)]
+pub struct ErrorCode {
+    /// The stable code, e.g. `FLAME-1101`.
+    pub code: &'static str,
+    /// The name of the class, e.g. `InvalidConfig`.
+    pub name: &'static str,
+    /// The description of the class in English.
+    pub description: &'static str,
+}
+
+pub const NOT_FOUND: ErrorCode = ErrorCode {
+    code: "FLAME-1001",
+    name: "NotFound",
+    description: "The object, e.g. the application, session or task, is not found.",
+};
+
+pub const ALREADY_EXISTS: ErrorCode = ErrorCode {
+    code: "FLAME-1002",
+    name: "AlreadyExists",
+    description: "The object with the same name or id already exists.",
+};
+
+pub const INVALID_CONFIG: ErrorCode = ErrorCode {
+    code: "FLAME-1101",
+    name: "InvalidConfig",
+    description: "The request or the configuration is invalid, e.g. a missing field.",
+};
+
+pub const INVALID_STATE: ErrorCode = ErrorCode {
+    code: "FLAME-1102",
+    name: "InvalidState",
+    description: "The object does not accept the request in its state, e.g. a closed session.",
+};
+
+pub const VERSION_MISMATCH: ErrorCode = ErrorCode {
+    code: "FLAME-1103",
+    name: "VersionMismatch",
+    description: "The object was changed by others; read it again and retry.",
+};
+
+pub const UNINITIALIZED: ErrorCode = ErrorCode {
+    code: "FLAME-1104",
+    name: "Uninitialized",
+    description: "The component is not initialized yet; retry later.",
+};
+
+pub const QUOTA_EXCEEDED: ErrorCode = ErrorCode {
+    code: "FLAME-1105",
+    name: "QuotaExceeded",
+    description: "The request exceeds a quota or a limit, e.g. the size of the input; retry later.",
+};
+
+pub const TASK_CANCELLED: ErrorCode = ErrorCode {
+    code: "FLAME-1106",
+    name: "TaskCancelled",
+    description: "The task was cancelled before it completed, e.g. by closing its session.",
+};
+
+pub const PERMISSION_DENIED: ErrorCode = ErrorCode {
+    code: "FLAME-1107",
+    name: "PermissionDenied",
+    description: "The request is denied by the policy, e.g. an admission hook.",
+};
+
+pub const INTERNAL: ErrorCode = ErrorCode {
+    code: "FLAME-1201",
+    name: "Internal",
+    description: "An internal error of the session manager.",
+};
+
+pub const STORAGE: ErrorCode = ErrorCode {
+    code: "FLAME-1202",
+    name: "Storage",
+    description: "The storage of the session manager failed.",
+};
+
+pub const NETWORK: ErrorCode = ErrorCode {
+    code: "FLAME-1203",
+    name: "Network",
+    description: "The connection to a component of the cluster failed.",
+};
+
+pub const INTEGRITY: ErrorCode = ErrorCode {
+    code: "FLAME-1204",
+    name: "Integrity",
+    description: "The data is corrupted, e.g. a checksum mismatch.",
+};
+
+/// All the error codes in the order of the codes.
+pub const CATALOG: &[ErrorCode] = &[
+    NOT_FOUND,
+    ALREADY_EXISTS,
+    INVALID_CONFIG,
+    INVALID_STATE,
+    VERSION_MISMATCH,
+    UNINITIALIZED,
+    QUOTA_EXCEEDED,
+    TASK_CANCELLED,
+    PERMISSION_DENIED,
+    INTERNAL,
+    STORAGE,
+    NETWORK,
+    INTEGRITY,
+];
+
+/// The error code by its code, e.g. `FLAME-1101`.
+pub fn lookup(code: &str) -> Option<&'static ErrorCode> {
+    CATALOG.iter().find(|c| c.code == code)
+}
+
+impl FlameError {
+    /// The class of the error in the catalog.
+    pub fn code(&self) -> &'static ErrorCode {
+        match self {
+            FlameError::NotFound(_) => &NOT_FOUND,
+            FlameError::AlreadyExist(_) => &ALREADY_EXISTS,
+            FlameError::InvalidConfig(_) => &INVALID_CONFIG,
+            FlameError::InvalidState(_) => &INVALID_STATE,
+            FlameError::VersionMismatch(_) => &VERSION_MISMATCH,
+            FlameError::Uninitialized(_) => &UNINITIALIZED,
+            FlameError::Internal(_) => &INTERNAL,
+            FlameError::Storage(_) => &STORAGE,
+            FlameError::Network(_) => &NETWORK,
+            FlameError::Integrity(_) => &INTEGRITY,
+            FlameError::QuotaExceeded(_) => &QUOTA_EXCEEDED,
+            FlameError::PermissionDenied(_) => &PERMISSION_DENIED,
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use std::collections::HashSet;
+
+    use tonic::Status;
+
+    use super::*;
+
+    #[test]
+    fn test_catalog() {
+        let codes: HashSet<_> = CATALOG.iter().map(|c| c.code).collect();
+        assert_eq!(codes.len(), CATALOG.len());
+        assert!(CATALOG.windows(2).all(|w| w[0].code < w[1].code));
+
+        assert_eq!(lookup("FLAME-1102"), Some(&INVALID_STATE));
+        assert_eq!(lookup("FLAME-9999"), None);
+    }
+
+    #[test]
+    fn test_status_with_error_code() {
+        let err = FlameError::InvalidState("session <ssn-1> is closed".to_string());
+        assert_eq!(err.code().name, "InvalidState");
+
+        let status = Status::from(err);
+        let code = status
+            .metadata()
+            .get(ERROR_CODE_HEADER)
+            .and_then(|c| c.to_str().ok());
+        assert_eq!(code, Some("FLAME-1102"));
+        assert_eq!(status.message(), "session <ssn-1> is closed");
+    }
+}
@@ -12,7 +12,10 @@ limitations under the License.
 */
 
 pub mod apis;
+pub mod clock;
+pub mod crypto;
 pub mod ctx;
+pub mod errors;
 pub mod storage;
 
 use std::string::FromUtf8Error;
@@ -60,17 +63,30 @@ pub enum FlameError {
 
     #[error("{0}")]
     VersionMismatch(String),
+
+    #[error("{0}")]
+    Integrity(String),
+
+    #[error("{0}")]
+    QuotaExceeded(String),
+
+    #[error("{0}")]
+    PermissionDenied(String),
 }
 
 impl From<stdng::Error> for FlameError {
     fn from(value: stdng::Error) -> Self {
-        FlameError::Internal(value.to_string())
+        match value {
+            stdng::Error::Integrity(msg) => FlameError::Integrity(msg),
+            _ => FlameError::Internal(value.to_string()),
+        }
     }
 }
 
 impl From<FlameError> for Status {
     fn from(value: FlameError) -> Self {
-        match value {
+        let code = value.code().code;
+        let mut status = match value {
             FlameError::NotFound(msg) => Status::not_found(msg),
             FlameError::AlreadyExist(msg) => Status::already_exists(msg),
             FlameError::InvalidConfig(msg) | FlameError::InvalidState(msg) => {
@@ -79,15 +95,30 @@ impl From<FlameError> for Status {
             FlameError::Internal(msg)
             | FlameError::Network(msg)
             | FlameError::Uninitialized(msg)
-            | FlameError::Storage(msg)
-            | FlameError::VersionMismatch(msg) => Status::internal(msg),
-        }
+            | FlameError::Storage(msg) => Status::internal(msg),
+            FlameError::VersionMismatch(msg) => Status::failed_precondition(msg),
+            FlameError::Integrity(msg) => Status::data_loss(msg),
+            FlameError::QuotaExceeded(msg) => Status::resource_exhausted(msg),
+            FlameError::PermissionDenied(msg) => Status::permission_denied(msg),
+        };
+        status.metadata_mut().insert(
+            errors::ERROR_CODE_HEADER,
+            tonic::metadata::MetadataValue::from_static(code),
+        );
+
+        status
     }
 }
 
 impl From<Status> for FlameError {
     fn from(value: Status) -> Self {
-        FlameError::Network(value.message().to_string())
+        match value.code() {
+            tonic::Code::DataLoss => FlameError::Integrity(value.message().to_string()),
+            tonic::Code::FailedPrecondition => {
+                FlameError::VersionMismatch(value.message().to_string())
+            }
+            _ => FlameError::Network(value.message().to_string()),
+        }
     }
 }
 
@@ -131,6 +162,9 @@ pub const FLAME_HOME: &str = "FLAME_HOME";
 pub const FLAME_LOG: &str = "FLAME_LOG";
 pub const FLAME_WORKING_DIRECTORY: &str = "/tmp/flame";
 pub const FLAME_INSTANCE_ENDPOINT: &str = "FLAME_INSTANCE_ENDPOINT";
+/// The file where the service publishes its endpoint if it does not listen on
+/// `FLAME_INSTANCE_ENDPOINT`, e.g. a port computed by its runtime.
+pub const FLAME_INSTANCE_ENDPOINT_FILE: &str = "FLAME_INSTANCE_ENDPOINT_FILE";
 pub const FLAME_CACHE_ENDPOINT: &str = "FLAME_CACHE_ENDPOINT";
 pub const FLAME_ENDPOINT: &str = "FLAME_ENDPOINT";
 pub const FLAME_CA_FILE: &str = "FLAME_CA_FILE";
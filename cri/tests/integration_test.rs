@@ -64,6 +64,7 @@ async fn test_run_pod() -> Result<(), FlameError> {
         environments: HashMap::new(),
         working_directory: None,
         url: None,
+        output_schema: None,
     };
 
     let pod = pm.run_pod(&app).await?;
@@ -94,6 +95,7 @@ async fn test_list_pods() -> Result<(), FlameError> {
         environments: HashMap::new(),
         working_directory: None,
         url: None,
+        output_schema: None,
     };
 
     let _ = pm.run_pod(&app).await?;
@@ -71,6 +71,8 @@ async fn main() -> Result<(), Box<dyn Error>> {
             min_instances: 0,
             max_instances: None,
             batch_size: 1,
+            content_type: None,
+            labels: vec![],
         })
         .await?;
 
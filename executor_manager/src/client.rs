@@ -20,8 +20,9 @@ use tonic::Streaming;
 use ::rpc::flame::v1 as rpc;
 use ::rpc::flame::v1::backend_client::BackendClient as FlameBackendClient;
 use ::rpc::flame::v1::{
-    BindExecutorCompletedRequest, BindExecutorRequest, CompleteTaskRequest, LaunchTaskRequest,
-    RegisterExecutorRequest, RegisterNodeRequest, ReleaseNodeRequest, SyncNodeRequest,
+    AnnotateTaskRequest, BindExecutorCompletedRequest, BindExecutorRequest, CompleteTaskRequest,
+    CompleteTaskResponse, LaunchTaskRequest, LaunchTaskResponse, RegisterExecutorRequest,
+    RegisterNodeRequest, ReleaseNodeRequest, RenewTaskLeaseRequest, SyncNodeRequest,
     UnbindExecutorCompletedRequest, UnbindExecutorRequest, UnregisterExecutorRequest,
     WatchNodeRequest, WatchNodeResponse,
 };
@@ -29,12 +30,34 @@ use ::rpc::flame::v1::{
 use crate::executor::Executor;
 use common::apis::{
     Application, Node, ResourceRequirement, Session, SessionContext, Shim, TaskContext, TaskResult,
+    TaskState,
 };
 use common::ctx::FlameClusterContext;
 use common::FlameError;
 
 const DEFAULT_PORT: u16 = 8080;
 
+/// The endpoint of the backend: the configured one, e.g. a gateway, or the
+/// port next to the one of the cluster endpoint.
+fn backend_endpoint(ctx: &FlameClusterContext) -> Result<String, FlameError> {
+    if let Some(endpoint) = &ctx.cluster.executors.backend_endpoint {
+        url::Url::parse(endpoint)
+            .map_err(|_| FlameError::InvalidConfig(format!("invalid endpoint <{endpoint}>")))?;
+        return Ok(endpoint.clone());
+    }
+
+    let url = url::Url::parse(&ctx.cluster.endpoint).map_err(|_| {
+        FlameError::InvalidConfig(format!("invalid endpoint <{}>", ctx.cluster.endpoint))
+    })?;
+    let port = url.port().unwrap_or(DEFAULT_PORT) + 1;
+
+    Ok(format!(
+        "{}://{}:{port}",
+        url.scheme(),
+        url.host_str().unwrap_or("localhost")
+    ))
+}
+
 pub type FlameClient = FlameBackendClient<Channel>;
 
 #[derive(Clone, Debug)]
@@ -44,22 +67,23 @@ pub struct BackendClient {
 
 impl BackendClient {
     pub async fn new(ctx: &FlameClusterContext) -> Result<Self, FlameError> {
-        let url = url::Url::parse(&ctx.cluster.endpoint).map_err(|_| {
-            FlameError::InvalidConfig(format!("invalid endpoint <{}>", ctx.cluster.endpoint))
-        })?;
-        let port = url.port().unwrap_or(DEFAULT_PORT) + 1;
-
-        let endpoint = format!(
-            "{}://{}:{port}",
-            url.scheme(),
-            url.host_str().unwrap_or("localhost")
-        );
+        let endpoint = backend_endpoint(ctx)?;
 
         tracing::info!("Connecting to flame backend at {}", endpoint);
         let mut channel_builder = Channel::from_shared(endpoint.clone()).map_err(|e| {
             FlameError::Network(format!("Failed to create channel for <{endpoint}>: {e}"))
         })?;
 
+        // All the backend RPCs are dialed out by the executor manager on this
+        // channel, so the nodes behind NAT only keep the connection alive.
+        if let Some(keepalive) = ctx.cluster.executors.keepalive {
+            let keepalive = Duration::from_secs(keepalive);
+            channel_builder = channel_builder
+                .tcp_keepalive(Some(keepalive))
+                .http2_keep_alive_interval(keepalive)
+                .keep_alive_while_idle(true);
+        }
+
         // Apply TLS if endpoint uses https://
         if endpoint.starts_with("https://") {
             let tls_config = if let Some(ref tls) = ctx.cluster.tls {
@@ -79,7 +103,9 @@ impl BackendClient {
             .await
             .map_err(|e| FlameError::Network(format!("Failed to connect to <{endpoint}>: {e}")))?;
 
-        let client = FlameBackendClient::new(channel);
+        // The inputs uploaded in chunks are larger than the default message
+        // limit of gRPC; they're bounded by the session manager.
+        let client = FlameBackendClient::new(channel).max_decoding_message_size(usize::MAX);
 
         Ok(Self { client })
     }
@@ -204,6 +230,16 @@ impl BackendClient {
     ) -> Result<Option<SessionContext>, FlameError> {
         let req = BindExecutorRequest {
             executor_id: exe.id.clone(),
+            excluded_applications: exe
+                .excluded_applications()
+                .into_iter()
+                .map(|e| rpc::ExcludedApplication {
+                    name: e.application,
+                    until: e.until.timestamp_millis(),
+                    crash_looping: e.crash_looping,
+                    session_id: e.session_id,
+                })
+                .collect(),
         };
 
         let resp = self
@@ -235,9 +271,16 @@ impl BackendClient {
         Ok(())
     }
 
-    pub async fn unbind_executor(&mut self, exe: &Executor) -> Result<(), FlameError> {
+    /// Unbind the executor from its session, with the reason if it's started
+    /// by the executor, e.g. the service is unhealthy.
+    pub async fn unbind_executor(
+        &mut self,
+        exe: &Executor,
+        reason: Option<String>,
+    ) -> Result<(), FlameError> {
         let req = UnbindExecutorRequest {
             executor_id: exe.id.clone(),
+            reason,
         };
 
         self.client
@@ -260,7 +303,47 @@ impl BackendClient {
         Ok(())
     }
 
-    pub async fn launch_task(&mut self, exe: &Executor) -> Result<Option<TaskContext>, FlameError> {
+    /// Launch the next task of the bound session, with the lease duration of
+    /// the task if the lease is enabled.
+    pub async fn launch_task(
+        &mut self,
+        exe: &Executor,
+    ) -> Result<Option<(TaskContext, Option<Duration>)>, FlameError> {
+        let resp = self.request_task(exe).await?;
+        self.accept_task(exe, resp).await
+    }
+
+    pub async fn complete_task(
+        &mut self,
+        exe: &Executor,
+        task_result: &TaskResult,
+    ) -> Result<(), FlameError> {
+        let sequence = exe.task.as_ref().and_then(|t| t.sequence);
+        self.request_completion(exe, sequence, task_result, false)
+            .await?;
+
+        Ok(())
+    }
+
+    /// Complete the task, and take the next task of the session piggybacked
+    /// on the response if there's one pending; it's `None` otherwise, and the
+    /// executor launches the task as usual.
+    pub async fn complete_and_launch_task(
+        &mut self,
+        exe: &Executor,
+        task_result: &TaskResult,
+    ) -> Result<Option<(TaskContext, Option<Duration>)>, FlameError> {
+        let sequence = exe.task.as_ref().and_then(|t| t.sequence);
+        let resp = self
+            .request_completion(exe, sequence, task_result, true)
+            .await?;
+        match resp.next_task {
+            Some(next) => self.accept_task(exe, next).await,
+            None => Ok(None),
+        }
+    }
+
+    async fn request_task(&mut self, exe: &Executor) -> Result<LaunchTaskResponse, FlameError> {
         let req = LaunchTaskRequest {
             executor_id: exe.id.clone(),
         };
@@ -271,28 +354,106 @@ impl BackendClient {
             .await
             .map_err(FlameError::from)?;
 
-        if let Some(t) = resp.into_inner().task {
-            return Ok(Some(TaskContext::try_from(t)?));
-        }
-
-        Ok(None)
+        Ok(resp.into_inner())
     }
 
-    pub async fn complete_task(
+    async fn request_completion(
         &mut self,
         exe: &Executor,
+        sequence: Option<u64>,
         task_result: &TaskResult,
-    ) -> Result<(), FlameError> {
+        launch_next_task: bool,
+    ) -> Result<CompleteTaskResponse, FlameError> {
         let req = CompleteTaskRequest {
             executor_id: exe.id.clone(),
             task_result: Some(task_result.clone().try_into()?),
+            launch_next_task,
+            sequence,
         };
 
-        self.client
+        let resp = self
+            .client
             .complete_task(req)
             .await
             .map_err(FlameError::from)?;
 
+        Ok(resp.into_inner())
+    }
+
+    /// The task of the launch response; the task whose input was corrupted
+    /// is failed, and the next one is launched.
+    async fn accept_task(
+        &mut self,
+        exe: &Executor,
+        mut resp: LaunchTaskResponse,
+    ) -> Result<Option<(TaskContext, Option<Duration>)>, FlameError> {
+        loop {
+            let lease = resp.lease_duration.map(Duration::from_secs);
+            let Some(t) = resp.task else {
+                return Ok(None);
+            };
+
+            match TaskContext::try_from(t) {
+                Ok(mut task) => {
+                    task.priority = resp.priority;
+                    task.sequence = resp.sequence;
+                    return Ok(Some((task, lease)));
+                }
+                Err(FlameError::Integrity(msg)) => {
+                    tracing::error!("Failed to launch task in <{}>: {msg}", exe.id);
+                    let task_result = TaskResult {
+                        state: TaskState::Failed,
+                        output: None,
+                        message: Some(msg),
+                        output_ref: None,
+                    };
+                    self.request_completion(exe, resp.sequence, &task_result, false)
+                        .await?;
+                    resp = self.request_task(exe).await?;
+                }
+                Err(e) => return Err(e),
+            }
+        }
+    }
+
+    pub async fn renew_task_lease(
+        &mut self,
+        exe: &Executor,
+        task: &TaskContext,
+    ) -> Result<(), FlameError> {
+        let req = RenewTaskLeaseRequest {
+            executor_id: exe.id.clone(),
+            session_id: task.session_id.clone(),
+            task_id: task.task_id.clone(),
+            sequence: task.sequence,
+        };
+
+        self.client
+            .renew_task_lease(req)
+            .await
+            .map_err(FlameError::from)?;
+
+        Ok(())
+    }
+
+    pub async fn annotate_task(
+        &mut self,
+        exe: &Executor,
+        task: &TaskContext,
+        annotation: String,
+    ) -> Result<(), FlameError> {
+        let req = AnnotateTaskRequest {
+            executor_id: exe.id.clone(),
+            session_id: task.session_id.clone(),
+            task_id: task.task_id.clone(),
+            annotation,
+        };
+
+        self.client
+            .annotate_task(req)
+            .await
+            .map_err(FlameError::from)?;
+
         Ok(())
     }
 
@@ -309,3 +470,25 @@ impl BackendClient {
         Ok(())
     }
 }
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_backend_endpoint() {
+        let mut ctx = FlameClusterContext::default();
+        ctx.cluster.endpoint = "https://flame-session-manager:8080".to_string();
+        assert_eq!(
+            backend_endpoint(&ctx).unwrap(),
+            "https://flame-session-manager:8081"
+        );
+
+        // The edge nodes dial the gateway of the session manager.
+        ctx.cluster.executors.backend_endpoint = Some("https://flame-gw:443".to_string());
+        assert_eq!(backend_endpoint(&ctx).unwrap(), "https://flame-gw:443");
+
+        ctx.cluster.executors.backend_endpoint = Some("flame-gw".to_string());
+        assert!(backend_endpoint(&ctx).is_err());
+    }
+}
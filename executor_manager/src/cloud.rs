@@ -0,0 +1,290 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//! The probes of the cloud metadata services, which label the node with its
+//! instance type, region, zone and spot flag at registration; so the
+//! scheduler can be cost-aware and zone-aware without manual labeling.
+//!
+//! The probes are best-effort: the node is registered without the labels if
+//! the metadata service is not reachable, e.g. on premise.
+
+use std::collections::HashMap;
+use std::time::Duration;
+
+use reqwest::Client;
+use serde_derive::Deserialize;
+
+use common::apis::{
+    LABEL_CLOUD_PROVIDER, LABEL_INSTANCE_TYPE, LABEL_REGION, LABEL_SPOT, LABEL_ZONE,
+};
+use common::ctx::CloudMetadata;
+use common::FlameError;
+
+/// The timeout of each request to the metadata services, which are local to
+/// the instance and respond in milliseconds.
+const PROBE_TIMEOUT_MILLIS: u64 = 1000;
+
+const AWS_METADATA_ENDPOINT: &str = "http://169.254.169.254/latest";
+const GCP_METADATA_ENDPOINT: &str = "http://metadata.google.internal/computeMetadata/v1/instance";
+const AZURE_METADATA_ENDPOINT: &str =
+    "http://169.254.169.254/metadata/instance/compute?api-version=2021-02-01";
+
+/// Probe the metadata of the cloud provider for the labels of the node; the
+/// labels are empty if the provider is not found.
+pub async fn probe(provider: CloudMetadata) -> HashMap<String, String> {
+    if provider == CloudMetadata::None {
+        return HashMap::new();
+    }
+
+    let client = match Client::builder()
+        .timeout(Duration::from_millis(PROBE_TIMEOUT_MILLIS))
+        .no_proxy()
+        .build()
+    {
+        Ok(client) => client,
+        Err(e) => {
+            tracing::warn!("Failed to build the client of cloud metadata: {e}");
+            return HashMap::new();
+        }
+    };
+
+    let labels = match provider {
+        CloudMetadata::None => return HashMap::new(),
+        CloudMetadata::Aws => probe_aws(&client).await,
+        CloudMetadata::Gcp => probe_gcp(&client).await,
+        CloudMetadata::Azure => probe_azure(&client).await,
+        CloudMetadata::Auto => {
+            let (aws, gcp, azure) =
+                tokio::join!(probe_aws(&client), probe_gcp(&client), probe_azure(&client));
+            aws.or(gcp).or(azure)
+        }
+    };
+
+    match labels {
+        Ok(labels) => {
+            tracing::info!("The node is labeled by cloud metadata: {labels:?}");
+            labels
+        }
+        Err(e) => {
+            tracing::debug!("No cloud metadata of <{provider:?}>: {e}");
+            HashMap::new()
+        }
+    }
+}
+
+async fn probe_aws(client: &Client) -> Result<HashMap<String, String>, FlameError> {
+    // IMDSv2 requires a session token for the metadata.
+    let token = client
+        .put(format!("{AWS_METADATA_ENDPOINT}/api/token"))
+        .header("X-aws-ec2-metadata-token-ttl-seconds", "60")
+        .send()
+        .await
+        .and_then(|resp| resp.error_for_status())
+        .map_err(network_error)?
+        .text()
+        .await
+        .map_err(network_error)?;
+
+    let get = |path: &'static str| {
+        let token = token.clone();
+        async move {
+            client
+                .get(format!("{AWS_METADATA_ENDPOINT}/meta-data/{path}"))
+                .header("X-aws-ec2-metadata-token", token)
+                .send()
+                .await
+                .and_then(|resp| resp.error_for_status())
+                .map_err(network_error)?
+                .text()
+                .await
+                .map_err(network_error)
+        }
+    };
+
+    let instance_type = get("instance-type").await?;
+    let zone = get("placement/availability-zone").await?;
+    let region = get("placement/region").await?;
+    // The life cycle is "spot" or "on-demand".
+    let life_cycle = get("instance-life-cycle").await.unwrap_or_default();
+
+    Ok(labels(
+        "aws",
+        &instance_type,
+        &region,
+        &zone,
+        life_cycle.trim() == "spot",
+    ))
+}
+
+async fn probe_gcp(client: &Client) -> Result<HashMap<String, String>, FlameError> {
+    let get = |path: &'static str| async move {
+        client
+            .get(format!("{GCP_METADATA_ENDPOINT}/{path}"))
+            .header("Metadata-Flavor", "Google")
+            .send()
+            .await
+            .and_then(|resp| resp.error_for_status())
+            .map_err(network_error)?
+            .text()
+            .await
+            .map_err(network_error)
+    };
+
+    // e.g. "projects/123456/machineTypes/n2-standard-8"
+    let machine_type = get("machine-type").await?;
+    // e.g. "projects/123456/zones/us-central1-a"
+    let zone = get("zone").await?;
+    let preemptible = get("scheduling/preemptible").await.unwrap_or_default();
+
+    let zone = last_segment(&zone);
+    Ok(labels(
+        "gcp",
+        last_segment(&machine_type),
+        gcp_region(zone),
+        zone,
+        preemptible.trim().eq_ignore_ascii_case("true"),
+    ))
+}
+
+#[derive(Debug, Default, Deserialize)]
+#[serde(rename_all = "camelCase")]
+struct AzureCompute {
+    #[serde(default)]
+    vm_size: String,
+    #[serde(default)]
+    location: String,
+    #[serde(default)]
+    zone: String,
+    /// "Spot", "Low" or "Regular"
+    #[serde(default)]
+    priority: String,
+}
+
+async fn probe_azure(client: &Client) -> Result<HashMap<String, String>, FlameError> {
+    let compute = client
+        .get(AZURE_METADATA_ENDPOINT)
+        .header("Metadata", "true")
+        .send()
+        .await
+        .and_then(|resp| resp.error_for_status())
+        .map_err(network_error)?
+        .text()
+        .await
+        .map_err(network_error)?;
+
+    azure_labels(&compute)
+}
+
+fn azure_labels(compute: &str) -> Result<HashMap<String, String>, FlameError> {
+    let compute: AzureCompute = serde_json::from_str(compute)
+        .map_err(|e| FlameError::Internal(format!("invalid azure metadata: {e}")))?;
+
+    // The zone of Azure is a number in the region, e.g. "eastus-1".
+    let zone = match compute.zone.as_str() {
+        "" => String::new(),
+        zone => format!("{}-{zone}", compute.location),
+    };
+
+    Ok(labels(
+        "azure",
+        &compute.vm_size,
+        &compute.location,
+        &zone,
+        matches!(compute.priority.as_str(), "Spot" | "Low"),
+    ))
+}
+
+fn labels(
+    provider: &str,
+    instance_type: &str,
+    region: &str,
+    zone: &str,
+    spot: bool,
+) -> HashMap<String, String> {
+    let mut labels = HashMap::from([
+        (LABEL_CLOUD_PROVIDER.to_string(), provider.to_string()),
+        (LABEL_SPOT.to_string(), spot.to_string()),
+    ]);
+
+    for (key, value) in [
+        (LABEL_INSTANCE_TYPE, instance_type),
+        (LABEL_REGION, region),
+        (LABEL_ZONE, zone),
+    ] {
+        let value = value.trim();
+        if !value.is_empty() {
+            labels.insert(key.to_string(), value.to_string());
+        }
+    }
+
+    labels
+}
+
+fn last_segment(path: &str) -> &str {
+    path.trim().rsplit('/').next().unwrap_or_default()
+}
+
+/// The region of a GCP zone, e.g. "us-central1" of "us-central1-a".
+fn gcp_region(zone: &str) -> &str {
+    zone.rsplit_once('-')
+        .map(|(region, _)| region)
+        .unwrap_or(zone)
+}
+
+fn network_error(e: reqwest::Error) -> FlameError {
+    FlameError::Network(e.to_string())
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_gcp_labels() {
+        let zone = last_segment("projects/123456/zones/us-central1-a");
+        let labels = labels(
+            "gcp",
+            last_segment("projects/123456/machineTypes/n2-standard-8"),
+            gcp_region(zone),
+            zone,
+            true,
+        );
+
+        assert_eq!(labels[LABEL_CLOUD_PROVIDER], "gcp");
+        assert_eq!(labels[LABEL_INSTANCE_TYPE], "n2-standard-8");
+        assert_eq!(labels[LABEL_REGION], "us-central1");
+        assert_eq!(labels[LABEL_ZONE], "us-central1-a");
+        assert_eq!(labels[LABEL_SPOT], "true");
+    }
+
+    #[test]
+    fn test_azure_labels() {
+        let labels = azure_labels(
+            r#"{"vmSize":"Standard_D4s_v3","location":"eastus","zone":"1","priority":"Spot","name":"vm-1"}"#,
+        )
+        .unwrap();
+
+        assert_eq!(labels[LABEL_CLOUD_PROVIDER], "azure");
+        assert_eq!(labels[LABEL_INSTANCE_TYPE], "Standard_D4s_v3");
+        assert_eq!(labels[LABEL_REGION], "eastus");
+        assert_eq!(labels[LABEL_ZONE], "eastus-1");
+        assert_eq!(labels[LABEL_SPOT], "true");
+
+        // No zone of the regional VMs.
+        let labels = azure_labels(r#"{"vmSize":"Standard_B2s","location":"westus"}"#).unwrap();
+        assert!(!labels.contains_key(LABEL_ZONE));
+        assert_eq!(labels[LABEL_SPOT], "false");
+
+        assert!(azure_labels("not json").is_err());
+    }
+}
@@ -0,0 +1,114 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//! The deduplication of the task outputs of a session: the outputs are
+//! hashed, and a repeated output is sent to the session manager as the
+//! reference `task://<task_id>` to the task of its first occurrence, which is
+//! expanded by the session manager, so the clients still get the bytes.
+
+use std::collections::HashMap;
+
+use stdng::{new_ptr, MutexPtr};
+
+use common::apis::checksum;
+use common::apis::{TaskResult, TaskState, TASK_OUTPUT_REF_PREFIX};
+
+/// The outputs remembered for a session; the new outputs beyond it are sent
+/// as is, so the memory of the executor is bounded.
+const MAX_DEDUP_OUTPUTS: usize = 10_000;
+
+pub type OutputDedupPtr = MutexPtr<OutputDedup>;
+
+/// The outputs sent by the executor in the bound session, by their checksums.
+pub struct OutputDedup {
+    min_size: u64,
+    outputs: HashMap<String, String>,
+}
+
+impl OutputDedup {
+    pub fn new(min_size: u64) -> OutputDedupPtr {
+        new_ptr(Self {
+            min_size,
+            outputs: HashMap::new(),
+        })
+    }
+
+    /// Replace the output by the reference to the task of its first
+    /// occurrence; the checksum of a new output is returned, which is
+    /// recorded by `record` after the result is accepted by the session
+    /// manager, so a reference never points to an output which was lost.
+    pub fn dedup(&self, result: TaskResult) -> (TaskResult, Option<String>) {
+        let Some(output) = result.output.as_ref() else {
+            return (result, None);
+        };
+        if result.state != TaskState::Succeed || (output.len() as u64) < self.min_size {
+            return (result, None);
+        }
+
+        let digest = checksum::checksum(output);
+        match self.outputs.get(&digest) {
+            Some(task_id) => (
+                TaskResult {
+                    output: None,
+                    output_ref: Some(format!("{TASK_OUTPUT_REF_PREFIX}{task_id}")),
+                    ..result
+                },
+                None,
+            ),
+            None => (result, Some(digest)),
+        }
+    }
+
+    /// Remember the output of the task by its checksum.
+    pub fn record(&mut self, digest: String, task_id: &str) {
+        if self.outputs.len() < MAX_DEDUP_OUTPUTS {
+            self.outputs.entry(digest).or_insert(task_id.to_string());
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use common::apis::TaskOutput;
+
+    fn task_result(state: TaskState, output: &str) -> TaskResult {
+        TaskResult {
+            state,
+            output: Some(TaskOutput::from(output.to_string())),
+            message: None,
+            output_ref: None,
+        }
+    }
+
+    #[test]
+    fn test_dedup_outputs() {
+        let dedup = OutputDedup::new(4);
+        let mut dedup = dedup.lock().unwrap();
+
+        let (result, digest) = dedup.dedup(task_result(TaskState::Succeed, "result"));
+        assert!(result.output.is_some());
+        dedup.record(digest.unwrap(), "1");
+
+        let (result, digest) = dedup.dedup(task_result(TaskState::Succeed, "result"));
+        assert!(result.output.is_none());
+        assert_eq!(result.output_ref.as_deref(), Some("task://1"));
+        assert_eq!(digest, None);
+
+        // The small outputs and the failed tasks are sent as is.
+        let (result, digest) = dedup.dedup(task_result(TaskState::Succeed, "abc"));
+        assert!(result.output.is_some() && digest.is_none());
+        let (result, digest) = dedup.dedup(task_result(TaskState::Failed, "result"));
+        assert!(result.output.is_some() && digest.is_none());
+    }
+}
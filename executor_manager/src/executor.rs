@@ -13,11 +13,18 @@ limitations under the License.
 
 use std::convert::TryFrom;
 use std::sync::{Arc, Mutex};
+use std::time::Duration;
 use stdng::{lock_ptr, logs::TraceFn, trace_fn, MutexPtr};
 use tokio::task::JoinHandle;
 
 use crate::client::BackendClient;
-use crate::shims::ShimPtr;
+use crate::dedup::OutputDedupPtr;
+use crate::logs::LogForwarderPtr;
+use crate::restarts::{Exclusion, RestartBudgetPtr};
+use crate::scratch::ScratchDirPtr;
+use crate::shims::health::HealthMonitorPtr;
+use crate::shims::schema::OutputSchemaPtr;
+use crate::shims::{ShimPtr, WarmShim};
 use ::rpc::flame::v1::{self as rpc, ExecutorSpec, ExecutorStatus, Metadata};
 
 use crate::states;
@@ -36,6 +43,13 @@ pub struct Executor {
 
     pub session: Option<SessionContext>,
     pub task: Option<TaskContext>,
+    /// The next task piggybacked on the completion of the previous one, with
+    /// its lease duration; it's run before launching another task.
+    pub next_task: Option<(TaskContext, Option<Duration>)>,
+    /// The group of the session entered by the service, i.e. the group of
+    /// the last task; it's left before a task of another group or leaving
+    /// the session.
+    pub group: Option<String>,
     pub context: Option<FlameClusterContext>,
 
     /// The shim instance used for task execution.
@@ -43,6 +57,34 @@ pub struct Executor {
     /// the executor binds to a session.
     pub shim_instance: Option<ShimPtr>,
 
+    /// The shim kept warm after the previous session was unbound, for the
+    /// next session of the same application.
+    pub warm_shim: Option<WarmShim>,
+
+    /// The scratch directory of the bound session, if scratch is enabled.
+    pub scratch: Option<ScratchDirPtr>,
+
+    /// The health monitor of the bound session, if the application defines
+    /// a health probe.
+    pub health: Option<HealthMonitorPtr>,
+
+    /// The output schema of the bound application, if any.
+    pub output_schema: Option<OutputSchemaPtr>,
+
+    /// The outputs sent in the bound session, if the outputs are deduplicated.
+    pub outputs: Option<OutputDedupPtr>,
+
+    /// The forwarder of the service logs, if any sink is configured.
+    pub logs: Option<LogForwarderPtr>,
+
+    /// The crashes of the services by application, if the restarts are
+    /// budgeted.
+    pub restarts: Option<RestartBudgetPtr>,
+
+    /// The executor is draining: it finishes the in-flight task, and then
+    /// unbinds and releases itself instead of pulling more tasks.
+    pub draining: bool,
+
     pub state: ExecutorState,
 }
 
@@ -83,8 +125,18 @@ impl TryFrom<&rpc::Executor> for Executor {
             shim: Shim::from(spec.shim()), // Get shim from spec
             session: None,
             task: None,
+            next_task: None,
+            group: None,
             context: None,
             shim_instance: None,
+            warm_shim: None,
+            scratch: None,
+            health: None,
+            output_schema: None,
+            outputs: None,
+            logs: None,
+            restarts: None,
+            draining: false,
             state,
         })
     }
@@ -101,6 +153,7 @@ impl From<&Executor> for rpc::Executor {
         let metadata = Some(Metadata {
             id: e.id.clone(),
             name: e.id.clone(),
+            resource_version: None,
         });
 
         let spec = Some(ExecutorSpec {
@@ -125,6 +178,41 @@ impl From<&Executor> for rpc::Executor {
 }
 
 impl Executor {
+    /// Count a crash of the service of the session's application; the
+    /// crash-looping application is reported by the next binding, and the
+    /// session manager records it as an event of the session.
+    pub fn record_crash(&self, ssn: &SessionContext) {
+        let Some(restarts) = &self.restarts else {
+            return;
+        };
+        let Ok(mut restarts) = lock_ptr!(restarts) else {
+            return;
+        };
+        let app = &ssn.application.name;
+        if restarts.crash(app, &ssn.session_id) {
+            tracing::warn!(
+                "Application <{app}> is crash-looping on executor <{}>, exclude it from binding.",
+                self.id
+            );
+        }
+    }
+
+    /// The service of the application served its session.
+    pub fn reset_crashes(&self, app: &str) {
+        if let Some(Ok(mut restarts)) = self.restarts.as_ref().map(|r| lock_ptr!(r)) {
+            restarts.reset(app);
+        }
+    }
+
+    /// The applications backing off or crash-looping on the executor, which
+    /// are excluded from its bindings.
+    pub fn excluded_applications(&self) -> Vec<Exclusion> {
+        match self.restarts.as_ref().map(|r| lock_ptr!(r)) {
+            Some(Ok(restarts)) => restarts.excluded(),
+            _ => vec![],
+        }
+    }
+
     pub fn update(&mut self, next: &Executor) {
         tracing::info!(
             "Update executor <{}> from <{}> to <{}>",
@@ -134,8 +222,15 @@ impl Executor {
         );
         self.state = next.state;
         self.shim_instance = next.shim_instance.clone();
+        self.scratch = next.scratch.clone();
+        self.health = next.health.clone();
+        self.output_schema = next.output_schema.clone();
+        self.outputs = next.outputs.clone();
         self.session = next.session.clone();
         self.task = next.task.clone();
+        self.next_task = next.next_task.clone();
+        self.warm_shim = next.warm_shim.clone();
+        self.group = next.group.clone();
     }
 }
 
@@ -178,3 +273,145 @@ pub fn start(client: BackendClient, executor: ExecutorPtr) {
         }
     });
 }
+
+#[cfg(test)]
+mod tests {
+    use std::collections::HashMap;
+
+    use async_trait::async_trait;
+    use stdng::new_ptr;
+
+    use super::*;
+    use common::apis::{ApplicationContext, TaskResult};
+
+    struct NoopShim;
+
+    #[async_trait]
+    impl crate::shims::Shim for NoopShim {
+        async fn on_session_enter(&mut self, _: &SessionContext) -> Result<(), FlameError> {
+            Ok(())
+        }
+
+        async fn on_task_invoke(&mut self, _: &TaskContext) -> Result<TaskResult, FlameError> {
+            Err(FlameError::Internal("not invoked".to_string()))
+        }
+
+        async fn on_session_leave(&mut self) -> Result<(), FlameError> {
+            Ok(())
+        }
+    }
+
+    fn application(name: &str) -> ApplicationContext {
+        ApplicationContext {
+            name: name.to_string(),
+            shim: Shim::Host,
+            image: None,
+            command: None,
+            arguments: vec![],
+            working_directory: None,
+            environments: HashMap::new(),
+            url: None,
+            output_schema: None,
+            outputs: None,
+        }
+    }
+
+    fn executor(state: ExecutorState) -> Executor {
+        Executor {
+            id: "exe-1".to_string(),
+            resreq: ResourceRequirement::default(),
+            node: "node-1".to_string(),
+            slots: 1,
+            shim: Shim::Host,
+            session: None,
+            task: None,
+            next_task: None,
+            group: None,
+            context: None,
+            shim_instance: None,
+            warm_shim: None,
+            scratch: None,
+            health: None,
+            output_schema: None,
+            outputs: None,
+            logs: None,
+            restarts: None,
+            draining: false,
+            state,
+        }
+    }
+
+    fn task(id: &str, group: Option<&str>) -> TaskContext {
+        TaskContext {
+            task_id: id.to_string(),
+            session_id: "ssn-1".to_string(),
+            input: None,
+            principal: None,
+            deadline: None,
+            content_type: None,
+            group: group.map(str::to_string),
+            method: None,
+            priority: None,
+            sequence: None,
+        }
+    }
+
+    /// Run a step of the runner: the state works on a clone of the executor,
+    /// whose result is updated back to the executor.
+    fn step(executor: &ExecutorPtr, state: impl FnOnce(&mut Executor)) {
+        let mut next = lock_ptr!(executor).unwrap().clone();
+        state(&mut next);
+        lock_ptr!(executor).unwrap().update(&next);
+    }
+
+    #[test]
+    fn test_update_next_task() {
+        let executor: ExecutorPtr = new_ptr(executor(ExecutorState::Bound));
+
+        // The task piggybacked on the completion is kept for the next step.
+        step(&executor, |exe| {
+            exe.task = Some(task("1", None));
+            exe.next_task = Some((task("2", None), Some(Duration::from_secs(30))));
+        });
+        let next_task = lock_ptr!(executor).unwrap().next_task.clone();
+        assert_eq!(
+            next_task.map(|(task, lease)| (task.task_id, lease)),
+            Some(("2".to_string(), Some(Duration::from_secs(30))))
+        );
+
+        // Bound to bound again: the next task is taken and run.
+        step(&executor, |exe| {
+            let (task, _) = exe.next_task.take().unwrap();
+            exe.task = Some(task);
+        });
+        let exe = lock_ptr!(executor).unwrap();
+        assert_eq!(exe.state, ExecutorState::Bound);
+        assert!(exe.next_task.is_none());
+        assert_eq!(exe.task.as_ref().map(|t| t.task_id.as_str()), Some("2"));
+    }
+
+    #[test]
+    fn test_update_warm_shim() {
+        let app = application("pi");
+        let shim: ShimPtr = Arc::new(tokio::sync::Mutex::new(NoopShim));
+        let executor: ExecutorPtr = new_ptr(executor(ExecutorState::Unbinding));
+
+        // The unbinding keeps the shim warm, which outlives the state.
+        step(&executor, |exe| {
+            exe.warm_shim = Some(WarmShim::new(&app, shim.clone(), Duration::from_secs(60)));
+            exe.shim_instance = None;
+            exe.state = ExecutorState::Idle;
+        });
+        assert_eq!(Arc::strong_count(&shim), 2);
+
+        // The next session of the application reuses the warm shim.
+        step(&executor, |exe| {
+            let warm = exe.warm_shim.take().and_then(|warm| warm.reuse(&app));
+            exe.shim_instance = warm;
+            exe.state = ExecutorState::Bound;
+        });
+        let exe = lock_ptr!(executor).unwrap();
+        assert!(exe.warm_shim.is_none());
+        assert!(Arc::ptr_eq(exe.shim_instance.as_ref().unwrap(), &shim));
+    }
+}
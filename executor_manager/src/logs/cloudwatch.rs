@@ -0,0 +1,121 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//! The sink of CloudWatch Logs, with the credentials of the environment. The
+//! log stream of each executor is `<node>/<executor_id>`, created on demand;
+//! each event is a JSON of the line with its session, task and stream.
+
+use std::collections::{BTreeMap, HashSet};
+
+use async_trait::async_trait;
+use aws_sdk_cloudwatchlogs::types::InputLogEvent;
+use aws_sdk_cloudwatchlogs::Client;
+use serde_json::json;
+use tokio::sync::Mutex;
+
+use common::FlameError;
+
+use crate::logs::{LogRecord, Sink};
+
+pub struct CloudWatchSink {
+    client: Client,
+    group: String,
+    streams: Mutex<HashSet<String>>,
+}
+
+impl CloudWatchSink {
+    pub async fn new(group: &str, region: Option<&str>) -> Self {
+        let mut loader = aws_config::defaults(aws_config::BehaviorVersion::latest());
+        if let Some(region) = region {
+            loader = loader.region(aws_config::Region::new(region.to_string()));
+        }
+
+        Self {
+            client: Client::new(&loader.load().await),
+            group: group.to_string(),
+            streams: Mutex::new(HashSet::new()),
+        }
+    }
+
+    async fn ensure_stream(&self, stream: &str) -> Result<(), FlameError> {
+        let mut streams = self.streams.lock().await;
+        if streams.contains(stream) {
+            return Ok(());
+        }
+
+        let result = self
+            .client
+            .create_log_stream()
+            .log_group_name(&self.group)
+            .log_stream_name(stream)
+            .send()
+            .await;
+
+        match result {
+            Ok(_) => {}
+            Err(e)
+                if e.as_service_error()
+                    .is_some_and(|e| e.is_resource_already_exists_exception()) => {}
+            Err(e) => return Err(FlameError::Network(format!("cloudwatch: {e}"))),
+        }
+
+        streams.insert(stream.to_string());
+        Ok(())
+    }
+}
+
+#[async_trait]
+impl Sink for CloudWatchSink {
+    fn name(&self) -> &'static str {
+        "cloudwatch"
+    }
+
+    async fn send(&self, records: &[LogRecord]) -> Result<(), FlameError> {
+        let mut streams: BTreeMap<String, Vec<InputLogEvent>> = BTreeMap::new();
+
+        for record in records {
+            let message = json!({
+                "session_id": record.session_id,
+                "task_id": record.task_id,
+                "application": record.application,
+                "stream": record.stream.as_str(),
+                "line": record.line,
+            });
+            let event = InputLogEvent::builder()
+                .timestamp(record.timestamp.timestamp_millis())
+                .message(message.to_string())
+                .build()
+                .map_err(|e| FlameError::Internal(e.to_string()))?;
+
+            streams
+                .entry(format!("{}/{}", record.node, record.executor_id))
+                .or_default()
+                .push(event);
+        }
+
+        // The events of a stream are in the order of the records.
+        for (stream, events) in streams {
+            self.ensure_stream(&stream).await?;
+            self.client
+                .put_log_events()
+                .log_group_name(&self.group)
+                .log_stream_name(&stream)
+                .set_log_events(Some(events))
+                .send()
+                .await
+                .map_err(|e| FlameError::Network(format!("cloudwatch: {e}")))?;
+        }
+
+        Ok(())
+    }
+}
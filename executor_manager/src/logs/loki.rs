@@ -0,0 +1,157 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//! The sink of Loki by its push API. The streams are labeled by the node,
+//! application and output stream; the executor, session and task are the
+//! structured metadata of each line instead of labels, to keep the number of
+//! the streams bounded. It requires Loki 2.9 or later.
+
+use std::collections::{BTreeMap, HashMap};
+use std::time::Duration;
+
+use async_trait::async_trait;
+use reqwest::Client;
+use serde_json::{json, Value};
+
+use common::FlameError;
+
+use crate::logs::{LogRecord, Sink};
+
+const LOKI_PUSH_PATH: &str = "/loki/api/v1/push";
+const LOKI_TIMEOUT: Duration = Duration::from_secs(10);
+
+pub struct LokiSink {
+    client: Client,
+    url: String,
+    labels: HashMap<String, String>,
+}
+
+impl LokiSink {
+    pub fn new(url: &str, labels: &HashMap<String, String>) -> Result<Self, FlameError> {
+        let client = Client::builder()
+            .timeout(LOKI_TIMEOUT)
+            .build()
+            .map_err(|e| FlameError::Internal(format!("failed to build loki client: {e}")))?;
+
+        Ok(Self {
+            client,
+            url: format!("{url}{LOKI_PUSH_PATH}"),
+            labels: labels.clone(),
+        })
+    }
+
+    fn payload(&self, records: &[LogRecord]) -> Value {
+        let mut streams: BTreeMap<(String, String, &str), Vec<Value>> = BTreeMap::new();
+
+        for record in records {
+            let mut metadata = json!({ "executor_id": record.executor_id });
+            if let Some(session_id) = &record.session_id {
+                metadata["session_id"] = json!(session_id);
+            }
+            if let Some(task_id) = &record.task_id {
+                metadata["task_id"] = json!(task_id);
+            }
+
+            let timestamp = record.timestamp.timestamp_nanos_opt().unwrap_or_default();
+            streams
+                .entry((
+                    record.node.clone(),
+                    record.application.clone(),
+                    record.stream.as_str(),
+                ))
+                .or_default()
+                .push(json!([timestamp.to_string(), record.line, metadata]));
+        }
+
+        let streams: Vec<Value> = streams
+            .into_iter()
+            .map(|((node, application, stream), values)| {
+                let mut labels = self.labels.clone();
+                labels.insert("job".to_string(), "flame".to_string());
+                labels.insert("node".to_string(), node);
+                labels.insert("application".to_string(), application);
+                labels.insert("stream".to_string(), stream.to_string());
+
+                json!({ "stream": labels, "values": values })
+            })
+            .collect();
+
+        json!({ "streams": streams })
+    }
+}
+
+#[async_trait]
+impl Sink for LokiSink {
+    fn name(&self) -> &'static str {
+        "loki"
+    }
+
+    async fn send(&self, records: &[LogRecord]) -> Result<(), FlameError> {
+        let body = serde_json::to_vec(&self.payload(records))
+            .map_err(|e| FlameError::Internal(e.to_string()))?;
+
+        self.client
+            .post(&self.url)
+            .header("Content-Type", "application/json")
+            .body(body)
+            .send()
+            .await
+            .and_then(|resp| resp.error_for_status())
+            .map_err(|e| FlameError::Network(e.to_string()))?;
+
+        Ok(())
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::logs::tests::test_record;
+    use crate::logs::LogStream;
+
+    #[test]
+    fn test_loki_payload() {
+        let sink = LokiSink::new(
+            "http://loki:3100",
+            &HashMap::from([("cluster".to_string(), "prod".to_string())]),
+        )
+        .unwrap();
+        assert_eq!(sink.url, "http://loki:3100/loki/api/v1/push");
+
+        let mut stdout = test_record("started");
+        stdout.stream = LogStream::Stdout;
+        stdout.task_id = None;
+        let payload = sink.payload(&[test_record("failed"), stdout, test_record("exited")]);
+
+        let streams = payload["streams"].as_array().unwrap();
+        assert_eq!(streams.len(), 2);
+
+        let stderr = streams
+            .iter()
+            .find(|s| s["stream"]["stream"] == "stderr")
+            .unwrap();
+        assert_eq!(stderr["stream"]["cluster"], "prod");
+        assert_eq!(stderr["stream"]["application"], "pi");
+        assert_eq!(stderr["values"].as_array().unwrap().len(), 2);
+        assert_eq!(stderr["values"][0][0], "1700000000000000000");
+        assert_eq!(stderr["values"][0][1], "failed");
+        assert_eq!(stderr["values"][0][2]["task_id"], "1");
+
+        let stdout = streams
+            .iter()
+            .find(|s| s["stream"]["stream"] == "stdout")
+            .unwrap();
+        assert!(stdout["values"][0][2].get("task_id").is_none());
+        assert_eq!(stdout["values"][0][2]["session_id"], "ssn-1");
+    }
+}
@@ -0,0 +1,297 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//! The forwarding of the service logs to the centralized sinks, e.g. Loki,
+//! CloudWatch Logs or syslog. The stdout and stderr of the services are still
+//! written to the log files of the executors; each line is also tagged with
+//! the node, executor, session and task, and shipped to the sinks in batches.
+//!
+//! The forwarding is best-effort: the lines are dropped instead of blocking
+//! the services if the sinks can not keep up.
+
+#[cfg(feature = "cloudwatch")]
+mod cloudwatch;
+mod loki;
+mod syslog;
+
+use std::fs::File;
+use std::sync::atomic::{AtomicU64, Ordering};
+use std::sync::Arc;
+use std::time::Duration;
+
+use async_trait::async_trait;
+use chrono::{DateTime, Utc};
+use stdng::{lock_ptr, MutexPtr};
+use tokio::io::{AsyncBufReadExt, AsyncRead, AsyncWriteExt, BufReader};
+use tokio::sync::mpsc;
+
+use common::ctx::FlameLogForwarder;
+use common::FlameError;
+
+/// The maximum lines buffered for the sinks; the later lines are dropped.
+const LOG_BUFFER_SIZE: usize = 8192;
+/// The maximum lines of each batch sent to the sinks.
+const LOG_BATCH_SIZE: usize = 512;
+/// The interval to flush the partial batches.
+const LOG_FLUSH_INTERVAL: Duration = Duration::from_secs(1);
+
+#[derive(Clone, Copy, Debug, PartialEq, Eq)]
+pub enum LogStream {
+    Stdout,
+    Stderr,
+}
+
+impl LogStream {
+    pub fn as_str(&self) -> &'static str {
+        match self {
+            LogStream::Stdout => "stdout",
+            LogStream::Stderr => "stderr",
+        }
+    }
+}
+
+#[derive(Clone, Debug)]
+pub struct LogRecord {
+    pub timestamp: DateTime<Utc>,
+    pub node: String,
+    pub executor_id: String,
+    pub application: String,
+    pub session_id: Option<String>,
+    pub task_id: Option<String>,
+    pub stream: LogStream,
+    pub line: String,
+}
+
+/// The sink of the log records, e.g. Loki.
+#[async_trait]
+trait Sink: Send + Sync {
+    fn name(&self) -> &'static str;
+    async fn send(&self, records: &[LogRecord]) -> Result<(), FlameError>;
+}
+
+type SinkPtr = Box<dyn Sink>;
+
+pub struct LogForwarder {
+    tx: mpsc::Sender<LogRecord>,
+    dropped: AtomicU64,
+}
+
+pub type LogForwarderPtr = Arc<LogForwarder>;
+
+/// Build the forwarder of the sinks in the configuration; None if no sink
+/// is configured.
+pub async fn new_ptr(conf: &[FlameLogForwarder]) -> Result<Option<LogForwarderPtr>, FlameError> {
+    if conf.is_empty() {
+        return Ok(None);
+    }
+
+    let mut sinks: Vec<SinkPtr> = vec![];
+    for forwarder in conf {
+        sinks.push(match forwarder {
+            FlameLogForwarder::Loki { url, labels } => Box::new(loki::LokiSink::new(url, labels)?),
+            FlameLogForwarder::Syslog { address } => {
+                Box::new(syslog::SyslogSink::new(address).await?)
+            }
+            #[cfg(feature = "cloudwatch")]
+            FlameLogForwarder::CloudWatch { group, region } => {
+                Box::new(cloudwatch::CloudWatchSink::new(group, region.as_deref()).await)
+            }
+            #[cfg(not(feature = "cloudwatch"))]
+            FlameLogForwarder::CloudWatch { .. } => {
+                return Err(FlameError::InvalidConfig(
+                    "the cloudwatch log forwarder requires the `cloudwatch` feature".to_string(),
+                ))
+            }
+        });
+    }
+
+    let (tx, rx) = mpsc::channel(LOG_BUFFER_SIZE);
+    tokio::spawn(run(rx, sinks));
+
+    Ok(Some(Arc::new(LogForwarder {
+        tx,
+        dropped: AtomicU64::new(0),
+    })))
+}
+
+impl LogForwarder {
+    pub fn forward(&self, record: LogRecord) {
+        if self.tx.try_send(record).is_err() {
+            let dropped = self.dropped.fetch_add(1, Ordering::Relaxed) + 1;
+            // Only warn once per batch of dropped lines to avoid flooding.
+            if dropped % LOG_BATCH_SIZE as u64 == 1 {
+                tracing::warn!("The log sinks can not keep up, {dropped} lines were dropped.");
+            }
+        }
+    }
+}
+
+async fn run(mut rx: mpsc::Receiver<LogRecord>, sinks: Vec<SinkPtr>) {
+    let mut batch = Vec::with_capacity(LOG_BATCH_SIZE);
+    let mut ticker = tokio::time::interval(LOG_FLUSH_INTERVAL);
+
+    loop {
+        tokio::select! {
+            record = rx.recv() => match record {
+                Some(record) => {
+                    batch.push(record);
+                    if batch.len() < LOG_BATCH_SIZE {
+                        continue;
+                    }
+                }
+                None => {
+                    flush(&sinks, &mut batch).await;
+                    return;
+                }
+            },
+            _ = ticker.tick() => {}
+        }
+
+        flush(&sinks, &mut batch).await;
+    }
+}
+
+async fn flush(sinks: &[SinkPtr], batch: &mut Vec<LogRecord>) {
+    if batch.is_empty() {
+        return;
+    }
+
+    for sink in sinks {
+        if let Err(e) = sink.send(batch).await {
+            tracing::warn!(
+                "Failed to forward {} lines to <{}>: {e}",
+                batch.len(),
+                sink.name()
+            );
+        }
+    }
+    batch.clear();
+}
+
+/// The session and task of the service instance, updated by the shim.
+#[derive(Clone, Debug, Default)]
+pub struct LogScope {
+    pub session_id: Option<String>,
+    pub task_id: Option<String>,
+}
+
+/// The tags of the logs of a service instance.
+#[derive(Clone)]
+pub struct LogTags {
+    pub node: String,
+    pub executor_id: String,
+    pub application: String,
+    pub scope: MutexPtr<LogScope>,
+}
+
+impl LogTags {
+    fn record(&self, stream: LogStream, line: String) -> LogRecord {
+        let scope = lock_ptr!(self.scope)
+            .map(|scope| scope.clone())
+            .unwrap_or_default();
+
+        LogRecord {
+            timestamp: Utc::now(),
+            node: self.node.clone(),
+            executor_id: self.executor_id.clone(),
+            application: self.application.clone(),
+            session_id: scope.session_id,
+            task_id: scope.task_id,
+            stream,
+            line,
+        }
+    }
+}
+
+/// Copy the output of the service into its log file line by line, and
+/// forward each line with the tags; it returns when the output is closed.
+pub fn tee<R>(reader: R, file: File, stream: LogStream, tags: LogTags, forwarder: LogForwarderPtr)
+where
+    R: AsyncRead + Unpin + Send + 'static,
+{
+    tokio::spawn(async move {
+        let mut reader = BufReader::new(reader);
+        let mut file = tokio::fs::File::from_std(file);
+        let mut buf = Vec::new();
+
+        loop {
+            buf.clear();
+            match reader.read_until(b'\n', &mut buf).await {
+                Ok(0) => break,
+                Ok(_) => {
+                    if let Err(e) = file.write_all(&buf).await {
+                        tracing::debug!(
+                            "Failed to write the {} of executor <{}>: {e}",
+                            stream.as_str(),
+                            tags.executor_id
+                        );
+                    }
+
+                    let line = String::from_utf8_lossy(&buf).trim_end().to_string();
+                    forwarder.forward(tags.record(stream, line));
+                }
+                Err(e) => {
+                    tracing::debug!(
+                        "Failed to read the {} of executor <{}>: {e}",
+                        stream.as_str(),
+                        tags.executor_id
+                    );
+                    break;
+                }
+            }
+        }
+
+        let _ = file.flush().await;
+    });
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    pub(crate) fn test_record(line: &str) -> LogRecord {
+        LogRecord {
+            timestamp: DateTime::from_timestamp(1700000000, 0).unwrap(),
+            node: "node-1".to_string(),
+            executor_id: "exe-1".to_string(),
+            application: "pi".to_string(),
+            session_id: Some("ssn-1".to_string()),
+            task_id: Some("1".to_string()),
+            stream: LogStream::Stderr,
+            line: line.to_string(),
+        }
+    }
+
+    #[test]
+    fn test_log_tags() {
+        let tags = LogTags {
+            node: "node-1".to_string(),
+            executor_id: "exe-1".to_string(),
+            application: "pi".to_string(),
+            scope: stdng::new_ptr(LogScope::default()),
+        };
+
+        let record = tags.record(LogStream::Stdout, "started".to_string());
+        assert!(record.session_id.is_none());
+        assert!(record.task_id.is_none());
+
+        *tags.scope.lock().unwrap() = LogScope {
+            session_id: Some("ssn-1".to_string()),
+            task_id: Some("1".to_string()),
+        };
+        let record = tags.record(LogStream::Stderr, "failed".to_string());
+        assert_eq!(record.session_id.as_deref(), Some("ssn-1"));
+        assert_eq!(record.task_id.as_deref(), Some("1"));
+        assert_eq!(record.stream.as_str(), "stderr");
+    }
+}
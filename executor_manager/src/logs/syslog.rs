@@ -0,0 +1,191 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//! The sink of syslog by RFC 5424, over UDP (one message per datagram) or TCP
+//! (octet counting of RFC 6587). The executor, session and task are the
+//! structured data of each message.
+
+use async_trait::async_trait;
+use tokio::io::AsyncWriteExt;
+use tokio::net::{TcpStream, UdpSocket};
+use tokio::sync::Mutex;
+
+use common::FlameError;
+
+use crate::logs::{LogRecord, LogStream, Sink};
+
+/// The facility of the user-level messages.
+const FACILITY_USER: u8 = 1;
+const SEVERITY_ERROR: u8 = 3;
+const SEVERITY_INFO: u8 = 6;
+/// The private enterprise number of the structured data; 32473 is reserved
+/// for the examples by RFC 5612.
+const SD_ID: &str = "flame@32473";
+
+enum Transport {
+    Udp(UdpSocket),
+    Tcp {
+        address: String,
+        stream: Mutex<Option<TcpStream>>,
+    },
+}
+
+pub struct SyslogSink {
+    transport: Transport,
+}
+
+impl SyslogSink {
+    pub async fn new(address: &str) -> Result<Self, FlameError> {
+        let transport = match address.split_once("://") {
+            Some(("udp", address)) => {
+                let socket = UdpSocket::bind("0.0.0.0:0").await.map_err(network_error)?;
+                socket.connect(address).await.map_err(network_error)?;
+                Transport::Udp(socket)
+            }
+            // The TCP connection is established by the first batch, so the
+            // executor manager starts without the syslog server.
+            Some(("tcp", address)) => Transport::Tcp {
+                address: address.to_string(),
+                stream: Mutex::new(None),
+            },
+            _ => {
+                return Err(FlameError::InvalidConfig(format!(
+                    "invalid syslog address <{address}>"
+                )))
+            }
+        };
+
+        Ok(Self { transport })
+    }
+}
+
+#[async_trait]
+impl Sink for SyslogSink {
+    fn name(&self) -> &'static str {
+        "syslog"
+    }
+
+    async fn send(&self, records: &[LogRecord]) -> Result<(), FlameError> {
+        match &self.transport {
+            Transport::Udp(socket) => {
+                for record in records {
+                    socket
+                        .send(format_message(record).as_bytes())
+                        .await
+                        .map_err(network_error)?;
+                }
+            }
+            Transport::Tcp { address, stream } => {
+                let mut frames = Vec::new();
+                for record in records {
+                    let message = format_message(record);
+                    frames.extend_from_slice(format!("{} {message}", message.len()).as_bytes());
+                }
+
+                let mut stream = stream.lock().await;
+                if stream.is_none() {
+                    *stream = Some(TcpStream::connect(address).await.map_err(network_error)?);
+                }
+                if let Some(conn) = stream.as_mut() {
+                    if let Err(e) = conn.write_all(&frames).await {
+                        // Reconnect by the next batch.
+                        *stream = None;
+                        return Err(network_error(e));
+                    }
+                }
+            }
+        }
+
+        Ok(())
+    }
+}
+
+/// Format the record as a RFC 5424 message, e.g.
+/// `<11>1 2023-11-14T22:13:20.000Z node-1 pi - stderr [flame@32473 executor="exe-1"] failed`.
+fn format_message(record: &LogRecord) -> String {
+    let severity = match record.stream {
+        LogStream::Stdout => SEVERITY_INFO,
+        LogStream::Stderr => SEVERITY_ERROR,
+    };
+
+    let mut sd = format!("[{SD_ID} executor=\"{}\"", escape(&record.executor_id));
+    if let Some(session_id) = &record.session_id {
+        sd.push_str(&format!(" session=\"{}\"", escape(session_id)));
+    }
+    if let Some(task_id) = &record.task_id {
+        sd.push_str(&format!(" task=\"{}\"", escape(task_id)));
+    }
+    sd.push(']');
+
+    format!(
+        "<{}>1 {} {} {} - {} {sd} {}",
+        FACILITY_USER * 8 + severity,
+        record.timestamp.format("%Y-%m-%dT%H:%M:%S%.3fZ"),
+        header(&record.node),
+        header(&record.application),
+        record.stream.as_str(),
+        record.line
+    )
+}
+
+/// The values of the structured data are escaped by RFC 5424.
+fn escape(value: &str) -> String {
+    value
+        .replace('\\', "\\\\")
+        .replace('"', "\\\"")
+        .replace(']', "\\]")
+}
+
+/// The header fields are printable ASCII without spaces; "-" if empty.
+fn header(value: &str) -> String {
+    let value: String = value
+        .chars()
+        .filter(|c| c.is_ascii_graphic())
+        .take(48)
+        .collect();
+    if value.is_empty() {
+        "-".to_string()
+    } else {
+        value
+    }
+}
+
+fn network_error(e: std::io::Error) -> FlameError {
+    FlameError::Network(format!("syslog: {e}"))
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::logs::tests::test_record;
+
+    #[test]
+    fn test_format_message() {
+        assert_eq!(
+            format_message(&test_record("division by zero")),
+            "<11>1 2023-11-14T22:13:20.000Z node-1 pi - stderr \
+             [flame@32473 executor=\"exe-1\" session=\"ssn-1\" task=\"1\"] division by zero"
+        );
+
+        let mut record = test_record("started");
+        record.stream = LogStream::Stdout;
+        record.session_id = Some("ssn \"1\"]".to_string());
+        record.task_id = None;
+        record.application = String::new();
+        assert_eq!(
+            format_message(&record),
+            "<14>1 2023-11-14T22:13:20.000Z node-1 - - stdout \
+             [flame@32473 executor=\"exe-1\" session=\"ssn \\\"1\\\"\\]\"] started"
+        );
+    }
+}
@@ -19,8 +19,14 @@ use common::ctx::FlameClusterContext;
 use common::FlameError;
 
 mod client;
+mod cloud;
+mod dedup;
 mod executor;
+mod logs;
 mod manager;
+mod restarts;
+mod results;
+mod scratch;
 mod shims;
 mod states;
 mod stream_handler;
@@ -35,6 +41,9 @@ struct Cli {
     config: Option<String>,
     #[arg(long)]
     slots: Option<i32>,
+    /// Inject the faults of the chaos configuration, only for the soak tests.
+    #[arg(long)]
+    unsafe_chaos: bool,
 }
 
 fn build_runtime(name: &str, threads: usize) -> Result<Runtime, FlameError> {
@@ -49,9 +58,11 @@ fn build_runtime(name: &str, threads: usize) -> Result<Runtime, FlameError> {
 #[tokio::main]
 async fn main() -> Result<(), FlameError> {
     let _log_guard = common::init_logger(Some("fem"))?;
+    common::crypto::init()?;
 
     let cli = Cli::parse();
     let ctx = FlameClusterContext::from_file(cli.config)?;
+    ctx.check_chaos(cli.unsafe_chaos)?;
 
     tracing::info!("flame-executor-manager is starting ...");
 
@@ -121,5 +132,11 @@ async fn main() -> Result<(), FlameError> {
     let (res, idx, _) = select_all(handlers).await;
     tracing::info!("Thread <{idx}> exited with result: {res:?}");
 
+    // The runtimes can not be dropped within the async context.
+    manager_rt.shutdown_background();
+    if let Some(cache_rt) = cache_rt {
+        cache_rt.shutdown_background();
+    }
+
     Ok(())
 }
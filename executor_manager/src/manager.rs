@@ -12,16 +12,22 @@ Unless required by applicable law or agreed to in writing, software
 
 use std::collections::HashMap;
 use std::fs;
+use std::sync::atomic::{AtomicBool, Ordering};
 use std::sync::{Arc, Mutex};
+use std::time::Duration;
 
 use tokio::sync::mpsc;
+use tokio::time::{interval, Instant};
 
-use common::apis::ExecutorState;
-use common::{ctx::FlameClusterContext, FlameError};
+use common::apis::{ExecutorState, Node, LABEL_TAGS};
+use common::{clock, ctx::FlameClusterContext, FlameError};
 use stdng::{lock_ptr, MutexPtr};
 
 use crate::client::BackendClient;
+use crate::cloud;
 use crate::executor::{self, Executor, ExecutorPtr};
+use crate::logs::{self, LogForwarderPtr};
+use crate::restarts::RestartBudget;
 use crate::stream_handler::StreamHandler;
 
 /// Messages sent from StreamHandler to ExecutorManager
@@ -30,10 +36,15 @@ pub enum ExecutorMessage {
     Update(Executor),
 }
 
+/// Interval to check whether all executors were released when draining.
+const DRAIN_CHECK_INTERVAL_MILLIS: u64 = 500;
+
 pub struct ExecutorManager {
     ctx: FlameClusterContext,
     executors: MutexPtr<HashMap<String, ExecutorPtr>>,
     client: BackendClient,
+    draining: Arc<AtomicBool>,
+    logs: Option<LogForwarderPtr>,
 }
 
 impl ExecutorManager {
@@ -43,11 +54,14 @@ impl ExecutorManager {
             .map_err(|e| FlameError::Internal(format!("failed to create shim directory: {e}")))?;
 
         let client = BackendClient::new(ctx).await?;
+        let logs = logs::new_ptr(&ctx.cluster.executors.logs).await?;
 
         Ok(Self {
             ctx: ctx.clone(),
             executors: Arc::new(Mutex::new(HashMap::new())),
             client,
+            draining: Arc::new(AtomicBool::new(false)),
+            logs,
         })
     }
 
@@ -57,6 +71,8 @@ impl ExecutorManager {
     /// 1. StreamHandler calls RegisterNode on each connection (handles failover)
     /// 2. StreamHandler starts WatchNode stream to receive executor updates
     /// 3. Process executor messages and maintain local state
+    /// 4. On SIGTERM, drain the executors within the grace period, and then
+    ///    release the node
     pub async fn run(&mut self) -> Result<(), FlameError> {
         // Create channel for executor messages
         let (executor_tx, mut executor_rx) = mpsc::channel::<ExecutorMessage>(32);
@@ -66,11 +82,26 @@ impl ExecutorManager {
 
         // Share executors reference with StreamHandler for re-registration
         let executors_for_handler = self.executors.clone();
+        let draining = self.draining.clone();
+        let chaos = self.ctx.cluster.chaos.clone();
+
+        // Label the node by the cloud metadata before it's registered
+        let mut labels = cloud::probe(self.ctx.cluster.executors.cloud_metadata).await;
+        // Advertise the tags of the executors, so the tagged tasks are routed
+        // to the node.
+        if !self.ctx.cluster.executors.tags.is_empty() {
+            labels.insert(
+                LABEL_TAGS.to_string(),
+                self.ctx.cluster.executors.tags.join(","),
+            );
+        }
 
         // Spawn the stream handler (long-running, self-recovering task)
         // StreamHandler handles register_node + watch_node on each connection
         let stream_handle = tokio::spawn(async move {
-            let mut handler = StreamHandler::new(client, executors_for_handler);
+            let mut handler = StreamHandler::new(client, executors_for_handler, draining)
+                .with_labels(labels)
+                .with_chaos(chaos);
             handler.run(executor_tx).await;
         });
 
@@ -79,21 +110,94 @@ impl ExecutorManager {
             self.ctx.cluster.executors.shim
         );
 
+        let shutdown = shutdown_signal();
+        tokio::pin!(shutdown);
+
+        let mut drain_deadline: Option<Instant> = None;
+        let mut drain_ticker = interval(Duration::from_millis(DRAIN_CHECK_INTERVAL_MILLIS));
+
         // Process executor messages from the stream
-        while let Some(msg) = executor_rx.recv().await {
-            match msg {
-                ExecutorMessage::Update(executor) => {
-                    self.handle_executor_update(executor)?;
+        loop {
+            tokio::select! {
+                msg = executor_rx.recv() => match msg {
+                    Some(ExecutorMessage::Update(executor)) => {
+                        self.handle_executor_update(executor)?;
+                    }
+                    None => break,
+                },
+                _ = &mut shutdown, if drain_deadline.is_none() => {
+                    let grace_period =
+                        Duration::from_secs(self.ctx.cluster.executors.grace_period);
+                    tracing::info!(
+                        "Received shutdown signal, draining executors in {:?}",
+                        grace_period
+                    );
+                    self.drain()?;
+                    drain_deadline = Some(Instant::now() + grace_period);
+                }
+                _ = drain_ticker.tick(), if drain_deadline.is_some() => {
+                    let remaining = self.remaining_executors()?;
+                    if remaining.is_empty() {
+                        tracing::info!("All executors were released.");
+                        break;
+                    }
+                    if drain_deadline.is_some_and(|d| Instant::now() >= d) {
+                        tracing::warn!(
+                            "Grace period exceeded, {} executors are not released: {:?}",
+                            remaining.len(),
+                            remaining
+                        );
+                        break;
+                    }
                 }
             }
         }
 
-        // Wait for stream handler to finish
-        let _ = stream_handle.await;
+        if drain_deadline.is_none() {
+            // Wait for stream handler to finish
+            let _ = stream_handle.await;
+            return Ok(());
+        }
+
+        // Release the node, so the leftover executors are cleaned up by
+        // the session manager.
+        stream_handle.abort();
+        self.client.release_node(&Node::new()).await?;
+        tracing::info!("Node was released.");
 
         Ok(())
     }
 
+    /// Marks the node and all executors as draining: the idle executors are
+    /// released, and the bound executors are unbound and released after
+    /// their in-flight tasks.
+    fn drain(&self) -> Result<(), FlameError> {
+        self.draining.store(true, Ordering::Relaxed);
+
+        let executors = lock_ptr!(self.executors)?;
+        for executor in executors.values() {
+            let mut executor = lock_ptr!(executor)?;
+            executor.draining = true;
+        }
+
+        Ok(())
+    }
+
+    /// Returns the IDs of the executors which are not released yet.
+    fn remaining_executors(&self) -> Result<Vec<String>, FlameError> {
+        let executors = lock_ptr!(self.executors)?;
+
+        let mut remaining = vec![];
+        for (id, executor) in executors.iter() {
+            let executor = lock_ptr!(executor)?;
+            if executor.state != ExecutorState::Released {
+                remaining.push(id.clone());
+            }
+        }
+
+        Ok(remaining)
+    }
+
     /// Handles an executor update by deriving and executing the appropriate action.
     ///
     /// Action derivation logic:
@@ -128,6 +232,16 @@ impl ExecutorManager {
             executor.context = Some(self.ctx.clone());
             // Set the shim from the executor-manager's configuration
             executor.shim = self.ctx.cluster.executors.shim;
+            // The executors created during draining are released directly.
+            executor.draining = self.draining.load(Ordering::Relaxed);
+            executor.logs = self.logs.clone();
+            executor.restarts = self
+                .ctx
+                .cluster
+                .executors
+                .restarts
+                .as_ref()
+                .map(|restarts| RestartBudget::new(restarts, clock::system()));
 
             let executor_ptr = Arc::new(Mutex::new(executor));
             executors.insert(executor_id.clone(), executor_ptr.clone());
@@ -150,6 +264,24 @@ impl ExecutorManager {
     }
 }
 
+/// Waits for SIGTERM (e.g. the termination of Kubernetes pod) or Ctrl-C.
+async fn shutdown_signal() {
+    use tokio::signal::unix::{signal, SignalKind};
+
+    match signal(SignalKind::terminate()) {
+        Ok(mut sigterm) => {
+            tokio::select! {
+                _ = sigterm.recv() => {},
+                _ = tokio::signal::ctrl_c() => {},
+            }
+        }
+        Err(e) => {
+            tracing::warn!("Failed to listen for SIGTERM: {e}");
+            let _ = tokio::signal::ctrl_c().await;
+        }
+    }
+}
+
 pub async fn run(ctx: &FlameClusterContext) -> Result<(), FlameError> {
     let mut manager = ExecutorManager::new(ctx).await?;
     manager.run().await?;
@@ -0,0 +1,220 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//! The restart budget of the services on an executor: a crash of the service,
+//! i.e. it failed to start, to enter the session or during a task, or it was
+//! unhealthy, is counted for its application, and the application is
+//! excluded from the bindings of the executor for an exponential backoff.
+//! Once the consecutive crashes reach the budget, the application is
+//! crash-looping on the executor: it's excluded until the max backoff since
+//! its last crash, and then given another try.
+
+use std::collections::HashMap;
+use std::time::{Duration, Instant};
+
+use chrono::{DateTime, Utc};
+use stdng::{new_ptr, MutexPtr};
+
+use common::apis::SessionID;
+use common::clock::ClockPtr;
+use common::ctx::FlameRestarts;
+
+pub type RestartBudgetPtr = MutexPtr<RestartBudget>;
+
+/// The consecutive crashes of an application.
+struct Crashes {
+    count: u32,
+    last: Instant,
+    session_id: SessionID,
+}
+
+/// The application excluded from the bindings of the executor; the session
+/// manager compares the end of the exclusion with its own clock, so the
+/// exclusion expires without another request of the executor.
+#[derive(Clone, Debug, PartialEq)]
+pub struct Exclusion {
+    pub application: String,
+    pub until: DateTime<Utc>,
+    pub crash_looping: bool,
+    /// The session whose service crashed last.
+    pub session_id: SessionID,
+}
+
+/// The crashes of the services of the applications on an executor.
+pub struct RestartBudget {
+    budget: u32,
+    backoff: Duration,
+    max_backoff: Duration,
+    crashes: HashMap<String, Crashes>,
+    clock: ClockPtr,
+}
+
+impl RestartBudget {
+    pub fn new(config: &FlameRestarts, clock: ClockPtr) -> RestartBudgetPtr {
+        new_ptr(Self {
+            budget: config.budget,
+            backoff: Duration::from_secs(config.backoff),
+            max_backoff: Duration::from_secs(config.max_backoff),
+            crashes: HashMap::new(),
+            clock,
+        })
+    }
+
+    /// Count a crash of the service of the application in the session;
+    /// return whether the application is crash-looping by it.
+    pub fn crash(&mut self, app: &str, session_id: &str) -> bool {
+        let now = self.clock.now();
+        let crashes = self.crashes.entry(app.to_string()).or_insert(Crashes {
+            count: 0,
+            last: now,
+            session_id: session_id.to_string(),
+        });
+        crashes.count += 1;
+        crashes.last = now;
+        crashes.session_id = session_id.to_string();
+
+        crashes.count == self.budget
+    }
+
+    /// The service of the application served its session, so its crashes
+    /// are forgotten.
+    pub fn reset(&mut self, app: &str) {
+        self.crashes.remove(app);
+    }
+
+    /// The time to wait before binding the application again, by the
+    /// backoff of its consecutive crashes.
+    pub fn backoff(&self, app: &str) -> Duration {
+        let Some(crashes) = self.crashes.get(app) else {
+            return Duration::ZERO;
+        };
+        let backoff = match crashes.count >= self.budget {
+            true => self.max_backoff,
+            false => self
+                .backoff
+                .saturating_mul(1u32 << (crashes.count - 1).min(16))
+                .min(self.max_backoff),
+        };
+
+        backoff.saturating_sub(self.clock.now().saturating_duration_since(crashes.last))
+    }
+
+    /// The applications backing off or crash-looping on the executor, which
+    /// are not bound to it until the end of their backoff.
+    pub fn excluded(&self) -> Vec<Exclusion> {
+        let now = self.clock.utc_now();
+        let mut apps: Vec<Exclusion> = self
+            .crashes
+            .iter()
+            .filter_map(|(app, crashes)| {
+                let backoff = self.backoff(app);
+                if backoff.is_zero() {
+                    return None;
+                }
+
+                Some(Exclusion {
+                    application: app.clone(),
+                    until: now + chrono::Duration::from_std(backoff).unwrap_or_default(),
+                    crash_looping: crashes.count >= self.budget,
+                    session_id: crashes.session_id.clone(),
+                })
+            })
+            .collect();
+        apps.sort_by(|a, b| a.application.cmp(&b.application));
+
+        apps
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use stdng::lock_ptr;
+
+    use common::clock::{Clock, VirtualClock};
+
+    use super::*;
+
+    fn excluded(budget: &RestartBudget) -> Vec<String> {
+        budget
+            .excluded()
+            .into_iter()
+            .map(|e| e.application)
+            .collect()
+    }
+
+    #[test]
+    fn test_restart_budget() {
+        let clock = VirtualClock::new_ptr();
+        let budget = RestartBudget::new(
+            &FlameRestarts {
+                budget: 3,
+                backoff: 10,
+                max_backoff: 30,
+            },
+            clock.clone(),
+        );
+        let mut budget = lock_ptr!(budget).unwrap();
+        assert_eq!(budget.backoff("pi"), Duration::ZERO);
+
+        // The backoff is doubled by each crash up to the max backoff.
+        assert!(!budget.crash("pi", "ssn-1"));
+        assert_eq!(budget.backoff("pi"), Duration::from_secs(10));
+        assert_eq!(excluded(&budget), vec!["pi"]);
+        clock.advance(Duration::from_secs(10));
+        assert!(budget.excluded().is_empty());
+
+        assert!(!budget.crash("pi", "ssn-1"));
+        assert_eq!(budget.backoff("pi"), Duration::from_secs(20));
+        clock.advance(Duration::from_secs(5));
+        assert_eq!(budget.backoff("pi"), Duration::from_secs(15));
+
+        // The crash-looping application is excluded for the max backoff.
+        assert!(budget.crash("pi", "ssn-2"));
+        assert_eq!(budget.backoff("pi"), Duration::from_secs(30));
+        assert_eq!(
+            budget.excluded(),
+            vec![Exclusion {
+                application: "pi".to_string(),
+                until: clock.utc_now() + chrono::Duration::seconds(30),
+                crash_looping: true,
+                session_id: "ssn-2".to_string(),
+            }]
+        );
+        assert_eq!(budget.backoff("matrix"), Duration::ZERO);
+
+        budget.reset("pi");
+        assert!(budget.excluded().is_empty());
+        assert_eq!(budget.backoff("pi"), Duration::ZERO);
+    }
+
+    #[test]
+    fn test_crash_looping_expired() {
+        let clock = VirtualClock::new_ptr();
+        let budget = RestartBudget::new(
+            &FlameRestarts {
+                budget: 1,
+                backoff: 10,
+                max_backoff: 60,
+            },
+            clock.clone(),
+        );
+        let mut budget = lock_ptr!(budget).unwrap();
+
+        // The application is tried again after the max backoff.
+        assert!(budget.crash("pi", "ssn-1"));
+        assert_eq!(excluded(&budget), vec!["pi"]);
+        clock.advance(Duration::from_secs(60));
+        assert!(budget.excluded().is_empty());
+        assert_eq!(budget.backoff("pi"), Duration::ZERO);
+    }
+}
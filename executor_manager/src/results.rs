@@ -0,0 +1,253 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//! The task outputs kept on the host of the executor in local mode, so the
+//! clients on the same host read them from the file instead of streaming the
+//! bytes from the session manager. Directory structure:
+//!   <root>/<session_id>/<task_id>
+//! The session manager still gets the output, as the system of record, with
+//! the reference `file://<host>/<path>`; the file is only a fast path, and the
+//! directories of the sessions are removed after the retention.
+
+use std::fs;
+use std::io::Write;
+use std::os::unix::fs::{DirBuilderExt, OpenOptionsExt};
+use std::path::{Component, Path, PathBuf};
+use std::time::{Duration, SystemTime};
+
+use common::apis::TaskResult;
+use common::ctx::FlameLocalResults;
+use common::FlameError;
+
+/// Keep a copy of the output of the task on the host if it's large enough and
+/// the client of the session is on the same host, and refer to it in the
+/// result; the output itself is always sent to the session manager.
+pub fn offload(
+    conf: &FlameLocalResults,
+    client_host: Option<&str>,
+    session_id: &str,
+    task_id: &str,
+    result: TaskResult,
+) -> TaskResult {
+    let Some(output) = result.output.as_ref() else {
+        return result;
+    };
+    if (output.len() as u64) < conf.min_size {
+        return result;
+    }
+
+    // The other hosts can't read the output by the reference.
+    let hostname = gethostname::gethostname().to_string_lossy().into_owned();
+    if client_host != Some(hostname.as_str()) {
+        return result;
+    }
+
+    // The ids are the names of the directory and the file under the root.
+    if !is_file_name(session_id) || !is_file_name(task_id) {
+        tracing::warn!(
+            "Failed to keep the output of task <{session_id}/{task_id}>: invalid file name"
+        );
+        return result;
+    }
+
+    match write_output(&Path::new(&conf.root).join(session_id), task_id, output) {
+        Ok(path) => {
+            tracing::debug!(
+                "The output of task <{session_id}/{task_id}> is kept in <{}>.",
+                path.display()
+            );
+            TaskResult {
+                output_ref: Some(format!("file://{hostname}{}", path.display())),
+                ..result
+            }
+        }
+        Err(e) => {
+            tracing::warn!("Failed to keep the output of task <{session_id}/{task_id}>: {e}");
+            result
+        }
+    }
+}
+
+/// Whether the name is a single normal component of a path, e.g. not `..` or
+/// `a/b`.
+fn is_file_name(name: &str) -> bool {
+    let mut components = Path::new(name).components();
+    matches!(
+        (components.next(), components.next()),
+        (Some(Component::Normal(_)), None)
+    )
+}
+
+/// Write the output to a temporary file and rename it, so the readers never
+/// see a partial output; the output is only readable by the user of the
+/// executor.
+fn write_output(dir: &Path, task_id: &str, output: &[u8]) -> Result<PathBuf, FlameError> {
+    fs::DirBuilder::new()
+        .recursive(true)
+        .mode(0o700)
+        .create(dir)
+        .map_err(|e| {
+            FlameError::Internal(format!(
+                "failed to create results directory {}: {e}",
+                dir.display()
+            ))
+        })?;
+
+    let path = dir.join(task_id);
+    let tmp = dir.join(format!(".{task_id}.tmp"));
+    fs::OpenOptions::new()
+        .write(true)
+        .create(true)
+        .truncate(true)
+        .mode(0o600)
+        .open(&tmp)
+        .and_then(|mut file| file.write_all(output))
+        .and_then(|_| fs::rename(&tmp, &path))
+        .map_err(|e| {
+            let _ = fs::remove_file(&tmp);
+            FlameError::Internal(format!("failed to write {}: {e}", path.display()))
+        })?;
+
+    // The reference must be absolute for the readers.
+    path.canonicalize()
+        .map_err(|e| FlameError::Internal(format!("failed to resolve {}: {e}", path.display())))
+}
+
+/// Remove the directories of the sessions which are not written within the
+/// retention.
+pub fn sweep(conf: &FlameLocalResults) {
+    let Ok(entries) = fs::read_dir(&conf.root) else {
+        return;
+    };
+    let retention = Duration::from_secs(conf.retention);
+
+    for entry in entries.flatten() {
+        let expired = entry
+            .metadata()
+            .and_then(|m| m.modified())
+            .ok()
+            .and_then(|modified| SystemTime::now().duration_since(modified).ok())
+            .is_some_and(|age| age > retention);
+
+        if expired {
+            tracing::debug!("Remove the expired results <{}>.", entry.path().display());
+            if let Err(e) = fs::remove_dir_all(entry.path()) {
+                tracing::warn!(
+                    "Failed to remove the results <{}>: {e}",
+                    entry.path().display()
+                );
+            }
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use std::os::unix::fs::PermissionsExt;
+
+    use super::*;
+    use common::apis::{TaskOutput, TaskState};
+    use tempfile::tempdir;
+
+    fn hostname() -> String {
+        gethostname::gethostname().to_string_lossy().into_owned()
+    }
+
+    fn results_conf(root: &Path, min_size: u64) -> FlameLocalResults {
+        FlameLocalResults {
+            root: root.to_string_lossy().to_string(),
+            min_size,
+            retention: 0,
+        }
+    }
+
+    fn task_result(output: &str) -> TaskResult {
+        TaskResult {
+            state: TaskState::Succeed,
+            output: Some(TaskOutput::from(output.to_string())),
+            message: None,
+            output_ref: None,
+        }
+    }
+
+    #[test]
+    fn test_offload_output() {
+        let temp = tempdir().unwrap();
+        let conf = results_conf(temp.path(), 4);
+
+        let host = hostname();
+        let client_host = Some(host.as_str());
+
+        // The small output is only sent to the session manager.
+        let result = offload(&conf, client_host, "ssn-1", "1", task_result("abc"));
+        assert!(result.output.is_some());
+        assert!(result.output_ref.is_none());
+
+        let result = offload(
+            &conf,
+            client_host,
+            "ssn-1",
+            "2",
+            task_result("large output"),
+        );
+        // The large output is sent to the session manager too.
+        assert_eq!(result.output.as_deref(), Some("large output".as_bytes()));
+        let output_ref = result.output_ref.unwrap();
+        assert!(output_ref.starts_with("file://"));
+
+        let path = temp.path().join("ssn-1").join("2");
+        assert!(output_ref.ends_with(&path.canonicalize().unwrap().to_string_lossy().to_string()));
+        assert_eq!(fs::read_to_string(&path).unwrap(), "large output");
+        let mode = fs::metadata(&path).unwrap().permissions().mode();
+        assert_eq!(mode & 0o777, 0o600);
+
+        // The directories of sessions are removed after the retention.
+        std::thread::sleep(Duration::from_millis(10));
+        sweep(&conf);
+        assert!(!temp.path().join("ssn-1").exists());
+    }
+
+    #[test]
+    fn test_offload_output_rejected() {
+        let temp = tempdir().unwrap();
+        let conf = results_conf(temp.path(), 4);
+        let host = hostname();
+
+        // The client on another host, or unknown, can't read the file.
+        for client_host in [None, Some("another-host")] {
+            let result = offload(
+                &conf,
+                client_host,
+                "ssn-1",
+                "1",
+                task_result("large output"),
+            );
+            assert!(result.output.is_some());
+            assert!(result.output_ref.is_none());
+        }
+
+        // The ids out of the root are never written.
+        for (session_id, task_id) in [("..", "1"), ("a/b", "1"), ("/tmp", "1"), ("ssn-1", "../1")] {
+            let result = offload(
+                &conf,
+                Some(host.as_str()),
+                session_id,
+                task_id,
+                task_result("large output"),
+            );
+            assert!(result.output.is_some());
+            assert!(result.output_ref.is_none());
+        }
+        assert_eq!(fs::read_dir(temp.path()).unwrap().count(), 0);
+    }
+}
@@ -0,0 +1,264 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+use std::fs;
+use std::path::{Path, PathBuf};
+use std::sync::{Arc, Mutex};
+
+use stdng::lock_ptr;
+
+use common::ctx::FlameScratch;
+use common::FlameError;
+
+pub type ScratchDirPtr = Arc<ScratchDir>;
+
+/// The ephemeral scratch directory of the session bound to an executor.
+/// Directory structure:
+///   <root>/<executor_id>/<session_id>/
+/// The directory is created when the executor binds to a session, and is
+/// removed when the executor unbinds from it. If `tmpfs` is enabled, the
+/// directory is a tmpfs mount whose size is limited by the kernel; otherwise
+/// the usage is checked after each task.
+pub struct ScratchDir {
+    path: PathBuf,
+    executor_dir: PathBuf,
+    size_limit: Option<u64>,
+    mounted: bool,
+    released: Mutex<bool>,
+}
+
+impl ScratchDir {
+    pub fn new(
+        conf: &FlameScratch,
+        executor_id: &str,
+        session_id: &str,
+    ) -> Result<ScratchDirPtr, FlameError> {
+        let executor_dir = Path::new(&conf.root).join(executor_id);
+        let path = executor_dir.join(session_id);
+
+        fs::create_dir_all(&path).map_err(|e| {
+            FlameError::Internal(format!(
+                "failed to create scratch directory {}: {e}",
+                path.display()
+            ))
+        })?;
+
+        let mounted = if conf.tmpfs {
+            mount_tmpfs(&path, conf.size_limit)?;
+            true
+        } else {
+            false
+        };
+
+        tracing::debug!(
+            "Created scratch directory <{}> (tmpfs: {mounted}, size limit: {:?})",
+            path.display(),
+            conf.size_limit
+        );
+
+        Ok(Arc::new(Self {
+            path,
+            executor_dir,
+            size_limit: conf.size_limit,
+            mounted,
+            released: Mutex::new(false),
+        }))
+    }
+
+    pub fn path(&self) -> &Path {
+        &self.path
+    }
+
+    /// Check the usage of the scratch directory against the size limit. The
+    /// tmpfs mount is limited by the kernel, so it's always passed.
+    pub fn check_usage(&self) -> Result<(), FlameError> {
+        let Some(limit) = self.size_limit else {
+            return Ok(());
+        };
+        if self.mounted {
+            return Ok(());
+        }
+
+        let usage = dir_size(&self.path);
+        if usage > limit {
+            return Err(FlameError::InvalidState(format!(
+                "scratch directory <{}> uses {usage} bytes, exceeds the limit {limit} bytes",
+                self.path.display()
+            )));
+        }
+
+        Ok(())
+    }
+
+    /// Unmount and remove the scratch directory; it's safe to call it more
+    /// than once.
+    pub fn release(&self) {
+        let Ok(mut released) = lock_ptr!(self.released) else {
+            return;
+        };
+        if *released {
+            return;
+        }
+        *released = true;
+
+        if self.mounted {
+            if let Err(e) = umount_tmpfs(&self.path) {
+                tracing::warn!("Failed to unmount scratch directory: {e}");
+            }
+        }
+
+        if self.path.exists() {
+            if let Err(e) = fs::remove_dir_all(&self.path) {
+                tracing::warn!(
+                    "Failed to remove scratch directory {}: {}",
+                    self.path.display(),
+                    e
+                );
+            } else {
+                tracing::debug!("Removed scratch directory: {}", self.path.display());
+            }
+        }
+        // The directory of the executor is removed once it has no session;
+        // it fails if another session of the executor is still there.
+        let _ = fs::remove_dir(&self.executor_dir);
+    }
+}
+
+impl Drop for ScratchDir {
+    fn drop(&mut self) {
+        self.release();
+    }
+}
+
+fn dir_size(path: &Path) -> u64 {
+    let Ok(entries) = fs::read_dir(path) else {
+        return 0;
+    };
+
+    entries
+        .flatten()
+        .map(|entry| match entry.metadata() {
+            Ok(meta) if meta.is_dir() => dir_size(&entry.path()),
+            Ok(meta) => meta.len(),
+            Err(_) => 0,
+        })
+        .sum()
+}
+
+#[cfg(target_os = "linux")]
+fn mount_tmpfs(path: &Path, size_limit: Option<u64>) -> Result<(), FlameError> {
+    use nix::mount::{mount, MsFlags};
+
+    let mut opts = "mode=0700".to_string();
+    if let Some(size) = size_limit {
+        opts.push_str(&format!(",size={size}"));
+    }
+
+    mount(
+        Some("tmpfs"),
+        path,
+        Some("tmpfs"),
+        MsFlags::MS_NOSUID | MsFlags::MS_NODEV,
+        Some(opts.as_str()),
+    )
+    .map_err(|e| FlameError::Internal(format!("failed to mount tmpfs at {}: {e}", path.display())))
+}
+
+#[cfg(not(target_os = "linux"))]
+fn mount_tmpfs(_: &Path, _: Option<u64>) -> Result<(), FlameError> {
+    Err(FlameError::InvalidConfig(
+        "tmpfs scratch directory is only supported on Linux".to_string(),
+    ))
+}
+
+#[cfg(target_os = "linux")]
+fn umount_tmpfs(path: &Path) -> Result<(), FlameError> {
+    use nix::mount::{umount2, MntFlags};
+
+    umount2(path, MntFlags::MNT_DETACH).map_err(|e| {
+        FlameError::Internal(format!(
+            "failed to unmount tmpfs at {}: {e}",
+            path.display()
+        ))
+    })
+}
+
+#[cfg(not(target_os = "linux"))]
+fn umount_tmpfs(_: &Path) -> Result<(), FlameError> {
+    Ok(())
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use tempfile::tempdir;
+
+    fn scratch_conf(root: &Path, size_limit: Option<u64>) -> FlameScratch {
+        FlameScratch {
+            root: root.to_string_lossy().to_string(),
+            size_limit,
+            tmpfs: false,
+        }
+    }
+
+    #[test]
+    fn test_scratch_dir_lifecycle() {
+        let temp = tempdir().unwrap();
+        let conf = scratch_conf(temp.path(), None);
+
+        let scratch = ScratchDir::new(&conf, "exec-1", "ssn-1").unwrap();
+        let path = scratch.path().to_path_buf();
+        assert_eq!(path, temp.path().join("exec-1").join("ssn-1"));
+        assert!(path.exists());
+
+        fs::write(path.join("data"), b"hello").unwrap();
+        scratch.release();
+        assert!(!temp.path().join("exec-1").exists());
+
+        // Release is idempotent, including the one in drop.
+        scratch.release();
+        drop(scratch);
+    }
+
+    #[test]
+    fn test_scratch_dir_release_keeps_other_sessions() {
+        let temp = tempdir().unwrap();
+        let conf = scratch_conf(temp.path(), None);
+
+        let previous = ScratchDir::new(&conf, "exec-1", "ssn-1").unwrap();
+        let next = ScratchDir::new(&conf, "exec-1", "ssn-2").unwrap();
+        fs::write(next.path().join("data"), b"hello").unwrap();
+
+        // Only the directory of the released session is removed.
+        previous.release();
+        assert!(!previous.path().exists());
+        assert!(next.path().join("data").exists());
+
+        next.release();
+        assert!(!temp.path().join("exec-1").exists());
+    }
+
+    #[test]
+    fn test_scratch_dir_size_limit() {
+        let temp = tempdir().unwrap();
+        let conf = scratch_conf(temp.path(), Some(8));
+
+        let scratch = ScratchDir::new(&conf, "exec-2", "ssn-2").unwrap();
+        fs::write(scratch.path().join("small"), b"1234").unwrap();
+        assert!(scratch.check_usage().is_ok());
+
+        fs::create_dir_all(scratch.path().join("nested")).unwrap();
+        fs::write(scratch.path().join("nested").join("large"), b"123456789").unwrap();
+        assert!(scratch.check_usage().is_err());
+    }
+}
@@ -0,0 +1,538 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//! The egress policy of the service process, so the user code only reaches
+//! the destinations declared by its application.
+//!
+//! The destinations are declared by the environments of the application:
+//!   FLAME_EGRESS_ALLOW - the destinations separated by commas, e.g.
+//!                        "api.example.com:443,10.0.0.0/8,[fd00::1]:5432"
+//!
+//! A destination is a host with a port, or an address or a network with an
+//! optional port; the ports are of both TCP and UDP, e.g. the DNS servers are
+//! declared as "10.0.0.2:53". The egress of the service is not restricted if
+//! no destination is declared.
+//!
+//! The service is started in the cgroup `flame/<executor_id>`, and the
+//! nftables table `flame_<executor_id>` rejects the connections of the cgroup
+//! except to the destinations, the loopback and the endpoints of the session
+//! manager and the cache. The hosts are resolved once when the service is
+//! started. It needs the cgroup v2 and `nft` on Linux, with the privileges to
+//! manage them; the service is not started if the policy can't be enforced.
+//!
+//! The service is run by `setpriv` as an unprivileged user without any
+//! capability, so it can't leave its cgroup or change the rules; the user is
+//! set by the environments of the executor manager:
+//!   FLAME_SERVICE_UID - the uid of the service, 65534 (nobody) by default
+//!   FLAME_SERVICE_GID - the gid of the service, 65534 (nogroup) by default
+//!
+//! The service owns its application and cache directories, and writes its
+//! socket in the socket directory by the group of the directory.
+
+use std::collections::HashMap;
+use std::fmt::Write as _;
+use std::io::Write;
+use std::net::{IpAddr, ToSocketAddrs};
+use std::os::unix::fs::PermissionsExt;
+use std::path::{Path, PathBuf};
+use std::process::{Command, Stdio};
+
+use common::FlameError;
+
+const FLAME_EGRESS_ALLOW: &str = "FLAME_EGRESS_ALLOW";
+const CGROUP_ROOT: &str = "/sys/fs/cgroup";
+const CGROUP_PARENT: &str = "flame";
+const FLAME_SERVICE_UID: &str = "FLAME_SERVICE_UID";
+const FLAME_SERVICE_GID: &str = "FLAME_SERVICE_GID";
+/// The `nobody` user and the `nogroup` group.
+const DEFAULT_SERVICE_ID: u32 = 65534;
+
+/// A destination declared by the application.
+#[derive(Clone, Debug, PartialEq)]
+enum Destination {
+    Host { host: String, port: u16 },
+    Net { rule: EgressRule },
+}
+
+/// The egress allowed to a network, on all ports if no port.
+#[derive(Clone, Debug, PartialEq)]
+pub struct EgressRule {
+    addr: IpAddr,
+    prefix: u8,
+    port: Option<u16>,
+}
+
+impl EgressRule {
+    fn render(&self) -> String {
+        let family = match self.addr {
+            IpAddr::V4(_) => "ip",
+            IpAddr::V6(_) => "ip6",
+        };
+        let mut rule = format!("{family} daddr {}/{}", self.addr, self.prefix);
+        if let Some(port) = self.port {
+            let _ = write!(rule, " meta l4proto {{ tcp, udp }} th dport {port}");
+        }
+        rule.push_str(" accept");
+
+        rule
+    }
+}
+
+#[derive(Clone, Debug, Default, PartialEq)]
+pub struct EgressPolicy {
+    destinations: Vec<Destination>,
+}
+
+impl EgressPolicy {
+    /// Build the policy from the environments of the application; None if
+    /// the application does not declare the egress.
+    pub fn from_envs(envs: &HashMap<String, String>) -> Result<Option<Self>, FlameError> {
+        let Some(allow) = envs.get(FLAME_EGRESS_ALLOW) else {
+            return Ok(None);
+        };
+
+        let destinations = allow
+            .split(',')
+            .map(str::trim)
+            .filter(|d| !d.is_empty())
+            .map(parse_destination)
+            .collect::<Result<Vec<_>, _>>()?;
+
+        Ok(Some(Self { destinations }))
+    }
+
+    /// Allow the endpoint of the cluster, e.g. the session manager for the
+    /// recursive calls of the service.
+    pub fn allow_endpoint(&mut self, endpoint: &str) {
+        let Ok(url) = url::Url::parse(endpoint) else {
+            return;
+        };
+        if let (Some(host), Some(port)) = (url.host_str(), url.port_or_known_default()) {
+            let host = host.trim_start_matches('[').trim_end_matches(']');
+            self.destinations.push(match host.parse::<IpAddr>() {
+                Ok(addr) => Destination::Net {
+                    rule: EgressRule {
+                        addr,
+                        prefix: max_prefix(&addr),
+                        port: Some(port),
+                    },
+                },
+                Err(_) => Destination::Host {
+                    host: host.to_string(),
+                    port,
+                },
+            });
+        }
+    }
+
+    /// The rules of the destinations, with the hosts resolved.
+    pub fn resolve(&self) -> Result<Vec<EgressRule>, FlameError> {
+        let mut rules = vec![];
+        for dest in &self.destinations {
+            match dest {
+                Destination::Net { rule } => rules.push(rule.clone()),
+                Destination::Host { host, port } => {
+                    let addrs = (host.as_str(), *port).to_socket_addrs().map_err(|e| {
+                        FlameError::InvalidConfig(format!(
+                            "failed to resolve egress destination <{host}>: {e}"
+                        ))
+                    })?;
+                    for addr in addrs {
+                        let rule = EgressRule {
+                            addr: addr.ip(),
+                            prefix: max_prefix(&addr.ip()),
+                            port: Some(*port),
+                        };
+                        if !rules.contains(&rule) {
+                            rules.push(rule);
+                        }
+                    }
+                }
+            }
+        }
+
+        Ok(rules)
+    }
+}
+
+fn max_prefix(addr: &IpAddr) -> u8 {
+    match addr {
+        IpAddr::V4(_) => 32,
+        IpAddr::V6(_) => 128,
+    }
+}
+
+/// The network of the address, i.e. without the bits of the host.
+fn network(addr: IpAddr, prefix: u8) -> IpAddr {
+    match addr {
+        IpAddr::V4(v4) => {
+            let mask = u32::MAX.checked_shl(32 - prefix as u32).unwrap_or(0);
+            IpAddr::from(u32::from(v4) & mask)
+        }
+        IpAddr::V6(v6) => {
+            let mask = u128::MAX.checked_shl(128 - prefix as u32).unwrap_or(0);
+            IpAddr::from(u128::from(v6) & mask)
+        }
+    }
+}
+
+fn parse_destination(dest: &str) -> Result<Destination, FlameError> {
+    let invalid = || FlameError::InvalidConfig(format!("invalid egress destination <{dest}>"));
+    let parse_port = |port: &str| port.parse::<u16>().map_err(|_| invalid());
+
+    // The IPv6 address with a port is in brackets, e.g. "[fd00::1]:443".
+    let (addr, port) = if let Some(rest) = dest.strip_prefix('[') {
+        let (addr, port) = rest.split_once("]:").ok_or_else(invalid)?;
+        (addr, Some(parse_port(port)?))
+    } else if dest.matches(':').count() > 1 {
+        (dest, None)
+    } else {
+        match dest.split_once(':') {
+            Some((addr, port)) => (addr, Some(parse_port(port)?)),
+            None => (dest, None),
+        }
+    };
+
+    let (ip, prefix) = match addr.split_once('/') {
+        Some((ip, prefix)) => (ip, Some(prefix.parse::<u8>().map_err(|_| invalid())?)),
+        None => (addr, None),
+    };
+    match ip.parse::<IpAddr>() {
+        Ok(ip) => {
+            let prefix = prefix.unwrap_or(max_prefix(&ip));
+            if prefix > max_prefix(&ip) {
+                return Err(invalid());
+            }
+            Ok(Destination::Net {
+                rule: EgressRule {
+                    addr: network(ip, prefix),
+                    prefix,
+                    port,
+                },
+            })
+        }
+        // The hosts are resolved to the addresses, which need a port.
+        Err(_) => match (prefix, port) {
+            (None, Some(port)) if !ip.is_empty() => Ok(Destination::Host {
+                host: ip.to_string(),
+                port,
+            }),
+            _ => Err(invalid()),
+        },
+    }
+}
+
+fn service_id(name: &str) -> Result<u32, FlameError> {
+    match std::env::var(name) {
+        Ok(id) => id
+            .parse::<u32>()
+            .map_err(|_| FlameError::InvalidConfig(format!("invalid {name} <{id}>"))),
+        Err(_) => Ok(DEFAULT_SERVICE_ID),
+    }
+}
+
+/// The cgroup and the nftables table which enforce the egress policy of a
+/// service; they're removed on drop.
+pub struct EgressGuard {
+    table: String,
+    cgroup: String,
+    uid: u32,
+    gid: u32,
+}
+
+impl EgressGuard {
+    /// The names of the table and the cgroup are of the sanitized id, so the
+    /// id can't reach the other cgroups, e.g. by "..".
+    fn new(executor_id: &str, uid: u32, gid: u32) -> Self {
+        let name = executor_id.replace(|c: char| !c.is_ascii_alphanumeric(), "_");
+        Self {
+            table: format!("flame_{name}"),
+            cgroup: format!("{CGROUP_PARENT}/{name}"),
+            uid,
+            gid,
+        }
+    }
+
+    /// Create the cgroup of the executor and program its rules.
+    pub fn apply(executor_id: &str, rules: &[EgressRule]) -> Result<Self, FlameError> {
+        let guard = Self::new(
+            executor_id,
+            service_id(FLAME_SERVICE_UID)?,
+            service_id(FLAME_SERVICE_GID)?,
+        );
+
+        std::fs::create_dir_all(guard.cgroup_dir()).map_err(|e| {
+            FlameError::Internal(format!(
+                "failed to create cgroup <{}> for the egress policy: {e}",
+                guard.cgroup
+            ))
+        })?;
+        nft(&guard.ruleset(rules))?;
+
+        tracing::debug!(
+            "Applied the egress policy of executor <{executor_id}> with {} rules.",
+            rules.len()
+        );
+
+        Ok(guard)
+    }
+
+    fn cgroup_dir(&self) -> PathBuf {
+        PathBuf::from(CGROUP_ROOT).join(&self.cgroup)
+    }
+
+    /// Grant the directory to the user of the service, e.g. its working
+    /// directory.
+    pub fn grant(&self, dir: &Path) -> Result<(), FlameError> {
+        std::os::unix::fs::chown(dir, Some(self.uid), Some(self.gid)).map_err(|e| {
+            FlameError::Internal(format!(
+                "failed to grant directory <{}> to the service: {e}",
+                dir.display()
+            ))
+        })
+    }
+
+    /// Share the directory with the user of the service by its group, e.g.
+    /// the socket directory of the executors; it's sticky, so the files are
+    /// only removed by their owners.
+    pub fn share(&self, dir: &Path) -> Result<(), FlameError> {
+        let failed = |e: std::io::Error| {
+            FlameError::Internal(format!(
+                "failed to share directory <{}> with the service: {e}",
+                dir.display()
+            ))
+        };
+        std::os::unix::fs::chown(dir, None, Some(self.gid)).map_err(failed)?;
+        std::fs::set_permissions(dir, std::fs::Permissions::from_mode(0o1770)).map_err(failed)
+    }
+
+    /// The command which starts the service in the cgroup, so the service is
+    /// restricted before it runs; the privileges are dropped after joining
+    /// the cgroup.
+    pub fn wrap(&self, command: &str, args: &[String]) -> (String, Vec<String>) {
+        let procs = self.cgroup_dir().join("cgroup.procs");
+        let script = format!(
+            "echo $$ > '{}' && exec setpriv --reuid={} --regid={} --clear-groups \
+             --inh-caps=-all --ambient-caps=-all --bounding-set=-all --no-new-privs -- \"$@\"",
+            procs.display(),
+            self.uid,
+            self.gid,
+        );
+
+        let mut wrapped = vec!["-c".to_string(), script, "sh".to_string()];
+        wrapped.push(command.to_string());
+        wrapped.extend(args.iter().cloned());
+
+        ("sh".to_string(), wrapped)
+    }
+
+    /// The table is replaced if it's left by the previous service.
+    fn ruleset(&self, rules: &[EgressRule]) -> String {
+        let mut ruleset = format!(
+            "table inet {table}\ndelete table inet {table}\n\
+             table inet {table} {{\n\
+             \x20   chain output {{\n\
+             \x20       type filter hook output priority 0; policy accept;\n\
+             \x20       socket cgroupv2 level 2 \"{cgroup}\" jump egress\n\
+             \x20   }}\n\
+             \x20   chain egress {{\n\
+             \x20       oifname \"lo\" accept\n\
+             \x20       ct state established,related accept\n",
+            table = self.table,
+            cgroup = self.cgroup,
+        );
+        for rule in rules {
+            let _ = writeln!(ruleset, "        {}", rule.render());
+        }
+        ruleset.push_str("        reject\n    }\n}\n");
+
+        ruleset
+    }
+}
+
+impl Drop for EgressGuard {
+    fn drop(&mut self) {
+        if let Err(e) = nft(&format!("delete table inet {}\n", self.table)) {
+            tracing::warn!("Failed to remove the egress policy <{}>: {e}", self.table);
+        }
+        // The cgroup is kept until the killed service exits, and reused by
+        // the next service of the executor.
+        if let Err(e) = std::fs::remove_dir(self.cgroup_dir()) {
+            tracing::debug!("The cgroup <{}> is not removed: {e}", self.cgroup);
+        }
+    }
+}
+
+/// Run the script by `nft -f -`.
+fn nft(script: &str) -> Result<(), FlameError> {
+    let failed = |e: String| FlameError::Internal(format!("failed to program nftables: {e}"));
+
+    let mut child = Command::new("nft")
+        .args(["-f", "-"])
+        .stdin(Stdio::piped())
+        .stdout(Stdio::null())
+        .stderr(Stdio::piped())
+        .spawn()
+        .map_err(|e| failed(e.to_string()))?;
+    if let Some(mut stdin) = child.stdin.take() {
+        stdin
+            .write_all(script.as_bytes())
+            .map_err(|e| failed(e.to_string()))?;
+    }
+    let output = child
+        .wait_with_output()
+        .map_err(|e| failed(e.to_string()))?;
+    if !output.status.success() {
+        return Err(failed(
+            String::from_utf8_lossy(&output.stderr).trim().to_string(),
+        ));
+    }
+
+    Ok(())
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn envs(allow: &str) -> HashMap<String, String> {
+        HashMap::from([(FLAME_EGRESS_ALLOW.to_string(), allow.to_string())])
+    }
+
+    #[test]
+    fn test_egress_policy_from_envs() {
+        assert_eq!(EgressPolicy::from_envs(&HashMap::new()).unwrap(), None);
+
+        let policy = EgressPolicy::from_envs(&envs(
+            "10.1.2.3/8, 192.168.1.10:5432,[fd00::1]:443,fd00::/8",
+        ))
+        .unwrap()
+        .unwrap();
+        let rules: Vec<String> = policy
+            .resolve()
+            .unwrap()
+            .iter()
+            .map(|r| r.render())
+            .collect();
+        assert_eq!(
+            rules,
+            vec![
+                "ip daddr 10.0.0.0/8 accept",
+                "ip daddr 192.168.1.10/32 meta l4proto { tcp, udp } th dport 5432 accept",
+                "ip6 daddr fd00::1/128 meta l4proto { tcp, udp } th dport 443 accept",
+                "ip6 daddr fd00::/8 accept",
+            ]
+        );
+
+        // The hosts need a port.
+        for allow in [
+            "api.example.com",
+            "10.0.0.0/33",
+            "10.0.0.1:http",
+            "[fd00::1]",
+        ] {
+            assert!(EgressPolicy::from_envs(&envs(allow)).is_err(), "{allow}");
+        }
+        let policy = EgressPolicy::from_envs(&envs("api.example.com:443"))
+            .unwrap()
+            .unwrap();
+        assert_eq!(
+            policy.destinations,
+            vec![Destination::Host {
+                host: "api.example.com".to_string(),
+                port: 443,
+            }]
+        );
+    }
+
+    #[test]
+    fn test_egress_policy_endpoints() {
+        let mut policy = EgressPolicy::default();
+        policy.allow_endpoint("http://127.0.0.1:8080");
+        policy.allow_endpoint("https://[::1]");
+        policy.allow_endpoint("not a url");
+
+        let rules: Vec<String> = policy
+            .resolve()
+            .unwrap()
+            .iter()
+            .map(|r| r.render())
+            .collect();
+        assert_eq!(
+            rules,
+            vec![
+                "ip daddr 127.0.0.1/32 meta l4proto { tcp, udp } th dport 8080 accept",
+                "ip6 daddr ::1/128 meta l4proto { tcp, udp } th dport 443 accept",
+            ]
+        );
+    }
+
+    #[test]
+    fn test_egress_ruleset() {
+        let guard = EgressGuard::new("exec-1", 1000, 1001);
+        let rules = [EgressRule {
+            addr: "10.0.0.0".parse().unwrap(),
+            prefix: 8,
+            port: None,
+        }];
+
+        let ruleset = guard.ruleset(&rules);
+        assert!(ruleset.starts_with("table inet flame_exec_1\ndelete table inet flame_exec_1\n"));
+        assert!(ruleset.contains("socket cgroupv2 level 2 \"flame/exec_1\" jump egress"));
+        assert!(ruleset.contains("        ip daddr 10.0.0.0/8 accept\n        reject\n"));
+
+        let (command, args) = guard.wrap("python3", &["-m".to_string(), "svc".to_string()]);
+        assert_eq!(command, "sh");
+        assert_eq!(args[0], "-c");
+        assert!(args[1].starts_with("echo $$ > '/sys/fs/cgroup/flame/exec_1/cgroup.procs' && "));
+        assert!(args[1].contains("exec setpriv --reuid=1000 --regid=1001 --clear-groups"));
+        assert!(args[1].contains("--bounding-set=-all --no-new-privs -- \"$@\""));
+        assert_eq!(&args[2..], ["sh", "python3", "-m", "svc"]);
+
+        // The guard of the test does not own the table or the cgroup.
+        std::mem::forget(guard);
+
+        // The id can't reach the other cgroups.
+        let guard = EgressGuard::new("../exec 1", DEFAULT_SERVICE_ID, DEFAULT_SERVICE_ID);
+        assert_eq!(guard.table, "flame____exec_1");
+        assert_eq!(guard.cgroup, "flame/___exec_1");
+        std::mem::forget(guard);
+    }
+
+    #[test]
+    fn test_grant_scratch_dir() {
+        use std::os::unix::fs::MetadataExt;
+
+        let temp = tempfile::tempdir().unwrap();
+        let scratch = temp.path().join("ssn-1");
+        std::fs::create_dir(&scratch).unwrap();
+        std::fs::set_permissions(&scratch, std::fs::Permissions::from_mode(0o700)).unwrap();
+
+        // The scratch directory is only granted to another user by root, as
+        // the executor manager runs; otherwise it's granted to the owner.
+        let owner = std::fs::metadata(&scratch).unwrap();
+        let (uid, gid) = match owner.uid() {
+            0 => (DEFAULT_SERVICE_ID, DEFAULT_SERVICE_ID),
+            _ => (owner.uid(), owner.gid()),
+        };
+        let guard = EgressGuard::new("exec-1", uid, gid);
+        guard.grant(&scratch).unwrap();
+
+        let meta = std::fs::metadata(&scratch).unwrap();
+        assert_eq!((meta.uid(), meta.gid()), (uid, gid));
+        assert_eq!(meta.mode() & 0o777, 0o700);
+
+        // The guard of the test does not own the table or the cgroup.
+        std::mem::forget(guard);
+    }
+}
@@ -22,7 +22,7 @@ use hyper_util::rt::TokioIo;
 use tokio::net::UnixStream;
 use tonic::transport::Channel;
 use tonic::transport::{Endpoint, Uri};
-use tonic::Request;
+use tonic::{Request, Response, Status};
 use tower::service_fn;
 
 use ::rpc::flame::v1 as rpc;
@@ -30,13 +30,44 @@ use rpc::instance_client::InstanceClient;
 use rpc::EmptyRequest;
 
 use crate::shims::{ExecutorWorkDir, Shim};
-use common::apis::{SessionContext, TaskContext, TaskResult, TaskState};
+use common::apis::{checksum, GroupContext, SessionContext, TaskContext, TaskResult, TaskState};
 use common::FlameError;
 use stdng::{logs::TraceFn, trace_fn};
 
 pub struct GrpcShim {
     client: Option<InstanceClient<Channel>>,
     endpoint: String,
+    /// Where the service publishes its endpoint if it does not listen on the
+    /// socket, e.g. a TCP port computed by its runtime.
+    endpoint_file: String,
+}
+
+/// The address of the service in its endpoint, i.e. `unix://<path>`,
+/// `tcp://<host>:<port>`, or the path of the socket.
+#[derive(Debug, PartialEq, Eq)]
+enum ServiceAddr {
+    Unix(String),
+    Tcp(String),
+}
+
+impl TryFrom<&str> for ServiceAddr {
+    type Error = FlameError;
+
+    fn try_from(endpoint: &str) -> Result<Self, Self::Error> {
+        if let Some(addr) = endpoint.strip_prefix("tcp://") {
+            return Ok(Self::Tcp(addr.to_string()));
+        }
+        if let Some(path) = endpoint.strip_prefix("unix://") {
+            return Ok(Self::Unix(path.to_string()));
+        }
+        if endpoint.contains("://") {
+            return Err(FlameError::InvalidConfig(format!(
+                "unsupported service endpoint <{endpoint}>"
+            )));
+        }
+
+        Ok(Self::Unix(endpoint.to_string()))
+    }
 }
 
 impl GrpcShim {
@@ -46,6 +77,7 @@ impl GrpcShim {
         Ok(Self {
             client: None,
             endpoint: work_dir.socket().to_string_lossy().to_string(),
+            endpoint_file: work_dir.endpoint_file().to_string_lossy().to_string(),
         })
     }
 
@@ -57,31 +89,44 @@ impl GrpcShim {
     pub async fn connect(&mut self) -> Result<(), FlameError> {
         trace_fn!("GrpcShim::connect");
 
-        WaitForSvcSocketFuture::new(self.endpoint.clone()).await?;
+        self.endpoint = WaitForSvcSocketFuture::new(self.endpoint.clone())
+            .with_endpoint_file(self.endpoint_file.clone())
+            .await?;
         tracing::debug!("Try to connect to service at <{}>", self.endpoint);
 
-        let channel = Endpoint::try_from("http://[::]:50051")
-            .unwrap()
-            .connect_with_connector({
-                let service_addr = self.endpoint.clone();
-
-                service_fn(move |_: Uri| {
-                    let service_addr = service_addr.clone();
-                    async move {
-                        UnixStream::connect(service_addr)
-                            .await
-                            .map(TokioIo::new)
-                            .map_err(std::io::Error::other)
-                    }
-                })
-            })
-            .await
-            .map_err(|e| {
-                FlameError::Network(format!(
-                    "failed to connect to service at <{}>: {e}",
-                    self.endpoint
-                ))
-            })?;
+        let channel = match ServiceAddr::try_from(self.endpoint.as_str())? {
+            ServiceAddr::Unix(service_addr) => {
+                Endpoint::try_from("http://[::]:50051")
+                    .unwrap()
+                    .connect_with_connector(service_fn(move |_: Uri| {
+                        let service_addr = service_addr.clone();
+                        async move {
+                            UnixStream::connect(service_addr)
+                                .await
+                                .map(TokioIo::new)
+                                .map_err(std::io::Error::other)
+                        }
+                    }))
+                    .await
+            }
+            ServiceAddr::Tcp(addr) => {
+                Endpoint::from_shared(format!("http://{addr}"))
+                    .map_err(|e| {
+                        FlameError::InvalidConfig(format!(
+                            "invalid service endpoint <{}>: {e}",
+                            self.endpoint
+                        ))
+                    })?
+                    .connect()
+                    .await
+            }
+        }
+        .map_err(|e| {
+            FlameError::Network(format!(
+                "failed to connect to service at <{}>: {e}",
+                self.endpoint
+            ))
+        })?;
 
         self.client = Some(InstanceClient::new(channel));
 
@@ -129,11 +174,30 @@ impl Shim for GrpcShim {
         trace_fn!("GrpcShim::on_task_invoke");
 
         if let Some(ref mut client) = self.client {
-            let req = Request::new(rpc::TaskContext::from(ctx.clone()));
+            let mut req = Request::new(rpc::TaskContext::from(ctx.clone()));
+            // The service observes the deadline by the context and the timeout.
+            if let Some(remaining) = ctx.remaining() {
+                req.set_timeout(remaining);
+            }
             tracing::debug!("req: {:?}", req);
             let resp = client.on_task_invoke(req).await?;
             let output = resp.into_inner();
 
+            // Fail the task if the output was corrupted.
+            if let Err(e) = checksum::verify(
+                &format!("output of task <{}/{}>", ctx.session_id, ctx.task_id),
+                output.output.as_deref(),
+                output.checksum.as_deref(),
+            ) {
+                tracing::error!("Task failed: {e}");
+                return Ok(TaskResult {
+                    state: TaskState::Failed,
+                    output: None,
+                    message: Some(e.to_string()),
+                    output_ref: None,
+                });
+            }
+
             // Convert rpc::TaskResult to TaskResult
             // The From trait handles return_code != 0 by setting TaskState::Failed
             let task_result: TaskResult = output.into();
@@ -175,24 +239,112 @@ impl Shim for GrpcShim {
 
         Ok(())
     }
+
+    async fn on_session_reset(&mut self) -> Result<bool, FlameError> {
+        trace_fn!("GrpcShim::on_session_reset");
+
+        let Some(ref mut client) = self.client else {
+            return Ok(false);
+        };
+
+        let req = Request::new(EmptyRequest::default());
+        match client.on_session_reset(req).await {
+            Ok(resp) => {
+                let output = resp.into_inner();
+                if output.return_code != 0 {
+                    tracing::debug!("The service was not reset: {:?}", output.message);
+                }
+                Ok(output.return_code == 0)
+            }
+            // The services of the earlier SDKs do not reset their state.
+            Err(status) if status.code() == tonic::Code::Unimplemented => Ok(false),
+            Err(status) => Err(FlameError::from(status)),
+        }
+    }
+
+    async fn on_group_enter(&mut self, ctx: &GroupContext) -> Result<(), FlameError> {
+        trace_fn!("GrpcShim::on_group_enter");
+
+        let Some(ref mut client) = self.client else {
+            return Err(FlameError::Internal(format!(
+                "no connection to service at <{}>",
+                self.endpoint
+            )));
+        };
+
+        let req = Request::new(rpc::GroupContext::from(ctx.clone()));
+        group_result(client.on_group_enter(req).await)
+    }
+
+    async fn on_group_leave(&mut self, ctx: &GroupContext) -> Result<(), FlameError> {
+        trace_fn!("GrpcShim::on_group_leave");
+
+        let Some(ref mut client) = self.client else {
+            return Err(FlameError::Internal(format!(
+                "no connection to service at <{}>",
+                self.endpoint
+            )));
+        };
+
+        let req = Request::new(rpc::GroupContext::from(ctx.clone()));
+        group_result(client.on_group_leave(req).await)
+    }
 }
 
+/// The result of the group callbacks; the services of the earlier SDKs do not
+/// know the groups, so they're ignored.
+fn group_result(resp: Result<Response<rpc::Result>, Status>) -> Result<(), FlameError> {
+    match resp {
+        Ok(resp) => {
+            let output = resp.into_inner();
+            if output.return_code != 0 {
+                return Err(FlameError::Internal(output.message.unwrap_or_default()));
+            }
+            Ok(())
+        }
+        Err(status) if status.code() == tonic::Code::Unimplemented => Ok(()),
+        Err(status) => Err(FlameError::from(status)),
+    }
+}
+
+/// Wait for the service to listen on the socket, or to publish its endpoint
+/// in the endpoint file; the endpoint of the service is returned.
 struct WaitForSvcSocketFuture {
     path: String,
+    endpoint_file: Option<String>,
 }
 
 impl WaitForSvcSocketFuture {
     pub fn new(path: String) -> Self {
-        Self { path }
+        Self {
+            path,
+            endpoint_file: None,
+        }
+    }
+
+    pub fn with_endpoint_file(mut self, endpoint_file: String) -> Self {
+        self.endpoint_file = Some(endpoint_file);
+        self
     }
 }
 
 impl Future for WaitForSvcSocketFuture {
-    type Output = Result<(), FlameError>;
+    type Output = Result<String, FlameError>;
 
     fn poll(self: Pin<&mut Self>, ctx: &mut Context<'_>) -> Poll<Self::Output> {
-        if fs::exists(&self.path).unwrap_or(false) {
-            Poll::Ready(Ok(()))
+        // The file is written by the service once it's listening; it's
+        // renamed into place, so it's never read partially.
+        let published = self
+            .endpoint_file
+            .as_ref()
+            .and_then(|file| fs::read_to_string(file).ok())
+            .map(|endpoint| endpoint.trim().to_string())
+            .filter(|endpoint| !endpoint.is_empty());
+
+        if let Some(endpoint) = published {
+            Poll::Ready(Ok(endpoint))
+        } else if fs::exists(&self.path).unwrap_or(false) {
+            Poll::Ready(Ok(self.path.clone()))
         } else {
             ctx.waker().wake_by_ref();
             Poll::Pending
@@ -229,6 +381,8 @@ mod tests {
             working_directory: None,
             environments: HashMap::new(),
             url: None,
+            output_schema: None,
+            outputs: None,
         };
 
         ExecutorWorkDir::new(&app, executor_id).unwrap()
@@ -287,6 +441,37 @@ mod tests {
         assert_eq!(future.path, "/tmp/test.sock");
     }
 
+    #[tokio::test]
+    async fn test_wait_for_published_endpoint() {
+        let temp = tempdir().unwrap();
+        let socket = temp.path().join("svc.sock");
+        let endpoint_file = temp.path().join("svc.endpoint");
+        std::fs::write(&endpoint_file, "tcp://127.0.0.1:7001\n").unwrap();
+
+        let endpoint = WaitForSvcSocketFuture::new(socket.to_string_lossy().to_string())
+            .with_endpoint_file(endpoint_file.to_string_lossy().to_string())
+            .await
+            .unwrap();
+        assert_eq!(endpoint, "tcp://127.0.0.1:7001");
+    }
+
+    #[test]
+    fn test_service_addr() {
+        assert_eq!(
+            ServiceAddr::try_from("/var/flame/executors/1.sock").unwrap(),
+            ServiceAddr::Unix("/var/flame/executors/1.sock".to_string())
+        );
+        assert_eq!(
+            ServiceAddr::try_from("unix:///tmp/svc.sock").unwrap(),
+            ServiceAddr::Unix("/tmp/svc.sock".to_string())
+        );
+        assert_eq!(
+            ServiceAddr::try_from("tcp://127.0.0.1:7001").unwrap(),
+            ServiceAddr::Tcp("127.0.0.1:7001".to_string())
+        );
+        assert!(ServiceAddr::try_from("mdns://svc.local").is_err());
+    }
+
     #[tokio::test]
     #[allow(clippy::await_holding_lock)]
     async fn test_on_session_enter_without_connection() {
@@ -308,9 +493,14 @@ mod tests {
                 working_directory: None,
                 environments: HashMap::new(),
                 url: None,
+                output_schema: None,
+                outputs: None,
             },
             slots: 1,
             common_data: None,
+            scratch_dir: None,
+            content_type: None,
+            client_host: None,
         };
 
         let result = shim.on_session_enter(&ctx).await;
@@ -334,6 +524,13 @@ mod tests {
             task_id: "test-task".to_string(),
             session_id: "test-session".to_string(),
             input: None,
+            principal: None,
+            deadline: None,
+            content_type: None,
+            group: None,
+            method: None,
+            priority: None,
+            sequence: None,
         };
 
         let result = shim.on_task_invoke(&ctx).await;
@@ -0,0 +1,321 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//! The health check of the service process behind the shim, so a dead service
+//! is detected between tasks instead of by the next task.
+//!
+//! The probe is configured by the environments of the application:
+//!   FLAME_HEALTH_GRPC_ENDPOINT   - the endpoint of the gRPC health service
+//!   FLAME_HEALTH_GRPC_SERVICE    - the service to check, default "" (the server)
+//!   FLAME_HEALTH_COMMAND         - the command to run by `sh -c`; exit 0 is healthy
+//!   FLAME_HEALTH_INTERVAL        - the interval of probes in seconds, default 10
+//!   FLAME_HEALTH_TIMEOUT         - the timeout of each probe in seconds, default 5
+//!   FLAME_HEALTH_FAILURE_THRESHOLD - the consecutive failures to be unhealthy,
+//!                                  default 3
+//!
+//! The gRPC probe is used if both of the endpoint and command are set.
+
+use std::collections::HashMap;
+use std::sync::{Arc, Mutex};
+use std::time::Duration;
+
+use stdng::lock_ptr;
+use tokio::process::Command;
+use tokio::task::JoinHandle;
+use tonic::transport::Endpoint;
+use tonic_health::pb::health_check_response::ServingStatus;
+use tonic_health::pb::health_client::HealthClient;
+use tonic_health::pb::HealthCheckRequest;
+
+use common::FlameError;
+
+const FLAME_HEALTH_GRPC_ENDPOINT: &str = "FLAME_HEALTH_GRPC_ENDPOINT";
+const FLAME_HEALTH_GRPC_SERVICE: &str = "FLAME_HEALTH_GRPC_SERVICE";
+const FLAME_HEALTH_COMMAND: &str = "FLAME_HEALTH_COMMAND";
+const FLAME_HEALTH_INTERVAL: &str = "FLAME_HEALTH_INTERVAL";
+const FLAME_HEALTH_TIMEOUT: &str = "FLAME_HEALTH_TIMEOUT";
+const FLAME_HEALTH_FAILURE_THRESHOLD: &str = "FLAME_HEALTH_FAILURE_THRESHOLD";
+
+const DEFAULT_HEALTH_INTERVAL_SECS: u64 = 10;
+const DEFAULT_HEALTH_TIMEOUT_SECS: u64 = 5;
+const DEFAULT_HEALTH_FAILURE_THRESHOLD: u32 = 3;
+
+pub type HealthMonitorPtr = Arc<HealthMonitor>;
+
+#[derive(Clone, Debug, PartialEq)]
+enum HealthProbe {
+    Grpc { endpoint: String, service: String },
+    Command { command: String },
+}
+
+#[derive(Clone, Debug)]
+pub struct HealthCheck {
+    probe: HealthProbe,
+    interval: Duration,
+    timeout: Duration,
+    failure_threshold: u32,
+    envs: HashMap<String, String>,
+}
+
+impl HealthCheck {
+    /// Build the health check from the environments of the application; None
+    /// if the application does not define a probe.
+    pub fn from_envs(envs: &HashMap<String, String>) -> Result<Option<Self>, FlameError> {
+        let probe = match (
+            envs.get(FLAME_HEALTH_GRPC_ENDPOINT),
+            envs.get(FLAME_HEALTH_COMMAND),
+        ) {
+            (Some(endpoint), _) if !endpoint.is_empty() => HealthProbe::Grpc {
+                endpoint: endpoint.clone(),
+                service: envs
+                    .get(FLAME_HEALTH_GRPC_SERVICE)
+                    .cloned()
+                    .unwrap_or_default(),
+            },
+            (_, Some(command)) if !command.is_empty() => HealthProbe::Command {
+                command: command.clone(),
+            },
+            _ => return Ok(None),
+        };
+
+        Ok(Some(Self {
+            probe,
+            interval: Duration::from_secs(parse_secs(
+                envs,
+                FLAME_HEALTH_INTERVAL,
+                DEFAULT_HEALTH_INTERVAL_SECS,
+            )?),
+            timeout: Duration::from_secs(parse_secs(
+                envs,
+                FLAME_HEALTH_TIMEOUT,
+                DEFAULT_HEALTH_TIMEOUT_SECS,
+            )?),
+            failure_threshold: parse_env(
+                envs,
+                FLAME_HEALTH_FAILURE_THRESHOLD,
+                DEFAULT_HEALTH_FAILURE_THRESHOLD,
+            )?
+            .max(1),
+            envs: envs.clone(),
+        }))
+    }
+
+    async fn probe(&self) -> Result<(), FlameError> {
+        match tokio::time::timeout(self.timeout, self.run_probe()).await {
+            Ok(res) => res,
+            Err(_) => Err(FlameError::Network(format!(
+                "health probe timed out after {:?}",
+                self.timeout
+            ))),
+        }
+    }
+
+    async fn run_probe(&self) -> Result<(), FlameError> {
+        match &self.probe {
+            HealthProbe::Grpc { endpoint, service } => {
+                let channel = Endpoint::from_shared(endpoint.clone())
+                    .map_err(|_| {
+                        FlameError::InvalidConfig(format!("invalid endpoint <{endpoint}>"))
+                    })?
+                    .connect()
+                    .await
+                    .map_err(|e| {
+                        FlameError::Network(format!("failed to connect to <{endpoint}>: {e}"))
+                    })?;
+
+                let resp = HealthClient::new(channel)
+                    .check(HealthCheckRequest {
+                        service: service.clone(),
+                    })
+                    .await?
+                    .into_inner();
+
+                if resp.status != ServingStatus::Serving as i32 {
+                    return Err(FlameError::Internal(format!(
+                        "service <{service}> is not serving: {:?}",
+                        ServingStatus::try_from(resp.status).unwrap_or(ServingStatus::Unknown)
+                    )));
+                }
+
+                Ok(())
+            }
+            HealthProbe::Command { command } => {
+                let output = Command::new("sh")
+                    .arg("-c")
+                    .arg(command)
+                    .envs(&self.envs)
+                    .kill_on_drop(true)
+                    .output()
+                    .await
+                    .map_err(|e| FlameError::Internal(format!("failed to run <{command}>: {e}")))?;
+
+                if !output.status.success() {
+                    return Err(FlameError::Internal(format!(
+                        "<{command}> exited with {}: {}",
+                        output.status,
+                        String::from_utf8_lossy(&output.stderr).trim()
+                    )));
+                }
+
+                Ok(())
+            }
+        }
+    }
+}
+
+/// Runs the health check periodically in background; the binding is marked
+/// unhealthy after the consecutive failures reach the threshold.
+pub struct HealthMonitor {
+    failure: Arc<Mutex<Option<String>>>,
+    handle: JoinHandle<()>,
+}
+
+impl HealthMonitor {
+    pub fn start(name: &str, check: HealthCheck) -> HealthMonitorPtr {
+        let failure = Arc::new(Mutex::new(None));
+
+        let name = name.to_string();
+        let result = failure.clone();
+        let handle = tokio::spawn(async move {
+            let mut failures = 0;
+            let mut interval = tokio::time::interval(check.interval);
+            // The service was checked by the shim when the session entered.
+            interval.tick().await;
+
+            loop {
+                interval.tick().await;
+                match check.probe().await {
+                    Ok(()) => failures = 0,
+                    Err(e) => {
+                        failures += 1;
+                        tracing::warn!(
+                            "Health probe of <{name}> failed ({failures}/{}): {e}",
+                            check.failure_threshold
+                        );
+                        if failures >= check.failure_threshold {
+                            if let Ok(mut result) = lock_ptr!(result) {
+                                *result =
+                                    Some(format!("health probe failed {failures} times: {e}"));
+                            }
+                            tracing::error!("The service of <{name}> is unhealthy: {e}");
+                            break;
+                        }
+                    }
+                }
+            }
+        });
+
+        Arc::new(Self { failure, handle })
+    }
+
+    /// The reason of the failure if the service is unhealthy.
+    pub fn failure(&self) -> Option<String> {
+        lock_ptr!(self.failure).ok().and_then(|f| f.clone())
+    }
+
+    pub fn stop(&self) {
+        self.handle.abort();
+    }
+}
+
+impl Drop for HealthMonitor {
+    fn drop(&mut self) {
+        self.stop();
+    }
+}
+
+fn parse_env<T: std::str::FromStr>(
+    envs: &HashMap<String, String>,
+    key: &str,
+    default: T,
+) -> Result<T, FlameError> {
+    match envs.get(key) {
+        Some(v) => v
+            .parse::<T>()
+            .map_err(|_| FlameError::InvalidConfig(format!("invalid {key} <{v}>"))),
+        None => Ok(default),
+    }
+}
+
+/// The seconds of the environment, which must be positive, e.g. a zero
+/// interval of the probes would never tick.
+fn parse_secs(envs: &HashMap<String, String>, key: &str, default: u64) -> Result<u64, FlameError> {
+    match parse_env(envs, key, default)? {
+        0 => Err(FlameError::InvalidConfig(format!("{key} must be positive"))),
+        secs => Ok(secs),
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_health_check_from_envs() {
+        assert!(HealthCheck::from_envs(&HashMap::new()).unwrap().is_none());
+
+        let envs = HashMap::from([
+            (
+                FLAME_HEALTH_GRPC_ENDPOINT.to_string(),
+                "http://127.0.0.1:50051".to_string(),
+            ),
+            (FLAME_HEALTH_COMMAND.to_string(), "true".to_string()),
+            (FLAME_HEALTH_INTERVAL.to_string(), "2".to_string()),
+        ]);
+        let check = HealthCheck::from_envs(&envs).unwrap().unwrap();
+        assert_eq!(
+            check.probe,
+            HealthProbe::Grpc {
+                endpoint: "http://127.0.0.1:50051".to_string(),
+                service: String::new(),
+            }
+        );
+        assert_eq!(check.interval, Duration::from_secs(2));
+        assert_eq!(
+            check.timeout,
+            Duration::from_secs(DEFAULT_HEALTH_TIMEOUT_SECS)
+        );
+        assert_eq!(check.failure_threshold, DEFAULT_HEALTH_FAILURE_THRESHOLD);
+
+        let invalid = HashMap::from([
+            (FLAME_HEALTH_COMMAND.to_string(), "true".to_string()),
+            (FLAME_HEALTH_TIMEOUT.to_string(), "abc".to_string()),
+        ]);
+        assert!(HealthCheck::from_envs(&invalid).is_err());
+
+        for key in [FLAME_HEALTH_INTERVAL, FLAME_HEALTH_TIMEOUT] {
+            let zero = HashMap::from([
+                (FLAME_HEALTH_COMMAND.to_string(), "true".to_string()),
+                (key.to_string(), "0".to_string()),
+            ]);
+            assert!(
+                matches!(
+                    HealthCheck::from_envs(&zero),
+                    Err(FlameError::InvalidConfig(_))
+                ),
+                "{key}"
+            );
+        }
+    }
+
+    #[tokio::test]
+    async fn test_command_probe() {
+        let envs = HashMap::from([(FLAME_HEALTH_COMMAND.to_string(), "exit 0".to_string())]);
+        let check = HealthCheck::from_envs(&envs).unwrap().unwrap();
+        assert!(check.probe().await.is_ok());
+
+        let envs = HashMap::from([(FLAME_HEALTH_COMMAND.to_string(), "exit 1".to_string())]);
+        let check = HealthCheck::from_envs(&envs).unwrap().unwrap();
+        assert!(check.probe().await.is_err());
+    }
+}
@@ -25,30 +25,70 @@ use async_trait::async_trait;
 use nix::sys::signal::{killpg, Signal};
 #[cfg(unix)]
 use nix::unistd::Pid;
-use stdng::{logs::TraceFn, trace_fn};
+use stdng::{lock_ptr, logs::TraceFn, trace_fn, MutexPtr};
 use tokio::sync::Mutex;
 
 use crate::executor::Executor;
+use crate::logs::{self, LogScope, LogStream, LogTags};
+use crate::shims::egress::{EgressGuard, EgressPolicy};
 use crate::shims::grpc_shim::GrpcShim;
 use crate::shims::{ExecutorWorkDir, Shim, ShimPtr};
-use common::apis::{ApplicationContext, SessionContext, TaskContext, TaskOutput, TaskResult};
+use common::apis::{
+    ApplicationContext, GroupContext, SessionContext, TaskContext, TaskOutput, TaskResult,
+};
 use common::{
     FlameError, FLAME_CACHE_ENDPOINT, FLAME_CA_FILE, FLAME_ENDPOINT, FLAME_HOME,
-    FLAME_INSTANCE_ENDPOINT, FLAME_LOG, FLAME_WORKING_DIRECTORY,
+    FLAME_INSTANCE_ENDPOINT, FLAME_INSTANCE_ENDPOINT_FILE, FLAME_LOG, FLAME_WORKING_DIRECTORY,
 };
 
-struct HostInstance {
+pub(crate) struct HostInstance {
     child: tokio::process::Child,
+    /// The session and task of the forwarded logs, if forwarding.
+    log_scope: Option<MutexPtr<LogScope>>,
+    /// The egress policy of the process, if the application declares it;
+    /// it's removed after the process is killed.
+    egress: Option<EgressGuard>,
 }
 
 impl HostInstance {
-    fn new(child: tokio::process::Child) -> Self {
-        Self { child }
+    fn new(
+        child: tokio::process::Child,
+        log_scope: Option<MutexPtr<LogScope>>,
+        egress: Option<EgressGuard>,
+    ) -> Self {
+        Self {
+            child,
+            log_scope,
+            egress,
+        }
+    }
+
+    /// Grant the directory to the user of the restricted service, e.g. the
+    /// scratch directory of its session, which is created by the executor
+    /// manager as root.
+    pub(crate) fn grant(&self, dir: &Path) -> Result<(), FlameError> {
+        match &self.egress {
+            Some(guard) => guard.grant(dir),
+            None => Ok(()),
+        }
+    }
+
+    /// Tag the forwarded logs of the instance with the session and task.
+    pub(crate) fn set_log_scope(&self, session_id: Option<&str>, task_id: Option<&str>) {
+        let Some(scope) = &self.log_scope else {
+            return;
+        };
+        if let Ok(mut scope) = lock_ptr!(scope) {
+            *scope = LogScope {
+                session_id: session_id.map(str::to_string),
+                task_id: task_id.map(str::to_string),
+            };
+        }
     }
 
     /// Kill the child process
     #[cfg(unix)]
-    fn kill_process(&mut self) {
+    pub(crate) fn kill_process(&mut self) {
         if let Some(id) = self.child.id() {
             let ig = Pid::from_raw(id as i32);
             let _ = killpg(ig, Signal::SIGTERM);
@@ -60,7 +100,7 @@ impl HostInstance {
     }
 
     #[cfg(not(unix))]
-    fn kill_process(&mut self) {
+    pub(crate) fn kill_process(&mut self) {
         drop(self.child.kill());
         tracing::debug!("Killed child process");
     }
@@ -183,7 +223,7 @@ impl HostShim {
             .into_owned()
     }
 
-    fn launch_instance(
+    pub(crate) fn launch_instance(
         app: &ApplicationContext,
         executor: &Executor,
         work_dir: &ExecutorWorkDir,
@@ -215,6 +255,10 @@ impl HostShim {
             FLAME_INSTANCE_ENDPOINT.to_string(),
             work_dir.socket().to_string_lossy().to_string(),
         );
+        envs.insert(
+            FLAME_INSTANCE_ENDPOINT_FILE.to_string(),
+            work_dir.endpoint_file().to_string_lossy().to_string(),
+        );
         if let Some(context) = &executor.context {
             // Pass session manager endpoint for recursive runner calls
             envs.insert(FLAME_ENDPOINT.to_string(), context.cluster.endpoint.clone());
@@ -235,6 +279,25 @@ impl HostShim {
             envs.entry("HOME".to_string()).or_insert(home);
         }
 
+        // Restrict the egress of the service to the destinations of the
+        // application and the endpoints of the cluster.
+        let egress = match EgressPolicy::from_envs(&app.environments)? {
+            Some(mut policy) => {
+                if let Some(context) = &executor.context {
+                    policy.allow_endpoint(&context.cluster.endpoint);
+                    if let Some(cache) = &context.cache {
+                        policy.allow_endpoint(&cache.endpoint);
+                    }
+                }
+                Some(EgressGuard::apply(&executor.id, &policy.resolve()?)?)
+            }
+            None => None,
+        };
+        let (command, args) = match &egress {
+            Some(guard) => guard.wrap(&command, &args),
+            None => (command, args),
+        };
+
         tracing::debug!(
             "Try to start service by command <{command}> with args <{args:?}> and envs <{envs:?}>"
         );
@@ -249,15 +312,28 @@ impl HostShim {
 
         // Setup working directory and tmp (per-instance)
         let work_dir_envs = Self::setup_working_directory(app_work_dir)?;
-        for (key, value) in work_dir_envs {
-            envs.entry(key).or_insert(value);
-        }
 
         // Setup cache directories (per-application) and get environment defaults
         // Use entry().or_insert() so application-specific envs take precedence over defaults
         // This allows applications like flmrun to specify UV_CACHE_DIR pointing to
         // the pre-cached directory instead of using a per-instance empty cache
         let cache_envs = Self::setup_cache(&app.name)?;
+
+        // The restricted service runs as the unprivileged user, so it's
+        // granted the directories it writes.
+        if let Some(guard) = &egress {
+            guard.grant(app_work_dir)?;
+            for dir in work_dir_envs.values().chain(cache_envs.values()) {
+                guard.grant(Path::new(dir))?;
+            }
+            if let Some(socket_dir) = work_dir.socket().parent() {
+                guard.share(socket_dir)?;
+            }
+        }
+
+        for (key, value) in work_dir_envs {
+            envs.entry(key).or_insert(value);
+        }
         for (key, value) in cache_envs {
             envs.entry(key).or_insert(value);
         }
@@ -278,36 +354,56 @@ impl HostShim {
             .open(process_work_dir.join(format!("{}.err", executor.id)))
             .map_err(|e| FlameError::Internal(format!("failed to open stderr log file: {e}")))?;
 
+        cmd.envs(envs).args(args).current_dir(process_work_dir);
         #[cfg(unix)]
-        let child = cmd
-            .envs(envs)
-            .args(args)
-            .current_dir(process_work_dir)
-            .stdout(Stdio::from(log_out))
-            .stderr(Stdio::from(log_err))
-            .process_group(0)
-            .spawn()
-            .map_err(|e| {
-                FlameError::InvalidConfig(format!(
-                    "failed to start service by command <{command}>: {e}"
-                ))
-            })?;
-
-        #[cfg(not(unix))]
-        let child = cmd
-            .envs(envs)
-            .args(args)
-            .current_dir(process_work_dir)
-            .stdout(Stdio::from(log_out))
-            .stderr(Stdio::from(log_err))
-            .spawn()
-            .map_err(|e| {
-                FlameError::InvalidConfig(format!(
-                    "failed to start service by command <{command}>: {e}"
-                ))
-            })?;
-
-        Ok(HostInstance::new(child))
+        cmd.process_group(0);
+
+        // The outputs are copied into the log files by the forwarder, or
+        // written to them directly.
+        let log_files = match executor.logs {
+            Some(_) => {
+                cmd.stdout(Stdio::piped()).stderr(Stdio::piped());
+                Some((log_out, log_err))
+            }
+            None => {
+                cmd.stdout(Stdio::from(log_out))
+                    .stderr(Stdio::from(log_err));
+                None
+            }
+        };
+
+        let mut child = cmd.spawn().map_err(|e| {
+            FlameError::InvalidConfig(format!(
+                "failed to start service by command <{command}>: {e}"
+            ))
+        })?;
+
+        let log_scope = match (executor.logs.clone(), log_files) {
+            (Some(forwarder), Some((log_out, log_err))) => {
+                let tags = LogTags {
+                    node: executor.node.clone(),
+                    executor_id: executor.id.clone(),
+                    application: app.name.clone(),
+                    scope: stdng::new_ptr(LogScope::default()),
+                };
+                if let Some(stdout) = child.stdout.take() {
+                    logs::tee(
+                        stdout,
+                        log_out,
+                        LogStream::Stdout,
+                        tags.clone(),
+                        forwarder.clone(),
+                    );
+                }
+                if let Some(stderr) = child.stderr.take() {
+                    logs::tee(stderr, log_err, LogStream::Stderr, tags.clone(), forwarder);
+                }
+                Some(tags.scope)
+            }
+            _ => None,
+        };
+
+        Ok(HostInstance::new(child, log_scope, egress))
     }
 }
 
@@ -326,18 +422,50 @@ impl Shim for HostShim {
     async fn on_session_enter(&mut self, ctx: &SessionContext) -> Result<(), FlameError> {
         trace_fn!("HostShim::on_session_enter");
 
+        // The scratch directory is created for each session, so it's granted
+        // when the session enters, also to the warm service.
+        if let Some(scratch_dir) = &ctx.scratch_dir {
+            self.instance.grant(Path::new(scratch_dir))?;
+        }
+        self.instance.set_log_scope(Some(&ctx.session_id), None);
         self.instance_client.on_session_enter(ctx).await
     }
 
     async fn on_task_invoke(&mut self, ctx: &TaskContext) -> Result<TaskResult, FlameError> {
         trace_fn!("HostShim::on_task_invoke");
 
-        self.instance_client.on_task_invoke(ctx).await
+        self.instance
+            .set_log_scope(Some(&ctx.session_id), Some(&ctx.task_id));
+        let result = self.instance_client.on_task_invoke(ctx).await;
+        self.instance.set_log_scope(Some(&ctx.session_id), None);
+
+        result
     }
 
     async fn on_session_leave(&mut self) -> Result<(), FlameError> {
         trace_fn!("HostShim::on_session_leave");
 
-        self.instance_client.on_session_leave().await
+        let result = self.instance_client.on_session_leave().await;
+        self.instance.set_log_scope(None, None);
+
+        result
+    }
+
+    async fn on_session_reset(&mut self) -> Result<bool, FlameError> {
+        trace_fn!("HostShim::on_session_reset");
+
+        self.instance_client.on_session_reset().await
+    }
+
+    async fn on_group_enter(&mut self, ctx: &GroupContext) -> Result<(), FlameError> {
+        trace_fn!("HostShim::on_group_enter");
+
+        self.instance_client.on_group_enter(ctx).await
+    }
+
+    async fn on_group_leave(&mut self, ctx: &GroupContext) -> Result<(), FlameError> {
+        trace_fn!("HostShim::on_group_leave");
+
+        self.instance_client.on_group_leave(ctx).await
     }
 }
@@ -0,0 +1,369 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//! The HTTP shim invokes a task by a POST against the endpoint of an existing
+//! HTTP service: the task input is the request body, and the response body is
+//! the task output. The deadline of the task, if any, is sent in milliseconds
+//! since the epoch by the `x-flame-deadline` header, and bounds the timeout of
+//! the requests; the priority of its session, if any, is sent by the
+//! `x-flame-priority` header.
+//!
+//! The shim is configured by the environments of the application:
+//!   FLAME_HTTP_ENDPOINT     - the URL to POST, default `http://127.0.0.1:8080`
+//!   FLAME_HTTP_TIMEOUT      - the timeout of each request in seconds, default 60
+//!   FLAME_HTTP_RETRIES      - the retries on connection errors, 5xx and 429, default 3
+//!   FLAME_HTTP_HEADER_<KEY> - the header `<KEY>` (`_` is replaced by `-`); the
+//!                             value supports `{task_id}`, `{session_id}` and
+//!                             `{application}` placeholders.
+
+use std::collections::HashMap;
+use std::sync::Arc;
+use std::time::Duration;
+
+use async_trait::async_trait;
+use reqwest::{Client, StatusCode};
+use stdng::{logs::TraceFn, trace_fn};
+use tokio::sync::Mutex;
+
+use crate::executor::Executor;
+use crate::shims::host_shim::{HostInstance, HostShim};
+use crate::shims::{ExecutorWorkDir, Shim, ShimPtr};
+use common::apis::{
+    ApplicationContext, SessionContext, TaskContext, TaskOutput, TaskResult, TaskState,
+};
+use common::FlameError;
+
+const FLAME_HTTP_ENDPOINT: &str = "FLAME_HTTP_ENDPOINT";
+const FLAME_HTTP_TIMEOUT: &str = "FLAME_HTTP_TIMEOUT";
+const FLAME_HTTP_RETRIES: &str = "FLAME_HTTP_RETRIES";
+const FLAME_HTTP_HEADER_PREFIX: &str = "FLAME_HTTP_HEADER_";
+
+const DEFAULT_HTTP_ENDPOINT: &str = "http://127.0.0.1:8080";
+const DEFAULT_HTTP_TIMEOUT_SECS: u64 = 60;
+const DEFAULT_HTTP_RETRIES: u32 = 3;
+const RETRY_DELAY: Duration = Duration::from_millis(200);
+const READY_TIMEOUT: Duration = Duration::from_secs(30);
+/// The content type of the inputs if the session has none.
+const DEFAULT_CONTENT_TYPE: &str = "application/octet-stream";
+
+#[derive(Clone, Debug)]
+struct HttpShimConfig {
+    endpoint: String,
+    timeout: Duration,
+    retries: u32,
+    headers: Vec<(String, String)>,
+}
+
+impl HttpShimConfig {
+    fn from_envs(envs: &HashMap<String, String>) -> Result<Self, FlameError> {
+        let timeout = match envs.get(FLAME_HTTP_TIMEOUT) {
+            Some(t) => t.parse::<u64>().map_err(|_| {
+                FlameError::InvalidConfig(format!("invalid {FLAME_HTTP_TIMEOUT} <{t}>"))
+            })?,
+            None => DEFAULT_HTTP_TIMEOUT_SECS,
+        };
+        let retries = match envs.get(FLAME_HTTP_RETRIES) {
+            Some(r) => r.parse::<u32>().map_err(|_| {
+                FlameError::InvalidConfig(format!("invalid {FLAME_HTTP_RETRIES} <{r}>"))
+            })?,
+            None => DEFAULT_HTTP_RETRIES,
+        };
+
+        let mut headers: Vec<(String, String)> = envs
+            .iter()
+            .filter_map(|(k, v)| {
+                k.strip_prefix(FLAME_HTTP_HEADER_PREFIX)
+                    .filter(|name| !name.is_empty())
+                    .map(|name| (name.replace('_', "-").to_lowercase(), v.clone()))
+            })
+            .collect();
+        headers.sort();
+
+        Ok(Self {
+            endpoint: envs
+                .get(FLAME_HTTP_ENDPOINT)
+                .cloned()
+                .unwrap_or(DEFAULT_HTTP_ENDPOINT.to_string()),
+            timeout: Duration::from_secs(timeout),
+            retries,
+            headers,
+        })
+    }
+}
+
+pub struct HttpShim {
+    /// The service process, if the application has a command.
+    instance: Option<HostInstance>,
+    work_dir: ExecutorWorkDir,
+    client: Client,
+    config: HttpShimConfig,
+    application: String,
+}
+
+impl HttpShim {
+    pub async fn new_ptr(
+        executor: &Executor,
+        app: &ApplicationContext,
+    ) -> Result<ShimPtr, FlameError> {
+        trace_fn!("HttpShim::new_ptr");
+
+        let config = HttpShimConfig::from_envs(&app.environments)?;
+        let client = Client::builder()
+            .timeout(config.timeout)
+            .build()
+            .map_err(|e| FlameError::Internal(format!("failed to build http client: {e}")))?;
+
+        let work_dir = ExecutorWorkDir::new(app, &executor.id)?;
+
+        // Launch the service if the application has a command; otherwise the
+        // service is expected to be running at the endpoint.
+        let instance = match app.command.as_deref() {
+            Some(cmd) if !cmd.is_empty() => {
+                Some(HostShim::launch_instance(app, executor, &work_dir)?)
+            }
+            _ => None,
+        };
+
+        Ok(Arc::new(Mutex::new(Self {
+            instance,
+            work_dir,
+            client,
+            config,
+            application: app.name.clone(),
+        })))
+    }
+
+    /// Wait for the service to accept connections; any response means the
+    /// service is up.
+    async fn wait_for_ready(&self) -> Result<(), FlameError> {
+        let deadline = tokio::time::Instant::now() + READY_TIMEOUT;
+        loop {
+            match self.client.head(&self.config.endpoint).send().await {
+                Ok(_) => return Ok(()),
+                Err(e) if tokio::time::Instant::now() >= deadline => {
+                    return Err(FlameError::Network(format!(
+                        "service at <{}> is not ready: {e}",
+                        self.config.endpoint
+                    )));
+                }
+                Err(_) => tokio::time::sleep(RETRY_DELAY).await,
+            }
+        }
+    }
+
+    async fn post(&self, ctx: &TaskContext) -> Result<Option<TaskOutput>, FlameError> {
+        let body = ctx.input.clone().map(|i| i.to_vec()).unwrap_or_default();
+        let content_type = ctx.content_type.as_deref().unwrap_or(DEFAULT_CONTENT_TYPE);
+
+        let mut attempt = 0;
+        loop {
+            let mut req = self
+                .client
+                .post(&self.config.endpoint)
+                .header("content-type", content_type)
+                .header("x-flame-session-id", &ctx.session_id)
+                .header("x-flame-task-id", &ctx.task_id);
+            for (name, value) in &self.config.headers {
+                req = req.header(name, render(value, ctx, &self.application));
+            }
+            // The services authorize the user by the same headers as the frontend.
+            if let Some(principal) = &ctx.principal {
+                for (name, value) in principal.to_headers() {
+                    req = req.header(name, value);
+                }
+            }
+            if let Some(priority) = ctx.priority {
+                req = req.header("x-flame-priority", priority.to_string());
+            }
+            if let (Some(deadline), Some(remaining)) = (ctx.deadline, ctx.remaining()) {
+                if remaining.is_zero() {
+                    return Err(FlameError::Internal(format!(
+                        "task <{}/{}> exceeded its deadline",
+                        ctx.session_id, ctx.task_id
+                    )));
+                }
+                req = req
+                    .header("x-flame-deadline", deadline.timestamp_millis().to_string())
+                    .timeout(remaining.min(self.config.timeout));
+            }
+
+            let err = match req.body(body.clone()).send().await {
+                Ok(resp) if resp.status().is_success() => {
+                    let output = resp.bytes().await.map_err(|e| {
+                        FlameError::Network(format!("failed to read response: {e}"))
+                    })?;
+                    return Ok((!output.is_empty()).then(|| TaskOutput::from(output)));
+                }
+                Ok(resp) => {
+                    let status = resp.status();
+                    let message = resp.text().await.unwrap_or_default();
+                    let err = FlameError::Internal(format!("{status}: {message}"));
+                    // Only the server errors and the throttling (429) are retried.
+                    if !status.is_server_error() && status != StatusCode::TOO_MANY_REQUESTS {
+                        return Err(err);
+                    }
+                    err
+                }
+                Err(e) => FlameError::Network(format!(
+                    "failed to post to <{}>: {e}",
+                    self.config.endpoint
+                )),
+            };
+
+            if attempt >= self.config.retries {
+                return Err(err);
+            }
+            attempt += 1;
+            tracing::warn!(
+                "Retry task <{}/{}> ({}/{}): {err}",
+                ctx.session_id,
+                ctx.task_id,
+                attempt,
+                self.config.retries
+            );
+            tokio::time::sleep(RETRY_DELAY * attempt).await;
+        }
+    }
+}
+
+/// Render the placeholders of a header template.
+fn render(template: &str, ctx: &TaskContext, application: &str) -> String {
+    template
+        .replace("{task_id}", &ctx.task_id)
+        .replace("{session_id}", &ctx.session_id)
+        .replace("{application}", application)
+}
+
+impl Drop for HttpShim {
+    fn drop(&mut self) {
+        if let Some(instance) = self.instance.as_mut() {
+            instance.kill_process();
+        }
+    }
+}
+
+#[async_trait]
+impl Shim for HttpShim {
+    async fn on_session_enter(&mut self, _: &SessionContext) -> Result<(), FlameError> {
+        trace_fn!("HttpShim::on_session_enter");
+
+        self.wait_for_ready().await
+    }
+
+    async fn on_task_invoke(&mut self, ctx: &TaskContext) -> Result<TaskResult, FlameError> {
+        trace_fn!("HttpShim::on_task_invoke");
+        if let Some(instance) = &self.instance {
+            instance.set_log_scope(Some(&ctx.session_id), Some(&ctx.task_id));
+        }
+
+        match self.post(ctx).await {
+            Ok(output) => Ok(TaskResult {
+                state: TaskState::Succeed,
+                output,
+                message: None,
+                output_ref: None,
+            }),
+            Err(e) => {
+                tracing::error!("Task failed: {e}");
+                Ok(TaskResult {
+                    state: TaskState::Failed,
+                    output: None,
+                    message: Some(e.to_string()),
+                    output_ref: None,
+                })
+            }
+        }
+    }
+
+    async fn on_session_leave(&mut self) -> Result<(), FlameError> {
+        trace_fn!("HttpShim::on_session_leave");
+        if let Some(instance) = &self.instance {
+            instance.set_log_scope(None, None);
+        }
+
+        Ok(())
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_http_shim_config_default() {
+        let config = HttpShimConfig::from_envs(&HashMap::new()).unwrap();
+
+        assert_eq!(config.endpoint, DEFAULT_HTTP_ENDPOINT);
+        assert_eq!(
+            config.timeout,
+            Duration::from_secs(DEFAULT_HTTP_TIMEOUT_SECS)
+        );
+        assert_eq!(config.retries, DEFAULT_HTTP_RETRIES);
+        assert!(config.headers.is_empty());
+    }
+
+    #[test]
+    fn test_http_shim_config_from_envs() {
+        let envs = HashMap::from([
+            (
+                FLAME_HTTP_ENDPOINT.to_string(),
+                "http://localhost:9000/run".to_string(),
+            ),
+            (FLAME_HTTP_TIMEOUT.to_string(), "5".to_string()),
+            (FLAME_HTTP_RETRIES.to_string(), "0".to_string()),
+            (
+                "FLAME_HTTP_HEADER_X_REQUEST_ID".to_string(),
+                "{session_id}-{task_id}".to_string(),
+            ),
+            ("FLAME_HTTP_HEADER_".to_string(), "ignored".to_string()),
+            ("OTHER".to_string(), "ignored".to_string()),
+        ]);
+        let config = HttpShimConfig::from_envs(&envs).unwrap();
+
+        assert_eq!(config.endpoint, "http://localhost:9000/run");
+        assert_eq!(config.timeout, Duration::from_secs(5));
+        assert_eq!(config.retries, 0);
+        assert_eq!(
+            config.headers,
+            vec![(
+                "x-request-id".to_string(),
+                "{session_id}-{task_id}".to_string()
+            )]
+        );
+
+        let invalid = HashMap::from([(FLAME_HTTP_TIMEOUT.to_string(), "abc".to_string())]);
+        assert!(HttpShimConfig::from_envs(&invalid).is_err());
+    }
+
+    #[test]
+    fn test_render_header() {
+        let ctx = TaskContext {
+            task_id: "1".to_string(),
+            session_id: "ssn-1".to_string(),
+            input: None,
+            principal: None,
+            deadline: None,
+            content_type: None,
+            group: None,
+            method: None,
+            priority: None,
+            sequence: None,
+        };
+
+        assert_eq!(
+            render("{application}/{session_id}/{task_id}", &ctx, "app"),
+            "app/ssn-1/1"
+        );
+        assert_eq!(render("static", &ctx, "app"), "static");
+    }
+}
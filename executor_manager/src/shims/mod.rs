@@ -11,38 +11,78 @@ See the License for the specific language governing permissions and
 limitations under the License.
 */
 
+mod egress;
 mod grpc_shim;
+pub mod health;
 mod host_shim;
+mod http_shim;
+mod reflection_shim;
+pub mod schema;
+pub mod stacks;
 mod wasm_shim;
 
 use std::env;
 use std::fs;
 use std::path::{Path, PathBuf};
 use std::sync::Arc;
+use std::time::{Duration, Instant};
 
 use async_trait::async_trait;
 use tokio::sync::Mutex;
 
 use self::host_shim::HostShim;
+use self::http_shim::HttpShim;
+use self::reflection_shim::ReflectionShim;
 use self::wasm_shim::WasmShim;
 
 use crate::executor::Executor;
 use common::apis::{
-    ApplicationContext, SessionContext, Shim as ShimType, TaskContext, TaskOutput, TaskResult,
+    ApplicationContext, GroupContext, SessionContext, Shim as ShimType, TaskContext, TaskOutput,
+    TaskResult,
 };
 use common::{FlameError, FLAME_WORKING_DIRECTORY};
 
 pub type ShimPtr = Arc<Mutex<dyn Shim>>;
 
+/// The shim kept alive after its session was unbound, so the next session of
+/// the same application skips starting the service.
+#[derive(Clone)]
+pub struct WarmShim {
+    application: ApplicationContext,
+    shim: ShimPtr,
+    expires_at: Instant,
+}
+
+impl WarmShim {
+    pub fn new(application: &ApplicationContext, shim: ShimPtr, ttl: Duration) -> Self {
+        Self {
+            application: application.clone(),
+            shim,
+            expires_at: Instant::now() + ttl,
+        }
+    }
+
+    /// The shim if it's still warm for the application; it's dropped, and
+    /// the service is stopped, if the application was changed or it expired.
+    pub fn reuse(self, app: &ApplicationContext) -> Option<ShimPtr> {
+        if self.application != *app || Instant::now() >= self.expires_at {
+            return None;
+        }
+
+        Some(self.shim)
+    }
+}
+
 /// Represents the executor's working directory with cleanup management.
 /// Directory structure:
 ///   top_dir/                     - Process working directory, stdout/stderr logs
 ///   top_dir/work/<app_name>/     - App-specific directory for tmp, cache
 ///   /var/flame/executors/<executor_id>.sock - Socket for gRPC communication
+///   /var/flame/executors/<executor_id>.endpoint - Endpoint published by the service, if not the socket
 /// Cleanup:
 ///   - top_dir: cleaned up only if auto-generated
 ///   - app_dir: always cleaned up
-///   - socket: always cleaned up
+///   - socket and endpoint file: always cleaned up
 pub struct ExecutorWorkDir {
     /// Top-level working directory (process runs here).
     top_dir: PathBuf,
@@ -50,6 +90,8 @@ pub struct ExecutorWorkDir {
     app_dir: PathBuf,
     /// Socket path: /var/flame/executors/<executor_id>.sock
     socket: PathBuf,
+    /// Endpoint file path: /var/flame/executors/<executor_id>.endpoint
+    endpoint_file: PathBuf,
     /// If true, top_dir was auto-generated and should be cleaned up on release.
     auto_dir: bool,
 }
@@ -79,6 +121,7 @@ impl ExecutorWorkDir {
         let app_dir = work_dir.join(&app.name);
         let socket_dir = get_socket_dir();
         let socket = socket_dir.join(format!("{}.sock", executor_id));
+        let endpoint_file = socket_dir.join(format!("{}.endpoint", executor_id));
 
         // Create top_dir if auto-generated
         if auto_dir {
@@ -114,10 +157,16 @@ impl ExecutorWorkDir {
             ))
         })?;
 
+        // The endpoint published by the previous service of the executor.
+        if endpoint_file.exists() {
+            let _ = fs::remove_file(&endpoint_file);
+        }
+
         Ok(Self {
             top_dir,
             app_dir,
             socket,
+            endpoint_file,
             auto_dir,
         })
     }
@@ -135,6 +184,10 @@ impl ExecutorWorkDir {
     pub fn socket(&self) -> &Path {
         &self.socket
     }
+
+    pub fn endpoint_file(&self) -> &Path {
+        &self.endpoint_file
+    }
 }
 
 impl Drop for ExecutorWorkDir {
@@ -151,6 +204,15 @@ impl Drop for ExecutorWorkDir {
                 tracing::debug!("Removed socket file: {}", self.socket.display());
             }
         }
+        if self.endpoint_file.exists() {
+            if let Err(e) = fs::remove_file(&self.endpoint_file) {
+                tracing::warn!(
+                    "Failed to remove endpoint file {}: {}",
+                    self.endpoint_file.display(),
+                    e
+                );
+            }
+        }
 
         // Always cleanup app_dir
         if self.app_dir.exists() {
@@ -203,6 +265,8 @@ pub async fn new(executor: &Executor, app: &ApplicationContext) -> Result<ShimPt
     match shim_type {
         ShimType::Wasm => Ok(WasmShim::new_ptr(executor, app).await?),
         ShimType::Host => Ok(HostShim::new_ptr(executor, app).await?),
+        ShimType::Grpc => Ok(ReflectionShim::new_ptr(executor, app).await?),
+        ShimType::Http => Ok(HttpShim::new_ptr(executor, app).await?),
     }
 }
 
@@ -211,6 +275,21 @@ pub trait Shim: Send + 'static {
     async fn on_session_enter(&mut self, ctx: &SessionContext) -> Result<(), FlameError>;
     async fn on_task_invoke(&mut self, ctx: &TaskContext) -> Result<TaskResult, FlameError>;
     async fn on_session_leave(&mut self) -> Result<(), FlameError>;
+    /// Reset the state of the service after `on_session_leave`; the shim is
+    /// reused by the next session of the same application only if it returns
+    /// true, and it's dropped otherwise.
+    async fn on_session_reset(&mut self) -> Result<bool, FlameError> {
+        Ok(false)
+    }
+    /// Enter the group of the tasks before invoking a task of the group; the
+    /// shims without services ignore the groups.
+    async fn on_group_enter(&mut self, _: &GroupContext) -> Result<(), FlameError> {
+        Ok(())
+    }
+    /// Leave the group before a task of another group or leaving the session.
+    async fn on_group_leave(&mut self, _: &GroupContext) -> Result<(), FlameError> {
+        Ok(())
+    }
 }
 
 #[cfg(test)]
@@ -233,6 +312,8 @@ mod tests {
             working_directory,
             environments: HashMap::new(),
             url: None,
+            output_schema: None,
+            outputs: None,
         }
     }
 
@@ -367,6 +448,36 @@ mod tests {
         );
     }
 
+    struct NoopShim;
+
+    #[async_trait]
+    impl Shim for NoopShim {
+        async fn on_session_enter(&mut self, _: &SessionContext) -> Result<(), FlameError> {
+            Ok(())
+        }
+
+        async fn on_task_invoke(&mut self, _: &TaskContext) -> Result<TaskResult, FlameError> {
+            Err(FlameError::Internal("not invoked".to_string()))
+        }
+
+        async fn on_session_leave(&mut self) -> Result<(), FlameError> {
+            Ok(())
+        }
+    }
+
+    #[test]
+    fn test_warm_shim_reuse() {
+        let app = create_test_app("test-app", None);
+        let shim: ShimPtr = Arc::new(tokio::sync::Mutex::new(NoopShim));
+
+        let warm = WarmShim::new(&app, shim.clone(), Duration::from_secs(60));
+        assert!(warm.clone().reuse(&app).is_some());
+        assert!(warm.reuse(&create_test_app("other-app", None)).is_none());
+
+        let expired = WarmShim::new(&app, shim, Duration::ZERO);
+        assert!(expired.reuse(&app).is_none());
+    }
+
     #[test]
     fn test_socket_path_is_fixed_location() {
         let _guard = TEST_LOCK.lock().unwrap();
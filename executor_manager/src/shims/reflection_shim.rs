@@ -0,0 +1,374 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//! The reflection shim invokes a method of an existing gRPC service as a task,
+//! so the service can join Flame without implementing the Instance API.
+//!
+//! The method is resolved by gRPC server reflection when the session enters;
+//! the task input is a JSON document of the request message, and the task
+//! output is the JSON document of the response message.
+
+use std::collections::HashSet;
+use std::str::FromStr;
+use std::sync::Arc;
+use std::time::Duration;
+
+use async_trait::async_trait;
+use prost::Message;
+use prost_reflect::prost_types::{FileDescriptorProto, FileDescriptorSet};
+use prost_reflect::{DescriptorPool, DynamicMessage, MessageDescriptor, MethodDescriptor};
+use stdng::{logs::TraceFn, trace_fn};
+use tokio::sync::Mutex;
+use tonic::codec::{Codec, DecodeBuf, Decoder, EncodeBuf, Encoder};
+use tonic::codegen::http::uri::PathAndQuery;
+use tonic::transport::{Channel, Endpoint};
+use tonic::{Request, Status};
+use tonic_reflection::pb::v1::server_reflection_client::ServerReflectionClient;
+use tonic_reflection::pb::v1::server_reflection_request::MessageRequest;
+use tonic_reflection::pb::v1::server_reflection_response::MessageResponse;
+use tonic_reflection::pb::v1::ServerReflectionRequest;
+
+use crate::executor::Executor;
+use crate::shims::host_shim::{HostInstance, HostShim};
+use crate::shims::{ExecutorWorkDir, Shim, ShimPtr};
+use common::apis::{
+    ApplicationContext, SessionContext, TaskContext, TaskOutput, TaskResult, TaskState,
+};
+use common::FlameError;
+
+/// The endpoint of the gRPC service, e.g. `http://127.0.0.1:50051`.
+const FLAME_GRPC_ENDPOINT: &str = "FLAME_GRPC_ENDPOINT";
+/// The method to invoke for each task, e.g. `helloworld.Greeter/SayHello`.
+const FLAME_GRPC_METHOD: &str = "FLAME_GRPC_METHOD";
+
+const DEFAULT_GRPC_ENDPOINT: &str = "http://127.0.0.1:50051";
+const CONNECT_TIMEOUT: Duration = Duration::from_secs(30);
+const CONNECT_INTERVAL: Duration = Duration::from_millis(200);
+
+pub struct ReflectionShim {
+    /// The service process, if the application has a command.
+    instance: Option<HostInstance>,
+    work_dir: ExecutorWorkDir,
+    channel: Channel,
+    service: String,
+    method: String,
+    descriptor: Option<MethodDescriptor>,
+}
+
+impl ReflectionShim {
+    pub async fn new_ptr(
+        executor: &Executor,
+        app: &ApplicationContext,
+    ) -> Result<ShimPtr, FlameError> {
+        trace_fn!("ReflectionShim::new_ptr");
+
+        let endpoint = app
+            .environments
+            .get(FLAME_GRPC_ENDPOINT)
+            .cloned()
+            .unwrap_or(DEFAULT_GRPC_ENDPOINT.to_string());
+        let (service, method) = app
+            .environments
+            .get(FLAME_GRPC_METHOD)
+            .ok_or(FlameError::InvalidConfig(format!(
+                "{FLAME_GRPC_METHOD} is required by grpc shim"
+            )))
+            .and_then(|m| parse_method(m))?;
+
+        let work_dir = ExecutorWorkDir::new(app, &executor.id)?;
+
+        // Launch the service if the application has a command; otherwise the
+        // service is expected to be running at the endpoint.
+        let instance = match app.command.as_deref() {
+            Some(cmd) if !cmd.is_empty() => {
+                Some(HostShim::launch_instance(app, executor, &work_dir)?)
+            }
+            _ => None,
+        };
+
+        let channel = connect(&endpoint).await?;
+
+        Ok(Arc::new(Mutex::new(Self {
+            instance,
+            work_dir,
+            channel,
+            service,
+            method,
+            descriptor: None,
+        })))
+    }
+
+    /// Resolve the method by server reflection, including the files it
+    /// depends on.
+    async fn resolve(&self) -> Result<MethodDescriptor, FlameError> {
+        trace_fn!("ReflectionShim::resolve");
+
+        let mut client = ServerReflectionClient::new(self.channel.clone());
+
+        let mut files = vec![];
+        let mut seen = HashSet::new();
+        let mut requests = vec![MessageRequest::FileContainingSymbol(self.service.clone())];
+
+        while let Some(req) = requests.pop() {
+            let req = ServerReflectionRequest {
+                host: String::new(),
+                message_request: Some(req),
+            };
+            let mut stream = client
+                .server_reflection_info(tokio_stream::once(req))
+                .await?
+                .into_inner();
+
+            while let Some(resp) = stream.message().await? {
+                match resp.message_response {
+                    Some(MessageResponse::FileDescriptorResponse(resp)) => {
+                        for data in resp.file_descriptor_proto {
+                            let file = FileDescriptorProto::decode(data.as_slice())
+                                .map_err(|e| FlameError::Internal(e.to_string()))?;
+                            if !seen.insert(file.name().to_string()) {
+                                continue;
+                            }
+                            for dep in &file.dependency {
+                                if !seen.contains(dep) {
+                                    requests.push(MessageRequest::FileByFilename(dep.clone()));
+                                }
+                            }
+                            files.push(file);
+                        }
+                    }
+                    Some(MessageResponse::ErrorResponse(e)) => {
+                        return Err(FlameError::NotFound(format!(
+                            "service <{}>: {}",
+                            self.service, e.error_message
+                        )));
+                    }
+                    _ => {}
+                }
+            }
+        }
+
+        let pool = DescriptorPool::from_file_descriptor_set(FileDescriptorSet { file: files })
+            .map_err(|e| FlameError::Internal(format!("invalid descriptors: {e}")))?;
+
+        let service = pool
+            .get_service_by_name(&self.service)
+            .ok_or(FlameError::NotFound(format!("service <{}>", self.service)))?;
+        let method =
+            service
+                .methods()
+                .find(|m| m.name() == self.method)
+                .ok_or(FlameError::NotFound(format!(
+                    "method <{}/{}>",
+                    self.service, self.method
+                )))?;
+
+        if method.is_client_streaming() || method.is_server_streaming() {
+            return Err(FlameError::InvalidConfig(format!(
+                "streaming method <{}/{}> is not supported",
+                self.service, self.method
+            )));
+        }
+
+        Ok(method)
+    }
+
+    async fn invoke(
+        &self,
+        method: &MethodDescriptor,
+        ctx: &TaskContext,
+    ) -> Result<Option<TaskOutput>, FlameError> {
+        let input = ctx.input.clone().unwrap_or_default();
+        let request = if input.is_empty() {
+            DynamicMessage::new(method.input())
+        } else {
+            let mut de = serde_json::Deserializer::from_slice(&input);
+            DynamicMessage::deserialize(method.input(), &mut de)
+                .map_err(|e| FlameError::InvalidConfig(format!("invalid task input: {e}")))?
+        };
+
+        let path = PathAndQuery::from_str(&format!("/{}/{}", self.service, self.method))
+            .map_err(|e| FlameError::InvalidConfig(e.to_string()))?;
+        let codec = DynamicCodec {
+            output: method.output(),
+        };
+
+        let mut grpc = tonic::client::Grpc::new(self.channel.clone());
+        grpc.ready()
+            .await
+            .map_err(|e| FlameError::Network(format!("service is not ready: {e}")))?;
+
+        let response = grpc
+            .unary(Request::new(request), path, codec)
+            .await?
+            .into_inner();
+
+        let output =
+            serde_json::to_vec(&response).map_err(|e| FlameError::Internal(e.to_string()))?;
+
+        Ok(Some(TaskOutput::from(output)))
+    }
+}
+
+impl Drop for ReflectionShim {
+    fn drop(&mut self) {
+        if let Some(instance) = self.instance.as_mut() {
+            instance.kill_process();
+        }
+    }
+}
+
+#[async_trait]
+impl Shim for ReflectionShim {
+    async fn on_session_enter(&mut self, _: &SessionContext) -> Result<(), FlameError> {
+        trace_fn!("ReflectionShim::on_session_enter");
+
+        if self.descriptor.is_none() {
+            self.descriptor = Some(self.resolve().await?);
+        }
+
+        Ok(())
+    }
+
+    async fn on_task_invoke(&mut self, ctx: &TaskContext) -> Result<TaskResult, FlameError> {
+        trace_fn!("ReflectionShim::on_task_invoke");
+        if let Some(instance) = &self.instance {
+            instance.set_log_scope(Some(&ctx.session_id), Some(&ctx.task_id));
+        }
+
+        let method = self.descriptor.clone().ok_or(FlameError::InvalidState(
+            "method was not resolved".to_string(),
+        ))?;
+
+        match self.invoke(&method, ctx).await {
+            Ok(output) => Ok(TaskResult {
+                state: TaskState::Succeed,
+                output,
+                message: None,
+                output_ref: None,
+            }),
+            Err(e) => {
+                tracing::error!("Task failed: {e}");
+                Ok(TaskResult {
+                    state: TaskState::Failed,
+                    output: None,
+                    message: Some(e.to_string()),
+                    output_ref: None,
+                })
+            }
+        }
+    }
+
+    async fn on_session_leave(&mut self) -> Result<(), FlameError> {
+        trace_fn!("ReflectionShim::on_session_leave");
+        if let Some(instance) = &self.instance {
+            instance.set_log_scope(None, None);
+        }
+
+        Ok(())
+    }
+}
+
+/// Parse the method as `<package>.<Service>/<Method>`.
+fn parse_method(method: &str) -> Result<(String, String), FlameError> {
+    match method.trim_start_matches('/').split_once('/') {
+        Some((svc, m)) if !svc.is_empty() && !m.is_empty() && !m.contains('/') => {
+            Ok((svc.to_string(), m.to_string()))
+        }
+        _ => Err(FlameError::InvalidConfig(format!(
+            "invalid method <{method}>, expected <package.Service/Method>"
+        ))),
+    }
+}
+
+/// Connect to the service, waiting for it to be started.
+async fn connect(endpoint: &str) -> Result<Channel, FlameError> {
+    let builder = Endpoint::from_shared(endpoint.to_string())
+        .map_err(|_| FlameError::InvalidConfig(format!("invalid endpoint <{endpoint}>")))?;
+
+    let deadline = tokio::time::Instant::now() + CONNECT_TIMEOUT;
+    loop {
+        match builder.connect().await {
+            Ok(channel) => return Ok(channel),
+            Err(e) if tokio::time::Instant::now() >= deadline => {
+                return Err(FlameError::Network(format!(
+                    "failed to connect to service at <{endpoint}>: {e}"
+                )));
+            }
+            Err(_) => tokio::time::sleep(CONNECT_INTERVAL).await,
+        }
+    }
+}
+
+struct DynamicCodec {
+    output: MessageDescriptor,
+}
+
+impl Codec for DynamicCodec {
+    type Encode = DynamicMessage;
+    type Decode = DynamicMessage;
+    type Encoder = DynamicEncoder;
+    type Decoder = DynamicDecoder;
+
+    fn encoder(&mut self) -> Self::Encoder {
+        DynamicEncoder
+    }
+
+    fn decoder(&mut self) -> Self::Decoder {
+        DynamicDecoder(self.output.clone())
+    }
+}
+
+struct DynamicEncoder;
+
+impl Encoder for DynamicEncoder {
+    type Item = DynamicMessage;
+    type Error = Status;
+
+    fn encode(&mut self, item: Self::Item, dst: &mut EncodeBuf<'_>) -> Result<(), Self::Error> {
+        item.encode(dst)
+            .map_err(|e| Status::internal(format!("failed to encode request: {e}")))
+    }
+}
+
+struct DynamicDecoder(MessageDescriptor);
+
+impl Decoder for DynamicDecoder {
+    type Item = DynamicMessage;
+    type Error = Status;
+
+    fn decode(&mut self, src: &mut DecodeBuf<'_>) -> Result<Option<Self::Item>, Self::Error> {
+        DynamicMessage::decode(self.0.clone(), src)
+            .map(Some)
+            .map_err(|e| Status::internal(format!("failed to decode response: {e}")))
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_parse_method() {
+        let (svc, m) = parse_method("helloworld.Greeter/SayHello").unwrap();
+        assert_eq!(svc, "helloworld.Greeter");
+        assert_eq!(m, "SayHello");
+
+        let (svc, m) = parse_method("/helloworld.Greeter/SayHello").unwrap();
+        assert_eq!(svc, "helloworld.Greeter");
+        assert_eq!(m, "SayHello");
+
+        assert!(parse_method("helloworld.Greeter").is_err());
+        assert!(parse_method("helloworld.Greeter/").is_err());
+        assert!(parse_method("a/b/c").is_err());
+    }
+}
@@ -0,0 +1,161 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//! The validation of the task outputs by the output schema of the application,
+//! so the outputs violating the schema, e.g. by a bug of the service, fail the
+//! task with the violations instead of failing the clients later.
+//!
+//! The outputs are validated as JSON; the succeed task with no output is not
+//! validated.
+
+use std::sync::Arc;
+
+use jsonschema::Validator;
+
+use common::apis::{TaskResult, TaskState};
+use common::FlameError;
+
+/// The violations reported in the message of the failed task.
+const MAX_REPORTED_VIOLATIONS: usize = 5;
+
+pub type OutputSchemaPtr = Arc<OutputSchema>;
+
+pub struct OutputSchema {
+    application: String,
+    validator: Validator,
+}
+
+impl OutputSchema {
+    /// Compile the output schema of the application; the schema is validated
+    /// by the session manager when the application is registered.
+    pub fn new(application: &str, schema: &str) -> Result<OutputSchemaPtr, FlameError> {
+        let schema: serde_json::Value = serde_json::from_str(schema).map_err(|e| {
+            FlameError::InvalidConfig(format!(
+                "invalid output schema of application <{application}>: {e}"
+            ))
+        })?;
+        let validator = jsonschema::validator_for(&schema).map_err(|e| {
+            FlameError::InvalidConfig(format!(
+                "invalid output schema of application <{application}>: {e}"
+            ))
+        })?;
+
+        Ok(Arc::new(Self {
+            application: application.to_string(),
+            validator,
+        }))
+    }
+
+    /// Fail the succeed task if its output violates the schema; the other
+    /// results are returned as is.
+    pub fn validate(&self, result: TaskResult) -> TaskResult {
+        if result.state != TaskState::Succeed {
+            return result;
+        }
+        let Some(output) = result.output.as_ref() else {
+            return result;
+        };
+
+        let violations = match serde_json::from_slice::<serde_json::Value>(output) {
+            Ok(output) => self
+                .validator
+                .iter_errors(&output)
+                .map(|e| {
+                    let path = e.instance_path.to_string();
+                    if path.is_empty() {
+                        e.to_string()
+                    } else {
+                        format!("{path}: {e}")
+                    }
+                })
+                .collect::<Vec<_>>(),
+            Err(e) => vec![format!("the output is not JSON: {e}")],
+        };
+
+        if violations.is_empty() {
+            return result;
+        }
+
+        let mut message = format!(
+            "the output violates the schema of application <{}>: {}",
+            self.application,
+            violations
+                .iter()
+                .take(MAX_REPORTED_VIOLATIONS)
+                .cloned()
+                .collect::<Vec<_>>()
+                .join("; ")
+        );
+        if violations.len() > MAX_REPORTED_VIOLATIONS {
+            message.push_str(&format!(
+                "; and {} more",
+                violations.len() - MAX_REPORTED_VIOLATIONS
+            ));
+        }
+
+        TaskResult {
+            state: TaskState::Failed,
+            output: None,
+            message: Some(message),
+            output_ref: None,
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use common::apis::TaskOutput;
+
+    fn task_result(output: &str) -> TaskResult {
+        TaskResult {
+            state: TaskState::Succeed,
+            output: Some(TaskOutput::from(output.to_string())),
+            message: None,
+            output_ref: None,
+        }
+    }
+
+    #[test]
+    fn test_validate_output() {
+        let schema = OutputSchema::new(
+            "pi",
+            r#"{"type": "object", "properties": {"area": {"type": "number"}}, "required": ["area"]}"#,
+        )
+        .unwrap();
+
+        let result = schema.validate(task_result(r#"{"area": 3.14}"#));
+        assert_eq!(result.state, TaskState::Succeed);
+        assert!(result.output.is_some());
+
+        let result = schema.validate(task_result(r#"{"area": "3.14"}"#));
+        assert_eq!(result.state, TaskState::Failed);
+        assert!(result.output.is_none());
+        let message = result.message.unwrap();
+        assert!(message.contains("application <pi>"));
+        assert!(message.contains("/area"));
+
+        let result = schema.validate(task_result("3.14"));
+        assert_eq!(result.state, TaskState::Failed);
+
+        let result = schema.validate(task_result("<html>"));
+        assert!(result.message.unwrap().contains("not JSON"));
+
+        // The failed task is reported as is.
+        let mut failed = task_result("panic");
+        failed.state = TaskState::Failed;
+        assert_eq!(schema.validate(failed).message, None);
+
+        assert!(OutputSchema::new("pi", "{").is_err());
+    }
+}
@@ -0,0 +1,167 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//! The stack sampling of the slow tasks: when a task runs longer than the
+//! threshold of `executors.slow_tasks`, the stacks of the service process are
+//! fetched from its debug endpoint, and attached to the task as an event, so
+//! the hung or slow tasks are diagnosed without attaching to the process.
+//!
+//! The endpoint is configured by the environments of the application:
+//!   FLAME_STACK_ENDPOINT - the HTTP endpoint returning the stacks as text, e.g.
+//!                          "http://127.0.0.1:6060/debug/pprof/goroutine?debug=2"
+//!
+//! The stacks are sampled once per task.
+
+use std::collections::HashMap;
+use std::time::Duration;
+
+use reqwest::Client;
+
+use common::ctx::FlameSlowTasks;
+use common::FlameError;
+
+const FLAME_STACK_ENDPOINT: &str = "FLAME_STACK_ENDPOINT";
+
+/// The timeout of fetching the stacks; the service may be busy.
+const SAMPLE_TIMEOUT_SECS: u64 = 10;
+
+#[derive(Clone, Debug)]
+pub struct StackSampler {
+    endpoint: String,
+    threshold: Duration,
+    max_size: usize,
+}
+
+impl StackSampler {
+    /// Build the sampler of the application; None if the slow tasks are not
+    /// configured or the application does not define a stack endpoint.
+    pub fn new(conf: Option<&FlameSlowTasks>, envs: &HashMap<String, String>) -> Option<Self> {
+        let conf = conf?;
+        let endpoint = envs
+            .get(FLAME_STACK_ENDPOINT)
+            .filter(|endpoint| !endpoint.is_empty())?;
+
+        Some(Self {
+            endpoint: endpoint.clone(),
+            threshold: Duration::from_secs(conf.threshold),
+            max_size: conf.max_size as usize,
+        })
+    }
+
+    /// The running time after which the task is slow.
+    pub fn threshold(&self) -> Duration {
+        self.threshold
+    }
+
+    /// Fetch the stacks of the service, and build the annotation of the slow
+    /// task; the failure of fetching is reported in the annotation.
+    pub async fn sample(&self) -> String {
+        match self.fetch().await {
+            Ok(stacks) => annotation(self.threshold, &stacks, self.max_size),
+            Err(e) => format!(
+                "Task is running longer than {}s; failed to sample the stacks of the service: {e}",
+                self.threshold.as_secs()
+            ),
+        }
+    }
+
+    async fn fetch(&self) -> Result<String, FlameError> {
+        let client = Client::builder()
+            .timeout(Duration::from_secs(SAMPLE_TIMEOUT_SECS))
+            .no_proxy()
+            .build()
+            .map_err(network_error)?;
+
+        let resp = client
+            .get(&self.endpoint)
+            .send()
+            .await
+            .map_err(network_error)?
+            .error_for_status()
+            .map_err(network_error)?;
+
+        resp.text().await.map_err(network_error)
+    }
+}
+
+/// The annotation of the slow task with its stacks, which are truncated to
+/// `max_size` bytes at a char boundary.
+fn annotation(threshold: Duration, stacks: &str, max_size: usize) -> String {
+    let mut annotation = format!(
+        "Task is running longer than {}s; stacks of the service:\n",
+        threshold.as_secs()
+    );
+
+    if stacks.len() <= max_size {
+        annotation.push_str(stacks);
+        return annotation;
+    }
+
+    let mut end = max_size;
+    while !stacks.is_char_boundary(end) {
+        end -= 1;
+    }
+    annotation.push_str(&stacks[..end]);
+    annotation.push_str(&format!("\n... truncated {} bytes", stacks.len() - end));
+
+    annotation
+}
+
+fn network_error(e: reqwest::Error) -> FlameError {
+    FlameError::Network(e.to_string())
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_stack_sampler_new() {
+        let conf = FlameSlowTasks {
+            threshold: 60,
+            max_size: 1024,
+        };
+        let envs = HashMap::from([(
+            FLAME_STACK_ENDPOINT.to_string(),
+            "http://127.0.0.1:6060/debug/pprof/goroutine?debug=2".to_string(),
+        )]);
+
+        let sampler = StackSampler::new(Some(&conf), &envs).unwrap();
+        assert_eq!(sampler.threshold(), Duration::from_secs(60));
+        assert_eq!(sampler.max_size, 1024);
+
+        assert!(StackSampler::new(None, &envs).is_none());
+        assert!(StackSampler::new(Some(&conf), &HashMap::new()).is_none());
+        let envs = HashMap::from([(FLAME_STACK_ENDPOINT.to_string(), String::new())]);
+        assert!(StackSampler::new(Some(&conf), &envs).is_none());
+    }
+
+    #[test]
+    fn test_annotation() {
+        let threshold = Duration::from_secs(60);
+
+        let sampled = annotation(threshold, "goroutine 1 [running]", 1024);
+        assert!(sampled.starts_with("Task is running longer than 60s"));
+        assert!(sampled.ends_with("goroutine 1 [running]"));
+
+        let stacks = "x".repeat(100);
+        let truncated = annotation(threshold, &stacks, 10);
+        assert!(truncated.contains(&"x".repeat(10)));
+        assert!(!truncated.contains(&"x".repeat(11)));
+        assert!(truncated.ends_with("truncated 90 bytes"));
+
+        // The stacks are truncated at a char boundary.
+        let truncated = annotation(threshold, "ééé", 3);
+        assert!(truncated.ends_with("é\n... truncated 4 bytes"));
+    }
+}
@@ -132,6 +132,7 @@ impl Shim for WasmShim {
                 state: apis::TaskState::Succeed,
                 output: output.map(apis::TaskOutput::from),
                 message: None,
+                output_ref: None,
             }),
             Err(e) => {
                 tracing::error!("Task failed: {}", e.message);
@@ -139,6 +140,7 @@ impl Shim for WasmShim {
                     state: apis::TaskState::Failed,
                     output: None,
                     message: Some(e.message),
+                    output_ref: None,
                 })
             }
         }
@@ -36,6 +36,8 @@ impl State for BindingState {
             self.executor.id
         );
 
+        // It's a reset for the recovery instead of a transition, so it's not
+        // checked by the transition table.
         self.executor.state = ExecutorState::Idle;
         self.executor.session = None;
 
@@ -11,13 +11,19 @@ See the License for the specific language governing permissions and
 limitations under the License.
 */
 
+use std::time::Duration;
+
 use async_trait::async_trait;
-use stdng::{logs::TraceFn, trace_fn};
+use stdng::{lock_ptr, logs::TraceFn, trace_fn};
 
 use crate::client::BackendClient;
 use crate::executor::Executor;
+use crate::results;
+use crate::shims::stacks::StackSampler;
+use crate::shims::Shim;
 use crate::states::State;
-use common::apis::ExecutorState;
+use common::apis::executor::{transit, ExecutorEvent};
+use common::apis::{GroupContext, TaskContext, TaskResult, TaskState};
 use common::FlameError;
 
 #[derive(Clone)]
@@ -31,11 +37,47 @@ impl State for BoundState {
     async fn execute(&mut self) -> Result<Executor, FlameError> {
         trace_fn!("BoundState::execute");
 
-        let task = self.client.launch_task(&self.executor.clone()).await?;
-        self.executor.task = task.clone();
+        // The task piggybacked on the completion of the previous one was
+        // launched already, so run it before draining or unbinding.
+        let next_task = self.executor.next_task.take();
+
+        // Stop pulling tasks when draining; the in-flight task was completed.
+        if next_task.is_none() && self.executor.draining {
+            tracing::info!(
+                "Executor <{}> is draining, start to unbind.",
+                &self.executor.id
+            );
+            self.executor.state = transit(self.executor.state, ExecutorEvent::Unbind)?;
+            return Ok(self.executor.clone());
+        }
+
+        // Unbind from the session if its service is unhealthy, so the dead
+        // service is replaced before the next task.
+        if let Some(reason) = self.unhealthy().filter(|_| next_task.is_none()) {
+            tracing::warn!(
+                "Executor <{}> is unhealthy, start to unbind: {reason}",
+                &self.executor.id
+            );
+            self.executor.state = transit(self.executor.state, ExecutorEvent::Unbind)?;
+            return Ok(self.executor.clone());
+        }
+
+        let mut task = match next_task {
+            Some(task) => Some(task),
+            None => self.client.launch_task(&self.executor.clone()).await?,
+        };
+        // Stamp the content type of the session on the task.
+        if let Some((task_ctx, _)) = task.as_mut() {
+            task_ctx.content_type = self
+                .executor
+                .session
+                .as_ref()
+                .and_then(|ssn| ssn.content_type.clone());
+        }
+        self.executor.task = task.as_ref().map(|(task_ctx, _)| task_ctx.clone());
 
         match task {
-            Some(task_ctx) => {
+            Some((task_ctx, lease)) => {
                 let shim_ptr =
                     &mut self
                         .executor
@@ -44,14 +86,147 @@ impl State for BoundState {
                         .ok_or(FlameError::InvalidState(
                             "no shim instance in bound state".to_string(),
                         ))?;
-                let task_result = {
+                // Keep renewing the lease while the task is running.
+                let keeper = lease.map(|lease| {
+                    tokio::spawn(keep_task_lease(
+                        self.client.clone(),
+                        self.executor.clone(),
+                        task_ctx.clone(),
+                        lease,
+                    ))
+                });
+                // Sample the stacks of the service if the task is slow.
+                let sampler = self.stack_sampler().map(|sampler| {
+                    tokio::spawn(sample_slow_task(
+                        self.client.clone(),
+                        self.executor.clone(),
+                        task_ctx.clone(),
+                        sampler,
+                    ))
+                });
+                // The task is failed without invoking the service if its
+                // deadline was exceeded, e.g. while it was pending.
+                let task_result = if task_ctx.is_expired() {
+                    Ok(deadline_exceeded(&task_ctx, "the service was not invoked"))
+                } else {
                     let mut shim = shim_ptr.lock().await;
-                    shim.on_task_invoke(&task_ctx).await?
+                    match enter_group(&mut self.executor.group, &mut *shim, &task_ctx).await {
+                        Ok(()) => shim.on_task_invoke(&task_ctx).await,
+                        Err(e) => Ok(group_failed(&task_ctx, e)),
+                    }
+                };
+                if let Some(keeper) = keeper {
+                    keeper.abort();
+                }
+                if let Some(sampler) = sampler {
+                    sampler.abort();
+                }
+                let mut task_result = match task_result {
+                    // The shims abort the invocation by the deadline.
+                    Err(e) if task_ctx.is_expired() => deadline_exceeded(&task_ctx, &e.to_string()),
+                    // The service crashed during the task.
+                    Err(e) => {
+                        if let Some(ssn) = &self.executor.session {
+                            self.executor.record_crash(ssn);
+                        }
+                        return Err(e);
+                    }
+                    Ok(task_result) => task_result,
                 };
 
-                self.client
-                    .complete_task(&self.executor.clone(), &task_result)
-                    .await?;
+                // Fail the task if its output violates the schema of the application.
+                if let Some(schema) = &self.executor.output_schema {
+                    task_result = schema.validate(task_result);
+                }
+
+                // Fail the task if it exceeds the size limit of scratch directory.
+                if let Some(scratch) = &self.executor.scratch {
+                    if let Err(e) = scratch.check_usage() {
+                        tracing::warn!(
+                            "Task <{}/{}> failed: {e}",
+                            task_ctx.session_id,
+                            task_ctx.task_id
+                        );
+                        task_result = TaskResult {
+                            state: TaskState::Failed,
+                            output: None,
+                            message: Some(e.to_string()),
+                            output_ref: None,
+                        };
+                    }
+                }
+
+                // Keep the output on the host for the clients of local mode.
+                if let Some(conf) = self
+                    .executor
+                    .context
+                    .as_ref()
+                    .and_then(|ctx| ctx.cluster.executors.local_results.as_ref())
+                {
+                    let client_host = self
+                        .executor
+                        .session
+                        .as_ref()
+                        .and_then(|ssn| ssn.client_host.as_deref());
+                    task_result = results::offload(
+                        conf,
+                        client_host,
+                        &task_ctx.session_id,
+                        &task_ctx.task_id,
+                        task_result,
+                    );
+                }
+
+                // Send the repeated output as the reference to the task of
+                // its first occurrence in the session.
+                let mut digest = match &self.executor.outputs {
+                    Some(outputs) => {
+                        let (result, digest) = lock_ptr!(outputs)?.dedup(task_result);
+                        task_result = result;
+                        digest
+                    }
+                    None => None,
+                };
+
+                // Take the next pending task on the completion, unless the
+                // executor is going to unbind before the next task.
+                let next_task = if self.executor.draining || self.unhealthy().is_some() {
+                    self.client
+                        .complete_task(&self.executor.clone(), &task_result)
+                        .await
+                        .map(|_| None)
+                } else {
+                    self.client
+                        .complete_and_launch_task(&self.executor.clone(), &task_result)
+                        .await
+                };
+                // The output is corrupted on the way to the session manager,
+                // so the task is failed instead of the executor.
+                let next_task = match next_task {
+                    Err(FlameError::Integrity(msg)) => {
+                        tracing::error!(
+                            "Failed to complete task <{}/{}>: {msg}",
+                            task_ctx.session_id,
+                            task_ctx.task_id
+                        );
+                        digest = None;
+                        let task_result = TaskResult {
+                            state: TaskState::Failed,
+                            output: None,
+                            message: Some(msg),
+                            output_ref: None,
+                        };
+                        self.client
+                            .complete_task(&self.executor.clone(), &task_result)
+                            .await
+                            .map(|_| None)
+                    }
+                    next_task => next_task,
+                };
+                self.executor.next_task = next_task?;
+                if let (Some(outputs), Some(digest)) = (&self.executor.outputs, digest) {
+                    lock_ptr!(outputs)?.record(digest, &task_ctx.task_id);
+                }
 
                 let (ssn_id, task_id) = {
                     let task = &self.executor.task.clone().unwrap();
@@ -60,7 +235,7 @@ impl State for BoundState {
                 tracing::debug!("Complete task <{ssn_id}/{task_id}>")
             }
             None => {
-                self.executor.state = ExecutorState::Unbinding;
+                self.executor.state = transit(self.executor.state, ExecutorEvent::Unbind)?;
             }
         }
 
@@ -69,3 +244,245 @@ impl State for BoundState {
         Ok(self.executor.clone())
     }
 }
+
+impl BoundState {
+    /// The reason of the failed health probe of the service, if any.
+    fn unhealthy(&self) -> Option<String> {
+        self.executor.health.as_ref().and_then(|h| h.failure())
+    }
+
+    fn stack_sampler(&self) -> Option<StackSampler> {
+        let conf = self
+            .executor
+            .context
+            .as_ref()
+            .and_then(|ctx| ctx.cluster.executors.slow_tasks.as_ref());
+        let ssn = self.executor.session.as_ref()?;
+
+        StackSampler::new(conf, &ssn.application.environments)
+    }
+}
+
+/// Move the service into the group of the task, leaving the group of the
+/// previous task first; it's a no-op if the task is in the same group.
+async fn enter_group(
+    current: &mut Option<String>,
+    shim: &mut dyn Shim,
+    task: &TaskContext,
+) -> Result<(), FlameError> {
+    if *current == task.group {
+        return Ok(());
+    }
+
+    if let Some(name) = current.take() {
+        shim.on_group_leave(&GroupContext {
+            session_id: task.session_id.clone(),
+            name,
+        })
+        .await?;
+    }
+    if let Some(name) = &task.group {
+        shim.on_group_enter(&GroupContext {
+            session_id: task.session_id.clone(),
+            name: name.clone(),
+        })
+        .await?;
+        *current = Some(name.clone());
+    }
+
+    Ok(())
+}
+
+/// The result of the task whose group was not entered by the service.
+fn group_failed(task: &TaskContext, e: FlameError) -> TaskResult {
+    tracing::warn!(
+        "Task <{}/{}> failed to enter group <{}>: {e}",
+        task.session_id,
+        task.task_id,
+        task.group.as_deref().unwrap_or_default()
+    );
+
+    TaskResult {
+        state: TaskState::Failed,
+        output: None,
+        message: Some(format!("failed to enter the group of the task: {e}")),
+        output_ref: None,
+    }
+}
+
+/// The result of the task which exceeded its deadline.
+fn deadline_exceeded(task: &TaskContext, reason: &str) -> TaskResult {
+    tracing::warn!(
+        "Task <{}/{}> exceeded its deadline: {reason}",
+        task.session_id,
+        task.task_id
+    );
+
+    TaskResult {
+        state: TaskState::Failed,
+        output: None,
+        message: Some(format!("task exceeded its deadline: {reason}")),
+        output_ref: None,
+    }
+}
+
+/// Attach the stacks of the service to the task once it runs longer than the
+/// threshold; it's aborted when the task is completed.
+async fn sample_slow_task(
+    mut client: BackendClient,
+    executor: Executor,
+    task: TaskContext,
+    sampler: StackSampler,
+) {
+    tokio::time::sleep(sampler.threshold()).await;
+
+    tracing::warn!(
+        "Task <{}/{}> is running longer than {}s, sample the stacks of the service.",
+        task.session_id,
+        task.task_id,
+        sampler.threshold().as_secs()
+    );
+    let annotation = sampler.sample().await;
+    if let Err(e) = client.annotate_task(&executor, &task, annotation).await {
+        tracing::warn!(
+            "Failed to annotate task <{}/{}>: {e}",
+            task.session_id,
+            task.task_id
+        );
+    }
+}
+
+/// Renew the lease of the task periodically until it's aborted; the lease is
+/// renewed three times per duration to tolerate a missed renewal.
+async fn keep_task_lease(
+    mut client: BackendClient,
+    executor: Executor,
+    task: TaskContext,
+    lease: Duration,
+) {
+    let mut interval = tokio::time::interval((lease / 3).max(Duration::from_secs(1)));
+    // The lease was started when the task was launched.
+    interval.tick().await;
+
+    loop {
+        interval.tick().await;
+        if let Err(e) = client.renew_task_lease(&executor, &task).await {
+            tracing::warn!(
+                "Failed to renew the lease of task <{}/{}>: {e}",
+                task.session_id,
+                task.task_id
+            );
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use async_trait::async_trait;
+    use common::apis::{ExecutorState, SessionContext};
+
+    use super::*;
+
+    /// The shim recording the group callbacks.
+    #[derive(Default)]
+    struct GroupShim {
+        calls: Vec<String>,
+    }
+
+    #[async_trait]
+    impl Shim for GroupShim {
+        async fn on_session_enter(&mut self, _: &SessionContext) -> Result<(), FlameError> {
+            Ok(())
+        }
+
+        async fn on_task_invoke(&mut self, _: &TaskContext) -> Result<TaskResult, FlameError> {
+            Err(FlameError::Internal("not invoked".to_string()))
+        }
+
+        async fn on_session_leave(&mut self) -> Result<(), FlameError> {
+            Ok(())
+        }
+
+        async fn on_group_enter(&mut self, ctx: &GroupContext) -> Result<(), FlameError> {
+            self.calls.push(format!("enter {}", ctx.name));
+            Ok(())
+        }
+
+        async fn on_group_leave(&mut self, ctx: &GroupContext) -> Result<(), FlameError> {
+            self.calls.push(format!("leave {}", ctx.name));
+            Ok(())
+        }
+    }
+
+    fn task(group: Option<&str>) -> TaskContext {
+        TaskContext {
+            task_id: "1".to_string(),
+            session_id: "ssn-1".to_string(),
+            input: None,
+            principal: None,
+            deadline: None,
+            content_type: None,
+            group: group.map(str::to_string),
+            method: None,
+            priority: None,
+            sequence: None,
+        }
+    }
+
+    #[tokio::test]
+    async fn test_enter_group() {
+        let mut shim = GroupShim::default();
+        let mut current = None;
+
+        for group in [Some("extract"), Some("extract"), Some("load"), None] {
+            enter_group(&mut current, &mut shim, &task(group))
+                .await
+                .unwrap();
+            assert_eq!(current.as_deref(), group);
+        }
+
+        assert_eq!(
+            shim.calls,
+            vec!["enter extract", "leave extract", "enter load", "leave load"]
+        );
+    }
+
+    #[tokio::test]
+    async fn test_enter_group_across_tasks() {
+        let mut shim = GroupShim::default();
+        let mut executor = Executor {
+            id: "exe-1".to_string(),
+            resreq: common::apis::ResourceRequirement::default(),
+            node: "node-1".to_string(),
+            slots: 1,
+            shim: common::apis::Shim::Host,
+            session: None,
+            task: None,
+            next_task: None,
+            group: None,
+            context: None,
+            shim_instance: None,
+            warm_shim: None,
+            scratch: None,
+            health: None,
+            output_schema: None,
+            outputs: None,
+            logs: None,
+            restarts: None,
+            draining: false,
+            state: ExecutorState::Bound,
+        };
+
+        // Each task runs on a clone of the executor, which is updated back
+        // by the runner; the group is entered once for both tasks.
+        for _ in 0..2 {
+            let mut next = executor.clone();
+            enter_group(&mut next.group, &mut shim, &task(Some("extract")))
+                .await
+                .unwrap();
+            executor.update(&next);
+        }
+        assert_eq!(executor.group.as_deref(), Some("extract"));
+        assert_eq!(shim.calls, vec!["enter extract"]);
+    }
+}
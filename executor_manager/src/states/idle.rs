@@ -15,10 +15,16 @@ use async_trait::async_trait;
 use stdng::{logs::TraceFn, new_ptr, trace_fn, MutexPtr};
 
 use crate::client::BackendClient;
+use crate::dedup::OutputDedup;
 use crate::executor::Executor;
+use crate::results;
+use crate::scratch::ScratchDir;
 use crate::shims;
+use crate::shims::health::{HealthCheck, HealthMonitor};
+use crate::shims::schema::OutputSchema;
 use crate::states::State;
-use common::apis::{Event, EventOwner, ExecutorState, Shim};
+use common::apis::executor::{transit, ExecutorEvent};
+use common::apis::{Event, EventOwner, Shim};
 use common::{new_async_ptr, FlameError};
 
 const ON_SESSION_ENTER_MAX_RETRIES: u32 = 5;
@@ -35,16 +41,27 @@ impl State for IdleState {
     async fn execute(&mut self) -> Result<Executor, FlameError> {
         trace_fn!("IdleState::execute");
 
+        if self.executor.draining {
+            tracing::info!(
+                "Executor <{}> is draining, start to release.",
+                &self.executor.id
+            );
+            self.executor.warm_shim = None;
+            self.executor.state = transit(self.executor.state, ExecutorEvent::Release)?;
+            return Ok(self.executor.clone());
+        }
+
         let ssn = self.client.bind_executor(&self.executor.clone()).await?;
 
-        let Some(ssn) = ssn else {
+        let Some(mut ssn) = ssn else {
             tracing::debug!(
                 "Executor <{}> is idle but no session is found, start to release.",
                 &self.executor.id.clone()
             );
 
             self.executor.session = None;
-            self.executor.state = ExecutorState::Releasing;
+            self.executor.warm_shim = None;
+            self.executor.state = transit(self.executor.state, ExecutorEvent::Release)?;
             return Ok(self.executor.clone());
         };
 
@@ -86,7 +103,55 @@ impl State for IdleState {
             &ssn.session_id.clone()
         );
 
-        let shim_ptr = shims::new(&self.executor.clone(), &ssn.application).await?;
+        // Provision the scratch directory before the shim, so the path is
+        // available to the service when the session enters; it's removed on
+        // drop if the binding fails.
+        let scratch = match self
+            .executor
+            .context
+            .as_ref()
+            .and_then(|ctx| ctx.cluster.executors.scratch.as_ref())
+        {
+            Some(conf) => Some(ScratchDir::new(conf, &self.executor.id, &ssn.session_id)?),
+            None => None,
+        };
+        // Remove the outputs of the expired sessions kept on the host.
+        if let Some(conf) = self
+            .executor
+            .context
+            .as_ref()
+            .and_then(|ctx| ctx.cluster.executors.local_results.as_ref())
+        {
+            results::sweep(conf);
+        }
+
+        ssn.scratch_dir = scratch
+            .as_ref()
+            .map(|s| s.path().to_string_lossy().to_string());
+
+        let health_check = HealthCheck::from_envs(&ssn.application.environments)?;
+        // Reuse the service kept warm by the previous session of the
+        // application; the other warm service is stopped.
+        let warm_shim = self
+            .executor
+            .warm_shim
+            .take()
+            .and_then(|warm| warm.reuse(&ssn.application));
+        let shim_ptr = match warm_shim {
+            Some(shim_ptr) => {
+                tracing::debug!(
+                    "Reuse the warm shim of application <{}> for session <{}>.",
+                    ssn.application.name,
+                    ssn.session_id
+                );
+                shim_ptr
+            }
+            // The application backing off its crashes was excluded from
+            // the binding, so its service is started directly.
+            None => shims::new(&self.executor.clone(), &ssn.application)
+                .await
+                .inspect_err(|_| self.executor.record_crash(&ssn))?,
+        };
 
         // Retry on_session_enter with delay between attempts
         let mut last_error: Option<FlameError> = None;
@@ -121,6 +186,7 @@ impl State for IdleState {
                 ON_SESSION_ENTER_MAX_RETRIES,
                 e
             );
+            self.executor.record_crash(&ssn);
             return Err(e);
         }
 
@@ -130,8 +196,23 @@ impl State for IdleState {
 
         // Own the shim instance.
         self.executor.shim_instance = Some(shim_ptr.clone());
+        self.executor.scratch = scratch;
+        self.executor.health =
+            health_check.map(|check| HealthMonitor::start(&ssn.application.name, check));
+        self.executor.output_schema = ssn.application.output_schema.as_ref().and_then(|schema| {
+            OutputSchema::new(&ssn.application.name, schema)
+                .inspect_err(|e| tracing::warn!("The outputs are not validated: {e}"))
+                .ok()
+        });
+        self.executor.outputs = self
+            .executor
+            .context
+            .as_ref()
+            .and_then(|ctx| ctx.cluster.executors.dedup_outputs)
+            .map(OutputDedup::new);
         self.executor.session = Some(ssn.clone());
-        self.executor.state = ExecutorState::Bound;
+        self.executor.state = transit(self.executor.state, ExecutorEvent::BindSession)
+            .and_then(|state| transit(state, ExecutorEvent::BindSessionCompleted))?;
 
         tracing::debug!(
             "Executor <{}> was bound to <{}>.",
@@ -167,7 +248,7 @@ mod tests {
         // Verify the state transition logic is correct by checking the code path:
         // When ssn is None:
         //   self.executor.session = None;
-        //   self.executor.state = ExecutorState::Releasing;
+        //   self.executor.state = transit(self.executor.state, ExecutorEvent::Release)?;
         //   return Ok(self.executor.clone());
 
         // The state machine ensures that:
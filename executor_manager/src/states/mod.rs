@@ -80,8 +80,18 @@ mod tests {
             shim: Shim::Host,
             session: None,
             task: None,
+            next_task: None,
+            group: None,
             context: None,
             shim_instance: None,
+            warm_shim: None,
+            scratch: None,
+            health: None,
+            output_schema: None,
+            outputs: None,
+            logs: None,
+            restarts: None,
+            draining: false,
             state,
         }
     }
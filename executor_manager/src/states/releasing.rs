@@ -17,7 +17,7 @@ use stdng::{logs::TraceFn, trace_fn};
 use crate::client::BackendClient;
 use crate::executor::Executor;
 use crate::states::State;
-use common::apis::ExecutorState;
+use common::apis::executor::{transit, ExecutorEvent};
 use common::FlameError;
 
 #[derive(Clone)]
@@ -36,7 +36,7 @@ impl State for ReleasingState {
             .await?;
 
         // After released, the executor is released now.
-        self.executor.state = ExecutorState::Released;
+        self.executor.state = transit(self.executor.state, ExecutorEvent::Unregister)?;
 
         Ok(self.executor.clone())
     }
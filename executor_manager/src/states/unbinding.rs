@@ -11,13 +11,17 @@ See the License for the specific language governing permissions and
 limitations under the License.
 */
 
+use std::time::Duration;
+
 use async_trait::async_trait;
 use stdng::{logs::TraceFn, trace_fn};
 
 use crate::client::BackendClient;
 use crate::executor::Executor;
+use crate::shims::{ShimPtr, WarmShim};
 use crate::states::State;
-use common::apis::ExecutorState;
+use common::apis::executor::{transit, ExecutorEvent};
+use common::apis::GroupContext;
 use common::FlameError;
 
 #[derive(Clone)]
@@ -31,7 +35,19 @@ impl State for UnbindingState {
     async fn execute(&mut self) -> Result<Executor, FlameError> {
         trace_fn!("UnbindingState::execute");
 
-        self.client.unbind_executor(&self.executor.clone()).await?;
+        let reason = self.executor.health.as_ref().and_then(|h| h.failure());
+        // The unhealthy service is replaced instead of being kept warm.
+        let healthy = reason.is_none();
+        if let Some(ssn) = &self.executor.session {
+            if healthy {
+                self.executor.reset_crashes(&ssn.application.name);
+            } else {
+                self.executor.record_crash(ssn);
+            }
+        }
+        self.client
+            .unbind_executor(&self.executor.clone(), reason)
+            .await?;
         let shim_ptr = &mut self
             .executor
             .shim_instance
@@ -42,9 +58,28 @@ impl State for UnbindingState {
 
         {
             let mut shim = shim_ptr.lock().await;
+            // Leave the group of the last task before leaving the session.
+            if let Some(name) = self.executor.group.take() {
+                let ctx = GroupContext {
+                    session_id: self
+                        .executor
+                        .session
+                        .as_ref()
+                        .map(|ssn| ssn.session_id.clone())
+                        .unwrap_or_default(),
+                    name,
+                };
+                if let Err(e) = shim.on_group_leave(&ctx).await {
+                    tracing::warn!("Failed to leave group <{}>: {e}", ctx.name);
+                }
+            }
             shim.on_session_leave().await?;
         }
 
+        if healthy && !self.executor.draining {
+            self.executor.warm_shim = self.keep_warm(shim_ptr).await;
+        }
+
         self.client
             .unbind_executor_completed(&self.executor.clone())
             .await?;
@@ -52,10 +87,57 @@ impl State for UnbindingState {
         self.executor.task = None;
         self.executor.session = None;
         self.executor.shim_instance = None;
+        if let Some(scratch) = self.executor.scratch.take() {
+            scratch.release();
+        }
+        if let Some(health) = self.executor.health.take() {
+            health.stop();
+        }
+        self.executor.output_schema = None;
+        self.executor.outputs = None;
 
         // After unbound from session, the executor is idle now.
-        self.executor.state = ExecutorState::Idle;
+        self.executor.state = transit(self.executor.state, ExecutorEvent::UnbindCompleted)?;
 
         Ok(self.executor.clone())
     }
 }
+
+impl UnbindingState {
+    /// Keep the shim warm for the next session of the application if it's
+    /// configured, and the service reset its state.
+    async fn keep_warm(&self, shim_ptr: &ShimPtr) -> Option<WarmShim> {
+        let ttl = self
+            .executor
+            .context
+            .as_ref()
+            .and_then(|ctx| ctx.cluster.executors.warm_shim_ttl)?;
+        let app = &self.executor.session.as_ref()?.application;
+
+        let reset = {
+            let mut shim = shim_ptr.lock().await;
+            shim.on_session_reset().await
+        };
+        match reset {
+            Ok(true) => {
+                tracing::debug!(
+                    "Keep the shim of application <{}> warm for {ttl}s.",
+                    app.name
+                );
+                Some(WarmShim::new(
+                    app,
+                    shim_ptr.clone(),
+                    Duration::from_secs(ttl),
+                ))
+            }
+            Ok(false) => None,
+            Err(e) => {
+                tracing::warn!(
+                    "Failed to reset the service of application <{}>: {e}",
+                    app.name
+                );
+                None
+            }
+        }
+    }
+}
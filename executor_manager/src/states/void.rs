@@ -17,7 +17,7 @@ use stdng::{logs::TraceFn, trace_fn};
 use crate::client::BackendClient;
 use crate::executor::Executor;
 use crate::states::State;
-use common::apis::ExecutorState;
+use common::apis::executor::{transit, ExecutorEvent};
 use common::FlameError;
 
 #[derive(Clone)]
@@ -35,7 +35,7 @@ impl State for VoidState {
             .register_executor(&self.executor.clone())
             .await?;
 
-        self.executor.state = ExecutorState::Idle;
+        self.executor.state = transit(self.executor.state, ExecutorEvent::Register)?;
 
         Ok(self.executor.clone())
     }
@@ -17,6 +17,8 @@ limitations under the License.
 //! streaming protocol, including reconnection logic and heartbeat management.
 
 use std::collections::HashMap;
+use std::sync::atomic::{AtomicBool, Ordering};
+use std::sync::Arc;
 use std::time::Duration;
 
 use tokio::sync::mpsc;
@@ -24,7 +26,8 @@ use tokio::time::{interval, timeout};
 use tokio_stream::wrappers::ReceiverStream;
 use tonic::Streaming;
 
-use common::apis::Node;
+use common::apis::{Node, ResourceRequirement};
+use common::ctx::FlameChaos;
 use common::FlameError;
 use rpc::flame::v1 as proto;
 use stdng::{lock_ptr, MutexPtr};
@@ -59,8 +62,13 @@ pub struct StreamHandler {
     node: MutexPtr<Node>,
     /// Reference to current executors (shared with manager) for re-registration
     executors: MutexPtr<HashMap<String, ExecutorPtr>>,
+    /// If the node is draining, no resources are advertised so that no new
+    /// executors are allocated to it.
+    draining: Arc<AtomicBool>,
     reconnect_interval: Duration,
     heartbeat_interval: Duration,
+    /// The node is disconnected randomly by the chaos for the soak tests.
+    chaos: Option<FlameChaos>,
 }
 
 impl StreamHandler {
@@ -70,16 +78,37 @@ impl StreamHandler {
     ///
     /// * `client` - The backend client for gRPC communication
     /// * `executors` - Shared reference to current executors for re-registration on reconnect
-    pub fn new(client: BackendClient, executors: MutexPtr<HashMap<String, ExecutorPtr>>) -> Self {
+    /// * `draining` - Shared flag whether the node is draining
+    pub fn new(
+        client: BackendClient,
+        executors: MutexPtr<HashMap<String, ExecutorPtr>>,
+        draining: Arc<AtomicBool>,
+    ) -> Self {
         StreamHandler {
             client,
             node: stdng::new_ptr(Node::new()),
             executors,
+            draining,
             reconnect_interval: Duration::from_secs(DEFAULT_RECONNECT_INTERVAL_SECS),
             heartbeat_interval: Duration::from_secs(DEFAULT_HEARTBEAT_INTERVAL_SECS),
+            chaos: None,
         }
     }
 
+    /// Set the labels of the node, e.g. from the cloud metadata.
+    pub fn with_labels(self, labels: HashMap<String, String>) -> Self {
+        if let Ok(mut node) = lock_ptr!(self.node) {
+            node.info.labels = labels;
+        }
+        self
+    }
+
+    /// Set the chaos of the cluster, e.g. to disconnect the node randomly.
+    pub fn with_chaos(mut self, chaos: Option<FlameChaos>) -> Self {
+        self.chaos = chaos;
+        self
+    }
+
     /// Runs the stream handler, forwarding executor updates to the manager.
     ///
     /// This method establishes the WatchNode stream and continuously
@@ -190,6 +219,7 @@ impl StreamHandler {
         // Spawn heartbeat task for periodic heartbeats
         let heartbeat_tx = request_tx.clone();
         let node_ptr = self.node.clone();
+        let draining = self.draining.clone();
         let heartbeat_interval = self.heartbeat_interval;
         let heartbeat_handle = tokio::spawn(async move {
             let mut ticker = interval(heartbeat_interval);
@@ -201,6 +231,9 @@ impl StreamHandler {
                     Ok(mut node) => {
                         // Refresh node to get current resource status
                         node.refresh();
+                        if draining.load(Ordering::Relaxed) {
+                            node.allocatable = ResourceRequirement::default();
+                        }
                         let status = proto::NodeStatus {
                             state: proto::NodeState::from(node.state) as i32,
                             capacity: Some(node.capacity.clone().into()),
@@ -248,8 +281,16 @@ impl StreamHandler {
             )
             .await
             {
+                Ok(Ok(Some(_)))
+                    if self
+                        .chaos
+                        .as_ref()
+                        .is_some_and(FlameChaos::disconnect_executor) =>
+                {
+                    return Err(FlameError::Network("disconnected by chaos".to_string()));
+                }
                 Ok(Ok(Some(response))) => {
-                    if let Some(msg) = self.handle_response(response)? {
+                    for msg in self.handle_response(response)? {
                         executor_tx.send(msg).await.map_err(|e| {
                             FlameError::Internal(format!("Failed to send executor: {}", e))
                         })?;
@@ -271,36 +312,44 @@ impl StreamHandler {
 
     /// Handles a single response from the server.
     ///
-    /// Returns an ExecutorMessage if the response contains an executor update,
-    /// which the caller is responsible for forwarding to the manager.
+    /// Returns the ExecutorMessages of the executor updates in the response, in
+    /// order, which the caller is responsible for forwarding to the manager.
     fn handle_response(
         &self,
         response: proto::WatchNodeResponse,
-    ) -> Result<Option<ExecutorMessage>, FlameError> {
-        match response.response {
+    ) -> Result<Vec<ExecutorMessage>, FlameError> {
+        let executors = match response.response {
             Some(proto::watch_node_response::Response::Executor(proto_executor)) => {
-                let executor: Executor = Executor::try_from(&proto_executor)?;
-
-                tracing::debug!(
-                    "WatchNode: Received executor <{}> with state {:?}",
-                    executor.id,
-                    executor.state
-                );
-
-                Ok(Some(ExecutorMessage::Update(executor)))
+                vec![proto_executor]
             }
+            Some(proto::watch_node_response::Response::Executors(list)) => list.executors,
             Some(proto::watch_node_response::Response::Ack(ack)) => {
                 tracing::trace!(
                     "WatchNode: Received acknowledgement with timestamp {}",
                     ack.timestamp
                 );
-                Ok(None)
+                vec![]
             }
             None => {
                 tracing::warn!("WatchNode: Received empty response");
-                Ok(None)
+                vec![]
             }
-        }
+        };
+
+        executors
+            .iter()
+            .map(|proto_executor| {
+                let executor: Executor = Executor::try_from(proto_executor)?;
+
+                tracing::debug!(
+                    "WatchNode: Received executor <{}> with state {:?}",
+                    executor.id,
+                    executor.state
+                );
+
+                Ok(ExecutorMessage::Update(executor))
+            })
+            .collect()
     }
 }
 
@@ -327,6 +376,7 @@ mod tests {
             info: NodeInfo {
                 arch: "x86_64".to_string(),
                 os: "linux".to_string(),
+                ..Default::default()
             },
         };
 
@@ -368,6 +418,7 @@ mod tests {
             info: NodeInfo {
                 arch: "x86_64".to_string(),
                 os: "linux".to_string(),
+                ..Default::default()
             },
         };
 
@@ -400,9 +451,19 @@ mod tests {
             slots: 1,
             session: None,
             task: None,
+            next_task: None,
+            group: None,
             context: None,
             shim: Shim::Host,
             shim_instance: None,
+            warm_shim: None,
+            scratch: None,
+            health: None,
+            output_schema: None,
+            outputs: None,
+            logs: None,
+            restarts: None,
+            draining: false,
             state: ExecutorState::Idle,
         };
 
@@ -31,6 +31,8 @@ pub struct SchemaYaml {
     pub input: Option<String>,
     pub output: Option<String>,
     pub common_data: Option<String>,
+    #[serde(default)]
+    pub content_types: Vec<String>,
 }
 
 #[derive(Debug, Clone, Serialize, Deserialize)]
@@ -63,9 +65,11 @@ impl TryFrom<&ApplicationYaml> for ApplicationAttributes {
         let shim = match yaml.spec.shim.as_deref() {
             Some("Host") | Some("host") | None => Some(Shim::Host),
             Some("Wasm") | Some("wasm") | Some("WASM") => Some(Shim::Wasm),
+            Some("Grpc") | Some("grpc") | Some("gRPC") => Some(Shim::Grpc),
+            Some("Http") | Some("http") | Some("HTTP") => Some(Shim::Http),
             Some(other) => {
                 return Err(FlameError::InvalidConfig(format!(
-                    "Invalid shim value '{}'. Must be 'Host' or 'Wasm'.",
+                    "Invalid shim value '{}'. Must be 'Host', 'Wasm', 'Grpc' or 'Http'.",
                     other
                 )))
             }
@@ -94,6 +98,7 @@ impl From<SchemaYaml> for ApplicationSchema {
             input: schema.input.clone(),
             output: schema.output.clone(),
             common_data: schema.common_data.clone(),
+            content_types: schema.content_types.clone(),
         }
     }
 }
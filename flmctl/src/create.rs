@@ -36,6 +36,8 @@ pub async fn run(
         min_instances: 0,
         max_instances: None,
         batch_size: *batch_size,
+        content_type: None,
+        labels: vec![],
     };
 
     let ssn = conn.create_session(&attr).await?;
@@ -0,0 +1,148 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+use std::error::Error;
+
+use chrono::{DateTime, Duration, Utc};
+use comfy_table::presets::NOTHING;
+use comfy_table::Table;
+use flame_rs as flame;
+use flame_rs::apis::{FlameContext, FlameError};
+use flame_rs::client::Connection;
+
+pub async fn executor(
+    ctx: &FlameContext,
+    executor_id: &str,
+    journal: bool,
+) -> Result<(), Box<dyn Error>> {
+    let current_ctx = ctx.get_current_context()?;
+    let conn = flame::client::connect_with_tls(
+        &current_ctx.cluster.endpoint,
+        current_ctx.cluster.tls.as_ref(),
+    )
+    .await?;
+
+    let executor = conn
+        .list_executor()
+        .await?
+        .into_iter()
+        .find(|executor| executor.id == executor_id);
+
+    match &executor {
+        Some(executor) => {
+            println!("{:<15}{}", "ID:", executor.id);
+            println!("{:<15}{}", "State:", executor.state);
+            println!(
+                "{:<15}{}",
+                "Session:",
+                executor.session_id.as_deref().unwrap_or("-")
+            );
+            println!("{:<15}{}", "Slots:", executor.slots);
+            println!("{:<15}{}", "Node:", executor.node);
+        }
+        // The journal is still kept for the unregistered executors.
+        None if journal => println!("Executor <{executor_id}> is not registered."),
+        None => {
+            return Err(Box::new(FlameError::NotFound(format!(
+                "executor <{executor_id}> not found"
+            ))))
+        }
+    }
+
+    if journal {
+        println!();
+        print_journal(&conn, executor_id).await?;
+    }
+
+    Ok(())
+}
+
+async fn print_journal(conn: &Connection, executor_id: &str) -> Result<(), Box<dyn Error>> {
+    let journal = conn.get_executor_journal(executor_id).await?;
+    if !journal.enabled {
+        println!("The journal is not enabled, set `cluster.journal` of the session manager.");
+        return Ok(());
+    }
+
+    let mut table = Table::new();
+    table
+        .load_preset(NOTHING)
+        .set_header(vec!["Time", "Method", "Kind", "Detail"]);
+
+    for entry in &journal.entries {
+        table.add_row(vec![
+            entry.timestamp.format("%T%.3f").to_string(),
+            entry.method.clone(),
+            entry.kind.clone(),
+            entry.detail.clone(),
+        ]);
+    }
+
+    println!("{table}");
+
+    Ok(())
+}
+
+pub async fn timeline(
+    ctx: &FlameContext,
+    last: u64,
+    until: &Option<String>,
+) -> Result<(), Box<dyn Error>> {
+    let until = match until {
+        Some(until) => DateTime::parse_from_rfc3339(until)
+            .map_err(|e| FlameError::InvalidConfig(format!("invalid until <{until}>: {e}")))?
+            .with_timezone(&Utc),
+        None => Utc::now(),
+    };
+    let since = until - Duration::seconds(last as i64);
+
+    let current_ctx = ctx.get_current_context()?;
+    let conn = flame::client::connect_with_tls(
+        &current_ctx.cluster.endpoint,
+        current_ctx.cluster.tls.as_ref(),
+    )
+    .await?;
+
+    let timeline = conn.get_timeline(Some(since), Some(until)).await?;
+    if !timeline.enabled {
+        println!("The timeline is not enabled, set `cluster.timeline` of the session manager.");
+        return Ok(());
+    }
+
+    let mut table = Table::new();
+    table.load_preset(NOTHING).set_header(vec![
+        "Time",
+        "Pending",
+        "Running",
+        "Sessions",
+        "Executors",
+        "Idle",
+        "Binds",
+    ]);
+
+    for sample in &timeline.samples {
+        table.add_row(vec![
+            sample.timestamp.format("%F %T").to_string(),
+            sample.pending_tasks.to_string(),
+            sample.running_tasks.to_string(),
+            sample.open_sessions.to_string(),
+            sample.executors.to_string(),
+            sample.idle_executors.to_string(),
+            sample.binds.to_string(),
+        ]);
+    }
+
+    println!("{table}");
+
+    Ok(())
+}
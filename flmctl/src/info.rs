@@ -0,0 +1,43 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+use std::error::Error;
+
+use flame_rs as flame;
+use flame_rs::apis::FlameContext;
+
+pub async fn run(ctx: &FlameContext) -> Result<(), Box<dyn Error>> {
+    let current_ctx = ctx.get_current_context()?;
+    let conn = flame::client::connect_with_tls(
+        &current_ctx.cluster.endpoint,
+        current_ctx.cluster.tls.as_ref(),
+    )
+    .await?;
+
+    let rtt = conn.ping().await?;
+    let info = conn.get_server_info().await?;
+
+    println!("{:<18}{}", "Endpoint:", current_ctx.cluster.endpoint);
+    println!("{:<18}{}", "Version:", info.version);
+    println!("{:<18}{rtt:?}", "Round trip:");
+    println!("{:<18}{}", "Features:", info.features.join(", "));
+    println!("{:<18}{} bytes", "Max message:", info.max_message_size);
+    println!("{:<18}{} bytes", "Max input:", info.max_input_size);
+    println!("{:<18}{}", "Max batch:", info.max_create_tasks);
+    let max_sessions = info
+        .max_sessions
+        .map_or("unlimited".to_string(), |max| max.to_string());
+    println!("{:<18}{max_sessions}", "Max sessions:");
+
+    Ok(())
+}
@@ -0,0 +1,202 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+use std::fs;
+use std::path::{Path, PathBuf};
+
+use flame_rs::apis::FlameError;
+
+/// The scaffold of a service: the files by path, whose `{{name}}` and
+/// `{{module}}` are replaced when the scaffold is generated. The `go-service`
+/// is a gRPC service of the grpc shim, invoked by the server reflection.
+struct Template {
+    name: &'static str,
+    files: &'static [(&'static str, &'static str)],
+}
+
+const TEMPLATES: &[Template] = &[Template {
+    name: "go-service",
+    files: &[
+        (
+            "main.go",
+            include_str!("../templates/go-service/main.go.tmpl"),
+        ),
+        (
+            "go.mod",
+            include_str!("../templates/go-service/go.mod.tmpl"),
+        ),
+        (
+            "Makefile",
+            include_str!("../templates/go-service/Makefile.tmpl"),
+        ),
+        (
+            "Dockerfile",
+            include_str!("../templates/go-service/Dockerfile.tmpl"),
+        ),
+        (
+            "flame.yaml",
+            include_str!("../templates/go-service/flame.yaml.tmpl"),
+        ),
+        (
+            "README.md",
+            include_str!("../templates/go-service/README.md.tmpl"),
+        ),
+        (
+            "protos/service.proto",
+            include_str!("../templates/go-service/protos/service.proto.tmpl"),
+        ),
+    ],
+}];
+
+pub async fn run(
+    template: &str,
+    name: &str,
+    module: &Option<String>,
+    dir: &Option<String>,
+) -> Result<(), FlameError> {
+    let template = TEMPLATES
+        .iter()
+        .find(|t| t.name == template)
+        .ok_or_else(|| {
+            FlameError::InvalidConfig(format!(
+                "unknown template <{template}>, must be one of: {}",
+                TEMPLATES
+                    .iter()
+                    .map(|t| t.name)
+                    .collect::<Vec<_>>()
+                    .join(", ")
+            ))
+        })?;
+
+    validate_name(name)?;
+    let module = module.clone().unwrap_or(name.to_string());
+    let dir = dir
+        .as_ref()
+        .map(PathBuf::from)
+        .unwrap_or(PathBuf::from(name));
+
+    let files = render(template, name, &module);
+    write_files(&dir, &files)?;
+
+    println!(
+        "The {} <{}> was generated in <{}>.",
+        template.name,
+        name,
+        dir.display()
+    );
+    println!("Next steps:");
+    println!("    cd {}", dir.display());
+    println!("    make build image");
+    println!("    flmctl push -f flame.yaml");
+
+    Ok(())
+}
+
+/// The name is the application name and the binary name, so it's limited to
+/// the lowercase alphanumerics and '-'.
+fn validate_name(name: &str) -> Result<(), FlameError> {
+    let valid = !name.is_empty()
+        && !name.starts_with('-')
+        && name
+            .chars()
+            .all(|c| c.is_ascii_lowercase() || c.is_ascii_digit() || c == '-');
+
+    if !valid {
+        return Err(FlameError::InvalidConfig(format!(
+            "invalid name <{name}>: only lowercase alphanumerics and '-' are allowed"
+        )));
+    }
+
+    Ok(())
+}
+
+fn render(template: &Template, name: &str, module: &str) -> Vec<(&'static str, String)> {
+    template
+        .files
+        .iter()
+        .map(|(path, content)| {
+            (
+                *path,
+                content
+                    .replace("{{name}}", name)
+                    .replace("{{module}}", module),
+            )
+        })
+        .collect()
+}
+
+fn write_files(dir: &Path, files: &[(&str, String)]) -> Result<(), FlameError> {
+    if dir.exists()
+        && fs::read_dir(dir)
+            .map_err(|e| FlameError::Internal(e.to_string()))?
+            .next()
+            .is_some()
+    {
+        return Err(FlameError::InvalidConfig(format!(
+            "<{}> is not empty",
+            dir.display()
+        )));
+    }
+
+    for (path, content) in files {
+        let path = dir.join(path);
+        if let Some(parent) = path.parent() {
+            fs::create_dir_all(parent).map_err(|e| FlameError::Internal(e.to_string()))?;
+        }
+        fs::write(&path, content).map_err(|e| FlameError::Internal(e.to_string()))?;
+    }
+
+    Ok(())
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_validate_name() {
+        for name in ["echo", "echo-2", "my-service"] {
+            assert!(validate_name(name).is_ok(), "{name}");
+        }
+        for name in ["", "-echo", "Echo", "echo_2", "echo.svc", "../echo"] {
+            assert!(
+                matches!(validate_name(name), Err(FlameError::InvalidConfig(_))),
+                "{name}"
+            );
+        }
+    }
+
+    #[test]
+    fn test_render() {
+        let files = render(&TEMPLATES[0], "echo", "example.com/echo");
+        let file = |path: &str| {
+            files
+                .iter()
+                .find(|(p, _)| *p == path)
+                .map(|(_, content)| content.as_str())
+                .unwrap()
+        };
+
+        assert_eq!(files.len(), TEMPLATES[0].files.len());
+        assert!(files
+            .iter()
+            .all(|(_, content)| !content.contains("{{name}}") && !content.contains("{{module}}")));
+        assert!(file("go.mod").starts_with("module example.com/echo\n"));
+        assert!(file("main.go").contains("pb \"example.com/echo/rpc\""));
+
+        let manifest = file("flame.yaml");
+        assert!(manifest.contains("name: echo\n"));
+        assert!(manifest.contains("shim: grpc\n"));
+        assert!(manifest.contains("FLAME_GRPC_METHOD: service.Service/Invoke\n"));
+    }
+}
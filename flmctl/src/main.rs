@@ -19,16 +19,25 @@ use clap_complete::{generate, Shell};
 use flame_rs::apis::FlameContext;
 
 mod apis;
+mod boost;
 mod close;
 mod create;
+mod debug;
 mod helper;
+mod info;
+mod init;
 mod list;
 mod migrate;
+mod plan;
+mod promote;
+mod push;
 mod register;
+mod run;
 mod unregister;
 mod update;
 mod utils;
 mod view;
+mod watch;
 
 #[derive(Parser)]
 #[command(name = "flmctl")]
@@ -95,6 +104,15 @@ enum Commands {
         #[arg(short, long)]
         session: String,
     },
+    /// Boost the priority of the open session, e.g. to expedite a stuck critical session
+    Boost {
+        /// The id of session
+        #[arg(short, long)]
+        session: String,
+        /// The priority of the session; zero removes the boost
+        #[arg(short, long)]
+        priority: u32,
+    },
     /// Create a session in Flame
     Create {
         /// The name of Application
@@ -107,6 +125,27 @@ enum Commands {
         #[arg(short, long, default_value = "1")]
         batch_size: u32,
     },
+    /// Run a task in the session and wait for its output
+    Run {
+        /// The id of session
+        #[arg(short, long)]
+        session: String,
+        /// The input of the task
+        #[arg(short, long)]
+        input: Option<String>,
+        /// The timeout in seconds to wait for the task
+        #[arg(short, long)]
+        timeout: Option<u64>,
+    },
+    /// Watch the progress of the session until its tasks are completed
+    Watch {
+        /// The id of session
+        #[arg(short, long)]
+        session: String,
+        /// The interval in seconds to refresh the progress
+        #[arg(short, long, default_value = "1")]
+        interval: u64,
+    },
     /// Migrate Flame metadata
     Migrate {
         /// The url of Flame database
@@ -122,12 +161,66 @@ enum Commands {
         #[arg(short, long)]
         file: String,
     },
+    /// Generate the skeleton of a service from a template, e.g. go-service
+    Init {
+        /// The template of the service
+        template: String,
+        /// The name of the application
+        name: String,
+        /// The module path of the service, e.g. for go.mod; the name by default
+        #[arg(short, long)]
+        module: Option<String>,
+        /// The directory of the service; `./<name>` by default
+        #[arg(short, long)]
+        dir: Option<String>,
+    },
+    /// Register the applications in the manifest, or update them if registered
+    Push {
+        /// The manifest of the applications
+        #[arg(short, long, default_value = "flame.yaml")]
+        file: String,
+    },
     /// Unregister the application from Flame
     Unregister {
         /// The name of the application
         #[arg(short, long)]
         application: String,
     },
+    /// Promote the standby session manager to the leader, e.g. the leader is down
+    Promote {
+        /// The endpoint of the standby; the endpoint of the current context by default
+        #[arg(short, long)]
+        endpoint: Option<String>,
+        /// The file of the token of the replication
+        #[arg(short, long)]
+        token_file: String,
+        /// Promote the standby even if the old leader is not fenced, e.g. it's unreachable
+        #[arg(long)]
+        force: bool,
+    },
+    /// Show the version, the features and the limits of the session manager
+    Info,
+    /// Simulate a workload on the executors to plan the capacity
+    Plan {
+        /// The yaml file of the workload
+        #[arg(short, long)]
+        workload: String,
+        /// The executors of the hypothetical fleet; the fleet of the workload or
+        /// the current executors by default
+        #[arg(short, long)]
+        executors: Option<u32>,
+        /// The slots of each executor of the fleet
+        #[arg(short, long)]
+        slots: Option<u32>,
+        /// The target of the p95 queue time of the tasks in seconds
+        #[arg(short, long, default_value = "60")]
+        target_wait: u64,
+    },
+    /// Debug the objects of Flame, e.g. the stuck executors
+    Debug {
+        #[command(subcommand)]
+        command: DebugCommands,
+    },
     /// Generate shell completion scripts
     Completion {
         /// Shell to generate completions for
@@ -136,6 +229,28 @@ enum Commands {
     },
 }
 
+#[derive(Subcommand)]
+enum DebugCommands {
+    /// Show the executor, with the journal of its backend RPCs
+    Executor {
+        /// The id of the executor
+        id: String,
+        /// Dump the journal of the backend RPCs of the executor
+        #[arg(long)]
+        journal: bool,
+    },
+    /// Show the timeline of the scheduler metrics, e.g. for post-mortems
+    Timeline {
+        /// The seconds of the timeline before `--until`
+        #[arg(long, default_value = "3600")]
+        last: u64,
+        /// The end of the timeline in RFC 3339, e.g. "2025-01-01T08:00:00Z";
+        /// now by default
+        #[arg(long)]
+        until: Option<String>,
+    },
+}
+
 #[tokio::main]
 async fn main() -> Result<(), Box<dyn Error>> {
     flame_rs::apis::init_logger()?;
@@ -151,6 +266,7 @@ async fn main() -> Result<(), Box<dyn Error>> {
             node,
         }) => list::run(&ctx, *application, *session, *executor, *node).await?,
         Some(Commands::Close { session }) => close::run(&ctx, session).await?,
+        Some(Commands::Boost { session, priority }) => boost::run(&ctx, session, *priority).await?,
         Some(Commands::Create {
             app,
             slots,
@@ -163,10 +279,39 @@ async fn main() -> Result<(), Box<dyn Error>> {
             node,
             output_format,
         }) => view::run(&ctx, output_format, application, session, task, node).await?,
+        Some(Commands::Run {
+            session,
+            input,
+            timeout,
+        }) => run::run(&ctx, session, input, timeout).await?,
+        Some(Commands::Watch { session, interval }) => watch::run(&ctx, session, *interval).await?,
         Some(Commands::Migrate { url, sql }) => migrate::run(&ctx, url, sql).await?,
         Some(Commands::Register { file }) => register::run(&ctx, file).await?,
+        Some(Commands::Init {
+            template,
+            name,
+            module,
+            dir,
+        }) => init::run(template, name, module, dir).await?,
+        Some(Commands::Push { file }) => push::run(&ctx, file).await?,
         Some(Commands::Unregister { application }) => unregister::run(&ctx, application).await?,
         Some(Commands::Update { application }) => update::run(&ctx, application).await?,
+        Some(Commands::Promote {
+            endpoint,
+            token_file,
+            force,
+        }) => promote::run(&ctx, endpoint, token_file, *force).await?,
+        Some(Commands::Info) => info::run(&ctx).await?,
+        Some(Commands::Plan {
+            workload,
+            executors,
+            slots,
+            target_wait,
+        }) => plan::run(&ctx, workload, *executors, *slots, *target_wait).await?,
+        Some(Commands::Debug { command }) => match command {
+            DebugCommands::Executor { id, journal } => debug::executor(&ctx, id, *journal).await?,
+            DebugCommands::Timeline { last, until } => debug::timeline(&ctx, *last, until).await?,
+        },
         Some(Commands::Completion { shell }) => {
             generate(*shell, &mut Cli::command(), "flmctl", &mut io::stdout());
         }
@@ -0,0 +1,336 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//! The capacity planning of a workload: the tasks of the sessions are
+//! scheduled on the simulated fleet by the fair share of the slots, as the
+//! `fairshare` plugin of the scheduler, to report the queue time of the tasks
+//! and the executors required to keep it under the target, e.g.
+//!
+//!   # workload.yaml
+//!   sessions:
+//!     - name: etl
+//!       slots: 2
+//!       tasks: 1000
+//!       duration: 30     # the seconds of each task
+//!       rate: 5          # the tasks submitted per second, all at once if not set
+//!     - name: report
+//!       tasks: 100
+//!       duration: 120
+//!   fleet:               # the current executors if not set
+//!     executors: 20
+//!     slots: 2
+//!
+//! The simulation is an estimate: the executors are always bound to the
+//! sessions, i.e. without the cost of binding and the gang or the placement of
+//! the nodes.
+
+use std::cmp::Reverse;
+use std::collections::{BinaryHeap, VecDeque};
+use std::error::Error;
+use std::fs;
+
+use comfy_table::presets::NOTHING;
+use comfy_table::Table;
+use serde_derive::Deserialize;
+
+use flame_rs as flame;
+use flame_rs::apis::{FlameContext, FlameError};
+
+fn default_slots() -> u32 {
+    1
+}
+
+#[derive(Debug, Clone, Deserialize)]
+struct WorkloadYaml {
+    sessions: Vec<SessionWorkloadYaml>,
+    #[serde(default)]
+    fleet: Option<FleetYaml>,
+}
+
+#[derive(Debug, Clone, Deserialize)]
+struct SessionWorkloadYaml {
+    name: String,
+    #[serde(default = "default_slots")]
+    slots: u32,
+    tasks: u32,
+    /// The seconds of each task.
+    duration: f64,
+    /// The tasks submitted per second; all the tasks are submitted at once
+    /// if not set.
+    #[serde(default)]
+    rate: Option<f64>,
+}
+
+#[derive(Debug, Clone, Deserialize)]
+struct FleetYaml {
+    executors: u32,
+    #[serde(default = "default_slots")]
+    slots: u32,
+}
+
+/// The queue time of the tasks of a session in milliseconds.
+#[derive(Debug, Default)]
+struct SessionReport {
+    waits: Vec<u64>,
+    /// The time the last task of the session was completed.
+    completion: u64,
+}
+
+impl SessionReport {
+    fn mean(&self) -> u64 {
+        if self.waits.is_empty() {
+            return 0;
+        }
+        self.waits.iter().sum::<u64>() / self.waits.len() as u64
+    }
+
+    fn p95(&self) -> u64 {
+        percentile(&self.waits, 0.95)
+    }
+}
+
+fn percentile(waits: &[u64], p: f64) -> u64 {
+    if waits.is_empty() {
+        return 0;
+    }
+    let mut waits = waits.to_vec();
+    waits.sort_unstable();
+    let i = ((waits.len() as f64 * p).ceil() as usize).clamp(1, waits.len()) - 1;
+    waits[i]
+}
+
+pub async fn run(
+    ctx: &FlameContext,
+    workload: &str,
+    executors: Option<u32>,
+    executor_slots: Option<u32>,
+    target_wait: u64,
+) -> Result<(), Box<dyn Error>> {
+    let contents = fs::read_to_string(workload)
+        .map_err(|e| FlameError::InvalidConfig(format!("failed to read <{workload}>: {e}")))?;
+    let workload: WorkloadYaml = serde_yaml::from_str(&contents)
+        .map_err(|e| FlameError::InvalidConfig(format!("invalid workload: {e}")))?;
+    validate(&workload)?;
+
+    // The fleet of the flags, then of the workload, then the current one.
+    let (count, slots) = match (executors, &workload.fleet) {
+        (Some(count), fleet) => (
+            count,
+            executor_slots.unwrap_or(fleet.as_ref().map_or(1, |f| f.slots)),
+        ),
+        (None, Some(fleet)) => (fleet.executors, executor_slots.unwrap_or(fleet.slots)),
+        (None, None) => current_fleet(ctx, executor_slots).await?,
+    };
+    let slots = slots.max(1);
+
+    println!("Fleet: {count} executors of {slots} slots; target queue time: {target_wait}s\n");
+    let reports = simulate(&workload.sessions, count * slots)?;
+    print_reports(&workload.sessions, &reports);
+
+    match required_executors(&workload.sessions, slots, target_wait * 1000)? {
+        Some(required) => println!(
+            "\nRequired: {required} executors of {slots} slots for the p95 queue time under {target_wait}s."
+        ),
+        None => println!("\nRequired: the queue time can't be under {target_wait}s by more executors."),
+    }
+
+    Ok(())
+}
+
+fn validate(workload: &WorkloadYaml) -> Result<(), FlameError> {
+    if workload.sessions.is_empty() {
+        return Err(FlameError::InvalidConfig(
+            "no session in the workload".to_string(),
+        ));
+    }
+    for ssn in &workload.sessions {
+        if ssn.slots == 0 || !ssn.duration.is_finite() || ssn.duration < 0.0 {
+            return Err(FlameError::InvalidConfig(format!(
+                "invalid slots or duration of session <{}>",
+                ssn.name
+            )));
+        }
+        if ssn
+            .rate
+            .is_some_and(|rate| !rate.is_finite() || rate <= 0.0)
+        {
+            return Err(FlameError::InvalidConfig(format!(
+                "invalid rate of session <{}>",
+                ssn.name
+            )));
+        }
+    }
+
+    Ok(())
+}
+
+/// The executors of the cluster and their slots.
+async fn current_fleet(
+    ctx: &FlameContext,
+    executor_slots: Option<u32>,
+) -> Result<(u32, u32), Box<dyn Error>> {
+    let current_ctx = ctx.get_current_context()?;
+    let conn = flame::client::connect_with_tls(
+        &current_ctx.cluster.endpoint,
+        current_ctx.cluster.tls.as_ref(),
+    )
+    .await?;
+
+    let executors = conn.list_executor().await?;
+    let total: u32 = executors.iter().map(|e| e.slots).sum();
+    let slots =
+        executor_slots.unwrap_or_else(|| executors.iter().map(|e| e.slots).max().unwrap_or(1));
+
+    Ok((total.div_ceil(slots.max(1)), slots))
+}
+
+/// Simulate the workload on the slots, returning the reports of the sessions.
+fn simulate(
+    sessions: &[SessionWorkloadYaml],
+    capacity: u32,
+) -> Result<Vec<SessionReport>, FlameError> {
+    if let Some(ssn) = sessions.iter().find(|ssn| ssn.slots > capacity) {
+        return Err(FlameError::InvalidConfig(format!(
+            "the tasks of session <{}> need {} slots, more than the {capacity} slots of the fleet",
+            ssn.name, ssn.slots
+        )));
+    }
+
+    // The arrivals of the tasks of each session in milliseconds.
+    let mut arrivals: Vec<VecDeque<u64>> = sessions
+        .iter()
+        .map(|ssn| {
+            (0..ssn.tasks)
+                .map(|i| ssn.rate.map_or(0, |rate| (i as f64 * 1000.0 / rate) as u64))
+                .collect()
+        })
+        .collect();
+    let durations: Vec<u64> = sessions
+        .iter()
+        .map(|ssn| (ssn.duration * 1000.0) as u64)
+        .collect();
+
+    let mut pending: Vec<VecDeque<u64>> = vec![VecDeque::new(); sessions.len()];
+    let mut allocated = vec![0u32; sessions.len()];
+    let mut running = BinaryHeap::new();
+    let mut reports: Vec<SessionReport> =
+        sessions.iter().map(|_| SessionReport::default()).collect();
+    let mut free = capacity;
+    let mut now = 0;
+
+    loop {
+        // The tasks completed and submitted by now.
+        while let Some(Reverse((end, i))) = running.peek().copied() {
+            if end > now {
+                break;
+            }
+            running.pop();
+            free += sessions[i].slots;
+            allocated[i] -= sessions[i].slots;
+            reports[i].completion = end;
+        }
+        for (i, queue) in arrivals.iter_mut().enumerate() {
+            while queue.front().is_some_and(|&t| t <= now) {
+                pending[i].extend(queue.pop_front());
+            }
+        }
+
+        // The session with the least allocated slots first, i.e. the even
+        // share of the slots by `fairshare`.
+        loop {
+            let next = (0..sessions.len())
+                .filter(|&i| !pending[i].is_empty() && sessions[i].slots <= free)
+                .min_by_key(|&i| allocated[i]);
+            let Some(i) = next else {
+                break;
+            };
+            let submitted = pending[i].pop_front().unwrap_or(now);
+            reports[i].waits.push(now - submitted);
+            free -= sessions[i].slots;
+            allocated[i] += sessions[i].slots;
+            running.push(Reverse((now + durations[i], i)));
+        }
+
+        let next_arrival = arrivals.iter().filter_map(|q| q.front()).min().copied();
+        let next_end = running.peek().map(|Reverse((end, _))| *end);
+        now = match (next_arrival, next_end) {
+            (Some(a), Some(e)) => a.min(e),
+            (Some(t), None) | (None, Some(t)) => t,
+            (None, None) => break,
+        };
+    }
+
+    Ok(reports)
+}
+
+/// The fewest executors of `slots` to keep the p95 queue time of all the
+/// tasks under `target` milliseconds; none if it's not reached even if all
+/// the tasks run at once, e.g. the target is zero.
+fn required_executors(
+    sessions: &[SessionWorkloadYaml],
+    slots: u32,
+    target: u64,
+) -> Result<Option<u32>, FlameError> {
+    let p95 = |executors: u32| -> Result<u64, FlameError> {
+        let reports = simulate(sessions, executors * slots)?;
+        let waits: Vec<u64> = reports.into_iter().flat_map(|r| r.waits).collect();
+        Ok(percentile(&waits, 0.95))
+    };
+
+    let max_slots = sessions.iter().map(|ssn| ssn.slots).max().unwrap_or(1);
+    let demand: u32 = sessions.iter().map(|ssn| ssn.tasks * ssn.slots).sum();
+    let mut lo = max_slots.div_ceil(slots).max(1);
+    let mut hi = demand.div_ceil(slots).max(lo);
+    if p95(hi)? > target {
+        return Ok(None);
+    }
+
+    while lo < hi {
+        let mid = lo + (hi - lo) / 2;
+        if p95(mid)? <= target {
+            hi = mid;
+        } else {
+            lo = mid + 1;
+        }
+    }
+
+    Ok(Some(lo))
+}
+
+fn print_reports(sessions: &[SessionWorkloadYaml], reports: &[SessionReport]) {
+    let secs = |ms: u64| format!("{:.1}s", ms as f64 / 1000.0);
+
+    let mut table = Table::new();
+    table.load_preset(NOTHING).set_header(vec![
+        "Session",
+        "Slots",
+        "Tasks",
+        "Mean Wait",
+        "P95 Wait",
+        "Max Wait",
+        "Completed",
+    ]);
+    for (ssn, report) in sessions.iter().zip(reports) {
+        table.add_row(vec![
+            ssn.name.clone(),
+            ssn.slots.to_string(),
+            ssn.tasks.to_string(),
+            secs(report.mean()),
+            secs(report.p95()),
+            secs(report.waits.iter().copied().max().unwrap_or(0)),
+            secs(report.completion),
+        ]);
+    }
+
+    println!("{table}");
+}
@@ -0,0 +1,43 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+use std::error::Error;
+use std::fs;
+
+use flame_rs as flame;
+use flame_rs::apis::FlameContext;
+
+pub async fn run(
+    ctx: &FlameContext,
+    endpoint: &Option<String>,
+    token_file: &str,
+    force: bool,
+) -> Result<(), Box<dyn Error>> {
+    let current_ctx = ctx.get_current_context()?;
+    let token = fs::read_to_string(token_file)
+        .map_err(|e| format!("Failed to read the token file <{token_file}>: {e}"))?;
+    let endpoint = endpoint
+        .clone()
+        .unwrap_or_else(|| current_ctx.cluster.endpoint.clone());
+
+    // Connect to the standby itself instead of its leader.
+    let conn = flame::client::connect_standby(&endpoint, current_ctx.cluster.tls.as_ref()).await?;
+    let status = conn.promote_standby(token.trim(), force).await?;
+
+    if status.standby {
+        return Err(format!("Standby <{endpoint}> was not promoted.").into());
+    }
+    println!("Standby <{endpoint}> was promoted to the leader.");
+
+    Ok(())
+}
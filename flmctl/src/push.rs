@@ -0,0 +1,59 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+use std::{fs, path::Path};
+
+use flame_rs as flame;
+use flame_rs::{
+    apis::{FlameContext, FlameError},
+    client::ApplicationAttributes,
+};
+
+use crate::apis::ApplicationYaml;
+
+/// Register the applications in the manifest, or update them if they're
+/// registered; so the manifest can be pushed again after each change.
+pub async fn run(ctx: &FlameContext, path: &str) -> Result<(), FlameError> {
+    if !Path::new(path).is_file() {
+        return Err(FlameError::InvalidConfig(format!("<{path}> is not a file")));
+    }
+
+    let contents = fs::read_to_string(path).map_err(|e| FlameError::Internal(e.to_string()))?;
+
+    let current_ctx = ctx.get_current_context()?;
+    let conn = flame::client::connect_with_tls(
+        &current_ctx.cluster.endpoint,
+        current_ctx.cluster.tls.as_ref(),
+    )
+    .await?;
+
+    let documents = contents
+        .split("\n---\n")
+        .map(|s| s.trim())
+        .filter(|s| !s.is_empty());
+
+    for doc in documents {
+        let app: ApplicationYaml =
+            serde_yaml::from_str(doc).map_err(|e| FlameError::Internal(e.to_string()))?;
+        let app_attr = ApplicationAttributes::try_from(&app)?;
+        let name = app.metadata.name;
+
+        if conn.apply_application(&name, app_attr).await? {
+            println!("Application <{name}> was registered.");
+        } else {
+            println!("Application <{name}> was updated.");
+        }
+    }
+
+    Ok(())
+}
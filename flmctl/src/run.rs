@@ -0,0 +1,63 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+use std::error::Error;
+use std::time::Duration;
+
+use flame_rs as flame;
+use flame_rs::apis::{FlameContext, TaskInput};
+
+pub async fn run(
+    ctx: &FlameContext,
+    session_id: &String,
+    input: &Option<String>,
+    timeout: &Option<u64>,
+) -> Result<(), Box<dyn Error>> {
+    let current_ctx = ctx.get_current_context()?;
+    let conn = flame::client::connect_with_tls(
+        &current_ctx.cluster.endpoint,
+        current_ctx.cluster.tls.as_ref(),
+    )
+    .await?;
+
+    let session = conn.get_session(session_id).await?;
+
+    // Check the submission first, so the rejected input is not sent.
+    let input_size = input.as_ref().map_or(0, |i| i.len() as u64);
+    let check = session.check_submission(input_size).await?;
+    check.ensure()?;
+    if !check.feasible {
+        println!(
+            "{:<15}{} (idle/total slots: {}/{})",
+            "Pending:",
+            check.reasons.join("; "),
+            check.idle_slots,
+            check.total_slots
+        );
+    }
+
+    let task = session
+        .submit_and_wait(
+            input.clone().map(TaskInput::from),
+            timeout.map(Duration::from_secs),
+        )
+        .await?;
+
+    println!("{:<15}{}", "Task:", task.id);
+    println!("{:<15}{}", "State:", task.state);
+    if let Some(output) = task.output {
+        println!("{:<15}{}", "Output:", String::from_utf8_lossy(&output));
+    }
+
+    Ok(())
+}
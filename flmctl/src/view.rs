@@ -224,6 +224,14 @@ async fn view_node(conn: client::Connection, node_name: &str) -> Result<(), Box<
     println!("{:<15}", "Info:");
     println!("  {:<13}{}", "Arch:", node.arch);
     println!("  {:<13}{}", "OS:", node.os);
+    if !node.labels.is_empty() {
+        let mut labels: Vec<_> = node.labels.iter().collect();
+        labels.sort();
+        println!("{:<15}", "Labels:");
+        for (key, value) in labels {
+            println!("  {key}={value}");
+        }
+    }
 
     Ok(())
 }
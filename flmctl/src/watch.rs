@@ -0,0 +1,41 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+use std::error::Error;
+use std::time::Duration;
+
+use flame_rs as flame;
+use flame_rs::apis::FlameContext;
+use flame_rs::client::progress;
+
+pub async fn run(
+    ctx: &FlameContext,
+    session_id: &str,
+    interval: u64,
+) -> Result<(), Box<dyn Error>> {
+    let current_ctx = ctx.get_current_context()?;
+    let conn = flame::client::connect_with_tls(
+        &current_ctx.cluster.endpoint,
+        current_ctx.cluster.tls.as_ref(),
+    )
+    .await?;
+
+    let session = conn.get_session(&session_id.to_string()).await?;
+    let stats = progress::show(&session, Duration::from_secs(interval.max(1))).await?;
+
+    println!("{:<15}{}", "Succeed:", stats.succeed);
+    println!("{:<15}{}", "Failed:", stats.failed);
+    println!("{:<15}{}", "Cancelled:", stats.cancelled);
+
+    Ok(())
+}
@@ -92,6 +92,8 @@ async fn main() -> Result<(), Box<dyn Error>> {
         min_instances: 0,
         max_instances: None,
         batch_size: 1,
+        content_type: None,
+        labels: vec![],
     };
     let ssn = conn.create_session(&ssn_attr).await?;
     let ssn_creation_end_time = Local::now();
@@ -70,6 +70,11 @@ impl flame::service::FlameService for FlmpingService {
     async fn on_session_leave(&self) -> Result<(), FlameError> {
         Ok(())
     }
+
+    // The service is stateless, so it's reused by the next session as is.
+    async fn on_session_reset(&self) -> Result<(), FlameError> {
+        Ok(())
+    }
 }
 
 #[tokio::main]
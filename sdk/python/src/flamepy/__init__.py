@@ -64,6 +64,7 @@ from .core import (  # Type aliases; Constants; Enums; Exception classes; Data c
     register_application,
     run,
     unregister_application,
+    update_application,
     update_object,
 )
 
@@ -108,6 +109,7 @@ __all__ = [
     "open_session",
     "register_application",
     "unregister_application",
+    "update_application",
     "list_applications",
     "get_application",
     "list_sessions",
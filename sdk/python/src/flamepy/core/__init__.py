@@ -37,6 +37,7 @@ from .client import (
     open_session,
     register_application,
     unregister_application,
+    update_application,
 )
 
 # Service functions
@@ -89,7 +90,9 @@ from .types import (
     TaskInput,
     TaskOutput,
     TaskState,
+    checksum,
     short_name,
+    verify_checksum,
 )
 
 __all__ = [
@@ -127,12 +130,15 @@ __all__ = [
     "FlameContext",
     # Utility functions
     "short_name",
+    "checksum",
+    "verify_checksum",
     # Client functions
     "connect",
     "create_session",
     "open_session",
     "register_application",
     "unregister_application",
+    "update_application",
     "list_applications",
     "get_application",
     "list_sessions",
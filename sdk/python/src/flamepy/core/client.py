@@ -54,6 +54,7 @@ from flamepy.proto.frontend_pb2 import (
     OpenSessionRequest,
     RegisterApplicationRequest,
     UnregisterApplicationRequest,
+    UpdateApplicationRequest,
     WatchTaskRequest,
 )
 from flamepy.proto.frontend_pb2_grpc import FrontendStub
@@ -62,6 +63,21 @@ from flamepy.proto.types_pb2 import ApplicationSpec, Environment, SessionSpec, T
 
 logger = logging.getLogger(__name__)
 
+# The errors kept across the wire by their status codes; the others are
+# reported as internal errors.
+_ERROR_CODES = {
+    grpc.StatusCode.INVALID_ARGUMENT: FlameErrorCode.INVALID_ARGUMENT,
+    grpc.StatusCode.ALREADY_EXISTS: FlameErrorCode.ALREADY_EXISTS,
+    grpc.StatusCode.NOT_FOUND: FlameErrorCode.NOT_FOUND,
+    grpc.StatusCode.FAILED_PRECONDITION: FlameErrorCode.VERSION_MISMATCH,
+    grpc.StatusCode.DATA_LOSS: FlameErrorCode.INTEGRITY,
+}
+
+
+def error_of(code: grpc.StatusCode, message: str) -> FlameError:
+    """The FlameError of the status code returned by the session manager."""
+    return FlameError(_ERROR_CODES.get(code, FlameErrorCode.INTERNAL), message)
+
 
 def connect(addr: str, tls_config: Optional[FlameClientTls] = None) -> "Connection":
     """Connect to the Flame service.
@@ -115,6 +131,11 @@ def register_application(name: str, app_attrs: Union[ApplicationAttributes, Dict
     conn.register_application(name, app_attrs)
 
 
+def update_application(name: str, app_attrs: Union[ApplicationAttributes, Dict[str, Any]], resource_version: Optional[int] = None) -> None:
+    conn = ConnectionInstance.instance()
+    conn.update_application(name, app_attrs, resource_version)
+
+
 def unregister_application(name: str) -> None:
     conn = ConnectionInstance.instance()
     conn.unregister_application(name)
@@ -140,9 +161,9 @@ def get_session(session_id: SessionID) -> "Session":
     return conn.get_session(session_id)
 
 
-def close_session(session_id: SessionID) -> "Session":
+def close_session(session_id: SessionID, resource_version: Optional[int] = None) -> "Session":
     conn = ConnectionInstance.instance()
-    return conn.close_session(session_id)
+    return conn.close_session(session_id, resource_version)
 
 
 class ConnectionInstance:
@@ -235,8 +256,8 @@ class Connection:
         self._executor.shutdown(wait=True)
         self._channel.close()
 
-    def register_application(self, name: str, app_attrs: Union[ApplicationAttributes, Dict[str, Any]]) -> None:
-        """Register a new application."""
+    def _application_spec(self, app_attrs: Union[ApplicationAttributes, Dict[str, Any]]) -> ApplicationSpec:
+        """The spec of the application by its attributes."""
         if isinstance(app_attrs, dict):
             app_attrs = ApplicationAttributes(**app_attrs)
 
@@ -260,7 +281,7 @@ class Connection:
 
         shim_value = app_attrs.shim.value if app_attrs.shim is not None else Shim.HOST.value
 
-        app_spec = ApplicationSpec(
+        return ApplicationSpec(
             shim=shim_value,
             image=app_attrs.image,
             command=app_attrs.command,
@@ -275,6 +296,10 @@ class Connection:
             url=app_attrs.url,
         )
 
+    def register_application(self, name: str, app_attrs: Union[ApplicationAttributes, Dict[str, Any]]) -> None:
+        """Register a new application."""
+        app_spec = self._application_spec(app_attrs)
+
         request = RegisterApplicationRequest(name=name, application=app_spec)
 
         try:
@@ -285,6 +310,17 @@ class Connection:
                 f"failed to register application: {e.details()}",
             )
 
+    def update_application(self, name: str, app_attrs: Union[ApplicationAttributes, Dict[str, Any]], resource_version: Optional[int] = None) -> None:
+        """Update an application; if the resource version is given, the
+        application is only updated if it's not modified since then, otherwise
+        FlameError(VERSION_MISMATCH) is raised."""
+        request = UpdateApplicationRequest(name=name, application=self._application_spec(app_attrs), resource_version=resource_version)
+
+        try:
+            self._frontend.UpdateApplication(request)
+        except grpc.RpcError as e:
+            raise error_of(e.code(), f"failed to update application: {e.details()}")
+
     def unregister_application(self, name: str) -> None:
         """Unregister an application."""
         request = UnregisterApplicationRequest(name=name)
@@ -334,6 +370,7 @@ class Connection:
                         delay_release=app.spec.delay_release,
                         schema=schema,
                         url=app.spec.url if app.spec.HasField("url") else None,
+                        resource_version=app.metadata.resource_version if app.metadata.HasField("resource_version") else None,
                     )
                 )
 
@@ -376,6 +413,7 @@ class Connection:
                 delay_release=response.spec.delay_release,
                 schema=schema,
                 url=response.spec.url if response.spec.HasField("url") else None,
+                resource_version=response.metadata.resource_version if response.metadata.HasField("resource_version") else None,
             )
 
         except grpc.RpcError as e:
@@ -536,9 +574,10 @@ class Connection:
         except grpc.RpcError as e:
             raise FlameError(FlameErrorCode.INTERNAL, f"failed to get session: {e.details()}")
 
-    def close_session(self, session_id: SessionID) -> "Session":
-        """Close a session."""
-        request = CloseSessionRequest(session_id=session_id)
+    def close_session(self, session_id: SessionID, resource_version: Optional[int] = None) -> "Session":
+        """Close a session; if the resource version is given, the session is
+        only closed if it's not modified since then."""
+        request = CloseSessionRequest(session_id=session_id, resource_version=resource_version)
 
         try:
             response = self._frontend.CloseSession(request)
@@ -562,7 +601,7 @@ class Connection:
             )
 
         except grpc.RpcError as e:
-            raise FlameError(FlameErrorCode.INTERNAL, f"failed to close session: {e.details()}")
+            raise error_of(e.code(), f"failed to close session: {e.details()}")
 
 
 class Session:
@@ -11,6 +11,7 @@ See the License for the specific language governing permissions and
 limitations under the License.
 """
 
+import hashlib
 import os
 import random
 import string
@@ -53,6 +54,7 @@ class TaskState(IntEnum):
     RUNNING = 1
     SUCCEED = 2
     FAILED = 3
+    CANCELLED = 4
 
 
 class ApplicationState(IntEnum):
@@ -67,6 +69,8 @@ class Shim(IntEnum):
 
     HOST = 0
     WASM = 1
+    GRPC = 2
+    HTTP = 3
 
 
 class FlameErrorCode(IntEnum):
@@ -78,6 +82,8 @@ class FlameErrorCode(IntEnum):
     INTERNAL = 3
     ALREADY_EXISTS = 4
     NOT_FOUND = 5
+    VERSION_MISMATCH = 6
+    INTEGRITY = 7
 
 
 class FlameError(Exception):
@@ -153,7 +159,7 @@ class Task:
 
     def is_completed(self) -> bool:
         """Check if the task is completed."""
-        return self.state in (TaskState.SUCCEED, TaskState.FAILED)
+        return self.state in (TaskState.SUCCEED, TaskState.FAILED, TaskState.CANCELLED)
 
     def is_failed(self) -> bool:
         """Check if the task is failed."""
@@ -180,6 +186,7 @@ class Application:
     delay_release: Optional[int] = None
     schema: Optional[ApplicationSchema] = None
     url: Optional[str] = None
+    resource_version: Optional[int] = None
 
 
 class TaskInformer:
@@ -194,6 +201,24 @@ class TaskInformer:
         pass
 
 
+def checksum(data: Optional[bytes]) -> str:
+    """The checksum of the data as the other SDKs, i.e. `sha256:<hex>`."""
+    return f"sha256:{hashlib.sha256(data or b'').hexdigest()}"
+
+
+def verify_checksum(name: str, data: Optional[bytes], expected: Optional[str]) -> None:
+    """Verify the data by its expected checksum, if any; the mismatch is
+    FlameError(INTEGRITY)."""
+    if expected is None:
+        return
+    if not expected.startswith("sha256:"):
+        raise FlameError(FlameErrorCode.INVALID_ARGUMENT, f"unsupported checksum <{expected}> of {name}")
+
+    actual = checksum(data)
+    if actual != expected:
+        raise FlameError(FlameErrorCode.INTEGRITY, f"checksum mismatch of {name}: expected <{expected}>, got <{actual}>")
+
+
 def short_name(prefix: str, length: int = 6) -> str:
     """Generate a short name with a prefix."""
     alphabet = string.ascii_letters + string.digits
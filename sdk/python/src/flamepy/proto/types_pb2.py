@@ -24,7 +24,7 @@ _sym_db = _symbol_database.Default()
 
 
 
-DESCRIPTOR = _descriptor_pool.Default().AddSerializedFile(b'\n\x0btypes.proto\x12\x08\x66lame.v1\"$\n\x08Metadata\x12\n\n\x02id\x18\x01 \x01(\t\x12\x0c\n\x04name\x18\x02 \x01(\t\"\xf6\x01\n\rSessionStatus\x12%\n\x05state\x18\x01 \x01(\x0e\x32\x16.flame.v1.SessionState\x12\x15\n\rcreation_time\x18\x02 \x01(\x03\x12\x1c\n\x0f\x63ompletion_time\x18\x03 \x01(\x03H\x00\x88\x01\x01\x12\x0f\n\x07pending\x18\x04 \x01(\x05\x12\x0f\n\x07running\x18\x05 \x01(\x05\x12\x0f\n\x07succeed\x18\x06 \x01(\x05\x12\x0e\n\x06\x66\x61iled\x18\x07 \x01(\x05\x12\x11\n\tcancelled\x18\t \x01(\x05\x12\x1f\n\x06\x65vents\x18\x08 \x03(\x0b\x32\x0f.flame.v1.EventB\x12\n\x10_completion_time\"\xb4\x01\n\x0bSessionSpec\x12\x13\n\x0b\x61pplication\x18\x02 \x01(\t\x12\r\n\x05slots\x18\x03 \x01(\r\x12\x18\n\x0b\x63ommon_data\x18\x04 \x01(\x0cH\x00\x88\x01\x01\x12\x15\n\rmin_instances\x18\x05 \x01(\r\x12\x1a\n\rmax_instances\x18\x06 \x01(\rH\x01\x88\x01\x01\x12\x12\n\nbatch_size\x18\x07 \x01(\rB\x0e\n\x0c_common_dataB\x10\n\x0e_max_instances\"}\n\x07Session\x12$\n\x08metadata\x18\x01 \x01(\x0b\x32\x12.flame.v1.Metadata\x12#\n\x04spec\x18\x02 \x01(\x0b\x32\x15.flame.v1.SessionSpec\x12\'\n\x06status\x18\x03 \x01(\x0b\x32\x17.flame.v1.SessionStatus\"\x9a\x01\n\nTaskStatus\x12\"\n\x05state\x18\x01 \x01(\x0e\x32\x13.flame.v1.TaskState\x12\x15\n\rcreation_time\x18\x02 \x01(\x03\x12\x1c\n\x0f\x63ompletion_time\x18\x03 \x01(\x03H\x00\x88\x01\x01\x12\x1f\n\x06\x65vents\x18\x04 \x03(\x0b\x32\x0f.flame.v1.EventB\x12\n\x10_completion_time\"\\\n\x08TaskSpec\x12\x12\n\nsession_id\x18\x02 \x01(\t\x12\x12\n\x05input\x18\x03 \x01(\x0cH\x00\x88\x01\x01\x12\x13\n\x06output\x18\x04 \x01(\x0cH\x01\x88\x01\x01\x42\x08\n\x06_inputB\t\n\x07_output\"t\n\x04Task\x12$\n\x08metadata\x18\x01 \x01(\x0b\x32\x12.flame.v1.Metadata\x12 \n\x04spec\x18\x02 \x01(\x0b\x32\x12.flame.v1.TaskSpec\x12$\n\x06status\x18\x03 \x01(\x0b\x32\x14.flame.v1.TaskStatus\"U\n\x11\x41pplicationStatus\x12)\n\x05state\x18\x01 \x01(\x0e\x32\x1a.flame.v1.ApplicationState\x12\x15\n\rcreation_time\x18\x02 \x01(\x03\"*\n\x0b\x45nvironment\x12\x0c\n\x04name\x18\x01 \x01(\t\x12\r\n\x05value\x18\x02 \x01(\t\"{\n\x11\x41pplicationSchema\x12\x12\n\x05input\x18\x01 \x01(\tH\x00\x88\x01\x01\x12\x13\n\x06output\x18\x02 \x01(\tH\x01\x88\x01\x01\x12\x18\n\x0b\x63ommon_data\x18\x03 \x01(\tH\x02\x88\x01\x01\x42\x08\n\x06_inputB\t\n\x07_outputB\x0e\n\x0c_common_data\"\xd2\x03\n\x0f\x41pplicationSpec\x12\x1c\n\x04shim\x18\x01 \x01(\x0e\x32\x0e.flame.v1.Shim\x12\x18\n\x0b\x64\x65scription\x18\x02 \x01(\tH\x00\x88\x01\x01\x12\x0e\n\x06labels\x18\x03 \x03(\t\x12\x12\n\x05image\x18\x04 \x01(\tH\x01\x88\x01\x01\x12\x14\n\x07\x63ommand\x18\x05 \x01(\tH\x02\x88\x01\x01\x12\x11\n\targuments\x18\x06 \x03(\t\x12+\n\x0c\x65nvironments\x18\x07 \x03(\x0b\x32\x15.flame.v1.Environment\x12\x1e\n\x11working_directory\x18\x08 \x01(\tH\x03\x88\x01\x01\x12\x1a\n\rmax_instances\x18\t \x01(\rH\x04\x88\x01\x01\x12\x1a\n\rdelay_release\x18\n \x01(\x03H\x05\x88\x01\x01\x12\x30\n\x06schema\x18\x0b \x01(\x0b\x32\x1b.flame.v1.ApplicationSchemaH\x06\x88\x01\x01\x12\x10\n\x03url\x18\x0c \x01(\tH\x07\x88\x01\x01\x42\x0e\n\x0c_descriptionB\x08\n\x06_imageB\n\n\x08_commandB\x14\n\x12_working_directoryB\x10\n\x0e_max_instancesB\x10\n\x0e_delay_releaseB\t\n\x07_schemaB\x06\n\x04_url\"\x89\x01\n\x0b\x41pplication\x12$\n\x08metadata\x18\x01 \x01(\x0b\x32\x12.flame.v1.Metadata\x12\'\n\x04spec\x18\x02 \x01(\x0b\x32\x19.flame.v1.ApplicationSpec\x12+\n\x06status\x18\x03 \x01(\x0b\x32\x1b.flame.v1.ApplicationStatus\"x\n\x0c\x45xecutorSpec\x12\x0c\n\x04node\x18\x01 \x01(\t\x12-\n\x06resreq\x18\x02 \x01(\x0b\x32\x1d.flame.v1.ResourceRequirement\x12\r\n\x05slots\x18\x03 \x01(\r\x12\x1c\n\x04shim\x18\x04 \x01(\x0e\x32\x0e.flame.v1.Shim\"`\n\x0e\x45xecutorStatus\x12&\n\x05state\x18\x01 \x01(\x0e\x32\x17.flame.v1.ExecutorState\x12\x17\n\nsession_id\x18\x02 \x01(\tH\x00\x88\x01\x01\x42\r\n\x0b_session_id\"\x80\x01\n\x08\x45xecutor\x12$\n\x08metadata\x18\x01 \x01(\x0b\x32\x12.flame.v1.Metadata\x12$\n\x04spec\x18\x02 \x01(\x0b\x32\x16.flame.v1.ExecutorSpec\x12(\n\x06status\x18\x03 \x01(\x0b\x32\x18.flame.v1.ExecutorStatus\"5\n\x0c\x45xecutorList\x12%\n\texecutors\x18\x01 \x03(\x0b\x32\x12.flame.v1.Executor\"2\n\x0bSessionList\x12#\n\x08sessions\x18\x01 \x03(\x0b\x32\x11.flame.v1.Session\">\n\x0f\x41pplicationList\x12+\n\x0c\x61pplications\x18\x01 \x03(\x0b\x32\x15.flame.v1.Application\"?\n\x13ResourceRequirement\x12\x0b\n\x03\x63pu\x18\x01 \x01(\x04\x12\x0e\n\x06memory\x18\x02 \x01(\x04\x12\x0b\n\x03gpu\x18\x03 \x01(\x05\"\x1c\n\x08NodeSpec\x12\x10\n\x08hostname\x18\x01 \x01(\t\"$\n\x08NodeInfo\x12\x0c\n\x04\x61rch\x18\x01 \x01(\t\x12\n\n\x02os\x18\x02 \x01(\t\",\n\x0bNodeAddress\x12\x0c\n\x04type\x18\x01 \x01(\t\x12\x0f\n\x07\x61\x64\x64ress\x18\x02 \x01(\t\"\xfe\x01\n\nNodeStatus\x12\"\n\x05state\x18\x01 \x01(\x0e\x32\x13.flame.v1.NodeState\x12/\n\x08\x63\x61pacity\x18\x02 \x01(\x0b\x32\x1d.flame.v1.ResourceRequirement\x12\x32\n\x0b\x61llocatable\x18\x03 \x01(\x0b\x32\x1d.flame.v1.ResourceRequirement\x12 \n\x04info\x18\x04 \x01(\x0b\x32\x12.flame.v1.NodeInfo\x12(\n\taddresses\x18\x05 \x03(\x0b\x32\x15.flame.v1.NodeAddress\x12\x1b\n\x13last_heartbeat_time\x18\x06 \x01(\x03\"t\n\x04Node\x12$\n\x08metadata\x18\x01 \x01(\x0b\x32\x12.flame.v1.Metadata\x12 \n\x04spec\x18\x02 \x01(\x0b\x32\x12.flame.v1.NodeSpec\x12$\n\x06status\x18\x03 \x01(\x0b\x32\x14.flame.v1.NodeStatus\")\n\x08NodeList\x12\x1d\n\x05nodes\x18\x01 \x03(\x0b\x32\x0e.flame.v1.Node\"?\n\x06Result\x12\x13\n\x0breturn_code\x18\x01 \x01(\x05\x12\x14\n\x07message\x18\x02 \x01(\tH\x00\x88\x01\x01\x42\n\n\x08_message\"c\n\nTaskResult\x12\x13\n\x0breturn_code\x18\x01 \x01(\x05\x12\x13\n\x06output\x18\x02 \x01(\x0cH\x00\x88\x01\x01\x12\x14\n\x07message\x18\x03 \x01(\tH\x01\x88\x01\x01\x42\t\n\x07_outputB\n\n\x08_message\"\x0e\n\x0c\x45mptyRequest\"N\n\x05\x45vent\x12\x0c\n\x04\x63ode\x18\x01 \x01(\x05\x12\x14\n\x07message\x18\x02 \x01(\tH\x00\x88\x01\x01\x12\x15\n\rcreation_time\x18\x03 \x01(\x03\x42\n\n\x08_message*$\n\x0cSessionState\x12\x08\n\x04Open\x10\x00\x12\n\n\x06\x43losed\x10\x01*M\n\tTaskState\x12\x0b\n\x07Pending\x10\x00\x12\x0b\n\x07Running\x10\x01\x12\x0b\n\x07Succeed\x10\x02\x12\n\n\x06\x46\x61iled\x10\x03\x12\r\n\tCancelled\x10\x04*\x1a\n\x04Shim\x12\x08\n\x04Host\x10\x00\x12\x08\n\x04Wasm\x10\x01*-\n\x10\x41pplicationState\x12\x0b\n\x07\x45nabled\x10\x00\x12\x0c\n\x08\x44isabled\x10\x01*\xb4\x01\n\rExecutorState\x12\x13\n\x0f\x45xecutorUnknown\x10\x00\x12\x10\n\x0c\x45xecutorVoid\x10\x01\x12\x10\n\x0c\x45xecutorIdle\x10\x02\x12\x13\n\x0f\x45xecutorBinding\x10\x03\x12\x11\n\rExecutorBound\x10\x04\x12\x15\n\x11\x45xecutorUnbinding\x10\x05\x12\x15\n\x11\x45xecutorReleasing\x10\x06\x12\x14\n\x10\x45xecutorReleased\x10\x07*1\n\tNodeState\x12\x0b\n\x07Unknown\x10\x00\x12\t\n\x05Ready\x10\x01\x12\x0c\n\x08NotReady\x10\x02\x42)Z\'github.com/flame-sh/flame/sdk/go/rpc/v1b\x06proto3')
+DESCRIPTOR = _descriptor_pool.Default().AddSerializedFile(b'\n\x0btypes.proto\x12\x08flame.v1"X\n\x08Metadata\x12\n\n\x02id\x18\x01 \x01(\t\x12\x0c\n\x04name\x18\x02 \x01(\t\x12\x1d\n\x10resource_version\x18\x03 \x01(\rH\x00\x88\x01\x01B\x13\n\x11_resource_version"\xf6\x01\n\rSessionStatus\x12%\n\x05state\x18\x01 \x01(\x0e2\x16.flame.v1.SessionState\x12\x15\n\rcreation_time\x18\x02 \x01(\x03\x12\x1c\n\x0fcompletion_time\x18\x03 \x01(\x03H\x00\x88\x01\x01\x12\x0f\n\x07pending\x18\x04 \x01(\x05\x12\x0f\n\x07running\x18\x05 \x01(\x05\x12\x0f\n\x07succeed\x18\x06 \x01(\x05\x12\x0e\n\x06failed\x18\x07 \x01(\x05\x12\x11\n\tcancelled\x18\t \x01(\x05\x12\x1f\n\x06events\x18\x08 \x03(\x0b2\x0f.flame.v1.EventB\x12\n\x10_completion_time"\xb4\x01\n\x0bSessionSpec\x12\x13\n\x0bapplication\x18\x02 \x01(\t\x12\r\n\x05slots\x18\x03 \x01(\r\x12\x18\n\x0bcommon_data\x18\x04 \x01(\x0cH\x00\x88\x01\x01\x12\x15\n\rmin_instances\x18\x05 \x01(\r\x12\x1a\n\rmax_instances\x18\x06 \x01(\rH\x01\x88\x01\x01\x12\x12\n\nbatch_size\x18\x07 \x01(\rB\x0e\n\x0c_common_dataB\x10\n\x0e_max_instances"}\n\x07Session\x12$\n\x08metadata\x18\x01 \x01(\x0b2\x12.flame.v1.Metadata\x12#\n\x04spec\x18\x02 \x01(\x0b2\x15.flame.v1.SessionSpec\x12\'\n\x06status\x18\x03 \x01(\x0b2\x17.flame.v1.SessionStatus"\x9a\x01\n\nTaskStatus\x12"\n\x05state\x18\x01 \x01(\x0e2\x13.flame.v1.TaskState\x12\x15\n\rcreation_time\x18\x02 \x01(\x03\x12\x1c\n\x0fcompletion_time\x18\x03 \x01(\x03H\x00\x88\x01\x01\x12\x1f\n\x06events\x18\x04 \x03(\x0b2\x0f.flame.v1.EventB\x12\n\x10_completion_time"\\\n\x08TaskSpec\x12\x12\n\nsession_id\x18\x02 \x01(\t\x12\x12\n\x05input\x18\x03 \x01(\x0cH\x00\x88\x01\x01\x12\x13\n\x06output\x18\x04 \x01(\x0cH\x01\x88\x01\x01B\x08\n\x06_inputB\t\n\x07_output"t\n\x04Task\x12$\n\x08metadata\x18\x01 \x01(\x0b2\x12.flame.v1.Metadata\x12 \n\x04spec\x18\x02 \x01(\x0b2\x12.flame.v1.TaskSpec\x12$\n\x06status\x18\x03 \x01(\x0b2\x14.flame.v1.TaskStatus"U\n\x11ApplicationStatus\x12)\n\x05state\x18\x01 \x01(\x0e2\x1a.flame.v1.ApplicationState\x12\x15\n\rcreation_time\x18\x02 \x01(\x03"*\n\x0bEnvironment\x12\x0c\n\x04name\x18\x01 \x01(\t\x12\r\n\x05value\x18\x02 \x01(\t"{\n\x11ApplicationSchema\x12\x12\n\x05input\x18\x01 \x01(\tH\x00\x88\x01\x01\x12\x13\n\x06output\x18\x02 \x01(\tH\x01\x88\x01\x01\x12\x18\n\x0bcommon_data\x18\x03 \x01(\tH\x02\x88\x01\x01B\x08\n\x06_inputB\t\n\x07_outputB\x0e\n\x0c_common_data"\xd2\x03\n\x0fApplicationSpec\x12\x1c\n\x04shim\x18\x01 \x01(\x0e2\x0e.flame.v1.Shim\x12\x18\n\x0bdescription\x18\x02 \x01(\tH\x00\x88\x01\x01\x12\x0e\n\x06labels\x18\x03 \x03(\t\x12\x12\n\x05image\x18\x04 \x01(\tH\x01\x88\x01\x01\x12\x14\n\x07command\x18\x05 \x01(\tH\x02\x88\x01\x01\x12\x11\n\targuments\x18\x06 \x03(\t\x12+\n\x0cenvironments\x18\x07 \x03(\x0b2\x15.flame.v1.Environment\x12\x1e\n\x11working_directory\x18\x08 \x01(\tH\x03\x88\x01\x01\x12\x1a\n\rmax_instances\x18\t \x01(\rH\x04\x88\x01\x01\x12\x1a\n\rdelay_release\x18\n \x01(\x03H\x05\x88\x01\x01\x120\n\x06schema\x18\x0b \x01(\x0b2\x1b.flame.v1.ApplicationSchemaH\x06\x88\x01\x01\x12\x10\n\x03url\x18\x0c \x01(\tH\x07\x88\x01\x01B\x0e\n\x0c_descriptionB\x08\n\x06_imageB\n\n\x08_commandB\x14\n\x12_working_directoryB\x10\n\x0e_max_instancesB\x10\n\x0e_delay_releaseB\t\n\x07_schemaB\x06\n\x04_url"\x89\x01\n\x0bApplication\x12$\n\x08metadata\x18\x01 \x01(\x0b2\x12.flame.v1.Metadata\x12\'\n\x04spec\x18\x02 \x01(\x0b2\x19.flame.v1.ApplicationSpec\x12+\n\x06status\x18\x03 \x01(\x0b2\x1b.flame.v1.ApplicationStatus"x\n\x0cExecutorSpec\x12\x0c\n\x04node\x18\x01 \x01(\t\x12-\n\x06resreq\x18\x02 \x01(\x0b2\x1d.flame.v1.ResourceRequirement\x12\r\n\x05slots\x18\x03 \x01(\r\x12\x1c\n\x04shim\x18\x04 \x01(\x0e2\x0e.flame.v1.Shim"`\n\x0eExecutorStatus\x12&\n\x05state\x18\x01 \x01(\x0e2\x17.flame.v1.ExecutorState\x12\x17\n\nsession_id\x18\x02 \x01(\tH\x00\x88\x01\x01B\r\n\x0b_session_id"\x80\x01\n\x08Executor\x12$\n\x08metadata\x18\x01 \x01(\x0b2\x12.flame.v1.Metadata\x12$\n\x04spec\x18\x02 \x01(\x0b2\x16.flame.v1.ExecutorSpec\x12(\n\x06status\x18\x03 \x01(\x0b2\x18.flame.v1.ExecutorStatus"5\n\x0cExecutorList\x12%\n\texecutors\x18\x01 \x03(\x0b2\x12.flame.v1.Executor"2\n\x0bSessionList\x12#\n\x08sessions\x18\x01 \x03(\x0b2\x11.flame.v1.Session">\n\x0fApplicationList\x12+\n\x0capplications\x18\x01 \x03(\x0b2\x15.flame.v1.Application"?\n\x13ResourceRequirement\x12\x0b\n\x03cpu\x18\x01 \x01(\x04\x12\x0e\n\x06memory\x18\x02 \x01(\x04\x12\x0b\n\x03gpu\x18\x03 \x01(\x05"\x1c\n\x08NodeSpec\x12\x10\n\x08hostname\x18\x01 \x01(\t"$\n\x08NodeInfo\x12\x0c\n\x04arch\x18\x01 \x01(\t\x12\n\n\x02os\x18\x02 \x01(\t",\n\x0bNodeAddress\x12\x0c\n\x04type\x18\x01 \x01(\t\x12\x0f\n\x07address\x18\x02 \x01(\t"\xfe\x01\n\nNodeStatus\x12"\n\x05state\x18\x01 \x01(\x0e2\x13.flame.v1.NodeState\x12/\n\x08capacity\x18\x02 \x01(\x0b2\x1d.flame.v1.ResourceRequirement\x122\n\x0ballocatable\x18\x03 \x01(\x0b2\x1d.flame.v1.ResourceRequirement\x12 \n\x04info\x18\x04 \x01(\x0b2\x12.flame.v1.NodeInfo\x12(\n\taddresses\x18\x05 \x03(\x0b2\x15.flame.v1.NodeAddress\x12\x1b\n\x13last_heartbeat_time\x18\x06 \x01(\x03"t\n\x04Node\x12$\n\x08metadata\x18\x01 \x01(\x0b2\x12.flame.v1.Metadata\x12 \n\x04spec\x18\x02 \x01(\x0b2\x12.flame.v1.NodeSpec\x12$\n\x06status\x18\x03 \x01(\x0b2\x14.flame.v1.NodeStatus")\n\x08NodeList\x12\x1d\n\x05nodes\x18\x01 \x03(\x0b2\x0e.flame.v1.Node"?\n\x06Result\x12\x13\n\x0breturn_code\x18\x01 \x01(\x05\x12\x14\n\x07message\x18\x02 \x01(\tH\x00\x88\x01\x01B\n\n\x08_message"c\n\nTaskResult\x12\x13\n\x0breturn_code\x18\x01 \x01(\x05\x12\x13\n\x06output\x18\x02 \x01(\x0cH\x00\x88\x01\x01\x12\x14\n\x07message\x18\x03 \x01(\tH\x01\x88\x01\x01B\t\n\x07_outputB\n\n\x08_message"\x0e\n\x0cEmptyRequest"N\n\x05Event\x12\x0c\n\x04code\x18\x01 \x01(\x05\x12\x14\n\x07message\x18\x02 \x01(\tH\x00\x88\x01\x01\x12\x15\n\rcreation_time\x18\x03 \x01(\x03B\n\n\x08_message*$\n\x0cSessionState\x12\x08\n\x04Open\x10\x00\x12\n\n\x06Closed\x10\x01*M\n\tTaskState\x12\x0b\n\x07Pending\x10\x00\x12\x0b\n\x07Running\x10\x01\x12\x0b\n\x07Succeed\x10\x02\x12\n\n\x06Failed\x10\x03\x12\r\n\tCancelled\x10\x04*\x1a\n\x04Shim\x12\x08\n\x04Host\x10\x00\x12\x08\n\x04Wasm\x10\x01*-\n\x10ApplicationState\x12\x0b\n\x07Enabled\x10\x00\x12\x0c\n\x08Disabled\x10\x01*\xb4\x01\n\rExecutorState\x12\x13\n\x0fExecutorUnknown\x10\x00\x12\x10\n\x0cExecutorVoid\x10\x01\x12\x10\n\x0cExecutorIdle\x10\x02\x12\x13\n\x0fExecutorBinding\x10\x03\x12\x11\n\rExecutorBound\x10\x04\x12\x15\n\x11ExecutorUnbinding\x10\x05\x12\x15\n\x11ExecutorReleasing\x10\x06\x12\x14\n\x10ExecutorReleased\x10\x07*1\n\tNodeState\x12\x0b\n\x07Unknown\x10\x00\x12\t\n\x05Ready\x10\x01\x12\x0c\n\x08NotReady\x10\x02B)Z\'github.com/flame-sh/flame/sdk/go/rpc/v1b\x06proto3')
 
 _globals = globals()
 _builder.BuildMessageAndEnumDescriptors(DESCRIPTOR, _globals)
@@ -32,74 +32,74 @@ _builder.BuildTopDescriptorsAndMessages(DESCRIPTOR, 'types_pb2', _globals)
 if not _descriptor._USE_C_DESCRIPTORS:
   _globals['DESCRIPTOR']._loaded_options = None
   _globals['DESCRIPTOR']._serialized_options = b'Z\'github.com/flame-sh/flame/sdk/go/rpc/v1'
-  _globals['_SESSIONSTATE']._serialized_start=3237
-  _globals['_SESSIONSTATE']._serialized_end=3273
-  _globals['_TASKSTATE']._serialized_start=3275
-  _globals['_TASKSTATE']._serialized_end=3352
-  _globals['_SHIM']._serialized_start=3354
-  _globals['_SHIM']._serialized_end=3380
-  _globals['_APPLICATIONSTATE']._serialized_start=3382
-  _globals['_APPLICATIONSTATE']._serialized_end=3427
-  _globals['_EXECUTORSTATE']._serialized_start=3430
-  _globals['_EXECUTORSTATE']._serialized_end=3610
-  _globals['_NODESTATE']._serialized_start=3612
-  _globals['_NODESTATE']._serialized_end=3661
+  _globals['_SESSIONSTATE']._serialized_start=3289
+  _globals['_SESSIONSTATE']._serialized_end=3325
+  _globals['_TASKSTATE']._serialized_start=3327
+  _globals['_TASKSTATE']._serialized_end=3404
+  _globals['_SHIM']._serialized_start=3406
+  _globals['_SHIM']._serialized_end=3432
+  _globals['_APPLICATIONSTATE']._serialized_start=3434
+  _globals['_APPLICATIONSTATE']._serialized_end=3479
+  _globals['_EXECUTORSTATE']._serialized_start=3482
+  _globals['_EXECUTORSTATE']._serialized_end=3662
+  _globals['_NODESTATE']._serialized_start=3664
+  _globals['_NODESTATE']._serialized_end=3713
   _globals['_METADATA']._serialized_start=25
-  _globals['_METADATA']._serialized_end=61
-  _globals['_SESSIONSTATUS']._serialized_start=64
-  _globals['_SESSIONSTATUS']._serialized_end=310
-  _globals['_SESSIONSPEC']._serialized_start=313
-  _globals['_SESSIONSPEC']._serialized_end=493
-  _globals['_SESSION']._serialized_start=495
-  _globals['_SESSION']._serialized_end=620
-  _globals['_TASKSTATUS']._serialized_start=623
-  _globals['_TASKSTATUS']._serialized_end=777
-  _globals['_TASKSPEC']._serialized_start=779
-  _globals['_TASKSPEC']._serialized_end=871
-  _globals['_TASK']._serialized_start=873
-  _globals['_TASK']._serialized_end=989
-  _globals['_APPLICATIONSTATUS']._serialized_start=991
-  _globals['_APPLICATIONSTATUS']._serialized_end=1076
-  _globals['_ENVIRONMENT']._serialized_start=1078
-  _globals['_ENVIRONMENT']._serialized_end=1120
-  _globals['_APPLICATIONSCHEMA']._serialized_start=1122
-  _globals['_APPLICATIONSCHEMA']._serialized_end=1245
-  _globals['_APPLICATIONSPEC']._serialized_start=1248
-  _globals['_APPLICATIONSPEC']._serialized_end=1714
-  _globals['_APPLICATION']._serialized_start=1717
-  _globals['_APPLICATION']._serialized_end=1854
-  _globals['_EXECUTORSPEC']._serialized_start=1856
-  _globals['_EXECUTORSPEC']._serialized_end=1976
-  _globals['_EXECUTORSTATUS']._serialized_start=1978
-  _globals['_EXECUTORSTATUS']._serialized_end=2074
-  _globals['_EXECUTOR']._serialized_start=2077
-  _globals['_EXECUTOR']._serialized_end=2205
-  _globals['_EXECUTORLIST']._serialized_start=2207
-  _globals['_EXECUTORLIST']._serialized_end=2260
-  _globals['_SESSIONLIST']._serialized_start=2262
-  _globals['_SESSIONLIST']._serialized_end=2312
-  _globals['_APPLICATIONLIST']._serialized_start=2314
-  _globals['_APPLICATIONLIST']._serialized_end=2376
-  _globals['_RESOURCEREQUIREMENT']._serialized_start=2378
-  _globals['_RESOURCEREQUIREMENT']._serialized_end=2441
-  _globals['_NODESPEC']._serialized_start=2443
-  _globals['_NODESPEC']._serialized_end=2471
-  _globals['_NODEINFO']._serialized_start=2473
-  _globals['_NODEINFO']._serialized_end=2509
-  _globals['_NODEADDRESS']._serialized_start=2511
-  _globals['_NODEADDRESS']._serialized_end=2555
-  _globals['_NODESTATUS']._serialized_start=2558
-  _globals['_NODESTATUS']._serialized_end=2812
-  _globals['_NODE']._serialized_start=2814
-  _globals['_NODE']._serialized_end=2930
-  _globals['_NODELIST']._serialized_start=2932
-  _globals['_NODELIST']._serialized_end=2973
-  _globals['_RESULT']._serialized_start=2975
-  _globals['_RESULT']._serialized_end=3038
-  _globals['_TASKRESULT']._serialized_start=3040
-  _globals['_TASKRESULT']._serialized_end=3139
-  _globals['_EMPTYREQUEST']._serialized_start=3141
-  _globals['_EMPTYREQUEST']._serialized_end=3155
-  _globals['_EVENT']._serialized_start=3157
-  _globals['_EVENT']._serialized_end=3235
+  _globals['_METADATA']._serialized_end=113
+  _globals['_SESSIONSTATUS']._serialized_start=116
+  _globals['_SESSIONSTATUS']._serialized_end=362
+  _globals['_SESSIONSPEC']._serialized_start=365
+  _globals['_SESSIONSPEC']._serialized_end=545
+  _globals['_SESSION']._serialized_start=547
+  _globals['_SESSION']._serialized_end=672
+  _globals['_TASKSTATUS']._serialized_start=675
+  _globals['_TASKSTATUS']._serialized_end=829
+  _globals['_TASKSPEC']._serialized_start=831
+  _globals['_TASKSPEC']._serialized_end=923
+  _globals['_TASK']._serialized_start=925
+  _globals['_TASK']._serialized_end=1041
+  _globals['_APPLICATIONSTATUS']._serialized_start=1043
+  _globals['_APPLICATIONSTATUS']._serialized_end=1128
+  _globals['_ENVIRONMENT']._serialized_start=1130
+  _globals['_ENVIRONMENT']._serialized_end=1172
+  _globals['_APPLICATIONSCHEMA']._serialized_start=1174
+  _globals['_APPLICATIONSCHEMA']._serialized_end=1297
+  _globals['_APPLICATIONSPEC']._serialized_start=1300
+  _globals['_APPLICATIONSPEC']._serialized_end=1766
+  _globals['_APPLICATION']._serialized_start=1769
+  _globals['_APPLICATION']._serialized_end=1906
+  _globals['_EXECUTORSPEC']._serialized_start=1908
+  _globals['_EXECUTORSPEC']._serialized_end=2028
+  _globals['_EXECUTORSTATUS']._serialized_start=2030
+  _globals['_EXECUTORSTATUS']._serialized_end=2126
+  _globals['_EXECUTOR']._serialized_start=2129
+  _globals['_EXECUTOR']._serialized_end=2257
+  _globals['_EXECUTORLIST']._serialized_start=2259
+  _globals['_EXECUTORLIST']._serialized_end=2312
+  _globals['_SESSIONLIST']._serialized_start=2314
+  _globals['_SESSIONLIST']._serialized_end=2364
+  _globals['_APPLICATIONLIST']._serialized_start=2366
+  _globals['_APPLICATIONLIST']._serialized_end=2428
+  _globals['_RESOURCEREQUIREMENT']._serialized_start=2430
+  _globals['_RESOURCEREQUIREMENT']._serialized_end=2493
+  _globals['_NODESPEC']._serialized_start=2495
+  _globals['_NODESPEC']._serialized_end=2523
+  _globals['_NODEINFO']._serialized_start=2525
+  _globals['_NODEINFO']._serialized_end=2561
+  _globals['_NODEADDRESS']._serialized_start=2563
+  _globals['_NODEADDRESS']._serialized_end=2607
+  _globals['_NODESTATUS']._serialized_start=2610
+  _globals['_NODESTATUS']._serialized_end=2864
+  _globals['_NODE']._serialized_start=2866
+  _globals['_NODE']._serialized_end=2982
+  _globals['_NODELIST']._serialized_start=2984
+  _globals['_NODELIST']._serialized_end=3025
+  _globals['_RESULT']._serialized_start=3027
+  _globals['_RESULT']._serialized_end=3090
+  _globals['_TASKRESULT']._serialized_start=3092
+  _globals['_TASKRESULT']._serialized_end=3191
+  _globals['_EMPTYREQUEST']._serialized_start=3193
+  _globals['_EMPTYREQUEST']._serialized_end=3207
+  _globals['_EVENT']._serialized_start=3209
+  _globals['_EVENT']._serialized_end=3287
 # @@protoc_insertion_point(module_scope)
@@ -100,3 +100,41 @@ def test_session_create_task_with_mocked_frontend(monkeypatch):
     t = s.create_task(b"input")
     assert t.session_id == s.id
     assert t.id is not None
+
+
+def test_update_application_with_resource_version():
+    import grpc
+
+    from flamepy.core.types import ApplicationAttributes, FlameError, FlameErrorCode
+
+    class Conflict(grpc.RpcError):
+        def code(self):
+            return grpc.StatusCode.FAILED_PRECONDITION
+
+        def details(self):
+            return "application <app> was modified"
+
+    class DummyFrontend:
+        def __init__(self):
+            self.requests = []
+
+        def UpdateApplication(self, req):
+            self.requests.append(req)
+            if req.resource_version != 2:
+                raise Conflict()
+
+    frontend = DummyFrontend()
+    conn = client.Connection("http://localhost:1234", DummyChannel("localhost:1234"), frontend)
+
+    conn.update_application("app", ApplicationAttributes(command="/bin/app"), resource_version=2)
+    assert frontend.requests[-1].HasField("resource_version")
+
+    with pytest.raises(FlameError) as e:
+        conn.update_application("app", ApplicationAttributes(command="/bin/app"), resource_version=1)
+    assert e.value.code == FlameErrorCode.VERSION_MISMATCH
+
+    # Without the version, the application is updated unconditionally.
+    with pytest.raises(FlameError):
+        conn.update_application("app", {"command": "/bin/app"})
+    assert not frontend.requests[-1].HasField("resource_version")
+    conn.close()
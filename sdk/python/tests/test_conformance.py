@@ -0,0 +1,60 @@
+"""Verify the Python SDK against the conformance fixtures shared by the SDKs of all languages."""
+
+import json
+from pathlib import Path
+
+import grpc
+import pytest
+
+from flamepy.core.client import error_of
+from flamepy.core.types import ApplicationState, FlameError, FlameErrorCode, SessionState, Shim, Task, TaskState, checksum, verify_checksum
+
+FIXTURES = json.loads((Path(__file__).parents[2] / "conformance" / "fixtures.json").read_text())
+
+ENUMS = {
+    "SessionState": SessionState,
+    "TaskState": TaskState,
+    "ApplicationState": ApplicationState,
+    "Shim": Shim,
+}
+
+
+@pytest.mark.parametrize("name", sorted(ENUMS))
+def test_conformance_enums(name):
+    enum = ENUMS[name]
+    expected = FIXTURES["enums"][name]
+
+    assert {e.name: e.value for e in enum} == {k.upper(): v for k, v in expected.items()}
+
+
+@pytest.mark.parametrize("group,terminal", [("terminal", True), ("non_terminal", False)])
+def test_conformance_task_states(group, terminal):
+    for name in FIXTURES["task_states"][group]:
+        task = Task(id="1", session_id="ssn-1", state=TaskState[name.upper()], creation_time=None)
+        assert task.is_completed() == terminal
+
+
+@pytest.mark.parametrize("payload", FIXTURES["payloads"], ids=lambda p: p["name"])
+def test_conformance_payloads(payload):
+    data = bytes.fromhex(payload["hex"])
+
+    assert checksum(data) == payload["sha256"]
+    verify_checksum("payload", data, payload["sha256"])
+    with pytest.raises(FlameError) as e:
+        verify_checksum("payload", data + b"\0", payload["sha256"])
+    assert e.value.code == FlameErrorCode.INTEGRITY
+
+
+ERRORS = {
+    "NotFound": FlameErrorCode.NOT_FOUND,
+    "Internal": FlameErrorCode.INTERNAL,
+    "Integrity": FlameErrorCode.INTEGRITY,
+    "VersionMismatch": FlameErrorCode.VERSION_MISMATCH,
+}
+
+
+@pytest.mark.parametrize("name,code", sorted(FIXTURES["status_codes"].items()))
+def test_conformance_status_codes(name, code):
+    status = next(s for s in grpc.StatusCode if s.value[0] == code)
+
+    assert error_of(status, name).code == ERRORS[name]
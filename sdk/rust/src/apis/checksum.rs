@@ -0,0 +1,32 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//! The checksums of task inputs and outputs, in the format of
+//! `<algorithm>:<hex digest>`, e.g. `xxh3:9a3d2f...`, by `stdng::checksum`
+//! so they're the same as the ones of the session manager.
+
+pub use stdng::checksum::{checksum, checksum_with, ChecksumAlgorithm, Hasher};
+
+use crate::apis::FlameError;
+
+/// The algorithm of the checksum, e.g. to verify the data received in chunks
+/// by a `Hasher`.
+pub fn algorithm_of(name: &str, checksum: &str) -> Result<ChecksumAlgorithm, FlameError> {
+    Ok(stdng::checksum::algorithm_of(name, checksum)?)
+}
+
+/// Verify the data against the expected checksum; the data without checksum,
+/// e.g. from an older client, is passed.
+pub fn verify(name: &str, data: Option<&[u8]>, expected: Option<&str>) -> Result<(), FlameError> {
+    Ok(stdng::checksum::verify(name, data, expected)?)
+}
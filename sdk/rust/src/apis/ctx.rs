@@ -16,6 +16,7 @@ use std::env;
 use std::fmt::{Display, Formatter};
 use std::fs;
 use std::path::Path;
+#[cfg(feature = "tls")]
 use tonic::transport::{Certificate, ClientTlsConfig};
 
 use crate::apis::FlameError;
@@ -36,6 +37,7 @@ pub struct FlameClientTls {
     pub ca_file: Option<String>,
 }
 
+#[cfg(feature = "tls")]
 impl FlameClientTls {
     /// Load client TLS config for tonic.
     ///
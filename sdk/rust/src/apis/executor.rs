@@ -0,0 +1,298 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//! The state machine of executors, as enforced by the session manager; the
+//! executor implementations drive it by the events of the backend API. The
+//! transitions are the same as `common::apis::executor`, which both the session
+//! manager and the executor manager move the executors by.
+//!
+//! ```text
+//!  Void --register--> Idle --bind--> Binding --bind completed--> Bound
+//!                      ^  \                                     |  ^
+//!                      |   release                         unbind  launch/complete
+//!                      |    v                                   v  |
+//!        unbind completed  Releasing --unregister--> Released  Unbinding
+//! ```
+
+use std::sync::Arc;
+
+use crate::apis::{ExecutorState, FlameError};
+
+#[derive(
+    Clone, Copy, Debug, PartialEq, Eq, Hash, strum_macros::Display, strum_macros::EnumIter,
+)]
+pub enum ExecutorEvent {
+    Register,
+    BindSession,
+    BindSessionCompleted,
+    LaunchTask,
+    CompleteTask,
+    Unbind,
+    UnbindCompleted,
+    Release,
+    Unregister,
+}
+
+/// The transitions of executors: (current state, event, next state).
+pub const TRANSITIONS: &[(ExecutorState, ExecutorEvent, ExecutorState)] = &[
+    (
+        ExecutorState::Void,
+        ExecutorEvent::Register,
+        ExecutorState::Idle,
+    ),
+    (
+        ExecutorState::Idle,
+        ExecutorEvent::BindSession,
+        ExecutorState::Binding,
+    ),
+    (
+        ExecutorState::Idle,
+        ExecutorEvent::Release,
+        ExecutorState::Releasing,
+    ),
+    (
+        ExecutorState::Binding,
+        ExecutorEvent::BindSession,
+        ExecutorState::Binding,
+    ),
+    (
+        ExecutorState::Binding,
+        ExecutorEvent::BindSessionCompleted,
+        ExecutorState::Bound,
+    ),
+    (
+        ExecutorState::Bound,
+        ExecutorEvent::LaunchTask,
+        ExecutorState::Bound,
+    ),
+    (
+        ExecutorState::Bound,
+        ExecutorEvent::CompleteTask,
+        ExecutorState::Bound,
+    ),
+    (
+        ExecutorState::Bound,
+        ExecutorEvent::Unbind,
+        ExecutorState::Unbinding,
+    ),
+    // The in-flight task is completed while unbinding, and no more tasks are
+    // launched.
+    (
+        ExecutorState::Unbinding,
+        ExecutorEvent::Unbind,
+        ExecutorState::Unbinding,
+    ),
+    (
+        ExecutorState::Unbinding,
+        ExecutorEvent::LaunchTask,
+        ExecutorState::Unbinding,
+    ),
+    (
+        ExecutorState::Unbinding,
+        ExecutorEvent::CompleteTask,
+        ExecutorState::Unbinding,
+    ),
+    (
+        ExecutorState::Unbinding,
+        ExecutorEvent::UnbindCompleted,
+        ExecutorState::Idle,
+    ),
+    (
+        ExecutorState::Releasing,
+        ExecutorEvent::Unregister,
+        ExecutorState::Released,
+    ),
+];
+
+/// Get the next state of the executor by the event, or an error if the event
+/// is not allowed in the current state.
+pub fn transit(state: ExecutorState, event: ExecutorEvent) -> Result<ExecutorState, FlameError> {
+    TRANSITIONS
+        .iter()
+        .find(|(from, ev, _)| *from == state && *ev == event)
+        .map(|(_, _, to)| *to)
+        .ok_or(FlameError::InvalidState(format!(
+            "event <{event}> is not allowed in executor state <{state}>"
+        )))
+}
+
+/// The hook of the transitions, e.g. to provision resources when the executor
+/// is bound; an error of `before_transit` rejects the transition.
+pub trait TransitionHook: Send + Sync {
+    fn before_transit(
+        &self,
+        _from: ExecutorState,
+        _event: ExecutorEvent,
+        _to: ExecutorState,
+    ) -> Result<(), FlameError> {
+        Ok(())
+    }
+
+    fn after_transit(&self, _from: ExecutorState, _event: ExecutorEvent, _to: ExecutorState) {}
+}
+
+pub type TransitionHookPtr = Arc<dyn TransitionHook>;
+
+/// The state machine of an executor with the hooks of its transitions.
+#[derive(Clone)]
+pub struct ExecutorStateMachine {
+    state: ExecutorState,
+    hooks: Vec<TransitionHookPtr>,
+}
+
+impl Default for ExecutorStateMachine {
+    fn default() -> Self {
+        Self::new(ExecutorState::Void)
+    }
+}
+
+impl ExecutorStateMachine {
+    pub fn new(state: ExecutorState) -> Self {
+        Self {
+            state,
+            hooks: vec![],
+        }
+    }
+
+    pub fn with_hook(mut self, hook: TransitionHookPtr) -> Self {
+        self.hooks.push(hook);
+        self
+    }
+
+    pub fn state(&self) -> ExecutorState {
+        self.state
+    }
+
+    /// Whether the event is allowed in the current state.
+    pub fn can_fire(&self, event: ExecutorEvent) -> bool {
+        transit(self.state, event).is_ok()
+    }
+
+    /// Fire the event and move to the next state; the state is not changed if
+    /// the transition is invalid or rejected by a hook.
+    pub fn fire(&mut self, event: ExecutorEvent) -> Result<ExecutorState, FlameError> {
+        let from = self.state;
+        let to = transit(from, event)?;
+
+        for hook in &self.hooks {
+            hook.before_transit(from, event, to)?;
+        }
+
+        self.state = to;
+
+        for hook in &self.hooks {
+            hook.after_transit(from, event, to);
+        }
+
+        Ok(to)
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use std::sync::Mutex;
+
+    use strum::IntoEnumIterator;
+
+    use super::*;
+
+    #[derive(Default)]
+    struct RecordHook {
+        history: Mutex<Vec<(ExecutorState, ExecutorEvent, ExecutorState)>>,
+        reject: Option<ExecutorEvent>,
+    }
+
+    impl TransitionHook for RecordHook {
+        fn before_transit(
+            &self,
+            _: ExecutorState,
+            event: ExecutorEvent,
+            _: ExecutorState,
+        ) -> Result<(), FlameError> {
+            match self.reject {
+                Some(reject) if reject == event => {
+                    Err(FlameError::Internal(format!("<{event}> was rejected")))
+                }
+                _ => Ok(()),
+            }
+        }
+
+        fn after_transit(&self, from: ExecutorState, event: ExecutorEvent, to: ExecutorState) {
+            self.history.lock().unwrap().push((from, event, to));
+        }
+    }
+
+    #[test]
+    fn test_executor_lifecycle() {
+        let hook = Arc::new(RecordHook::default());
+        let mut fsm = ExecutorStateMachine::default().with_hook(hook.clone());
+
+        for (event, state) in [
+            (ExecutorEvent::Register, ExecutorState::Idle),
+            (ExecutorEvent::BindSession, ExecutorState::Binding),
+            (ExecutorEvent::BindSessionCompleted, ExecutorState::Bound),
+            (ExecutorEvent::LaunchTask, ExecutorState::Bound),
+            (ExecutorEvent::CompleteTask, ExecutorState::Bound),
+            (ExecutorEvent::Unbind, ExecutorState::Unbinding),
+            (ExecutorEvent::UnbindCompleted, ExecutorState::Idle),
+            (ExecutorEvent::Release, ExecutorState::Releasing),
+            (ExecutorEvent::Unregister, ExecutorState::Released),
+        ] {
+            assert_eq!(fsm.fire(event).unwrap(), state);
+        }
+
+        assert_eq!(hook.history.lock().unwrap().len(), 9);
+    }
+
+    #[test]
+    fn test_invalid_transition() {
+        let mut fsm = ExecutorStateMachine::new(ExecutorState::Idle);
+
+        assert!(!fsm.can_fire(ExecutorEvent::LaunchTask));
+        assert!(matches!(
+            fsm.fire(ExecutorEvent::LaunchTask),
+            Err(FlameError::InvalidState(_))
+        ));
+        assert_eq!(fsm.state(), ExecutorState::Idle);
+
+        // No event is allowed after the executor was released.
+        for event in ExecutorEvent::iter() {
+            assert!(transit(ExecutorState::Released, event).is_err());
+        }
+    }
+
+    #[test]
+    fn test_rejected_by_hook() {
+        let hook = Arc::new(RecordHook {
+            reject: Some(ExecutorEvent::BindSession),
+            ..Default::default()
+        });
+        let mut fsm = ExecutorStateMachine::new(ExecutorState::Idle).with_hook(hook.clone());
+
+        assert!(fsm.fire(ExecutorEvent::BindSession).is_err());
+        assert_eq!(fsm.state(), ExecutorState::Idle);
+        assert!(hook.history.lock().unwrap().is_empty());
+    }
+
+    #[test]
+    fn test_transitions_are_deterministic() {
+        for (i, (from, event, _)) in TRANSITIONS.iter().enumerate() {
+            assert!(
+                !TRANSITIONS[i + 1..]
+                    .iter()
+                    .any(|(f, e, _)| f == from && e == event),
+                "duplicated transition <{from}, {event}>"
+            );
+        }
+    }
+}
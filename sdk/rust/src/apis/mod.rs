@@ -28,7 +28,11 @@ use tonic::Status;
 use tracing_subscriber::filter::{FromEnvError, ParseError};
 use tracing_subscriber::fmt::time::LocalTime;
 
+pub mod checksum;
+pub mod crypto;
 mod ctx;
+pub mod errors;
+pub mod executor;
 pub use ctx::FlameClientCache;
 pub use ctx::FlameClientTls;
 pub use ctx::FlameClusterConfig;
@@ -46,6 +50,10 @@ pub type TaskInput = Message;
 pub type TaskOutput = Message;
 pub type CommonData = Message;
 
+/// The label of a task for the method of the service to invoke, e.g.
+/// `flame.io/method=resize`; it's dispatched by `service::Router`.
+pub const LABEL_METHOD: &str = "flame.io/method";
+
 #[derive(Encode, Decode, PartialEq, Eq)]
 pub enum DataSource {
     Local,
@@ -100,11 +108,41 @@ pub enum FlameError {
 
     #[error("{0}")]
     InvalidConfig(String),
+
+    #[error("{0}")]
+    Integrity(String),
+
+    #[error("{0}")]
+    InvalidState(String),
+
+    #[error("{0}")]
+    VersionMismatch(String),
+
+    #[error("{0}")]
+    AlreadyExist(String),
+
+    #[error("{0}")]
+    Uninitialized(String),
+
+    #[error("{0}")]
+    Storage(String),
+
+    #[error("{0}")]
+    QuotaExceeded(String),
+
+    #[error("{0}")]
+    Cancelled(String),
+
+    #[error("{0}")]
+    PermissionDenied(String),
 }
 
 impl From<stdng::Error> for FlameError {
     fn from(value: stdng::Error) -> Self {
-        FlameError::Internal(value.to_string())
+        match value {
+            stdng::Error::Integrity(msg) => FlameError::Integrity(msg),
+            _ => FlameError::Internal(value.to_string()),
+        }
     }
 }
 
@@ -159,6 +197,8 @@ pub enum ApplicationState {
 pub enum Shim {
     Host = 0,
     Wasm = 1,
+    Grpc = 2,
+    Http = 3,
 }
 
 #[derive(
@@ -180,6 +220,11 @@ impl From<FlameError> for Status {
         match value {
             FlameError::NotFound(s) => Status::not_found(s),
             FlameError::Internal(s) => Status::internal(s),
+            FlameError::Integrity(s) => Status::data_loss(s),
+            FlameError::VersionMismatch(s) => Status::failed_precondition(s),
+            FlameError::QuotaExceeded(s) => Status::resource_exhausted(s),
+            FlameError::Cancelled(s) => Status::cancelled(s),
+            FlameError::PermissionDenied(s) => Status::permission_denied(s),
             _ => Status::unknown(value.to_string()),
         }
     }
@@ -187,7 +232,7 @@ impl From<FlameError> for Status {
 
 impl From<Status> for FlameError {
     fn from(value: Status) -> Self {
-        FlameError::Network(value.message().to_string())
+        errors::error_of(errors::code_of(&value), value.message().to_string())
     }
 }
 
@@ -196,6 +241,8 @@ impl From<rpc::Shim> for Shim {
         match shim {
             rpc::Shim::Host => Shim::Host,
             rpc::Shim::Wasm => Shim::Wasm,
+            rpc::Shim::Grpc => Shim::Grpc,
+            rpc::Shim::Http => Shim::Http,
         }
     }
 }
@@ -246,3 +293,121 @@ pub fn init_logger() -> Result<(), FlameError> {
 
     Ok(())
 }
+
+#[cfg(test)]
+mod tests {
+    use std::collections::HashMap;
+
+    use serde_json::Value;
+
+    use super::*;
+
+    /// The conformance fixtures shared by the SDKs of all languages.
+    const FIXTURES: &str = include_str!("../../../conformance/fixtures.json");
+
+    fn fixtures() -> Value {
+        serde_json::from_str(FIXTURES).unwrap()
+    }
+
+    fn enum_fixture(name: &str) -> HashMap<String, i32> {
+        serde_json::from_value(fixtures()["enums"][name].clone()).unwrap()
+    }
+
+    fn decode_hex(s: &str) -> Vec<u8> {
+        (0..s.len())
+            .step_by(2)
+            .map(|i| u8::from_str_radix(&s[i..i + 2], 16).unwrap())
+            .collect()
+    }
+
+    #[test]
+    fn test_conformance_enums() {
+        for (name, value) in enum_fixture("SessionState") {
+            assert_eq!(SessionState::try_from(value).unwrap().to_string(), name);
+            assert_eq!(
+                rpc::SessionState::from_str_name(&name),
+                Some(rpc::SessionState::try_from(value).unwrap())
+            );
+        }
+        for (name, value) in enum_fixture("TaskState") {
+            assert_eq!(TaskState::try_from(value).unwrap().to_string(), name);
+            assert_eq!(
+                rpc::TaskState::from_str_name(&name),
+                Some(rpc::TaskState::try_from(value).unwrap())
+            );
+        }
+        for (name, value) in enum_fixture("ApplicationState") {
+            assert_eq!(ApplicationState::try_from(value).unwrap().to_string(), name);
+            assert_eq!(
+                rpc::ApplicationState::from_str_name(&name),
+                Some(rpc::ApplicationState::try_from(value).unwrap())
+            );
+        }
+        for (name, value) in enum_fixture("Shim") {
+            assert_eq!(Shim::try_from(value).unwrap().to_string(), name);
+            assert_eq!(
+                rpc::Shim::from_str_name(&name),
+                Some(rpc::Shim::try_from(value).unwrap())
+            );
+        }
+    }
+
+    #[test]
+    fn test_conformance_task_states() {
+        let states = enum_fixture("TaskState");
+        let fixtures = fixtures();
+
+        for (group, terminal) in [("terminal", true), ("non_terminal", false)] {
+            for name in fixtures["task_states"][group].as_array().unwrap() {
+                let value = states[name.as_str().unwrap()];
+                assert_eq!(TaskState::try_from(value).unwrap().is_terminal(), terminal);
+            }
+        }
+    }
+
+    #[test]
+    fn test_conformance_payloads() {
+        for payload in fixtures()["payloads"].as_array().unwrap() {
+            let data = decode_hex(payload["hex"].as_str().unwrap());
+            let expected = payload["sha256"].as_str().unwrap();
+
+            assert_eq!(
+                checksum::checksum_with(checksum::ChecksumAlgorithm::Sha256, &data),
+                expected
+            );
+            assert!(checksum::verify("payload", Some(&data), Some(expected)).is_ok());
+        }
+    }
+
+    #[test]
+    fn test_conformance_status_codes() {
+        let codes: HashMap<String, i32> =
+            serde_json::from_value(fixtures()["status_codes"].clone()).unwrap();
+
+        let errors = [
+            ("NotFound", FlameError::NotFound("task".to_string())),
+            ("Internal", FlameError::Internal("internal".to_string())),
+            ("Integrity", FlameError::Integrity("checksum".to_string())),
+            (
+                "VersionMismatch",
+                FlameError::VersionMismatch("application".to_string()),
+            ),
+        ];
+        for (name, err) in errors {
+            assert_eq!(Status::from(err.clone()).code() as i32, codes[name]);
+            // The typed errors are kept across the wire, except NotFound and
+            // Internal which are reported as network errors by the client.
+            match name {
+                "Integrity" => assert!(matches!(
+                    FlameError::from(Status::from(err)),
+                    FlameError::Integrity(_)
+                )),
+                "VersionMismatch" => assert!(matches!(
+                    FlameError::from(Status::from(err)),
+                    FlameError::VersionMismatch(_)
+                )),
+                _ => {}
+            }
+        }
+    }
+}
@@ -0,0 +1,290 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//! The client-side cache of the fetched task outputs, so the repeated reads,
+//! e.g. the retries or several consumers in the process, do not download the
+//! outputs from the cluster again, e.g.
+//!
+//! ```ignore
+//! let cache = Arc::new(OutputCache::new(256 * 1024 * 1024));
+//! let session = session.with_output_cache(cache.clone());
+//! let output = session.fetch_output(&task.id).await?;
+//! ```
+//!
+//! The outputs are keyed by the task and its checksum: the cached checksum is
+//! sent with the request, and the session manager only returns the output if
+//! it's changed, e.g. the task was retried; so a stale output is never read.
+
+use std::collections::{BTreeMap, HashMap};
+use std::sync::{Arc, Mutex};
+
+use stdng::{lock_ptr, trace_fn};
+use tokio_stream::StreamExt;
+
+use crate::apis::checksum;
+use crate::apis::{FlameError, SessionID, TaskID, TaskOutput};
+use crate::client::rpc::GetTaskOutputRequest;
+use crate::client::Session;
+
+pub type OutputCachePtr = Arc<OutputCache>;
+
+/// The in-memory cache of the outputs of at most `capacity` bytes; the least
+/// recently used output is evicted first.
+pub struct OutputCache {
+    capacity: usize,
+    entries: Mutex<CacheEntries>,
+}
+
+type CacheKey = (SessionID, TaskID);
+
+struct CacheEntry {
+    checksum: String,
+    output: TaskOutput,
+    /// The tick of the last use, i.e. the key in `CacheEntries::order`.
+    tick: u64,
+}
+
+#[derive(Default)]
+struct CacheEntries {
+    outputs: HashMap<CacheKey, CacheEntry>,
+    order: BTreeMap<u64, CacheKey>,
+    tick: u64,
+    size: usize,
+}
+
+impl CacheEntries {
+    fn remove(&mut self, key: &CacheKey) {
+        if let Some(entry) = self.outputs.remove(key) {
+            self.order.remove(&entry.tick);
+            self.size -= entry.output.len();
+        }
+    }
+}
+
+impl OutputCache {
+    pub fn new(capacity: usize) -> Self {
+        Self {
+            capacity,
+            entries: Mutex::new(CacheEntries::default()),
+        }
+    }
+
+    /// The checksum and the output of the task, if cached.
+    pub fn get(
+        &self,
+        ssn_id: &str,
+        task_id: &str,
+    ) -> Result<Option<(String, TaskOutput)>, FlameError> {
+        let mut entries = lock_ptr!(self.entries)?;
+        let key = (ssn_id.to_string(), task_id.to_string());
+        entries.tick += 1;
+        let tick = entries.tick;
+
+        let Some(entry) = entries.outputs.get_mut(&key) else {
+            return Ok(None);
+        };
+        let last = std::mem::replace(&mut entry.tick, tick);
+        let cached = (entry.checksum.clone(), entry.output.clone());
+        entries.order.remove(&last);
+        entries.order.insert(tick, key);
+
+        Ok(Some(cached))
+    }
+
+    /// Cache the output of the task; the output larger than the capacity is
+    /// not cached.
+    pub fn put(
+        &self,
+        ssn_id: &str,
+        task_id: &str,
+        checksum: String,
+        output: TaskOutput,
+    ) -> Result<(), FlameError> {
+        let mut entries = lock_ptr!(self.entries)?;
+        let key = (ssn_id.to_string(), task_id.to_string());
+        entries.remove(&key);
+        if output.len() > self.capacity {
+            return Ok(());
+        }
+
+        while entries.size + output.len() > self.capacity {
+            let Some((_, oldest)) = entries.order.pop_first() else {
+                break;
+            };
+            entries.remove(&oldest);
+        }
+
+        entries.tick += 1;
+        let tick = entries.tick;
+        entries.size += output.len();
+        entries.order.insert(tick, key.clone());
+        entries.outputs.insert(
+            key,
+            CacheEntry {
+                checksum,
+                output,
+                tick,
+            },
+        );
+
+        Ok(())
+    }
+
+    /// Remove the output of the task.
+    pub fn invalidate(&self, ssn_id: &str, task_id: &str) -> Result<(), FlameError> {
+        let mut entries = lock_ptr!(self.entries)?;
+        entries.remove(&(ssn_id.to_string(), task_id.to_string()));
+
+        Ok(())
+    }
+
+    /// Remove the outputs of the session, e.g. after it's closed.
+    pub fn invalidate_session(&self, ssn_id: &str) -> Result<(), FlameError> {
+        let mut entries = lock_ptr!(self.entries)?;
+        let keys: Vec<_> = entries
+            .outputs
+            .keys()
+            .filter(|(id, _)| id == ssn_id)
+            .cloned()
+            .collect();
+        for key in keys {
+            entries.remove(&key);
+        }
+
+        Ok(())
+    }
+
+    /// The bytes of the cached outputs.
+    pub fn size(&self) -> Result<usize, FlameError> {
+        Ok(lock_ptr!(self.entries)?.size)
+    }
+}
+
+impl Session {
+    /// Cache the outputs fetched by `fetch_output` in the cache, which may be
+    /// shared by the sessions.
+    pub fn with_output_cache(mut self, cache: OutputCachePtr) -> Self {
+        self.output_cache = Some(cache);
+        self
+    }
+
+    /// The output of the completed task; it's read from the output cache of
+    /// the session if it's not changed. None if the task has no output.
+    pub async fn fetch_output(&self, id: &TaskID) -> Result<Option<TaskOutput>, FlameError> {
+        trace_fn!("Session::fetch_output");
+        let mut client = self
+            .client
+            .clone()
+            .ok_or(FlameError::Internal("no flame client".to_string()))?;
+
+        let cached = match &self.output_cache {
+            Some(cache) => cache.get(&self.id, id)?,
+            None => None,
+        };
+
+        let mut chunks = client
+            .get_task_output(GetTaskOutputRequest {
+                session_id: self.id.clone(),
+                task_id: id.clone(),
+                if_none_match: cached.as_ref().map(|(checksum, _)| checksum.clone()),
+            })
+            .await?
+            .into_inner();
+
+        let mut expected = None;
+        let mut output = vec![];
+        while let Some(chunk) = chunks.next().await {
+            let chunk = chunk?;
+            if chunk.checksum.is_some() {
+                expected = chunk.checksum;
+            }
+            output.extend_from_slice(&chunk.chunk);
+        }
+
+        let Some(expected) = expected else {
+            return Ok(None);
+        };
+        if let Some((checksum, cached)) = cached {
+            if checksum == expected && output.is_empty() {
+                return Ok(Some(cached));
+            }
+        }
+
+        checksum::verify(
+            &format!("output of task <{}/{id}>", self.id),
+            Some(&output),
+            Some(&expected),
+        )?;
+        let output = TaskOutput::from(output);
+        if let Some(cache) = &self.output_cache {
+            cache.put(&self.id, id, expected, output.clone())?;
+        }
+
+        Ok(Some(output))
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use bytes::Bytes;
+
+    use super::*;
+
+    fn put(cache: &OutputCache, task_id: &str, output: &'static str) {
+        cache
+            .put(
+                "ssn-1",
+                task_id,
+                format!("sha256:{task_id}"),
+                Bytes::from(output),
+            )
+            .unwrap();
+    }
+
+    fn cached(cache: &OutputCache, task_id: &str) -> Option<Bytes> {
+        cache
+            .get("ssn-1", task_id)
+            .unwrap()
+            .map(|(_, output)| output)
+    }
+
+    #[test]
+    fn test_output_cache() {
+        let cache = OutputCache::new(10);
+        put(&cache, "1", "one");
+        put(&cache, "2", "two");
+        put(&cache, "3", "three");
+
+        // The least recently used output is evicted.
+        assert_eq!(cached(&cache, "1"), None);
+        assert_eq!(cache.size().unwrap(), 8);
+        assert_eq!(
+            cache.get("ssn-1", "2").unwrap(),
+            Some(("sha256:2".to_string(), Bytes::from("two")))
+        );
+        put(&cache, "4", "four");
+        assert_eq!(cached(&cache, "3"), None);
+        assert_eq!(cached(&cache, "2"), Some(Bytes::from("two")));
+        assert_eq!(cache.size().unwrap(), 7);
+
+        // The output larger than the capacity is not cached.
+        put(&cache, "5", "eleven byte");
+        assert_eq!(cached(&cache, "5"), None);
+
+        cache.invalidate("ssn-1", "2").unwrap();
+        assert_eq!(cached(&cache, "2"), None);
+        assert_eq!(cache.size().unwrap(), 4);
+        cache.invalidate_session("ssn-1").unwrap();
+        assert_eq!(cache.size().unwrap(), 0);
+    }
+}
@@ -0,0 +1,134 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//! The tasks of a session with the dependencies between them, e.g.
+//!
+//! ```ignore
+//! let mut graph = TaskGraph::new();
+//! let extract = graph.add(Some(input), &[])?;
+//! let left = graph.add(Some(left_input), &[extract])?;
+//! let right = graph.add(Some(right_input), &[extract])?;
+//! graph.add(None, &[left, right])?;
+//! let tasks = session.submit_graph(graph).await?;
+//! ```
+//!
+//! The session manager only launches a task after all of its parents
+//! succeeded, and fails it if any of them failed; so the whole graph is
+//! submitted at once, instead of waiting for each level.
+
+use stdng::trace_fn;
+
+use crate::apis::{FlameError, TaskID, TaskInput};
+use crate::client::{Session, Task};
+
+/// A task in the graph, i.e. its index in the order of `TaskGraph::add`.
+#[derive(Clone, Copy, Debug, PartialEq, Eq)]
+pub struct TaskNode(usize);
+
+struct GraphTask {
+    input: Option<TaskInput>,
+    depends_on: Vec<TaskNode>,
+}
+
+/// The tasks to be submitted together; a task only depends on the tasks
+/// added before it, so there's no cycle.
+#[derive(Default)]
+pub struct TaskGraph {
+    tasks: Vec<GraphTask>,
+}
+
+impl TaskGraph {
+    pub fn new() -> Self {
+        Self::default()
+    }
+
+    /// Add a task which depends on the tasks added before.
+    pub fn add(
+        &mut self,
+        input: Option<TaskInput>,
+        depends_on: &[TaskNode],
+    ) -> Result<TaskNode, FlameError> {
+        if let Some(node) = depends_on.iter().find(|node| node.0 >= self.tasks.len()) {
+            return Err(FlameError::InvalidConfig(format!(
+                "unknown task <{}> in the graph",
+                node.0
+            )));
+        }
+
+        self.tasks.push(GraphTask {
+            input,
+            depends_on: depends_on.to_vec(),
+        });
+        Ok(TaskNode(self.tasks.len() - 1))
+    }
+
+    pub fn len(&self) -> usize {
+        self.tasks.len()
+    }
+
+    pub fn is_empty(&self) -> bool {
+        self.tasks.is_empty()
+    }
+}
+
+impl Session {
+    /// Create a task which is not launched until the tasks of the session it
+    /// depends on succeeded; the task is failed if any of them failed.
+    pub async fn create_dependent_task(
+        &self,
+        input: Option<TaskInput>,
+        depends_on: &[TaskID],
+    ) -> Result<Task, FlameError> {
+        trace_fn!("Session::create_dependent_task");
+        let mut task_spec = self.task_spec(input, vec![], None);
+        task_spec.depends_on = depends_on.to_vec();
+        self.submit_spec(task_spec).await
+    }
+
+    /// Create the tasks of the graph in the order they were added, so the
+    /// parents are always created before their children; the tasks are
+    /// returned in the same order. The tasks created before an error are
+    /// not cancelled.
+    pub async fn submit_graph(&self, graph: TaskGraph) -> Result<Vec<Task>, FlameError> {
+        trace_fn!("Session::submit_graph");
+        let mut tasks: Vec<Task> = Vec::with_capacity(graph.len());
+        for task in graph.tasks {
+            let depends_on = task
+                .depends_on
+                .iter()
+                .map(|node| tasks[node.0].id.clone())
+                .collect::<Vec<_>>();
+            tasks.push(self.create_dependent_task(task.input, &depends_on).await?);
+        }
+
+        Ok(tasks)
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_task_graph() {
+        let mut graph = TaskGraph::new();
+        let root = graph.add(None, &[]).unwrap();
+        let child = graph.add(None, &[root]).unwrap();
+        assert_eq!(graph.add(None, &[root, child]).unwrap(), TaskNode(2));
+        assert_eq!(graph.len(), 3);
+
+        // The task of another graph is rejected.
+        let res = TaskGraph::new().add(None, &[child]);
+        assert!(matches!(res, Err(FlameError::InvalidConfig(_))));
+    }
+}
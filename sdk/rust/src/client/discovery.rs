@@ -0,0 +1,245 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//! The discovery of the session managers by the address of the client:
+//!
+//! * `http://` or `https://`: the session manager itself.
+//! * `dns:///<host>:<port>`: all the addresses of the host, e.g. the headless
+//!   service of Kubernetes.
+//! * `dns+srv:///<name>`: the targets of the SRV records of the name, e.g.
+//!   `_flame._tcp.example.com`; it requires the `srv` feature.
+//! * `discovery+http://` or `discovery+https://`: the endpoints listed by the
+//!   URL, e.g. `{"endpoints": ["https://flame-1:8080"]}`; it requires the
+//!   `discovery` feature.
+//!
+//! The endpoints resolved by DNS use TLS if the TLS configuration is provided,
+//! and the certificates are verified by the name of the host or the target.
+
+use std::collections::BTreeSet;
+
+#[cfg(any(feature = "discovery", test))]
+use serde_derive::Deserialize;
+use url::Url;
+
+use crate::apis::FlameError;
+
+#[cfg(feature = "discovery")]
+const DISCOVERY_TIMEOUT: std::time::Duration = std::time::Duration::from_secs(10);
+
+/// A session manager to connect.
+#[derive(Clone, Debug, PartialEq, Eq)]
+pub struct Target {
+    /// The URI of the session manager, e.g. `https://10.0.0.1:8080`.
+    pub uri: String,
+    /// The domain name to verify the certificate of the session manager.
+    pub domain: String,
+}
+
+impl Target {
+    pub fn is_tls(&self) -> bool {
+        self.uri.starts_with("https://")
+    }
+}
+
+#[cfg(any(feature = "discovery", test))]
+#[derive(Deserialize)]
+struct DiscoveryResponse {
+    endpoints: Vec<String>,
+}
+
+/// Resolve the address into the session managers; it fails if none is found.
+pub async fn resolve(addr: &str, tls: bool) -> Result<Vec<Target>, FlameError> {
+    let targets = match addr.split_once("://") {
+        Some(("dns", rest)) => resolve_dns(strip_authority(rest), tls).await?,
+        Some(("dns+srv", rest)) => resolve_srv(strip_authority(rest), tls).await?,
+        Some((scheme, rest)) if scheme.starts_with("discovery+") => {
+            fetch(&format!("{}://{rest}", &scheme["discovery+".len()..])).await?
+        }
+        _ => vec![target_of(addr)?],
+    };
+
+    if targets.is_empty() {
+        return Err(FlameError::InvalidConfig(format!(
+            "no session manager found by <{addr}>"
+        )));
+    }
+
+    Ok(targets)
+}
+
+/// The name of `dns:///<name>` or `dns://<server>/<name>`; the DNS server of
+/// the address is ignored, the one of the system is used instead.
+fn strip_authority(rest: &str) -> &str {
+    rest.split_once('/').map(|(_, name)| name).unwrap_or(rest)
+}
+
+fn scheme_of(tls: bool) -> &'static str {
+    if tls {
+        "https"
+    } else {
+        "http"
+    }
+}
+
+pub(crate) fn target_of(addr: &str) -> Result<Target, FlameError> {
+    let url = Url::parse(addr)
+        .map_err(|e| FlameError::InvalidConfig(format!("invalid address <{addr}>: {e}")))?;
+    let domain = url
+        .host_str()
+        .ok_or_else(|| FlameError::InvalidConfig(format!("no host in address <{addr}>")))?;
+
+    Ok(Target {
+        uri: addr.to_string(),
+        domain: domain.to_string(),
+    })
+}
+
+async fn resolve_dns(name: &str, tls: bool) -> Result<Vec<Target>, FlameError> {
+    let (host, _) = name
+        .rsplit_once(':')
+        .ok_or_else(|| FlameError::InvalidConfig(format!("no port in dns address <{name}>")))?;
+
+    // Sorted and deduplicated, so the clients see the same list.
+    let addrs: BTreeSet<_> = tokio::net::lookup_host(name)
+        .await
+        .map_err(|e| FlameError::Network(format!("failed to resolve <{name}>: {e}")))?
+        .collect();
+
+    Ok(addrs
+        .into_iter()
+        .map(|addr| Target {
+            uri: format!("{}://{addr}", scheme_of(tls)),
+            domain: host.to_string(),
+        })
+        .collect())
+}
+
+#[cfg(feature = "srv")]
+async fn resolve_srv(name: &str, tls: bool) -> Result<Vec<Target>, FlameError> {
+    let resolver = hickory_resolver::TokioAsyncResolver::tokio_from_system_conf()
+        .map_err(|e| FlameError::InvalidConfig(format!("invalid dns config: {e}")))?;
+    let lookup = resolver
+        .srv_lookup(name)
+        .await
+        .map_err(|e| FlameError::Network(format!("failed to resolve <{name}>: {e}")))?;
+
+    // The lower priority first, then the higher weight.
+    let mut records: Vec<_> = lookup.iter().collect();
+    records.sort_by_key(|srv| (srv.priority(), std::cmp::Reverse(srv.weight())));
+
+    Ok(records
+        .into_iter()
+        .map(|srv| {
+            let target = srv.target().to_utf8();
+            let target = target.trim_end_matches('.');
+            Target {
+                uri: format!("{}://{target}:{}", scheme_of(tls), srv.port()),
+                domain: target.to_string(),
+            }
+        })
+        .collect())
+}
+
+#[cfg(not(feature = "srv"))]
+async fn resolve_srv(name: &str, _: bool) -> Result<Vec<Target>, FlameError> {
+    Err(FlameError::InvalidConfig(format!(
+        "the SRV records are not supported, enable the `srv` feature for <{name}>"
+    )))
+}
+
+#[cfg(feature = "discovery")]
+async fn fetch(url: &str) -> Result<Vec<Target>, FlameError> {
+    let client = reqwest::Client::builder()
+        .timeout(DISCOVERY_TIMEOUT)
+        .build()
+        .map_err(|e| FlameError::Internal(format!("failed to build discovery client: {e}")))?;
+
+    let body = client
+        .get(url)
+        .send()
+        .await
+        .and_then(|resp| resp.error_for_status())
+        .map_err(|e| FlameError::Network(format!("failed to discover by <{url}>: {e}")))?
+        .bytes()
+        .await
+        .map_err(|e| FlameError::Network(format!("failed to discover by <{url}>: {e}")))?;
+
+    parse_endpoints(&body)
+}
+
+#[cfg(not(feature = "discovery"))]
+async fn fetch(url: &str) -> Result<Vec<Target>, FlameError> {
+    Err(FlameError::InvalidConfig(format!(
+        "the discovery endpoint is not supported, enable the `discovery` feature for <{url}>"
+    )))
+}
+
+#[cfg(any(feature = "discovery", test))]
+fn parse_endpoints(body: &[u8]) -> Result<Vec<Target>, FlameError> {
+    let resp: DiscoveryResponse = serde_json::from_slice(body)
+        .map_err(|e| FlameError::InvalidConfig(format!("invalid discovery response: {e}")))?;
+
+    resp.endpoints.iter().map(|e| target_of(e)).collect()
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_strip_authority() {
+        assert_eq!(strip_authority("/flame:8080"), "flame:8080");
+        assert_eq!(strip_authority("8.8.8.8/flame:8080"), "flame:8080");
+        assert_eq!(strip_authority("flame:8080"), "flame:8080");
+    }
+
+    #[tokio::test]
+    async fn test_resolve() {
+        let targets = resolve("https://flame.example.com:8080", false)
+            .await
+            .unwrap();
+        assert_eq!(
+            targets,
+            vec![Target {
+                uri: "https://flame.example.com:8080".to_string(),
+                domain: "flame.example.com".to_string(),
+            }]
+        );
+        assert!(targets[0].is_tls());
+
+        let targets = resolve("dns:///127.0.0.1:8080", true).await.unwrap();
+        assert_eq!(
+            targets,
+            vec![Target {
+                uri: "https://127.0.0.1:8080".to_string(),
+                domain: "127.0.0.1".to_string(),
+            }]
+        );
+
+        assert!(resolve("dns:///127.0.0.1", false).await.is_err());
+    }
+
+    #[test]
+    fn test_parse_endpoints() {
+        let targets = parse_endpoints(
+            br#"{"endpoints": ["http://flame-1:8080", "https://flame-2.example.com:8080"]}"#,
+        )
+        .unwrap();
+        assert_eq!(targets.len(), 2);
+        assert!(!targets[0].is_tls());
+        assert_eq!(targets[1].domain, "flame-2.example.com");
+
+        assert!(parse_endpoints(br#"{"endpoints": ["flame-1"]}"#).is_err());
+        assert!(parse_endpoints(b"<html>").is_err());
+    }
+}
@@ -0,0 +1,195 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//! The output of a task read in chunks, so the outputs larger than the
+//! message limit of gRPC are consumed incrementally, e.g.
+//!
+//! ```ignore
+//! let mut reader = session.output_reader(&task.id).await?;
+//! tokio::io::copy(&mut reader, &mut tokio::fs::File::create("output.bin").await?).await?;
+//! ```
+
+use std::pin::Pin;
+use std::task::{ready, Context, Poll};
+
+use bytes::{Buf, Bytes};
+use futures::Stream;
+use stdng::trace_fn;
+use tokio::io::{AsyncRead, ReadBuf};
+use tonic::Status;
+
+use crate::apis::checksum::{self, Hasher};
+use crate::apis::{FlameError, TaskID};
+use crate::client::rpc::{GetTaskOutputRequest, TaskOutputChunk};
+use crate::client::Session;
+
+type ChunkStream = Pin<Box<dyn Stream<Item = Result<TaskOutputChunk, Status>> + Send>>;
+
+/// The reader of the output of a completed task; the output is verified by
+/// its checksum at the end, and the mismatch is an `InvalidData` error.
+pub struct TaskOutputReader {
+    name: String,
+    chunks: ChunkStream,
+    chunk: Bytes,
+    /// The hasher and the expected checksum from the first chunk.
+    hasher: Option<(Hasher, String)>,
+    done: bool,
+}
+
+impl Session {
+    /// Read the output of the completed task in chunks; see `TaskOutputReader`.
+    pub async fn output_reader(&self, id: &TaskID) -> Result<TaskOutputReader, FlameError> {
+        trace_fn!("Session::output_reader");
+        let mut client = self
+            .client
+            .clone()
+            .ok_or(FlameError::Internal("no flame client".to_string()))?;
+
+        let chunks = client
+            .get_task_output(GetTaskOutputRequest {
+                session_id: self.id.clone(),
+                task_id: id.clone(),
+                if_none_match: None,
+            })
+            .await?
+            .into_inner();
+
+        Ok(TaskOutputReader::new(
+            format!("output of task <{}/{id}>", self.id),
+            Box::pin(chunks),
+        ))
+    }
+}
+
+impl TaskOutputReader {
+    fn new(name: String, chunks: ChunkStream) -> Self {
+        Self {
+            name,
+            chunks,
+            chunk: Bytes::new(),
+            hasher: None,
+            done: false,
+        }
+    }
+
+    fn on_chunk(&mut self, chunk: TaskOutputChunk) -> Result<(), FlameError> {
+        if let Some(expected) = chunk.checksum {
+            let algo = checksum::algorithm_of(&self.name, &expected)?;
+            self.hasher = Some((Hasher::new(algo), expected));
+        }
+        if let Some((hasher, _)) = &mut self.hasher {
+            hasher.update(&chunk.chunk);
+        }
+        self.chunk = Bytes::from(chunk.chunk);
+
+        Ok(())
+    }
+
+    fn on_end(&mut self) -> Result<(), FlameError> {
+        self.done = true;
+        let Some((hasher, expected)) = self.hasher.take() else {
+            return Ok(());
+        };
+
+        let actual = hasher.finish();
+        if actual != expected {
+            return Err(FlameError::Integrity(format!(
+                "checksum mismatch of {}: expected <{expected}>, got <{actual}>",
+                self.name
+            )));
+        }
+
+        Ok(())
+    }
+}
+
+impl AsyncRead for TaskOutputReader {
+    fn poll_read(
+        mut self: Pin<&mut Self>,
+        cx: &mut Context<'_>,
+        buf: &mut ReadBuf<'_>,
+    ) -> Poll<std::io::Result<()>> {
+        loop {
+            if !self.chunk.is_empty() {
+                let n = buf.remaining().min(self.chunk.len());
+                buf.put_slice(&self.chunk[..n]);
+                self.chunk.advance(n);
+                return Poll::Ready(Ok(()));
+            }
+            if self.done {
+                return Poll::Ready(Ok(()));
+            }
+
+            let res = match ready!(self.chunks.as_mut().poll_next(cx)) {
+                Some(Ok(chunk)) => self.on_chunk(chunk),
+                Some(Err(status)) => Err(FlameError::from(status)),
+                None => self.on_end(),
+            };
+            if let Err(e) = res {
+                self.done = true;
+                let kind = match e {
+                    FlameError::Integrity(_) => std::io::ErrorKind::InvalidData,
+                    _ => std::io::ErrorKind::Other,
+                };
+                return Poll::Ready(Err(std::io::Error::new(kind, e)));
+            }
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use tokio::io::AsyncReadExt;
+
+    use super::*;
+
+    fn reader(checksum: String, output: &[u8]) -> TaskOutputReader {
+        let mut chunks: Vec<Result<TaskOutputChunk, Status>> = output
+            .chunks(3)
+            .map(|c| {
+                Ok(TaskOutputChunk {
+                    checksum: None,
+                    chunk: c.to_vec(),
+                })
+            })
+            .collect();
+        if let Some(Ok(first)) = chunks.first_mut() {
+            first.checksum = Some(checksum);
+        }
+
+        TaskOutputReader::new(
+            "output of task <ssn-1/1>".to_string(),
+            Box::pin(futures::stream::iter(chunks)),
+        )
+    }
+
+    #[tokio::test]
+    async fn test_task_output_reader() {
+        let output = b"hello flame, in chunks";
+
+        let mut outputs = vec![];
+        reader(checksum::checksum(output), output)
+            .read_to_end(&mut outputs)
+            .await
+            .unwrap();
+        assert_eq!(outputs, output);
+
+        // The output is corrupted on the way.
+        let err = reader(checksum::checksum(b"hello world"), output)
+            .read_to_end(&mut vec![])
+            .await
+            .unwrap_err();
+        assert_eq!(err.kind(), std::io::ErrorKind::InvalidData);
+        assert!(err.to_string().contains("checksum mismatch"));
+    }
+}
@@ -0,0 +1,166 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//! The events of a session as a channel, e.g. the tasks completed and the
+//! executors bound, so the dashboards and orchestrators react to the changes
+//! instead of polling the session, e.g.
+//!
+//! ```ignore
+//! let mut events = session.events().await?;
+//! while let Some(event) = events.recv().await {
+//!     match event?.kind {
+//!         SessionEventKind::TaskFailed(id) => tracing::warn!("Task <{id}> failed"),
+//!         kind => tracing::debug!("{kind:?}"),
+//!     }
+//! }
+//! ```
+//!
+//! Unlike `watch`, the stream is not re-established after an error, as the
+//! events in between would be lost; watch the session again, and reconcile
+//! with its current tasks.
+
+use chrono::{DateTime, Utc};
+use stdng::trace_fn;
+use tokio::sync::mpsc;
+use tokio_stream::StreamExt;
+
+use crate::apis::flame::v1 as rpc;
+use crate::apis::{FlameError, SessionState, TaskID};
+use crate::client::rpc::WatchSessionRequest;
+use crate::client::Session;
+
+use self::rpc::session_event::Event;
+
+/// The events buffered in the channel before the watch waits for the receiver.
+const EVENTS_BUFFER_SIZE: usize = 64;
+
+/// The change of a session.
+#[derive(Clone, Debug, PartialEq)]
+pub enum SessionEventKind {
+    TaskCreated(TaskID),
+    /// The task succeeded.
+    TaskCompleted(TaskID),
+    /// The task failed or was cancelled.
+    TaskFailed(TaskID),
+    ExecutorBound(String),
+    ExecutorUnbound(String),
+    /// The current state of the session first, then its changes.
+    StateChanged(SessionState),
+}
+
+/// An event of a session, and the time it was seen by the session manager.
+#[derive(Clone, Debug, PartialEq)]
+pub struct SessionEvent {
+    pub kind: SessionEventKind,
+    pub event_time: DateTime<Utc>,
+}
+
+impl TryFrom<rpc::SessionEvent> for SessionEvent {
+    type Error = FlameError;
+
+    fn try_from(event: rpc::SessionEvent) -> Result<Self, Self::Error> {
+        let kind = match event.event {
+            Some(Event::TaskCreated(id)) => SessionEventKind::TaskCreated(id),
+            Some(Event::TaskCompleted(id)) => SessionEventKind::TaskCompleted(id),
+            Some(Event::TaskFailed(id)) => SessionEventKind::TaskFailed(id),
+            Some(Event::ExecutorBound(id)) => SessionEventKind::ExecutorBound(id),
+            Some(Event::ExecutorUnbound(id)) => SessionEventKind::ExecutorUnbound(id),
+            Some(Event::StateChanged(state)) => {
+                SessionEventKind::StateChanged(SessionState::try_from(state).map_err(|_| {
+                    FlameError::InvalidState(format!("invalid session state <{state}>"))
+                })?)
+            }
+            None => {
+                return Err(FlameError::InvalidState(
+                    "session event is empty".to_string(),
+                ))
+            }
+        };
+        let event_time = DateTime::from_timestamp_millis(event.event_time).ok_or(
+            FlameError::InvalidState(format!("invalid event time <{}>", event.event_time)),
+        )?;
+
+        Ok(Self { kind, event_time })
+    }
+}
+
+impl Session {
+    /// Watch the events of the session; the channel is closed after the
+    /// session is closed and its executors are unbound, or after the first
+    /// error. The watch is stopped if the receiver is dropped.
+    pub async fn events(
+        &self,
+    ) -> Result<mpsc::Receiver<Result<SessionEvent, FlameError>>, FlameError> {
+        trace_fn!("Session::events");
+        let mut client = self
+            .client
+            .clone()
+            .ok_or(FlameError::Internal("no flame client".to_string()))?;
+
+        // The stream is established here, so the unknown session is reported
+        // to the caller instead of the channel.
+        let mut stream = client
+            .watch_session(WatchSessionRequest {
+                session_id: self.id.clone(),
+            })
+            .await?
+            .into_inner();
+
+        let (tx, rx) = mpsc::channel(EVENTS_BUFFER_SIZE);
+        tokio::spawn(async move {
+            while let Some(event) = stream.next().await {
+                let event = event
+                    .map_err(FlameError::from)
+                    .and_then(SessionEvent::try_from);
+                let failed = event.is_err();
+                if tx.send(event).await.is_err() || failed {
+                    return;
+                }
+            }
+        });
+
+        Ok(rx)
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_session_event_from_rpc() {
+        let event = SessionEvent::try_from(rpc::SessionEvent {
+            event_time: 1000,
+            event: Some(Event::TaskFailed("3".to_string())),
+        })
+        .unwrap();
+        assert_eq!(event.kind, SessionEventKind::TaskFailed("3".to_string()));
+        assert_eq!(event.event_time.timestamp_millis(), 1000);
+
+        let event = SessionEvent::try_from(rpc::SessionEvent {
+            event_time: 1000,
+            event: Some(Event::StateChanged(rpc::SessionState::Closed as i32)),
+        })
+        .unwrap();
+        assert_eq!(
+            event.kind,
+            SessionEventKind::StateChanged(SessionState::Closed)
+        );
+
+        let res = SessionEvent::try_from(rpc::SessionEvent {
+            event_time: 1000,
+            event: None,
+        });
+        assert!(matches!(res, Err(FlameError::InvalidState(_))));
+    }
+}
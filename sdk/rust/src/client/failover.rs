@@ -0,0 +1,207 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//! The failover between the session managers listed by the client, so an
+//! outage of one session manager does not strand the client, e.g.
+//!
+//! ```ignore
+//! let conn = ConnectOptions::new("http://flame-1:8080")
+//!     .with_failover(&["http://flame-2:8080", "http://flame-3:8080"])
+//!     .connect()
+//!     .await?;
+//! ```
+//!
+//! The connection uses one session manager at a time, the first healthy one
+//! in the order of the list. It sticks to that session manager while it's
+//! healthy; otherwise, it fails over to the first healthy one of the others.
+//! A session manager is healthy if it serves the requests, i.e. it's not a
+//! standby unless the redirection is disabled.
+
+use tokio::sync::mpsc::Sender;
+use tonic::transport::{Channel, Endpoint};
+use tonic::Code;
+use tower::discover::Change;
+
+use crate::apis::flame::v1 as rpc;
+use crate::apis::FlameError;
+use crate::client::options::ConnectOptions;
+use crate::client::{discovery, endpoint_of, Connection};
+
+use self::rpc::replication_client::ReplicationClient;
+use self::rpc::{GetReplicationRequest, ReplicaRole};
+
+/// Connect to the first healthy session manager of the options, and start
+/// to check its health in the background until the connection is dropped.
+pub(crate) async fn connect(options: &ConnectOptions) -> Result<Connection, FlameError> {
+    let mut addrs = Vec::with_capacity(options.failover.len() + 1);
+    let mut endpoints = Vec::with_capacity(options.failover.len() + 1);
+    for addr in std::iter::once(&options.endpoint).chain(options.failover.iter()) {
+        for target in discovery::resolve(addr, options.tls.is_some()).await? {
+            endpoints.push(endpoint_of(&target, options)?);
+            addrs.push(target.uri);
+        }
+    }
+
+    let mut current = None;
+    for (i, endpoint) in endpoints.iter().enumerate() {
+        if is_healthy(endpoint, options).await {
+            current = Some(i);
+            break;
+        }
+        tracing::debug!("Session manager <{}> is not healthy, skip it", addrs[i]);
+    }
+    let Some(current) = current else {
+        return Err(FlameError::InvalidConfig(format!(
+            "no healthy session manager in <{}>",
+            addrs.join(",")
+        )));
+    };
+
+    let (channel, tx) = Channel::balance_channel(endpoints.len());
+    tx.send(Change::Insert(current, endpoints[current].clone()))
+        .await
+        .map_err(|e| FlameError::Internal(format!("failed to use <{}>: {e}", addrs[current])))?;
+    tracing::debug!(
+        "Connected to <{}> of {} session managers",
+        addrs[current],
+        addrs.len()
+    );
+
+    tokio::spawn(watch(
+        Failover {
+            addrs,
+            endpoints,
+            current,
+            tx,
+        },
+        options.clone(),
+    ));
+
+    Ok(Connection {
+        channel: channel.into(),
+        middlewares: Default::default(),
+        submit_limit: None,
+    })
+}
+
+/// The session managers of a connection, and the one in use.
+struct Failover {
+    addrs: Vec<String>,
+    endpoints: Vec<Endpoint>,
+    current: usize,
+    tx: Sender<Change<usize, Endpoint>>,
+}
+
+/// Check the health of the session manager in use periodically, and fail
+/// over to another one if it's not healthy; it stops once the channel of
+/// the connection, i.e. the receiver of the changes, is dropped.
+async fn watch(mut failover: Failover, options: ConnectOptions) {
+    let mut interval = tokio::time::interval(options.health_interval);
+    // The first tick is immediate, and the session manager was just checked.
+    interval.tick().await;
+
+    loop {
+        tokio::select! {
+            _ = interval.tick() => {}
+            _ = failover.tx.closed() => return,
+        }
+
+        if is_healthy(&failover.endpoints[failover.current], &options).await {
+            continue;
+        }
+
+        let mut next = None;
+        for i in failover_order(failover.current, failover.endpoints.len()) {
+            if is_healthy(&failover.endpoints[i], &options).await {
+                next = Some(i);
+                break;
+            }
+        }
+        // Keep the session manager in use if none is healthy, it may recover.
+        let Some(next) = next else {
+            tracing::warn!(
+                "Session manager <{}> is not healthy, and no other one to fail over",
+                failover.addrs[failover.current]
+            );
+            continue;
+        };
+
+        tracing::warn!(
+            "Session manager <{}> is not healthy, fail over to <{}>",
+            failover.addrs[failover.current],
+            failover.addrs[next]
+        );
+        let changes = [
+            Change::Insert(next, failover.endpoints[next].clone()),
+            Change::Remove(failover.current),
+        ];
+        for change in changes {
+            if failover.tx.send(change).await.is_err() {
+                return;
+            }
+        }
+        failover.current = next;
+    }
+}
+
+/// The others of the session managers to fail over to, in the order of
+/// preference.
+fn failover_order(current: usize, len: usize) -> impl Iterator<Item = usize> {
+    (0..len).filter(move |i| *i != current)
+}
+
+/// Whether the session manager serves the requests; the older session
+/// managers, which do not serve the replication, are healthy if reachable.
+async fn is_healthy(endpoint: &Endpoint, options: &ConnectOptions) -> bool {
+    let probe = async {
+        let channel = endpoint.connect().await.ok()?;
+        let mut client = ReplicationClient::new(channel);
+        match client.get_replication(GetReplicationRequest {}).await {
+            Ok(status) => {
+                Some(!options.redirect || status.into_inner().role() != ReplicaRole::Standby)
+            }
+            Err(status) => Some(status.code() == Code::Unimplemented),
+        }
+    };
+
+    matches!(
+        tokio::time::timeout(options.health_interval, probe).await,
+        Ok(Some(true))
+    )
+}
+
+#[cfg(test)]
+mod tests {
+    use std::time::Duration;
+
+    use super::*;
+
+    #[test]
+    fn test_failover_order() {
+        assert_eq!(failover_order(0, 3).collect::<Vec<_>>(), vec![1, 2]);
+        // The more preferred session managers are checked first.
+        assert_eq!(failover_order(2, 3).collect::<Vec<_>>(), vec![0, 1]);
+        assert_eq!(failover_order(0, 1).count(), 0);
+    }
+
+    #[tokio::test]
+    async fn test_connect_without_healthy() {
+        let options = ConnectOptions::new("http://127.0.0.1:1")
+            .with_failover(&["http://127.0.0.1:2"])
+            .with_connect_timeout(Duration::from_millis(100));
+        let res = connect(&options).await;
+        assert!(
+            matches!(res, Err(FlameError::InvalidConfig(m)) if m.contains("http://127.0.0.1:2"))
+        );
+    }
+}
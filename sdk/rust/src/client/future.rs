@@ -0,0 +1,179 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//! The handle of a submitted task, which is completed with the output of the
+//! task; it's a future, so it's composed by `select!`, `try_join_all` and so
+//! on, e.g.
+//!
+//! ```ignore
+//! let task = session.submit_task(Some(input)).await?;
+//! tokio::select! {
+//!     output = task.wait() => println!("{:?}", output?),
+//!     _ = tokio::time::sleep(Duration::from_secs(10)) => println!("timeout"),
+//! }
+//! ```
+
+use std::future::{Future, IntoFuture};
+use std::pin::Pin;
+
+use stdng::trace_fn;
+use tokio::sync::watch;
+use tokio::task::JoinHandle;
+
+use crate::apis::{FlameError, TaskID, TaskInput, TaskOutput};
+use crate::client::typed::output_of;
+use crate::client::{Session, Task};
+
+type TaskResult = Result<TaskOutput, FlameError>;
+
+/// The handle of a submitted task; the watch of the task is stopped if the
+/// handle is dropped.
+pub struct TaskFuture {
+    pub id: TaskID,
+    rx: watch::Receiver<Option<TaskResult>>,
+    watcher: JoinHandle<()>,
+}
+
+impl Session {
+    /// Create a task and return its handle without waiting for it.
+    pub async fn submit_task(&self, input: Option<TaskInput>) -> Result<TaskFuture, FlameError> {
+        trace_fn!("Session::submit_task");
+        let task = self.create_task(input).await?;
+        let mut updates = self.watch(task.id.clone()).await?;
+
+        let (tx, rx) = watch::channel(None);
+        let watcher = tokio::spawn(async move {
+            let mut last: Option<Task> = None;
+            while let Some(update) = updates.recv().await {
+                match update {
+                    Ok(task) => last = Some(task),
+                    Err(e) => {
+                        let _ = tx.send(Some(Err(e)));
+                        return;
+                    }
+                }
+            }
+
+            // The channel of the watch is closed after the task is completed.
+            let result = match last {
+                Some(task) => output_of(&task),
+                None => Err(FlameError::Internal(
+                    "the watch of the task was closed".to_string(),
+                )),
+            };
+            let _ = tx.send(Some(result));
+        });
+
+        Ok(TaskFuture {
+            id: task.id,
+            rx,
+            watcher,
+        })
+    }
+}
+
+impl TaskFuture {
+    /// Wait for the output of the task; the failed or cancelled task is an
+    /// error. It can be called several times, e.g. by several waiters.
+    pub async fn wait(&self) -> TaskResult {
+        let mut rx = self.rx.clone();
+        let result = rx
+            .wait_for(Option::is_some)
+            .await
+            .map_err(|_| FlameError::Internal("the watch of the task was stopped".to_string()))?;
+
+        result
+            .clone()
+            .unwrap_or_else(|| Err(FlameError::Internal("no result of the task".to_string())))
+    }
+
+    /// Whether the task is completed, i.e. `wait` returns immediately.
+    pub fn is_done(&self) -> bool {
+        self.rx.borrow().is_some()
+    }
+
+    /// The error of the completed task; None if the task succeeded or is not
+    /// completed yet.
+    pub fn err(&self) -> Option<FlameError> {
+        self.rx.borrow().as_ref().and_then(|r| r.clone().err())
+    }
+}
+
+impl IntoFuture for TaskFuture {
+    type Output = TaskResult;
+    type IntoFuture = Pin<Box<dyn Future<Output = TaskResult> + Send>>;
+
+    fn into_future(self) -> Self::IntoFuture {
+        Box::pin(async move { self.wait().await })
+    }
+}
+
+impl Drop for TaskFuture {
+    fn drop(&mut self) {
+        self.watcher.abort();
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use std::time::Duration;
+
+    use bytes::Bytes;
+
+    use super::*;
+
+    fn task_future() -> (watch::Sender<Option<TaskResult>>, TaskFuture) {
+        let (tx, rx) = watch::channel(None);
+        let watcher = tokio::spawn(async {});
+        (
+            tx,
+            TaskFuture {
+                id: "1".to_string(),
+                rx,
+                watcher,
+            },
+        )
+    }
+
+    #[tokio::test]
+    async fn test_task_future() {
+        let (tx, task) = task_future();
+        assert!(!task.is_done());
+        assert!(task.err().is_none());
+
+        tokio::spawn(async move {
+            tokio::time::sleep(Duration::from_millis(10)).await;
+            let _ = tx.send(Some(Ok(Bytes::from("42"))));
+        });
+
+        assert_eq!(task.wait().await.unwrap(), Bytes::from("42"));
+        assert!(task.is_done());
+        assert!(task.err().is_none());
+        // The output is kept for the later waiters.
+        assert_eq!(task.await.unwrap(), Bytes::from("42"));
+    }
+
+    #[tokio::test]
+    async fn test_task_future_error() {
+        let (tx, task) = task_future();
+        let _ = tx.send(Some(Err(FlameError::Internal("failed".to_string()))));
+
+        assert!(task.is_done());
+        assert!(task.err().is_some());
+        assert!(task.wait().await.is_err());
+
+        let (tx, task) = task_future();
+        drop(tx);
+        assert!(task.wait().await.is_err());
+    }
+}
@@ -0,0 +1,287 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//! A group of the tasks of a session, which is submitted one by one with
+//! bounded tasks in flight, and waited for together, e.g.
+//!
+//! ```ignore
+//! let mut group = session.group();
+//! for input in inputs {
+//!     // Stop submitting after the first failure.
+//!     group.go(Some(input)).await?;
+//! }
+//! let outputs = group.wait().await?;
+//! ```
+//!
+//! The tasks are not cancelled in the session manager on failure; the group
+//! only stops submitting and waiting for them.
+
+use std::future::{Future, IntoFuture};
+use std::sync::atomic::{AtomicBool, Ordering};
+use std::sync::Arc;
+
+use stdng::trace_fn;
+use tokio::sync::{OwnedSemaphorePermit, Semaphore};
+use tokio::task::JoinSet;
+
+use crate::apis::{FlameError, TaskInput, TaskOutput};
+use crate::client::mapreduce::{collect, DEFAULT_MAP_CONCURRENCY};
+use crate::client::Session;
+
+type TaskResult = Result<TaskOutput, FlameError>;
+
+#[derive(Clone, Debug)]
+pub struct GroupOptions {
+    /// The maximum number of the tasks in flight; `go` waits for a task to
+    /// be completed beyond it.
+    pub concurrency: usize,
+    /// Whether to stop on the first failure; otherwise, all the tasks are
+    /// waited for, and the failures are reported together.
+    pub cancel_on_error: bool,
+}
+
+impl Default for GroupOptions {
+    fn default() -> Self {
+        GroupOptions {
+            concurrency: DEFAULT_MAP_CONCURRENCY,
+            cancel_on_error: true,
+        }
+    }
+}
+
+impl GroupOptions {
+    pub fn new() -> Self {
+        Self::default()
+    }
+
+    pub fn with_concurrency(mut self, concurrency: usize) -> Self {
+        self.concurrency = concurrency;
+        self
+    }
+
+    /// Wait for all the tasks even if some of them failed.
+    pub fn without_cancel_on_error(mut self) -> Self {
+        self.cancel_on_error = false;
+        self
+    }
+
+    pub fn validate(&self) -> Result<(), FlameError> {
+        if self.concurrency == 0 {
+            return Err(FlameError::InvalidConfig(
+                "the concurrency of the group must be positive".to_string(),
+            ));
+        }
+
+        Ok(())
+    }
+}
+
+pub struct TaskGroup {
+    session: Session,
+    inflight: Inflight,
+}
+
+impl Session {
+    pub fn group(&self) -> TaskGroup {
+        self.group_with(GroupOptions::default())
+    }
+
+    pub fn group_with(&self, opts: GroupOptions) -> TaskGroup {
+        TaskGroup {
+            session: self.clone(),
+            inflight: Inflight::new(opts),
+        }
+    }
+}
+
+impl TaskGroup {
+    /// Submit a task to the group, waiting for a slot if the tasks in flight
+    /// reach the concurrency; it returns the index of the task in the outputs
+    /// of `wait`. It fails after the first failure if `cancel_on_error`.
+    pub async fn go(&mut self, input: Option<TaskInput>) -> Result<usize, FlameError> {
+        trace_fn!("TaskGroup::go");
+        let permit = self.inflight.acquire().await?;
+        let task = self.session.submit_task(input).await?;
+
+        Ok(self.inflight.spawn(permit, task.into_future()))
+    }
+
+    /// Wait for all the tasks of the group; the outputs are in the order of
+    /// `go`. The first failure is returned if `cancel_on_error`, and the
+    /// other tasks are not waited for.
+    pub async fn wait(self) -> Result<Vec<TaskOutput>, FlameError> {
+        trace_fn!("TaskGroup::wait");
+        self.inflight.wait().await
+    }
+}
+
+/// The tasks in flight of a group, bounded by the permits.
+struct Inflight {
+    opts: GroupOptions,
+    permits: Arc<Semaphore>,
+    cancelled: Arc<AtomicBool>,
+    tasks: JoinSet<(usize, TaskResult)>,
+    next: usize,
+}
+
+impl Inflight {
+    fn new(opts: GroupOptions) -> Self {
+        Self {
+            permits: Arc::new(Semaphore::new(opts.concurrency.max(1))),
+            opts,
+            cancelled: Arc::new(AtomicBool::new(false)),
+            tasks: JoinSet::new(),
+            next: 0,
+        }
+    }
+
+    async fn acquire(&self) -> Result<OwnedSemaphorePermit, FlameError> {
+        let cancelled = || FlameError::InvalidState("the task group was cancelled".to_string());
+        if self.cancelled.load(Ordering::Acquire) {
+            return Err(cancelled());
+        }
+
+        let permit = self
+            .permits
+            .clone()
+            .acquire_owned()
+            .await
+            .map_err(|_| cancelled())?;
+        // A task failed while waiting for the permit.
+        if self.cancelled.load(Ordering::Acquire) {
+            return Err(cancelled());
+        }
+
+        Ok(permit)
+    }
+
+    fn spawn<F>(&mut self, permit: OwnedSemaphorePermit, task: F) -> usize
+    where
+        F: Future<Output = TaskResult> + Send + 'static,
+    {
+        let index = self.next;
+        self.next += 1;
+
+        let cancelled = self.cancelled.clone();
+        let cancel_on_error = self.opts.cancel_on_error;
+        self.tasks.spawn(async move {
+            let result = task.await;
+            if result.is_err() && cancel_on_error {
+                cancelled.store(true, Ordering::Release);
+            }
+            drop(permit);
+
+            (index, result)
+        });
+
+        index
+    }
+
+    async fn wait(mut self) -> Result<Vec<TaskOutput>, FlameError> {
+        let mut results: Vec<Option<TaskResult>> = vec![None; self.next];
+        while let Some(joined) = self.tasks.join_next().await {
+            let (index, result) =
+                joined.map_err(|e| FlameError::Internal(format!("task of group panicked: {e}")))?;
+            if let Err(e) = &result {
+                if self.opts.cancel_on_error {
+                    self.tasks.abort_all();
+                    return Err(FlameError::Internal(format!(
+                        "task <{index}> of the group failed: {e}"
+                    )));
+                }
+            }
+            results[index] = Some(result);
+        }
+
+        collect(results.into_iter().flatten().collect())
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use std::sync::atomic::AtomicUsize;
+    use std::time::Duration;
+
+    use bytes::Bytes;
+
+    use super::*;
+
+    async fn run(
+        opts: GroupOptions,
+        fail: Option<usize>,
+        in_flight: Arc<AtomicUsize>,
+        max_in_flight: Arc<AtomicUsize>,
+    ) -> Result<Vec<TaskOutput>, FlameError> {
+        let mut inflight = Inflight::new(opts);
+        for i in 0..8usize {
+            let Ok(permit) = inflight.acquire().await else {
+                break;
+            };
+            let in_flight = in_flight.clone();
+            let max_in_flight = max_in_flight.clone();
+            inflight.spawn(permit, async move {
+                let current = in_flight.fetch_add(1, Ordering::SeqCst) + 1;
+                max_in_flight.fetch_max(current, Ordering::SeqCst);
+                // The later tasks are completed first.
+                tokio::time::sleep(Duration::from_millis(40 - i as u64 * 5)).await;
+                in_flight.fetch_sub(1, Ordering::SeqCst);
+
+                match fail {
+                    Some(f) if f == i => Err(FlameError::Internal("division by zero".to_string())),
+                    _ => Ok(Bytes::from(i.to_string())),
+                }
+            });
+        }
+
+        inflight.wait().await
+    }
+
+    #[tokio::test]
+    async fn test_task_group_in_order() {
+        let max_in_flight = Arc::new(AtomicUsize::new(0));
+        let opts = GroupOptions {
+            concurrency: 3,
+            ..Default::default()
+        };
+
+        let outputs = run(opts, None, Arc::default(), max_in_flight.clone())
+            .await
+            .unwrap();
+        let expected: Vec<TaskOutput> = (0..8).map(|i| Bytes::from(i.to_string())).collect();
+        assert_eq!(outputs, expected);
+        assert!(max_in_flight.load(Ordering::SeqCst) <= 3);
+    }
+
+    #[tokio::test]
+    async fn test_task_group_failures() {
+        let opts = GroupOptions {
+            concurrency: 2,
+            cancel_on_error: true,
+        };
+        let err = run(opts, Some(1), Arc::default(), Arc::default())
+            .await
+            .unwrap_err();
+        assert!(err.to_string().contains("task <1> of the group failed"));
+
+        // All the tasks are waited for, and the failures are reported together.
+        let opts = GroupOptions::new()
+            .with_concurrency(2)
+            .without_cancel_on_error();
+        assert!(opts.validate().is_ok());
+        assert!(GroupOptions::new().with_concurrency(0).validate().is_err());
+        let err = run(opts, Some(1), Arc::default(), Arc::default())
+            .await
+            .unwrap_err();
+        assert!(err.to_string().contains("1 of 8 tasks failed"));
+    }
+}
@@ -0,0 +1,156 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//! The version, the features and the limits of the session manager, so the
+//! requests are validated before sending them, e.g.
+//!
+//! ```ignore
+//! let info = conn.get_server_info().await?;
+//! info.check_input(input.len())?;
+//! if !info.supports("upload") {
+//!     return session.create_task(Some(input)).await;
+//! }
+//! ```
+//!
+//! `ping` is the round trip of the cheapest request, e.g. for the health
+//! checks of the tooling.
+
+use std::time::{Duration, Instant};
+
+use chrono::{DateTime, Utc};
+use stdng::trace_fn;
+
+use crate::apis::flame::v1 as rpc;
+use crate::apis::FlameError;
+use crate::client::rpc::GetServerInfoRequest;
+use crate::client::{Connection, FlameClient};
+
+/// The information of the session manager.
+#[derive(Clone, Debug, PartialEq)]
+pub struct ServerInfo {
+    /// The version of the session manager, e.g. `0.5.0`.
+    pub version: String,
+    /// The optional features of the APIs, e.g. `upload` and `poll`.
+    pub features: Vec<String>,
+    /// The max size of a request in bytes, e.g. the input of `create_task`.
+    pub max_message_size: u64,
+    /// The max size of the input of a task in bytes, by `upload_task`.
+    pub max_input_size: u64,
+    /// The max tasks created by one CreateTasks.
+    pub max_create_tasks: u32,
+    /// The max sessions kept by the session manager, if limited.
+    pub max_sessions: Option<u64>,
+    pub server_time: Option<DateTime<Utc>>,
+}
+
+impl From<rpc::ServerInfo> for ServerInfo {
+    fn from(info: rpc::ServerInfo) -> Self {
+        Self {
+            version: info.version,
+            features: info.features,
+            max_message_size: info.max_message_size,
+            max_input_size: info.max_input_size,
+            max_create_tasks: info.max_create_tasks,
+            max_sessions: info.max_sessions,
+            server_time: DateTime::from_timestamp_millis(info.server_time),
+        }
+    }
+}
+
+impl ServerInfo {
+    /// Whether the session manager serves the feature, e.g. `poll`.
+    pub fn supports(&self, feature: &str) -> bool {
+        self.features.iter().any(|f| f == feature)
+    }
+
+    /// Fail if the input of `size` bytes can't be sent by `create_task`;
+    /// the larger inputs are uploaded by `upload_task`.
+    pub fn check_input(&self, size: usize) -> Result<(), FlameError> {
+        // The message carries the spec of the task besides the input.
+        if self.max_message_size == 0 || (size as u64) < self.max_message_size {
+            return Ok(());
+        }
+
+        Err(FlameError::QuotaExceeded(format!(
+            "the input of <{size}> bytes is larger than the message limit <{}> bytes, upload it",
+            self.max_message_size
+        )))
+    }
+
+    /// Fail if the input of `size` bytes can't be uploaded by `upload_task`.
+    pub fn check_upload(&self, size: u64) -> Result<(), FlameError> {
+        if self.max_input_size == 0 || size <= self.max_input_size {
+            return Ok(());
+        }
+
+        Err(FlameError::QuotaExceeded(format!(
+            "the input of <{size}> bytes is larger than <{}> bytes",
+            self.max_input_size
+        )))
+    }
+}
+
+impl Connection {
+    pub async fn get_server_info(&self) -> Result<ServerInfo, FlameError> {
+        trace_fn!("Connection::get_server_info");
+        let mut client = FlameClient::new(self.channel.clone());
+        let info = client
+            .get_server_info(GetServerInfoRequest {})
+            .await?
+            .into_inner();
+
+        Ok(ServerInfo::from(info))
+    }
+
+    /// The round trip to the session manager.
+    pub async fn ping(&self) -> Result<Duration, FlameError> {
+        trace_fn!("Connection::ping");
+        let mut client = FlameClient::new(self.channel.clone());
+        let start = Instant::now();
+        client.get_server_info(GetServerInfoRequest {}).await?;
+
+        Ok(start.elapsed())
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_server_info() {
+        let info = ServerInfo::from(rpc::ServerInfo {
+            version: "0.5.0".to_string(),
+            features: vec!["upload".to_string(), "poll".to_string()],
+            max_message_size: 4 * 1024 * 1024,
+            max_input_size: 1 << 30,
+            max_create_tasks: 1000,
+            max_sessions: None,
+            server_time: 1_700_000_000_000,
+        });
+        assert!(info.supports("poll"));
+        assert!(!info.supports("lineage"));
+        assert!(info.server_time.is_some());
+
+        assert!(info.check_input(1024).is_ok());
+        let err = info.check_input(4 * 1024 * 1024).unwrap_err();
+        assert!(matches!(err, FlameError::QuotaExceeded(_)));
+        assert!(info.check_upload(1 << 30).is_ok());
+        assert!(info.check_upload((1 << 30) + 1).is_err());
+
+        // The limits which are not reported are not checked.
+        let info = ServerInfo::from(rpc::ServerInfo::default());
+        assert!(info.check_input(usize::MAX).is_ok());
+        assert!(info.check_upload(u64::MAX).is_ok());
+    }
+}
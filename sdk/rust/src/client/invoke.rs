@@ -0,0 +1,70 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//! Run one task of an application in a session of its own, e.g. for the CLI
+//! tools and the serverless-style callers:
+//!
+//! ```ignore
+//! let output = flame_rs::client::invoke("http://flame:8080", "pi", Some(input)).await?;
+//! ```
+
+use stdng::trace_fn;
+
+use crate::apis::{FlameError, TaskInput, TaskOutput};
+use crate::client::options::ConnectOptions;
+use crate::client::template::SessionTemplate;
+use crate::client::typed::output_of;
+use crate::client::Connection;
+
+/// Connect to the session manager and invoke the application; see
+/// `Connection::invoke`.
+pub async fn invoke(
+    endpoint: &str,
+    application: &str,
+    input: Option<TaskInput>,
+) -> Result<TaskOutput, FlameError> {
+    invoke_with(&ConnectOptions::new(endpoint), application, input).await
+}
+
+pub async fn invoke_with(
+    options: &ConnectOptions,
+    application: &str,
+    input: Option<TaskInput>,
+) -> Result<TaskOutput, FlameError> {
+    let conn = options.connect().await?;
+    conn.invoke(application, input).await
+}
+
+impl Connection {
+    /// Create a session of the application, run the task and wait for its
+    /// output; the session is closed whether the task succeeded or not, and
+    /// the failed task is an error with its reason.
+    pub async fn invoke(
+        &self,
+        application: &str,
+        input: Option<TaskInput>,
+    ) -> Result<TaskOutput, FlameError> {
+        trace_fn!("Connection::invoke");
+        let id = format!("{application}-{}", stdng::rand::short_name());
+        let ssn = self
+            .create_session(&SessionTemplate::new(application).attributes(&id))
+            .await?;
+
+        let res = ssn.submit_and_wait(input, None).await;
+        if let Err(e) = ssn.close().await {
+            tracing::warn!("Failed to close session <{id}> of the invocation: {e}");
+        }
+
+        output_of(&res?)
+    }
+}
@@ -0,0 +1,96 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//! The lineage of a task, i.e. the task and the tasks it depends on with how
+//! they were created, to trace how its output was produced, e.g.
+//!
+//! ```ignore
+//! for task in session.get_task_lineage(&task.id).await? {
+//!     println!("{} by {:?}: {:?}", task.id, task.subject, task.input_checksum);
+//! }
+//! ```
+
+use stdng::trace_fn;
+
+use crate::apis::{FlameError, SessionID, TaskID, TaskState};
+use crate::client::rpc::{self, GetTaskLineageRequest};
+use crate::client::Session;
+
+/// The provenance of a task in the lineage.
+#[derive(Clone, Debug, Default)]
+pub struct TaskProvenance {
+    pub id: TaskID,
+    pub ssn_id: SessionID,
+    pub state: TaskState,
+    /// The subject of the user who submitted the task, if known.
+    pub subject: Option<String>,
+    /// The version of the application when the task was created; zero if
+    /// unknown, e.g. created by an older session manager.
+    pub application_version: u32,
+    /// The checksum of the input when the task was created.
+    pub input_checksum: Option<String>,
+    /// The parents of the task in the lineage.
+    pub depends_on: Vec<TaskID>,
+}
+
+impl TryFrom<&rpc::Task> for TaskProvenance {
+    type Error = FlameError;
+
+    fn try_from(task: &rpc::Task) -> Result<Self, FlameError> {
+        let metadata = task
+            .metadata
+            .as_ref()
+            .ok_or_else(|| FlameError::Internal("missing metadata in response".to_string()))?;
+        let spec = task
+            .spec
+            .as_ref()
+            .ok_or_else(|| FlameError::Internal("missing spec in response".to_string()))?;
+        let status = task
+            .status
+            .as_ref()
+            .ok_or_else(|| FlameError::Internal("missing status in response".to_string()))?;
+        let provenance = status.provenance.clone().unwrap_or_default();
+
+        Ok(Self {
+            id: metadata.id.clone(),
+            ssn_id: spec.session_id.clone(),
+            state: TaskState::try_from(status.state).unwrap_or_default(),
+            subject: spec.principal.as_ref().map(|p| p.subject.clone()),
+            application_version: provenance.application_version,
+            input_checksum: provenance.input_checksum,
+            depends_on: spec.depends_on.clone(),
+        })
+    }
+}
+
+impl Session {
+    /// The task and the tasks it depends on directly or through other tasks,
+    /// in the order of task id, i.e. the parents first.
+    pub async fn get_task_lineage(&self, id: &TaskID) -> Result<Vec<TaskProvenance>, FlameError> {
+        trace_fn!("Session::get_task_lineage");
+        let mut client = self
+            .client
+            .clone()
+            .ok_or(FlameError::Internal("no flame client".to_string()))?;
+
+        let lineage = client
+            .get_task_lineage(GetTaskLineageRequest {
+                task_id: id.clone(),
+                session_id: self.id.clone(),
+            })
+            .await?
+            .into_inner();
+
+        lineage.tasks.iter().map(TaskProvenance::try_from).collect()
+    }
+}
@@ -0,0 +1,246 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//! The map/reduce of a session: one task per input with bounded tasks in
+//! flight, and the outputs in the order of the inputs; all the tasks are
+//! drained before the failures are reported, e.g.
+//!
+//! ```ignore
+//! let total = session
+//!     .map_reduce(inputs, 0u64, |total, output| Ok(total + output.len() as u64))
+//!     .await?;
+//! ```
+
+use std::future::Future;
+use std::time::Duration;
+
+use futures::StreamExt;
+use stdng::trace_fn;
+
+use crate::apis::{FlameError, TaskInput, TaskOutput};
+use crate::client::typed::output_of;
+use crate::client::Session;
+
+/// The default number of the tasks in flight of a map.
+pub const DEFAULT_MAP_CONCURRENCY: usize = 64;
+
+#[derive(Clone, Debug)]
+pub struct MapOptions {
+    /// The maximum number of the tasks in flight.
+    pub concurrency: usize,
+    /// The timeout of each task; the task is failed if it's not completed
+    /// within the timeout.
+    pub timeout: Option<Duration>,
+}
+
+impl Default for MapOptions {
+    fn default() -> Self {
+        MapOptions {
+            concurrency: DEFAULT_MAP_CONCURRENCY,
+            timeout: None,
+        }
+    }
+}
+
+impl MapOptions {
+    pub fn new() -> Self {
+        Self::default()
+    }
+
+    pub fn with_concurrency(mut self, concurrency: usize) -> Self {
+        self.concurrency = concurrency;
+        self
+    }
+
+    pub fn with_timeout(mut self, timeout: Duration) -> Self {
+        self.timeout = Some(timeout);
+        self
+    }
+
+    pub fn validate(&self) -> Result<(), FlameError> {
+        if self.concurrency == 0 {
+            return Err(FlameError::InvalidConfig(
+                "the concurrency of the map must be positive".to_string(),
+            ));
+        }
+        if self.timeout.is_some_and(|t| t.is_zero()) {
+            return Err(FlameError::InvalidConfig(
+                "the timeout of the tasks must be positive".to_string(),
+            ));
+        }
+
+        Ok(())
+    }
+}
+
+impl Session {
+    /// Submit one task per input and wait for all of them; the outputs are
+    /// in the order of the inputs.
+    pub async fn map(&self, inputs: Vec<TaskInput>) -> Result<Vec<TaskOutput>, FlameError> {
+        self.map_with(inputs, &MapOptions::default()).await
+    }
+
+    /// Same as `map` with the options; the error reports the number of the
+    /// failed tasks and the first of them.
+    pub async fn map_with(
+        &self,
+        inputs: Vec<TaskInput>,
+        opts: &MapOptions,
+    ) -> Result<Vec<TaskOutput>, FlameError> {
+        opts.validate()?;
+        collect(self.map_results(inputs, opts).await)
+    }
+
+    /// Submit one task per input and wait for all of them; the result of
+    /// each task is in the order of the inputs, so the failures are handled
+    /// one by one.
+    pub async fn map_results(
+        &self,
+        inputs: Vec<TaskInput>,
+        opts: &MapOptions,
+    ) -> Vec<Result<TaskOutput, FlameError>> {
+        trace_fn!("Session::map_results");
+        let timeout = opts.timeout;
+
+        map_ordered(inputs, opts.concurrency, |input| async move {
+            let task = self.submit_and_wait(Some(input), timeout).await?;
+            output_of(&task)
+        })
+        .await
+    }
+
+    /// Map the inputs and reduce the outputs in the order of the inputs; the
+    /// reduce is only called if all the tasks succeed.
+    pub async fn map_reduce<R, F>(
+        &self,
+        inputs: Vec<TaskInput>,
+        init: R,
+        reduce: F,
+    ) -> Result<R, FlameError>
+    where
+        F: FnMut(R, TaskOutput) -> Result<R, FlameError>,
+    {
+        self.map(inputs).await?.into_iter().try_fold(init, reduce)
+    }
+}
+
+/// Run `f` for each input with at most `concurrency` in flight; the results
+/// are in the order of the inputs whatever the order of completion.
+async fn map_ordered<T, F, Fut>(
+    inputs: Vec<TaskInput>,
+    concurrency: usize,
+    f: F,
+) -> Vec<Result<T, FlameError>>
+where
+    F: FnMut(TaskInput) -> Fut,
+    Fut: Future<Output = Result<T, FlameError>>,
+{
+    futures::stream::iter(inputs)
+        .map(f)
+        .buffered(concurrency.max(1))
+        .collect()
+        .await
+}
+
+pub(crate) fn collect(
+    results: Vec<Result<TaskOutput, FlameError>>,
+) -> Result<Vec<TaskOutput>, FlameError> {
+    let total = results.len();
+    let mut outputs = Vec::with_capacity(total);
+    let mut failures = vec![];
+
+    for (index, result) in results.into_iter().enumerate() {
+        match result {
+            Ok(output) => outputs.push(output),
+            Err(e) => failures.push((index, e)),
+        }
+    }
+
+    match failures.first() {
+        None => Ok(outputs),
+        Some((index, e)) => Err(FlameError::Internal(format!(
+            "{} of {total} tasks failed, the first is input <{index}>: {e}",
+            failures.len()
+        ))),
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use std::sync::atomic::{AtomicUsize, Ordering};
+
+    use bytes::Bytes;
+
+    use super::*;
+
+    fn inputs(n: usize) -> Vec<TaskInput> {
+        (0..n).map(|i| Bytes::from(i.to_string())).collect()
+    }
+
+    #[tokio::test]
+    async fn test_map_ordered() {
+        let in_flight = AtomicUsize::new(0);
+        let max_in_flight = AtomicUsize::new(0);
+
+        // The later inputs are completed first.
+        let results = map_ordered(inputs(8), 3, |input| {
+            let in_flight = &in_flight;
+            let max_in_flight = &max_in_flight;
+            async move {
+                let current = in_flight.fetch_add(1, Ordering::SeqCst) + 1;
+                max_in_flight.fetch_max(current, Ordering::SeqCst);
+
+                let i: u64 = String::from_utf8_lossy(&input).parse().unwrap();
+                tokio::time::sleep(Duration::from_millis(40 - i * 5)).await;
+
+                in_flight.fetch_sub(1, Ordering::SeqCst);
+                Ok::<_, FlameError>(input)
+            }
+        })
+        .await;
+
+        let outputs = collect(results).unwrap();
+        assert_eq!(outputs, inputs(8));
+        assert!(max_in_flight.load(Ordering::SeqCst) <= 3);
+    }
+
+    #[test]
+    fn test_map_options() {
+        let opts = MapOptions::new()
+            .with_concurrency(4)
+            .with_timeout(Duration::from_secs(10));
+        assert_eq!(opts.concurrency, 4);
+        assert!(opts.validate().is_ok());
+
+        assert!(MapOptions::new().with_concurrency(0).validate().is_err());
+        assert!(MapOptions::new()
+            .with_timeout(Duration::ZERO)
+            .validate()
+            .is_err());
+    }
+
+    #[test]
+    fn test_collect_failures() {
+        let results = vec![
+            Ok(Bytes::from("0")),
+            Err(FlameError::Internal("division by zero".to_string())),
+            Ok(Bytes::from("2")),
+            Err(FlameError::Internal("timeout".to_string())),
+        ];
+
+        let err = collect(results).unwrap_err().to_string();
+        assert!(err.contains("2 of 4 tasks failed"));
+        assert!(err.contains("input <1>"));
+        assert!(err.contains("division by zero"));
+    }
+}
@@ -0,0 +1,235 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//! The middlewares of the client, which see the operations of the SDK, i.e.
+//! submitting the tasks, watching them and closing the sessions, instead of
+//! the raw RPCs; e.g. to tag the tasks, check the permissions, record the
+//! metrics or dry run:
+//!
+//! ```ignore
+//! struct TraceLabel(String);
+//!
+//! impl ClientMiddleware for TraceLabel {
+//!     fn on_submit(&self, task: &mut TaskSubmission) -> Result<(), FlameError> {
+//!         task.labels.push(format!("trace-id={}", self.0));
+//!         Ok(())
+//!     }
+//! }
+//!
+//! let conn = flame::client::connect(addr)
+//!     .await?
+//!     .with_middleware(Arc::new(TraceLabel(trace_id)));
+//! ```
+//!
+//! The middlewares are called in the order they were added; an error of the
+//! `on_` callbacks rejects the operation before it's sent, e.g. a dry run, and
+//! the later middlewares are not called. The sessions inherit the middlewares
+//! of their connection.
+
+use std::sync::Arc;
+use std::time::{Duration, Instant};
+
+use crate::apis::flame::v1::TaskSpec;
+use crate::apis::{FlameError, SessionID};
+use crate::client::{Connection, Session};
+
+pub type ClientMiddlewarePtr = Arc<dyn ClientMiddleware>;
+
+/// The operations seen by the middlewares.
+#[derive(Clone, Copy, Debug, PartialEq, Eq, strum_macros::Display)]
+pub enum Operation {
+    Submit,
+    Watch,
+    Close,
+}
+
+/// The task submitted to a session; the changes of the middlewares are sent
+/// with the task.
+#[derive(Clone, Debug, Default)]
+pub struct TaskSubmission {
+    pub session_id: SessionID,
+    /// The size of the input in bytes, or 0 if it's uploaded in chunks; the
+    /// input itself is not changed.
+    pub input_size: usize,
+    pub tags: Vec<String>,
+    pub labels: Vec<String>,
+    pub group: Option<String>,
+}
+
+pub trait ClientMiddleware: Send + Sync + 'static {
+    /// Before a task is submitted to the session, e.g. to tag it.
+    fn on_submit(&self, _task: &mut TaskSubmission) -> Result<(), FlameError> {
+        Ok(())
+    }
+
+    /// Before a task of the session is watched.
+    fn on_watch(&self, _session_id: &str, _task_id: &str) -> Result<(), FlameError> {
+        Ok(())
+    }
+
+    /// Before the session is closed.
+    fn on_close(&self, _session_id: &str) -> Result<(), FlameError> {
+        Ok(())
+    }
+
+    /// After the operation was sent, with its duration and its error if it
+    /// failed, e.g. to record the metrics.
+    fn on_complete(
+        &self,
+        _operation: Operation,
+        _session_id: &str,
+        _elapsed: Duration,
+        _error: Option<&FlameError>,
+    ) {
+    }
+}
+
+/// The middlewares of a connection or a session, in order.
+#[derive(Clone, Default)]
+pub(crate) struct Middlewares(Vec<ClientMiddlewarePtr>);
+
+impl Middlewares {
+    fn push(&mut self, middleware: ClientMiddlewarePtr) {
+        self.0.push(middleware);
+    }
+
+    /// Apply the middlewares to the spec of the submitted task.
+    pub(crate) fn submit(&self, spec: &mut TaskSpec) -> Result<(), FlameError> {
+        if self.0.is_empty() {
+            return Ok(());
+        }
+
+        let mut task = TaskSubmission {
+            session_id: spec.session_id.clone(),
+            input_size: spec.input.as_ref().map_or(0, Vec::len),
+            tags: std::mem::take(&mut spec.tags),
+            labels: std::mem::take(&mut spec.labels),
+            group: spec.group.take(),
+        };
+        let res = self.0.iter().try_for_each(|m| m.on_submit(&mut task));
+
+        spec.tags = task.tags;
+        spec.labels = task.labels;
+        spec.group = task.group;
+        res
+    }
+
+    pub(crate) fn watch(&self, session_id: &str, task_id: &str) -> Result<(), FlameError> {
+        self.0
+            .iter()
+            .try_for_each(|m| m.on_watch(session_id, task_id))
+    }
+
+    pub(crate) fn close(&self, session_id: &str) -> Result<(), FlameError> {
+        self.0.iter().try_for_each(|m| m.on_close(session_id))
+    }
+
+    /// Report the result of the operation started at `start`.
+    pub(crate) fn complete<T>(
+        &self,
+        operation: Operation,
+        session_id: &str,
+        start: Instant,
+        res: &Result<T, FlameError>,
+    ) {
+        let elapsed = start.elapsed();
+        for m in &self.0 {
+            m.on_complete(operation, session_id, elapsed, res.as_ref().err());
+        }
+    }
+}
+
+impl Connection {
+    /// Add the middleware to the operations of the connection and its
+    /// sessions, after the middlewares added before.
+    pub fn with_middleware(mut self, middleware: ClientMiddlewarePtr) -> Self {
+        self.middlewares.push(middleware);
+        self
+    }
+}
+
+impl Session {
+    /// Add the middleware to the operations of the session only, after the
+    /// middlewares of its connection.
+    pub fn with_middleware(mut self, middleware: ClientMiddlewarePtr) -> Self {
+        self.middlewares.push(middleware);
+        self
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use std::sync::Mutex;
+
+    use super::*;
+
+    struct Recorder {
+        label: &'static str,
+        seen: Arc<Mutex<Vec<String>>>,
+    }
+
+    impl ClientMiddleware for Recorder {
+        fn on_submit(&self, task: &mut TaskSubmission) -> Result<(), FlameError> {
+            if task.input_size > 3 {
+                return Err(FlameError::InvalidConfig("dry run".to_string()));
+            }
+            task.labels.push(self.label.to_string());
+            Ok(())
+        }
+
+        fn on_complete(
+            &self,
+            operation: Operation,
+            session_id: &str,
+            _: Duration,
+            error: Option<&FlameError>,
+        ) {
+            self.seen.lock().unwrap().push(format!(
+                "{}:{operation}:{session_id}:{}",
+                self.label,
+                error.is_some()
+            ));
+        }
+    }
+
+    #[test]
+    fn test_middlewares() {
+        let seen = Arc::new(Mutex::new(vec![]));
+        let mut middlewares = Middlewares::default();
+        for label in ["a", "b"] {
+            middlewares.push(Arc::new(Recorder {
+                label,
+                seen: seen.clone(),
+            }));
+        }
+
+        let mut spec = TaskSpec {
+            session_id: "ssn-1".to_string(),
+            input: Some(b"abc".to_vec()),
+            group: Some("extract".to_string()),
+            ..Default::default()
+        };
+        middlewares.submit(&mut spec).unwrap();
+        assert_eq!(spec.labels, vec!["a".to_string(), "b".to_string()]);
+        assert_eq!(spec.group.as_deref(), Some("extract"));
+
+        spec.input = Some(b"abcd".to_vec());
+        assert!(middlewares.submit(&mut spec).is_err());
+
+        middlewares.complete(Operation::Close, "ssn-1", Instant::now(), &Ok(()));
+        assert_eq!(
+            *seen.lock().unwrap(),
+            vec!["a:Close:ssn-1:false", "b:Close:ssn-1:false"]
+        );
+    }
+}
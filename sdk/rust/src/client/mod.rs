@@ -13,6 +13,7 @@ limitations under the License.
 
 use std::collections::HashMap;
 use std::sync::{Arc, Mutex};
+use std::time::Instant;
 
 use chrono::{DateTime, Duration, TimeZone, Utc};
 use futures::TryFutureExt;
@@ -23,24 +24,72 @@ use tokio_stream::StreamExt;
 use tonic::transport::Channel;
 use tonic::transport::Endpoint;
 use tonic::Request;
-use url::Url;
 
+pub use self::invoke::{invoke, invoke_with};
+use self::options::ConnectOptions;
 use self::rpc::frontend_client::FrontendClient as FlameFrontendClient;
 use self::rpc::{
-    ApplicationSpec, CloseSessionRequest, CreateSessionRequest, CreateTaskRequest, Environment,
-    GetApplicationRequest, GetNodeRequest, GetSessionRequest, GetTaskRequest,
-    ListApplicationRequest, ListExecutorRequest, ListNodesRequest, ListSessionRequest,
-    ListTaskRequest, OpenSessionRequest, RegisterApplicationRequest, SessionSpec, TaskSpec,
-    UnregisterApplicationRequest, UpdateApplicationRequest, WatchTaskRequest,
+    ApplicationSpec, BoostSessionRequest, CloseSessionRequest, CreateSessionRequest,
+    CreateTaskRequest, CreateTasksRequest, Environment, GetApplicationRequest,
+    GetExecutorJournalRequest, GetNodeRequest, GetSessionOutputsRequest, GetSessionRequest,
+    GetSessionStatsRequest, GetTaskRequest, GetTimelineRequest, ListApplicationRequest,
+    ListExecutorRequest, ListNodesRequest, ListSessionRequest, ListTaskRequest, OpenSessionRequest,
+    RegisterApplicationRequest, RotateTenantKeyRequest, RunTaskRequest, TaskSpec,
+    UnregisterApplicationRequest, UpdateApplicationRequest, WaitForGroupRequest, WatchTaskRequest,
 };
 use crate::apis::flame::v1 as rpc;
 use crate::apis::FlameClientTls;
 use crate::apis::{
-    ApplicationID, ApplicationState, CommonData, ExecutorState, FlameError, SessionID,
-    SessionState, Shim, TaskID, TaskInput, TaskOutput, TaskState,
+    checksum, crypto, ApplicationID, ApplicationState, CommonData, ExecutorState, FlameError,
+    SessionID, SessionState, Shim, TaskID, TaskInput, TaskOutput, TaskState, LABEL_METHOD,
 };
 
-type FlameClient = FlameFrontendClient<Channel>;
+pub mod cache;
+pub mod dag;
+pub mod discovery;
+pub mod download;
+pub mod events;
+pub mod failover;
+pub mod future;
+pub mod group;
+pub mod info;
+pub mod invoke;
+pub mod lineage;
+pub mod mapreduce;
+pub mod middleware;
+pub mod options;
+pub mod pool;
+pub mod precheck;
+pub mod progress;
+pub mod ratelimit;
+pub mod replication;
+pub mod results;
+pub mod scope;
+pub mod sessions;
+pub mod tasks;
+pub mod template;
+pub mod transport;
+pub mod typed;
+pub mod upload;
+pub mod wait;
+pub mod watch;
+
+type FlameClient = FlameFrontendClient<transport::Transport>;
+
+/// The retries of the get-modify-update helpers on conflicts.
+const DEFAULT_CONFLICT_RETRIES: u32 = 5;
+
+/// The page size of collecting the outputs of a session.
+const DEFAULT_OUTPUT_PAGE_SIZE: u32 = 100;
+
+/// The tasks of each CreateTasks by `submit_batch`.
+const DEFAULT_SUBMIT_BATCH_SIZE: usize = 500;
+
+/// The CreateTasks in flight by `submit_batch`.
+const DEFAULT_SUBMIT_BATCH_CONCURRENCY: usize = 4;
+
+/// The timeout of draining the session by `Session::close`.
+const DEFAULT_DRAIN_TIMEOUT: std::time::Duration = std::time::Duration::from_secs(60);
 
 /// Connect to a Flame service without TLS (plaintext).
 ///
@@ -52,47 +101,160 @@ pub async fn connect(addr: &str) -> Result<Connection, FlameError> {
 /// Connect to a Flame service with optional TLS configuration.
 ///
 /// # Arguments
-/// * `addr` - The endpoint URL (use https:// for TLS, http:// for plaintext)
+/// * `addr` - The endpoint URL (use https:// for TLS, http:// for plaintext), or
+///   the address to discover the session managers, e.g. `dns:///flame:8080`;
+///   see `discovery` for the schemes
 /// * `tls_config` - Optional TLS configuration for secure connections
 ///
 /// # TLS Behavior
 /// - If `addr` starts with `https://` and `tls_config` is `Some`, use provided TLS config
 /// - If `addr` starts with `https://` and `tls_config` is `None`, use default TLS config (system CA)
 /// - If `addr` starts with `http://`, TLS is not used regardless of `tls_config`
+/// - If `addr` is resolved by DNS, TLS is used only if `tls_config` is `Some`
+///
+/// If several session managers are discovered, the requests are balanced over
+/// them and the connections are established on demand. If the only session
+/// manager is a standby, the connection is redirected to its leader. See
+/// `ConnectOptions` for the retries, keepalive, timeouts and the failover
+/// between the session managers.
 pub async fn connect_with_tls(
     addr: &str,
     tls_config: Option<&FlameClientTls>,
 ) -> Result<Connection, FlameError> {
-    let mut channel_builder = Endpoint::from_shared(addr.to_string())
-        .map_err(|_| FlameError::InvalidConfig(format!("invalid address <{addr}>")))?;
+    options_of(addr, tls_config).connect().await
+}
 
-    // Apply TLS if endpoint uses https://
-    if addr.starts_with("https://") {
-        // Extract domain name from URL for TLS verification
-        let url = Url::parse(addr)
-            .map_err(|e| FlameError::InvalidConfig(format!("invalid URL <{}>: {}", addr, e)))?;
-        let domain = url
-            .host_str()
-            .ok_or_else(|| FlameError::InvalidConfig(format!("no host in URL <{}>", addr)))?;
-
-        let client_tls_config = if let Some(tls) = tls_config {
-            tls.client_tls_config(domain)?
-        } else {
-            // Use default TLS config (system CA bundle)
-            tonic::transport::ClientTlsConfig::new().domain_name(domain)
-        };
+/// Connect to the session manager without the redirection of the standby to
+/// its leader, e.g. to promote the standby.
+pub async fn connect_standby(
+    addr: &str,
+    tls_config: Option<&FlameClientTls>,
+) -> Result<Connection, FlameError> {
+    options_of(addr, tls_config)
+        .without_redirect()
+        .connect()
+        .await
+}
 
-        channel_builder = channel_builder.tls_config(client_tls_config).map_err(|e| {
-            FlameError::InvalidConfig(format!("TLS config error for <{}>: {}", addr, e))
-        })?;
-        tracing::debug!("TLS enabled for connection to {}", addr);
+fn options_of(addr: &str, tls_config: Option<&FlameClientTls>) -> ConnectOptions {
+    let options = ConnectOptions::new(addr);
+    match tls_config {
+        Some(tls) => options.with_tls(tls.clone()),
+        None => options,
+    }
+}
+
+async fn connect_to(options: &ConnectOptions) -> Result<Connection, FlameError> {
+    crypto::init()?;
+    if !options.failover.is_empty() {
+        return failover::connect(options).await;
+    }
+
+    let addr = options.endpoint.as_str();
+    let tls_config = options.tls.as_ref();
+    let targets = discovery::resolve(addr, tls_config.is_some()).await?;
+
+    let mut endpoints = Vec::with_capacity(targets.len());
+    for target in &targets {
+        endpoints.push(endpoint_of(target, options)?);
+    }
+
+    let channel = match endpoints.pop() {
+        Some(channel_builder) if endpoints.is_empty() => {
+            let channel = transport::connect(&channel_builder, &targets[0], options, addr).await?;
+
+            if !options.redirect {
+                return Ok(Connection {
+                    channel,
+                    middlewares: Default::default(),
+                    submit_limit: None,
+                });
+            }
+
+            // The standby rejects the requests, so connect to its leader.
+            match replication::leader_of(&channel).await {
+                Some(leader) => {
+                    tracing::info!("<{addr}> is a standby, connecting to the leader <{leader}>");
+                    let target = discovery::target_of(&leader)?;
+                    transport::connect(&endpoint_of(&target, options)?, &target, options, &leader)
+                        .await?
+                }
+                None => channel,
+            }
+        }
+        Some(channel_builder) => {
+            endpoints.push(channel_builder);
+            tracing::debug!(
+                "Balance over {} session managers of <{addr}>",
+                endpoints.len()
+            );
+            Channel::balance_list(endpoints.into_iter()).into()
+        }
+        None => {
+            return Err(FlameError::InvalidConfig(format!(
+                "no session manager found by <{addr}>"
+            )))
+        }
+    };
+
+    Ok(Connection {
+        channel,
+        middlewares: Default::default(),
+        submit_limit: None,
+    })
+}
+
+fn endpoint_of(
+    target: &discovery::Target,
+    options: &ConnectOptions,
+) -> Result<Endpoint, FlameError> {
+    let channel_builder = Endpoint::from_shared(target.uri.clone())
+        .map_err(|_| FlameError::InvalidConfig(format!("invalid address <{}>", target.uri)))?;
+    let channel_builder = options.apply(channel_builder);
+
+    // Apply TLS if endpoint uses https://
+    if target.is_tls() {
+        return with_tls(channel_builder, target, options.tls.as_ref());
     }
 
-    let channel = channel_builder.connect().await.map_err(|e| {
-        FlameError::InvalidConfig(format!("failed to connect to <{}>: {}", addr, e))
+    Ok(channel_builder)
+}
+
+#[cfg(feature = "tls")]
+fn with_tls(
+    channel_builder: Endpoint,
+    target: &discovery::Target,
+    tls_config: Option<&FlameClientTls>,
+) -> Result<Endpoint, FlameError> {
+    // The domain name of the target for TLS verification
+    let domain = target.domain.as_str();
+
+    let client_tls_config = if let Some(tls) = tls_config {
+        tls.client_tls_config(domain)?
+    } else {
+        // Use default TLS config (system CA bundle)
+        tonic::transport::ClientTlsConfig::new().domain_name(domain)
+    };
+
+    let channel_builder = channel_builder.tls_config(client_tls_config).map_err(|e| {
+        FlameError::InvalidConfig(format!("TLS config error for <{}>: {}", target.uri, e))
     })?;
+    tracing::debug!("TLS enabled for connection to {}", target.uri);
+
+    Ok(channel_builder)
+}
 
-    Ok(Connection { channel })
+/// The lite client, i.e. without the `tls` feature, only talks plaintext.
+#[cfg(not(feature = "tls"))]
+fn with_tls(
+    _: Endpoint,
+    target: &discovery::Target,
+    _: Option<&FlameClientTls>,
+) -> Result<Endpoint, FlameError> {
+    Err(FlameError::InvalidConfig(format!(
+        "TLS is not supported by the lite client, enable the `tls` feature for <{}>",
+        target.uri
+    )))
 }
 
 #[derive(Clone, Debug, Serialize, Deserialize)]
@@ -105,7 +267,9 @@ pub struct Event {
 
 #[derive(Clone)]
 pub struct Connection {
-    pub(crate) channel: Channel,
+    pub(crate) channel: transport::Transport,
+    pub(crate) middlewares: middleware::Middlewares,
+    pub(crate) submit_limit: Option<ratelimit::RateLimiterPtr>,
 }
 
 #[derive(Clone, Serialize, Deserialize)]
@@ -119,6 +283,13 @@ pub struct SessionAttributes {
     pub max_instances: Option<u32>,
     #[serde(default = "default_batch_size")]
     pub batch_size: u32,
+    /// The content type of the inputs of the tasks, e.g. `application/json`;
+    /// the first content type of the application by default.
+    #[serde(default)]
+    pub content_type: Option<String>,
+    /// The labels of the session, e.g. `team=ml`, to filter the sessions.
+    #[serde(default)]
+    pub labels: Vec<String>,
 }
 
 fn default_batch_size() -> u32 {
@@ -130,6 +301,10 @@ pub struct ApplicationSchema {
     pub input: Option<String>,
     pub output: Option<String>,
     pub common_data: Option<String>,
+    /// The content types of the inputs, e.g. `application/json`, in the order
+    /// of preference.
+    #[serde(default)]
+    pub content_types: Vec<String>,
 }
 
 #[derive(Clone, Serialize, Deserialize)]
@@ -152,6 +327,9 @@ pub struct ApplicationAttributes {
 #[derive(Clone, Serialize, Deserialize)]
 pub struct Application {
     pub name: ApplicationID,
+    /// The revision of the application, which is changed by each update.
+    #[serde(default)]
+    pub resource_version: u32,
 
     pub attributes: ApplicationAttributes,
 
@@ -178,6 +356,9 @@ pub struct Node {
     pub memory: u64,
     pub arch: String,
     pub os: String,
+    /// The labels of the node, e.g. the instance type and zone.
+    #[serde(default)]
+    pub labels: HashMap<String, String>,
 }
 
 #[derive(Clone, Copy, Debug, Default, Eq, PartialEq, Hash, Serialize, Deserialize)]
@@ -188,16 +369,131 @@ pub enum NodeState {
     NotReady = 2,
 }
 
+/// The order of the outputs of a session.
+#[derive(Clone, Copy, Debug, Default, Eq, PartialEq, Hash, Serialize, Deserialize)]
+pub enum OutputOrder {
+    /// The order in which the tasks were submitted.
+    #[default]
+    Submission = 0,
+    /// The order in which the tasks were completed.
+    Completion = 1,
+}
+
+impl From<OutputOrder> for rpc::OutputOrder {
+    fn from(order: OutputOrder) -> Self {
+        match order {
+            OutputOrder::Submission => rpc::OutputOrder::Submission,
+            OutputOrder::Completion => rpc::OutputOrder::Completion,
+        }
+    }
+}
+
+/// A page of the outputs of a session; `next_page_token` is None for the
+/// last page.
+#[derive(Clone, Default)]
+pub struct OutputPage {
+    pub tasks: Vec<Task>,
+    pub next_page_token: Option<String>,
+}
+
+/// An entry of the journal of the backend RPCs of an executor.
+#[derive(Clone, Debug)]
+pub struct JournalEntry {
+    pub timestamp: DateTime<Utc>,
+    /// The backend RPC, e.g. "BindExecutor".
+    pub method: String,
+    /// "request", "response" or "error".
+    pub kind: String,
+    pub detail: String,
+}
+
+/// The journal of the backend RPCs of an executor, from the oldest to the
+/// latest entry; it's only kept if enabled in the cluster.
+#[derive(Clone, Debug, Default)]
+pub struct ExecutorJournal {
+    pub enabled: bool,
+    pub entries: Vec<JournalEntry>,
+}
+
+/// The metrics of the scheduler at a time.
+#[derive(Clone, Debug)]
+pub struct TimelineSample {
+    pub timestamp: DateTime<Utc>,
+    pub pending_tasks: u64,
+    pub running_tasks: u64,
+    pub open_sessions: u64,
+    pub executors: u64,
+    pub idle_executors: u64,
+    /// The sessions bound to the executors since the previous sample.
+    pub binds: u64,
+}
+
+/// The time series of the scheduler metrics, from the oldest to the latest
+/// sample; it's only recorded if enabled in the cluster.
+#[derive(Clone, Debug, Default)]
+pub struct Timeline {
+    pub enabled: bool,
+    /// The seconds between two samples.
+    pub interval: u64,
+    pub samples: Vec<TimelineSample>,
+}
+
+/// The summary of the tasks of a session, e.g. for the progress bars.
+#[derive(Clone, Debug, Default)]
+pub struct SessionStats {
+    pub pending: u32,
+    pub running: u32,
+    pub succeed: u32,
+    pub failed: u32,
+    pub cancelled: u32,
+    /// The completed tasks per minute over the window.
+    pub throughput: f64,
+    /// The estimated time to complete the remaining tasks; None if no task
+    /// was completed in the window.
+    pub eta: Option<std::time::Duration>,
+    /// Whether the session is closed, so no more tasks are submitted.
+    pub closed: bool,
+}
+
+impl SessionStats {
+    pub fn total(&self) -> u32 {
+        self.pending + self.running + self.succeed + self.failed + self.cancelled
+    }
+
+    pub fn completed(&self) -> u32 {
+        self.succeed + self.failed + self.cancelled
+    }
+}
+
 #[derive(Clone, Serialize, Deserialize)]
 pub struct Session {
     #[serde(skip)]
     pub(crate) client: Option<FlameClient>,
+    #[serde(skip)]
+    pub(crate) result_cache: Option<results::SessionResultCache>,
+    #[serde(skip)]
+    pub(crate) output_cache: Option<cache::OutputCachePtr>,
+    #[serde(skip)]
+    pub(crate) middlewares: middleware::Middlewares,
+    #[serde(skip)]
+    pub(crate) submit_limit: Option<ratelimit::RateLimiterPtr>,
+    /// Whether the tasks are watched by the long-poll; see `transport`.
+    #[serde(skip)]
+    pub(crate) long_poll: bool,
 
     pub id: SessionID,
+    /// The revision of the session, which is changed by each update.
+    #[serde(default)]
+    pub resource_version: u32,
     pub slots: u32,
     pub application: String,
     #[serde(with = "serde_utc")]
     pub creation_time: DateTime<Utc>,
+    /// The content type of the inputs negotiated with the application.
+    #[serde(default)]
+    pub content_type: Option<String>,
+    #[serde(default)]
+    pub labels: Vec<String>,
 
     pub state: SessionState,
     pub pending: i32,
@@ -220,6 +516,14 @@ pub struct Task {
     pub input: Option<TaskInput>,
     #[serde(with = "serde_message")]
     pub output: Option<TaskOutput>,
+    /// The reference of the output kept on the host of the executor, if it
+    /// can't be read on this host; the output is None then, and it's read by
+    /// `Session::output_reader`.
+    #[serde(default)]
+    pub output_ref: Option<String>,
+    /// The labels of the task given at the submission.
+    #[serde(default)]
+    pub labels: Vec<String>,
 
     pub events: Vec<Event>,
 }
@@ -255,14 +559,7 @@ impl Connection {
 
         let create_ssn_req = CreateSessionRequest {
             session_id: attrs.id.clone(),
-            session: Some(SessionSpec {
-                application: attrs.application.clone(),
-                slots: attrs.slots,
-                common_data: attrs.common_data.clone().map(CommonData::into),
-                min_instances: attrs.min_instances,
-                max_instances: attrs.max_instances,
-                batch_size: attrs.batch_size.max(1),
-            }),
+            session: Some(template::spec_of(attrs)),
         };
 
         let mut client = FlameClient::new(self.channel.clone());
@@ -270,12 +567,15 @@ impl Connection {
         let inner_ssn = ssn.into_inner();
         let mut ssn = Session::try_from(&inner_ssn)?;
         ssn.client = Some(client);
+        ssn.middlewares = self.middlewares.clone();
+        ssn.submit_limit = self.submit_limit.clone();
+        ssn.long_poll = self.channel.is_long_poll();
         Ok(ssn)
     }
 
     pub async fn list_session(&self) -> Result<Vec<Session>, FlameError> {
         let mut client = FlameClient::new(self.channel.clone());
-        let ssn_list = client.list_session(ListSessionRequest {}).await?;
+        let ssn_list = client.list_session(ListSessionRequest::default()).await?;
 
         let inner = ssn_list.into_inner();
         inner
@@ -296,6 +596,9 @@ impl Connection {
         let inner_ssn = ssn.into_inner();
         let mut ssn = Session::try_from(&inner_ssn)?;
         ssn.client = Some(client);
+        ssn.middlewares = self.middlewares.clone();
+        ssn.submit_limit = self.submit_limit.clone();
+        ssn.long_poll = self.channel.is_long_poll();
         Ok(ssn)
     }
 
@@ -304,14 +607,7 @@ impl Connection {
         id: &SessionID,
         spec: Option<&SessionAttributes>,
     ) -> Result<Session, FlameError> {
-        let session_spec = spec.map(|attrs| SessionSpec {
-            application: attrs.application.clone(),
-            slots: attrs.slots,
-            common_data: attrs.common_data.clone().map(CommonData::into),
-            min_instances: attrs.min_instances,
-            max_instances: attrs.max_instances,
-            batch_size: attrs.batch_size.max(1),
-        });
+        let session_spec = spec.map(template::spec_of);
 
         let open_ssn_req = OpenSessionRequest {
             session_id: id.clone(),
@@ -323,16 +619,36 @@ impl Connection {
         let inner_ssn = ssn.into_inner();
         let mut ssn = Session::try_from(&inner_ssn)?;
         ssn.client = Some(client);
+        ssn.middlewares = self.middlewares.clone();
+        ssn.submit_limit = self.submit_limit.clone();
+        ssn.long_poll = self.channel.is_long_poll();
         Ok(ssn)
     }
 
+    /// Attach to the existing session by its id, e.g. from another process
+    /// or after a restart, to submit tasks and watch their results; unlike
+    /// `open_session`, the session is never created, and it must be open.
+    /// The closed session is read by `get_session`.
+    pub async fn attach_session(&self, id: &SessionID) -> Result<Session, FlameError> {
+        trace_fn!("Connection::attach_session");
+        self.open_session(id, None).await
+    }
+
     pub async fn close_session(&self, id: &str) -> Result<(), FlameError> {
         let mut client = FlameClient::new(self.channel.clone());
-        client
+        self.middlewares.close(id)?;
+        let start = Instant::now();
+        let res = client
             .close_session(CloseSessionRequest {
                 session_id: id.to_string(),
+                resource_version: None,
+                drain_timeout: None,
             })
-            .await?;
+            .await
+            .map_err(FlameError::from);
+        self.middlewares
+            .complete(middleware::Operation::Close, id, start, &res);
+        res?;
 
         Ok(())
     }
@@ -365,12 +681,24 @@ impl Connection {
         &self,
         name: String,
         app: ApplicationAttributes,
+    ) -> Result<(), FlameError> {
+        self.update_application_if(name, app, None).await
+    }
+
+    /// Update the application only if its resource version is still `version`,
+    /// otherwise it's rejected with `FlameError::VersionMismatch`.
+    pub async fn update_application_if(
+        &self,
+        name: String,
+        app: ApplicationAttributes,
+        version: Option<u32>,
     ) -> Result<(), FlameError> {
         let mut client = FlameClient::new(self.channel.clone());
 
         let req = UpdateApplicationRequest {
             name,
             application: Some(ApplicationSpec::from(app)),
+            resource_version: version,
         };
 
         let res = client
@@ -385,6 +713,75 @@ impl Connection {
         }
     }
 
+    /// Get the application, modify its attributes by `f` and update it; the
+    /// whole get-modify-update is retried if the application was modified by
+    /// others in between.
+    pub async fn modify_application<F>(&self, name: &str, mut f: F) -> Result<(), FlameError>
+    where
+        F: FnMut(&mut ApplicationAttributes),
+    {
+        let mut retries = 0;
+        loop {
+            let mut app = self.get_application(name).await?;
+            f(&mut app.attributes);
+
+            match self
+                .update_application_if(name.to_string(), app.attributes, Some(app.resource_version))
+                .await
+            {
+                Err(FlameError::VersionMismatch(msg)) if retries < DEFAULT_CONFLICT_RETRIES => {
+                    retries += 1;
+                    tracing::debug!("Retry to update application <{name}> ({retries}): {msg}");
+                }
+                res => return res,
+            }
+        }
+    }
+
+    /// Register the application, or replace the attributes of the registered
+    /// one, e.g. by a deployment pipeline; it returns whether the application
+    /// was registered. The update is retried if the application was modified
+    /// by others in between.
+    pub async fn apply_application(
+        &self,
+        name: &str,
+        app: ApplicationAttributes,
+    ) -> Result<bool, FlameError> {
+        let mut retries = 0;
+        loop {
+            let current = self
+                .list_application()
+                .await?
+                .into_iter()
+                .find(|a| a.name == name);
+
+            let res = match current {
+                None => {
+                    return self
+                        .register_application(name.to_string(), app)
+                        .await
+                        .map(|_| true)
+                }
+                Some(current) => {
+                    self.update_application_if(
+                        name.to_string(),
+                        app.clone(),
+                        Some(current.resource_version),
+                    )
+                    .await
+                }
+            };
+
+            match res {
+                Err(FlameError::VersionMismatch(msg)) if retries < DEFAULT_CONFLICT_RETRIES => {
+                    retries += 1;
+                    tracing::debug!("Retry to apply application <{name}> ({retries}): {msg}");
+                }
+                res => return res.map(|_| false),
+            }
+        }
+    }
+
     pub async fn unregister_application(&self, name: String) -> Result<(), FlameError> {
         let mut client = FlameClient::new(self.channel.clone());
 
@@ -459,28 +856,408 @@ impl Connection {
             .ok_or(FlameError::NotFound(format!("node <{}> not found", name)))?;
         Ok(Node::from(&node))
     }
+
+    /// Get the journal of the backend RPCs of the executor, e.g. to diagnose
+    /// the stuck bindings.
+    pub async fn get_executor_journal(
+        &self,
+        executor_id: &str,
+    ) -> Result<ExecutorJournal, FlameError> {
+        trace_fn!("Connection::get_executor_journal");
+        let mut client = FlameClient::new(self.channel.clone());
+        let journal = client
+            .get_executor_journal(GetExecutorJournalRequest {
+                executor_id: executor_id.to_string(),
+            })
+            .await?
+            .into_inner();
+
+        let entries = journal
+            .entries
+            .into_iter()
+            .map(|entry| {
+                Ok(JournalEntry {
+                    timestamp: DateTime::from_timestamp_millis(entry.timestamp)
+                        .ok_or_else(|| FlameError::Internal("invalid timestamp".to_string()))?,
+                    method: entry.method,
+                    kind: entry.kind,
+                    detail: entry.detail,
+                })
+            })
+            .collect::<Result<Vec<_>, FlameError>>()?;
+
+        Ok(ExecutorJournal {
+            enabled: journal.enabled,
+            entries,
+        })
+    }
+
+    /// Get the timeline of the scheduler metrics in `[since, until)`, e.g. to
+    /// reconstruct what the cluster was doing during an incident; the last
+    /// hour by default.
+    pub async fn get_timeline(
+        &self,
+        since: Option<DateTime<Utc>>,
+        until: Option<DateTime<Utc>>,
+    ) -> Result<Timeline, FlameError> {
+        trace_fn!("Connection::get_timeline");
+        let mut client = FlameClient::new(self.channel.clone());
+        let timeline = client
+            .get_timeline(GetTimelineRequest {
+                since: since.map(|t| t.timestamp_millis()),
+                until: until.map(|t| t.timestamp_millis()),
+            })
+            .await?
+            .into_inner();
+
+        let samples = timeline
+            .samples
+            .into_iter()
+            .map(|sample| {
+                Ok(TimelineSample {
+                    timestamp: DateTime::from_timestamp_millis(sample.timestamp)
+                        .ok_or_else(|| FlameError::Internal("invalid timestamp".to_string()))?,
+                    pending_tasks: sample.pending_tasks,
+                    running_tasks: sample.running_tasks,
+                    open_sessions: sample.open_sessions,
+                    executors: sample.executors,
+                    idle_executors: sample.idle_executors,
+                    binds: sample.binds,
+                })
+            })
+            .collect::<Result<Vec<_>, FlameError>>()?;
+
+        Ok(Timeline {
+            enabled: timeline.enabled,
+            interval: timeline.interval,
+            samples,
+        })
+    }
+
+    /// Rotate the key of the tenant, which encrypts its new task data in the
+    /// storage of the cluster; the new version of the key is returned.
+    pub async fn rotate_tenant_key(&self, tenant: &str) -> Result<u32, FlameError> {
+        trace_fn!("Connection::rotate_tenant_key");
+        let mut client = FlameClient::new(self.channel.clone());
+        let key = client
+            .rotate_tenant_key(RotateTenantKeyRequest {
+                tenant: tenant.to_string(),
+            })
+            .await?
+            .into_inner();
+
+        Ok(key.version)
+    }
+
+    /// Boost the priority of the open session, e.g. to expedite a stuck
+    /// critical session; zero removes the boost. The previous priority is
+    /// returned, and only the admins boost the sessions.
+    pub async fn boost_session(&self, id: &str, priority: u32) -> Result<u32, FlameError> {
+        trace_fn!("Connection::boost_session");
+        let mut client = FlameClient::new(self.channel.clone());
+        let boost = client
+            .boost_session(BoostSessionRequest {
+                session_id: id.to_string(),
+                priority,
+            })
+            .await?
+            .into_inner();
+
+        Ok(boost.previous)
+    }
 }
 
 impl Session {
+    fn task_spec(
+        &self,
+        input: Option<TaskInput>,
+        tags: Vec<String>,
+        deadline: Option<DateTime<Utc>>,
+    ) -> TaskSpec {
+        TaskSpec {
+            session_id: self.id.clone(),
+            input_checksum: input.as_deref().map(checksum::checksum),
+            input: input.map(|input| input.to_vec()),
+            output: None,
+            output_checksum: None,
+            output_ref: None,
+            // The principal is set by the session manager.
+            principal: None,
+            tags,
+            deadline: deadline.map(|d| d.timestamp_millis()),
+            group: None,
+            labels: vec![],
+            run_at: None,
+            depends_on: vec![],
+        }
+    }
+
+    /// Create the task of the spec once the rate limit allows it.
+    async fn submit_spec(&self, spec: TaskSpec) -> Result<Task, FlameError> {
+        self.wait_submit(1).await;
+        self.send_spec(spec).await
+    }
+
+    /// Create the task of the spec, through the middlewares of the session.
+    pub(crate) async fn send_spec(&self, mut spec: TaskSpec) -> Result<Task, FlameError> {
+        let mut client = self
+            .client
+            .clone()
+            .ok_or(FlameError::Internal("no flame client".to_string()))?;
+
+        self.middlewares.submit(&mut spec)?;
+        let start = Instant::now();
+        let res = client
+            .create_task(CreateTaskRequest { task: Some(spec) })
+            .await
+            .map_err(FlameError::from)
+            .and_then(|task| Task::try_from(&task.into_inner()));
+        self.middlewares
+            .complete(middleware::Operation::Submit, &self.id, start, &res);
+
+        res
+    }
+
     pub async fn create_task(&self, input: Option<TaskInput>) -> Result<Task, FlameError> {
-        trace_fn!("Session::create_task");
+        self.create_tagged_task(input, vec![]).await
+    }
+
+    /// Create a task with the tags, e.g. `huge-memory`; it's only launched on
+    /// the executors advertising all of the tags.
+    pub async fn create_tagged_task(
+        &self,
+        input: Option<TaskInput>,
+        tags: Vec<String>,
+    ) -> Result<Task, FlameError> {
+        trace_fn!("Session::create_tagged_task");
+        self.submit_spec(self.task_spec(input, tags, None)).await
+    }
+
+    /// Create a task with the deadline, which is observed by the executor and
+    /// the service; the task is failed if it's not completed in time.
+    pub async fn create_task_with_deadline(
+        &self,
+        input: Option<TaskInput>,
+        deadline: DateTime<Utc>,
+    ) -> Result<Task, FlameError> {
+        trace_fn!("Session::create_task_with_deadline");
+        self.submit_spec(self.task_spec(input, vec![], Some(deadline)))
+            .await
+    }
+
+    /// Create a task in the group of the session, e.g. a phase of a pipeline;
+    /// the group is waited for by `wait_group`, and the service enters the
+    /// group before the task.
+    pub async fn create_grouped_task(
+        &self,
+        group: &str,
+        input: Option<TaskInput>,
+    ) -> Result<Task, FlameError> {
+        trace_fn!("Session::create_grouped_task");
+        let mut task_spec = self.task_spec(input, vec![], None);
+        task_spec.group = Some(group.to_string());
+        self.submit_spec(task_spec).await
+    }
+
+    /// Create a task which is not launched before the time, e.g. a delayed
+    /// or periodic work; it's pending meanwhile, and no executors are
+    /// allocated for it.
+    pub async fn submit_at(
+        &self,
+        input: Option<TaskInput>,
+        run_at: DateTime<Utc>,
+    ) -> Result<Task, FlameError> {
+        trace_fn!("Session::submit_at");
+        let mut task_spec = self.task_spec(input, vec![], None);
+        task_spec.run_at = Some(run_at.timestamp_millis());
+        self.submit_spec(task_spec).await
+    }
+
+    /// Create a task which is not launched before the delay by the clock of
+    /// the client, see `submit_at`.
+    pub async fn submit_after(
+        &self,
+        input: Option<TaskInput>,
+        delay: std::time::Duration,
+    ) -> Result<Task, FlameError> {
+        let delay = Duration::from_std(delay)
+            .map_err(|e| FlameError::InvalidConfig(format!("invalid delay: {e}")))?;
+        self.submit_at(input, Utc::now() + delay).await
+    }
+
+    /// Create a task with the labels, e.g. `trace-id=abc`, which are returned
+    /// with the task and filter the tasks by `tasks`; unlike the tags, they do
+    /// not affect where the task is launched.
+    pub async fn create_labeled_task(
+        &self,
+        input: Option<TaskInput>,
+        labels: Vec<String>,
+    ) -> Result<Task, FlameError> {
+        trace_fn!("Session::create_labeled_task");
+        let mut task_spec = self.task_spec(input, vec![], None);
+        task_spec.labels = labels;
+        self.submit_spec(task_spec).await
+    }
+
+    /// Create a task for the method of the service, e.g. `resize`, which is
+    /// dispatched to its handler by the `Router` of the service.
+    pub async fn create_method_task(
+        &self,
+        method: &str,
+        input: Option<TaskInput>,
+    ) -> Result<Task, FlameError> {
+        trace_fn!("Session::create_method_task");
+        let mut task_spec = self.task_spec(input, vec![], None);
+        task_spec.labels = vec![format!("{LABEL_METHOD}={method}")];
+        self.submit_spec(task_spec).await
+    }
+
+    /// Wait for all the tasks of the group to be completed, instead of the
+    /// whole session; the summary of the group is returned in its current
+    /// state if the timeout is reached. The throughput and ETA are not set.
+    pub async fn wait_group(
+        &self,
+        group: &str,
+        timeout: Option<std::time::Duration>,
+    ) -> Result<SessionStats, FlameError> {
+        trace_fn!("Session::wait_group");
         let mut client = self
             .client
             .clone()
             .ok_or(FlameError::Internal("no flame client".to_string()))?;
 
-        let create_task_req = CreateTaskRequest {
-            task: Some(TaskSpec {
+        let stats = client
+            .wait_for_group(WaitForGroupRequest {
                 session_id: self.id.clone(),
-                input: input.map(|input| input.to_vec()),
-                output: None,
-            }),
+                group: group.to_string(),
+                timeout: timeout.map(|t| t.as_millis() as u64),
+            })
+            .await?
+            .into_inner();
+
+        Ok(SessionStats {
+            pending: stats.pending,
+            running: stats.running,
+            succeed: stats.succeed,
+            failed: stats.failed,
+            cancelled: stats.cancelled,
+            throughput: stats.throughput,
+            eta: stats.eta.map(std::time::Duration::from_secs),
+            closed: stats.closed,
+        })
+    }
+
+    /// Create the tasks of the inputs in batches, with several batches in
+    /// flight; the result of each task is in the order of the inputs, so the
+    /// rejected tasks are handled one by one.
+    pub async fn submit_batch(
+        &self,
+        inputs: Vec<Option<TaskInput>>,
+    ) -> Result<Vec<Result<Task, FlameError>>, FlameError> {
+        trace_fn!("Session::submit_batch");
+        let client = self
+            .client
+            .clone()
+            .ok_or(FlameError::Internal("no flame client".to_string()))?;
+
+        // The batch is rejected by the middlewares before any task is sent.
+        let mut specs = Vec::with_capacity(inputs.len());
+        for input in inputs {
+            let mut spec = self.task_spec(input, vec![], None);
+            self.middlewares.submit(&mut spec)?;
+            specs.push(spec);
+        }
+
+        let mut batches = vec![];
+        let mut specs = specs.into_iter().peekable();
+        while specs.peek().is_some() {
+            batches.push(
+                specs
+                    .by_ref()
+                    .take(DEFAULT_SUBMIT_BATCH_SIZE)
+                    .collect::<Vec<_>>(),
+            );
+        }
+
+        let requests = futures::stream::iter(batches).map(|batch| {
+            let mut client = client.clone();
+            let size = batch.len();
+            let req = CreateTasksRequest {
+                session_id: self.id.clone(),
+                tasks: batch,
+            };
+
+            async move {
+                self.wait_submit(size).await;
+                let start = Instant::now();
+                let results: Vec<Result<Task, FlameError>> = match client.create_tasks(req).await {
+                    Ok(resp) => resp
+                        .into_inner()
+                        .results
+                        .iter()
+                        .map(|result| match (&result.task, &result.error) {
+                            (Some(task), _) => Task::try_from(task),
+                            (None, error) => {
+                                Err(FlameError::InvalidState(error.clone().unwrap_or_default()))
+                            }
+                        })
+                        .collect(),
+                    // All the tasks of the batch are failed by the error.
+                    Err(e) => vec![Err(FlameError::from(e)); size],
+                };
+                for res in &results {
+                    self.middlewares
+                        .complete(middleware::Operation::Submit, &self.id, start, res);
+                }
+
+                results
+            }
+        });
+        let results: Vec<Vec<Result<Task, FlameError>>> =
+            futures::StreamExt::buffered(requests, DEFAULT_SUBMIT_BATCH_CONCURRENCY)
+                .collect()
+                .await;
+
+        Ok(results.into_iter().flatten().collect())
+    }
+
+    /// Create a task and wait for its completion by the server in one call;
+    /// the task is returned in its current state if the timeout is reached.
+    pub async fn submit_and_wait(
+        &self,
+        input: Option<TaskInput>,
+        timeout: Option<std::time::Duration>,
+    ) -> Result<Task, FlameError> {
+        trace_fn!("Session::submit_and_wait");
+        // The request held until the task is completed may be cut by the
+        // proxies of HTTP/1.1, so wait by the long-poll instead.
+        if self.long_poll {
+            return self.create_and_wait(input, timeout).await;
+        }
+
+        let mut client = self
+            .client
+            .clone()
+            .ok_or(FlameError::Internal("no flame client".to_string()))?;
+
+        let mut task_spec = self.task_spec(input, vec![], None);
+        self.middlewares.submit(&mut task_spec)?;
+        let run_task_req = RunTaskRequest {
+            task: Some(task_spec),
+            timeout: timeout.map(|t| t.as_millis() as u64),
         };
 
-        let task = client.create_task(create_task_req).await?;
+        self.wait_submit(1).await;
+        let start = Instant::now();
+        let res = client
+            .run_task(run_task_req)
+            .await
+            .map_err(FlameError::from)
+            .and_then(|task| Task::try_from(&task.into_inner()));
+        self.middlewares
+            .complete(middleware::Operation::Submit, &self.id, start, &res);
 
-        let inner = task.into_inner();
-        Task::try_from(&inner)
+        res
     }
 
     pub async fn get_task(&self, id: &TaskID) -> Result<Task, FlameError> {
@@ -510,6 +1287,7 @@ impl Session {
         let task_stream = client
             .list_task(Request::new(ListTaskRequest {
                 session_id: self.id.to_string(),
+                ..Default::default()
             }))
             .await?;
 
@@ -525,6 +1303,91 @@ impl Session {
         Ok(task_list)
     }
 
+    /// Get a page of the completed tasks with their outputs in the order;
+    /// `page_token` is the `next_page_token` of the previous page.
+    pub async fn get_outputs(
+        &self,
+        order: OutputOrder,
+        page_token: Option<String>,
+        page_size: Option<u32>,
+    ) -> Result<OutputPage, FlameError> {
+        trace_fn!("Session::get_outputs");
+        let mut client = self
+            .client
+            .clone()
+            .ok_or(FlameError::Internal("no flame client".to_string()))?;
+
+        let outputs = client
+            .get_session_outputs(GetSessionOutputsRequest {
+                session_id: self.id.clone(),
+                order_by: rpc::OutputOrder::from(order) as i32,
+                page_token,
+                page_size,
+            })
+            .await?
+            .into_inner();
+
+        Ok(OutputPage {
+            tasks: outputs
+                .tasks
+                .iter()
+                .map(Task::try_from)
+                .collect::<Result<_, _>>()?,
+            next_page_token: outputs.next_page_token,
+        })
+    }
+
+    /// Get the completed tasks with their outputs in the order, page by page;
+    /// the outputs stop before the first task not completed in the order, so
+    /// all the outputs are collected once the tasks are completed.
+    pub async fn collect_outputs(&self, order: OutputOrder) -> Result<Vec<Task>, FlameError> {
+        trace_fn!("Session::collect_outputs");
+        let mut tasks = vec![];
+        let mut page_token = None;
+        loop {
+            let page = self
+                .get_outputs(order, page_token, Some(DEFAULT_OUTPUT_PAGE_SIZE))
+                .await?;
+            // The empty page stops at a task to be completed.
+            let done = page.tasks.is_empty();
+            tasks.extend(page.tasks);
+
+            match page.next_page_token {
+                Some(token) if !done => page_token = Some(token),
+                _ => return Ok(tasks),
+            }
+        }
+    }
+
+    /// Get the summary of the tasks by state, with the throughput and ETA
+    /// over the last `window` minutes; 5 minutes if not set.
+    pub async fn stats(&self, window: Option<u32>) -> Result<SessionStats, FlameError> {
+        trace_fn!("Session::stats");
+        let mut client = self
+            .client
+            .clone()
+            .ok_or(FlameError::Internal("no flame client".to_string()))?;
+
+        let stats = client
+            .get_session_stats(GetSessionStatsRequest {
+                session_id: self.id.clone(),
+                window,
+            })
+            .await?
+            .into_inner();
+
+        Ok(SessionStats {
+            pending: stats.pending,
+            running: stats.running,
+            succeed: stats.succeed,
+            failed: stats.failed,
+            cancelled: stats.cancelled,
+            throughput: stats.throughput,
+            eta: stats.eta.map(std::time::Duration::from_secs),
+            closed: stats.closed,
+        })
+    }
+
     pub async fn run_task(
         &self,
         input: Option<TaskInput>,
@@ -548,31 +1411,62 @@ impl Session {
             .clone()
             .ok_or(FlameError::Internal("no flame client".to_string()))?;
 
+        self.middlewares.watch(&session_id, &task_id)?;
+        let start = Instant::now();
         let watch_task_req = WatchTaskRequest {
-            session_id,
+            session_id: session_id.clone(),
             task_id,
         };
-        let mut task_stream = client.watch_task(watch_task_req).await?.into_inner();
-        while let Some(task) = task_stream.next().await {
-            match task {
-                Ok(t) => {
-                    let mut informer = lock_ptr!(informer_ptr)?;
-                    match Task::try_from(&t) {
-                        Ok(parsed) => informer.on_update(parsed),
-                        Err(err) => informer.on_error(err),
+        let res: Result<(), FlameError> = async {
+            let mut task_stream = client.watch_task(watch_task_req).await?.into_inner();
+            while let Some(task) = task_stream.next().await {
+                match task {
+                    Ok(t) => {
+                        let mut informer = lock_ptr!(informer_ptr)?;
+                        match Task::try_from(&t) {
+                            Ok(parsed) => informer.on_update(parsed),
+                            Err(err) => informer.on_error(err),
+                        }
+                    }
+                    Err(e) => {
+                        let mut informer = lock_ptr!(informer_ptr)?;
+                        informer.on_error(FlameError::from(e.clone()));
                     }
-                }
-                Err(e) => {
-                    let mut informer = lock_ptr!(informer_ptr)?;
-                    informer.on_error(FlameError::from(e.clone()));
                 }
             }
+            Ok(())
         }
-        Ok(())
+        .await;
+        self.middlewares
+            .complete(middleware::Operation::Watch, &session_id, start, &res);
+
+        res
     }
 
+    /// Drain and close the session: the new tasks are rejected, and the
+    /// tasks are waited for up to 60 seconds before they're cancelled; see
+    /// `close_with` for another timeout.
     pub async fn close(&self) -> Result<(), FlameError> {
-        trace_fn!("Session::close");
+        self.close_with(DEFAULT_DRAIN_TIMEOUT).await
+    }
+
+    /// Drain and close the session, waiting for its tasks up to the timeout;
+    /// the timeout of the requests in `ConnectOptions` should be longer.
+    pub async fn close_with(&self, drain_timeout: std::time::Duration) -> Result<(), FlameError> {
+        trace_fn!("Session::close_with");
+        self.close_session(Some(drain_timeout)).await
+    }
+
+    /// Close the session immediately, cancelling its pending tasks.
+    pub async fn close_now(&self) -> Result<(), FlameError> {
+        trace_fn!("Session::close_now");
+        self.close_session(None).await
+    }
+
+    async fn close_session(
+        &self,
+        drain_timeout: Option<std::time::Duration>,
+    ) -> Result<(), FlameError> {
         let mut client = self
             .client
             .clone()
@@ -580,9 +1474,19 @@ impl Session {
 
         let close_ssn_req = CloseSessionRequest {
             session_id: self.id.clone(),
+            resource_version: None,
+            drain_timeout: drain_timeout.map(|t| t.as_millis() as u64),
         };
 
-        client.close_session(close_ssn_req).await?;
+        self.middlewares.close(&self.id)?;
+        let start = Instant::now();
+        let res = client
+            .close_session(close_ssn_req)
+            .await
+            .map_err(FlameError::from);
+        self.middlewares
+            .complete(middleware::Operation::Close, &self.id, start, &res);
+        res?;
 
         Ok(())
     }
@@ -611,17 +1515,77 @@ impl TryFrom<&rpc::Task> for Task {
             .map(|ev| Event::try_from(&ev))
             .collect::<Result<Vec<Event>, FlameError>>()?;
 
+        checksum::verify(
+            &format!("input of task <{}>", metadata.id),
+            spec.input.as_deref(),
+            spec.input_checksum.as_deref(),
+        )?;
+        // The output kept on the host of the executor is not inlined, so it's
+        // read from the local file as a fast path and verified by its
+        // checksum; the reference is kept for the caller if the file can't be
+        // read, e.g. the client is on another host, and the output is streamed
+        // from the session manager by `Session::output_reader` then.
+        let output_name = format!("output of task <{}>", metadata.id);
+        let output_checksum = spec.output_checksum.as_deref();
+        let (output, output_ref) = match (spec.output, spec.output_ref) {
+            (Some(output), _) => {
+                checksum::verify(&output_name, Some(&output[..]), output_checksum)?;
+                (Some(TaskOutput::from(output)), None)
+            }
+            (None, Some(output_ref)) => {
+                match read_local_output(&metadata.id, &output_ref).and_then(|output| {
+                    checksum::verify(&output_name, Some(&output[..]), output_checksum)?;
+                    Ok(output)
+                }) {
+                    Ok(output) => (Some(output), None),
+                    Err(e) => {
+                        tracing::warn!("{e}");
+                        (None, Some(output_ref))
+                    }
+                }
+            }
+            (None, None) => (None, None),
+        };
+
         Ok(Task {
             id: metadata.id,
             ssn_id: spec.session_id.clone(),
             input: spec.input.map(TaskInput::from),
-            output: spec.output.map(TaskOutput::from),
+            output,
+            output_ref,
             state: TaskState::try_from(status.state).unwrap_or(TaskState::default()),
+            labels: spec.labels,
             events,
         })
     }
 }
 
+/// Read the output kept on the host of the executor by its reference, i.e.
+/// `file://<host>/<path>`; it's only readable on the same host.
+fn read_local_output(task_id: &str, output_ref: &str) -> Result<TaskOutput, FlameError> {
+    let (host, path) = output_ref
+        .strip_prefix("file://")
+        .and_then(|r| r.find('/').map(|i| r.split_at(i)))
+        .ok_or_else(|| {
+            FlameError::Internal(format!(
+                "invalid output reference <{output_ref}> of task <{task_id}>"
+            ))
+        })?;
+
+    let hostname = gethostname::gethostname().to_string_lossy().into_owned();
+    if !host.is_empty() && host != hostname {
+        return Err(FlameError::InvalidState(format!(
+            "the output of task <{task_id}> is kept on host <{host}>, not readable on <{hostname}>"
+        )));
+    }
+
+    std::fs::read(path).map(TaskOutput::from).map_err(|e| {
+        FlameError::Internal(format!(
+            "failed to read the output of task <{task_id}> from <{path}>: {e}"
+        ))
+    })
+}
+
 impl TryFrom<&rpc::Session> for Session {
     type Error = FlameError;
     fn try_from(ssn: &rpc::Session) -> Result<Self, FlameError> {
@@ -638,7 +1602,10 @@ impl TryFrom<&rpc::Session> for Session {
             .clone()
             .ok_or_else(|| FlameError::Internal("missing spec in response".to_string()))?;
 
-        let naivedatetime_utc = DateTime::from_timestamp_millis(status.creation_time * 1000)
+        let naivedatetime_utc = status
+            .creation_time
+            .checked_mul(1000)
+            .and_then(DateTime::from_timestamp_millis)
             .ok_or_else(|| FlameError::Internal("invalid timestamp".to_string()))?;
         let creation_time = Utc.from_utc_datetime(&naivedatetime_utc.naive_utc());
 
@@ -651,10 +1618,18 @@ impl TryFrom<&rpc::Session> for Session {
 
         Ok(Session {
             client: None,
+            result_cache: None,
+            output_cache: None,
+            middlewares: Default::default(),
+            submit_limit: None,
+            long_poll: false,
             id: metadata.id,
+            resource_version: metadata.resource_version.unwrap_or_default(),
             slots: spec.slots,
             application: spec.application,
             creation_time,
+            content_type: spec.content_type,
+            labels: spec.labels,
             state: SessionState::try_from(status.state).unwrap_or(SessionState::default()),
             pending: status.pending,
             running: status.running,
@@ -703,12 +1678,16 @@ impl TryFrom<&rpc::Application> for Application {
             .status
             .ok_or_else(|| FlameError::Internal("missing status in application".to_string()))?;
 
-        let naivedatetime_utc = DateTime::from_timestamp_millis(status.creation_time * 1000)
+        let naivedatetime_utc = status
+            .creation_time
+            .checked_mul(1000)
+            .and_then(DateTime::from_timestamp_millis)
             .ok_or_else(|| FlameError::Internal("invalid timestamp".to_string()))?;
         let creation_time = Utc.from_utc_datetime(&naivedatetime_utc.naive_utc());
 
         Ok(Self {
             name: metadata.name,
+            resource_version: metadata.resource_version.unwrap_or_default(),
             attributes: ApplicationAttributes::from(spec),
             state: ApplicationState::from(status.state()),
             creation_time,
@@ -772,6 +1751,7 @@ impl From<ApplicationSchema> for rpc::ApplicationSchema {
             input: schema.input,
             output: schema.output,
             common_data: schema.common_data,
+            content_types: schema.content_types,
         }
     }
 }
@@ -782,6 +1762,7 @@ impl From<rpc::ApplicationSchema> for ApplicationSchema {
             input: schema.input,
             output: schema.output,
             common_data: schema.common_data,
+            content_types: schema.content_types,
         }
     }
 }
@@ -839,6 +1820,7 @@ impl From<&rpc::Node> for Node {
             memory: capacity.memory,
             arch: info.arch,
             os: info.os,
+            labels: info.labels,
         }
     }
 }
@@ -0,0 +1,360 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//! The options of connecting to the session manager, so the consumers do not
+//! build the channels by themselves, e.g.
+//!
+//! ```ignore
+//! let conn = ConnectOptions::new("https://flame:8080")
+//!     .with_tls(FlameClientTls { ca_file: Some("/etc/flame/ca.crt".to_string()) })
+//!     .with_retry(RetryPolicy::default())
+//!     .with_keepalive(Duration::from_secs(30))
+//!     .with_timeout(Duration::from_secs(60))
+//!     .with_failover(&["https://flame-2:8080"])
+//!     .connect()
+//!     .await?;
+//! ```
+//!
+//! The options of the other components follow the same pattern: the
+//! defaults by `new` or `Default`, the `with_*` setters, and `validate`
+//! before they're used, e.g. `SessionTemplate`, `MapOptions`, `GroupOptions`
+//! and `ConnectorConfig`.
+
+use std::time::Duration;
+
+use tonic::transport::{Channel, Endpoint};
+
+use crate::apis::{FlameClientTls, FlameError};
+use crate::client::transport::TransportMode;
+use crate::client::Connection;
+
+/// The retries of connecting by default.
+const DEFAULT_CONNECT_RETRIES: u32 = 3;
+/// The backoff of the first retry, doubled by each retry.
+const DEFAULT_CONNECT_BACKOFF: Duration = Duration::from_millis(100);
+const MAX_CONNECT_BACKOFF: Duration = Duration::from_secs(5);
+/// The interval of checking the health of the session manager in use if
+/// there are session managers to fail over to.
+const DEFAULT_HEALTH_INTERVAL: Duration = Duration::from_secs(5);
+
+/// The retries of connecting to the session manager, e.g. it's restarting;
+/// the requests of the connection are not retried.
+#[derive(Clone, Debug)]
+pub struct RetryPolicy {
+    pub max_retries: u32,
+    pub initial_backoff: Duration,
+    pub max_backoff: Duration,
+}
+
+impl Default for RetryPolicy {
+    fn default() -> Self {
+        Self {
+            max_retries: DEFAULT_CONNECT_RETRIES,
+            initial_backoff: DEFAULT_CONNECT_BACKOFF,
+            max_backoff: MAX_CONNECT_BACKOFF,
+        }
+    }
+}
+
+impl RetryPolicy {
+    /// Fail on the first error of connecting.
+    pub fn none() -> Self {
+        Self {
+            max_retries: 0,
+            ..Default::default()
+        }
+    }
+
+    pub fn with_max_retries(mut self, max_retries: u32) -> Self {
+        self.max_retries = max_retries;
+        self
+    }
+
+    pub fn with_backoff(mut self, initial_backoff: Duration, max_backoff: Duration) -> Self {
+        self.initial_backoff = initial_backoff;
+        self.max_backoff = max_backoff;
+        self
+    }
+
+    pub fn validate(&self) -> Result<(), FlameError> {
+        if self.initial_backoff > self.max_backoff {
+            return Err(FlameError::InvalidConfig(format!(
+                "the initial backoff <{:?}> is longer than the max backoff <{:?}>",
+                self.initial_backoff, self.max_backoff
+            )));
+        }
+
+        Ok(())
+    }
+
+    /// The backoff before the retry, starting from zero.
+    pub fn backoff(&self, retries: u32) -> Duration {
+        self.initial_backoff
+            .saturating_mul(1 << retries.min(16))
+            .min(self.max_backoff)
+    }
+}
+
+/// The options of the connection to the session manager; `connect` and
+/// `connect_with_tls` are the shortcuts of the default options.
+#[derive(Clone, Debug)]
+pub struct ConnectOptions {
+    /// The endpoint of the session manager, or the address to discover the
+    /// session managers; see `connect_with_tls`.
+    pub endpoint: String,
+    pub tls: Option<FlameClientTls>,
+    pub retry: RetryPolicy,
+    /// The interval of the HTTP/2 and TCP keepalive.
+    pub keepalive: Option<Duration>,
+    pub connect_timeout: Option<Duration>,
+    /// The timeout of each request, except the streams, e.g. `watch_task`.
+    pub timeout: Option<Duration>,
+    /// Whether to follow the standby to its leader.
+    pub redirect: bool,
+    /// The session managers to fail over to after `endpoint`, in the order
+    /// of preference; see `failover`.
+    pub failover: Vec<String>,
+    /// The interval of checking the health of the session manager in use,
+    /// and the timeout of each check; only for `failover`.
+    pub health_interval: Duration,
+    /// HTTP/2 or the long-poll over HTTP/1.1; see `transport`.
+    pub transport: TransportMode,
+}
+
+impl ConnectOptions {
+    pub fn new(endpoint: &str) -> Self {
+        Self {
+            endpoint: endpoint.to_string(),
+            tls: None,
+            retry: RetryPolicy::none(),
+            keepalive: None,
+            connect_timeout: None,
+            timeout: None,
+            redirect: true,
+            failover: vec![],
+            health_interval: DEFAULT_HEALTH_INTERVAL,
+            transport: TransportMode::default(),
+        }
+    }
+
+    pub fn with_endpoint(mut self, endpoint: &str) -> Self {
+        self.endpoint = endpoint.to_string();
+        self
+    }
+
+    pub fn with_tls(mut self, tls: FlameClientTls) -> Self {
+        self.tls = Some(tls);
+        self
+    }
+
+    pub fn with_retry(mut self, retry: RetryPolicy) -> Self {
+        self.retry = retry;
+        self
+    }
+
+    pub fn with_keepalive(mut self, keepalive: Duration) -> Self {
+        self.keepalive = Some(keepalive);
+        self
+    }
+
+    pub fn with_connect_timeout(mut self, connect_timeout: Duration) -> Self {
+        self.connect_timeout = Some(connect_timeout);
+        self
+    }
+
+    pub fn with_timeout(mut self, timeout: Duration) -> Self {
+        self.timeout = Some(timeout);
+        self
+    }
+
+    /// Connect to the standby itself, e.g. to promote it.
+    pub fn without_redirect(mut self) -> Self {
+        self.redirect = false;
+        self
+    }
+
+    pub fn with_failover(mut self, endpoints: &[&str]) -> Self {
+        self.failover = endpoints.iter().map(|e| e.to_string()).collect();
+        self
+    }
+
+    pub fn with_health_interval(mut self, health_interval: Duration) -> Self {
+        self.health_interval = health_interval;
+        self
+    }
+
+    pub fn with_transport(mut self, transport: TransportMode) -> Self {
+        self.transport = transport;
+        self
+    }
+
+    pub fn validate(&self) -> Result<(), FlameError> {
+        if self.endpoint.trim().is_empty() {
+            return Err(FlameError::InvalidConfig(
+                "the endpoint of the session manager is empty".to_string(),
+            ));
+        }
+        if let Some(endpoint) = self.failover.iter().find(|e| e.trim().is_empty()) {
+            return Err(FlameError::InvalidConfig(format!(
+                "invalid failover endpoint <{endpoint}>"
+            )));
+        }
+        if !self.failover.is_empty() && self.health_interval.is_zero() {
+            return Err(FlameError::InvalidConfig(
+                "the health interval of the failover must be positive".to_string(),
+            ));
+        }
+        for (name, duration) in [
+            ("keepalive", self.keepalive),
+            ("connect timeout", self.connect_timeout),
+            ("timeout", self.timeout),
+        ] {
+            if duration.is_some_and(|d| d.is_zero()) {
+                return Err(FlameError::InvalidConfig(format!(
+                    "the {name} must be positive"
+                )));
+            }
+        }
+
+        self.retry.validate()
+    }
+
+    pub async fn connect(&self) -> Result<Connection, FlameError> {
+        self.validate()?;
+        super::connect_to(self).await
+    }
+
+    /// Apply the options to the endpoint of a session manager.
+    pub(crate) fn apply(&self, mut endpoint: Endpoint) -> Endpoint {
+        if let Some(keepalive) = self.keepalive {
+            endpoint = endpoint
+                .tcp_keepalive(Some(keepalive))
+                .http2_keep_alive_interval(keepalive)
+                .keep_alive_while_idle(true);
+        }
+        if let Some(connect_timeout) = self.connect_timeout {
+            endpoint = endpoint.connect_timeout(connect_timeout);
+        }
+        if let Some(timeout) = self.timeout {
+            endpoint = endpoint.timeout(timeout);
+        }
+
+        endpoint
+    }
+
+    /// Connect to the endpoint by the retry policy.
+    pub(crate) async fn connect_endpoint(
+        &self,
+        endpoint: &Endpoint,
+        addr: &str,
+    ) -> Result<Channel, FlameError> {
+        let mut retries = 0;
+        loop {
+            match endpoint.connect().await {
+                Ok(channel) => return Ok(channel),
+                Err(e) if retries < self.retry.max_retries => {
+                    let backoff = self.retry.backoff(retries);
+                    retries += 1;
+                    tracing::debug!(
+                        "Retry to connect to <{addr}> in {backoff:?} ({retries}/{}): {e}",
+                        self.retry.max_retries
+                    );
+                    tokio::time::sleep(backoff).await;
+                }
+                Err(e) => {
+                    return Err(FlameError::InvalidConfig(format!(
+                        "failed to connect to <{}>: {}",
+                        addr, e
+                    )))
+                }
+            }
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_retry_backoff() {
+        let retry = RetryPolicy::default();
+        assert_eq!(retry.backoff(0), Duration::from_millis(100));
+        assert_eq!(retry.backoff(3), Duration::from_millis(800));
+        assert_eq!(retry.backoff(10), MAX_CONNECT_BACKOFF);
+        assert_eq!(RetryPolicy::none().max_retries, 0);
+    }
+
+    #[test]
+    fn test_connect_options() {
+        let options = ConnectOptions::new("http://flame:8080")
+            .with_endpoint("http://flame-1:8080")
+            .with_keepalive(Duration::from_secs(30))
+            .without_redirect();
+        assert_eq!(options.endpoint, "http://flame-1:8080");
+        assert_eq!(options.keepalive, Some(Duration::from_secs(30)));
+        assert!(options.tls.is_none());
+        assert!(!options.redirect);
+        assert_eq!(options.retry.max_retries, 0);
+        assert!(options.failover.is_empty());
+        assert_eq!(options.transport, TransportMode::Auto);
+
+        let options = options
+            .with_failover(&["http://flame-2:8080", "http://flame-3:8080"])
+            .with_health_interval(Duration::from_secs(1));
+        assert_eq!(
+            options.failover,
+            vec!["http://flame-2:8080", "http://flame-3:8080"]
+        );
+        assert_eq!(options.health_interval, Duration::from_secs(1));
+
+        let options = options.with_transport(TransportMode::LongPoll);
+        assert_eq!(options.transport, TransportMode::LongPoll);
+        assert!(options.validate().is_ok());
+    }
+
+    #[test]
+    fn test_validate_connect_options() {
+        assert!(ConnectOptions::new("").validate().is_err());
+        assert!(ConnectOptions::new("http://flame:8080")
+            .with_timeout(Duration::ZERO)
+            .validate()
+            .is_err());
+        assert!(ConnectOptions::new("http://flame:8080")
+            .with_failover(&["http://flame-2:8080"])
+            .with_health_interval(Duration::ZERO)
+            .validate()
+            .is_err());
+
+        let retry = RetryPolicy::default()
+            .with_max_retries(5)
+            .with_backoff(Duration::from_secs(10), Duration::from_secs(1));
+        assert_eq!(retry.max_retries, 5);
+        assert!(retry.validate().is_err());
+        assert!(ConnectOptions::new("http://flame:8080")
+            .with_retry(retry)
+            .validate()
+            .is_err());
+    }
+
+    #[tokio::test]
+    async fn test_connect_with_retries() {
+        let options = ConnectOptions::new("http://127.0.0.1:1").with_retry(RetryPolicy {
+            max_retries: 2,
+            initial_backoff: Duration::from_millis(1),
+            max_backoff: Duration::from_millis(1),
+        });
+        let res = options.connect().await;
+        assert!(matches!(res, Err(FlameError::InvalidConfig(_))));
+    }
+}
@@ -0,0 +1,296 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//! A pool of warm sessions of an application, so the services fanning out
+//! bursts of tasks do not create a session per request, e.g.
+//!
+//! ```ignore
+//! let pool = conn.create_session_pool(SessionTemplate::new("pi"), 4).await?;
+//!
+//! let ssn = pool.acquire().await?;
+//! let task = ssn.create_task(Some(input)).await?;
+//! ssn.release();
+//!
+//! // Wait for the acquired sessions and close all of them.
+//! pool.close().await?;
+//! ```
+
+use std::ops::Deref;
+use std::sync::atomic::{AtomicBool, AtomicU64, Ordering};
+use std::sync::Arc;
+
+use chrono::Utc;
+use futures::future::try_join_all;
+use stdng::{lock_ptr, new_ptr, trace_fn, MutexPtr};
+use tokio::sync::{OwnedSemaphorePermit, Semaphore};
+use tonic::{Code, Response, Status};
+
+use crate::apis::flame::v1 as rpc;
+use crate::apis::FlameError;
+use crate::client::rpc::GetSessionRequest;
+use crate::client::template::SessionTemplate;
+use crate::client::{Connection, FlameClient, Session};
+
+/// The sessions kept open by the pool; they are handed out by `acquire` and
+/// returned when the `PooledSession` is released or dropped.
+#[derive(Clone)]
+pub struct SessionPool {
+    inner: Arc<PoolInner>,
+}
+
+struct PoolInner {
+    conn: Connection,
+    template: SessionTemplate,
+    /// The prefix of the ids of the sessions, unique to the pool.
+    prefix: String,
+    next_id: AtomicU64,
+    size: u32,
+    idle: MutexPtr<Vec<Session>>,
+    permits: Arc<Semaphore>,
+    closed: AtomicBool,
+}
+
+/// A session acquired from the pool.
+pub struct PooledSession {
+    session: Option<Session>,
+    pool: Arc<PoolInner>,
+    _permit: OwnedSemaphorePermit,
+}
+
+impl Connection {
+    /// Create a pool of `size` sessions by the template; all of the sessions
+    /// are created before it returns.
+    pub async fn create_session_pool(
+        &self,
+        template: SessionTemplate,
+        size: u32,
+    ) -> Result<SessionPool, FlameError> {
+        trace_fn!("Connection::create_session_pool");
+        if size == 0 {
+            return Err(FlameError::InvalidConfig(
+                "the size of the session pool must be positive".to_string(),
+            ));
+        }
+        template.validate()?;
+
+        let inner = Arc::new(PoolInner {
+            conn: self.clone(),
+            prefix: format!(
+                "{}-pool-{}",
+                template.application,
+                Utc::now().timestamp_millis()
+            ),
+            template,
+            next_id: AtomicU64::new(0),
+            size,
+            idle: new_ptr(vec![]),
+            permits: Arc::new(Semaphore::new(size as usize)),
+            closed: AtomicBool::new(false),
+        });
+
+        let sessions = try_join_all((0..size).map(|_| inner.create_session())).await?;
+        lock_ptr!(inner.idle)?.extend(sessions);
+
+        Ok(SessionPool { inner })
+    }
+}
+
+impl SessionPool {
+    pub fn size(&self) -> u32 {
+        self.inner.size
+    }
+
+    /// Acquire a session, waiting for one to be released if all of them are
+    /// acquired; the session closed by others, e.g. `flmctl close`, is
+    /// replaced by a new one.
+    pub async fn acquire(&self) -> Result<PooledSession, FlameError> {
+        trace_fn!("SessionPool::acquire");
+        let closed = || FlameError::InvalidState("the session pool is closed".to_string());
+        if self.inner.closed.load(Ordering::Acquire) {
+            return Err(closed());
+        }
+
+        let permit = self
+            .inner
+            .permits
+            .clone()
+            .acquire_owned()
+            .await
+            .map_err(|_| closed())?;
+        // The pool was closed while waiting for the permit.
+        if self.inner.closed.load(Ordering::Acquire) {
+            return Err(closed());
+        }
+
+        let idle = lock_ptr!(self.inner.idle)?.pop();
+        let session = match idle {
+            Some(ssn) => {
+                let mut client = FlameClient::new(self.inner.conn.channel.clone());
+                let res = client
+                    .get_session(GetSessionRequest {
+                        session_id: ssn.id.clone(),
+                    })
+                    .await;
+                match is_alive(&res) {
+                    Ok(true) => ssn,
+                    Ok(false) => {
+                        tracing::debug!("Session <{}> of the pool is dead, recreate it.", ssn.id);
+                        self.inner.create_session().await?
+                    }
+                    Err(e) => {
+                        // Keep the session for the next acquire, e.g. the
+                        // session manager is restarting.
+                        lock_ptr!(self.inner.idle)?.push(ssn);
+                        return Err(e);
+                    }
+                }
+            }
+            // The dead session was discarded.
+            None => self.inner.create_session().await?,
+        };
+
+        Ok(PooledSession {
+            session: Some(session),
+            pool: self.inner.clone(),
+            _permit: permit,
+        })
+    }
+
+    /// Close the pool: the new acquires are rejected, and all of the sessions
+    /// are closed after the acquired ones are released.
+    pub async fn close(&self) -> Result<(), FlameError> {
+        trace_fn!("SessionPool::close");
+        if self.inner.closed.swap(true, Ordering::AcqRel) {
+            return Ok(());
+        }
+
+        // Drain the acquired sessions.
+        let _permits = self
+            .inner
+            .permits
+            .acquire_many(self.inner.size)
+            .await
+            .map_err(|_| FlameError::Internal("the session pool was drained".to_string()))?;
+        self.inner.permits.close();
+
+        let sessions = std::mem::take(&mut *lock_ptr!(self.inner.idle)?);
+        for ssn in &sessions {
+            if let Err(e) = ssn.close().await {
+                tracing::warn!("Failed to close session <{}> of the pool: {e}", ssn.id);
+            }
+        }
+
+        Ok(())
+    }
+}
+
+impl PoolInner {
+    async fn create_session(&self) -> Result<Session, FlameError> {
+        let id = format!(
+            "{}-{}",
+            self.prefix,
+            self.next_id.fetch_add(1, Ordering::Relaxed)
+        );
+        self.conn
+            .create_session(&self.template.attributes(&id))
+            .await
+    }
+}
+
+impl PooledSession {
+    /// Return the session to the pool; it's the same as dropping it.
+    pub fn release(self) {}
+
+    /// Close the session instead of returning it to the pool, e.g. its
+    /// common data is stale; the pool creates another one on demand.
+    pub async fn discard(mut self) -> Result<(), FlameError> {
+        match self.session.take() {
+            Some(ssn) => ssn.close().await,
+            None => Ok(()),
+        }
+    }
+}
+
+impl Deref for PooledSession {
+    type Target = Session;
+
+    fn deref(&self) -> &Session {
+        // The session is only taken by `discard`, which consumes the guard.
+        self.session.as_ref().expect("session of the pool")
+    }
+}
+
+impl Drop for PooledSession {
+    fn drop(&mut self) {
+        if let Some(ssn) = self.session.take() {
+            match self.pool.idle.lock() {
+                Ok(mut idle) => idle.push(ssn),
+                Err(_) => tracing::warn!("Failed to return session <{}> to the pool", ssn.id),
+            }
+        }
+    }
+}
+
+/// Whether the session is still open by the result of `GetSession`; the
+/// other errors are returned, e.g. the session manager is unavailable.
+fn is_alive(res: &Result<Response<rpc::Session>, Status>) -> Result<bool, FlameError> {
+    match res {
+        Ok(ssn) => Ok(ssn
+            .get_ref()
+            .status
+            .as_ref()
+            .is_some_and(|status| status.state == rpc::SessionState::Open as i32)),
+        Err(status) if status.code() == Code::NotFound => Ok(false),
+        Err(status) => Err(FlameError::from(status.clone())),
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use tonic::transport::Channel;
+
+    use super::*;
+
+    fn session(state: rpc::SessionState) -> Response<rpc::Session> {
+        Response::new(rpc::Session {
+            status: Some(rpc::SessionStatus {
+                state: state as i32,
+                ..Default::default()
+            }),
+            ..Default::default()
+        })
+    }
+
+    #[test]
+    fn test_is_alive() {
+        assert!(is_alive(&Ok(session(rpc::SessionState::Open))).unwrap());
+        assert!(!is_alive(&Ok(session(rpc::SessionState::Closed))).unwrap());
+        assert!(!is_alive(&Err(Status::not_found("pi-pool-0"))).unwrap());
+        assert!(is_alive(&Err(Status::unavailable("restarting"))).is_err());
+    }
+
+    #[tokio::test]
+    async fn test_empty_session_pool() {
+        let conn = Connection {
+            channel: Channel::from_static("http://127.0.0.1:8080")
+                .connect_lazy()
+                .into(),
+            middlewares: Default::default(),
+            submit_limit: None,
+        };
+        let res = conn
+            .create_session_pool(SessionTemplate::new("pi"), 0)
+            .await;
+        assert!(matches!(res, Err(FlameError::InvalidConfig(_))));
+    }
+}
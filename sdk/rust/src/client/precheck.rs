@@ -0,0 +1,131 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//! The pre-check of submitting the tasks, so the large inputs are not
+//! uploaded for the tasks which are rejected anyway, e.g.
+//!
+//! ```ignore
+//! let check = session.check_submission(input.len() as u64).await?;
+//! check.ensure()?;
+//! if !check.feasible {
+//!     tracing::warn!("The tasks will be pending: {:?}", check.reasons);
+//! }
+//! let mut writer = session.upload_task().await?;
+//! ```
+
+use stdng::trace_fn;
+
+use crate::apis::flame::v1 as rpc;
+use crate::apis::FlameError;
+use crate::client::rpc::CheckSubmissionRequest;
+use crate::client::Session;
+
+/// Whether the tasks of a session would be accepted and scheduled, and the
+/// headroom of the cluster.
+#[derive(Clone, Debug, PartialEq)]
+pub struct SubmissionCheck {
+    /// Whether the tasks are accepted, e.g. the session is open and the
+    /// input is not too large.
+    pub accepted: bool,
+    /// Whether the executors of the session fit the nodes; the tasks of an
+    /// infeasible session are pending until larger nodes join.
+    pub feasible: bool,
+    /// The reasons why the tasks are rejected or infeasible.
+    pub reasons: Vec<String>,
+    /// The max size of the input of a task in bytes, by `upload_task`.
+    pub max_input_size: u64,
+    /// The slots of the ready nodes not allocated to the executors.
+    pub idle_slots: u32,
+    /// The slots of all the ready nodes.
+    pub total_slots: u32,
+}
+
+impl From<rpc::SubmissionCheck> for SubmissionCheck {
+    fn from(check: rpc::SubmissionCheck) -> Self {
+        Self {
+            accepted: check.accepted,
+            feasible: check.feasible,
+            reasons: check.reasons,
+            max_input_size: check.max_input_size,
+            idle_slots: check.idle_slots,
+            total_slots: check.total_slots,
+        }
+    }
+}
+
+impl SubmissionCheck {
+    /// Fail with the reasons if the tasks are rejected.
+    pub fn ensure(&self) -> Result<(), FlameError> {
+        if self.accepted {
+            return Ok(());
+        }
+
+        Err(FlameError::InvalidState(format!(
+            "the tasks are rejected: {}",
+            self.reasons.join("; ")
+        )))
+    }
+}
+
+impl Session {
+    /// Check whether the tasks with the inputs of `input_size` bytes would be
+    /// accepted and scheduled, before uploading the inputs.
+    pub async fn check_submission(&self, input_size: u64) -> Result<SubmissionCheck, FlameError> {
+        trace_fn!("Session::check_submission");
+        let mut client = self
+            .client
+            .clone()
+            .ok_or(FlameError::Internal("no flame client".to_string()))?;
+
+        let check = client
+            .check_submission(CheckSubmissionRequest {
+                session_id: self.id.clone(),
+                input_size,
+            })
+            .await?
+            .into_inner();
+
+        Ok(SubmissionCheck::from(check))
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_submission_check_ensure() {
+        let check = SubmissionCheck::from(rpc::SubmissionCheck {
+            accepted: true,
+            feasible: false,
+            reasons: vec!["the executor of <8> slots does not fit any ready node".to_string()],
+            max_input_size: 1 << 30,
+            idle_slots: 2,
+            total_slots: 4,
+        });
+        // The infeasible tasks are accepted, and pending.
+        assert!(check.ensure().is_ok());
+
+        let check = SubmissionCheck {
+            accepted: false,
+            reasons: vec![
+                "session <ssn-1> is not open".to_string(),
+                "the input is too large".to_string(),
+            ],
+            ..check
+        };
+        let err = check.ensure().unwrap_err();
+        assert!(matches!(err, FlameError::InvalidState(m)
+            if m == "the tasks are rejected: session <ssn-1> is not open; the input is too large"));
+    }
+}
@@ -0,0 +1,183 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//! The progress of a session by its stats, which are polled from the session
+//! manager instead of listing every task; the progress is either rendered to
+//! the terminal or passed to a callback.
+
+use std::io::Write;
+use std::time::Duration;
+
+use stdng::trace_fn;
+
+use crate::apis::FlameError;
+use crate::client::{Session, SessionStats};
+
+/// The default interval of polling the stats of a session.
+pub const DEFAULT_PROGRESS_INTERVAL: Duration = Duration::from_secs(1);
+
+/// The width of the progress bar in characters.
+const PROGRESS_BAR_WIDTH: usize = 30;
+
+/// Whether all the tasks of the session are completed; an open session
+/// without tasks is not done, as the tasks may not be submitted yet, but a
+/// closed one is.
+pub fn is_done(stats: &SessionStats) -> bool {
+    (stats.total() > 0 || stats.closed) && stats.pending == 0 && stats.running == 0
+}
+
+/// Render the stats as a line of progress, e.g.
+/// `[###############...............] 50/100 (50%) 12.0/min ETA 4m10s, 2 failed`.
+pub fn render(stats: &SessionStats) -> String {
+    let total = stats.total();
+    let completed = stats.completed();
+    let filled = if total == 0 {
+        0
+    } else {
+        completed as usize * PROGRESS_BAR_WIDTH / total as usize
+    };
+    let percent = if total == 0 {
+        0
+    } else {
+        completed * 100 / total
+    };
+
+    let mut line = format!(
+        "[{}{}] {completed}/{total} ({percent}%) {:.1}/min",
+        "#".repeat(filled),
+        ".".repeat(PROGRESS_BAR_WIDTH - filled),
+        stats.throughput,
+    );
+
+    if let Some(eta) = stats.eta.filter(|_| !is_done(stats)) {
+        line.push_str(&format!(" ETA {}", format_duration(eta)));
+    }
+    if stats.failed > 0 {
+        line.push_str(&format!(", {} failed", stats.failed));
+    }
+    if stats.cancelled > 0 {
+        line.push_str(&format!(", {} cancelled", stats.cancelled));
+    }
+
+    line
+}
+
+fn format_duration(duration: Duration) -> String {
+    let secs = duration.as_secs();
+    match (secs / 3600, secs % 3600 / 60, secs % 60) {
+        (0, 0, s) => format!("{s}s"),
+        (0, m, s) => format!("{m}m{s}s"),
+        (h, m, _) => format!("{h}h{m}m"),
+    }
+}
+
+/// Poll the stats of the session by `interval` and invoke the callback with
+/// each of them, until all the tasks are completed or the session is closed
+/// without tasks; the last stats are returned.
+pub async fn watch<F>(
+    session: &Session,
+    interval: Duration,
+    mut callback: F,
+) -> Result<SessionStats, FlameError>
+where
+    F: FnMut(&SessionStats),
+{
+    trace_fn!("progress::watch");
+    loop {
+        let stats = session.stats(None).await?;
+        callback(&stats);
+
+        if is_done(&stats) {
+            return Ok(stats);
+        }
+
+        tokio::time::sleep(interval).await;
+    }
+}
+
+/// Render the progress of the session to stderr until all the tasks are
+/// completed.
+pub async fn show(session: &Session, interval: Duration) -> Result<SessionStats, FlameError> {
+    let stats = watch(session, interval, |stats| {
+        let mut stderr = std::io::stderr();
+        // The progress is best-effort, e.g. stderr may be closed.
+        let _ = write!(stderr, "\r{}\x1b[K", render(stats));
+        let _ = stderr.flush();
+    })
+    .await?;
+    eprintln!();
+
+    Ok(stats)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_render_progress() {
+        let stats = SessionStats {
+            pending: 40,
+            running: 10,
+            succeed: 48,
+            failed: 2,
+            cancelled: 0,
+            throughput: 12.0,
+            eta: Some(Duration::from_secs(250)),
+            closed: false,
+        };
+        assert!(!is_done(&stats));
+        assert_eq!(
+            render(&stats),
+            format!(
+                "[{}{}] 50/100 (50%) 12.0/min ETA 4m10s, 2 failed",
+                "#".repeat(15),
+                ".".repeat(15)
+            )
+        );
+
+        let stats = SessionStats {
+            pending: 0,
+            running: 0,
+            succeed: 3,
+            ..stats
+        };
+        assert!(is_done(&stats));
+        assert_eq!(
+            render(&stats),
+            format!("[{}] 5/5 (100%) 12.0/min, 2 failed", "#".repeat(30))
+        );
+
+        // No tasks yet.
+        let stats = SessionStats::default();
+        assert!(!is_done(&stats));
+        assert_eq!(
+            render(&stats),
+            format!("[{}] 0/0 (0%) 0.0/min", ".".repeat(30))
+        );
+
+        // No tasks are to come once the session is closed.
+        let stats = SessionStats {
+            closed: true,
+            ..Default::default()
+        };
+        assert!(is_done(&stats));
+    }
+
+    #[test]
+    fn test_format_duration() {
+        assert_eq!(format_duration(Duration::from_secs(42)), "42s");
+        assert_eq!(format_duration(Duration::from_secs(250)), "4m10s");
+        assert_eq!(format_duration(Duration::from_secs(7300)), "2h1m");
+    }
+}
@@ -0,0 +1,171 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//! The rate limit of submitting the tasks by the client, so a bulk loader
+//! can't overwhelm the session manager, e.g.
+//!
+//! ```ignore
+//! let conn = flame::client::connect(addr)
+//!     .await?
+//!     .with_submit_rate_limit(100.0, 20)?;
+//! ```
+//!
+//! The limit is a token bucket shared by all the sessions of the connection:
+//! `burst` tasks are submitted at once, and then `rps` tasks per second. The
+//! submits wait for the tokens, e.g. `create_task` and `submit_batch`, except
+//! `try_create_task` which returns none if there's no token.
+
+use std::sync::{Arc, Mutex};
+use std::time::{Duration, Instant};
+
+use stdng::trace_fn;
+
+use crate::apis::{FlameError, TaskInput};
+use crate::client::{Connection, Session, Task};
+
+pub(crate) type RateLimiterPtr = Arc<RateLimiter>;
+
+pub(crate) struct RateLimiter {
+    rps: f64,
+    burst: f64,
+    bucket: Mutex<Bucket>,
+}
+
+struct Bucket {
+    /// The tokens in the bucket; it's negative when the waiting submits
+    /// reserved the tokens in the future.
+    tokens: f64,
+    last: Instant,
+}
+
+impl RateLimiter {
+    fn new(rps: f64, burst: u32) -> Result<Self, FlameError> {
+        if !rps.is_finite() || rps <= 0.0 {
+            return Err(FlameError::InvalidConfig(format!(
+                "invalid submit rate <{rps}>"
+            )));
+        }
+        if burst == 0 {
+            return Err(FlameError::InvalidConfig(
+                "the submit burst must be at least 1".to_string(),
+            ));
+        }
+
+        Ok(Self {
+            rps,
+            burst: burst as f64,
+            bucket: Mutex::new(Bucket {
+                tokens: burst as f64,
+                last: Instant::now(),
+            }),
+        })
+    }
+
+    /// Take the tokens if they're in the bucket.
+    fn try_acquire(&self, n: u32) -> bool {
+        let mut bucket = self.refill();
+        if bucket.tokens < n as f64 {
+            return false;
+        }
+        bucket.tokens -= n as f64;
+        true
+    }
+
+    /// Wait for the tokens; more tokens than the burst are waited for at
+    /// the rate.
+    pub(crate) async fn acquire(&self, n: u32) {
+        let wait = self.reserve(n);
+        if !wait.is_zero() {
+            tokio::time::sleep(wait).await;
+        }
+    }
+
+    /// Reserve the tokens, returning how long to wait until they're filled.
+    fn reserve(&self, n: u32) -> Duration {
+        let mut bucket = self.refill();
+        bucket.tokens -= n as f64;
+        if bucket.tokens >= 0.0 {
+            return Duration::ZERO;
+        }
+
+        Duration::from_secs_f64(-bucket.tokens / self.rps)
+    }
+
+    fn refill(&self) -> std::sync::MutexGuard<'_, Bucket> {
+        // The bucket is always consistent, so it's still used if poisoned.
+        let mut bucket = self.bucket.lock().unwrap_or_else(|e| e.into_inner());
+        let now = Instant::now();
+        let elapsed = now.duration_since(bucket.last).as_secs_f64();
+        bucket.tokens = (bucket.tokens + elapsed * self.rps).min(self.burst);
+        bucket.last = now;
+        bucket
+    }
+}
+
+impl Connection {
+    /// Limit the tasks submitted by the sessions of the connection to `rps`
+    /// per second, with the bursts of up to `burst` tasks.
+    pub fn with_submit_rate_limit(mut self, rps: f64, burst: u32) -> Result<Self, FlameError> {
+        self.submit_limit = Some(Arc::new(RateLimiter::new(rps, burst)?));
+        Ok(self)
+    }
+}
+
+impl Session {
+    /// Create a task if the rate limit of the connection allows it now,
+    /// without waiting; none is returned if the limit is reached.
+    pub async fn try_create_task(
+        &self,
+        input: Option<TaskInput>,
+    ) -> Result<Option<Task>, FlameError> {
+        trace_fn!("Session::try_create_task");
+        if let Some(limit) = &self.submit_limit {
+            if !limit.try_acquire(1) {
+                return Ok(None);
+            }
+        }
+
+        let spec = self.task_spec(input, vec![], None);
+        self.send_spec(spec).await.map(Some)
+    }
+
+    /// Wait for the rate limit of the connection to submit `n` tasks.
+    pub(crate) async fn wait_submit(&self, n: usize) {
+        if let Some(limit) = &self.submit_limit {
+            limit.acquire(n as u32).await;
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_rate_limiter() {
+        assert!(RateLimiter::new(0.0, 1).is_err());
+        assert!(RateLimiter::new(f64::NAN, 1).is_err());
+        assert!(RateLimiter::new(1.0, 0).is_err());
+
+        let limiter = RateLimiter::new(1.0, 2).unwrap();
+        assert!(limiter.try_acquire(1));
+        assert!(limiter.try_acquire(1));
+        assert!(!limiter.try_acquire(1));
+
+        // The tokens over the burst are reserved at the rate.
+        let wait = limiter.reserve(3);
+        assert!(wait > Duration::from_millis(2900), "{wait:?}");
+        assert!(wait <= Duration::from_secs(3), "{wait:?}");
+        assert!(!limiter.try_acquire(1));
+    }
+}
@@ -0,0 +1,156 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//! The replication of the session manager to its warm standby: the standby
+//! rejects the requests until it's promoted, so `connect` follows the standby
+//! to its leader, and the operators promote the standby by the token of the
+//! replication when the leader is down, e.g.
+//!
+//! ```ignore
+//! let conn = flame_rs::client::connect_standby("http://flame-session-manager-1:8080", None).await?;
+//! let status = conn.promote_standby(&token, false).await?;
+//! assert!(!status.standby);
+//! ```
+
+use chrono::{DateTime, Utc};
+use stdng::trace_fn;
+
+use crate::apis::flame::v1 as rpc;
+use crate::apis::FlameError;
+use crate::client::transport::Transport;
+use crate::client::Connection;
+
+use self::rpc::replication_client::ReplicationClient;
+use self::rpc::{GetReplicationRequest, PromoteStandbyRequest, ReplicaRole};
+
+/// The metadata of the token of the replication, i.e. `Bearer <token>`.
+const AUTHORIZATION_METADATA: &str = "authorization";
+
+/// The role of the session manager in the replication.
+#[derive(Clone, Debug)]
+pub struct ReplicationStatus {
+    /// Whether the session manager is a standby not promoted yet.
+    pub standby: bool,
+    /// Whether the session manager is an old leader fenced by its promoted
+    /// standby.
+    pub fenced: bool,
+    /// The leader followed by the standby, or the new leader of the fenced
+    /// one.
+    pub leader: Option<String>,
+    /// Whether the standby received the state of the leader.
+    pub synced: bool,
+    /// The time of the last state delta received by the standby.
+    pub last_delta_time: Option<DateTime<Utc>>,
+}
+
+impl From<rpc::ReplicationStatus> for ReplicationStatus {
+    fn from(status: rpc::ReplicationStatus) -> Self {
+        Self {
+            standby: status.role() == ReplicaRole::Standby,
+            fenced: status.role() == ReplicaRole::Fenced,
+            leader: status.leader,
+            synced: status.synced,
+            last_delta_time: status
+                .last_delta_time
+                .and_then(DateTime::from_timestamp_millis),
+        }
+    }
+}
+
+impl Connection {
+    pub async fn get_replication(&self) -> Result<ReplicationStatus, FlameError> {
+        trace_fn!("Connection::get_replication");
+        let mut client = ReplicationClient::new(self.channel.clone());
+        let status = client
+            .get_replication(GetReplicationRequest {})
+            .await?
+            .into_inner();
+
+        Ok(ReplicationStatus::from(status))
+    }
+
+    /// Promote the standby to the leader by the token of the replication,
+    /// e.g. the leader is down; the old leader is fenced first, unless it's
+    /// promoted by force, and the tasks running on it are re-queued. It's a
+    /// no-op if the standby was promoted.
+    pub async fn promote_standby(
+        &self,
+        token: &str,
+        force: bool,
+    ) -> Result<ReplicationStatus, FlameError> {
+        trace_fn!("Connection::promote_standby");
+        let mut req = tonic::Request::new(PromoteStandbyRequest { force });
+        let value = format!("Bearer {token}")
+            .parse()
+            .map_err(|_| FlameError::InvalidConfig("invalid token".to_string()))?;
+        req.metadata_mut().insert(AUTHORIZATION_METADATA, value);
+
+        let mut client = ReplicationClient::new(self.channel.clone());
+        let status = client.promote_standby(req).await?.into_inner();
+
+        Ok(ReplicationStatus::from(status))
+    }
+}
+
+/// The leader of the session manager if it's a standby, or the new leader if
+/// it was fenced; the errors are ignored, e.g. the older session managers do
+/// not serve the replication.
+pub(crate) async fn leader_of(channel: &Transport) -> Option<String> {
+    let mut client = ReplicationClient::new(channel.clone());
+    let status = client
+        .get_replication(GetReplicationRequest {})
+        .await
+        .ok()?
+        .into_inner();
+
+    let status = ReplicationStatus::from(status);
+    status.leader.filter(|_| status.standby || status.fenced)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_replication_status() {
+        let status = ReplicationStatus::from(rpc::ReplicationStatus {
+            role: ReplicaRole::Standby as i32,
+            leader: Some("http://flame-session-manager-0:8080".to_string()),
+            synced: true,
+            last_delta_time: Some(1_700_000_000_123),
+        });
+        assert!(status.standby);
+        assert!(!status.fenced);
+        assert_eq!(
+            status.leader.as_deref(),
+            Some("http://flame-session-manager-0:8080")
+        );
+        assert_eq!(
+            status.last_delta_time,
+            DateTime::from_timestamp_millis(1_700_000_000_123)
+        );
+
+        let status = ReplicationStatus::from(rpc::ReplicationStatus {
+            role: ReplicaRole::Fenced as i32,
+            leader: Some("http://flame-session-manager-1:8080".to_string()),
+            ..Default::default()
+        });
+        assert!(!status.standby);
+        assert!(status.fenced);
+
+        let status = ReplicationStatus::from(rpc::ReplicationStatus::default());
+        assert!(!status.standby);
+        assert!(!status.fenced);
+        assert!(status.leader.is_none());
+    }
+}
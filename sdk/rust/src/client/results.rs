@@ -0,0 +1,217 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//! The client-side cache of the task outputs, so the identical submissions,
+//! i.e. the same input to the same application with the same common data,
+//! return the cached output instead of running the task again, e.g.
+//!
+//! ```ignore
+//! let cache = Arc::new(MemoryResultCache::new(1024));
+//! let session = session.with_result_cache(cache).await?;
+//! let area: f64 = session.submit(&Circle { radius: 2.0 }).await?;
+//! ```
+//!
+//! Only the outputs of the succeed tasks are cached; the services must be
+//! deterministic for the cache to be correct.
+
+use std::collections::{HashMap, VecDeque};
+use std::sync::{Arc, Mutex};
+use std::time::Duration;
+
+use sha2::{Digest, Sha256};
+use stdng::{lock_ptr, trace_fn};
+
+use crate::apis::{FlameError, TaskInput, TaskOutput};
+use crate::client::typed::output_of;
+use crate::client::Session;
+
+/// The backing store of the cached outputs, e.g. in memory or a shared cache
+/// of several clients.
+#[tonic::async_trait]
+pub trait ResultCache: Send + Sync + 'static {
+    async fn get(&self, key: &str) -> Result<Option<TaskOutput>, FlameError>;
+    async fn put(&self, key: &str, output: TaskOutput) -> Result<(), FlameError>;
+}
+
+pub type ResultCachePtr = Arc<dyn ResultCache>;
+
+/// The cache of a session; the scope is the digest of the application and
+/// common data of the session.
+#[derive(Clone)]
+pub(crate) struct SessionResultCache {
+    cache: ResultCachePtr,
+    scope: String,
+}
+
+impl SessionResultCache {
+    fn key(&self, input: Option<&[u8]>) -> String {
+        digest(&[self.scope.as_bytes(), input.unwrap_or_default()])
+    }
+}
+
+impl Session {
+    /// Cache the outputs of the session in the cache; the common data of the
+    /// session is fetched to build the cache keys.
+    pub async fn with_result_cache(mut self, cache: ResultCachePtr) -> Result<Self, FlameError> {
+        trace_fn!("Session::with_result_cache");
+        let template = self.template().await?;
+        self.result_cache = Some(SessionResultCache {
+            cache,
+            scope: digest(&[
+                template.application.as_bytes(),
+                template.common_data.as_deref().unwrap_or_default(),
+            ]),
+        });
+
+        Ok(self)
+    }
+
+    /// Submit a task and wait for its output, which is returned from the
+    /// result cache of the session if the same input was submitted before.
+    pub async fn submit_output(
+        &self,
+        input: Option<TaskInput>,
+        timeout: Option<Duration>,
+    ) -> Result<TaskOutput, FlameError> {
+        trace_fn!("Session::submit_output");
+        let Some(cache) = &self.result_cache else {
+            let task = self.submit_and_wait(input, timeout).await?;
+            return output_of(&task);
+        };
+
+        let key = cache.key(input.as_deref());
+        match cache.cache.get(&key).await {
+            Ok(Some(output)) => return Ok(output),
+            Ok(None) => {}
+            Err(e) => tracing::warn!("Failed to get the output from the result cache: {e}"),
+        }
+
+        let task = self.submit_and_wait(input, timeout).await?;
+        let output = output_of(&task)?;
+        if let Err(e) = cache.cache.put(&key, output.clone()).await {
+            tracing::warn!("Failed to put the output into the result cache: {e}");
+        }
+
+        Ok(output)
+    }
+}
+
+/// The in-memory cache of at most `capacity` outputs; the oldest output is
+/// evicted first.
+pub struct MemoryResultCache {
+    capacity: usize,
+    entries: Mutex<MemoryEntries>,
+}
+
+#[derive(Default)]
+struct MemoryEntries {
+    outputs: HashMap<String, TaskOutput>,
+    order: VecDeque<String>,
+}
+
+impl MemoryResultCache {
+    pub fn new(capacity: usize) -> Self {
+        Self {
+            capacity: capacity.max(1),
+            entries: Mutex::new(MemoryEntries::default()),
+        }
+    }
+}
+
+#[tonic::async_trait]
+impl ResultCache for MemoryResultCache {
+    async fn get(&self, key: &str) -> Result<Option<TaskOutput>, FlameError> {
+        let entries = lock_ptr!(self.entries)?;
+        Ok(entries.outputs.get(key).cloned())
+    }
+
+    async fn put(&self, key: &str, output: TaskOutput) -> Result<(), FlameError> {
+        let mut entries = lock_ptr!(self.entries)?;
+        if entries.outputs.insert(key.to_string(), output).is_none() {
+            entries.order.push_back(key.to_string());
+        }
+        while entries.order.len() > self.capacity {
+            if let Some(oldest) = entries.order.pop_front() {
+                entries.outputs.remove(&oldest);
+            }
+        }
+
+        Ok(())
+    }
+}
+
+/// The SHA-256 digest of the fields; each field is prefixed by its length so
+/// the boundaries of the fields are part of the digest.
+fn digest(fields: &[&[u8]]) -> String {
+    let mut hasher = Sha256::new();
+    for field in fields {
+        hasher.update((field.len() as u64).to_be_bytes());
+        hasher.update(field);
+    }
+
+    hasher
+        .finalize()
+        .iter()
+        .map(|b| format!("{b:02x}"))
+        .collect()
+}
+
+#[cfg(test)]
+mod tests {
+    use bytes::Bytes;
+
+    use super::*;
+
+    #[test]
+    fn test_digest() {
+        assert_eq!(digest(&[b"pi", b"1"]), digest(&[b"pi", b"1"]));
+        assert_ne!(digest(&[b"pi", b"1"]), digest(&[b"pi", b"2"]));
+        // The boundaries of the fields are part of the digest.
+        assert_ne!(digest(&[b"pi", b"1"]), digest(&[b"pi1", b""]));
+    }
+
+    #[test]
+    fn test_session_result_cache_key() {
+        let cache = SessionResultCache {
+            cache: Arc::new(MemoryResultCache::new(1)),
+            scope: digest(&[b"pi", b""]),
+        };
+        let other = SessionResultCache {
+            scope: digest(&[b"pi", b"common"]),
+            ..cache.clone()
+        };
+
+        assert_eq!(cache.key(Some(b"1")), cache.key(Some(b"1")));
+        assert_ne!(cache.key(Some(b"1")), cache.key(Some(b"2")));
+        assert_ne!(cache.key(Some(b"1")), other.key(Some(b"1")));
+    }
+
+    #[tokio::test]
+    async fn test_memory_result_cache() {
+        let cache = MemoryResultCache::new(2);
+        cache.put("1", Bytes::from("one")).await.unwrap();
+        cache.put("2", Bytes::from("two")).await.unwrap();
+        assert_eq!(cache.get("1").await.unwrap(), Some(Bytes::from("one")));
+
+        // The oldest output is evicted.
+        cache.put("3", Bytes::from("three")).await.unwrap();
+        assert_eq!(cache.get("1").await.unwrap(), None);
+        assert_eq!(cache.get("2").await.unwrap(), Some(Bytes::from("two")));
+        assert_eq!(cache.get("3").await.unwrap(), Some(Bytes::from("three")));
+
+        // The update of an output does not change its order.
+        cache.put("2", Bytes::from("two")).await.unwrap();
+        cache.put("4", Bytes::from("four")).await.unwrap();
+        assert_eq!(cache.get("2").await.unwrap(), None);
+    }
+}
@@ -0,0 +1,154 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//! A session scoped to a closure, so the application code does not leak the
+//! sessions on errors, panics or cancellation, e.g.
+//!
+//! ```ignore
+//! let outputs = conn
+//!     .with_session(&attrs, |ssn| async move {
+//!         let task = ssn.submit_task(Some(input)).await?;
+//!         task.await
+//!     })
+//!     .await?;
+//! ```
+
+use std::future::Future;
+use std::panic::AssertUnwindSafe;
+
+use futures::FutureExt;
+use stdng::trace_fn;
+
+use crate::apis::FlameError;
+use crate::client::{Connection, Session, SessionAttributes};
+
+impl Connection {
+    /// Create a session, run `f` with it and close the session after `f`
+    /// returns, fails or panics; the session is drained if `f` succeeded,
+    /// or closed immediately otherwise. The error of `f` is returned before
+    /// the error of closing the session.
+    ///
+    /// If the returned future is dropped, e.g. by a timeout or `select!`, the
+    /// session is closed in the background, so its tasks are not dispatched
+    /// anymore.
+    pub async fn with_session<F, Fut, T>(
+        &self,
+        attrs: &SessionAttributes,
+        f: F,
+    ) -> Result<T, FlameError>
+    where
+        F: FnOnce(Session) -> Fut,
+        Fut: Future<Output = Result<T, FlameError>>,
+    {
+        trace_fn!("Connection::with_session");
+        let ssn = self.create_session(attrs).await?;
+        run_in(ssn, f).await
+    }
+}
+
+async fn run_in<F, Fut, T>(ssn: Session, f: F) -> Result<T, FlameError>
+where
+    F: FnOnce(Session) -> Fut,
+    Fut: Future<Output = Result<T, FlameError>>,
+{
+    let guard = CloseGuard {
+        session: Some(ssn.clone()),
+    };
+
+    let res = AssertUnwindSafe(f(ssn)).catch_unwind().await;
+    let closed = guard.close(matches!(res, Ok(Ok(_)))).await;
+
+    match res {
+        Ok(Ok(output)) => closed.map(|_| output),
+        Ok(Err(e)) => {
+            if let Err(ce) = closed {
+                tracing::warn!("Failed to close the session of the scope: {ce}");
+            }
+            Err(e)
+        }
+        Err(panic) => std::panic::resume_unwind(panic),
+    }
+}
+
+/// Close the session if the scope is dropped before `close`.
+struct CloseGuard {
+    session: Option<Session>,
+}
+
+impl CloseGuard {
+    async fn close(mut self, drain: bool) -> Result<(), FlameError> {
+        match self.session.take() {
+            Some(ssn) if drain => ssn.close().await,
+            Some(ssn) => ssn.close_now().await,
+            None => Ok(()),
+        }
+    }
+}
+
+impl Drop for CloseGuard {
+    fn drop(&mut self) {
+        let Some(ssn) = self.session.take() else {
+            return;
+        };
+
+        match tokio::runtime::Handle::try_current() {
+            Ok(handle) => {
+                handle.spawn(async move {
+                    if let Err(e) = ssn.close_now().await {
+                        tracing::warn!("Failed to close session <{}> of the scope: {e}", ssn.id);
+                    }
+                });
+            }
+            Err(_) => tracing::warn!("Session <{}> of the scope was not closed", ssn.id),
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::apis::flame::v1 as rpc;
+
+    fn session() -> Session {
+        Session::try_from(&rpc::Session {
+            metadata: Some(rpc::Metadata {
+                id: "ssn-1".to_string(),
+                ..Default::default()
+            }),
+            spec: Some(rpc::SessionSpec::default()),
+            status: Some(rpc::SessionStatus::default()),
+        })
+        .unwrap()
+    }
+
+    #[tokio::test]
+    async fn test_run_in_errors() {
+        // The session without a client fails to be closed.
+        let res = run_in(session(), |_| async { Ok(1) }).await;
+        assert!(matches!(res, Err(FlameError::Internal(_))));
+
+        // The error of the scope is returned before the one of closing.
+        let res: Result<(), FlameError> = run_in(session(), |ssn| async move {
+            Err(FlameError::InvalidState(format!("session <{}>", ssn.id)))
+        })
+        .await;
+        assert!(matches!(res, Err(FlameError::InvalidState(m)) if m == "session <ssn-1>"));
+    }
+
+    #[tokio::test]
+    #[should_panic(expected = "division by zero")]
+    async fn test_run_in_panic() {
+        let _: Result<(), FlameError> =
+            run_in(session(), |_| async { panic!("division by zero") }).await;
+    }
+}
@@ -0,0 +1,170 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//! The sessions filtered by the session manager as a stream, which pages
+//! through the sessions in the order of session id instead of pulling all the
+//! sessions of the cluster, e.g.
+//!
+//! ```ignore
+//! let filter = SessionFilter {
+//!     states: vec![SessionState::Open],
+//!     labels: vec!["team=ml".to_string()],
+//!     ..Default::default()
+//! };
+//! let mut sessions = std::pin::pin!(conn.sessions(filter));
+//! while let Some(ssn) = sessions.next().await {
+//!     println!("{}", ssn?.id);
+//! }
+//! ```
+
+use chrono::{DateTime, Utc};
+use futures::{Stream, TryStreamExt};
+use stdng::trace_fn;
+
+use crate::apis::{FlameError, SessionState};
+use crate::client::rpc::ListSessionRequest;
+use crate::client::{Connection, FlameClient, Session};
+
+/// The sessions of each page by `sessions`.
+const DEFAULT_SESSION_PAGE_SIZE: u32 = 500;
+
+/// The filter of the sessions.
+#[derive(Clone, Debug, Default)]
+pub struct SessionFilter {
+    /// The states of the sessions; all the states if empty.
+    pub states: Vec<SessionState>,
+    /// The sessions of the application; all the applications if not set.
+    pub application: Option<String>,
+    /// The sessions created at or after the time.
+    pub created_after: Option<DateTime<Utc>>,
+    /// The sessions created before the time.
+    pub created_before: Option<DateTime<Utc>>,
+    /// The sessions with all the labels.
+    pub labels: Vec<String>,
+}
+
+impl SessionFilter {
+    fn request(&self, page_token: Option<String>) -> ListSessionRequest {
+        ListSessionRequest {
+            states: self.states.iter().map(|s| *s as i32).collect(),
+            application: self.application.clone(),
+            created_after: self.created_after.map(|t| t.timestamp_millis()),
+            created_before: self.created_before.map(|t| t.timestamp_millis()),
+            labels: self.labels.clone(),
+            page_token,
+            page_size: Some(DEFAULT_SESSION_PAGE_SIZE),
+        }
+    }
+}
+
+impl Connection {
+    /// The sessions matching the filter in the order of session id; the pages
+    /// are fetched on demand, and the stream ends after the first error.
+    pub fn sessions(
+        &self,
+        filter: SessionFilter,
+    ) -> impl Stream<Item = Result<Session, FlameError>> {
+        trace_fn!("Connection::sessions");
+        let client = FlameClient::new(self.channel.clone());
+
+        // The state is the token of the next page; None after the last page.
+        futures::stream::try_unfold(Some(None), move |page_token: Option<Option<String>>| {
+            let mut client = client.clone();
+            let req = page_token.map(|token| filter.request(token));
+
+            async move {
+                let Some(req) = req else {
+                    return Ok(None);
+                };
+
+                let page = client
+                    .list_session(req)
+                    .await?
+                    .into_inner()
+                    .sessions
+                    .iter()
+                    .map(Session::try_from)
+                    .collect::<Result<Vec<_>, FlameError>>()?;
+
+                let next = next_page_token(&page);
+                Ok(Some((
+                    futures::stream::iter(page.into_iter().map(Ok)),
+                    next,
+                )))
+            }
+        })
+        .try_flatten()
+    }
+}
+
+/// The token of the next page, i.e. the id of the last session; None if the
+/// page is not full, which is the last one.
+fn next_page_token(page: &[Session]) -> Option<Option<String>> {
+    if page.len() < DEFAULT_SESSION_PAGE_SIZE as usize {
+        return None;
+    }
+
+    page.last().map(|ssn| Some(ssn.id.clone()))
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::apis::flame::v1 as rpc;
+
+    fn session(id: usize) -> Session {
+        Session::try_from(&rpc::Session {
+            metadata: Some(rpc::Metadata {
+                id: format!("ssn-{id:04}"),
+                ..Default::default()
+            }),
+            spec: Some(rpc::SessionSpec::default()),
+            status: Some(rpc::SessionStatus::default()),
+        })
+        .unwrap()
+    }
+
+    #[test]
+    fn test_next_page_token() {
+        assert_eq!(next_page_token(&[]), None);
+        assert_eq!(next_page_token(&[session(1), session(2)]), None);
+
+        let page: Vec<_> = (1..=DEFAULT_SESSION_PAGE_SIZE as usize)
+            .map(session)
+            .collect();
+        assert_eq!(
+            next_page_token(&page),
+            Some(Some(format!("ssn-{DEFAULT_SESSION_PAGE_SIZE:04}")))
+        );
+    }
+
+    #[test]
+    fn test_session_filter_request() {
+        let filter = SessionFilter {
+            states: vec![SessionState::Closed],
+            application: Some("pi".to_string()),
+            created_before: DateTime::from_timestamp_millis(1000),
+            labels: vec!["team=ml".to_string()],
+            ..Default::default()
+        };
+
+        let req = filter.request(Some("ssn-0500".to_string()));
+        assert_eq!(req.states, vec![1]);
+        assert_eq!(req.application.as_deref(), Some("pi"));
+        assert_eq!(req.created_after, None);
+        assert_eq!(req.created_before, Some(1000));
+        assert_eq!(req.labels, vec!["team=ml"]);
+        assert_eq!(req.page_token.as_deref(), Some("ssn-0500"));
+        assert_eq!(req.page_size, Some(DEFAULT_SESSION_PAGE_SIZE));
+    }
+}
@@ -0,0 +1,162 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//! The tasks of a session as a stream, which pages through the tasks in the
+//! order of task id instead of one unbounded list, e.g.
+//!
+//! ```ignore
+//! let filter = TaskFilter {
+//!     states: vec![TaskState::Failed],
+//!     ..Default::default()
+//! };
+//! let mut tasks = std::pin::pin!(session.tasks(filter));
+//! while let Some(task) = tasks.next().await {
+//!     println!("{}", task?.id);
+//! }
+//! ```
+
+use chrono::{DateTime, Utc};
+use futures::{Stream, TryStreamExt};
+use stdng::trace_fn;
+use tokio_stream::StreamExt;
+
+use crate::apis::{FlameError, TaskState};
+use crate::client::rpc::ListTaskRequest;
+use crate::client::{Session, Task};
+
+/// The tasks of each page by `tasks`.
+const DEFAULT_TASK_PAGE_SIZE: u32 = 500;
+
+/// The filter of the tasks of a session.
+#[derive(Clone, Debug, Default)]
+pub struct TaskFilter {
+    /// The states of the tasks; all the states if empty.
+    pub states: Vec<TaskState>,
+    /// The tasks created at or after the time.
+    pub created_after: Option<DateTime<Utc>>,
+    /// The tasks created before the time.
+    pub created_before: Option<DateTime<Utc>>,
+    /// The tasks with all the labels, e.g. `trace-id=abc`.
+    pub labels: Vec<String>,
+}
+
+impl TaskFilter {
+    fn request(&self, session_id: &str, page_token: Option<String>) -> ListTaskRequest {
+        ListTaskRequest {
+            session_id: session_id.to_string(),
+            states: self.states.iter().map(|s| *s as i32).collect(),
+            created_after: self.created_after.map(|t| t.timestamp_millis()),
+            created_before: self.created_before.map(|t| t.timestamp_millis()),
+            page_token,
+            page_size: Some(DEFAULT_TASK_PAGE_SIZE),
+            labels: self.labels.clone(),
+        }
+    }
+}
+
+impl Session {
+    /// The tasks of the session matching the filter in the order of task id;
+    /// the pages are fetched on demand, and the stream ends after the first
+    /// error.
+    pub fn tasks(&self, filter: TaskFilter) -> impl Stream<Item = Result<Task, FlameError>> {
+        trace_fn!("Session::tasks");
+        let client = self.client.clone();
+        let session_id = self.id.clone();
+
+        // The state is the token of the next page; None after the last page.
+        futures::stream::try_unfold(Some(None), move |page_token: Option<Option<String>>| {
+            let client = client.clone();
+            let req = page_token.map(|token| filter.request(&session_id, token));
+
+            async move {
+                let Some(req) = req else {
+                    return Ok(None);
+                };
+                let mut client =
+                    client.ok_or(FlameError::Internal("no flame client".to_string()))?;
+
+                let mut page = vec![];
+                let mut task_stream = client.list_task(req).await?.into_inner();
+                while let Some(task) = task_stream.next().await {
+                    page.push(Task::try_from(&task?)?);
+                }
+
+                let next = next_page_token(&page);
+                Ok(Some((
+                    futures::stream::iter(page.into_iter().map(Ok)),
+                    next,
+                )))
+            }
+        })
+        .try_flatten()
+    }
+}
+
+/// The token of the next page, i.e. the id of the last task; None if the page
+/// is not full, which is the last one.
+fn next_page_token(page: &[Task]) -> Option<Option<String>> {
+    if page.len() < DEFAULT_TASK_PAGE_SIZE as usize {
+        return None;
+    }
+
+    page.last().map(|task| Some(task.id.clone()))
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn task(id: usize) -> Task {
+        Task {
+            id: id.to_string(),
+            ssn_id: "ssn-1".to_string(),
+            state: TaskState::Succeed,
+            input: None,
+            output: None,
+            output_ref: None,
+            labels: vec![],
+            events: vec![],
+        }
+    }
+
+    #[test]
+    fn test_next_page_token() {
+        assert_eq!(next_page_token(&[]), None);
+        assert_eq!(next_page_token(&[task(1), task(2)]), None);
+
+        let page: Vec<_> = (1..=DEFAULT_TASK_PAGE_SIZE as usize).map(task).collect();
+        assert_eq!(
+            next_page_token(&page),
+            Some(Some(DEFAULT_TASK_PAGE_SIZE.to_string()))
+        );
+    }
+
+    #[test]
+    fn test_task_filter_request() {
+        let filter = TaskFilter {
+            states: vec![TaskState::Failed, TaskState::Cancelled],
+            created_after: DateTime::from_timestamp_millis(1000),
+            labels: vec!["shard=3".to_string()],
+            ..Default::default()
+        };
+
+        let req = filter.request("ssn-1", Some("500".to_string()));
+        assert_eq!(req.session_id, "ssn-1");
+        assert_eq!(req.states, vec![3, 4]);
+        assert_eq!(req.created_after, Some(1000));
+        assert_eq!(req.created_before, None);
+        assert_eq!(req.labels, vec!["shard=3".to_string()]);
+        assert_eq!(req.page_token.as_deref(), Some("500"));
+        assert_eq!(req.page_size, Some(DEFAULT_TASK_PAGE_SIZE));
+    }
+}
@@ -0,0 +1,318 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//! The templates of sessions, so many sessions of the same application,
+//! common data and slots are created by changing only a few fields, e.g.
+//!
+//! ```ignore
+//! let template = SessionTemplate::new("pi").with_slots(2).with_common_data(data);
+//! for i in 0..10 {
+//!     conn.create_session(&template.attributes(&format!("pi-{i}"))).await?;
+//! }
+//!
+//! // Or clone an existing session with another batch size.
+//! let ssn = ssn.clone_with("pi-gang", |t| t.with_batch_size(4)).await?;
+//! ```
+
+use stdng::trace_fn;
+
+use crate::apis::flame::v1 as rpc;
+use crate::apis::{CommonData, FlameError};
+use crate::client::rpc::{CreateSessionRequest, GetSessionRequest};
+use crate::client::{Session, SessionAttributes};
+
+/// The settings shared by the sessions created from the template.
+#[derive(Clone, Debug)]
+pub struct SessionTemplate {
+    pub application: String,
+    pub slots: u32,
+    pub common_data: Option<CommonData>,
+    pub min_instances: u32,
+    pub max_instances: Option<u32>,
+    pub batch_size: u32,
+    pub content_type: Option<String>,
+    pub labels: Vec<String>,
+}
+
+impl SessionTemplate {
+    /// The template of the application with one slot and no common data.
+    pub fn new(application: &str) -> Self {
+        Self {
+            application: application.to_string(),
+            slots: 1,
+            common_data: None,
+            min_instances: 0,
+            max_instances: None,
+            batch_size: 1,
+            content_type: None,
+            labels: vec![],
+        }
+    }
+
+    pub fn with_application(mut self, application: &str) -> Self {
+        self.application = application.to_string();
+        self
+    }
+
+    pub fn with_slots(mut self, slots: u32) -> Self {
+        self.slots = slots;
+        self
+    }
+
+    pub fn with_common_data(mut self, common_data: CommonData) -> Self {
+        self.common_data = Some(common_data);
+        self
+    }
+
+    pub fn without_common_data(mut self) -> Self {
+        self.common_data = None;
+        self
+    }
+
+    pub fn with_min_instances(mut self, min_instances: u32) -> Self {
+        self.min_instances = min_instances;
+        self
+    }
+
+    pub fn with_max_instances(mut self, max_instances: Option<u32>) -> Self {
+        self.max_instances = max_instances;
+        self
+    }
+
+    pub fn with_batch_size(mut self, batch_size: u32) -> Self {
+        self.batch_size = batch_size;
+        self
+    }
+
+    /// The content type of the inputs, which must be one of the content
+    /// types of the application if it declares any.
+    pub fn with_content_type(mut self, content_type: &str) -> Self {
+        self.content_type = Some(content_type.to_string());
+        self
+    }
+
+    /// The labels of the session, e.g. `team=ml`, to filter the sessions.
+    pub fn with_labels(mut self, labels: &[&str]) -> Self {
+        self.labels = labels.iter().map(|l| l.to_string()).collect();
+        self
+    }
+
+    pub fn validate(&self) -> Result<(), FlameError> {
+        if self.application.trim().is_empty() {
+            return Err(FlameError::InvalidConfig(
+                "the application of the session is empty".to_string(),
+            ));
+        }
+        if self.slots == 0 || self.batch_size == 0 {
+            return Err(FlameError::InvalidConfig(
+                "the slots and the batch size of the session must be positive".to_string(),
+            ));
+        }
+        if self
+            .max_instances
+            .is_some_and(|max| max < self.min_instances)
+        {
+            return Err(FlameError::InvalidConfig(format!(
+                "the max instances <{:?}> are less than the min instances <{}>",
+                self.max_instances, self.min_instances
+            )));
+        }
+
+        Ok(())
+    }
+
+    /// The attributes of the session with the id.
+    pub fn attributes(&self, id: &str) -> SessionAttributes {
+        SessionAttributes {
+            id: id.to_string(),
+            application: self.application.clone(),
+            slots: self.slots,
+            common_data: self.common_data.clone(),
+            min_instances: self.min_instances,
+            max_instances: self.max_instances,
+            batch_size: self.batch_size,
+            content_type: self.content_type.clone(),
+            labels: self.labels.clone(),
+        }
+    }
+}
+
+impl From<&SessionAttributes> for SessionTemplate {
+    fn from(attrs: &SessionAttributes) -> Self {
+        Self {
+            application: attrs.application.clone(),
+            slots: attrs.slots,
+            common_data: attrs.common_data.clone(),
+            min_instances: attrs.min_instances,
+            max_instances: attrs.max_instances,
+            batch_size: attrs.batch_size,
+            content_type: attrs.content_type.clone(),
+            labels: attrs.labels.clone(),
+        }
+    }
+}
+
+impl From<rpc::SessionSpec> for SessionTemplate {
+    fn from(spec: rpc::SessionSpec) -> Self {
+        Self {
+            application: spec.application,
+            slots: spec.slots,
+            common_data: spec.common_data.map(CommonData::from),
+            min_instances: spec.min_instances,
+            max_instances: spec.max_instances,
+            batch_size: spec.batch_size,
+            content_type: spec.content_type,
+            labels: spec.labels,
+        }
+    }
+}
+
+impl Session {
+    /// The template of the session, i.e. its application, common data, slots
+    /// and instances; the common data is fetched from the session manager.
+    pub async fn template(&self) -> Result<SessionTemplate, FlameError> {
+        trace_fn!("Session::template");
+        let mut client = self
+            .client
+            .clone()
+            .ok_or(FlameError::Internal("no flame client".to_string()))?;
+
+        let ssn = client
+            .get_session(GetSessionRequest {
+                session_id: self.id.clone(),
+            })
+            .await?
+            .into_inner();
+        let spec = ssn
+            .spec
+            .ok_or_else(|| FlameError::Internal("missing spec in response".to_string()))?;
+
+        Ok(SessionTemplate::from(spec))
+    }
+
+    /// Create a session with the id by the template of the session, changed
+    /// by the overrides; the tasks of the session are not cloned.
+    pub async fn clone_with(
+        &self,
+        id: &str,
+        overrides: impl FnOnce(SessionTemplate) -> SessionTemplate,
+    ) -> Result<Session, FlameError> {
+        trace_fn!("Session::clone_with");
+        let template = overrides(self.template().await?);
+        template.validate()?;
+        let mut client = self
+            .client
+            .clone()
+            .ok_or(FlameError::Internal("no flame client".to_string()))?;
+
+        let attrs = template.attributes(id);
+        let ssn = client
+            .create_session(CreateSessionRequest {
+                session_id: attrs.id.clone(),
+                session: Some(spec_of(&attrs)),
+            })
+            .await?
+            .into_inner();
+
+        let mut ssn = Session::try_from(&ssn)?;
+        ssn.client = Some(client);
+        ssn.middlewares = self.middlewares.clone();
+        ssn.submit_limit = self.submit_limit.clone();
+        ssn.long_poll = self.channel.is_long_poll();
+        Ok(ssn)
+    }
+}
+
+/// The spec of the session by its attributes.
+pub(crate) fn spec_of(attrs: &SessionAttributes) -> rpc::SessionSpec {
+    rpc::SessionSpec {
+        application: attrs.application.clone(),
+        slots: attrs.slots,
+        common_data: attrs.common_data.clone().map(CommonData::into),
+        min_instances: attrs.min_instances,
+        max_instances: attrs.max_instances,
+        batch_size: attrs.batch_size.max(1),
+        content_type: attrs.content_type.clone(),
+        // The large outputs are read from the host of the executor if it's
+        // the host of the client.
+        client_host: Some(gethostname::gethostname().to_string_lossy().into_owned()),
+        labels: attrs.labels.clone(),
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use bytes::Bytes;
+
+    use super::*;
+
+    #[test]
+    fn test_session_template() {
+        let template = SessionTemplate::new("pi")
+            .with_slots(2)
+            .with_common_data(Bytes::from("data"))
+            .with_max_instances(Some(10));
+
+        let attrs = template.attributes("pi-1");
+        assert_eq!(attrs.id, "pi-1");
+        assert_eq!(attrs.application, "pi");
+        assert_eq!(attrs.slots, 2);
+        assert_eq!(attrs.common_data, Some(Bytes::from("data")));
+        assert_eq!(attrs.min_instances, 0);
+        assert_eq!(attrs.max_instances, Some(10));
+        assert_eq!(attrs.batch_size, 1);
+
+        // Only the overridden fields are changed.
+        let clone = SessionTemplate::from(&attrs)
+            .with_batch_size(4)
+            .without_common_data();
+        assert_eq!(clone.application, "pi");
+        assert_eq!(clone.slots, 2);
+        assert_eq!(clone.common_data, None);
+        assert_eq!(clone.max_instances, Some(10));
+        assert_eq!(clone.batch_size, 4);
+        assert!(clone.validate().is_ok());
+
+        assert!(SessionTemplate::new("").validate().is_err());
+        assert!(SessionTemplate::new("pi").with_slots(0).validate().is_err());
+        assert!(SessionTemplate::new("pi")
+            .with_min_instances(4)
+            .with_max_instances(Some(2))
+            .validate()
+            .is_err());
+    }
+
+    #[test]
+    fn test_session_template_from_spec() {
+        let spec = spec_of(
+            &SessionTemplate::new("pi")
+                .with_batch_size(0)
+                .with_content_type("application/json")
+                .with_labels(&["team=ml"])
+                .attributes("pi"),
+        );
+        assert_eq!(spec.batch_size, 1);
+        assert_eq!(spec.content_type.as_deref(), Some("application/json"));
+        assert_eq!(spec.labels, vec!["team=ml"]);
+
+        let template = SessionTemplate::from(rpc::SessionSpec {
+            common_data: Some(b"data".to_vec()),
+            ..spec
+        });
+        assert_eq!(template.application, "pi");
+        assert_eq!(template.common_data, Some(Bytes::from("data")));
+        assert_eq!(template.batch_size, 1);
+        assert_eq!(template.content_type.as_deref(), Some("application/json"));
+        assert_eq!(template.labels, vec!["team=ml"]);
+    }
+}
@@ -0,0 +1,265 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//! The transport of the connection to the session manager. It's HTTP/2 by
+//! default; behind the proxies which block HTTP/2, e.g. in the corporate
+//! networks, the client falls back to gRPC-web over HTTP/1.1, and the tasks
+//! are watched by the long-poll of PollTask instead of the streams, e.g.
+//!
+//! ```ignore
+//! let conn = ConnectOptions::new("https://flame:8080")
+//!     .with_transport(TransportMode::LongPoll)
+//!     .connect()
+//!     .await?;
+//! ```
+//!
+//! By `TransportMode::Auto`, the long-poll is used only if the HTTP/2
+//! connection fails but the session manager answers over HTTP/1.1; it needs
+//! the `longpoll` feature. The client streams, e.g. `upload_task`, are not
+//! supported by the long-poll, and the session managers are not balanced or
+//! failed over by it.
+
+use std::task::{Context, Poll};
+
+use futures::future::BoxFuture;
+use futures::TryFutureExt;
+use tonic::body::BoxBody;
+use tonic::codegen::http;
+use tonic::transport::{Channel, Endpoint};
+use tower::Service;
+
+use crate::apis::FlameError;
+use crate::client::discovery::Target;
+use crate::client::options::ConnectOptions;
+
+type StdError = Box<dyn std::error::Error + Send + Sync>;
+
+/// How the client talks to the session manager.
+#[derive(Clone, Copy, Debug, Default, PartialEq, Eq)]
+pub enum TransportMode {
+    /// HTTP/2, falling back to the long-poll if HTTP/2 is blocked.
+    #[default]
+    Auto,
+    /// HTTP/2 only.
+    Http2,
+    /// gRPC-web over HTTP/1.1 with the long-poll of the tasks.
+    LongPoll,
+}
+
+/// The channel of the clients of the session manager.
+#[derive(Clone)]
+pub(crate) enum Transport {
+    Http2(Channel),
+    #[cfg(feature = "longpoll")]
+    LongPoll(web::WebChannel),
+}
+
+impl From<Channel> for Transport {
+    fn from(channel: Channel) -> Self {
+        Self::Http2(channel)
+    }
+}
+
+impl Transport {
+    /// Whether the streams are replaced by the long-poll.
+    pub(crate) fn is_long_poll(&self) -> bool {
+        match self {
+            Self::Http2(_) => false,
+            #[cfg(feature = "longpoll")]
+            Self::LongPoll(_) => true,
+        }
+    }
+}
+
+impl Service<http::Request<BoxBody>> for Transport {
+    type Response = http::Response<BoxBody>;
+    type Error = StdError;
+    type Future = BoxFuture<'static, Result<Self::Response, Self::Error>>;
+
+    fn poll_ready(&mut self, cx: &mut Context<'_>) -> Poll<Result<(), Self::Error>> {
+        match self {
+            Self::Http2(channel) => channel.poll_ready(cx).map_err(Into::into),
+            #[cfg(feature = "longpoll")]
+            Self::LongPoll(channel) => channel.poll_ready(cx),
+        }
+    }
+
+    fn call(&mut self, req: http::Request<BoxBody>) -> Self::Future {
+        match self {
+            Self::Http2(channel) => Box::pin(channel.call(req).map_err(Into::into)),
+            #[cfg(feature = "longpoll")]
+            Self::LongPoll(channel) => channel.call(req),
+        }
+    }
+}
+
+/// Connect to the only session manager by the transport of the options.
+pub(crate) async fn connect(
+    endpoint: &Endpoint,
+    target: &Target,
+    options: &ConnectOptions,
+    addr: &str,
+) -> Result<Transport, FlameError> {
+    match options.transport {
+        TransportMode::Http2 => options
+            .connect_endpoint(endpoint, addr)
+            .await
+            .map(Transport::from),
+        TransportMode::LongPoll => connect_long_poll(target, options).await,
+        TransportMode::Auto => match options.connect_endpoint(endpoint, addr).await {
+            Ok(channel) => Ok(channel.into()),
+            Err(e) => match connect_long_poll(target, options).await {
+                Ok(transport) => {
+                    tracing::warn!(
+                        "HTTP/2 to <{addr}> is blocked, fall back to the long-poll: {e}"
+                    );
+                    Ok(transport)
+                }
+                Err(_) => Err(e),
+            },
+        },
+    }
+}
+
+#[cfg(feature = "longpoll")]
+async fn connect_long_poll(
+    target: &Target,
+    options: &ConnectOptions,
+) -> Result<Transport, FlameError> {
+    use tonic::Code;
+
+    use crate::client::rpc::frontend_client::FrontendClient;
+    use crate::client::rpc::ListApplicationRequest;
+
+    let transport = Transport::LongPoll(web::WebChannel::new(target, options)?);
+
+    // Any status of the session manager means it answers over HTTP/1.1; the
+    // failures of the transport are unavailable or unknown.
+    let mut client = FrontendClient::new(transport.clone());
+    match client.list_application(ListApplicationRequest {}).await {
+        Err(status) if matches!(status.code(), Code::Unavailable | Code::Unknown) => {
+            Err(FlameError::Network(format!(
+                "failed to connect to <{}> over HTTP/1.1: {}",
+                target.uri,
+                status.message()
+            )))
+        }
+        _ => Ok(transport),
+    }
+}
+
+#[cfg(not(feature = "longpoll"))]
+async fn connect_long_poll(target: &Target, _: &ConnectOptions) -> Result<Transport, FlameError> {
+    Err(FlameError::InvalidConfig(format!(
+        "the long-poll is not supported, enable the `longpoll` feature for <{}>",
+        target.uri
+    )))
+}
+
+#[cfg(feature = "longpoll")]
+mod web {
+    use hyper_rustls::{HttpsConnector, HttpsConnectorBuilder};
+    use hyper_util::client::legacy::connect::HttpConnector;
+    use hyper_util::client::legacy::Client;
+    use hyper_util::rt::TokioExecutor;
+    use tonic_web::{GrpcWebCall, GrpcWebClientLayer, GrpcWebClientService};
+    use tower::Layer;
+
+    use super::*;
+
+    type HttpClient = Client<HttpsConnector<HttpConnector>, GrpcWebCall<BoxBody>>;
+
+    /// gRPC-web over HTTP/1.1 to the session manager.
+    #[derive(Clone)]
+    pub(crate) struct WebChannel {
+        inner: GrpcWebClientService<HttpClient>,
+        /// The scheme and the authority of the session manager, as the
+        /// clients only set the path of the requests.
+        origin: http::Uri,
+    }
+
+    impl WebChannel {
+        pub(crate) fn new(target: &Target, options: &ConnectOptions) -> Result<Self, FlameError> {
+            // The CA of the channels is not applied to the HTTP/1.1 client.
+            if options
+                .tls
+                .as_ref()
+                .is_some_and(|tls| tls.ca_file.is_some())
+            {
+                return Err(FlameError::InvalidConfig(format!(
+                    "the CA file is not supported by the long-poll to <{}>, \
+                     add it to the system CA bundle",
+                    target.uri
+                )));
+            }
+
+            let origin = target.uri.parse::<http::Uri>().map_err(|_| {
+                FlameError::InvalidConfig(format!("invalid address <{}>", target.uri))
+            })?;
+
+            let mut http = HttpConnector::new();
+            http.enforce_http(false);
+            http.set_keepalive(options.keepalive);
+            http.set_connect_timeout(options.connect_timeout);
+            let https = HttpsConnectorBuilder::new()
+                .with_native_roots()
+                .map_err(|e| {
+                    FlameError::InvalidConfig(format!("failed to load the system CA bundle: {e}"))
+                })?
+                .https_or_http()
+                .enable_http1()
+                .wrap_connector(http);
+            let client = Client::builder(TokioExecutor::new()).build(https);
+
+            Ok(Self {
+                inner: GrpcWebClientLayer::new().layer(client),
+                origin,
+            })
+        }
+
+        pub(crate) fn poll_ready(&mut self, cx: &mut Context<'_>) -> Poll<Result<(), StdError>> {
+            Service::poll_ready(&mut self.inner, cx).map_err(Into::into)
+        }
+
+        pub(crate) fn call(
+            &mut self,
+            mut req: http::Request<BoxBody>,
+        ) -> BoxFuture<'static, Result<http::Response<BoxBody>, StdError>> {
+            let mut parts = self.origin.clone().into_parts();
+            parts.path_and_query = req.uri().path_and_query().cloned();
+            match http::Uri::from_parts(parts) {
+                Ok(uri) => *req.uri_mut() = uri,
+                Err(e) => return Box::pin(futures::future::err(e.into())),
+            }
+
+            Box::pin(
+                Service::call(&mut self.inner, req)
+                    .map_ok(|res| res.map(tonic::body::boxed))
+                    .map_err(Into::into),
+            )
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[tokio::test]
+    async fn test_transport_mode() {
+        assert_eq!(TransportMode::default(), TransportMode::Auto);
+
+        let channel = Channel::from_static("http://127.0.0.1:8080").connect_lazy();
+        assert!(!Transport::from(channel).is_long_poll());
+    }
+}
@@ -0,0 +1,185 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//! The typed tasks, whose input and output are marshaled by a codec instead
+//! of passing the raw bytes, e.g.
+//!
+//! ```ignore
+//! let area: f64 = session.submit(&Circle { radius: 2.0 }).await?;
+//! ```
+
+use std::time::Duration;
+
+use bytes::Bytes;
+use serde::de::DeserializeOwned;
+use serde::Serialize;
+use stdng::trace_fn;
+
+use crate::apis::{FlameError, TaskInput, TaskOutput};
+use crate::client::{Session, Task};
+
+/// The codec of the input and output of the typed tasks; the service of the
+/// application must use the same codec.
+pub trait Codec: Send + Sync {
+    fn encode<T: Serialize>(&self, value: &T) -> Result<Bytes, FlameError>;
+    fn decode<T: DeserializeOwned>(&self, data: &[u8]) -> Result<T, FlameError>;
+}
+
+/// The JSON codec, which is the default of the typed tasks.
+#[derive(Clone, Copy, Debug, Default)]
+pub struct JsonCodec;
+
+impl Codec for JsonCodec {
+    fn encode<T: Serialize>(&self, value: &T) -> Result<Bytes, FlameError> {
+        serde_json::to_vec(value)
+            .map(Bytes::from)
+            .map_err(|e| FlameError::InvalidConfig(format!("failed to encode input: {e}")))
+    }
+
+    fn decode<T: DeserializeOwned>(&self, data: &[u8]) -> Result<T, FlameError> {
+        serde_json::from_slice(data)
+            .map_err(|e| FlameError::InvalidState(format!("failed to decode output: {e}")))
+    }
+}
+
+impl Session {
+    /// Submit a typed task by the JSON codec and wait for its output.
+    pub async fn submit<In, Out>(&self, input: &In) -> Result<Out, FlameError>
+    where
+        In: Serialize,
+        Out: DeserializeOwned,
+    {
+        self.submit_with(&JsonCodec, input, None).await
+    }
+
+    /// Submit a typed task by the codec and wait for its output; the task is
+    /// failed with `InvalidState` if it's not completed within the timeout.
+    /// The output is from the result cache of the session, if any.
+    pub async fn submit_with<C, In, Out>(
+        &self,
+        codec: &C,
+        input: &In,
+        timeout: Option<Duration>,
+    ) -> Result<Out, FlameError>
+    where
+        C: Codec,
+        In: Serialize,
+        Out: DeserializeOwned,
+    {
+        trace_fn!("Session::submit_with");
+        let input: TaskInput = codec.encode(input)?;
+        let output = self.submit_output(Some(input), timeout).await?;
+
+        codec.decode(&output)
+    }
+}
+
+/// Decode the output of the completed task.
+pub fn decode_output<C, Out>(codec: &C, task: &Task) -> Result<Out, FlameError>
+where
+    C: Codec,
+    Out: DeserializeOwned,
+{
+    let output = output_of(task)?;
+    codec.decode(&output)
+}
+
+/// The output of the succeed task; the failed, cancelled or uncompleted task
+/// is an error with the reason of its last event.
+pub fn output_of(task: &Task) -> Result<TaskOutput, FlameError> {
+    if !task.is_completed() {
+        return Err(FlameError::InvalidState(format!(
+            "task <{}/{}> is not completed: {}",
+            task.ssn_id, task.id, task.state
+        )));
+    }
+
+    if !task.is_succeed() {
+        let reason = task
+            .events
+            .last()
+            .and_then(|event| event.message.clone())
+            .unwrap_or_default();
+        let msg = format!(
+            "task <{}/{}> is {}: {reason}",
+            task.ssn_id, task.id, task.state
+        );
+        if task.is_cancelled() {
+            return Err(FlameError::Cancelled(msg));
+        }
+        return Err(FlameError::Internal(msg));
+    }
+
+    // No output is an empty output, e.g. of the unit type.
+    Ok(task.output.clone().unwrap_or_else(TaskOutput::new))
+}
+
+#[cfg(test)]
+mod tests {
+    use serde_derive::{Deserialize, Serialize};
+
+    use super::*;
+    use crate::apis::TaskState;
+    use crate::client::Event;
+
+    #[derive(Debug, PartialEq, Serialize, Deserialize)]
+    struct Area {
+        value: f64,
+    }
+
+    fn task(state: TaskState, output: Option<&str>) -> Task {
+        Task {
+            id: "1".to_string(),
+            ssn_id: "ssn-1".to_string(),
+            state,
+            input: None,
+            output: output.map(|o| TaskOutput::from(o.to_string())),
+            output_ref: None,
+            labels: vec![],
+            events: vec![Event {
+                code: 0,
+                message: Some("division by zero".to_string()),
+                creation_time: chrono::Utc::now(),
+            }],
+        }
+    }
+
+    #[test]
+    fn test_decode_output() {
+        let input = JsonCodec.encode(&Area { value: 1.5 }).unwrap();
+        assert_eq!(input, Bytes::from(r#"{"value":1.5}"#));
+
+        let area: Area = decode_output(
+            &JsonCodec,
+            &task(TaskState::Succeed, Some(r#"{"value":12.5}"#)),
+        )
+        .unwrap();
+        assert_eq!(area, Area { value: 12.5 });
+
+        let err = decode_output::<_, Area>(&JsonCodec, &task(TaskState::Failed, None)).unwrap_err();
+        assert!(err.to_string().contains("division by zero"));
+
+        let err =
+            decode_output::<_, Area>(&JsonCodec, &task(TaskState::Cancelled, None)).unwrap_err();
+        assert!(matches!(err, FlameError::Cancelled(_)));
+
+        let err =
+            decode_output::<_, Area>(&JsonCodec, &task(TaskState::Running, None)).unwrap_err();
+        assert!(matches!(err, FlameError::InvalidState(_)));
+
+        // The output does not match the type.
+        assert!(
+            decode_output::<_, Area>(&JsonCodec, &task(TaskState::Succeed, Some("12.5"))).is_err()
+        );
+    }
+}
@@ -0,0 +1,280 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//! The input of a task uploaded in chunks, so the inputs larger than the
+//! message limit of gRPC are not split by the users, e.g.
+//!
+//! ```ignore
+//! let mut writer = session.upload_task().await?;
+//! tokio::io::copy(&mut tokio::fs::File::open("input.bin").await?, &mut writer).await?;
+//! let task = writer.finish().await?;
+//! ```
+
+use std::pin::Pin;
+use std::task::{ready, Context, Poll};
+use std::time::Instant;
+
+use futures::channel::mpsc;
+use futures::{Sink, SinkExt};
+use stdng::trace_fn;
+use tokio::io::AsyncWrite;
+use tokio::task::JoinHandle;
+
+use crate::apis::checksum::Hasher;
+use crate::apis::FlameError;
+use crate::client::middleware::Operation;
+use crate::client::rpc::UploadTaskRequest;
+use crate::client::{Session, Task};
+
+/// The size of the chunks of the input, well below the message limit.
+const UPLOAD_CHUNK_SIZE: usize = 1024 * 1024;
+/// The chunks buffered before the writes wait for the upload.
+const UPLOAD_CHUNKS_IN_FLIGHT: usize = 4;
+
+/// The writer of the input of a task; the task is created by `finish` after
+/// the whole input is uploaded, and the upload is aborted if it's dropped.
+pub struct TaskWriter {
+    session: Session,
+    tx: mpsc::Sender<UploadTaskRequest>,
+    chunk: Vec<u8>,
+    hasher: Hasher,
+    upload: JoinHandle<Result<Task, FlameError>>,
+}
+
+impl Session {
+    /// Start to upload the input of a task; see `TaskWriter`.
+    pub async fn upload_task(&self) -> Result<TaskWriter, FlameError> {
+        trace_fn!("Session::upload_task");
+        let mut client = self
+            .client
+            .clone()
+            .ok_or(FlameError::Internal("no flame client".to_string()))?;
+        if self.long_poll {
+            return Err(FlameError::InvalidState(
+                "the upload is not supported by the long-poll transport, use create_task"
+                    .to_string(),
+            ));
+        }
+
+        // The spec is sent first, so the task is rejected before its input
+        // is uploaded; the submission is reported to the middlewares once the
+        // task is created or the upload fails.
+        let (mut tx, rx) = mpsc::channel(UPLOAD_CHUNKS_IN_FLIGHT);
+        tx.send(spec_chunk(self)?)
+            .await
+            .map_err(|e| FlameError::Internal(format!("failed to start the upload: {e}")))?;
+        let middlewares = self.middlewares.clone();
+        let session_id = self.id.clone();
+        let start = Instant::now();
+        let upload = tokio::spawn(async move {
+            let res = client
+                .upload_task(rx)
+                .await
+                .map_err(FlameError::from)
+                .and_then(|task| Task::try_from(task.get_ref()));
+            middlewares.complete(Operation::Submit, &session_id, start, &res);
+
+            res
+        });
+
+        Ok(TaskWriter {
+            session: self.clone(),
+            tx,
+            chunk: Vec::with_capacity(UPLOAD_CHUNK_SIZE),
+            hasher: Hasher::default(),
+            upload,
+        })
+    }
+}
+
+impl TaskWriter {
+    /// Upload the rest of the input, and create the task.
+    pub async fn finish(mut self) -> Result<Task, FlameError> {
+        trace_fn!("TaskWriter::finish");
+        let hasher = std::mem::take(&mut self.hasher);
+        let req = UploadTaskRequest {
+            task: None,
+            chunk: std::mem::take(&mut self.chunk),
+            input_checksum: Some(hasher.finish()),
+        };
+        // The upload failed, e.g. it was rejected; the error is returned below.
+        if self.tx.send(req).await.is_ok() {
+            self.tx.close_channel();
+        }
+
+        (&mut self.upload)
+            .await
+            .map_err(|e| FlameError::Internal(format!("upload of the task panicked: {e}")))?
+    }
+
+    /// Send the buffered chunk if it's full, or `force`d by a flush.
+    fn poll_send(&mut self, cx: &mut Context<'_>, force: bool) -> Poll<std::io::Result<()>> {
+        if self.chunk.is_empty() || (!force && self.chunk.len() < UPLOAD_CHUNK_SIZE) {
+            return Poll::Ready(Ok(()));
+        }
+
+        ready!(Pin::new(&mut self.tx).poll_ready(cx)).map_err(closed)?;
+        let chunk = std::mem::replace(&mut self.chunk, Vec::with_capacity(UPLOAD_CHUNK_SIZE));
+        Pin::new(&mut self.tx)
+            .start_send(UploadTaskRequest {
+                task: None,
+                chunk,
+                input_checksum: None,
+            })
+            .map_err(closed)?;
+
+        Poll::Ready(Ok(()))
+    }
+}
+
+impl AsyncWrite for TaskWriter {
+    fn poll_write(
+        mut self: Pin<&mut Self>,
+        cx: &mut Context<'_>,
+        buf: &[u8],
+    ) -> Poll<std::io::Result<usize>> {
+        ready!(self.poll_send(cx, false))?;
+
+        let n = buf.len().min(UPLOAD_CHUNK_SIZE - self.chunk.len());
+        self.chunk.extend_from_slice(&buf[..n]);
+        self.hasher.update(&buf[..n]);
+
+        Poll::Ready(Ok(n))
+    }
+
+    fn poll_flush(mut self: Pin<&mut Self>, cx: &mut Context<'_>) -> Poll<std::io::Result<()>> {
+        ready!(self.poll_send(cx, true))?;
+        Pin::new(&mut self.tx).poll_flush(cx).map_err(closed)
+    }
+
+    fn poll_shutdown(self: Pin<&mut Self>, cx: &mut Context<'_>) -> Poll<std::io::Result<()>> {
+        // The task is only created by `finish`.
+        self.poll_flush(cx)
+    }
+}
+
+impl Drop for TaskWriter {
+    fn drop(&mut self) {
+        self.upload.abort();
+    }
+}
+
+/// The first chunk of the upload, with the spec of the task through the
+/// middlewares of the session but no input.
+fn spec_chunk(session: &Session) -> Result<UploadTaskRequest, FlameError> {
+    let mut spec = session.task_spec(None, vec![], None);
+    session.middlewares.submit(&mut spec)?;
+
+    Ok(UploadTaskRequest {
+        task: Some(spec),
+        chunk: vec![],
+        input_checksum: None,
+    })
+}
+
+fn closed(e: mpsc::SendError) -> std::io::Error {
+    std::io::Error::new(
+        std::io::ErrorKind::BrokenPipe,
+        format!("the upload of the task was closed: {e}"),
+    )
+}
+
+#[cfg(test)]
+mod tests {
+    use std::sync::{Arc, Mutex};
+
+    use futures::StreamExt;
+    use tokio::io::AsyncWriteExt;
+
+    use super::*;
+    use crate::apis::checksum::checksum;
+    use crate::apis::flame::v1 as rpc;
+    use crate::client::middleware::{ClientMiddleware, TaskSubmission};
+
+    fn session() -> Session {
+        Session::try_from(&rpc::Session {
+            metadata: Some(rpc::Metadata {
+                id: "ssn-1".to_string(),
+                ..Default::default()
+            }),
+            spec: Some(rpc::SessionSpec::default()),
+            status: Some(rpc::SessionStatus::default()),
+        })
+        .unwrap()
+    }
+
+    struct Labeler;
+
+    impl ClientMiddleware for Labeler {
+        fn on_submit(&self, task: &mut TaskSubmission) -> Result<(), FlameError> {
+            if task.labels.iter().any(|l| l == "uploaded") {
+                return Err(FlameError::InvalidConfig("dry run".to_string()));
+            }
+            task.labels.push("uploaded".to_string());
+            Ok(())
+        }
+    }
+
+    #[test]
+    fn test_spec_chunk_middlewares() {
+        let session = session().with_middleware(Arc::new(Labeler));
+        let spec = spec_chunk(&session).unwrap().task.unwrap();
+        assert_eq!(spec.labels, vec!["uploaded".to_string()]);
+
+        // The upload is not started if a middleware rejects the task.
+        let session = session.with_middleware(Arc::new(Labeler));
+        assert!(spec_chunk(&session).is_err());
+    }
+
+    #[tokio::test]
+    async fn test_task_writer_chunks() {
+        let session = session();
+
+        let (tx, mut rx) = mpsc::channel(UPLOAD_CHUNKS_IN_FLIGHT);
+        let received = Arc::new(Mutex::new(vec![]));
+        let upload = tokio::spawn({
+            let received = received.clone();
+            async move {
+                while let Some(req) = rx.next().await {
+                    received.lock().unwrap().push(req);
+                }
+                Err(FlameError::Internal("no session manager".to_string()))
+            }
+        });
+        let spec = spec_chunk(&session).unwrap().task.unwrap();
+        assert_eq!(spec.session_id, "ssn-1");
+        assert!(spec.input.is_none());
+
+        let mut writer = TaskWriter {
+            session,
+            tx,
+            chunk: vec![],
+            hasher: Hasher::default(),
+            upload,
+        };
+
+        let input = vec![7u8; UPLOAD_CHUNK_SIZE * 2 + 10];
+        writer.write_all(&input).await.unwrap();
+        assert!(writer.finish().await.is_err());
+
+        let received = received.lock().unwrap();
+        let sizes: Vec<usize> = received.iter().map(|req| req.chunk.len()).collect();
+        assert_eq!(sizes, vec![UPLOAD_CHUNK_SIZE, UPLOAD_CHUNK_SIZE, 10]);
+
+        // The last chunk carries the checksum of the whole input; the spec
+        // was sent by the first chunk.
+        let last = received.last().unwrap();
+        assert_eq!(last.input_checksum, Some(checksum(&input)));
+        assert!(received.iter().all(|req| req.task.is_none()));
+    }
+}
@@ -0,0 +1,148 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//! Waiting for the completion of a task by the watch of the task, falling
+//! back to polling GetTask when the stream is rejected, e.g. by a proxy which
+//! strips the long-lived streams:
+//!
+//! ```ignore
+//! let task = session.create_and_wait(Some(input), None).await?;
+//! ```
+//!
+//! The polls are backed off exponentially with a jitter, so the clients of
+//! the same batch don't poll the session manager at the same time.
+
+use std::time::Duration;
+
+use stdng::trace_fn;
+
+use crate::apis::{errors, FlameError, TaskID, TaskInput};
+use crate::client::{Session, Task};
+
+/// The initial interval of polling the task, doubled by each poll.
+const DEFAULT_POLL_INTERVAL: Duration = Duration::from_millis(200);
+/// The maximum interval of polling the task, without the jitter.
+const MAX_POLL_INTERVAL: Duration = Duration::from_secs(10);
+
+impl Session {
+    /// Create a task and wait for its completion by the client, i.e. the watch
+    /// of the task or polling it; unlike `submit_and_wait`, no request is held
+    /// by the session manager until the task is completed. The task is
+    /// returned in its current state if the timeout is reached.
+    pub async fn create_and_wait(
+        &self,
+        input: Option<TaskInput>,
+        timeout: Option<Duration>,
+    ) -> Result<Task, FlameError> {
+        trace_fn!("Session::create_and_wait");
+        let task = self.create_task(input).await?;
+        self.wait_task(&task.id, timeout).await
+    }
+
+    /// Wait for the completion of the task of the session; the task is
+    /// returned in its current state if the timeout is reached.
+    pub async fn wait_task(
+        &self,
+        task_id: &TaskID,
+        timeout: Option<Duration>,
+    ) -> Result<Task, FlameError> {
+        trace_fn!("Session::wait_task");
+        let Some(timeout) = timeout else {
+            return self.wait_completed(task_id).await;
+        };
+
+        match tokio::time::timeout(timeout, self.wait_completed(task_id)).await {
+            Ok(res) => res,
+            Err(_) => self.get_task(task_id).await,
+        }
+    }
+
+    async fn wait_completed(&self, task_id: &TaskID) -> Result<Task, FlameError> {
+        match self.watch(task_id.clone()).await {
+            Ok(mut updates) => {
+                while let Some(update) = updates.recv().await {
+                    match update {
+                        Ok(task) if task.is_completed() => return Ok(task),
+                        Ok(_) => {}
+                        // The stream was dropped and could not be re-established.
+                        Err(e) if is_stream_error(&e) => {
+                            tracing::debug!(
+                                "The watch of task <{}/{task_id}> was dropped, poll it: {e}",
+                                self.id
+                            );
+                            break;
+                        }
+                        Err(e) => return Err(e),
+                    }
+                }
+            }
+            Err(e) if is_stream_error(&e) => {
+                tracing::debug!(
+                    "The watch of task <{}/{task_id}> was rejected, poll it: {e}",
+                    self.id
+                );
+            }
+            Err(e) => return Err(e),
+        }
+
+        self.poll_task(task_id).await
+    }
+
+    async fn poll_task(&self, task_id: &TaskID) -> Result<Task, FlameError> {
+        let mut polls = 0;
+        loop {
+            let task = self.get_task(task_id).await?;
+            if task.is_completed() {
+                return Ok(task);
+            }
+
+            tokio::time::sleep(poll_interval(polls)).await;
+            polls += 1;
+        }
+    }
+}
+
+/// Whether the watch failed instead of the task, e.g. the stream is not
+/// supported by a proxy; the invalid task is reported to the caller.
+fn is_stream_error(e: &FlameError) -> bool {
+    e.is_retryable() || e.is(&errors::INTERNAL)
+}
+
+/// The interval before the next poll, with a jitter of up to a half of it.
+fn poll_interval(polls: u32) -> Duration {
+    let interval = DEFAULT_POLL_INTERVAL
+        .saturating_mul(1 << polls.min(16))
+        .min(MAX_POLL_INTERVAL);
+    let jitter = stdng::rand::up_to(interval.as_millis() as u64 / 2);
+
+    interval + Duration::from_millis(jitter)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_poll_interval() {
+        for (polls, interval) in [
+            (0, DEFAULT_POLL_INTERVAL),
+            (2, Duration::from_millis(800)),
+            (10, MAX_POLL_INTERVAL),
+            (u32::MAX, MAX_POLL_INTERVAL),
+        ] {
+            let next = poll_interval(polls);
+            assert!(next >= interval, "{next:?} < {interval:?}");
+            assert!(next <= interval + interval / 2, "{next:?} > {interval:?}");
+        }
+    }
+}
@@ -0,0 +1,277 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//! The updates of a task as a channel; the stream of WatchTask is
+//! re-established on the transient errors, so the receiver only sees the
+//! updates until the task is completed, e.g.
+//!
+//! ```ignore
+//! let mut updates = session.watch(task.id.clone()).await?;
+//! while let Some(update) = updates.recv().await {
+//!     println!("{:?}", update?.state);
+//! }
+//! ```
+//!
+//! By the long-poll transport, the task is polled by PollTask instead of the
+//! stream, with the same updates and retries.
+
+use std::time::{Duration, Instant};
+
+use stdng::trace_fn;
+use tokio::sync::mpsc;
+use tokio_stream::StreamExt;
+use tonic::{Code, Status, Streaming};
+
+use crate::apis::{FlameError, TaskID};
+use crate::client::middleware::Operation;
+use crate::client::rpc::{self, GetTaskRequest, PollTaskRequest, WatchTaskRequest};
+use crate::client::{FlameClient, Session, Task};
+
+/// The updates buffered in the channel before the watch waits for the receiver.
+const WATCH_BUFFER_SIZE: usize = 16;
+/// The retries of re-establishing the stream without any update in between.
+const DEFAULT_WATCH_RETRIES: u32 = 8;
+/// The initial delay of re-establishing the stream, doubled by each retry.
+const DEFAULT_WATCH_BACKOFF: Duration = Duration::from_millis(100);
+/// The maximum delay of re-establishing the stream.
+const MAX_WATCH_BACKOFF: Duration = Duration::from_secs(5);
+/// The time each PollTask is held by the session manager at most.
+const LONG_POLL_TIMEOUT: Duration = Duration::from_secs(25);
+
+/// The update of a watched task; the error is the last update of the channel.
+pub type TaskUpdate = Result<Task, FlameError>;
+
+impl Session {
+    /// Watch the task of the session; the channel is closed after the task
+    /// is completed, or after the error which can not be recovered. The watch
+    /// is stopped if the receiver is dropped.
+    pub async fn watch(&self, task_id: TaskID) -> Result<mpsc::Receiver<TaskUpdate>, FlameError> {
+        trace_fn!("Session::watch");
+        let mut client = self
+            .client
+            .clone()
+            .ok_or(FlameError::Internal("no flame client".to_string()))?;
+
+        self.middlewares.watch(&self.id, &task_id)?;
+        if self.long_poll {
+            return self.watch_by_poll(client, task_id).await;
+        }
+
+        let req = WatchTaskRequest {
+            session_id: self.id.clone(),
+            task_id: task_id.clone(),
+        };
+        // The first stream is established here, so the invalid task is
+        // reported to the caller instead of the channel; the middlewares see
+        // the watch completed once it's established.
+        let start = Instant::now();
+        let res = client
+            .watch_task(req.clone())
+            .await
+            .map_err(FlameError::from);
+        self.middlewares
+            .complete(Operation::Watch, &self.id, start, &res);
+        let stream = res?.into_inner();
+
+        let (tx, rx) = mpsc::channel(WATCH_BUFFER_SIZE);
+        tokio::spawn(run(client, req, stream, tx));
+
+        Ok(rx)
+    }
+
+    /// Watch the task by the long-poll; the current state is got here, so
+    /// the invalid task is reported to the caller.
+    async fn watch_by_poll(
+        &self,
+        mut client: FlameClient,
+        task_id: TaskID,
+    ) -> Result<mpsc::Receiver<TaskUpdate>, FlameError> {
+        let start = Instant::now();
+        let res = client
+            .get_task(GetTaskRequest {
+                session_id: self.id.clone(),
+                task_id: task_id.clone(),
+            })
+            .await
+            .map_err(FlameError::from);
+        self.middlewares
+            .complete(Operation::Watch, &self.id, start, &res);
+        let task = res?.into_inner();
+
+        let req = PollTaskRequest {
+            session_id: self.id.clone(),
+            task_id,
+            state: 0,
+            timeout: Some(LONG_POLL_TIMEOUT.as_millis() as u64),
+        };
+        let (tx, rx) = mpsc::channel(WATCH_BUFFER_SIZE);
+        tokio::spawn(poll(client, req, task, tx));
+
+        Ok(rx)
+    }
+}
+
+async fn poll(
+    mut client: FlameClient,
+    mut req: PollTaskRequest,
+    mut task: rpc::Task,
+    tx: mpsc::Sender<TaskUpdate>,
+) {
+    let mut retries = 0;
+
+    loop {
+        let update = Task::try_from(&task);
+        let done = update.as_ref().map_or(true, Task::is_completed);
+        if tx.send(update).await.is_err() || done {
+            return;
+        }
+        req.state = state_of(&task);
+
+        // The poll timed out returns the same state, which is polled again.
+        task = loop {
+            let res = tokio::select! {
+                res = client.poll_task(req.clone()) => res,
+                _ = tx.closed() => return,
+            };
+
+            match res {
+                Ok(resp) => {
+                    retries = 0;
+                    let task = resp.into_inner();
+                    if state_of(&task) != req.state {
+                        break task;
+                    }
+                }
+                Err(status) if is_transient(&status) && retries < DEFAULT_WATCH_RETRIES => {
+                    tokio::select! {
+                        _ = tokio::time::sleep(backoff(retries)) => {}
+                        _ = tx.closed() => return,
+                    }
+                    retries += 1;
+                }
+                Err(status) => {
+                    let _ = tx.send(Err(FlameError::from(status))).await;
+                    return;
+                }
+            }
+        };
+    }
+}
+
+fn state_of(task: &rpc::Task) -> i32 {
+    task.status.as_ref().map(|s| s.state).unwrap_or_default()
+}
+
+async fn run(
+    mut client: FlameClient,
+    req: WatchTaskRequest,
+    mut stream: Streaming<rpc::Task>,
+    tx: mpsc::Sender<TaskUpdate>,
+) {
+    let mut retries = 0;
+
+    loop {
+        let status = loop {
+            match stream.next().await {
+                Some(Ok(t)) => {
+                    let update = Task::try_from(&t);
+                    let done = update.as_ref().map_or(true, Task::is_completed);
+                    if tx.send(update).await.is_err() || done {
+                        return;
+                    }
+                    retries = 0;
+                }
+                Some(Err(status)) => break status,
+                // The stream is closed by the session manager before the
+                // task is completed, e.g. restarted.
+                None => break Status::unavailable("the watch of the task was closed"),
+            }
+        };
+
+        loop {
+            if !is_transient(&status) || retries >= DEFAULT_WATCH_RETRIES {
+                let _ = tx.send(Err(FlameError::from(status))).await;
+                return;
+            }
+
+            tokio::select! {
+                _ = tokio::time::sleep(backoff(retries)) => {}
+                _ = tx.closed() => return,
+            }
+            retries += 1;
+
+            tracing::debug!(
+                "Re-establish the watch of task <{}/{}> ({retries}/{DEFAULT_WATCH_RETRIES}): {status}",
+                req.session_id,
+                req.task_id
+            );
+
+            match client.watch_task(req.clone()).await {
+                Ok(resp) => {
+                    stream = resp.into_inner();
+                    break;
+                }
+                Err(e) => {
+                    if !is_transient(&e) {
+                        let _ = tx.send(Err(FlameError::from(e))).await;
+                        return;
+                    }
+                }
+            }
+        }
+    }
+}
+
+/// Whether the stream may be re-established after the error, e.g. the session
+/// manager was restarted or the connection was reset.
+fn is_transient(status: &Status) -> bool {
+    matches!(
+        status.code(),
+        Code::Unavailable
+            | Code::Unknown
+            | Code::Aborted
+            | Code::DeadlineExceeded
+            | Code::ResourceExhausted
+    )
+}
+
+fn backoff(retries: u32) -> Duration {
+    DEFAULT_WATCH_BACKOFF
+        .saturating_mul(1 << retries.min(16))
+        .min(MAX_WATCH_BACKOFF)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_is_transient() {
+        assert!(is_transient(&Status::unavailable("connection reset")));
+        assert!(is_transient(&Status::unknown("h2 protocol error")));
+        assert!(is_transient(&Status::deadline_exceeded("timeout")));
+
+        assert!(!is_transient(&Status::not_found("task <1> not found")));
+        assert!(!is_transient(&Status::invalid_argument("invalid task id")));
+        assert!(!is_transient(&Status::permission_denied("forbidden")));
+    }
+
+    #[test]
+    fn test_backoff() {
+        assert_eq!(backoff(0), Duration::from_millis(100));
+        assert_eq!(backoff(1), Duration::from_millis(200));
+        assert_eq!(backoff(3), Duration::from_millis(800));
+        assert_eq!(backoff(10), MAX_WATCH_BACKOFF);
+        assert_eq!(backoff(u32::MAX), MAX_WATCH_BACKOFF);
+    }
+}
@@ -0,0 +1,186 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+use std::collections::HashMap;
+use std::time::Duration;
+
+use bytes::Bytes;
+use rdkafka::config::ClientConfig;
+use rdkafka::consumer::{CommitMode, Consumer, StreamConsumer};
+use rdkafka::producer::{FutureProducer, FutureRecord};
+use rdkafka::{Message, Offset, TopicPartitionList};
+use tokio::time::Instant;
+
+use crate::apis::{FlameError, TaskOutput};
+use crate::connectors::{Record, Sink, Source};
+
+const DEFAULT_POLL_TIMEOUT: Duration = Duration::from_millis(500);
+
+#[derive(Clone, Debug)]
+pub struct KafkaSourceConfig {
+    pub brokers: String,
+    pub group_id: String,
+    pub topics: Vec<String>,
+    /// Extra librdkafka properties, e.g. `security.protocol`.
+    pub properties: HashMap<String, String>,
+}
+
+/// Consume records from Kafka topics; offsets are committed by the connector
+/// only after the tasks of the records were completed.
+pub struct KafkaSource {
+    consumer: StreamConsumer,
+    poll_timeout: Duration,
+}
+
+impl KafkaSource {
+    pub fn new(conf: &KafkaSourceConfig) -> Result<Self, FlameError> {
+        let mut client_conf = ClientConfig::new();
+        for (k, v) in &conf.properties {
+            client_conf.set(k, v);
+        }
+
+        let consumer: StreamConsumer = client_conf
+            .set("bootstrap.servers", &conf.brokers)
+            .set("group.id", &conf.group_id)
+            .set("enable.auto.commit", "false")
+            .set("auto.offset.reset", "earliest")
+            .create()
+            .map_err(|e| FlameError::InvalidConfig(format!("failed to create consumer: {e}")))?;
+
+        let topics: Vec<&str> = conf.topics.iter().map(String::as_str).collect();
+        consumer.subscribe(&topics).map_err(|e| {
+            FlameError::Network(format!("failed to subscribe <{:?}>: {e}", conf.topics))
+        })?;
+
+        Ok(Self {
+            consumer,
+            poll_timeout: DEFAULT_POLL_TIMEOUT,
+        })
+    }
+
+    pub fn with_poll_timeout(mut self, timeout: Duration) -> Self {
+        self.poll_timeout = timeout;
+        self
+    }
+}
+
+#[tonic::async_trait]
+impl Source for KafkaSource {
+    async fn poll(&mut self, max: usize) -> Result<Option<Vec<Record>>, FlameError> {
+        let deadline = Instant::now() + self.poll_timeout;
+        let mut records = vec![];
+
+        while records.len() < max {
+            let msg = match tokio::time::timeout_at(deadline, self.consumer.recv()).await {
+                Ok(msg) => {
+                    msg.map_err(|e| FlameError::Network(format!("failed to receive: {e}")))?
+                }
+                Err(_) => break,
+            };
+
+            records.push(Record {
+                stream: msg.topic().to_string(),
+                partition: msg.partition(),
+                offset: msg.offset(),
+                key: msg.key().map(Bytes::copy_from_slice),
+                payload: msg
+                    .payload()
+                    .map(Bytes::copy_from_slice)
+                    .unwrap_or_default(),
+            });
+        }
+
+        Ok(Some(records))
+    }
+
+    async fn commit(
+        &mut self,
+        stream: &str,
+        partition: i32,
+        offset: i64,
+    ) -> Result<(), FlameError> {
+        let mut tpl = TopicPartitionList::new();
+        tpl.add_partition_offset(stream, partition, Offset::Offset(offset))
+            .map_err(|e| FlameError::Internal(e.to_string()))?;
+
+        self.consumer.commit(&tpl, CommitMode::Async).map_err(|e| {
+            FlameError::Network(format!(
+                "failed to commit <{stream}/{partition}> at <{offset}>: {e}"
+            ))
+        })
+    }
+}
+
+#[derive(Clone, Debug)]
+pub struct KafkaSinkConfig {
+    pub brokers: String,
+    pub topic: String,
+    /// Extra librdkafka properties, e.g. `security.protocol`.
+    pub properties: HashMap<String, String>,
+}
+
+/// Produce task outputs to a Kafka topic. The key of the input record is kept
+/// when a task handles a single record.
+pub struct KafkaSink {
+    producer: FutureProducer,
+    topic: String,
+}
+
+impl KafkaSink {
+    pub fn new(conf: &KafkaSinkConfig) -> Result<Self, FlameError> {
+        let mut client_conf = ClientConfig::new();
+        for (k, v) in &conf.properties {
+            client_conf.set(k, v);
+        }
+
+        let producer: FutureProducer = client_conf
+            .set("bootstrap.servers", &conf.brokers)
+            .set("enable.idempotence", "true")
+            .create()
+            .map_err(|e| FlameError::InvalidConfig(format!("failed to create producer: {e}")))?;
+
+        Ok(Self {
+            producer,
+            topic: conf.topic.clone(),
+        })
+    }
+}
+
+#[tonic::async_trait]
+impl Sink for KafkaSink {
+    async fn send(
+        &mut self,
+        records: &[Record],
+        output: Option<TaskOutput>,
+    ) -> Result<(), FlameError> {
+        let payload = output.unwrap_or_default();
+        let key = match records {
+            [r] => r.key.clone(),
+            _ => None,
+        };
+
+        let mut record = FutureRecord::<[u8], [u8]>::to(&self.topic).payload(&payload[..]);
+        if let Some(key) = key.as_ref() {
+            record = record.key(&key[..]);
+        }
+
+        self.producer
+            .send(record, Duration::from_secs(0))
+            .await
+            .map_err(|(e, _)| {
+                FlameError::Network(format!("failed to send to <{}>: {e}", self.topic))
+            })?;
+
+        Ok(())
+    }
+}
@@ -0,0 +1,162 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+use std::collections::HashMap;
+use std::fs::{self, OpenOptions};
+use std::io::Write;
+use std::path::{Path, PathBuf};
+
+use serde_derive::{Deserialize, Serialize};
+
+use crate::apis::{FlameError, TaskID, TaskState};
+
+/// An entry of the manifest: the item, e.g. an object key, was handled by the
+/// task with the state.
+#[derive(Clone, Debug, Serialize, Deserialize)]
+pub struct ManifestEntry {
+    pub item: String,
+    pub task_id: TaskID,
+    pub state: TaskState,
+}
+
+/// A manifest records the completion of the items of a batch run, so an
+/// interrupted run can be resumed by skipping the succeeded items. The
+/// entries are appended as JSON lines; the last entry of an item wins.
+pub struct Manifest {
+    path: PathBuf,
+    entries: HashMap<String, ManifestEntry>,
+}
+
+impl Manifest {
+    /// Open the manifest at `path`, loading the entries of previous runs.
+    pub fn open(path: impl AsRef<Path>) -> Result<Self, FlameError> {
+        let path = path.as_ref().to_path_buf();
+        let mut entries = HashMap::new();
+
+        if path.exists() {
+            let content = fs::read_to_string(&path).map_err(|e| {
+                FlameError::Internal(format!("failed to read manifest <{}>: {e}", path.display()))
+            })?;
+
+            for line in content.lines() {
+                if line.trim().is_empty() {
+                    continue;
+                }
+                // Skip the partial line written by an interrupted run.
+                match serde_json::from_str::<ManifestEntry>(line) {
+                    Ok(entry) => {
+                        entries.insert(entry.item.clone(), entry);
+                    }
+                    Err(e) => tracing::warn!("Skip invalid manifest entry <{line}>: {e}"),
+                }
+            }
+
+            // Terminate the partial line, so the new entries start at a new line.
+            if !content.is_empty() && !content.ends_with('\n') {
+                let mut file = OpenOptions::new().append(true).open(&path).map_err(|e| {
+                    FlameError::Internal(format!(
+                        "failed to open manifest <{}>: {e}",
+                        path.display()
+                    ))
+                })?;
+                writeln!(file).map_err(|e| FlameError::Internal(e.to_string()))?;
+            }
+        }
+
+        Ok(Self { path, entries })
+    }
+
+    pub fn is_succeed(&self, item: &str) -> bool {
+        self.entries
+            .get(item)
+            .map(|e| e.state == TaskState::Succeed)
+            .unwrap_or(false)
+    }
+
+    pub fn get(&self, item: &str) -> Option<&ManifestEntry> {
+        self.entries.get(item)
+    }
+
+    pub fn len(&self) -> usize {
+        self.entries.len()
+    }
+
+    pub fn is_empty(&self) -> bool {
+        self.entries.is_empty()
+    }
+
+    /// Append the entry to the manifest file and flush it.
+    pub fn record(&mut self, entry: ManifestEntry) -> Result<(), FlameError> {
+        let line =
+            serde_json::to_string(&entry).map_err(|e| FlameError::Internal(e.to_string()))?;
+
+        let mut file = OpenOptions::new()
+            .create(true)
+            .append(true)
+            .open(&self.path)
+            .map_err(|e| {
+                FlameError::Internal(format!(
+                    "failed to open manifest <{}>: {e}",
+                    self.path.display()
+                ))
+            })?;
+        writeln!(file, "{line}").map_err(|e| FlameError::Internal(e.to_string()))?;
+        file.flush()
+            .map_err(|e| FlameError::Internal(e.to_string()))?;
+
+        self.entries.insert(entry.item.clone(), entry);
+
+        Ok(())
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn entry(item: &str, state: TaskState) -> ManifestEntry {
+        ManifestEntry {
+            item: item.to_string(),
+            task_id: "1".to_string(),
+            state,
+        }
+    }
+
+    #[test]
+    fn test_manifest_resume() {
+        let dir = tempfile::tempdir().unwrap();
+        let path = dir.path().join("manifest.jsonl");
+
+        {
+            let mut manifest = Manifest::open(&path).unwrap();
+            assert!(manifest.is_empty());
+            manifest.record(entry("a", TaskState::Succeed)).unwrap();
+            manifest.record(entry("b", TaskState::Failed)).unwrap();
+        }
+
+        // Simulate a partial line of an interrupted run.
+        let mut file = OpenOptions::new().append(true).open(&path).unwrap();
+        write!(file, "{{\"item\":\"c\"").unwrap();
+        drop(file);
+
+        let mut manifest = Manifest::open(&path).unwrap();
+        assert_eq!(manifest.len(), 2);
+        assert!(manifest.is_succeed("a"));
+        assert!(!manifest.is_succeed("b"));
+        assert!(!manifest.is_succeed("c"));
+
+        manifest.record(entry("b", TaskState::Succeed)).unwrap();
+        let manifest = Manifest::open(&path).unwrap();
+        assert!(manifest.is_succeed("b"));
+    }
+}
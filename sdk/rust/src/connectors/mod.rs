@@ -0,0 +1,383 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//! Connectors bridge external record streams into Flame sessions.
+//!
+//! A connector polls records from a [`Source`], submits each record (or a
+//! micro-batch of records) as a task of a [`Session`], writes the task output
+//! to a [`Sink`] and only then acknowledges the records back to the source.
+//! Records are acknowledged in order per partition, so a restarted connector
+//! replays everything that was not completed: the delivery is at-least-once.
+
+use std::collections::{BTreeSet, HashMap};
+use std::sync::{Arc, Mutex};
+
+use bincode::config;
+use bytes::Bytes;
+use futures::stream::FuturesUnordered;
+use stdng::{lock_ptr, trace_fn};
+use tokio_stream::StreamExt;
+
+use crate::apis::{FlameError, TaskInput, TaskOutput};
+use crate::client::{Session, Task, TaskInformer};
+
+#[cfg(feature = "kafka")]
+pub mod kafka;
+pub mod manifest;
+#[cfg(feature = "nats")]
+pub mod nats;
+#[cfg(feature = "s3")]
+pub mod s3;
+
+/// A record consumed from an external stream.
+#[derive(Clone, Debug)]
+pub struct Record {
+    /// The topic, subject or stream the record belongs to.
+    pub stream: String,
+    pub partition: i32,
+    pub offset: i64,
+    pub key: Option<Bytes>,
+    pub payload: Bytes,
+}
+
+#[tonic::async_trait]
+pub trait Source: Send + 'static {
+    /// Poll up to `max` records; it should wait a while before returning an
+    /// empty list. `None` means the source is exhausted.
+    async fn poll(&mut self, max: usize) -> Result<Option<Vec<Record>>, FlameError>;
+
+    /// Acknowledge the records once their task was completed, e.g. for the
+    /// sources which acknowledge each message individually.
+    async fn ack(&mut self, _records: &[Record]) -> Result<(), FlameError> {
+        Ok(())
+    }
+
+    /// Acknowledge all records of the partition before `offset`.
+    async fn commit(&mut self, stream: &str, partition: i32, offset: i64)
+        -> Result<(), FlameError>;
+}
+
+#[tonic::async_trait]
+pub trait Sink: Send + 'static {
+    /// Write the output of the task which handled `records`.
+    async fn send(
+        &mut self,
+        records: &[Record],
+        output: Option<TaskOutput>,
+    ) -> Result<(), FlameError>;
+}
+
+#[derive(Clone, Debug)]
+pub struct ConnectorConfig {
+    /// The number of records submitted as one task; if it's greater than 1,
+    /// the task input is encoded by [`encode_batch`].
+    pub batch_size: usize,
+    /// The maximum number of tasks in flight.
+    pub max_inflight: usize,
+}
+
+impl Default for ConnectorConfig {
+    fn default() -> Self {
+        Self {
+            batch_size: 1,
+            max_inflight: 16,
+        }
+    }
+}
+
+impl ConnectorConfig {
+    pub fn new() -> Self {
+        Self::default()
+    }
+
+    pub fn with_batch_size(mut self, batch_size: usize) -> Self {
+        self.batch_size = batch_size;
+        self
+    }
+
+    pub fn with_max_inflight(mut self, max_inflight: usize) -> Self {
+        self.max_inflight = max_inflight;
+        self
+    }
+
+    pub fn validate(&self) -> Result<(), FlameError> {
+        if self.batch_size == 0 || self.max_inflight == 0 {
+            return Err(FlameError::InvalidConfig(
+                "the batch size and the max in-flight tasks of the connector must be positive"
+                    .to_string(),
+            ));
+        }
+
+        Ok(())
+    }
+}
+
+pub struct Connector<S: Source, K: Sink> {
+    session: Session,
+    source: S,
+    sink: K,
+    config: ConnectorConfig,
+    tracker: OffsetTracker,
+}
+
+impl<S: Source, K: Sink> Connector<S, K> {
+    pub fn new(session: Session, source: S, sink: K, config: ConnectorConfig) -> Self {
+        Self {
+            session,
+            source,
+            sink,
+            config,
+            tracker: OffsetTracker::default(),
+        }
+    }
+
+    /// Run the connector until the source is exhausted or any task fails;
+    /// the records of a failed task are not acknowledged.
+    pub async fn run(&mut self) -> Result<(), FlameError> {
+        trace_fn!("Connector::run");
+        self.config.validate()?;
+
+        let batch_size = self.config.batch_size.max(1);
+        let max_inflight = self.config.max_inflight.max(1);
+
+        let mut inflight = FuturesUnordered::new();
+        let mut exhausted = false;
+
+        loop {
+            while !exhausted && inflight.len() < max_inflight {
+                let records = match self.source.poll(batch_size).await? {
+                    Some(records) => records,
+                    None => {
+                        exhausted = true;
+                        break;
+                    }
+                };
+                if records.is_empty() {
+                    break;
+                }
+
+                for r in &records {
+                    self.tracker.start(r);
+                }
+
+                let input = if batch_size == 1 {
+                    records[0].payload.clone()
+                } else {
+                    encode_batch(&records)?
+                };
+
+                let task = self.session.create_task(Some(input)).await?;
+                let ssn = self.session.clone();
+                inflight.push(async move {
+                    let task = wait_task(&ssn, task).await;
+                    (records, task)
+                });
+            }
+
+            match inflight.next().await {
+                Some((records, task)) => self.complete(records, task?).await?,
+                None if exhausted => return Ok(()),
+                None => continue,
+            }
+        }
+    }
+
+    async fn complete(&mut self, records: Vec<Record>, task: Task) -> Result<(), FlameError> {
+        if !task.is_succeed() {
+            return Err(FlameError::Internal(format!(
+                "task <{}/{}> is <{}>",
+                task.ssn_id, task.id, task.state
+            )));
+        }
+
+        self.sink.send(&records, task.output.clone()).await?;
+        self.source.ack(&records).await?;
+
+        let mut commits = HashMap::new();
+        for r in &records {
+            if let Some(offset) = self.tracker.complete(r) {
+                commits.insert((r.stream.clone(), r.partition), offset);
+            }
+        }
+        for ((stream, partition), offset) in commits {
+            self.source.commit(&stream, partition, offset).await?;
+        }
+
+        Ok(())
+    }
+}
+
+/// Encode a micro-batch of record payloads as a task input.
+pub fn encode_batch(records: &[Record]) -> Result<TaskInput, FlameError> {
+    let payloads: Vec<Vec<u8>> = records.iter().map(|r| r.payload.to_vec()).collect();
+    let data = bincode::encode_to_vec(payloads, config::standard())
+        .map_err(|e| FlameError::Internal(e.to_string()))?;
+
+    Ok(TaskInput::from(data))
+}
+
+/// Decode the record payloads of a micro-batch, used by the service side.
+pub fn decode_batch(input: &TaskInput) -> Result<Vec<Bytes>, FlameError> {
+    let (payloads, _): (Vec<Vec<u8>>, usize) =
+        bincode::decode_from_slice(input, config::standard())
+            .map_err(|e| FlameError::Internal(e.to_string()))?;
+
+    Ok(payloads.into_iter().map(Bytes::from).collect())
+}
+
+struct TaskWaiter {
+    task: Option<Task>,
+    error: Option<FlameError>,
+}
+
+impl TaskInformer for TaskWaiter {
+    fn on_update(&mut self, task: Task) {
+        self.task = Some(task);
+    }
+
+    fn on_error(&mut self, e: FlameError) {
+        self.error = Some(e);
+    }
+}
+
+pub(crate) async fn wait_task(ssn: &Session, task: Task) -> Result<Task, FlameError> {
+    let waiter = Arc::new(Mutex::new(TaskWaiter {
+        task: None,
+        error: None,
+    }));
+
+    ssn.watch_task(task.ssn_id.clone(), task.id.clone(), waiter.clone())
+        .await?;
+
+    let mut waiter = lock_ptr!(waiter)?;
+    if let Some(e) = waiter.error.take() {
+        return Err(e);
+    }
+
+    match waiter.task.take() {
+        Some(t) if t.is_completed() => Ok(t),
+        _ => Err(FlameError::Internal(format!(
+            "task <{}/{}> was not completed",
+            task.ssn_id, task.id
+        ))),
+    }
+}
+
+/// Track the in-flight offsets of each partition, and compute the offset
+/// that is safe to commit: all records before it were completed.
+#[derive(Default)]
+struct OffsetTracker {
+    partitions: HashMap<(String, i32), PartitionOffsets>,
+}
+
+#[derive(Default)]
+struct PartitionOffsets {
+    inflight: BTreeSet<i64>,
+    next: i64,
+    committed: i64,
+}
+
+impl OffsetTracker {
+    fn start(&mut self, r: &Record) {
+        let p = self
+            .partitions
+            .entry((r.stream.clone(), r.partition))
+            .or_default();
+        p.inflight.insert(r.offset);
+        p.next = p.next.max(r.offset + 1);
+    }
+
+    /// Mark the record as completed; return the new offset to commit if the
+    /// low watermark of the partition moved.
+    fn complete(&mut self, r: &Record) -> Option<i64> {
+        let p = self.partitions.get_mut(&(r.stream.clone(), r.partition))?;
+        p.inflight.remove(&r.offset);
+
+        let watermark = p.inflight.first().copied().unwrap_or(p.next);
+        if watermark > p.committed {
+            p.committed = watermark;
+            return Some(watermark);
+        }
+
+        None
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn record(partition: i32, offset: i64) -> Record {
+        Record {
+            stream: "input".to_string(),
+            partition,
+            offset,
+            key: None,
+            payload: Bytes::from(format!("{partition}-{offset}")),
+        }
+    }
+
+    #[test]
+    fn test_connector_config() {
+        let config = ConnectorConfig::new()
+            .with_batch_size(8)
+            .with_max_inflight(4);
+        assert_eq!(config.batch_size, 8);
+        assert!(config.validate().is_ok());
+        assert!(ConnectorConfig::new()
+            .with_batch_size(0)
+            .validate()
+            .is_err());
+        assert!(ConnectorConfig::new()
+            .with_max_inflight(0)
+            .validate()
+            .is_err());
+    }
+
+    #[test]
+    fn test_offset_tracker_in_order() {
+        let mut tracker = OffsetTracker::default();
+        for i in 0..3 {
+            tracker.start(&record(0, i));
+        }
+
+        assert_eq!(tracker.complete(&record(0, 0)), Some(1));
+        assert_eq!(tracker.complete(&record(0, 1)), Some(2));
+        assert_eq!(tracker.complete(&record(0, 2)), Some(3));
+    }
+
+    #[test]
+    fn test_offset_tracker_out_of_order() {
+        let mut tracker = OffsetTracker::default();
+        for i in 0..3 {
+            tracker.start(&record(0, i));
+        }
+        tracker.start(&record(1, 7));
+
+        assert_eq!(tracker.complete(&record(0, 2)), None);
+        assert_eq!(tracker.complete(&record(0, 1)), None);
+        assert_eq!(tracker.complete(&record(1, 7)), Some(8));
+        assert_eq!(tracker.complete(&record(0, 0)), Some(3));
+    }
+
+    #[test]
+    fn test_batch_roundtrip() {
+        let records = vec![record(0, 0), record(0, 1)];
+        let input = encode_batch(&records).unwrap();
+        let payloads = decode_batch(&input).unwrap();
+
+        assert_eq!(payloads.len(), 2);
+        assert_eq!(payloads[0], records[0].payload);
+        assert_eq!(payloads[1], records[1].payload);
+    }
+}
@@ -0,0 +1,198 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+use std::collections::HashMap;
+use std::time::Duration;
+
+use async_nats::jetstream::{self, consumer, AckKind};
+use bytes::Bytes;
+use tokio::time::Instant;
+use tokio_stream::StreamExt;
+
+use crate::apis::{FlameError, TaskOutput};
+use crate::connectors::{Record, Sink, Source};
+
+const DEFAULT_POLL_TIMEOUT: Duration = Duration::from_millis(500);
+
+#[derive(Clone, Debug)]
+pub struct JetStreamSourceConfig {
+    pub url: String,
+    pub stream: String,
+    /// The durable consumer name, created if not found.
+    pub consumer: String,
+    pub filter_subject: Option<String>,
+    /// How long the server waits for the ack of a message before redelivering
+    /// it; it should be longer than the task's execution time.
+    pub ack_wait: Duration,
+}
+
+/// Consume messages from a JetStream durable pull consumer; a message is
+/// acked after its task was completed and its output was written.
+pub struct JetStreamSource {
+    messages: consumer::pull::Stream,
+    pending: HashMap<i64, jetstream::Message>,
+    poll_timeout: Duration,
+    /// The stream of the messages ended.
+    closed: bool,
+}
+
+impl JetStreamSource {
+    pub async fn new(conf: &JetStreamSourceConfig) -> Result<Self, FlameError> {
+        let client = async_nats::connect(&conf.url).await.map_err(|e| {
+            FlameError::Network(format!("failed to connect to <{}>: {e}", conf.url))
+        })?;
+        let js = jetstream::new(client);
+
+        let stream = js
+            .get_stream(&conf.stream)
+            .await
+            .map_err(|e| FlameError::NotFound(format!("stream <{}>: {e}", conf.stream)))?;
+
+        let consumer = stream
+            .get_or_create_consumer(
+                &conf.consumer,
+                consumer::pull::Config {
+                    durable_name: Some(conf.consumer.clone()),
+                    ack_policy: consumer::AckPolicy::Explicit,
+                    ack_wait: conf.ack_wait,
+                    filter_subject: conf.filter_subject.clone().unwrap_or_default(),
+                    ..Default::default()
+                },
+            )
+            .await
+            .map_err(|e| {
+                FlameError::Network(format!(
+                    "failed to create consumer <{}>: {e}",
+                    conf.consumer
+                ))
+            })?;
+
+        let messages = consumer
+            .messages()
+            .await
+            .map_err(|e| FlameError::Network(format!("failed to pull messages: {e}")))?;
+
+        Ok(Self {
+            messages,
+            pending: HashMap::new(),
+            poll_timeout: DEFAULT_POLL_TIMEOUT,
+            closed: false,
+        })
+    }
+
+    pub fn with_poll_timeout(mut self, timeout: Duration) -> Self {
+        self.poll_timeout = timeout;
+        self
+    }
+}
+
+#[tonic::async_trait]
+impl Source for JetStreamSource {
+    async fn poll(&mut self, max: usize) -> Result<Option<Vec<Record>>, FlameError> {
+        if self.closed {
+            return Ok(None);
+        }
+
+        let deadline = Instant::now() + self.poll_timeout;
+        let mut records = vec![];
+
+        while records.len() < max {
+            let msg = match tokio::time::timeout_at(deadline, self.messages.next()).await {
+                Ok(Some(msg)) => {
+                    msg.map_err(|e| FlameError::Network(format!("failed to receive: {e}")))?
+                }
+                // The records received before the end of the stream are
+                // returned; the next poll reports the end.
+                Ok(None) => {
+                    self.closed = true;
+                    if records.is_empty() {
+                        return Ok(None);
+                    }
+                    break;
+                }
+                Err(_) => break,
+            };
+
+            let info = msg
+                .info()
+                .map_err(|e| FlameError::Internal(format!("invalid message info: {e}")))?;
+            let record = Record {
+                stream: info.stream.to_string(),
+                partition: 0,
+                offset: info.stream_sequence as i64,
+                key: Some(Bytes::from(msg.subject.to_string())),
+                payload: msg.payload.clone(),
+            };
+
+            self.pending.insert(record.offset, msg);
+            records.push(record);
+        }
+
+        Ok(Some(records))
+    }
+
+    async fn ack(&mut self, records: &[Record]) -> Result<(), FlameError> {
+        for r in records {
+            if let Some(msg) = self.pending.remove(&r.offset) {
+                msg.ack_with(AckKind::Ack).await.map_err(|e| {
+                    FlameError::Network(format!("failed to ack <{}/{}>: {e}", r.stream, r.offset))
+                })?;
+            }
+        }
+
+        Ok(())
+    }
+
+    async fn commit(&mut self, _: &str, _: i32, _: i64) -> Result<(), FlameError> {
+        // The messages were acked one by one.
+        Ok(())
+    }
+}
+
+/// Publish task outputs to a JetStream subject.
+pub struct JetStreamSink {
+    js: jetstream::Context,
+    subject: String,
+}
+
+impl JetStreamSink {
+    pub async fn new(url: &str, subject: &str) -> Result<Self, FlameError> {
+        let client = async_nats::connect(url)
+            .await
+            .map_err(|e| FlameError::Network(format!("failed to connect to <{url}>: {e}")))?;
+
+        Ok(Self {
+            js: jetstream::new(client),
+            subject: subject.to_string(),
+        })
+    }
+}
+
+#[tonic::async_trait]
+impl Sink for JetStreamSink {
+    async fn send(&mut self, _: &[Record], output: Option<TaskOutput>) -> Result<(), FlameError> {
+        let ack = self
+            .js
+            .publish(self.subject.clone(), output.unwrap_or_default())
+            .await
+            .map_err(|e| {
+                FlameError::Network(format!("failed to publish <{}>: {e}", self.subject))
+            })?;
+
+        ack.await.map_err(|e| {
+            FlameError::Network(format!("failed to publish <{}>: {e}", self.subject))
+        })?;
+
+        Ok(())
+    }
+}
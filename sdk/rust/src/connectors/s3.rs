@@ -0,0 +1,191 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+use std::path::PathBuf;
+
+use aws_sdk_s3::Client;
+use futures::stream::FuturesUnordered;
+use serde_derive::{Deserialize, Serialize};
+use stdng::trace_fn;
+use tokio_stream::StreamExt;
+
+use crate::apis::{FlameError, TaskInput};
+use crate::client::Session;
+use crate::connectors::manifest::{Manifest, ManifestEntry};
+use crate::connectors::wait_task;
+
+/// The reference to an object, passed to the task as a JSON input; the
+/// service downloads the object by itself.
+#[derive(Clone, Debug, Serialize, Deserialize)]
+pub struct ObjectRef {
+    pub bucket: String,
+    pub key: String,
+    pub size: Option<i64>,
+    pub etag: Option<String>,
+}
+
+impl ObjectRef {
+    pub fn decode(input: &TaskInput) -> Result<Self, FlameError> {
+        serde_json::from_slice(input).map_err(|e| FlameError::Internal(e.to_string()))
+    }
+
+    fn encode(&self) -> Result<TaskInput, FlameError> {
+        let data = serde_json::to_vec(self).map_err(|e| FlameError::Internal(e.to_string()))?;
+        Ok(TaskInput::from(data))
+    }
+}
+
+#[derive(Clone, Debug)]
+pub struct S3DriverConfig {
+    pub bucket: String,
+    pub prefix: String,
+    /// The manifest file to track the completed objects; re-run the driver
+    /// with the same manifest to resume an interrupted run.
+    pub manifest: PathBuf,
+    /// The maximum number of tasks in flight.
+    pub max_inflight: usize,
+}
+
+#[derive(Clone, Debug, Default)]
+pub struct S3DriverReport {
+    pub total: usize,
+    /// The objects succeeded in previous runs.
+    pub skipped: usize,
+    pub succeed: usize,
+    pub failed: Vec<String>,
+}
+
+/// Create one task per object under the prefix of a bucket.
+pub struct S3Driver {
+    client: Client,
+    config: S3DriverConfig,
+}
+
+impl S3Driver {
+    /// Create the driver with the AWS config of the environment.
+    pub async fn new(config: S3DriverConfig) -> Self {
+        let sdk_config = aws_config::load_defaults(aws_config::BehaviorVersion::latest()).await;
+        Self::with_client(Client::new(&sdk_config), config)
+    }
+
+    pub fn with_client(client: Client, config: S3DriverConfig) -> Self {
+        Self { client, config }
+    }
+
+    async fn list_objects(&self) -> Result<Vec<ObjectRef>, FlameError> {
+        let mut objects = vec![];
+
+        let mut pages = self
+            .client
+            .list_objects_v2()
+            .bucket(&self.config.bucket)
+            .prefix(&self.config.prefix)
+            .into_paginator()
+            .send();
+
+        while let Some(page) = pages.next().await {
+            let page = page.map_err(|e| {
+                FlameError::Network(format!(
+                    "failed to list <s3://{}/{}>: {e}",
+                    self.config.bucket, self.config.prefix
+                ))
+            })?;
+
+            for obj in page.contents() {
+                let Some(key) = obj.key() else {
+                    continue;
+                };
+                // Skip the "directory" placeholders.
+                if key.ends_with('/') {
+                    continue;
+                }
+                objects.push(ObjectRef {
+                    bucket: self.config.bucket.clone(),
+                    key: key.to_string(),
+                    size: obj.size(),
+                    etag: obj.e_tag().map(str::to_string),
+                });
+            }
+        }
+
+        Ok(objects)
+    }
+
+    /// Submit a task for each object which was not succeeded in the manifest,
+    /// and wait for all of them.
+    pub async fn run(&self, ssn: &Session) -> Result<S3DriverReport, FlameError> {
+        trace_fn!("S3Driver::run");
+
+        let mut manifest = Manifest::open(&self.config.manifest)?;
+        let objects = self.list_objects().await?;
+
+        let mut report = S3DriverReport {
+            total: objects.len(),
+            ..Default::default()
+        };
+
+        let max_inflight = self.config.max_inflight.max(1);
+        let mut inflight = FuturesUnordered::new();
+        let mut pending = objects
+            .into_iter()
+            .filter(|obj| {
+                let done = manifest.is_succeed(&obj.key);
+                if done {
+                    report.skipped += 1;
+                }
+                !done
+            })
+            .collect::<Vec<_>>()
+            .into_iter();
+
+        loop {
+            while inflight.len() < max_inflight {
+                let Some(obj) = pending.next() else {
+                    break;
+                };
+
+                let task = ssn.create_task(Some(obj.encode()?)).await?;
+                let ssn = ssn.clone();
+                inflight.push(async move {
+                    let task = wait_task(&ssn, task).await;
+                    (obj, task)
+                });
+            }
+
+            let Some((obj, task)) = inflight.next().await else {
+                break;
+            };
+
+            match task {
+                Ok(task) => {
+                    if task.is_succeed() {
+                        report.succeed += 1;
+                    } else {
+                        report.failed.push(obj.key.clone());
+                    }
+                    manifest.record(ManifestEntry {
+                        item: obj.key,
+                        task_id: task.id,
+                        state: task.state,
+                    })?;
+                }
+                Err(e) => {
+                    tracing::warn!("Failed to wait task of <{}>: {e}", obj.key);
+                    report.failed.push(obj.key);
+                }
+            }
+        }
+
+        Ok(report)
+    }
+}
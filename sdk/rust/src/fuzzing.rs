@@ -0,0 +1,81 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//! The entry points of the fuzz targets in `sdk/rust/fuzz`, which are only
+//! built by `cargo fuzz`. They decode the untrusted input as the SDK does;
+//! a malformed input must be an error instead of a panic.
+
+use bytes::Bytes;
+use prost::Message;
+
+use crate::apis::flame::v1 as rpc;
+use crate::apis::{checksum, DataExpr, ExecutorState, FlameError, SessionState, TaskState};
+use crate::client::{Application, Executor, Node, Session, Task};
+use crate::service::{SessionContext, TaskContext};
+
+fn decode<M: Message + Default>(data: &[u8]) -> Result<M, FlameError> {
+    M::decode(data).map_err(|e| FlameError::Internal(e.to_string()))
+}
+
+/// The payload codec of data expressions; the decoded expression must be
+/// encoded again.
+pub fn data_expr(data: &[u8]) {
+    if let Ok(expr) = DataExpr::decode(Bytes::copy_from_slice(data)) {
+        expr.encode()
+            .expect("failed to encode a decoded data expression");
+    }
+}
+
+/// The checksum of payloads, e.g. `xxh3:<hex>`, from the remote peers.
+pub fn checksum(expected: &str, data: &[u8]) {
+    let _ = checksum::verify("payload", Some(data), Some(expected));
+}
+
+/// The messages from the session manager to the clients.
+pub fn frontend_messages(data: &[u8]) {
+    if let Ok(task) = decode::<rpc::Task>(data) {
+        let _ = Task::try_from(&task);
+    }
+    if let Ok(ssn) = decode::<rpc::Session>(data) {
+        let _ = Session::try_from(&ssn);
+    }
+    if let Ok(app) = decode::<rpc::Application>(data) {
+        let _ = Application::try_from(&app);
+    }
+    if let Ok(exe) = decode::<rpc::Executor>(data) {
+        let _ = Executor::try_from(&exe);
+    }
+    if let Ok(node) = decode::<rpc::Node>(data) {
+        let _ = Node::from(&node);
+    }
+}
+
+/// The messages from the executor manager to the services.
+pub fn shim_messages(data: &[u8]) {
+    if let Ok(ctx) = decode::<rpc::SessionContext>(data) {
+        let _ = SessionContext::try_from(ctx);
+    }
+    if let Ok(ctx) = decode::<rpc::TaskContext>(data) {
+        let _ = TaskContext::from(ctx);
+    }
+}
+
+/// The states reported by the remote peers, which may be unknown to this
+/// version of SDK.
+pub fn states(states: &[i32]) {
+    for state in states {
+        let _ = SessionState::try_from(*state);
+        let _ = TaskState::try_from(*state).map(|s| s.is_terminal());
+        let _ = rpc::ExecutorState::try_from(*state).map(ExecutorState::from);
+    }
+}
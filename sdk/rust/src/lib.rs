@@ -13,4 +13,10 @@ limitations under the License.
 
 pub mod apis;
 pub mod client;
+pub mod connectors;
+#[cfg(feature = "service")]
 pub mod service;
+
+#[cfg(all(fuzzing, feature = "service"))]
+#[doc(hidden)]
+pub mod fuzzing;
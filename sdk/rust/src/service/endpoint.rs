@@ -0,0 +1,187 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//! The endpoint which the service listens on. By default, it's the socket
+//! given by the executor in `FLAME_INSTANCE_ENDPOINT`; the runtimes where the
+//! socket or the port must be computed override it by an `EndpointResolver`,
+//! e.g. by a file or mDNS:
+//!
+//! ```ignore
+//! service::run_with(MyService::default(), FileResolver::new("/run/svc/endpoint")).await?;
+//! ```
+//!
+//! The other endpoints are published to the executor by the file in
+//! `FLAME_INSTANCE_ENDPOINT_FILE` once the service is listening, so the
+//! executor connects to it instead of the socket.
+
+use std::fmt;
+use std::net::SocketAddr;
+use std::path::{Path, PathBuf};
+use std::str::FromStr;
+
+use crate::apis::FlameError;
+
+pub(crate) const FLAME_INSTANCE_ENDPOINT: &str = "FLAME_INSTANCE_ENDPOINT";
+pub(crate) const FLAME_INSTANCE_ENDPOINT_FILE: &str = "FLAME_INSTANCE_ENDPOINT_FILE";
+
+/// The endpoint of the service, i.e. `unix://<path>`, `tcp://<host>:<port>`
+/// or the path of the socket.
+#[derive(Clone, Debug, PartialEq, Eq)]
+pub enum ServiceEndpoint {
+    Unix(PathBuf),
+    /// The TCP address, e.g. `127.0.0.1:0` for a port picked by the system.
+    Tcp(SocketAddr),
+}
+
+impl FromStr for ServiceEndpoint {
+    type Err = FlameError;
+
+    fn from_str(endpoint: &str) -> Result<Self, Self::Err> {
+        let endpoint = endpoint.trim();
+        if let Some(addr) = endpoint.strip_prefix("tcp://") {
+            let addr = addr.parse().map_err(|e| {
+                FlameError::InvalidConfig(format!("invalid service endpoint <{endpoint}>: {e}"))
+            })?;
+            return Ok(Self::Tcp(addr));
+        }
+        if let Some(path) = endpoint.strip_prefix("unix://") {
+            return Ok(Self::Unix(PathBuf::from(path)));
+        }
+        if endpoint.is_empty() || endpoint.contains("://") {
+            return Err(FlameError::InvalidConfig(format!(
+                "unsupported service endpoint <{endpoint}>"
+            )));
+        }
+
+        Ok(Self::Unix(PathBuf::from(endpoint)))
+    }
+}
+
+impl fmt::Display for ServiceEndpoint {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        match self {
+            Self::Unix(path) => write!(f, "unix://{}", path.display()),
+            Self::Tcp(addr) => write!(f, "tcp://{addr}"),
+        }
+    }
+}
+
+/// The hook to locate the endpoint of the service before it listens.
+#[tonic::async_trait]
+pub trait EndpointResolver: Send + Sync + 'static {
+    async fn resolve(&self) -> Result<ServiceEndpoint, FlameError>;
+}
+
+/// The endpoint given by the executor in `FLAME_INSTANCE_ENDPOINT`.
+#[derive(Clone, Copy, Debug, Default)]
+pub struct EnvResolver;
+
+#[tonic::async_trait]
+impl EndpointResolver for EnvResolver {
+    async fn resolve(&self) -> Result<ServiceEndpoint, FlameError> {
+        std::env::var(FLAME_INSTANCE_ENDPOINT)
+            .map_err(|_| FlameError::InvalidConfig(format!("{FLAME_INSTANCE_ENDPOINT} not found")))?
+            .parse()
+    }
+}
+
+/// The endpoint in a file written by the runtime, e.g. `tcp://0.0.0.0:7001`.
+#[derive(Clone, Debug)]
+pub struct FileResolver {
+    path: PathBuf,
+}
+
+impl FileResolver {
+    pub fn new(path: impl AsRef<Path>) -> Self {
+        Self {
+            path: path.as_ref().to_path_buf(),
+        }
+    }
+}
+
+#[tonic::async_trait]
+impl EndpointResolver for FileResolver {
+    async fn resolve(&self) -> Result<ServiceEndpoint, FlameError> {
+        tokio::fs::read_to_string(&self.path)
+            .await
+            .map_err(|e| {
+                FlameError::InvalidConfig(format!(
+                    "failed to read the service endpoint from <{}>: {e}",
+                    self.path.display()
+                ))
+            })?
+            .parse()
+    }
+}
+
+/// Publish the endpoint which the service is listening on to the executor,
+/// unless it's the socket given by the executor.
+#[cfg(unix)]
+pub(crate) fn publish(endpoint: &ServiceEndpoint) -> Result<(), FlameError> {
+    if let Ok(default) = std::env::var(FLAME_INSTANCE_ENDPOINT) {
+        if default.parse::<ServiceEndpoint>().ok().as_ref() == Some(endpoint) {
+            return Ok(());
+        }
+    }
+    let Ok(file) = std::env::var(FLAME_INSTANCE_ENDPOINT_FILE) else {
+        tracing::warn!(
+            "The executor does not support the service endpoint <{endpoint}>, \
+             as {FLAME_INSTANCE_ENDPOINT_FILE} is not set"
+        );
+        return Ok(());
+    };
+
+    // Renamed into place, so the executor never reads it partially.
+    let tmp = format!("{file}.tmp");
+    std::fs::write(&tmp, endpoint.to_string())
+        .and_then(|_| std::fs::rename(&tmp, &file))
+        .map_err(|e| {
+            FlameError::Internal(format!(
+                "failed to publish the service endpoint to <{file}>: {e}"
+            ))
+        })
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_service_endpoint() {
+        for (endpoint, expected) in [
+            (
+                "/var/flame/executors/1.sock",
+                ServiceEndpoint::Unix(PathBuf::from("/var/flame/executors/1.sock")),
+            ),
+            (
+                "unix:///tmp/svc.sock",
+                ServiceEndpoint::Unix(PathBuf::from("/tmp/svc.sock")),
+            ),
+            (
+                "tcp://127.0.0.1:7001\n",
+                ServiceEndpoint::Tcp("127.0.0.1:7001".parse().unwrap()),
+            ),
+        ] {
+            let parsed: ServiceEndpoint = endpoint.parse().unwrap();
+            assert_eq!(parsed, expected);
+            assert_eq!(
+                parsed.to_string().parse::<ServiceEndpoint>().unwrap(),
+                expected
+            );
+        }
+
+        assert!("mdns://svc.local".parse::<ServiceEndpoint>().is_err());
+        assert!("tcp://localhost".parse::<ServiceEndpoint>().is_err());
+        assert!("".parse::<ServiceEndpoint>().is_err());
+    }
+}
@@ -0,0 +1,161 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//! The graceful shutdown of the service: once the executor stops it, e.g.
+//! by SIGTERM on its restart, the new tasks are rejected, the in-flight tasks
+//! are waited for up to `FLAME_SERVICE_DRAIN_TIMEOUT` seconds, and the session
+//! is left before the service exits, instead of killing the running tasks.
+
+use std::sync::atomic::{AtomicBool, AtomicUsize, Ordering};
+use std::time::Duration;
+
+use tokio::sync::Notify;
+
+use crate::apis::FlameError;
+
+pub(crate) const FLAME_SERVICE_DRAIN_TIMEOUT: &str = "FLAME_SERVICE_DRAIN_TIMEOUT";
+const DEFAULT_DRAIN_TIMEOUT: Duration = Duration::from_secs(30);
+
+/// The timeout to wait for the in-flight tasks on shutdown.
+pub(crate) fn drain_timeout() -> Result<Duration, FlameError> {
+    match std::env::var(FLAME_SERVICE_DRAIN_TIMEOUT) {
+        Ok(secs) => secs.trim().parse().map(Duration::from_secs).map_err(|e| {
+            FlameError::InvalidConfig(format!(
+                "invalid {FLAME_SERVICE_DRAIN_TIMEOUT} <{secs}>: {e}"
+            ))
+        }),
+        Err(_) => Ok(DEFAULT_DRAIN_TIMEOUT),
+    }
+}
+
+/// The tasks and the session of the service, for the graceful shutdown.
+#[derive(Default)]
+pub(crate) struct Lifecycle {
+    draining: AtomicBool,
+    in_flight: AtomicUsize,
+    idle: Notify,
+    in_session: AtomicBool,
+}
+
+/// An in-flight task, which is completed when it's dropped.
+pub(crate) struct InFlight<'a> {
+    lifecycle: &'a Lifecycle,
+}
+
+impl Drop for InFlight<'_> {
+    fn drop(&mut self) {
+        if self.lifecycle.in_flight.fetch_sub(1, Ordering::SeqCst) == 1 {
+            self.lifecycle.idle.notify_waiters();
+        }
+    }
+}
+
+impl Lifecycle {
+    /// Start a task, unless the service is draining.
+    pub(crate) fn start_task(&self) -> Option<InFlight<'_>> {
+        // Counted before the check, so the drain never misses the task.
+        self.in_flight.fetch_add(1, Ordering::SeqCst);
+        let task = InFlight { lifecycle: self };
+        if self.draining.load(Ordering::SeqCst) {
+            return None;
+        }
+
+        Some(task)
+    }
+
+    pub(crate) fn enter_session(&self) {
+        self.in_session.store(true, Ordering::SeqCst);
+    }
+
+    /// Leave the session, and return whether it was entered, so it's left
+    /// only once by the executor or the shutdown.
+    pub(crate) fn leave_session(&self) -> bool {
+        self.in_session.swap(false, Ordering::SeqCst)
+    }
+
+    /// Reject the new tasks, and wait for the in-flight ones up to the
+    /// timeout; return whether all of them were completed.
+    pub(crate) async fn drain(&self, timeout: Duration) -> bool {
+        self.draining.store(true, Ordering::SeqCst);
+
+        let wait = async {
+            loop {
+                let idle = self.idle.notified();
+                tokio::pin!(idle);
+                idle.as_mut().enable();
+                if self.in_flight.load(Ordering::SeqCst) == 0 {
+                    return;
+                }
+                idle.await;
+            }
+        };
+
+        tokio::time::timeout(timeout, wait).await.is_ok()
+    }
+
+    pub(crate) fn in_flight(&self) -> usize {
+        self.in_flight.load(Ordering::SeqCst)
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use std::sync::Arc;
+
+    use super::*;
+
+    #[tokio::test]
+    async fn test_drain() {
+        let lifecycle = Arc::new(Lifecycle::default());
+        assert!(lifecycle.drain(Duration::from_millis(10)).await);
+
+        let lifecycle = Arc::new(Lifecycle::default());
+        lifecycle.enter_session();
+        let (tx, rx) = tokio::sync::oneshot::channel::<()>();
+        let running = {
+            let lifecycle = lifecycle.clone();
+            tokio::spawn(async move {
+                let _task = lifecycle.start_task().unwrap();
+                let _ = rx.await;
+            })
+        };
+        while lifecycle.in_flight() == 0 {
+            tokio::task::yield_now().await;
+        }
+
+        // The in-flight task is waited for, and the new ones are rejected.
+        let drain = {
+            let lifecycle = lifecycle.clone();
+            tokio::spawn(async move { lifecycle.drain(Duration::from_secs(10)).await })
+        };
+        while !lifecycle.draining.load(Ordering::SeqCst) {
+            tokio::task::yield_now().await;
+        }
+        assert!(lifecycle.start_task().is_none());
+        assert_eq!(lifecycle.in_flight(), 1);
+
+        tx.send(()).unwrap();
+        running.await.unwrap();
+        assert!(drain.await.unwrap());
+
+        assert!(lifecycle.leave_session());
+        assert!(!lifecycle.leave_session());
+    }
+
+    #[tokio::test]
+    async fn test_drain_timeout() {
+        let lifecycle = Lifecycle::default();
+        let _task = lifecycle.start_task().unwrap();
+        assert!(!lifecycle.drain(Duration::from_millis(10)).await);
+    }
+}
@@ -0,0 +1,284 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//! The middlewares around the hooks of the service, e.g. for logging,
+//! metrics, authorization or the validation of the inputs, so they're shared
+//! by the services instead of being re-implemented in each hook, e.g.
+//!
+//! ```ignore
+//! let chain = Chain::new(MyService::default())
+//!     .with(AccessLog)
+//!     .with(RequireGroup("analysts"));
+//! service::run(chain).await?;
+//! ```
+//!
+//! The `before_*` hooks are called in the order of the middlewares, and the
+//! `after_*` hooks in the reverse order. If a `before_*` hook fails, the
+//! service is not called; the error is the result of the hook, which is seen
+//! by the `after_*` hooks of the middlewares before it.
+
+use std::sync::Arc;
+
+use crate::apis::{FlameError, TaskOutput};
+use crate::service::{FlameService, FlameServicePtr, GroupContext, SessionContext, TaskContext};
+
+pub type MiddlewarePtr = Arc<dyn Middleware>;
+
+/// The hooks around the session and the tasks of the service; all of them
+/// do nothing by default.
+#[tonic::async_trait]
+pub trait Middleware: Send + Sync + 'static {
+    /// Before the session is entered; an error rejects the session.
+    async fn before_session_enter(&self, _: &SessionContext) -> Result<(), FlameError> {
+        Ok(())
+    }
+    /// After the session is entered, by the id of the session.
+    async fn after_session_enter(&self, _: &str, _: &Result<(), FlameError>) {}
+    /// Before the task is invoked, e.g. to validate or to decode its input;
+    /// an error fails the task.
+    async fn before_task_invoke(&self, _: &mut TaskContext) -> Result<(), FlameError> {
+        Ok(())
+    }
+    /// After the task is invoked, e.g. to record or to rewrite its result;
+    /// the input is not kept in the context.
+    async fn after_task_invoke(
+        &self,
+        _: &TaskContext,
+        _: &mut Result<Option<TaskOutput>, FlameError>,
+    ) {
+    }
+    /// Before the session is left; an error is returned to the executor
+    /// without leaving the session of the service.
+    async fn before_session_leave(&self) -> Result<(), FlameError> {
+        Ok(())
+    }
+    /// After the session is left.
+    async fn after_session_leave(&self, _: &Result<(), FlameError>) {}
+}
+
+/// The service called through the chain of the middlewares.
+pub struct Chain {
+    service: FlameServicePtr,
+    middlewares: Vec<MiddlewarePtr>,
+}
+
+impl Chain {
+    pub fn new(service: impl FlameService) -> Self {
+        Self {
+            service: Arc::new(service),
+            middlewares: vec![],
+        }
+    }
+
+    /// Append the middleware, which is called inside the earlier ones.
+    pub fn with(mut self, middleware: impl Middleware) -> Self {
+        self.middlewares.push(Arc::new(middleware));
+        self
+    }
+}
+
+impl TaskContext {
+    /// The context of the task without its input, for the `after_*` hooks.
+    fn without_input(&self) -> Self {
+        TaskContext {
+            task_id: self.task_id.clone(),
+            session_id: self.session_id.clone(),
+            input: None,
+            principal: self.principal.clone(),
+            deadline: self.deadline,
+            content_type: self.content_type.clone(),
+            group: self.group.clone(),
+            method: self.method.clone(),
+            priority: self.priority,
+        }
+    }
+}
+
+#[tonic::async_trait]
+impl FlameService for Chain {
+    async fn on_session_enter(&self, ctx: SessionContext) -> Result<(), FlameError> {
+        let session_id = ctx.session_id.clone();
+        let mut entered = 0;
+        let mut res = Ok(());
+        for middleware in &self.middlewares {
+            if let Err(e) = middleware.before_session_enter(&ctx).await {
+                res = Err(e);
+                break;
+            }
+            entered += 1;
+        }
+        if res.is_ok() {
+            res = self.service.on_session_enter(ctx).await;
+        }
+        for middleware in self.middlewares[..entered].iter().rev() {
+            middleware.after_session_enter(&session_id, &res).await;
+        }
+
+        res
+    }
+
+    async fn on_task_invoke(&self, mut ctx: TaskContext) -> Result<Option<TaskOutput>, FlameError> {
+        let mut entered = 0;
+        let mut res = Ok(None);
+        for middleware in &self.middlewares {
+            if let Err(e) = middleware.before_task_invoke(&mut ctx).await {
+                res = Err(e);
+                break;
+            }
+            entered += 1;
+        }
+        let task = ctx.without_input();
+        if res.is_ok() {
+            res = self.service.on_task_invoke(ctx).await;
+        }
+        for middleware in self.middlewares[..entered].iter().rev() {
+            middleware.after_task_invoke(&task, &mut res).await;
+        }
+
+        res
+    }
+
+    async fn on_session_leave(&self) -> Result<(), FlameError> {
+        let mut entered = 0;
+        let mut res = Ok(());
+        for middleware in &self.middlewares {
+            if let Err(e) = middleware.before_session_leave().await {
+                res = Err(e);
+                break;
+            }
+            entered += 1;
+        }
+        if res.is_ok() {
+            res = self.service.on_session_leave().await;
+        }
+        for middleware in self.middlewares[..entered].iter().rev() {
+            middleware.after_session_leave(&res).await;
+        }
+
+        res
+    }
+
+    async fn on_session_reset(&self) -> Result<(), FlameError> {
+        self.service.on_session_reset().await
+    }
+
+    async fn on_group_enter(&self, ctx: GroupContext) -> Result<(), FlameError> {
+        self.service.on_group_enter(ctx).await
+    }
+
+    async fn on_group_leave(&self, ctx: GroupContext) -> Result<(), FlameError> {
+        self.service.on_group_leave(ctx).await
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use std::sync::Mutex;
+
+    use super::*;
+    use crate::apis::TaskInput;
+
+    struct Echo;
+
+    #[tonic::async_trait]
+    impl FlameService for Echo {
+        async fn on_session_enter(&self, _: SessionContext) -> Result<(), FlameError> {
+            Ok(())
+        }
+
+        async fn on_task_invoke(&self, ctx: TaskContext) -> Result<Option<TaskOutput>, FlameError> {
+            Ok(ctx.input)
+        }
+
+        async fn on_session_leave(&self) -> Result<(), FlameError> {
+            Ok(())
+        }
+    }
+
+    /// Record the hooks, and reject the tasks without an input.
+    struct Recorder {
+        name: &'static str,
+        calls: Arc<Mutex<Vec<String>>>,
+    }
+
+    #[tonic::async_trait]
+    impl Middleware for Recorder {
+        async fn before_task_invoke(&self, ctx: &mut TaskContext) -> Result<(), FlameError> {
+            self.calls
+                .lock()
+                .unwrap()
+                .push(format!("{}:before", self.name));
+            if ctx.input.is_none() {
+                return Err(FlameError::InvalidConfig("no input".to_string()));
+            }
+
+            Ok(())
+        }
+
+        async fn after_task_invoke(
+            &self,
+            ctx: &TaskContext,
+            res: &mut Result<Option<TaskOutput>, FlameError>,
+        ) {
+            assert!(ctx.input.is_none());
+            self.calls.lock().unwrap().push(format!(
+                "{}:after:{}:{}",
+                self.name,
+                ctx.task_id,
+                res.is_ok()
+            ));
+        }
+    }
+
+    fn task(input: Option<&str>) -> TaskContext {
+        TaskContext {
+            task_id: "1".to_string(),
+            session_id: "ssn-1".to_string(),
+            input: input.map(TaskInput::from),
+            principal: None,
+            deadline: None,
+            content_type: None,
+            group: None,
+            method: None,
+            priority: None,
+        }
+    }
+
+    #[tokio::test]
+    async fn test_chain_task_invoke() {
+        let calls = Arc::new(Mutex::new(vec![]));
+        let chain = Chain::new(Echo)
+            .with(Recorder {
+                name: "a",
+                calls: calls.clone(),
+            })
+            .with(Recorder {
+                name: "b",
+                calls: calls.clone(),
+            });
+
+        let output = chain.on_task_invoke(task(Some("hello"))).await.unwrap();
+        assert_eq!(output, Some(TaskOutput::from("hello")));
+        assert_eq!(
+            *calls.lock().unwrap(),
+            vec!["a:before", "b:before", "b:after:1:true", "a:after:1:true"]
+        );
+
+        // The task is rejected by the first middleware, so the others and
+        // the service are not called.
+        calls.lock().unwrap().clear();
+        let err = chain.on_task_invoke(task(None)).await;
+        assert!(matches!(err, Err(FlameError::InvalidConfig(_))));
+        assert_eq!(*calls.lock().unwrap(), vec!["a:before"]);
+    }
+}
@@ -11,12 +11,18 @@ See the License for the specific language governing permissions and
 limitations under the License.
 */
 
+use std::collections::HashMap;
 use std::sync::Arc;
+#[cfg(unix)]
+use std::time::Duration;
 
+use chrono::{DateTime, Utc};
+#[cfg(unix)]
+use tokio::net::{TcpListener, UnixListener};
 #[cfg(unix)]
-use tokio::net::UnixListener;
+use tokio::sync::{Notify, Semaphore};
 #[cfg(unix)]
-use tokio_stream::wrappers::UnixListenerStream;
+use tokio_stream::wrappers::{TcpListenerStream, UnixListenerStream};
 #[cfg(unix)]
 use tonic::transport::Server;
 #[cfg(unix)]
@@ -26,10 +32,26 @@ use tonic::{Request, Response, Status};
 use self::rpc::instance_server::{Instance, InstanceServer};
 use crate::apis::flame::v1 as rpc;
 
-use crate::apis::{CommonData, FlameError, TaskInput, TaskOutput};
+use crate::apis::{checksum, CommonData, FlameError, TaskInput, TaskOutput};
+
+pub use self::endpoint::{EndpointResolver, EnvResolver, FileResolver, ServiceEndpoint};
+pub use self::middleware::{Chain, Middleware, MiddlewarePtr};
+pub use self::options::ServiceOptions;
+pub use self::router::Router;
+pub use self::stateful::{Stateful, StatefulService};
+
+#[cfg(unix)]
+use self::lifecycle::Lifecycle;
 
+mod endpoint;
+#[cfg(unix)]
+mod lifecycle;
+mod middleware;
+mod options;
 #[cfg(unix)]
-const FLAME_INSTANCE_ENDPOINT: &str = "FLAME_INSTANCE_ENDPOINT";
+mod recovery;
+mod router;
+mod stateful;
 
 pub struct ApplicationContext {
     pub name: String,
@@ -41,12 +63,74 @@ pub struct SessionContext {
     pub session_id: String,
     pub application: ApplicationContext,
     pub common_data: Option<CommonData>,
+    /// The path of the scratch directory provisioned for the session, if any;
+    /// it's removed when the session leaves.
+    pub scratch_dir: Option<String>,
+    /// The content type of the inputs of the tasks, e.g. `application/json`,
+    /// negotiated with the content types of the application.
+    pub content_type: Option<String>,
 }
 
 pub struct TaskContext {
     pub task_id: String,
     pub session_id: String,
     pub input: Option<TaskInput>,
+    /// The user who submitted the task, if it's authenticated by the proxy of
+    /// the frontend; for the authorization of the service.
+    pub principal: Option<Principal>,
+    /// The deadline of the task, e.g. by the context of the client; the
+    /// service should abort the task early if it can't be completed in time.
+    pub deadline: Option<DateTime<Utc>>,
+    /// The content type of the input, so the service decodes it without
+    /// sniffing the bytes.
+    pub content_type: Option<String>,
+    /// The group of the task in the session, which was entered by
+    /// `on_group_enter` before the task.
+    pub group: Option<String>,
+    /// The method of the service to invoke, e.g. by `create_method_task`;
+    /// it's dispatched by the `Router`.
+    pub method: Option<String>,
+    /// The priority of the session of the task, e.g. boosted by the
+    /// operators; the service may spend more effort on the higher ones.
+    pub priority: Option<u32>,
+}
+
+/// The group of the tasks in a session, e.g. a phase of a pipeline.
+#[derive(Clone, Debug, PartialEq, Eq)]
+pub struct GroupContext {
+    pub session_id: String,
+    pub name: String,
+}
+
+impl TaskContext {
+    /// The remaining time before the deadline, which is zero if the deadline
+    /// is exceeded; None if the task has no deadline.
+    pub fn remaining(&self) -> Option<std::time::Duration> {
+        self.deadline
+            .map(|deadline| (deadline - Utc::now()).to_std().unwrap_or_default())
+    }
+
+    /// Whether the work of `effort` can be completed before the deadline,
+    /// e.g. to reduce the depth of a search when little time remains; it's
+    /// always true if the task has no deadline.
+    pub fn has_time_for(&self, effort: std::time::Duration) -> bool {
+        self.remaining()
+            .map_or(true, |remaining| remaining >= effort)
+    }
+}
+
+/// The identity of the user who submitted a task.
+#[derive(Clone, Debug, Default, PartialEq, Eq)]
+pub struct Principal {
+    pub subject: String,
+    pub groups: Vec<String>,
+    pub claims: HashMap<String, String>,
+}
+
+impl Principal {
+    pub fn in_group(&self, group: &str) -> bool {
+        self.groups.iter().any(|g| g == group)
+    }
 }
 
 #[tonic::async_trait]
@@ -54,6 +138,23 @@ pub trait FlameService: Send + Sync + 'static {
     async fn on_session_enter(&self, _: SessionContext) -> Result<(), FlameError>;
     async fn on_task_invoke(&self, _: TaskContext) -> Result<Option<TaskOutput>, FlameError>;
     async fn on_session_leave(&self) -> Result<(), FlameError>;
+    /// Reset the state of the service after `on_session_leave`, so the
+    /// executor keeps it warm for the next session of the same application;
+    /// the service is restarted for the next session by default.
+    async fn on_session_reset(&self) -> Result<(), FlameError> {
+        Err(FlameError::InvalidState(
+            "the service does not reset its state".to_string(),
+        ))
+    }
+    /// Enter the group before the tasks of the group are invoked, e.g. to
+    /// load the state of a phase; the groups are ignored by default.
+    async fn on_group_enter(&self, _: GroupContext) -> Result<(), FlameError> {
+        Ok(())
+    }
+    /// Leave the group before a task of another group, or `on_session_leave`.
+    async fn on_group_leave(&self, _: GroupContext) -> Result<(), FlameError> {
+        Ok(())
+    }
 }
 
 pub type FlameServicePtr = Arc<dyn FlameService>;
@@ -61,6 +162,9 @@ pub type FlameServicePtr = Arc<dyn FlameService>;
 #[cfg(unix)]
 struct ShimService {
     service: FlameServicePtr,
+    lifecycle: Arc<Lifecycle>,
+    /// The slots of the tasks, if they're limited.
+    slots: Option<Arc<Semaphore>>,
 }
 
 #[cfg(unix)]
@@ -73,10 +177,11 @@ impl Instance for ShimService {
         tracing::debug!("ShimService::on_session_enter");
 
         let req = req.into_inner();
-        let resp = self
-            .service
-            .on_session_enter(SessionContext::from(req))
-            .await;
+        let ctx = SessionContext::try_from(req)?;
+        let resp = self.service.on_session_enter(ctx).await;
+        if resp.is_ok() {
+            self.lifecycle.enter_session();
+        }
 
         match resp {
             Ok(_) => Ok(Response::new(rpc::Result {
@@ -95,19 +200,44 @@ impl Instance for ShimService {
         req: Request<rpc::TaskContext>,
     ) -> Result<Response<rpc::TaskResult>, Status> {
         tracing::debug!("ShimService::on_task_invoke");
+        // The task waits for a slot, which delays its result to the executor.
+        let _permit = match &self.slots {
+            Some(slots) => Some(
+                slots
+                    .clone()
+                    .acquire_owned()
+                    .await
+                    .map_err(|_| Status::unavailable("the service is shutting down"))?,
+            ),
+            None => None,
+        };
+        let Some(_task) = self.lifecycle.start_task() else {
+            return Err(Status::unavailable("the service is shutting down"));
+        };
         let req = req.into_inner();
-        let resp = self.service.on_task_invoke(TaskContext::from(req)).await;
+        let task_id = req.task_id.clone();
+        // A panic of the task fails it, instead of the connection of the
+        // executor.
+        let resp = recovery::catch_panic(
+            &task_id,
+            self.service.on_task_invoke(TaskContext::from(req)),
+        )
+        .await;
 
         match resp {
             Ok(data) => Ok(Response::new(rpc::TaskResult {
                 return_code: 0,
+                checksum: data.as_deref().map(checksum::checksum),
                 output: data.map(|d| d.into()),
                 message: None,
+                output_ref: None,
             })),
             Err(e) => Ok(Response::new(rpc::TaskResult {
                 return_code: -1,
                 output: None,
                 message: Some(e.to_string()),
+                checksum: None,
+                output_ref: None,
             })),
         }
     }
@@ -117,6 +247,13 @@ impl Instance for ShimService {
         _: Request<rpc::EmptyRequest>,
     ) -> Result<Response<rpc::Result>, Status> {
         tracing::debug!("ShimService::on_session_leave");
+        // The session may be left by the shutdown already.
+        if !self.lifecycle.leave_session() {
+            return Ok(Response::new(rpc::Result {
+                return_code: 0,
+                message: None,
+            }));
+        }
         let resp = self.service.on_session_leave().await;
 
         match resp {
@@ -130,29 +267,206 @@ impl Instance for ShimService {
             })),
         }
     }
+
+    async fn on_session_reset(
+        &self,
+        _: Request<rpc::EmptyRequest>,
+    ) -> Result<Response<rpc::Result>, Status> {
+        tracing::debug!("ShimService::on_session_reset");
+        let resp = self.service.on_session_reset().await;
+
+        match resp {
+            Ok(_) => Ok(Response::new(rpc::Result {
+                return_code: 0,
+                message: None,
+            })),
+            Err(e) => Ok(Response::new(rpc::Result {
+                return_code: -1,
+                message: Some(e.to_string()),
+            })),
+        }
+    }
+
+    async fn on_group_enter(
+        &self,
+        req: Request<rpc::GroupContext>,
+    ) -> Result<Response<rpc::Result>, Status> {
+        tracing::debug!("ShimService::on_group_enter");
+        let ctx = GroupContext::from(req.into_inner());
+        let resp = self.service.on_group_enter(ctx).await;
+
+        match resp {
+            Ok(_) => Ok(Response::new(rpc::Result {
+                return_code: 0,
+                message: None,
+            })),
+            Err(e) => Ok(Response::new(rpc::Result {
+                return_code: -1,
+                message: Some(e.to_string()),
+            })),
+        }
+    }
+
+    async fn on_group_leave(
+        &self,
+        req: Request<rpc::GroupContext>,
+    ) -> Result<Response<rpc::Result>, Status> {
+        tracing::debug!("ShimService::on_group_leave");
+        let ctx = GroupContext::from(req.into_inner());
+        let resp = self.service.on_group_leave(ctx).await;
+
+        match resp {
+            Ok(_) => Ok(Response::new(rpc::Result {
+                return_code: 0,
+                message: None,
+            })),
+            Err(e) => Ok(Response::new(rpc::Result {
+                return_code: -1,
+                message: Some(e.to_string()),
+            })),
+        }
+    }
 }
 
-#[cfg(unix)]
+/// Serve the service on the socket given by the executor, until it's stopped
+/// by SIGTERM or Ctrl-C; the new tasks are rejected, the in-flight tasks are
+/// waited for up to `FLAME_SERVICE_DRAIN_TIMEOUT` seconds, and the session is
+/// left before it returns.
 pub async fn run(service: impl FlameService) -> Result<(), Box<dyn std::error::Error>> {
+    run_with(service, EnvResolver).await
+}
+
+/// Serve the service on the endpoint located by the resolver, which is
+/// published to the executor once the service is listening.
+pub async fn run_with(
+    service: impl FlameService,
+    resolver: impl EndpointResolver,
+) -> Result<(), Box<dyn std::error::Error>> {
+    run_with_options(service, resolver, ServiceOptions::default()).await
+}
+
+/// Serve the service by the options, e.g. the max concurrent tasks.
+#[cfg(unix)]
+pub async fn run_with_options(
+    service: impl FlameService,
+    resolver: impl EndpointResolver,
+    options: ServiceOptions,
+) -> Result<(), Box<dyn std::error::Error>> {
+    options.validate()?;
+    let timeout = lifecycle::drain_timeout()?;
+    let service: FlameServicePtr = Arc::new(service);
+    let lifecycle = Arc::new(Lifecycle::default());
     let shim_service = ShimService {
-        service: Arc::new(service),
+        service: service.clone(),
+        lifecycle: lifecycle.clone(),
+        slots: options
+            .max_concurrent_tasks
+            .map(|n| Arc::new(Semaphore::new(n))),
     };
 
-    let endpoint = std::env::var(FLAME_INSTANCE_ENDPOINT)
-        .map_err(|_| FlameError::InvalidConfig("FLAME_INSTANCE_ENDPOINT not found".to_string()))?;
+    let router = Server::builder()
+        // The inputs uploaded in chunks are larger than the default message
+        // limit of gRPC; they're bounded by the session manager.
+        .add_service(InstanceServer::new(shim_service).max_decoding_message_size(usize::MAX));
 
-    let uds_stream = UnixListenerStream::new(UnixListener::bind(endpoint)?);
+    let abandoned = Arc::new(Notify::new());
+    let shutdown = shutdown(service, lifecycle, timeout, abandoned.clone());
+    let mut socket = None;
+    let serve = async {
+        match resolver.resolve().await? {
+            ServiceEndpoint::Unix(path) => {
+                let uds_stream = UnixListenerStream::new(UnixListener::bind(&path)?);
+                socket = Some(path.clone());
+                endpoint::publish(&ServiceEndpoint::Unix(path))?;
+                router
+                    .serve_with_incoming_shutdown(uds_stream, shutdown)
+                    .await?;
+            }
+            ServiceEndpoint::Tcp(addr) => {
+                let listener = TcpListener::bind(addr).await?;
+                // The port picked by the system, if any, is published.
+                endpoint::publish(&ServiceEndpoint::Tcp(listener.local_addr()?))?;
+                router
+                    .serve_with_incoming_shutdown(TcpListenerStream::new(listener), shutdown)
+                    .await?;
+            }
+        }
 
-    Server::builder()
-        .add_service(InstanceServer::new(shim_service))
-        .serve_with_incoming(uds_stream)
-        .await?;
+        Ok::<(), Box<dyn std::error::Error>>(())
+    };
 
-    Ok(())
+    // The server waits for the in-flight tasks, unless they're abandoned.
+    let res = tokio::select! {
+        res = serve => res,
+        _ = abandoned.notified() => Ok(()),
+    };
+    // The socket is not left for the next service of the executor.
+    if let Some(path) = socket {
+        let _ = std::fs::remove_file(path);
+    }
+
+    res
+}
+
+/// Drain the service once it's stopped, then leave the session; the tasks
+/// which are not completed in time are abandoned.
+#[cfg(unix)]
+async fn shutdown(
+    service: FlameServicePtr,
+    lifecycle: Arc<Lifecycle>,
+    timeout: Duration,
+    abandoned: Arc<Notify>,
+) {
+    shutdown_signal().await;
+
+    let drained = lifecycle.drain(timeout).await;
+    if !drained {
+        tracing::warn!(
+            "{} in-flight tasks were not completed in {}s, abandon them",
+            lifecycle.in_flight(),
+            timeout.as_secs()
+        );
+    }
+    if lifecycle.leave_session() {
+        if let Err(e) = service.on_session_leave().await {
+            tracing::warn!("Failed to leave the session on shutdown: {e}");
+        }
+    }
+    if !drained {
+        abandoned.notify_one();
+    }
+}
+
+/// Wait for the service to be stopped, i.e. SIGTERM by the executor or Ctrl-C.
+#[cfg(unix)]
+async fn shutdown_signal() {
+    use tokio::signal::unix::{signal, SignalKind};
+
+    let terminate = async {
+        match signal(SignalKind::terminate()) {
+            Ok(mut sigterm) => {
+                sigterm.recv().await;
+            }
+            Err(e) => {
+                tracing::warn!("Failed to watch SIGTERM: {e}");
+                std::future::pending::<()>().await;
+            }
+        }
+    };
+
+    tokio::select! {
+        _ = terminate => {}
+        _ = tokio::signal::ctrl_c() => {}
+    }
+    tracing::info!("The service is stopping, drain the in-flight tasks");
 }
 
 #[cfg(not(unix))]
-pub async fn run(_service: impl FlameService) -> Result<(), Box<dyn std::error::Error>> {
+pub async fn run_with_options(
+    _service: impl FlameService,
+    _resolver: impl EndpointResolver,
+    _options: ServiceOptions,
+) -> Result<(), Box<dyn std::error::Error>> {
     Err(FlameError::InvalidConfig(
         "Unix domain sockets are not supported on this platform".to_string(),
     )
@@ -169,13 +483,24 @@ impl From<rpc::ApplicationContext> for ApplicationContext {
     }
 }
 
-impl From<rpc::SessionContext> for SessionContext {
-    fn from(ctx: rpc::SessionContext) -> Self {
-        SessionContext {
+impl TryFrom<rpc::SessionContext> for SessionContext {
+    type Error = FlameError;
+
+    fn try_from(ctx: rpc::SessionContext) -> Result<Self, Self::Error> {
+        let application =
+            ctx.application
+                .map(ApplicationContext::from)
+                .ok_or(FlameError::InvalidConfig(
+                    "missing application in session context".to_string(),
+                ))?;
+
+        Ok(SessionContext {
             session_id: ctx.session_id.clone(),
-            application: ctx.application.map(ApplicationContext::from).unwrap(),
+            application,
             common_data: ctx.common_data.map(|data| data.into()),
-        }
+            scratch_dir: ctx.scratch_dir.clone(),
+            content_type: ctx.content_type,
+        })
     }
 }
 
@@ -185,6 +510,56 @@ impl From<rpc::TaskContext> for TaskContext {
             task_id: ctx.task_id.clone(),
             session_id: ctx.session_id.clone(),
             input: ctx.input.map(|data| data.into()),
+            principal: ctx.principal.map(|principal| Principal {
+                subject: principal.subject,
+                groups: principal.groups,
+                claims: principal.claims,
+            }),
+            deadline: ctx.deadline.and_then(DateTime::from_timestamp_millis),
+            content_type: ctx.content_type,
+            group: ctx.group,
+            method: ctx.method,
+            priority: ctx.priority,
+        }
+    }
+}
+
+impl From<rpc::GroupContext> for GroupContext {
+    fn from(ctx: rpc::GroupContext) -> Self {
+        GroupContext {
+            session_id: ctx.session_id,
+            name: ctx.name,
         }
     }
 }
+
+#[cfg(test)]
+mod tests {
+    use std::time::Duration;
+
+    use super::*;
+
+    #[test]
+    fn test_task_context_budget() {
+        let ctx = TaskContext::from(rpc::TaskContext {
+            task_id: "1".to_string(),
+            session_id: "ssn-1".to_string(),
+            priority: Some(10),
+            ..Default::default()
+        });
+        assert_eq!(ctx.priority, Some(10));
+        assert_eq!(ctx.remaining(), None);
+        assert!(ctx.has_time_for(Duration::from_secs(3600)));
+
+        let deadline = Utc::now() + chrono::Duration::seconds(60);
+        let ctx = TaskContext::from(rpc::TaskContext {
+            task_id: "1".to_string(),
+            session_id: "ssn-1".to_string(),
+            deadline: Some(deadline.timestamp_millis()),
+            ..Default::default()
+        });
+        assert_eq!(ctx.priority, None);
+        assert!(ctx.has_time_for(Duration::from_secs(30)));
+        assert!(!ctx.has_time_for(Duration::from_secs(120)));
+    }
+}
@@ -0,0 +1,74 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//! The options of the shim server of the service, e.g.
+//!
+//! ```ignore
+//! let options = ServiceOptions::new().with_max_concurrent_tasks(2);
+//! service::run_with_options(MyService::default(), EnvResolver, options).await?;
+//! ```
+//!
+//! By `max_concurrent_tasks`, the tasks beyond the limit wait for the running
+//! ones instead of being invoked at once, so the executor is pushed back by
+//! the delayed results, e.g. for the services which hold large inputs in
+//! memory.
+
+use crate::apis::FlameError;
+
+/// The options of the shim server; the tasks are not limited by default.
+#[derive(Clone, Debug, Default)]
+pub struct ServiceOptions {
+    pub max_concurrent_tasks: Option<usize>,
+}
+
+impl ServiceOptions {
+    pub fn new() -> Self {
+        Self::default()
+    }
+
+    /// Invoke at most `n` tasks at once; the others wait for a slot.
+    pub fn with_max_concurrent_tasks(mut self, n: usize) -> Self {
+        self.max_concurrent_tasks = Some(n);
+        self
+    }
+
+    pub fn validate(&self) -> Result<(), FlameError> {
+        if self.max_concurrent_tasks == Some(0) {
+            return Err(FlameError::InvalidConfig(
+                "the max concurrent tasks of the service must be positive".to_string(),
+            ));
+        }
+
+        Ok(())
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_service_options() {
+        let options = ServiceOptions::new();
+        assert_eq!(options.max_concurrent_tasks, None);
+        assert!(options.validate().is_ok());
+
+        let options = options.with_max_concurrent_tasks(2);
+        assert_eq!(options.max_concurrent_tasks, Some(2));
+        assert!(options.validate().is_ok());
+        assert!(ServiceOptions::new()
+            .with_max_concurrent_tasks(0)
+            .validate()
+            .is_err());
+    }
+}
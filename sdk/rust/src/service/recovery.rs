@@ -0,0 +1,111 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//! The recovery of the panics of the tasks: a panic in `on_task_invoke` fails
+//! the task by the panic message and its backtrace, instead of dropping the
+//! connection of the executor, so the other tasks of the service go on.
+
+use std::any::Any;
+use std::backtrace::Backtrace;
+use std::cell::RefCell;
+use std::future::Future;
+use std::panic::AssertUnwindSafe;
+use std::sync::Once;
+
+use futures::FutureExt;
+
+use crate::apis::FlameError;
+
+thread_local! {
+    /// The backtrace of the last panic of the thread, by the panic hook.
+    static BACKTRACE: RefCell<Option<String>> = const { RefCell::new(None) };
+}
+
+static HOOK: Once = Once::new();
+
+/// Keep the backtrace of the panics for the failures of the tasks; the panics
+/// are still reported by the earlier hook.
+fn install_hook() {
+    HOOK.call_once(|| {
+        let hook = std::panic::take_hook();
+        std::panic::set_hook(Box::new(move |info| {
+            let backtrace = Backtrace::force_capture().to_string();
+            BACKTRACE.with(|bt| *bt.borrow_mut() = Some(backtrace));
+            hook(info);
+        }));
+    });
+}
+
+fn panic_message(panic: &(dyn Any + Send)) -> String {
+    if let Some(msg) = panic.downcast_ref::<&str>() {
+        return msg.to_string();
+    }
+    if let Some(msg) = panic.downcast_ref::<String>() {
+        return msg.clone();
+    }
+
+    "unknown panic".to_string()
+}
+
+/// Run the task, and fail it if it panics.
+pub(crate) async fn catch_panic<F, T>(task_id: &str, task: F) -> Result<T, FlameError>
+where
+    F: Future<Output = Result<T, FlameError>>,
+{
+    install_hook();
+
+    match AssertUnwindSafe(task).catch_unwind().await {
+        Ok(res) => res,
+        Err(panic) => {
+            let msg = panic_message(panic.as_ref());
+            // The unwinding is caught on the thread of the panic.
+            let backtrace = BACKTRACE.with(|bt| bt.borrow_mut().take());
+            tracing::error!("The task <{task_id}> panicked: {msg}");
+
+            Err(FlameError::Internal(format!(
+                "task <{task_id}> panicked: {msg}\nbacktrace:\n{}",
+                backtrace.unwrap_or_default()
+            )))
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[tokio::test]
+    async fn test_catch_panic() {
+        let res = catch_panic("1", async { Ok::<_, FlameError>(1) }).await;
+        assert_eq!(res.unwrap(), 1);
+
+        let res = catch_panic("1", async {
+            Err::<(), _>(FlameError::NotFound("x".into()))
+        })
+        .await;
+        assert!(matches!(res, Err(FlameError::NotFound(_))));
+
+        let divisor = 0;
+        let res = catch_panic("2", async move {
+            if divisor == 0 {
+                panic!("division by {divisor}");
+            }
+            Ok(())
+        })
+        .await;
+        let Err(FlameError::Internal(msg)) = res else {
+            panic!("the panic is not recovered");
+        };
+        assert!(msg.starts_with("task <2> panicked: division by 0\nbacktrace:\n"));
+    }
+}
@@ -0,0 +1,188 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//! The dispatch of the tasks by their method, so one application serves
+//! several operations without decoding the method from the input, e.g.
+//!
+//! ```ignore
+//! let router = Router::new()
+//!     .handle("resize", |ctx| async move { resize(ctx.input).await })
+//!     .handle("crop", |ctx| async move { crop(ctx.input).await });
+//! service::run(router).await?;
+//!
+//! // The client
+//! session.create_method_task("resize", Some(input)).await?;
+//! ```
+//!
+//! The method is carried by the `flame.io/method` label of the task. The
+//! tasks without a method are invoked on the fallback service if any, which
+//! also enters and leaves the sessions.
+
+use std::collections::HashMap;
+use std::future::Future;
+use std::sync::Arc;
+
+use futures::future::BoxFuture;
+
+use crate::apis::{FlameError, TaskOutput};
+use crate::service::{FlameService, FlameServicePtr, GroupContext, SessionContext, TaskContext};
+
+type Handler = Arc<
+    dyn Fn(TaskContext) -> BoxFuture<'static, Result<Option<TaskOutput>, FlameError>> + Send + Sync,
+>;
+
+/// The service which dispatches the tasks to the handlers of their method.
+#[derive(Default)]
+pub struct Router {
+    handlers: HashMap<String, Handler>,
+    fallback: Option<FlameServicePtr>,
+}
+
+impl Router {
+    pub fn new() -> Self {
+        Self::default()
+    }
+
+    /// The router of the service, which also serves the tasks without a
+    /// method and the sessions.
+    pub fn with_service(service: impl FlameService) -> Self {
+        Self {
+            handlers: HashMap::new(),
+            fallback: Some(Arc::new(service)),
+        }
+    }
+
+    /// Handle the tasks of the method; the earlier handler of the method is
+    /// replaced.
+    pub fn handle<F, Fut>(mut self, method: &str, handler: F) -> Self
+    where
+        F: Fn(TaskContext) -> Fut + Send + Sync + 'static,
+        Fut: Future<Output = Result<Option<TaskOutput>, FlameError>> + Send + 'static,
+    {
+        let handler: Handler = Arc::new(move |ctx| Box::pin(handler(ctx)));
+        self.handlers.insert(method.to_string(), handler);
+        self
+    }
+
+    /// The methods handled by the router.
+    pub fn methods(&self) -> Vec<String> {
+        let mut methods: Vec<String> = self.handlers.keys().cloned().collect();
+        methods.sort();
+        methods
+    }
+}
+
+#[tonic::async_trait]
+impl FlameService for Router {
+    async fn on_session_enter(&self, ctx: SessionContext) -> Result<(), FlameError> {
+        match &self.fallback {
+            Some(service) => service.on_session_enter(ctx).await,
+            None => Ok(()),
+        }
+    }
+
+    async fn on_task_invoke(&self, ctx: TaskContext) -> Result<Option<TaskOutput>, FlameError> {
+        if let Some(method) = &ctx.method {
+            let handler = self.handlers.get(method).ok_or_else(|| {
+                FlameError::NotFound(format!(
+                    "method <{method}> of task <{}> not found",
+                    ctx.task_id
+                ))
+            })?;
+            return handler(ctx).await;
+        }
+
+        match &self.fallback {
+            Some(service) => service.on_task_invoke(ctx).await,
+            None => Err(FlameError::InvalidConfig(format!(
+                "no method of task <{}>, one of {:?}",
+                ctx.task_id,
+                self.methods()
+            ))),
+        }
+    }
+
+    async fn on_session_leave(&self) -> Result<(), FlameError> {
+        match &self.fallback {
+            Some(service) => service.on_session_leave().await,
+            None => Ok(()),
+        }
+    }
+
+    async fn on_session_reset(&self) -> Result<(), FlameError> {
+        // The handlers may keep the state of the session, so the service is
+        // restarted unless the fallback resets it.
+        match &self.fallback {
+            Some(service) => service.on_session_reset().await,
+            None => Err(FlameError::InvalidState(
+                "the service does not reset its state".to_string(),
+            )),
+        }
+    }
+
+    async fn on_group_enter(&self, ctx: GroupContext) -> Result<(), FlameError> {
+        match &self.fallback {
+            Some(service) => service.on_group_enter(ctx).await,
+            None => Ok(()),
+        }
+    }
+
+    async fn on_group_leave(&self, ctx: GroupContext) -> Result<(), FlameError> {
+        match &self.fallback {
+            Some(service) => service.on_group_leave(ctx).await,
+            None => Ok(()),
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::apis::TaskInput;
+
+    fn task(method: Option<&str>) -> TaskContext {
+        TaskContext {
+            task_id: "1".to_string(),
+            session_id: "ssn-1".to_string(),
+            input: Some(TaskInput::from("hello")),
+            principal: None,
+            deadline: None,
+            content_type: None,
+            group: None,
+            method: method.map(str::to_string),
+            priority: None,
+        }
+    }
+
+    #[tokio::test]
+    async fn test_router_dispatch() {
+        let router = Router::new()
+            .handle("echo", |ctx| async move { Ok(ctx.input) })
+            .handle("len", |ctx| async move {
+                let len = ctx.input.map_or(0, |input| input.len());
+                Ok(Some(TaskOutput::from(len.to_string())))
+            });
+        assert_eq!(router.methods(), vec!["echo", "len"]);
+
+        let output = router.on_task_invoke(task(Some("echo"))).await.unwrap();
+        assert_eq!(output, Some(TaskOutput::from("hello")));
+        let output = router.on_task_invoke(task(Some("len"))).await.unwrap();
+        assert_eq!(output, Some(TaskOutput::from("5")));
+
+        let err = router.on_task_invoke(task(Some("resize"))).await;
+        assert!(matches!(err, Err(FlameError::NotFound(_))));
+        let err = router.on_task_invoke(task(None)).await;
+        assert!(matches!(err, Err(FlameError::InvalidConfig(_))));
+        assert!(router.on_session_reset().await.is_err());
+    }
+}
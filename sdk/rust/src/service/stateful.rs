@@ -0,0 +1,221 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//! The services with a state per session: the state is returned by
+//! `on_session_enter`, given to the tasks and `on_session_leave` of the
+//! session, and dropped after the session is left, instead of keeping it in
+//! the fields of the service behind a lock, e.g.
+//!
+//! ```ignore
+//! #[tonic::async_trait]
+//! impl StatefulService for Matrix {
+//!     type State = Vec<f64>;
+//!
+//!     async fn on_session_enter(&self, ctx: SessionContext) -> Result<Vec<f64>, FlameError> {
+//!         decode(ctx.common_data)
+//!     }
+//!
+//!     async fn on_task_invoke(&self, matrix: &Vec<f64>, ctx: TaskContext) -> ... {
+//!         multiply(matrix, ctx.input)
+//!     }
+//!
+//!     async fn on_session_leave(&self, _: &Vec<f64>) -> Result<(), FlameError> {
+//!         Ok(())
+//!     }
+//! }
+//!
+//! service::run(Stateful::new(Matrix)).await?;
+//! ```
+
+use std::sync::Arc;
+
+use stdng::{lock_ptr, new_ptr, MutexPtr};
+
+use crate::apis::{FlameError, TaskOutput};
+use crate::service::{FlameService, GroupContext, SessionContext, TaskContext};
+
+/// The service whose hooks are given the state of the session.
+#[tonic::async_trait]
+pub trait StatefulService: Send + Sync + 'static {
+    type State: Send + Sync + 'static;
+
+    async fn on_session_enter(&self, _: SessionContext) -> Result<Self::State, FlameError>;
+    async fn on_task_invoke(
+        &self,
+        _: &Self::State,
+        _: TaskContext,
+    ) -> Result<Option<TaskOutput>, FlameError>;
+    async fn on_session_leave(&self, _: &Self::State) -> Result<(), FlameError>;
+    /// The state of the session is dropped once it's left, so the service is
+    /// kept warm for the next session by default.
+    async fn on_session_reset(&self) -> Result<(), FlameError> {
+        Ok(())
+    }
+    async fn on_group_enter(&self, _: &Self::State, _: GroupContext) -> Result<(), FlameError> {
+        Ok(())
+    }
+    async fn on_group_leave(&self, _: &Self::State, _: GroupContext) -> Result<(), FlameError> {
+        Ok(())
+    }
+}
+
+/// The service which keeps the state of the entered session for the
+/// stateful service.
+pub struct Stateful<S: StatefulService> {
+    service: S,
+    state: MutexPtr<Option<Arc<S::State>>>,
+}
+
+impl<S: StatefulService> Stateful<S> {
+    pub fn new(service: S) -> Self {
+        Self {
+            service,
+            state: new_ptr(None),
+        }
+    }
+
+    /// The state of the entered session; the lock is not held by the hooks,
+    /// so the tasks of the session run concurrently.
+    fn state(&self) -> Result<Arc<S::State>, FlameError> {
+        lock_ptr!(self.state)?
+            .clone()
+            .ok_or_else(|| FlameError::InvalidState("no session entered".to_string()))
+    }
+}
+
+#[tonic::async_trait]
+impl<S: StatefulService> FlameService for Stateful<S> {
+    async fn on_session_enter(&self, ctx: SessionContext) -> Result<(), FlameError> {
+        let state = self.service.on_session_enter(ctx).await?;
+        *lock_ptr!(self.state)? = Some(Arc::new(state));
+
+        Ok(())
+    }
+
+    async fn on_task_invoke(&self, ctx: TaskContext) -> Result<Option<TaskOutput>, FlameError> {
+        let state = self.state()?;
+        self.service.on_task_invoke(&state, ctx).await
+    }
+
+    async fn on_session_leave(&self) -> Result<(), FlameError> {
+        let state = lock_ptr!(self.state)?
+            .take()
+            .ok_or_else(|| FlameError::InvalidState("no session entered".to_string()))?;
+        self.service.on_session_leave(&state).await
+    }
+
+    async fn on_session_reset(&self) -> Result<(), FlameError> {
+        self.service.on_session_reset().await
+    }
+
+    async fn on_group_enter(&self, ctx: GroupContext) -> Result<(), FlameError> {
+        let state = self.state()?;
+        self.service.on_group_enter(&state, ctx).await
+    }
+
+    async fn on_group_leave(&self, ctx: GroupContext) -> Result<(), FlameError> {
+        let state = self.state()?;
+        self.service.on_group_leave(&state, ctx).await
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use std::sync::atomic::{AtomicUsize, Ordering};
+
+    use super::*;
+    use crate::apis::TaskInput;
+    use crate::service::ApplicationContext;
+
+    /// Count the tasks of each session, and return the count of the session.
+    struct Counter {
+        left: AtomicUsize,
+    }
+
+    #[tonic::async_trait]
+    impl StatefulService for Counter {
+        type State = (String, AtomicUsize);
+
+        async fn on_session_enter(&self, ctx: SessionContext) -> Result<Self::State, FlameError> {
+            Ok((ctx.session_id, AtomicUsize::new(0)))
+        }
+
+        async fn on_task_invoke(
+            &self,
+            state: &Self::State,
+            ctx: TaskContext,
+        ) -> Result<Option<TaskOutput>, FlameError> {
+            assert_eq!(state.0, ctx.session_id);
+            let count = state.1.fetch_add(1, Ordering::SeqCst) + 1;
+            Ok(Some(TaskOutput::from(count.to_string())))
+        }
+
+        async fn on_session_leave(&self, state: &Self::State) -> Result<(), FlameError> {
+            self.left
+                .fetch_add(state.1.load(Ordering::SeqCst), Ordering::SeqCst);
+            Ok(())
+        }
+    }
+
+    fn session(id: &str) -> SessionContext {
+        SessionContext {
+            session_id: id.to_string(),
+            application: ApplicationContext {
+                name: "counter".to_string(),
+                image: None,
+                command: None,
+            },
+            common_data: None,
+            scratch_dir: None,
+            content_type: None,
+        }
+    }
+
+    fn task(session_id: &str) -> TaskContext {
+        TaskContext {
+            task_id: "1".to_string(),
+            session_id: session_id.to_string(),
+            input: Some(TaskInput::from("hello")),
+            principal: None,
+            deadline: None,
+            content_type: None,
+            group: None,
+            method: None,
+            priority: None,
+        }
+    }
+
+    #[tokio::test]
+    async fn test_stateful_service() {
+        let service = Stateful::new(Counter {
+            left: AtomicUsize::new(0),
+        });
+        let err = service.on_task_invoke(task("ssn-1")).await;
+        assert!(matches!(err, Err(FlameError::InvalidState(_))));
+
+        service.on_session_enter(session("ssn-1")).await.unwrap();
+        for count in ["1", "2"] {
+            let output = service.on_task_invoke(task("ssn-1")).await.unwrap();
+            assert_eq!(output, Some(TaskOutput::from(count)));
+        }
+        service.on_session_leave().await.unwrap();
+        assert_eq!(service.service.left.load(Ordering::SeqCst), 2);
+        assert!(service.on_session_leave().await.is_err());
+
+        // The next session starts from a new state.
+        assert!(service.on_session_reset().await.is_ok());
+        service.on_session_enter(session("ssn-2")).await.unwrap();
+        let output = service.on_task_invoke(task("ssn-2")).await.unwrap();
+        assert_eq!(output, Some(TaskOutput::from("1")));
+    }
+}
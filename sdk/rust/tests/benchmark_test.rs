@@ -15,6 +15,9 @@ limitations under the License.
 //!
 //! This test creates 10 concurrent sessions, each running 1000 tasks,
 //! for a total of 10,000 tasks. The benchmark must complete within 10 minutes.
+//!
+//! The burst test creates 100 sessions at once, each running 10 tasks, so the
+//! executors bound in a scheduling cycle are notified to the nodes in batches.
 
 use std::sync::atomic::{AtomicU64, Ordering};
 use std::sync::Arc;
@@ -45,6 +48,11 @@ const TASKS_PER_SESSION: usize = 1000;
 const TOTAL_TASKS: usize = NUM_SESSIONS * TASKS_PER_SESSION;
 const TIMEOUT_SECS: u64 = 600; // 10 minutes
 
+const BURST_SESSIONS: usize = 100;
+const TASKS_PER_BURST_SESSION: usize = 10;
+const BURST_TASKS: usize = BURST_SESSIONS * TASKS_PER_BURST_SESSION;
+const BURST_TIMEOUT_SECS: u64 = 300; // 5 minutes
+
 /// Metrics collector for benchmark
 struct BenchmarkMetrics {
     succeeded: AtomicU64,
@@ -93,25 +101,28 @@ impl TaskInformer for BenchmarkTaskInformer {
 /// Run tasks for a single session
 async fn run_session(
     conn: &flame::client::Connection,
-    session_id: usize,
+    session_id: String,
+    tasks: usize,
     metrics: Arc<BenchmarkMetrics>,
 ) -> Result<(), FlameError> {
     let ssn_attr = SessionAttributes {
-        id: format!("benchmark-ssn-{}", session_id),
+        id: session_id,
         application: FLAME_APP.to_string(),
         slots: 1,
         common_data: None,
         min_instances: 0,
         max_instances: None,
         batch_size: 1,
+        content_type: None,
+        labels: vec![],
     };
 
     let ssn = conn.create_session(&ssn_attr).await?;
     assert_eq!(ssn.state, SessionState::Open);
 
     // Submit all tasks for this session
-    let mut task_handles = Vec::with_capacity(TASKS_PER_SESSION);
-    for _ in 0..TASKS_PER_SESSION {
+    let mut task_handles = Vec::with_capacity(tasks);
+    for _ in 0..tasks {
         let informer = new_ptr(BenchmarkTaskInformer::new(metrics.clone()));
         let handle = ssn.run_task(None, informer);
         task_handles.push(handle);
@@ -148,7 +159,10 @@ async fn benchmark_multi_session_throughput() -> Result<(), FlameError> {
     for session_id in 0..NUM_SESSIONS {
         let conn = conn.clone();
         let metrics = metrics.clone();
-        let handle = tokio::spawn(async move { run_session(&conn, session_id, metrics).await });
+        let handle = tokio::spawn(async move {
+            let id = format!("benchmark-ssn-{}", session_id);
+            run_session(&conn, id, TASKS_PER_SESSION, metrics).await
+        });
         session_handles.push(handle);
     }
 
@@ -190,3 +204,61 @@ async fn benchmark_multi_session_throughput() -> Result<(), FlameError> {
 
     Ok(())
 }
+
+#[tokio::test]
+async fn benchmark_burst_sessions() -> Result<(), FlameError> {
+    tracing_subscriber::fmt::try_init().ok();
+
+    println!("\n============================================================");
+    println!(
+        "BENCHMARK: {} burst sessions × {} tasks = {} total",
+        BURST_SESSIONS, TASKS_PER_BURST_SESSION, BURST_TASKS
+    );
+    println!("============================================================\n");
+
+    let metrics = Arc::new(BenchmarkMetrics::new());
+    let tls_config = FlameClientTls {
+        ca_file: Some(get_ca_cert_path()),
+    };
+    let conn = flame::client::connect_with_tls(FLAME_ADDR, Some(&tls_config)).await?;
+
+    let start = Instant::now();
+
+    // Create all sessions at once, so their executors are bound together
+    let sessions = (0..BURST_SESSIONS).map(|session_id| {
+        let id = format!("benchmark-burst-ssn-{}", session_id);
+        run_session(&conn, id, TASKS_PER_BURST_SESSION, metrics.clone())
+    });
+    try_join_all(sessions).await?;
+
+    let duration = start.elapsed();
+
+    // Report results
+    let succeeded = metrics.succeeded.load(Ordering::Relaxed);
+    let failed = metrics.failed.load(Ordering::Relaxed);
+    let throughput = succeeded as f64 / duration.as_secs_f64();
+
+    println!("\n============================================================");
+    println!("BENCHMARK RESULTS");
+    println!("============================================================");
+    println!("Duration:        {:.2}s", duration.as_secs_f64());
+    println!("Succeeded:       {}/{}", succeeded, BURST_TASKS);
+    println!("Failed:          {}", failed);
+    println!("Throughput:      {:.2} tasks/sec", throughput);
+    println!("============================================================\n");
+
+    // Assertions for CI pass/fail
+    assert_eq!(failed, 0, "Benchmark had {} failed tasks", failed);
+    assert_eq!(
+        succeeded as usize, BURST_TASKS,
+        "Not all tasks succeeded: {}/{}",
+        succeeded, BURST_TASKS
+    );
+    assert!(
+        duration < Duration::from_secs(BURST_TIMEOUT_SECS),
+        "Benchmark exceeded 5 minute timeout: {:.2}s",
+        duration.as_secs_f64()
+    );
+
+    Ok(())
+}
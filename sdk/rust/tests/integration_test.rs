@@ -83,6 +83,8 @@ async fn test_create_session() -> Result<(), FlameError> {
         min_instances: 0,
         max_instances: None,
         batch_size: 1,
+        content_type: None,
+        labels: vec![],
     };
     let ssn = conn.create_session(&ssn_attr).await?;
 
@@ -108,6 +110,8 @@ async fn test_create_multiple_sessions() -> Result<(), FlameError> {
             min_instances: 0,
             max_instances: None,
             batch_size: 1,
+            content_type: None,
+            labels: vec![],
         };
         let ssn = conn.create_session(&ssn_attr).await?;
 
@@ -131,6 +135,8 @@ async fn test_create_session_with_tasks() -> Result<(), FlameError> {
         min_instances: 0,
         max_instances: None,
         batch_size: 1,
+        content_type: None,
+        labels: vec![],
     };
     let ssn = conn.create_session(&ssn_attr).await?;
 
@@ -192,6 +198,8 @@ async fn test_create_multiple_sessions_with_tasks() -> Result<(), FlameError> {
         min_instances: 0,
         max_instances: None,
         batch_size: 1,
+        content_type: None,
+        labels: vec![],
     };
     let ssn_1 = conn.create_session(&ssn_1_attr).await?;
     assert_eq!(ssn_1.state, SessionState::Open);
@@ -204,6 +212,8 @@ async fn test_create_multiple_sessions_with_tasks() -> Result<(), FlameError> {
         min_instances: 0,
         max_instances: None,
         batch_size: 1,
+        content_type: None,
+        labels: vec![],
     };
     let ssn_2 = conn.create_session(&ssn_2_attr).await?;
     assert_eq!(ssn_2.state, SessionState::Open);
@@ -268,6 +278,7 @@ async fn test_application_lifecycle() -> Result<(), FlameError> {
                     input: Some(string_schema.to_string()),
                     output: Some(string_schema.to_string()),
                     common_data: None,
+                    content_types: vec![],
                 }),
                 url: None,
             },
@@ -302,6 +313,84 @@ async fn test_application_lifecycle() -> Result<(), FlameError> {
     Ok(())
 }
 
+#[tokio::test]
+async fn test_apply_application() -> Result<(), FlameError> {
+    let conn = get_connection().await?;
+
+    let name = "my-test-apply-app".to_string();
+    let mut app_attr = ApplicationAttributes {
+        shim: None,
+        image: None,
+        description: Some("The first revision.".to_string()),
+        labels: vec![],
+        command: Some("my-agent".to_string()),
+        arguments: vec![],
+        environments: HashMap::new(),
+        working_directory: None,
+        max_instances: None,
+        delay_release: None,
+        schema: None,
+        url: None,
+    };
+    // Start from the application not registered.
+    let _ = conn.unregister_application(name.clone()).await;
+
+    // The application is registered by the first apply.
+    assert!(conn.apply_application(&name, app_attr.clone()).await?);
+    let app = conn.get_application(&name).await?;
+    assert_eq!(
+        app.attributes.description.as_deref(),
+        Some("The first revision.")
+    );
+
+    // The registered application is updated by the next apply.
+    app_attr.description = Some("The second revision.".to_string());
+    assert!(!conn.apply_application(&name, app_attr).await?);
+    let app = conn.get_application(&name).await?;
+    assert_eq!(
+        app.attributes.description.as_deref(),
+        Some("The second revision.")
+    );
+
+    conn.unregister_application(name).await?;
+
+    Ok(())
+}
+
+#[tokio::test]
+async fn test_attach_session() -> Result<(), FlameError> {
+    let conn = get_connection().await?;
+
+    let ssn_attr = SessionAttributes {
+        id: String::from("ssn-1-test-attach-session"),
+        application: FLAME_DEFAULT_APP.to_string(),
+        slots: 1,
+        common_data: None,
+        min_instances: 0,
+        max_instances: None,
+        batch_size: 1,
+        content_type: None,
+        labels: vec![],
+    };
+    let ssn = conn.create_session(&ssn_attr).await?;
+
+    // The open session is attached by its id, e.g. from another process.
+    let attached = conn.attach_session(&ssn_attr.id).await?;
+    assert_eq!(attached.id, ssn.id);
+    assert_eq!(attached.state, SessionState::Open);
+
+    ssn.close().await?;
+
+    // The closed session is not attached, nor the unknown one.
+    assert!(conn.attach_session(&ssn_attr.id).await.is_err());
+    assert!(conn
+        .attach_session(&"ssn-1-test-attach-unknown".to_string())
+        .await
+        .is_err());
+
+    Ok(())
+}
+
 #[tokio::test]
 async fn test_batch_session() -> Result<(), FlameError> {
     let conn = get_connection().await?;
@@ -314,6 +403,8 @@ async fn test_batch_session() -> Result<(), FlameError> {
         min_instances: 2, // Ensure batch_size executors are allocated
         max_instances: None,
         batch_size: 2,
+        content_type: None,
+        labels: vec![],
     };
     let ssn = conn.create_session(&ssn_attr).await?;
 
@@ -0,0 +1,4 @@
+-- Add the labels of nodes, e.g. the instance type, zone and spot flag from
+-- the cloud metadata, as a JSON object
+
+ALTER TABLE nodes ADD COLUMN info_labels TEXT;
@@ -0,0 +1,4 @@
+-- Add the reference of the task output kept on the host of the executor,
+-- e.g. `file://<host>/<path>`, instead of the output itself
+
+ALTER TABLE tasks ADD COLUMN output_ref TEXT;
@@ -0,0 +1 @@
+ALTER TABLE sessions ADD COLUMN content_type TEXT;
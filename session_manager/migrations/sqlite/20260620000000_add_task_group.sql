@@ -0,0 +1 @@
+ALTER TABLE tasks ADD COLUMN group_name TEXT;
@@ -0,0 +1 @@
+ALTER TABLE tasks ADD COLUMN run_at INTEGER;
@@ -15,23 +15,24 @@ use async_trait::async_trait;
 use chrono::Utc;
 use stdng::{logs::TraceFn, trace_fn};
 use tokio::sync::mpsc;
-use tokio::time::timeout;
 use tokio_stream::wrappers::ReceiverStream;
 use tonic::{Request, Response, Status, Streaming};
 
 use self::rpc::backend_server::Backend;
 use self::rpc::{
-    BindExecutorCompletedRequest, BindExecutorRequest, BindExecutorResponse, CompleteTaskRequest,
-    LaunchTaskRequest, LaunchTaskResponse, RegisterExecutorRequest, RegisterNodeRequest,
-    ReleaseNodeRequest, SyncNodeRequest, SyncNodeResponse, UnbindExecutorCompletedRequest,
-    UnbindExecutorRequest, UnregisterExecutorRequest, WatchNodeRequest, WatchNodeResponse,
+    AnnotateTaskRequest, BindExecutorCompletedRequest, BindExecutorRequest, BindExecutorResponse,
+    CompleteTaskRequest, CompleteTaskResponse, LaunchTaskRequest, LaunchTaskResponse,
+    RegisterExecutorRequest, RegisterNodeRequest, ReleaseNodeRequest, RenewTaskLeaseRequest,
+    SyncNodeRequest, SyncNodeResponse, UnbindExecutorCompletedRequest, UnbindExecutorRequest,
+    UnregisterExecutorRequest, WatchNodeRequest, WatchNodeResponse,
 };
 use ::rpc::flame::v1 as rpc;
 
 use crate::apiserver::Flame;
 use crate::controller::ControllerPtr;
-use crate::model::Executor;
-use common::apis::{ExecutorState, Node, Shim, TaskResult};
+use crate::model::{Exclusion, Executor};
+use common::apis::{checksum, ExecutorState, Node, Shim, Task, TaskGID, TaskID, TaskResult};
+use common::clock::timeout;
 use common::FlameError;
 
 /// Timeout for heartbeat in seconds. If no heartbeat is received within this
@@ -54,6 +55,17 @@ async fn send_ack(tx: &mpsc::Sender<Result<WatchNodeResponse, Status>>) -> bool
     tx.send(Ok(ack)).await.is_ok()
 }
 
+/// The response of a batch of executor updates; a single update is sent as
+/// is, so the executor managers without the batches still get it.
+fn watch_node_response_of(executors: &[Executor]) -> rpc::watch_node_response::Response {
+    match executors {
+        [executor] => rpc::watch_node_response::Response::Executor(rpc::Executor::from(executor)),
+        _ => rpc::watch_node_response::Response::Executors(rpc::ExecutorList {
+            executors: executors.iter().map(rpc::Executor::from).collect(),
+        }),
+    }
+}
+
 /// Handles a heartbeat request from the client.
 /// Updates node status and sends acknowledgement.
 async fn handle_heartbeat(
@@ -204,21 +216,23 @@ impl Backend for Flame {
         // Spawn a task to handle the incoming stream
         tokio::spawn(async move {
             let mut node_name: Option<String> = None;
+            let clock = controller.clock();
 
             loop {
                 let request = match timeout(
+                    clock.as_ref(),
                     std::time::Duration::from_secs(HEARTBEAT_TIMEOUT_SECS),
                     in_stream.message(),
                 )
                 .await
                 {
-                    Ok(Ok(Some(req))) => req,
-                    Ok(Ok(None)) => break, // Stream closed
-                    Ok(Err(e)) => {
+                    Some(Ok(Some(req))) => req,
+                    Some(Ok(None)) => break, // Stream closed
+                    Some(Err(e)) => {
                         tracing::error!("Stream error: {}", e);
                         break;
                     }
-                    Err(_) => {
+                    None => {
                         tracing::warn!(
                             "Heartbeat timeout for node <{:?}>. Closing stream.",
                             node_name
@@ -248,13 +262,9 @@ impl Backend for Flame {
                             let tx_clone = tx_for_queue.clone();
                             let name_clone = name.clone();
                             tokio::spawn(async move {
-                                while let Some(executor) = receiver.recv().await {
+                                while let Some(executors) = receiver.recv().await {
                                     let response = WatchNodeResponse {
-                                        response: Some(
-                                            rpc::watch_node_response::Response::Executor(
-                                                rpc::Executor::from(&executor),
-                                            ),
-                                        ),
+                                        response: Some(watch_node_response_of(&executors)),
                                     };
                                     if tx_clone.send(Ok(response)).await.is_err() {
                                         tracing::debug!(
@@ -363,7 +373,30 @@ impl Backend for Flame {
         trace_fn!("Backend::bind_executor");
         let req = req.into_inner();
         let executor_id = req.executor_id.to_string();
+        let exclusions: Vec<Exclusion> = req
+            .excluded_applications
+            .into_iter()
+            .map(|app| Exclusion {
+                application: app.name,
+                until: chrono::DateTime::from_timestamp_millis(app.until).unwrap_or_default(),
+                crash_looping: app.crash_looping,
+                session_id: app.session_id,
+            })
+            .collect();
+        if !exclusions.is_empty() {
+            tracing::info!(
+                "Applications <{}> are crashing on executor <{executor_id}>, exclude them.",
+                exclusions
+                    .iter()
+                    .map(|app| app.application.as_str())
+                    .collect::<Vec<_>>()
+                    .join(",")
+            );
+        }
 
+        self.controller
+            .exclude_applications(&executor_id, exclusions)
+            .await?;
         let ssn = self
             .controller
             .wait_for_session(executor_id.clone())
@@ -378,10 +411,13 @@ impl Backend for Flame {
             }));
         };
 
-        let app = self
+        let mut app = self
             .controller
             .get_application(ssn.application.clone())
             .await?;
+        // The environments injected by the hooks override the ones of the
+        // application for this session.
+        app.environments.extend(ssn.environments.clone());
         let application = Some(rpc::Application::from(&app));
         let session = Some(rpc::Session::from(&ssn));
 
@@ -426,7 +462,13 @@ impl Backend for Flame {
     ) -> Result<Response<rpc::Result>, Status> {
         trace_fn!("Backend::unbind_executor");
         let req = req.into_inner();
-        self.controller.unbind_executor(req.executor_id).await?;
+        if let Some(reason) = &req.reason {
+            tracing::warn!("Executor <{}> is unbinding: {}", req.executor_id, reason);
+        }
+
+        self.controller
+            .unbind_executor(req.executor_id, req.reason)
+            .await?;
 
         Ok(Response::new(rpc::Result::default()))
     }
@@ -437,6 +479,7 @@ impl Backend for Flame {
     ) -> Result<Response<rpc::Result>, Status> {
         trace_fn!("Backend::unbind_executor_completed");
         let req = req.into_inner();
+
         self.controller
             .unbind_executor_completed(req.executor_id)
             .await?;
@@ -450,44 +493,172 @@ impl Backend for Flame {
     ) -> Result<Response<LaunchTaskResponse>, Status> {
         trace_fn!("Backend::launch_task");
         let req = req.into_inner();
-        let executor_id = req.executor_id.clone();
 
-        let batch_index = self
-            .controller
-            .get_executor(executor_id.clone())
-            .ok()
-            .and_then(|e| e.batch_index);
+        let task = self.controller.launch_task(req.executor_id.clone()).await?;
+        let resp = self.launched(&req.executor_id, task)?;
 
-        let task = self.controller.launch_task(executor_id).await?;
-        if let Some(task) = task {
-            return Ok(Response::new(LaunchTaskResponse {
-                task: Some(rpc::Task::from(&task)),
-                batch_index,
-            }));
-        }
-
-        Ok(Response::new(LaunchTaskResponse {
-            task: None,
-            batch_index,
-        }))
+        Ok(Response::new(resp))
     }
 
     async fn complete_task(
         &self,
         req: Request<CompleteTaskRequest>,
-    ) -> Result<Response<rpc::Result>, Status> {
+    ) -> Result<Response<CompleteTaskResponse>, Status> {
         trace_fn!("Backend::complete_task");
         let req = req.into_inner();
+        let executor_id = req.executor_id.clone();
+
+        if let Some(delay) = self.chaos.as_ref().and_then(|c| c.complete_task_delay()) {
+            tracing::warn!("Chaos: delay CompleteTask of executor <{executor_id}> by {delay:?}");
+            self.controller.clock().sleep(delay).await;
+        }
 
         let task_result = req.task_result.ok_or(FlameError::InvalidState(format!(
             "no task result when completing task in {}",
             req.executor_id.clone()
         )))?;
 
+        checksum::verify(
+            &format!("output of executor <{}>", req.executor_id),
+            task_result.output.as_deref(),
+            task_result.checksum.as_deref(),
+        )?;
+
         self.controller
-            .complete_task(req.executor_id.clone(), TaskResult::from(task_result))
+            .complete_task(
+                req.executor_id.clone(),
+                TaskResult::from(task_result),
+                req.sequence,
+            )
             .await?;
 
+        // The task was completed, so the failure of launching the next one
+        // is not returned; the executor launches it as usual.
+        let next_task = if req.launch_next_task {
+            match self.try_launch_next(&executor_id).await {
+                Ok(next_task) => next_task,
+                Err(e) => {
+                    tracing::warn!(
+                        "Failed to launch the next task for executor <{executor_id}>: {e}"
+                    );
+                    None
+                }
+            }
+        } else {
+            None
+        };
+
+        Ok(Response::new(CompleteTaskResponse {
+            return_code: 0,
+            message: None,
+            next_task,
+        }))
+    }
+
+    async fn renew_task_lease(
+        &self,
+        req: Request<RenewTaskLeaseRequest>,
+    ) -> Result<Response<rpc::Result>, Status> {
+        trace_fn!("Backend::renew_task_lease");
+        let req = req.into_inner();
+        let Some(lease) = self.task_lease else {
+            return Ok(Response::new(rpc::Result::default()));
+        };
+
+        let gid = TaskGID {
+            ssn_id: req.session_id,
+            task_id: req
+                .task_id
+                .parse::<TaskID>()
+                .map_err(|_| Status::invalid_argument("invalid task id"))?,
+        };
+
+        self.controller
+            .renew_task_lease(req.executor_id, gid, lease, req.sequence)?;
+
         Ok(Response::new(rpc::Result::default()))
     }
+
+    async fn annotate_task(
+        &self,
+        req: Request<AnnotateTaskRequest>,
+    ) -> Result<Response<rpc::Result>, Status> {
+        trace_fn!("Backend::annotate_task");
+        let req = req.into_inner();
+        let gid = TaskGID {
+            ssn_id: req.session_id,
+            task_id: req
+                .task_id
+                .parse::<TaskID>()
+                .map_err(|_| Status::invalid_argument("invalid task id"))?,
+        };
+
+        self.controller
+            .annotate_task(req.executor_id, gid, req.annotation)
+            .await?;
+
+        Ok(Response::new(rpc::Result::default()))
+    }
+}
+
+impl Flame {
+    /// The response of the launched task; the lease of the task starts when
+    /// it's launched, and is renewed by the executor until it's completed.
+    fn launched(
+        &self,
+        executor_id: &str,
+        task: Option<Task>,
+    ) -> Result<LaunchTaskResponse, FlameError> {
+        let batch_index = self
+            .controller
+            .get_executor(executor_id.to_string())
+            .ok()
+            .and_then(|e| e.batch_index);
+
+        let Some(task) = task else {
+            return Ok(LaunchTaskResponse {
+                task: None,
+                batch_index,
+                lease_duration: None,
+                sequence: None,
+                priority: None,
+            });
+        };
+
+        let sequence = self.controller.start_launch(executor_id.to_string())?;
+        if let Some(lease) = self.task_lease {
+            self.controller.renew_task_lease(
+                executor_id.to_string(),
+                task.gid(),
+                lease,
+                Some(sequence),
+            )?;
+        }
+        let priority = self.controller.session_priority(&task.ssn_id)?;
+
+        Ok(LaunchTaskResponse {
+            task: Some(rpc::Task::from(&task)),
+            batch_index,
+            lease_duration: self.task_lease.map(|lease| lease.as_secs()),
+            sequence: Some(sequence),
+            priority,
+        })
+    }
+
+    /// Launch the next pending task piggybacked on the completion; `None` if
+    /// there's no pending task right now.
+    async fn try_launch_next(
+        &self,
+        executor_id: &str,
+    ) -> Result<Option<LaunchTaskResponse>, FlameError> {
+        let task = self
+            .controller
+            .try_launch_task(executor_id.to_string())
+            .await?;
+        if task.is_none() {
+            return Ok(None);
+        }
+
+        self.launched(executor_id, task).map(Some)
+    }
 }
@@ -10,33 +10,137 @@ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
 See the License for the specific language governing permissions and
 limitations under the License.
 */
+use std::collections::HashMap;
 use std::path::Path;
 use std::pin::Pin;
+use std::time::Duration;
 
 use async_trait::async_trait;
 use common::apis::{ApplicationAttributes, SessionAttributes};
 use futures::Stream;
 use serde_json::Value;
 use stdng::trace_fn;
-use tokio::sync::mpsc;
-use tokio_stream::wrappers::ReceiverStream;
-use tonic::{Request, Response, Status};
+use tokio::sync::{mpsc, watch};
+use tokio_stream::wrappers::{ReceiverStream, WatchStream};
+use tokio_stream::StreamExt;
+use tonic::metadata::KeyAndValueRef;
+use tonic::{Request, Response, Status, Streaming};
 
 use self::rpc::frontend_server::Frontend;
+use self::rpc::session_event::Event;
 use self::rpc::{
-    ApplicationList, CloseSessionRequest, CreateSessionRequest, CreateTaskRequest,
-    DeleteSessionRequest, DeleteTaskRequest, ExecutorList, GetApplicationRequest, GetNodeRequest,
-    GetNodeResponse, GetSessionRequest, GetTaskRequest, ListApplicationRequest,
-    ListExecutorRequest, ListNodesRequest, ListSessionRequest, ListTaskRequest, NodeList,
-    OpenSessionRequest, RegisterApplicationRequest, Session, SessionList, Task,
-    UnregisterApplicationRequest, UpdateApplicationRequest, WatchTaskRequest,
+    ApplicationList, BoostSessionRequest, CheckSubmissionRequest, CloseSessionRequest,
+    CreateSessionRequest, CreateTaskRequest, CreateTaskResult, CreateTasksRequest,
+    CreateTasksResponse, DeleteSessionRequest, DeleteTaskRequest, ExecutorJournal, ExecutorList,
+    GetApplicationRequest, GetExecutorJournalRequest, GetNodeRequest, GetNodeResponse,
+    GetServerInfoRequest, GetSessionOutputsRequest, GetSessionRequest, GetSessionStatsRequest,
+    GetTaskLineageRequest, GetTaskOutputRequest, GetTaskRequest, GetTimelineRequest,
+    ListApplicationRequest, ListExecutorRequest, ListNodesRequest, ListSessionRequest,
+    ListTaskRequest, NodeList, OpenSessionRequest, OutputOrder, PollTaskRequest,
+    RegisterApplicationRequest, RotateTenantKeyRequest, RunTaskRequest, ServerInfo, Session,
+    SessionList, SessionOutputs, SessionPriority, SessionStats, SubmissionCheck, Task, TaskLineage,
+    TaskOutputChunk, TenantKey, Timeline, UnregisterApplicationRequest, UpdateApplicationRequest,
+    UploadTaskRequest, WaitForGroupRequest, WatchSessionRequest, WatchTaskRequest,
 };
 
 use rpc::flame::v1 as rpc;
 
+use common::ctx::HookOperation;
 use common::{apis, FlameError};
 
 use crate::apiserver::Flame;
+use crate::controller;
+
+/// The default window of the throughput of the session stats in minutes.
+const DEFAULT_STATS_WINDOW_MINUTES: u32 = 5;
+
+/// The maximum tasks created by one CreateTasks; the clients split the
+/// larger batches.
+const MAX_CREATE_TASKS: usize = 1000;
+
+/// The default range of the timeline in seconds, i.e. the last hour.
+const DEFAULT_TIMELINE_SECONDS: i64 = 3600;
+
+/// The maximum size of the input uploaded by UploadTask, i.e. 256MiB; the
+/// inputs are buffered, so at most `MAX_CONCURRENT_UPLOADS` of them.
+const MAX_UPLOAD_INPUT_SIZE: usize = 256 << 20;
+
+/// The size of the chunks of the output streamed by GetTaskOutput.
+const OUTPUT_CHUNK_SIZE: usize = 1024 * 1024;
+
+/// The interval to poll the changes of the session watched by WatchSession.
+const WATCH_SESSION_INTERVAL: Duration = Duration::from_secs(1);
+
+/// The max size of a request, i.e. the default limit of decoding a message.
+const MAX_MESSAGE_SIZE: usize = 4 * 1024 * 1024;
+
+/// The optional features of the frontend, reported by GetServerInfo so the
+/// clients check them instead of the version.
+const SERVER_FEATURES: &[&str] = &[
+    "upload",
+    "output-stream",
+    "watch-session",
+    "poll",
+    "grpc-web",
+    "lineage",
+    "replication",
+    "tenant-keys",
+];
+
+/// The maximum time a PollTask is held, so it's answered before the idle
+/// timeout of the proxies.
+const MAX_POLL_TASK_TIMEOUT: Duration = Duration::from_secs(30);
+
+/// The attributes of the task by its spec and the principal of the request.
+fn task_attributes(
+    task_spec: rpc::TaskSpec,
+    principal: Option<apis::Principal>,
+) -> Result<apis::TaskAttributes, FlameError> {
+    let depends_on = task_spec
+        .depends_on
+        .iter()
+        .map(|id| {
+            id.parse::<apis::TaskID>()
+                .map_err(|_| FlameError::InvalidConfig(format!("invalid parent task id <{id}>")))
+        })
+        .collect::<Result<_, _>>()?;
+
+    Ok(apis::TaskAttributes {
+        input: task_spec.input.map(apis::TaskInput::from),
+        principal,
+        tags: task_spec.tags,
+        deadline: task_spec
+            .deadline
+            .and_then(chrono::DateTime::from_timestamp_millis),
+        group: task_spec.group,
+        labels: task_spec.labels,
+        run_at: task_spec
+            .run_at
+            .and_then(chrono::DateTime::from_timestamp_millis),
+        depends_on,
+        // It's recorded by the controller, not given by the submitter.
+        provenance: None,
+    })
+}
+
+/// The event of WatchSession, seen at `event_time`.
+fn session_event(event: controller::SessionEvent, event_time: i64) -> rpc::SessionEvent {
+    let event = match event {
+        controller::SessionEvent::TaskCreated(id) => Event::TaskCreated(id.to_string()),
+        controller::SessionEvent::TaskCompleted(id) => Event::TaskCompleted(id.to_string()),
+        controller::SessionEvent::TaskFailed(id) => Event::TaskFailed(id.to_string()),
+        controller::SessionEvent::ExecutorBound(id) => Event::ExecutorBound(id),
+        controller::SessionEvent::ExecutorUnbound(id) => Event::ExecutorUnbound(id),
+        controller::SessionEvent::StateChanged(state) => {
+            Event::StateChanged(rpc::SessionState::from(state) as i32)
+        }
+    };
+
+    rpc::SessionEvent {
+        event_time,
+        event: Some(event),
+    }
+}
 
 fn validate_working_directory(working_dir: &Option<String>) -> Result<(), FlameError> {
     if let Some(wd) = working_dir {
@@ -49,22 +153,212 @@ fn validate_working_directory(working_dir: &Option<String>) -> Result<(), FlameE
     Ok(())
 }
 
+/// The principal of the request by the headers of the authenticating proxy;
+/// the headers are ignored unless the proxy is trusted, so the clients can't
+/// claim any principal by themselves.
+fn principal_of<T>(req: &Request<T>, trusted: bool) -> Option<apis::Principal> {
+    if !trusted {
+        return None;
+    }
+
+    apis::Principal::from_headers(req.metadata().iter().filter_map(|kv| match kv {
+        KeyAndValueRef::Ascii(key, value) => Some((key.as_str(), value.to_str().ok()?)),
+        KeyAndValueRef::Binary(..) => None,
+    }))
+}
+
+/// The task returned to the clients, without the principal of its submitter,
+/// which is only for the hooks and the service of the task. The output kept
+/// on the host of the executor is not inlined, but its checksum is, so the
+/// clients on that host verify the local file, and the others stream the
+/// output by `GetTaskOutput`.
+fn task_of(task: &apis::Task) -> Task {
+    let mut task = Task::from(task);
+    if let Some(spec) = task.spec.as_mut() {
+        spec.principal = None;
+        if spec.output_ref.is_some() {
+            spec.output = None;
+        }
+    }
+
+    task
+}
+
+fn tenant_of(principal: Option<&apis::Principal>) -> &str {
+    principal.map_or(apis::principal::DEFAULT_TENANT, apis::Principal::tenant)
+}
+
+/// The chunks of the output, sliced on demand; there's always one chunk
+/// with the checksum, even if the output is empty. Only the chunk with the
+/// checksum is returned if the checksum is `if_none_match`, i.e. the output
+/// is cached by the client.
+fn output_chunks(
+    output: Option<apis::TaskOutput>,
+    if_none_match: Option<&str>,
+) -> impl Iterator<Item = TaskOutputChunk> {
+    let checksum = output.as_deref().map(apis::checksum::checksum);
+    let output = match (&checksum, if_none_match) {
+        (Some(checksum), Some(cached)) if checksum == cached => apis::TaskOutput::default(),
+        _ => output.unwrap_or_default(),
+    };
+    let count = output.len().div_ceil(OUTPUT_CHUNK_SIZE).max(1);
+
+    (0..count).map(move |i| {
+        let start = (i * OUTPUT_CHUNK_SIZE).min(output.len());
+        let end = (start + OUTPUT_CHUNK_SIZE).min(output.len());
+        TaskOutputChunk {
+            checksum: if i == 0 { checksum.clone() } else { None },
+            chunk: output.slice(start..end).to_vec(),
+        }
+    })
+}
+
+impl Flame {
+    /// Whether the task is read by the caller; the tasks of the other tenants
+    /// are hidden if the tenants are isolated.
+    fn readable(&self, principal: Option<&apis::Principal>, task: &apis::Task) -> bool {
+        !self.tenant_isolation || tenant_of(principal) == tenant_of(task.principal.as_ref())
+    }
+
+    fn check_readable(
+        &self,
+        principal: Option<&apis::Principal>,
+        task: &apis::Task,
+    ) -> Result<(), Status> {
+        if !self.readable(principal, task) {
+            return Err(Status::permission_denied(format!(
+                "task <{}> belongs to another tenant",
+                task.gid()
+            )));
+        }
+
+        Ok(())
+    }
+
+    async fn admit_task(
+        &self,
+        ssn_id: &str,
+        input: Option<&[u8]>,
+        principal: Option<&apis::Principal>,
+    ) -> Result<(), FlameError> {
+        if !self.hooks.reviews(HookOperation::SubmitTask) {
+            return Ok(());
+        }
+
+        let ssn = self.controller.get_session(ssn_id.to_string())?;
+        self.hooks
+            .admit_task(
+                ssn_id,
+                &ssn.application,
+                input.map(|i| i.len()).unwrap_or(0),
+                principal,
+            )
+            .await
+    }
+
+    async fn create_task_of(
+        &self,
+        task_spec: rpc::TaskSpec,
+        principal: Option<apis::Principal>,
+    ) -> Result<Task, Status> {
+        let ssn_id = task_spec
+            .session_id
+            .parse::<apis::SessionID>()
+            .map_err(|_| Status::invalid_argument("invalid session id"))?;
+
+        apis::checksum::verify(
+            &format!("input of session <{ssn_id}>"),
+            task_spec.input.as_deref(),
+            task_spec.input_checksum.as_deref(),
+        )?;
+
+        self.admit_task(&ssn_id, task_spec.input.as_deref(), principal.as_ref())
+            .await?;
+
+        self.controller
+            .create_task(ssn_id, task_attributes(task_spec, principal)?)
+            .await
+            .map(|task| task_of(&task))
+            .map_err(Status::from)
+    }
+
+    /// Admit the session by the hooks with the image of its application, and
+    /// negotiate its content type with the content types of the application.
+    async fn admit_session(
+        &self,
+        attr: SessionAttributes,
+        principal: Option<&apis::Principal>,
+    ) -> Result<SessionAttributes, FlameError> {
+        let app = self
+            .controller
+            .get_application(attr.application.clone())
+            .await?;
+        let mut attr = self
+            .hooks
+            .admit_session(attr, app.image.as_deref(), principal)
+            .await?;
+        let schema = app.schema.unwrap_or_default();
+        attr.content_type = schema.negotiate(attr.content_type.as_deref())?;
+
+        Ok(attr)
+    }
+}
+
 #[async_trait]
 impl Frontend for Flame {
     type WatchTaskStream = Pin<Box<dyn Stream<Item = Result<Task, Status>> + Send>>;
+    type WatchSessionStream = Pin<Box<dyn Stream<Item = Result<rpc::SessionEvent, Status>> + Send>>;
     type ListTaskStream = Pin<Box<dyn Stream<Item = Result<Task, Status>> + Send>>;
+    type GetTaskOutputStream = Pin<Box<dyn Stream<Item = Result<TaskOutputChunk, Status>> + Send>>;
 
     async fn list_task(
         &self,
         req: Request<ListTaskRequest>,
     ) -> Result<Response<Self::ListTaskStream>, Status> {
         trace_fn!("Frontend::list_task");
+        let principal = principal_of(&req, self.trust_proxy_headers);
         let req = req.into_inner();
         let ssn_id = req
             .session_id
             .parse::<apis::SessionID>()
             .map_err(|_| Status::invalid_argument("invalid session id"))?;
-        let task_list = self.controller.list_task(ssn_id).map_err(Status::from)?;
+        let filter = controller::TaskFilter {
+            states: req
+                .states
+                .iter()
+                .map(|s| apis::TaskState::try_from(*s))
+                .collect::<Result<_, _>>()
+                .map_err(Status::from)?,
+            created_after: req
+                .created_after
+                .map(|t| {
+                    chrono::DateTime::from_timestamp_millis(t)
+                        .ok_or(Status::invalid_argument("invalid created_after"))
+                })
+                .transpose()?,
+            created_before: req
+                .created_before
+                .map(|t| {
+                    chrono::DateTime::from_timestamp_millis(t)
+                        .ok_or(Status::invalid_argument("invalid created_before"))
+                })
+                .transpose()?,
+            labels: req.labels,
+        };
+        let after = req
+            .page_token
+            .filter(|t| !t.is_empty())
+            .map(|t| {
+                t.parse::<apis::TaskID>()
+                    .map_err(|_| Status::invalid_argument(format!("invalid page token <{t}>")))
+            })
+            .transpose()?;
+
+        let mut task_list = self
+            .controller
+            .list_task_page(ssn_id, &filter, after, req.page_size.map(|n| n as usize))
+            .map_err(Status::from)?;
+        task_list.retain(|task| self.readable(principal.as_ref(), task));
 
         let (tx, rx) = mpsc::channel(128);
 
@@ -74,7 +368,7 @@ impl Frontend for Flame {
                     break;
                 }
 
-                if let Err(e) = tx.send(Result::<_, Status>::Ok(Task::from(&task))).await {
+                if let Err(e) = tx.send(Result::<_, Status>::Ok(task_of(&task))).await {
                     tracing::error!("Failed to send Task <{}>: {e}", task.id);
                 }
             }
@@ -199,7 +493,11 @@ impl Frontend for Flame {
 
         let res = self
             .controller
-            .update_application(req.name, ApplicationAttributes::from(spec))
+            .update_application(
+                req.name,
+                ApplicationAttributes::from(spec),
+                req.resource_version,
+            )
             .await;
 
         match res {
@@ -207,6 +505,9 @@ impl Frontend for Flame {
                 return_code: 0,
                 message: None,
             })),
+            // The conflict is returned as FAILED_PRECONDITION, so the client
+            // can get the application again and retry.
+            Err(e @ FlameError::VersionMismatch(_)) => Err(Status::from(e)),
             Err(e) => Ok(Response::new(rpc::Result {
                 return_code: -1,
                 message: Some(e.to_string()),
@@ -285,6 +586,7 @@ impl Frontend for Flame {
         req: Request<CreateSessionRequest>,
     ) -> Result<Response<Session>, Status> {
         trace_fn!("Frontend::create_session");
+        let principal = principal_of(&req, self.trust_proxy_headers);
         let req = req.into_inner();
         let ssn_spec = req
             .session
@@ -302,8 +604,14 @@ impl Frontend for Flame {
             min_instances: ssn_spec.min_instances,
             max_instances: ssn_spec.max_instances,
             batch_size: ssn_spec.batch_size.max(1),
+            content_type: ssn_spec.content_type,
+            client_host: ssn_spec.client_host,
+            labels: ssn_spec.labels,
+            environments: HashMap::new(),
         };
 
+        let attr = self.admit_session(attr, principal.as_ref()).await?;
+
         tracing::debug!(
             "Creating session with attributes: id={}, application={}, slots={}, min_instances={}, max_instances={:?}",
             attr.id,
@@ -347,6 +655,7 @@ impl Frontend for Flame {
         req: Request<OpenSessionRequest>,
     ) -> Result<Response<rpc::Session>, Status> {
         trace_fn!("Frontend::open_session");
+        let principal = principal_of(&req, self.trust_proxy_headers);
         let req = req.into_inner();
         let ssn_id = req
             .session_id
@@ -354,7 +663,7 @@ impl Frontend for Flame {
             .map_err(|_| Status::invalid_argument("invalid session id"))?;
 
         // Convert optional SessionSpec to SessionAttributes
-        let spec = req.session.map(|ssn_spec| SessionAttributes {
+        let attr = req.session.map(|ssn_spec| SessionAttributes {
             id: ssn_id.clone(),
             application: ssn_spec.application,
             slots: ssn_spec.slots,
@@ -362,7 +671,15 @@ impl Frontend for Flame {
             min_instances: ssn_spec.min_instances,
             max_instances: ssn_spec.max_instances,
             batch_size: ssn_spec.batch_size.max(1),
+            content_type: ssn_spec.content_type,
+            client_host: ssn_spec.client_host,
+            labels: ssn_spec.labels,
+            environments: HashMap::new(),
         });
+        let spec = match attr {
+            Some(attr) => Some(self.admit_session(attr, principal.as_ref()).await?),
+            None => None,
+        };
 
         let ssn = self
             .controller
@@ -379,18 +696,26 @@ impl Frontend for Flame {
         req: Request<CloseSessionRequest>,
     ) -> Result<Response<rpc::Session>, Status> {
         trace_fn!("Frontend::close_session");
+        let req = req.into_inner();
         let ssn_id = req
-            .into_inner()
             .session_id
             .parse::<apis::SessionID>()
             .map_err(|_| Status::invalid_argument("invalid session id"))?;
 
-        let ssn = self
-            .controller
-            .close_session(ssn_id)
-            .await
-            .map(rpc::Session::from)
-            .map_err(Status::from)?;
+        let ssn = match req.drain_timeout {
+            Some(timeout) => {
+                self.controller
+                    .drain_session(ssn_id, req.resource_version, Duration::from_millis(timeout))
+                    .await
+            }
+            None => {
+                self.controller
+                    .close_session(ssn_id, req.resource_version)
+                    .await
+            }
+        }
+        .map(rpc::Session::from)
+        .map_err(Status::from)?;
 
         Ok(Response::new(ssn))
     }
@@ -416,10 +741,40 @@ impl Frontend for Flame {
     }
     async fn list_session(
         &self,
-        _: Request<ListSessionRequest>,
+        req: Request<ListSessionRequest>,
     ) -> Result<Response<SessionList>, Status> {
         trace_fn!("Frontend::list_session");
-        let ssn_list = self.controller.list_session().map_err(Status::from)?;
+        let req = req.into_inner();
+        let filter = controller::SessionFilter {
+            states: req
+                .states
+                .iter()
+                .map(|s| apis::SessionState::try_from(*s))
+                .collect::<Result<_, _>>()
+                .map_err(Status::from)?,
+            application: req.application.filter(|app| !app.is_empty()),
+            created_after: req
+                .created_after
+                .map(|t| {
+                    chrono::DateTime::from_timestamp_millis(t)
+                        .ok_or(Status::invalid_argument("invalid created_after"))
+                })
+                .transpose()?,
+            created_before: req
+                .created_before
+                .map(|t| {
+                    chrono::DateTime::from_timestamp_millis(t)
+                        .ok_or(Status::invalid_argument("invalid created_before"))
+                })
+                .transpose()?,
+            labels: req.labels,
+        };
+        let after = req.page_token.filter(|t| !t.is_empty());
+
+        let ssn_list = self
+            .controller
+            .list_session_page(&filter, after.as_deref(), req.page_size.map(|n| n as usize))
+            .map_err(Status::from)?;
 
         let sessions = ssn_list.iter().map(Session::from).collect();
 
@@ -428,24 +783,218 @@ impl Frontend for Flame {
 
     async fn create_task(&self, req: Request<CreateTaskRequest>) -> Result<Response<Task>, Status> {
         trace_fn!("Frontend::create_task");
+        let principal = principal_of(&req, self.trust_proxy_headers);
         let task_spec = req
             .into_inner()
             .task
             .ok_or(Status::invalid_argument("session spec"))?;
+        let task = self.create_task_of(task_spec, principal).await?;
+
+        Ok(Response::new(task))
+    }
+
+    async fn upload_task(
+        &self,
+        req: Request<Streaming<UploadTaskRequest>>,
+    ) -> Result<Response<Task>, Status> {
+        trace_fn!("Frontend::upload_task");
+        let principal = principal_of(&req, self.trust_proxy_headers);
+        let _permit = self.uploads.clone().try_acquire_owned().map_err(|_| {
+            Status::resource_exhausted("too many uploads of the tasks, retry later")
+        })?;
+        let mut chunks = req.into_inner();
+
+        // The task is admitted by its spec before the input is buffered.
+        let first = chunks
+            .message()
+            .await?
+            .ok_or(Status::invalid_argument("no spec of the task"))?;
+        let mut task_spec = first.task.ok_or(Status::invalid_argument(
+            "the spec of the task must be in the first chunk",
+        ))?;
+        let ssn_id = task_spec
+            .session_id
+            .parse::<apis::SessionID>()
+            .map_err(|_| Status::invalid_argument("invalid session id"))?;
+        self.controller
+            .get_session(ssn_id.clone())
+            .map_err(Status::from)?;
+        self.admit_task(&ssn_id, None, principal.as_ref()).await?;
+
+        let mut input = first.chunk;
+        let mut input_checksum = first.input_checksum.or(task_spec.input_checksum.take());
+        while let Some(req) = chunks.message().await? {
+            if req.task.is_some() {
+                return Err(Status::invalid_argument("duplicated spec of the task"));
+            }
+            if input.len() + req.chunk.len() > MAX_UPLOAD_INPUT_SIZE {
+                return Err(FlameError::QuotaExceeded(format!(
+                    "the input of the task is larger than <{MAX_UPLOAD_INPUT_SIZE}> bytes"
+                ))
+                .into());
+            }
+            input.extend_from_slice(&req.chunk);
+            if req.input_checksum.is_some() {
+                input_checksum = req.input_checksum;
+            }
+        }
+
+        apis::checksum::verify(
+            &format!("input of session <{ssn_id}>"),
+            Some(input.as_slice()),
+            input_checksum.as_deref(),
+        )?;
+        task_spec.input = Some(input);
+        task_spec.input_checksum = input_checksum;
+
+        let task = self
+            .controller
+            .create_task(ssn_id, task_attributes(task_spec, principal)?)
+            .await
+            .map_err(Status::from)?;
+
+        // The input is not sent back to the client.
+        let mut task = task_of(&task);
+        if let Some(spec) = task.spec.as_mut() {
+            spec.input = None;
+        }
+
+        Ok(Response::new(task))
+    }
+    async fn create_tasks(
+        &self,
+        req: Request<CreateTasksRequest>,
+    ) -> Result<Response<CreateTasksResponse>, Status> {
+        trace_fn!("Frontend::create_tasks");
+        let principal = principal_of(&req, self.trust_proxy_headers);
+        let req = req.into_inner();
+        let ssn_id = req
+            .session_id
+            .parse::<apis::SessionID>()
+            .map_err(|_| Status::invalid_argument("invalid session id"))?;
+
+        if req.tasks.len() > MAX_CREATE_TASKS {
+            return Err(Status::invalid_argument(format!(
+                "too many tasks <{}>, at most <{MAX_CREATE_TASKS}>",
+                req.tasks.len()
+            )));
+        }
+
+        // The unknown session fails the whole batch instead of each task.
+        self.controller
+            .get_session(ssn_id.clone())
+            .map_err(Status::from)?;
+
+        let mut results = Vec::with_capacity(req.tasks.len());
+        for task_spec in req.tasks {
+            let task = async {
+                apis::checksum::verify(
+                    &format!("input of session <{ssn_id}>"),
+                    task_spec.input.as_deref(),
+                    task_spec.input_checksum.as_deref(),
+                )?;
+
+                self.admit_task(&ssn_id, task_spec.input.as_deref(), principal.as_ref())
+                    .await?;
+
+                self.controller
+                    .create_task(
+                        ssn_id.clone(),
+                        task_attributes(task_spec, principal.clone())?,
+                    )
+                    .await
+            }
+            .await;
+
+            results.push(match task {
+                Ok(task) => CreateTaskResult {
+                    task: Some(task_of(&task)),
+                    error: None,
+                },
+                Err(e) => CreateTaskResult {
+                    task: None,
+                    error: Some(e.to_string()),
+                },
+            });
+        }
+
+        Ok(Response::new(CreateTasksResponse { results }))
+    }
+    async fn run_task(&self, req: Request<RunTaskRequest>) -> Result<Response<Task>, Status> {
+        trace_fn!("Frontend::run_task");
+        let principal = principal_of(&req, self.trust_proxy_headers);
+        let req = req.into_inner();
+        let task_spec = req.task.ok_or(Status::invalid_argument("task spec"))?;
         let ssn_id = task_spec
             .session_id
             .parse::<apis::SessionID>()
             .map_err(|_| Status::invalid_argument("invalid session id"))?;
 
+        apis::checksum::verify(
+            &format!("input of session <{ssn_id}>"),
+            task_spec.input.as_deref(),
+            task_spec.input_checksum.as_deref(),
+        )?;
+
+        self.admit_task(&ssn_id, task_spec.input.as_deref(), principal.as_ref())
+            .await?;
+
         let task = self
             .controller
-            .create_task(ssn_id, task_spec.input.map(apis::TaskInput::from))
+            .run_task(
+                ssn_id,
+                task_attributes(task_spec, principal)?,
+                req.timeout.map(Duration::from_millis),
+            )
             .await
-            .map(Task::from)
+            .map(|task| task_of(&task))
             .map_err(Status::from)?;
 
         Ok(Response::new(task))
     }
+
+    async fn wait_for_group(
+        &self,
+        req: Request<WaitForGroupRequest>,
+    ) -> Result<Response<SessionStats>, Status> {
+        trace_fn!("Frontend::wait_for_group");
+        let req = req.into_inner();
+        let ssn_id = req
+            .session_id
+            .parse::<apis::SessionID>()
+            .map_err(|_| Status::invalid_argument("invalid session id"))?;
+        if req.group.is_empty() {
+            return Err(Status::invalid_argument("empty group"));
+        }
+
+        // The unknown session is rejected instead of an empty group.
+        self.controller
+            .get_session(ssn_id.clone())
+            .map_err(Status::from)?;
+
+        let stats = self
+            .controller
+            .wait_for_group(
+                ssn_id.clone(),
+                &req.group,
+                req.timeout.map(Duration::from_millis),
+            )
+            .await
+            .map_err(Status::from)?;
+
+        Ok(Response::new(SessionStats {
+            session_id: ssn_id,
+            pending: stats.pending,
+            running: stats.running,
+            succeed: stats.succeed,
+            failed: stats.failed,
+            cancelled: stats.cancelled,
+            throughput: stats.throughput,
+            eta: stats.eta.map(|eta| eta.as_secs()),
+            closed: stats.closed,
+        }))
+    }
+
     async fn delete_task(
         &self,
         _: Request<DeleteTaskRequest>,
@@ -457,6 +1006,7 @@ impl Frontend for Flame {
         &self,
         req: Request<WatchTaskRequest>,
     ) -> Result<Response<Self::WatchTaskStream>, Status> {
+        let principal = principal_of(&req, self.trust_proxy_headers);
         let req = req.into_inner();
         let gid = apis::TaskGID {
             ssn_id: req
@@ -470,18 +1020,38 @@ impl Frontend for Flame {
                 .map_err(|_| Status::invalid_argument("invalid task id"))?,
         };
 
-        let (tx, rx) = mpsc::channel(128);
+        // The watcher receives the snapshot of the task first, so it resumes
+        // from the current state after a reconnection; the watch is
+        // registered before the snapshot, so no change after it is missed.
+        let (snapshot, mut watch) = self
+            .controller
+            .task_watch(gid.clone())
+            .map_err(Status::from)?;
+        self.check_readable(principal.as_ref(), &snapshot)?;
+        let completed = snapshot.is_completed();
+
+        // Only the latest state is kept for the watcher: if it falls behind,
+        // the stale states are replaced by the latest snapshot instead of
+        // being queued.
+        let (tx, rx) = watch::channel(task_of(&snapshot));
 
-        let controller = self.controller.clone();
         tokio::spawn(async move {
+            if completed {
+                return;
+            }
             loop {
-                match controller.watch_task(gid.clone()).await {
+                let task = tokio::select! {
+                    task = watch.changed() => task,
+                    _ = tx.closed() => {
+                        tracing::debug!("The watcher of Task <{gid}> is closed, exit.");
+                        break;
+                    }
+                };
+
+                match task {
                     Ok(task) => {
                         tracing::debug!("Task <{}> state is <{}>", task.id, task.state as i32);
-                        if let Err(e) = tx.send(Result::<_, Status>::Ok(Task::from(&task))).await {
-                            tracing::debug!("Failed to send Task <{gid}>: {e}");
-                            break;
-                        }
+                        tx.send_replace(task_of(&task));
                         if task.is_completed() {
                             tracing::debug!("Task <{}> is completed, exit.", task.id);
                             break;
@@ -495,13 +1065,112 @@ impl Frontend for Flame {
             }
         });
 
-        let output_stream = ReceiverStream::new(rx);
+        let output_stream = WatchStream::new(rx).map(Result::<_, Status>::Ok);
         Ok(Response::new(
             Box::pin(output_stream) as Self::WatchTaskStream
         ))
     }
 
+    async fn poll_task(&self, req: Request<PollTaskRequest>) -> Result<Response<Task>, Status> {
+        trace_fn!("Frontend::poll_task");
+        let principal = principal_of(&req, self.trust_proxy_headers);
+        let req = req.into_inner();
+        let gid = apis::TaskGID {
+            ssn_id: req
+                .session_id
+                .parse::<apis::SessionID>()
+                .map_err(|_| Status::invalid_argument("invalid session id"))?,
+
+            task_id: req
+                .task_id
+                .parse::<apis::TaskID>()
+                .map_err(|_| Status::invalid_argument("invalid task id"))?,
+        };
+        let timeout = req
+            .timeout
+            .map(Duration::from_millis)
+            .unwrap_or(MAX_POLL_TASK_TIMEOUT)
+            .min(MAX_POLL_TASK_TIMEOUT);
+
+        let task = self
+            .controller
+            .get_task(gid.ssn_id.clone(), gid.task_id)
+            .map_err(Status::from)?;
+        self.check_readable(principal.as_ref(), &task)?;
+        if task.is_completed() || task.state as i32 != req.state {
+            return Ok(Response::new(task_of(&task)));
+        }
+
+        // A change right before the watch is returned by the timeout at the
+        // latest, as the current state is returned then.
+        let task =
+            match tokio::time::timeout(timeout, self.controller.watch_task(gid.clone())).await {
+                Ok(task) => task,
+                Err(_) => self.controller.get_task(gid.ssn_id, gid.task_id),
+            }
+            .map_err(Status::from)?;
+
+        Ok(Response::new(task_of(&task)))
+    }
+
+    async fn watch_session(
+        &self,
+        req: Request<WatchSessionRequest>,
+    ) -> Result<Response<Self::WatchSessionStream>, Status> {
+        trace_fn!("Frontend::watch_session");
+        let ssn_id = req
+            .into_inner()
+            .session_id
+            .parse::<apis::SessionID>()
+            .map_err(|_| Status::invalid_argument("invalid session id"))?;
+
+        // The current state of the session is checked here, so an unknown
+        // session fails the call instead of ending the stream.
+        let mut watcher = controller::SessionWatcher::default();
+        let events = self
+            .controller
+            .session_events(ssn_id.clone(), &mut watcher)
+            .map_err(Status::from)?;
+
+        let controller = self.controller.clone();
+        let (tx, rx) = mpsc::channel(128);
+        tokio::spawn(async move {
+            let clock = controller.clock();
+            let mut events = events;
+            loop {
+                let now = clock.utc_now().timestamp_millis();
+                for event in events {
+                    if tx.send(Ok(session_event(event, now))).await.is_err() {
+                        return;
+                    }
+                }
+                if watcher.is_done() {
+                    tracing::debug!("Session <{ssn_id}> is closed, stop watching.");
+                    return;
+                }
+
+                tokio::select! {
+                    _ = clock.sleep(WATCH_SESSION_INTERVAL) => {}
+                    _ = tx.closed() => return,
+                }
+                events = match controller.session_events(ssn_id.clone(), &mut watcher) {
+                    Ok(events) => events,
+                    Err(e) => {
+                        let _ = tx.send(Err(Status::from(e))).await;
+                        return;
+                    }
+                };
+            }
+        });
+
+        let output_stream = ReceiverStream::new(rx);
+        Ok(Response::new(
+            Box::pin(output_stream) as Self::WatchSessionStream
+        ))
+    }
+
     async fn get_task(&self, req: Request<GetTaskRequest>) -> Result<Response<Task>, Status> {
+        let principal = principal_of(&req, self.trust_proxy_headers);
         let req = req.into_inner();
         let ssn_id = req
             .session_id
@@ -516,9 +1185,312 @@ impl Frontend for Flame {
         let task = self
             .controller
             .get_task(ssn_id, task_id)
-            .map(Task::from)
             .map_err(Status::from)?;
+        self.check_readable(principal.as_ref(), &task)?;
 
-        Ok(Response::new(task))
+        Ok(Response::new(task_of(&task)))
+    }
+
+    async fn get_task_lineage(
+        &self,
+        req: Request<GetTaskLineageRequest>,
+    ) -> Result<Response<TaskLineage>, Status> {
+        trace_fn!("Frontend::get_task_lineage");
+        let principal = principal_of(&req, self.trust_proxy_headers);
+        let req = req.into_inner();
+        let ssn_id = req
+            .session_id
+            .parse::<apis::SessionID>()
+            .map_err(|_| Status::invalid_argument("invalid session id"))?;
+        let task_id = req
+            .task_id
+            .parse::<apis::TaskID>()
+            .map_err(|_| Status::invalid_argument("invalid task id"))?;
+
+        let tasks = self
+            .controller
+            .get_task_lineage(ssn_id, task_id)
+            .map_err(Status::from)?;
+
+        Ok(Response::new(TaskLineage {
+            tasks: tasks
+                .iter()
+                .filter(|task| self.readable(principal.as_ref(), task))
+                .map(task_of)
+                .collect(),
+        }))
+    }
+
+    async fn get_task_output(
+        &self,
+        req: Request<GetTaskOutputRequest>,
+    ) -> Result<Response<Self::GetTaskOutputStream>, Status> {
+        trace_fn!("Frontend::get_task_output");
+        let principal = principal_of(&req, self.trust_proxy_headers);
+        let req = req.into_inner();
+        let ssn_id = req
+            .session_id
+            .parse::<apis::SessionID>()
+            .map_err(|_| Status::invalid_argument("invalid session id"))?;
+
+        let task_id = req
+            .task_id
+            .parse::<apis::TaskID>()
+            .map_err(|_| Status::invalid_argument("invalid task id"))?;
+
+        let task = self
+            .controller
+            .get_task(ssn_id, task_id)
+            .map_err(Status::from)?;
+        self.check_readable(principal.as_ref(), &task)?;
+        if !task.is_completed() {
+            return Err(Status::from(FlameError::InvalidState(format!(
+                "task <{}> is not completed",
+                task.id
+            ))));
+        }
+        let output_stream =
+            futures::stream::iter(output_chunks(task.output, req.if_none_match.as_deref()).map(Ok));
+        Ok(Response::new(
+            Box::pin(output_stream) as Self::GetTaskOutputStream
+        ))
+    }
+
+    async fn get_session_outputs(
+        &self,
+        req: Request<GetSessionOutputsRequest>,
+    ) -> Result<Response<SessionOutputs>, Status> {
+        trace_fn!("Frontend::get_session_outputs");
+        let principal = principal_of(&req, self.trust_proxy_headers);
+        let req = req.into_inner();
+        let ssn_id = req
+            .session_id
+            .parse::<apis::SessionID>()
+            .map_err(|_| Status::invalid_argument("invalid session id"))?;
+
+        let order = match OutputOrder::try_from(req.order_by) {
+            Ok(OutputOrder::Submission) => controller::OutputOrder::Submission,
+            Ok(OutputOrder::Completion) => controller::OutputOrder::Completion,
+            Err(_) => return Err(Status::invalid_argument("invalid output order")),
+        };
+
+        let page = self
+            .controller
+            .get_session_outputs(
+                ssn_id,
+                order,
+                req.page_token,
+                req.page_size.map(|n| n as usize),
+            )
+            .map_err(Status::from)?;
+
+        Ok(Response::new(SessionOutputs {
+            tasks: page
+                .tasks
+                .iter()
+                .filter(|task| self.readable(principal.as_ref(), task))
+                .map(task_of)
+                .collect(),
+            next_page_token: page.next_page_token,
+        }))
+    }
+
+    async fn get_session_stats(
+        &self,
+        req: Request<GetSessionStatsRequest>,
+    ) -> Result<Response<SessionStats>, Status> {
+        trace_fn!("Frontend::get_session_stats");
+        let req = req.into_inner();
+        let ssn_id = req
+            .session_id
+            .parse::<apis::SessionID>()
+            .map_err(|_| Status::invalid_argument("invalid session id"))?;
+
+        let window = req
+            .window
+            .filter(|w| *w > 0)
+            .unwrap_or(DEFAULT_STATS_WINDOW_MINUTES);
+
+        let stats = self
+            .controller
+            .get_session_stats(ssn_id.clone(), Duration::from_secs(window as u64 * 60))
+            .map_err(Status::from)?;
+
+        Ok(Response::new(SessionStats {
+            session_id: ssn_id,
+            pending: stats.pending,
+            running: stats.running,
+            succeed: stats.succeed,
+            failed: stats.failed,
+            cancelled: stats.cancelled,
+            throughput: stats.throughput,
+            eta: stats.eta.map(|eta| eta.as_secs()),
+            closed: stats.closed,
+        }))
+    }
+
+    async fn check_submission(
+        &self,
+        req: Request<CheckSubmissionRequest>,
+    ) -> Result<Response<SubmissionCheck>, Status> {
+        trace_fn!("Frontend::check_submission");
+        let req = req.into_inner();
+        let ssn_id = req
+            .session_id
+            .parse::<apis::SessionID>()
+            .map_err(|_| Status::invalid_argument("invalid session id"))?;
+
+        let mut check = self
+            .controller
+            .check_submission(ssn_id, &self.slot)
+            .map_err(Status::from)?;
+        if req.input_size > MAX_UPLOAD_INPUT_SIZE as u64 {
+            check.accepted = false;
+            check.reasons.push(format!(
+                "the input of <{}> bytes is larger than <{MAX_UPLOAD_INPUT_SIZE}> bytes",
+                req.input_size
+            ));
+        }
+
+        Ok(Response::new(SubmissionCheck {
+            accepted: check.accepted,
+            feasible: check.feasible,
+            reasons: check.reasons,
+            max_input_size: MAX_UPLOAD_INPUT_SIZE as u64,
+            idle_slots: check.idle_slots,
+            total_slots: check.total_slots,
+        }))
+    }
+
+    async fn get_executor_journal(
+        &self,
+        req: Request<GetExecutorJournalRequest>,
+    ) -> Result<Response<ExecutorJournal>, Status> {
+        trace_fn!("Frontend::get_executor_journal");
+        let req = req.into_inner();
+
+        let entries = self
+            .journal
+            .entries(&req.executor_id)
+            .into_iter()
+            .map(|entry| rpc::JournalEntry {
+                timestamp: entry.timestamp.timestamp_millis(),
+                method: entry.method.to_string(),
+                kind: entry.kind.to_string(),
+                detail: entry.detail,
+            })
+            .collect();
+
+        Ok(Response::new(ExecutorJournal {
+            executor_id: req.executor_id,
+            enabled: self.journal.is_enabled(),
+            entries,
+        }))
+    }
+
+    async fn get_timeline(
+        &self,
+        req: Request<GetTimelineRequest>,
+    ) -> Result<Response<Timeline>, Status> {
+        trace_fn!("Frontend::get_timeline");
+        let req = req.into_inner();
+
+        let until = match req.until {
+            Some(t) => chrono::DateTime::from_timestamp_millis(t)
+                .ok_or(Status::invalid_argument("invalid until"))?,
+            None => self.controller.clock().utc_now(),
+        };
+        let since = match req.since {
+            Some(t) => chrono::DateTime::from_timestamp_millis(t)
+                .ok_or(Status::invalid_argument("invalid since"))?,
+            None => until - chrono::Duration::seconds(DEFAULT_TIMELINE_SECONDS),
+        };
+
+        let samples = self
+            .timeline
+            .query(since, until)?
+            .into_iter()
+            .map(|sample| rpc::TimelineSample {
+                timestamp: sample.timestamp.timestamp_millis(),
+                pending_tasks: sample.pending_tasks,
+                running_tasks: sample.running_tasks,
+                open_sessions: sample.open_sessions,
+                executors: sample.executors,
+                idle_executors: sample.idle_executors,
+                binds: sample.binds,
+            })
+            .collect();
+
+        Ok(Response::new(Timeline {
+            enabled: self.timeline.is_enabled(),
+            interval: self.timeline.interval(),
+            samples,
+        }))
+    }
+
+    async fn rotate_tenant_key(
+        &self,
+        req: Request<RotateTenantKeyRequest>,
+    ) -> Result<Response<TenantKey>, Status> {
+        trace_fn!("Frontend::rotate_tenant_key");
+        let principal = principal_of(&req, self.trust_proxy_headers);
+        if !principal.as_ref().is_some_and(apis::Principal::is_admin) {
+            return Err(Status::permission_denied(format!(
+                "only the group <{}> rotates the keys of the tenants",
+                apis::principal::ADMIN_GROUP
+            )));
+        }
+        let req = req.into_inner();
+        let version = self.controller.rotate_tenant_key(&req.tenant).await?;
+
+        Ok(Response::new(TenantKey {
+            tenant: req.tenant,
+            version,
+        }))
+    }
+
+    async fn get_server_info(
+        &self,
+        _: Request<GetServerInfoRequest>,
+    ) -> Result<Response<ServerInfo>, Status> {
+        trace_fn!("Frontend::get_server_info");
+
+        Ok(Response::new(ServerInfo {
+            version: env!("CARGO_PKG_VERSION").to_string(),
+            features: SERVER_FEATURES.iter().map(|f| f.to_string()).collect(),
+            max_message_size: MAX_MESSAGE_SIZE as u64,
+            max_input_size: MAX_UPLOAD_INPUT_SIZE as u64,
+            max_create_tasks: MAX_CREATE_TASKS as u32,
+            max_sessions: self.max_sessions.map(|max| max as u64),
+            server_time: chrono::Utc::now().timestamp_millis(),
+        }))
+    }
+
+    async fn boost_session(
+        &self,
+        req: Request<BoostSessionRequest>,
+    ) -> Result<Response<SessionPriority>, Status> {
+        trace_fn!("Frontend::boost_session");
+        // The boosts take the executors of the others, so only the admins
+        // boost the sessions.
+        let Some(principal) =
+            principal_of(&req, self.trust_proxy_headers).filter(apis::Principal::is_admin)
+        else {
+            return Err(Status::permission_denied(format!(
+                "only the group <{}> boosts the sessions",
+                apis::principal::ADMIN_GROUP
+            )));
+        };
+        let req = req.into_inner();
+        let previous = self
+            .controller
+            .boost_session(&req.session_id, req.priority, &principal.subject)
+            .await?;
+
+        Ok(Response::new(SessionPriority {
+            session_id: req.session_id,
+            priority: req.priority,
+            previous,
+        }))
     }
 }
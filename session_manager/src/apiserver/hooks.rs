@@ -0,0 +1,424 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//! The admission and mutation webhooks of the external controllers, so the
+//! platform teams can enforce their policy without forking the session
+//! manager. The hooks are called in order: a review is posted to the hook as
+//! JSON, e.g.
+//!   {"operation": "create_session", "session": {"id": "ssn-1", ...},
+//!    "principal": {"subject": "alice", "groups": ["data"], ...}}
+//! and the hook responds whether it's allowed; a mutating hook may also
+//! respond the patch of the session, e.g.
+//!   {"allowed": true, "session": {"max_instances": 10, "labels": ["team=ml"],
+//!    "environments": {"HTTPS_PROXY": "http://proxy:3128"}}}
+//! which is applied before the next hook; the labels are appended and the
+//! environments are merged into the ones of the application. The hook may
+//! also be a Rego policy evaluated in place, see `policy`.
+
+use std::collections::HashMap;
+use std::time::Duration;
+
+use reqwest::Client;
+use serde_derive::{Deserialize, Serialize};
+
+use common::apis::{Principal, SessionAttributes};
+use common::ctx::{FlameHook, HookBackend, HookFailurePolicy, HookOperation};
+use common::FlameError;
+
+use crate::apiserver::policy::Policy;
+
+#[derive(Debug, Serialize)]
+struct Review<'a> {
+    operation: String,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    session: Option<SessionReview<'a>>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    task: Option<TaskReview<'a>>,
+    /// The user of the operation, if it's authenticated by the proxy.
+    #[serde(skip_serializing_if = "Option::is_none")]
+    principal: Option<&'a Principal>,
+}
+
+#[derive(Debug, Serialize)]
+struct SessionReview<'a> {
+    id: &'a str,
+    application: &'a str,
+    slots: u32,
+    min_instances: u32,
+    max_instances: Option<u32>,
+    batch_size: u32,
+    labels: &'a [String],
+    /// The image of the application, if any.
+    #[serde(skip_serializing_if = "Option::is_none")]
+    image: Option<&'a str>,
+}
+
+#[derive(Debug, Serialize)]
+struct TaskReview<'a> {
+    session_id: &'a str,
+    application: &'a str,
+    input_size: usize,
+}
+
+#[derive(Debug, Default, Deserialize)]
+struct ReviewResponse {
+    allowed: bool,
+    #[serde(default)]
+    message: Option<String>,
+    #[serde(default)]
+    session: Option<SessionPatch>,
+}
+
+/// The mutable fields of the session; the others are kept as is.
+#[derive(Debug, Default, Deserialize)]
+struct SessionPatch {
+    slots: Option<u32>,
+    min_instances: Option<u32>,
+    max_instances: Option<u32>,
+    batch_size: Option<u32>,
+    labels: Option<Vec<String>>,
+    environments: Option<HashMap<String, String>>,
+}
+
+impl SessionPatch {
+    fn apply(self, attr: &mut SessionAttributes) {
+        if let Some(slots) = self.slots {
+            attr.slots = slots;
+        }
+        if let Some(min_instances) = self.min_instances {
+            attr.min_instances = min_instances;
+        }
+        if let Some(max_instances) = self.max_instances {
+            attr.max_instances = Some(max_instances);
+        }
+        if let Some(batch_size) = self.batch_size {
+            attr.batch_size = batch_size.max(1);
+        }
+        for label in self.labels.unwrap_or_default() {
+            if !attr.labels.contains(&label) {
+                attr.labels.push(label);
+            }
+        }
+        attr.environments
+            .extend(self.environments.unwrap_or_default());
+    }
+}
+
+struct Hook {
+    conf: FlameHook,
+    /// The policy is loaded once at start, if the hook is not a webhook.
+    policy: Option<Policy>,
+}
+
+#[derive(Default)]
+pub struct Hooks {
+    client: Client,
+    hooks: Vec<Hook>,
+}
+
+impl Hooks {
+    pub fn new(hooks: Vec<FlameHook>) -> Result<Self, FlameError> {
+        let client = Client::builder()
+            .build()
+            .map_err(|e| FlameError::Internal(format!("failed to build hook client: {e}")))?;
+
+        let hooks = hooks
+            .into_iter()
+            .map(|conf| {
+                let policy = match &conf.backend {
+                    HookBackend::Webhook { .. } => None,
+                    HookBackend::Policy { bundle, query } => {
+                        tracing::info!("Load policy <{bundle}> of hook <{}>.", conf.name);
+                        Some(Policy::load(bundle, query)?)
+                    }
+                };
+                Ok(Hook { conf, policy })
+            })
+            .collect::<Result<Vec<_>, FlameError>>()?;
+
+        Ok(Hooks { client, hooks })
+    }
+
+    /// Whether any hook reviews the operation, so the callers can skip
+    /// preparing the review.
+    pub fn reviews(&self, operation: HookOperation) -> bool {
+        self.hooks.iter().any(|hook| hook.conf.reviews(operation))
+    }
+
+    /// Admit the session of the application by the hooks, and return the
+    /// session mutated by the mutating hooks.
+    pub async fn admit_session(
+        &self,
+        mut attr: SessionAttributes,
+        image: Option<&str>,
+        principal: Option<&Principal>,
+    ) -> Result<SessionAttributes, FlameError> {
+        for hook in self.for_operation(HookOperation::CreateSession) {
+            let review = Review {
+                operation: HookOperation::CreateSession.to_string(),
+                session: Some(SessionReview {
+                    id: &attr.id,
+                    application: &attr.application,
+                    slots: attr.slots,
+                    min_instances: attr.min_instances,
+                    max_instances: attr.max_instances,
+                    batch_size: attr.batch_size,
+                    labels: &attr.labels,
+                    image,
+                }),
+                task: None,
+                principal,
+            };
+
+            let Some(resp) = self.review(hook, &review).await? else {
+                continue;
+            };
+            if !resp.allowed {
+                return Err(rejected(
+                    &hook.conf,
+                    &format!("session <{}>", attr.id),
+                    resp,
+                ));
+            }
+
+            if let Some(patch) = resp.session {
+                if hook.conf.mutating {
+                    tracing::debug!(
+                        "Session <{}> is mutated by hook <{}>.",
+                        attr.id,
+                        hook.conf.name
+                    );
+                    patch.apply(&mut attr);
+                } else {
+                    tracing::warn!(
+                        "Ignore the mutation of session <{}> by validating hook <{}>.",
+                        attr.id,
+                        hook.conf.name
+                    );
+                }
+            }
+        }
+
+        Ok(attr)
+    }
+
+    /// Admit the task of the session by the hooks; the tasks are not mutated.
+    pub async fn admit_task(
+        &self,
+        ssn_id: &str,
+        application: &str,
+        input_size: usize,
+        principal: Option<&Principal>,
+    ) -> Result<(), FlameError> {
+        for hook in self.for_operation(HookOperation::SubmitTask) {
+            let review = Review {
+                operation: HookOperation::SubmitTask.to_string(),
+                session: None,
+                task: Some(TaskReview {
+                    session_id: ssn_id,
+                    application,
+                    input_size,
+                }),
+                principal,
+            };
+
+            let Some(resp) = self.review(hook, &review).await? else {
+                continue;
+            };
+            if !resp.allowed {
+                return Err(rejected(
+                    &hook.conf,
+                    &format!("task of session <{ssn_id}>"),
+                    resp,
+                ));
+            }
+        }
+
+        Ok(())
+    }
+
+    fn for_operation(&self, operation: HookOperation) -> impl Iterator<Item = &Hook> {
+        self.hooks
+            .iter()
+            .filter(move |hook| hook.conf.reviews(operation))
+    }
+
+    /// Review by the webhook or the policy of the hook; None if the hook is
+    /// not available and its failure policy is to ignore it.
+    async fn review(
+        &self,
+        hook: &Hook,
+        review: &Review<'_>,
+    ) -> Result<Option<ReviewResponse>, FlameError> {
+        let body = serde_json::to_string(review)
+            .map_err(|e| FlameError::Internal(format!("failed to encode review: {e}")))?;
+
+        let resp = match (&hook.conf.backend, &hook.policy) {
+            (_, Some(policy)) => evaluate(policy, &body),
+            (HookBackend::Webhook { url }, None) => self.post(url, hook.conf.timeout, body).await,
+            (HookBackend::Policy { bundle, .. }, None) => Err(FlameError::Uninitialized(format!(
+                "policy <{bundle}> is not loaded"
+            ))),
+        };
+
+        let hook = &hook.conf;
+        match resp {
+            Ok(resp) => Ok(Some(resp)),
+            Err(e) if hook.failure_policy == HookFailurePolicy::Ignore => {
+                tracing::warn!("Ignore the failure of hook <{}>: {e}", hook.name);
+                Ok(None)
+            }
+            Err(e) => Err(FlameError::Network(format!(
+                "hook <{}> is not available: {e}",
+                hook.name
+            ))),
+        }
+    }
+
+    async fn post(
+        &self,
+        url: &str,
+        timeout: u64,
+        body: String,
+    ) -> Result<ReviewResponse, FlameError> {
+        let resp = self
+            .client
+            .post(url)
+            .header("Content-Type", "application/json")
+            .timeout(Duration::from_millis(timeout))
+            .body(body)
+            .send()
+            .await
+            .and_then(|resp| resp.error_for_status())
+            .map_err(|e| FlameError::Network(e.to_string()))?
+            .text()
+            .await
+            .map_err(|e| FlameError::Network(e.to_string()))?;
+
+        serde_json::from_str(&resp)
+            .map_err(|e| FlameError::Internal(format!("invalid response of hook: {e}")))
+    }
+}
+
+/// The policy allows the operation if nothing is denied.
+fn evaluate(policy: &Policy, input: &str) -> Result<ReviewResponse, FlameError> {
+    let decision = policy.evaluate(input)?;
+    let session = decision
+        .patch
+        .map(serde_json::from_value)
+        .transpose()
+        .map_err(|e| FlameError::Internal(format!("invalid patch of policy: {e}")))?;
+
+    Ok(ReviewResponse {
+        allowed: decision.deny.is_empty(),
+        message: (!decision.deny.is_empty()).then(|| decision.deny.join("; ")),
+        session,
+    })
+}
+
+fn rejected(hook: &FlameHook, target: &str, resp: ReviewResponse) -> FlameError {
+    let message = resp.message.unwrap_or("no reason".to_string());
+    tracing::info!(
+        "The {target} is rejected by hook <{}>: {message}",
+        hook.name
+    );
+
+    FlameError::PermissionDenied(format!(
+        "{target} is rejected by hook <{}>: {message}",
+        hook.name
+    ))
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn unavailable_hook(failure_policy: HookFailurePolicy) -> FlameHook {
+        FlameHook {
+            name: "unavailable".to_string(),
+            // Nothing listens on the port 1 of the local host.
+            backend: HookBackend::Webhook {
+                url: "http://127.0.0.1:1/admit".to_string(),
+            },
+            operations: vec![HookOperation::CreateSession],
+            mutating: true,
+            timeout: 1000,
+            failure_policy,
+        }
+    }
+
+    fn test_session() -> SessionAttributes {
+        SessionAttributes {
+            id: "ssn-1".to_string(),
+            application: "test-app".to_string(),
+            ..Default::default()
+        }
+    }
+
+    #[test]
+    fn test_apply_session_patch() {
+        let mut attr = test_session();
+        let patch: ReviewResponse = serde_json::from_str(
+            r#"{"allowed": true, "session": {"max_instances": 10, "batch_size": 0,
+                "labels": ["team=ml", "cost=gpu"], "environments": {"HTTPS_PROXY": "proxy"}}}"#,
+        )
+        .unwrap();
+        assert!(patch.allowed);
+
+        attr.labels = vec!["team=ml".to_string()];
+        attr.environments
+            .insert("HTTPS_PROXY".to_string(), "direct".to_string());
+        patch.session.unwrap().apply(&mut attr);
+        assert_eq!(attr.max_instances, Some(10));
+        assert_eq!(attr.batch_size, 1);
+        assert_eq!(attr.labels, vec!["team=ml", "cost=gpu"]);
+        assert_eq!(attr.environments["HTTPS_PROXY"], "proxy");
+        assert_eq!(attr.slots, 1);
+        assert_eq!(attr.application, "test-app");
+
+        let resp: ReviewResponse =
+            serde_json::from_str(r#"{"allowed": false, "message": "no cost tag"}"#).unwrap();
+        assert!(!resp.allowed);
+        assert!(resp.session.is_none());
+        let err = rejected(
+            &unavailable_hook(HookFailurePolicy::Fail),
+            "session <ssn-1>",
+            resp,
+        );
+        assert!(matches!(err, FlameError::PermissionDenied(_)));
+    }
+
+    #[tokio::test]
+    async fn test_failure_policy() {
+        let hooks = Hooks::new(vec![unavailable_hook(HookFailurePolicy::Ignore)]).unwrap();
+        assert!(hooks.reviews(HookOperation::CreateSession));
+        assert!(!hooks.reviews(HookOperation::SubmitTask));
+        let attr = hooks
+            .admit_session(test_session(), None, None)
+            .await
+            .unwrap();
+        assert_eq!(attr.id, "ssn-1");
+
+        // The tasks are not reviewed by the hook.
+        hooks
+            .admit_task("ssn-1", "test-app", 0, None)
+            .await
+            .unwrap();
+
+        let hooks = Hooks::new(vec![unavailable_hook(HookFailurePolicy::Fail)]).unwrap();
+        assert!(hooks
+            .admit_session(test_session(), None, None)
+            .await
+            .is_err());
+    }
+}
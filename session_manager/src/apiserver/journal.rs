@@ -0,0 +1,574 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//! The opt-in journal of the backend RPCs, kept in a bounded ring per
+//! executor: each RPC is recorded by the `JournalLayer` of the backend when
+//! it's received and when it returns, so a binding which never returns, e.g.
+//! stuck in `BindExecutor`, is visible as a request without response. The
+//! journal is in memory only; it's dumped by
+//! `flmctl debug executor <id> --journal`.
+
+use std::collections::{HashMap, VecDeque};
+use std::convert::Infallible;
+use std::sync::{Arc, Mutex};
+use std::task::{Context, Poll};
+
+use bytes::Bytes;
+use chrono::{DateTime, Utc};
+use futures::future::BoxFuture;
+use http_body::Frame;
+use http_body_util::{BodyExt, Full, StreamBody};
+use prost::Message;
+use tonic::body::BoxBody;
+use tonic::{Code, Status};
+use tower::{Layer, Service};
+
+use ::rpc::flame::v1::{
+    AnnotateTaskRequest, BindExecutorCompletedRequest, BindExecutorRequest, BindExecutorResponse,
+    CompleteTaskRequest, CompleteTaskResponse, LaunchTaskRequest, LaunchTaskResponse,
+    RegisterExecutorRequest, RenewTaskLeaseRequest, UnbindExecutorCompletedRequest,
+    UnbindExecutorRequest, UnregisterExecutorRequest,
+};
+use common::apis::ExecutorID;
+use common::ctx::FlameJournal;
+
+type BoxError = Box<dyn std::error::Error + Send + Sync>;
+
+#[derive(Clone, Copy, Debug, PartialEq, Eq, strum_macros::Display)]
+pub enum EntryKind {
+    #[strum(serialize = "request")]
+    Request,
+    #[strum(serialize = "response")]
+    Response,
+    #[strum(serialize = "error")]
+    Error,
+}
+
+#[derive(Clone, Debug)]
+pub struct JournalEntry {
+    pub timestamp: DateTime<Utc>,
+    pub method: &'static str,
+    pub kind: EntryKind,
+    pub detail: String,
+}
+
+pub struct Journal {
+    conf: Option<FlameJournal>,
+    executors: Mutex<HashMap<ExecutorID, VecDeque<JournalEntry>>>,
+}
+
+pub type JournalPtr = Arc<Journal>;
+
+pub fn new_ptr(conf: Option<FlameJournal>) -> JournalPtr {
+    Arc::new(Journal {
+        conf,
+        executors: Mutex::new(HashMap::new()),
+    })
+}
+
+impl Journal {
+    pub fn is_enabled(&self) -> bool {
+        self.conf.is_some()
+    }
+
+    /// Record the request of the executor; the detail is only built if the
+    /// journal is enabled.
+    pub fn request<F>(&self, executor_id: &str, method: &'static str, detail: F)
+    where
+        F: FnOnce() -> String,
+    {
+        if self.is_enabled() {
+            self.record(executor_id, method, EntryKind::Request, detail());
+        }
+    }
+
+    /// Record the result of the request, described by `detail` if succeeded.
+    pub fn result<T, F>(
+        &self,
+        executor_id: &str,
+        method: &'static str,
+        result: &Result<T, Status>,
+        detail: F,
+    ) where
+        F: FnOnce(&T) -> String,
+    {
+        if !self.is_enabled() {
+            return;
+        }
+
+        match result {
+            Ok(resp) => self.record(executor_id, method, EntryKind::Response, detail(resp)),
+            Err(status) => self.record(
+                executor_id,
+                method,
+                EntryKind::Error,
+                format!("{:?}: {}", status.code(), status.message()),
+            ),
+        }
+    }
+
+    /// The entries of the executor, from the oldest to the latest.
+    pub fn entries(&self, executor_id: &str) -> Vec<JournalEntry> {
+        let Ok(executors) = self.executors.lock() else {
+            return vec![];
+        };
+
+        executors
+            .get(executor_id)
+            .map(|entries| entries.iter().cloned().collect())
+            .unwrap_or_default()
+    }
+
+    fn record(&self, executor_id: &str, method: &'static str, kind: EntryKind, detail: String) {
+        let Some(conf) = &self.conf else {
+            return;
+        };
+        // The journal is best-effort, it never fails the RPCs.
+        let Ok(mut executors) = self.executors.lock() else {
+            return;
+        };
+
+        if !executors.contains_key(executor_id) && executors.len() >= conf.executors {
+            let oldest = executors
+                .iter()
+                .min_by_key(|(_, entries)| entries.back().map(|entry| entry.timestamp))
+                .map(|(id, _)| id.clone());
+            if let Some(oldest) = oldest {
+                executors.remove(&oldest);
+            }
+        }
+
+        let entries = executors.entry(executor_id.to_string()).or_default();
+        if entries.len() >= conf.size {
+            entries.pop_front();
+        }
+        entries.push_back(JournalEntry {
+            timestamp: Utc::now(),
+            method,
+            kind,
+            detail,
+        });
+    }
+}
+
+/// The layer of the backend server which journals its RPCs by method.
+#[derive(Clone)]
+pub struct JournalLayer {
+    journal: JournalPtr,
+}
+
+impl JournalLayer {
+    pub fn new(journal: JournalPtr) -> Self {
+        Self { journal }
+    }
+}
+
+impl<S> Layer<S> for JournalLayer {
+    type Service = JournalService<S>;
+
+    fn layer(&self, inner: S) -> Self::Service {
+        JournalService {
+            inner,
+            journal: self.journal.clone(),
+        }
+    }
+}
+
+#[derive(Clone)]
+pub struct JournalService<S> {
+    inner: S,
+    journal: JournalPtr,
+}
+
+impl<S, ResBody> Service<http::Request<BoxBody>> for JournalService<S>
+where
+    S: Service<http::Request<BoxBody>, Response = http::Response<ResBody>> + Clone + Send + 'static,
+    S::Future: Send + 'static,
+    S::Error: Into<BoxError>,
+    ResBody: http_body::Body<Data = Bytes> + Send + 'static,
+    ResBody::Error: Into<BoxError>,
+{
+    type Response = http::Response<BoxBody>;
+    type Error = BoxError;
+    type Future = BoxFuture<'static, Result<Self::Response, Self::Error>>;
+
+    fn poll_ready(&mut self, cx: &mut Context<'_>) -> Poll<Result<(), Self::Error>> {
+        self.inner.poll_ready(cx).map_err(Into::into)
+    }
+
+    fn call(&mut self, req: http::Request<BoxBody>) -> Self::Future {
+        // Take the inner service which is ready, and leave its clone.
+        let clone = self.inner.clone();
+        let mut inner = std::mem::replace(&mut self.inner, clone);
+        let journal = self.journal.clone();
+
+        Box::pin(async move {
+            // The streams of the nodes are not journaled.
+            let method = Method::of(req.uri().path()).filter(|_| journal.is_enabled());
+            let Some(method) = method else {
+                let resp = inner.call(req).await.map_err(Into::into)?;
+                return Ok(resp.map(tonic::body::boxed));
+            };
+
+            // The unary request is buffered to be decoded.
+            let (parts, body) = req.into_parts();
+            let body = body.collect().await?.to_bytes();
+            let executor = (method.request)(&body);
+            let req = http::Request::from_parts(parts, tonic::body::boxed(Full::new(body)));
+            let Some((executor_id, detail)) = executor else {
+                let resp = inner.call(req).await.map_err(Into::into)?;
+                return Ok(resp.map(tonic::body::boxed));
+            };
+            journal.request(&executor_id, method.name, || detail);
+
+            let resp = inner.call(req).await.map_err(Into::into)?;
+            let (parts, body) = resp.into_parts();
+            let body = body.collect().await.map_err(Into::into)?;
+            let trailers = body.trailers().cloned();
+            let body = body.to_bytes();
+            // The status of the error without a message is in the headers.
+            let status = trailers
+                .as_ref()
+                .and_then(Status::from_header_map)
+                .or_else(|| Status::from_header_map(&parts.headers));
+            let result = match status {
+                Some(status) if status.code() != Code::Ok => Err(status),
+                _ => Ok(body.clone()),
+            };
+            journal.result(&executor_id, method.name, &result, |body| {
+                (method.response)(body)
+            });
+
+            Ok(http::Response::from_parts(parts, buffered(body, trailers)))
+        })
+    }
+}
+
+/// The body of the buffered response, with its trailers of the status.
+fn buffered(data: Bytes, trailers: Option<http::HeaderMap>) -> BoxBody {
+    let mut frames = vec![Ok::<_, Infallible>(Frame::data(data))];
+    frames.extend(trailers.map(|trailers| Ok(Frame::trailers(trailers))));
+
+    tonic::body::boxed(StreamBody::new(futures::stream::iter(frames)))
+}
+
+/// The executor and the detail of the request.
+type RequestDetail = fn(&[u8]) -> Option<(ExecutorID, String)>;
+/// The detail of the response.
+type ResponseDetail = fn(&[u8]) -> String;
+
+/// The journaled RPC of the backend, by the details of its messages.
+#[derive(Clone, Copy)]
+struct Method {
+    name: &'static str,
+    request: RequestDetail,
+    response: ResponseDetail,
+}
+
+impl Method {
+    fn new(name: &'static str, request: RequestDetail, response: ResponseDetail) -> Self {
+        Self {
+            name,
+            request,
+            response,
+        }
+    }
+
+    /// The method of the path, e.g. "/flame.v1.Backend/BindExecutor".
+    fn of(path: &str) -> Option<Self> {
+        let method = match path.rsplit('/').next()? {
+            "RegisterExecutor" => Method::new("RegisterExecutor", register_executor, no_detail),
+            "UnregisterExecutor" => {
+                Method::new("UnregisterExecutor", unregister_executor, no_detail)
+            }
+            "BindExecutor" => Method::new("BindExecutor", bind_executor, bound_session),
+            "BindExecutorCompleted" => {
+                Method::new("BindExecutorCompleted", bind_executor_completed, no_detail)
+            }
+            "UnbindExecutor" => Method::new("UnbindExecutor", unbind_executor, no_detail),
+            "UnbindExecutorCompleted" => Method::new(
+                "UnbindExecutorCompleted",
+                unbind_executor_completed,
+                no_detail,
+            ),
+            "LaunchTask" => Method::new("LaunchTask", launch_task, launched_task),
+            "CompleteTask" => Method::new("CompleteTask", complete_task, next_task),
+            "RenewTaskLease" => Method::new("RenewTaskLease", renew_task_lease, no_detail),
+            "AnnotateTask" => Method::new("AnnotateTask", annotate_task, no_detail),
+            _ => return None,
+        };
+
+        Some(method)
+    }
+}
+
+/// The message of the unary body, framed by the compressed flag and its
+/// length; None if it's compressed.
+fn decode<M: Message + Default>(body: &[u8]) -> Option<M> {
+    let (compressed, frame) = body.split_first()?;
+    if *compressed != 0 {
+        return None;
+    }
+    let len = u32::from_be_bytes(frame.get(..4)?.try_into().ok()?) as usize;
+
+    M::decode(frame.get(4..4 + len)?).ok()
+}
+
+fn register_executor(body: &[u8]) -> Option<(ExecutorID, String)> {
+    let req = decode::<RegisterExecutorRequest>(body)?;
+    let detail = match &req.executor_spec {
+        Some(spec) => format!("node={}, slots={}", spec.node, spec.slots),
+        None => "no executor spec".to_string(),
+    };
+
+    Some((req.executor_id, detail))
+}
+
+fn unregister_executor(body: &[u8]) -> Option<(ExecutorID, String)> {
+    decode::<UnregisterExecutorRequest>(body).map(|req| (req.executor_id, String::new()))
+}
+
+fn bind_executor(body: &[u8]) -> Option<(ExecutorID, String)> {
+    decode::<BindExecutorRequest>(body).map(|req| {
+        let apps: Vec<String> = req
+            .excluded_applications
+            .into_iter()
+            .map(|app| app.name)
+            .collect();
+        (req.executor_id, apps.join(","))
+    })
+}
+
+fn bind_executor_completed(body: &[u8]) -> Option<(ExecutorID, String)> {
+    decode::<BindExecutorCompletedRequest>(body).map(|req| (req.executor_id, String::new()))
+}
+
+fn unbind_executor(body: &[u8]) -> Option<(ExecutorID, String)> {
+    decode::<UnbindExecutorRequest>(body)
+        .map(|req| (req.executor_id, req.reason.unwrap_or_default()))
+}
+
+fn unbind_executor_completed(body: &[u8]) -> Option<(ExecutorID, String)> {
+    decode::<UnbindExecutorCompletedRequest>(body).map(|req| (req.executor_id, String::new()))
+}
+
+fn launch_task(body: &[u8]) -> Option<(ExecutorID, String)> {
+    decode::<LaunchTaskRequest>(body).map(|req| (req.executor_id, String::new()))
+}
+
+fn complete_task(body: &[u8]) -> Option<(ExecutorID, String)> {
+    let req = decode::<CompleteTaskRequest>(body)?;
+    let detail = match &req.task_result {
+        Some(result) => format!(
+            "return_code={}, output={} bytes",
+            result.return_code,
+            result.output.as_ref().map_or(0, Vec::len)
+        ),
+        None => "no task result".to_string(),
+    };
+
+    Some((req.executor_id, detail))
+}
+
+fn renew_task_lease(body: &[u8]) -> Option<(ExecutorID, String)> {
+    decode::<RenewTaskLeaseRequest>(body).map(|req| {
+        let detail = format!("task={}/{}", req.session_id, req.task_id);
+        (req.executor_id, detail)
+    })
+}
+
+fn annotate_task(body: &[u8]) -> Option<(ExecutorID, String)> {
+    decode::<AnnotateTaskRequest>(body).map(|req| {
+        let detail = format!(
+            "task={}/{}, size={}",
+            req.session_id,
+            req.task_id,
+            req.annotation.len()
+        );
+        (req.executor_id, detail)
+    })
+}
+
+fn no_detail(_: &[u8]) -> String {
+    String::new()
+}
+
+fn bound_session(body: &[u8]) -> String {
+    let Some(resp) = decode::<BindExecutorResponse>(body) else {
+        return String::new();
+    };
+
+    match &resp.session {
+        Some(ssn) => format!(
+            "session={}, batch_index={:?}",
+            ssn.metadata
+                .as_ref()
+                .map(|m| m.id.as_str())
+                .unwrap_or_default(),
+            resp.batch_index
+        ),
+        None => "no session".to_string(),
+    }
+}
+
+fn launched_task(body: &[u8]) -> String {
+    let task = decode::<LaunchTaskResponse>(body)
+        .and_then(|resp| resp.task)
+        .and_then(|task| task.metadata);
+
+    match task {
+        Some(metadata) => format!("task={}", metadata.id),
+        None => "no task".to_string(),
+    }
+}
+
+fn next_task(body: &[u8]) -> String {
+    decode::<CompleteTaskResponse>(body)
+        .and_then(|resp| resp.next_task)
+        .and_then(|next| next.task)
+        .and_then(|task| task.metadata)
+        .map(|metadata| format!("next task={}", metadata.id))
+        .unwrap_or_default()
+}
+
+#[cfg(test)]
+mod tests {
+    use tower::ServiceExt;
+
+    use super::*;
+
+    fn frame(msg: &impl Message) -> Bytes {
+        let mut buf = vec![0u8];
+        buf.extend((msg.encoded_len() as u32).to_be_bytes());
+        msg.encode(&mut buf).unwrap();
+
+        Bytes::from(buf)
+    }
+
+    fn request(path: &str, msg: &impl Message) -> http::Request<BoxBody> {
+        http::Request::builder()
+            .uri(path)
+            .body(tonic::body::boxed(Full::new(frame(msg))))
+            .unwrap()
+    }
+
+    #[test]
+    fn test_journal_is_bounded() {
+        let journal = new_ptr(Some(FlameJournal {
+            size: 2,
+            executors: 2,
+        }));
+
+        journal.request("exe-1", "BindExecutor", || "bind".to_string());
+        journal.result(
+            "exe-1",
+            "BindExecutor",
+            &Err::<(), _>(Status::internal("no session")),
+            |_| String::new(),
+        );
+        journal.result("exe-1", "LaunchTask", &Ok(1), |id| format!("task {id}"));
+
+        // The oldest entry is dropped.
+        let entries = journal.entries("exe-1");
+        assert_eq!(entries.len(), 2);
+        assert_eq!(entries[0].kind, EntryKind::Error);
+        assert!(entries[0].detail.contains("no session"));
+        assert_eq!(entries[1].method, "LaunchTask");
+        assert_eq!(entries[1].detail, "task 1");
+
+        // The least recently journaled executor is dropped.
+        journal.request("exe-2", "RegisterExecutor", String::new);
+        journal.request("exe-3", "RegisterExecutor", String::new);
+        assert!(journal.entries("exe-1").is_empty());
+        assert_eq!(journal.entries("exe-2").len(), 1);
+        assert_eq!(journal.entries("exe-3").len(), 1);
+    }
+
+    #[test]
+    fn test_journal_is_disabled() {
+        let journal = new_ptr(None);
+        journal.request("exe-1", "BindExecutor", || {
+            panic!("the detail is not built if disabled")
+        });
+        assert!(journal.entries("exe-1").is_empty());
+    }
+
+    #[tokio::test]
+    async fn test_journal_layer() {
+        let journal = new_ptr(Some(FlameJournal {
+            size: 8,
+            executors: 2,
+        }));
+        // Bind the executor to no session, and fail the other RPCs.
+        let backend = tower::service_fn(|req: http::Request<BoxBody>| async move {
+            let mut trailers = http::HeaderMap::new();
+            let data = match req.uri().path() {
+                "/flame.v1.Backend/BindExecutor" => {
+                    let body = req.into_body().collect().await?.to_bytes();
+                    let req = decode::<BindExecutorRequest>(&body).unwrap();
+                    assert_eq!(req.executor_id, "exe-1");
+                    trailers.insert("grpc-status", "0".parse().unwrap());
+                    frame(&BindExecutorResponse::default())
+                }
+                _ => {
+                    trailers.insert("grpc-status", "5".parse().unwrap());
+                    trailers.insert("grpc-message", "not found".parse().unwrap());
+                    Bytes::new()
+                }
+            };
+
+            Ok::<_, BoxError>(http::Response::new(buffered(data, Some(trailers))))
+        });
+        let service = JournalLayer::new(journal.clone()).layer(backend);
+
+        let bind = BindExecutorRequest {
+            executor_id: "exe-1".to_string(),
+            excluded_applications: vec![::rpc::flame::v1::ExcludedApplication {
+                name: "pi".to_string(),
+                ..Default::default()
+            }],
+        };
+        let resp = service
+            .clone()
+            .oneshot(request("/flame.v1.Backend/BindExecutor", &bind))
+            .await
+            .unwrap();
+        // The response is passed through with its trailers.
+        let body = resp.into_body().collect().await.unwrap();
+        assert!(body.trailers().is_some());
+        assert!(decode::<BindExecutorResponse>(&body.to_bytes()).is_some());
+
+        let launch = LaunchTaskRequest {
+            executor_id: "exe-1".to_string(),
+        };
+        service
+            .oneshot(request("/flame.v1.Backend/LaunchTask", &launch))
+            .await
+            .unwrap();
+
+        let entries = journal.entries("exe-1");
+        let entries: Vec<_> = entries
+            .iter()
+            .map(|e| (e.method, e.kind, e.detail.as_str()))
+            .collect();
+        assert_eq!(
+            entries,
+            vec![
+                ("BindExecutor", EntryKind::Request, "pi"),
+                ("BindExecutor", EntryKind::Response, "no session"),
+                ("LaunchTask", EntryKind::Request, ""),
+                ("LaunchTask", EntryKind::Error, "NotFound: not found"),
+            ]
+        );
+    }
+}
@@ -13,35 +13,93 @@ limitations under the License.
 
 use std::sync::Arc;
 use std::time::Duration;
+use tokio::sync::Semaphore;
 use tonic::transport::Server;
 
-use common::ctx::FlameClusterContext;
+use common::apis::ResourceRequirement;
+use common::ctx::{FlameChaos, FlameClusterContext};
 use rpc::flame::v1::backend_server::BackendServer;
 use rpc::flame::v1::frontend_server::FrontendServer;
+use rpc::flame::v1::replication_server::ReplicationServer;
 
+use crate::apiserver::hooks::Hooks;
+use crate::apiserver::journal::{JournalLayer, JournalPtr};
+use crate::apiserver::replication::{ReplicaPtr, ReplicationService};
 use crate::controller::ControllerPtr;
+use crate::timeline::{self, TimelinePtr};
 use crate::{FlameError, FlameThread};
 
 mod backend;
 mod frontend;
+mod hooks;
+pub mod journal;
+mod policy;
+pub mod replication;
 
 const DEFAULT_PORT: u16 = 8080;
 const ALL_HOST_ADDRESS: &str = "0.0.0.0";
+/// The interval to check the expired task leases.
+const LEASE_CHECK_INTERVAL: Duration = Duration::from_secs(1);
+/// The uploads of the inputs buffered by the frontend at the same time.
+const MAX_CONCURRENT_UPLOADS: usize = 4;
 
 pub struct Flame {
     controller: ControllerPtr,
+    /// The lease of the running tasks, renewed by the executors.
+    task_lease: Option<Duration>,
+    /// The admission and mutation hooks of the frontend.
+    hooks: Hooks,
+    /// The journal of the backend RPCs, shared by the frontend to dump it.
+    journal: JournalPtr,
+    /// The time series of the scheduler metrics, only served by the frontend.
+    timeline: TimelinePtr,
+    /// The resources of a slot, to measure the nodes in slots.
+    slot: ResourceRequirement,
+    /// The faults injected into the backend for the soak tests.
+    chaos: Option<FlameChaos>,
+    /// The max sessions kept by the session manager, reported to the clients.
+    max_sessions: Option<usize>,
+    /// The principal is only taken from the headers of a trusted proxy.
+    trust_proxy_headers: bool,
+    /// The permits of the uploads, so the inputs buffered are bounded.
+    uploads: Arc<Semaphore>,
+    /// The tasks are only read by the callers of their tenants, as the data
+    /// of the tasks is decrypted by the storage for any caller.
+    tenant_isolation: bool,
 }
 
-pub fn new_frontend(controller: ControllerPtr) -> Arc<dyn FlameThread> {
-    Arc::new(FrontendRunner { controller })
+pub fn new_frontend(
+    controller: ControllerPtr,
+    journal: JournalPtr,
+    replica: ReplicaPtr,
+    timeline: TimelinePtr,
+) -> Arc<dyn FlameThread> {
+    Arc::new(FrontendRunner {
+        controller,
+        journal,
+        replica,
+        timeline,
+    })
 }
 
-pub fn new_backend(controller: ControllerPtr) -> Arc<dyn FlameThread> {
-    Arc::new(BackendRunner { controller })
+pub fn new_backend(
+    controller: ControllerPtr,
+    journal: JournalPtr,
+    replica: ReplicaPtr,
+) -> Arc<dyn FlameThread> {
+    Arc::new(BackendRunner {
+        controller,
+        journal,
+        replica,
+    })
 }
 
 struct FrontendRunner {
     controller: ControllerPtr,
+    journal: JournalPtr,
+    /// The role of the session manager; the standby follows the leader.
+    replica: ReplicaPtr,
+    timeline: TimelinePtr,
 }
 
 #[async_trait::async_trait]
@@ -62,6 +120,16 @@ impl FlameThread for FrontendRunner {
 
         let frontend_service = Flame {
             controller: self.controller.clone(),
+            task_lease: None,
+            hooks: Hooks::new(ctx.cluster.hooks.clone())?,
+            journal: self.journal.clone(),
+            timeline: self.timeline.clone(),
+            slot: ctx.cluster.slot.clone(),
+            chaos: None,
+            max_sessions: ctx.cluster.limits.max_sessions,
+            trust_proxy_headers: ctx.cluster.trust_proxy_headers,
+            uploads: Arc::new(Semaphore::new(MAX_CONCURRENT_UPLOADS)),
+            tenant_isolation: ctx.cluster.encryption.is_some(),
         };
 
         let mut builder = Server::builder().tcp_keepalive(Some(Duration::from_secs(1)));
@@ -75,8 +143,27 @@ impl FlameThread for FrontendRunner {
             tracing::info!("TLS enabled for frontend apiserver");
         }
 
+        if self.replica.is_standby() {
+            tokio::spawn(replication::follow(
+                self.controller.clone(),
+                self.replica.clone(),
+            ));
+        }
+
+        // The clients behind the proxies without HTTP/2 fall back to
+        // gRPC-web over HTTP/1.1, see PollTask.
+        let replica = self.replica.clone();
         builder
-            .add_service(FrontendServer::new(frontend_service))
+            .accept_http1(true)
+            .layer(tonic_web::GrpcWebLayer::new())
+            .add_service(FrontendServer::with_interceptor(
+                frontend_service,
+                move |req| replica.intercept(req),
+            ))
+            .add_service(ReplicationServer::new(ReplicationService::new(
+                self.controller.clone(),
+                self.replica.clone(),
+            )))
             .serve(address)
             .await
             .map_err(|e| FlameError::Network(e.to_string()))?;
@@ -87,6 +174,10 @@ impl FlameThread for FrontendRunner {
 
 struct BackendRunner {
     controller: ControllerPtr,
+    journal: JournalPtr,
+    /// The fenced leader rejects the executors, so they connect to the new
+    /// leader.
+    replica: ReplicaPtr,
 }
 
 #[async_trait::async_trait]
@@ -104,10 +195,34 @@ impl FlameThread for BackendRunner {
             FlameError::InvalidConfig(format!("failed to parse url <{address_str}>"))
         })?;
 
+        let task_lease = ctx.cluster.executors.task_lease.map(Duration::from_secs);
         let backend_service = Flame {
             controller: self.controller.clone(),
+            task_lease,
+            hooks: Hooks::default(),
+            journal: self.journal.clone(),
+            timeline: timeline::new_ptr(None)?,
+            slot: ctx.cluster.slot.clone(),
+            chaos: ctx.cluster.chaos.clone(),
+            max_sessions: ctx.cluster.limits.max_sessions,
+            trust_proxy_headers: false,
+            uploads: Arc::new(Semaphore::new(0)),
+            tenant_isolation: false,
         };
 
+        if task_lease.is_some() {
+            let controller = self.controller.clone();
+            tokio::spawn(async move {
+                let clock = controller.clock();
+                loop {
+                    clock.sleep(LEASE_CHECK_INTERVAL).await;
+                    if let Err(e) = controller.expire_task_leases().await {
+                        tracing::warn!("Failed to expire task leases: {e}");
+                    }
+                }
+            });
+        }
+
         let mut builder = Server::builder().tcp_keepalive(Some(Duration::from_secs(1)));
 
         // Apply TLS if configured
@@ -119,8 +234,13 @@ impl FlameThread for BackendRunner {
             tracing::info!("TLS enabled for backend apiserver");
         }
 
+        let replica = self.replica.clone();
         builder
-            .add_service(BackendServer::new(backend_service))
+            .layer(JournalLayer::new(self.journal.clone()))
+            .add_service(BackendServer::with_interceptor(
+                backend_service,
+                move |req| replica.intercept(req),
+            ))
             .serve(address)
             .await
             .map_err(|e| FlameError::Network(e.to_string()))?;
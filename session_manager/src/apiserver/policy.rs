@@ -0,0 +1,206 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//! The built-in Rego evaluator of the admission hooks, so the constraints are
+//! expressed declaratively instead of running a webhook. The review is the
+//! `input` of the policy, and the decision is queried from the package, e.g.
+//!
+//! ```rego
+//! package flame.admission
+//!
+//! deny contains msg if {
+//!     input.operation == "create_session"
+//!     not startswith(input.session.id, "team-")
+//!     msg := "session id must start with team-"
+//! }
+//!
+//! patch := {"max_instances": 10} if input.operation == "create_session"
+//! ```
+//!
+//! The operation is allowed if `deny` is empty; `patch` is the patch of the
+//! session for the mutating hooks.
+
+use std::fs;
+use std::path::Path;
+use std::sync::Mutex;
+
+use regorus::{Engine, Value};
+use serde_derive::Deserialize;
+
+use common::FlameError;
+
+/// The data of the bundle, which is `data` in the policy.
+const BUNDLE_DATA_FILE: &str = "data.json";
+const REGO_EXTENSION: &str = "rego";
+
+#[derive(Debug, Default, Deserialize)]
+pub struct Decision {
+    #[serde(default)]
+    pub deny: Vec<String>,
+    #[serde(default)]
+    pub patch: Option<serde_json::Value>,
+}
+
+pub struct Policy {
+    engine: Mutex<Engine>,
+    query: String,
+}
+
+impl Policy {
+    /// Load the policy from a .rego file, or a bundle directory of .rego files
+    /// and an optional data.json.
+    pub fn load(bundle: &str, query: &str) -> Result<Self, FlameError> {
+        let mut engine = Engine::new();
+        let path = Path::new(bundle);
+
+        if path.is_dir() {
+            let entries = fs::read_dir(path).map_err(|e| {
+                FlameError::InvalidConfig(format!("failed to read policy bundle <{bundle}>: {e}"))
+            })?;
+            let mut files: Vec<_> = entries.flatten().map(|entry| entry.path()).collect();
+            // Load the policies in the same order on every start.
+            files.sort();
+
+            for file in files {
+                if file.extension().is_some_and(|ext| ext == REGO_EXTENSION) {
+                    add_policy(&mut engine, &file)?;
+                } else if file
+                    .file_name()
+                    .is_some_and(|name| name == BUNDLE_DATA_FILE)
+                {
+                    let data = Value::from_json_file(&file).map_err(|e| {
+                        FlameError::InvalidConfig(format!(
+                            "invalid policy data <{}>: {e}",
+                            file.display()
+                        ))
+                    })?;
+                    engine.add_data(data).map_err(|e| {
+                        FlameError::InvalidConfig(format!(
+                            "invalid policy data <{}>: {e}",
+                            file.display()
+                        ))
+                    })?;
+                }
+            }
+        } else {
+            add_policy(&mut engine, path)?;
+        }
+
+        Ok(Policy {
+            engine: Mutex::new(engine),
+            query: query.to_string(),
+        })
+    }
+
+    /// Evaluate the decision of the policy by the review as its input.
+    pub fn evaluate(&self, input: &str) -> Result<Decision, FlameError> {
+        // The engine is cloned, so the reviews are not serialized by the lock.
+        let mut engine = self
+            .engine
+            .lock()
+            .map_err(|_| FlameError::Internal("policy engine is poisoned".to_string()))?
+            .clone();
+
+        let input = Value::from_json_str(input)
+            .map_err(|e| FlameError::Internal(format!("invalid policy input: {e}")))?;
+        engine.set_input(input);
+
+        let results = engine.eval_query(self.query.clone(), false).map_err(|e| {
+            FlameError::Internal(format!("failed to evaluate <{}>: {e}", self.query))
+        })?;
+
+        // An undefined decision allows the operation.
+        let Some(value) = results
+            .result
+            .first()
+            .and_then(|result| result.expressions.first())
+            .map(|expr| &expr.value)
+        else {
+            return Ok(Decision::default());
+        };
+
+        let decision = value.to_json_str().map_err(|e| {
+            FlameError::Internal(format!("invalid decision of <{}>: {e}", self.query))
+        })?;
+        serde_json::from_str(&decision)
+            .map_err(|e| FlameError::Internal(format!("invalid decision of <{}>: {e}", self.query)))
+    }
+}
+
+fn add_policy(engine: &mut Engine, path: &Path) -> Result<(), FlameError> {
+    engine.add_policy_from_file(path).map_err(|e| {
+        FlameError::InvalidConfig(format!("invalid policy <{}>: {e}", path.display()))
+    })?;
+
+    Ok(())
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use tempfile::tempdir;
+
+    const TEST_POLICY: &str = r#"
+package flame.admission
+
+import rego.v1
+
+deny contains msg if {
+    input.operation == "create_session"
+    not input.session.application in data.allowed_applications
+    msg := sprintf("application %s is not allowed", [input.session.application])
+}
+
+patch := {"max_instances": 10} if {
+    input.operation == "create_session"
+    input.session.max_instances == null
+}
+"#;
+
+    #[test]
+    fn test_evaluate_bundle() {
+        let bundle = tempdir().unwrap();
+        fs::write(bundle.path().join("admission.rego"), TEST_POLICY).unwrap();
+        fs::write(
+            bundle.path().join(BUNDLE_DATA_FILE),
+            r#"{"allowed_applications": ["pi"]}"#,
+        )
+        .unwrap();
+
+        let policy =
+            Policy::load(&bundle.path().to_string_lossy(), "data.flame.admission").unwrap();
+
+        let decision = policy
+            .evaluate(r#"{"operation": "create_session", "session": {"id": "ssn-1", "application": "pi", "max_instances": null}}"#)
+            .unwrap();
+        assert!(decision.deny.is_empty());
+        assert_eq!(
+            decision.patch,
+            Some(serde_json::json!({"max_instances": 10}))
+        );
+
+        let decision = policy
+            .evaluate(r#"{"operation": "create_session", "session": {"id": "ssn-2", "application": "shell", "max_instances": 2}}"#)
+            .unwrap();
+        assert_eq!(decision.deny, vec!["application shell is not allowed"]);
+        assert!(decision.patch.is_none());
+
+        // Nothing is decided for the tasks.
+        let decision = policy
+            .evaluate(r#"{"operation": "submit_task", "task": {"session_id": "ssn-1"}}"#)
+            .unwrap();
+        assert!(decision.deny.is_empty());
+
+        assert!(Policy::load("/not/exist.rego", "data.flame.admission").is_err());
+    }
+}
@@ -0,0 +1,676 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//! The warm standby of the session manager: the standby follows the state
+//! stream of the leader, and rejects the frontend requests with the endpoint
+//! of the leader, so the clients are redirected; after it's promoted by
+//! `PromoteStandby`, it fences the old leader, serves the requests and starts
+//! the backend and the scheduler.
+//!
+//! The state stream and the promotion are authorized by the token of the
+//! replication, as the Replication service shares the port of the frontend.
+
+use std::collections::HashSet;
+use std::pin::Pin;
+use std::sync::Arc;
+use std::time::Duration;
+
+use async_trait::async_trait;
+use chrono::{DateTime, Utc};
+use futures::Stream;
+use stdng::{lock_ptr, trace_fn, MutexPtr};
+use tokio::sync::broadcast::error::{RecvError, TryRecvError};
+use tokio::sync::{mpsc, watch};
+use tokio_stream::wrappers::ReceiverStream;
+use tonic::transport::{Channel, ClientTlsConfig};
+use tonic::{Request, Response, Status};
+
+use rpc::flame::v1 as rpc;
+
+use self::rpc::replication_client::ReplicationClient;
+use self::rpc::replication_server::Replication;
+use self::rpc::state_delta::Delta;
+use self::rpc::{
+    FenceLeaderRequest, GetReplicationRequest, PromoteStandbyRequest, ReplicaRole,
+    ReplicationStatus, StreamStateRequest,
+};
+
+use common::apis::{Application, Session, SessionID, Task};
+use common::ctx::{FlameCluster, FlameReplication, FlameTls};
+use common::FlameError;
+
+use crate::controller::{ControllerPtr, StateDelta, StateTracker};
+use crate::storage::StateChange;
+
+/// The interval to connect to the leader again after the stream was broken.
+const RECONNECT_INTERVAL: Duration = Duration::from_secs(3);
+
+/// The metadata of the errors of the standby with the endpoint of the leader.
+pub const LEADER_METADATA: &str = "x-flame-leader";
+/// The metadata of the token of the replication, i.e. `Bearer <token>`.
+const AUTHORIZATION_METADATA: &str = "authorization";
+
+pub type ReplicaPtr = Arc<Replica>;
+
+/// The role of the session manager in the replication.
+pub struct Replica {
+    /// The leader followed by the standby; None on the leader.
+    leader: Option<String>,
+    /// The frontend endpoint of the session manager, i.e. the new leader of
+    /// the fenced one.
+    endpoint: String,
+    replication: Option<FlameReplication>,
+    tls: Option<FlameTls>,
+    promoted: watch::Sender<bool>,
+    /// The new leader after the leader was fenced by its promoted standby;
+    /// it's kept until the session manager restarts.
+    fenced: MutexPtr<Option<String>>,
+    status: MutexPtr<FollowerStatus>,
+}
+
+#[derive(Default)]
+struct FollowerStatus {
+    synced: bool,
+    last_delta_time: Option<DateTime<Utc>>,
+}
+
+pub fn new_replica(cluster: &FlameCluster) -> ReplicaPtr {
+    let (promoted, _) = watch::channel(false);
+    Arc::new(Replica {
+        leader: cluster
+            .standby
+            .as_ref()
+            .map(|standby| standby.leader.clone()),
+        endpoint: cluster.endpoint.clone(),
+        replication: cluster.replication.clone(),
+        tls: cluster.tls.clone(),
+        promoted,
+        fenced: stdng::new_ptr(None),
+        status: stdng::new_ptr(FollowerStatus::default()),
+    })
+}
+
+/// Compare the tokens in constant time, so the token is not guessed by the
+/// time of the comparison.
+fn token_eq(a: &[u8], b: &[u8]) -> bool {
+    a.len() == b.len() && a.iter().zip(b).fold(0, |acc, (x, y)| acc | (x ^ y)) == 0
+}
+
+impl Replica {
+    /// Whether it's a standby not promoted yet, which serves no requests.
+    pub fn is_standby(&self) -> bool {
+        self.leader.is_some() && !*self.promoted.borrow()
+    }
+
+    /// Wait until the standby is promoted; it returns at once on the leader.
+    pub async fn wait_promoted(&self) {
+        let mut promoted = self.promoted.subscribe();
+        while self.is_standby() {
+            if promoted.changed().await.is_err() {
+                return;
+            }
+        }
+    }
+
+    /// The new leader if the leader was fenced by its promoted standby.
+    fn fenced_by(&self) -> Result<Option<String>, FlameError> {
+        Ok(lock_ptr!(self.fenced)?.clone())
+    }
+
+    /// Reject the requests with the endpoint of the leader while it's a
+    /// standby, or of the new leader after it was fenced, so the clients and
+    /// the executors connect to the leader instead.
+    pub fn intercept(&self, req: Request<()>) -> Result<Request<()>, Status> {
+        let leader = match self.leader.as_ref().filter(|_| self.is_standby()) {
+            Some(leader) => Some(leader.clone()),
+            None => self.fenced_by()?,
+        };
+        let Some(leader) = leader else {
+            return Ok(req);
+        };
+
+        let mut status = Status::unavailable(format!(
+            "session manager is not the leader, connect to the leader <{leader}> instead"
+        ));
+        if let Ok(value) = leader.parse() {
+            status.metadata_mut().insert(LEADER_METADATA, value);
+        }
+
+        Err(status)
+    }
+
+    /// Authorize the call by the token of the replication in its metadata;
+    /// all the calls are rejected if the token is not configured.
+    fn authorize<T>(&self, req: &Request<T>) -> Result<(), Status> {
+        let Some(replication) = self.replication.as_ref() else {
+            return Err(Status::unauthenticated(
+                "the token of the replication is not configured",
+            ));
+        };
+        let token = replication.token()?;
+
+        let given = req
+            .metadata()
+            .get(AUTHORIZATION_METADATA)
+            .and_then(|value| value.to_str().ok())
+            .and_then(|value| value.strip_prefix("Bearer "));
+        if !given.is_some_and(|given| token_eq(given.as_bytes(), token.as_bytes())) {
+            return Err(Status::unauthenticated("invalid token of the replication"));
+        }
+
+        Ok(())
+    }
+
+    /// The request with the token of the replication.
+    fn authorized<T>(&self, message: T) -> Result<Request<T>, FlameError> {
+        let replication = self.replication.as_ref().ok_or(FlameError::InvalidConfig(
+            "the token of the replication is not configured".to_string(),
+        ))?;
+        let value = format!("Bearer {}", replication.token()?)
+            .parse()
+            .map_err(|_| {
+                FlameError::InvalidConfig("invalid token of the replication".to_string())
+            })?;
+
+        let mut req = Request::new(message);
+        req.metadata_mut().insert(AUTHORIZATION_METADATA, value);
+        Ok(req)
+    }
+
+    async fn connect(&self, leader: &str) -> Result<ReplicationClient<Channel>, FlameError> {
+        let mut channel_builder = Channel::from_shared(leader.to_string())
+            .map_err(|e| FlameError::InvalidConfig(format!("invalid leader <{leader}>: {e}")))?;
+        if leader.starts_with("https://") {
+            let tls_config = match self.tls {
+                Some(ref tls) => tls.client_tls_config()?,
+                None => ClientTlsConfig::new(),
+            };
+            channel_builder = channel_builder
+                .tls_config(tls_config)
+                .map_err(|e| FlameError::InvalidConfig(format!("TLS config error: {}", e)))?;
+        }
+        let channel = channel_builder
+            .connect()
+            .await
+            .map_err(|e| FlameError::Network(format!("Failed to connect to <{leader}>: {e}")))?;
+
+        Ok(ReplicationClient::new(channel))
+    }
+
+    /// Fence the old leader by the endpoint of the standby, so the clients
+    /// and the executors are redirected to the standby once it's promoted.
+    async fn fence(&self, leader: &str) -> Result<(), FlameError> {
+        let req = self.authorized(FenceLeaderRequest {
+            leader: self.endpoint.clone(),
+        })?;
+        let status = self.connect(leader).await?.fence_leader(req).await?;
+        if status.into_inner().role() != ReplicaRole::Fenced {
+            return Err(FlameError::InvalidState(format!(
+                "the leader <{leader}> was not fenced"
+            )));
+        }
+
+        Ok(())
+    }
+
+    fn promote(&self) {
+        self.promoted.send_replace(true);
+    }
+
+    fn record_delta(&self, now: DateTime<Utc>, synced: Option<bool>) -> Result<(), FlameError> {
+        let mut status = lock_ptr!(self.status)?;
+        status.last_delta_time = Some(now);
+        if let Some(synced) = synced {
+            status.synced = synced;
+        }
+
+        Ok(())
+    }
+
+    fn set_synced(&self, synced: bool) -> Result<(), FlameError> {
+        let mut status = lock_ptr!(self.status)?;
+        status.synced = synced;
+
+        Ok(())
+    }
+
+    fn status(&self) -> Result<ReplicationStatus, FlameError> {
+        if let Some(leader) = self.fenced_by()? {
+            return Ok(ReplicationStatus {
+                role: ReplicaRole::Fenced as i32,
+                leader: Some(leader),
+                synced: false,
+                last_delta_time: None,
+            });
+        }
+        if !self.is_standby() {
+            return Ok(ReplicationStatus {
+                role: ReplicaRole::Leader as i32,
+                leader: None,
+                synced: true,
+                last_delta_time: None,
+            });
+        }
+
+        let status = lock_ptr!(self.status)?;
+        Ok(ReplicationStatus {
+            role: ReplicaRole::Standby as i32,
+            leader: self.leader.clone(),
+            synced: status.synced,
+            last_delta_time: status.last_delta_time.map(|t| t.timestamp_millis()),
+        })
+    }
+}
+
+pub struct ReplicationService {
+    controller: ControllerPtr,
+    replica: ReplicaPtr,
+}
+
+impl ReplicationService {
+    pub fn new(controller: ControllerPtr, replica: ReplicaPtr) -> Self {
+        Self {
+            controller,
+            replica,
+        }
+    }
+}
+
+/// The session of the change, whose tasks are compared for the deltas.
+fn changed_session(change: StateChange, changed: &mut HashSet<SessionID>) {
+    match change {
+        StateChange::Application(_) => {}
+        StateChange::Session(id) => {
+            changed.insert(id);
+        }
+        StateChange::Task(gid) => {
+            changed.insert(gid.ssn_id);
+        }
+    }
+}
+
+#[async_trait]
+impl Replication for ReplicationService {
+    type StreamStateStream = Pin<Box<dyn Stream<Item = Result<rpc::StateDelta, Status>> + Send>>;
+
+    async fn stream_state(
+        &self,
+        req: Request<StreamStateRequest>,
+    ) -> Result<Response<Self::StreamStateStream>, Status> {
+        trace_fn!("Replication::stream_state");
+        self.replica.authorize(&req)?;
+        if self.replica.is_standby() || self.replica.fenced_by()?.is_some() {
+            return Err(Status::failed_precondition(
+                "the state is only streamed by the leader",
+            ));
+        }
+
+        let controller = self.controller.clone();
+        let (tx, rx) = mpsc::channel(128);
+
+        tokio::spawn(async move {
+            // Subscribe before the snapshot, so no change after it is missed.
+            let mut changes = controller.subscribe_changes();
+            let mut tracker = StateTracker::default();
+            // All the sessions are compared for the snapshot, and after the
+            // changes were dropped as the stream fell behind.
+            let mut changed = None;
+            loop {
+                let deltas = match controller
+                    .state_deltas(&mut tracker, changed.as_ref())
+                    .await
+                {
+                    Ok(deltas) => deltas,
+                    Err(e) => {
+                        let _ = tx.send(Err(Status::from(e))).await;
+                        return;
+                    }
+                };
+                for delta in deltas {
+                    if tx.send(Ok(rpc::StateDelta::from(delta))).await.is_err() {
+                        return;
+                    }
+                }
+
+                let change = tokio::select! {
+                    change = changes.recv() => change,
+                    _ = tx.closed() => return,
+                };
+                let mut sessions = HashSet::new();
+                changed = match change {
+                    Ok(change) => {
+                        changed_session(change, &mut sessions);
+                        // The changes so far are streamed together.
+                        loop {
+                            match changes.try_recv() {
+                                Ok(change) => changed_session(change, &mut sessions),
+                                Err(TryRecvError::Empty) => break Some(sessions),
+                                Err(TryRecvError::Lagged(_)) => break None,
+                                Err(TryRecvError::Closed) => return,
+                            }
+                        }
+                    }
+                    Err(RecvError::Lagged(n)) => {
+                        tracing::warn!(
+                            "The state stream missed <{n}> changes, compare all the state"
+                        );
+                        None
+                    }
+                    Err(RecvError::Closed) => return,
+                };
+            }
+        });
+
+        let output_stream = ReceiverStream::new(rx);
+        Ok(Response::new(
+            Box::pin(output_stream) as Self::StreamStateStream
+        ))
+    }
+
+    async fn get_replication(
+        &self,
+        _: Request<GetReplicationRequest>,
+    ) -> Result<Response<ReplicationStatus>, Status> {
+        trace_fn!("Replication::get_replication");
+        Ok(Response::new(self.replica.status()?))
+    }
+
+    async fn promote_standby(
+        &self,
+        req: Request<PromoteStandbyRequest>,
+    ) -> Result<Response<ReplicationStatus>, Status> {
+        trace_fn!("Replication::promote_standby");
+        self.replica.authorize(&req)?;
+        let force = req.into_inner().force;
+        let Some(leader) = self.replica.leader.as_ref() else {
+            return Err(Status::failed_precondition(
+                "session manager is not a standby",
+            ));
+        };
+
+        if self.replica.is_standby() {
+            // The old leader is fenced first, so only one of them serves the
+            // clients and the executors.
+            if let Err(e) = self.replica.fence(leader).await {
+                if !force {
+                    return Err(Status::failed_precondition(format!(
+                        "failed to fence the leader <{leader}>: {e}; promote by force if it's down"
+                    )));
+                }
+                tracing::warn!("Promoting the standby without fencing the leader <{leader}>: {e}");
+            }
+
+            // Stop following the leader before taking over its tasks.
+            self.replica.promote();
+            self.controller.take_over().await?;
+            tracing::info!("The standby of <{leader}> was promoted to the leader");
+        }
+
+        Ok(Response::new(self.replica.status()?))
+    }
+
+    async fn fence_leader(
+        &self,
+        req: Request<FenceLeaderRequest>,
+    ) -> Result<Response<ReplicationStatus>, Status> {
+        trace_fn!("Replication::fence_leader");
+        self.replica.authorize(&req)?;
+        let leader = req.into_inner().leader;
+        if !leader.starts_with("http://") && !leader.starts_with("https://") {
+            return Err(Status::invalid_argument(format!(
+                "invalid new leader <{leader}>"
+            )));
+        }
+        if self.replica.is_standby() {
+            return Err(Status::failed_precondition(
+                "session manager is not the leader",
+            ));
+        }
+
+        *lock_ptr!(self.replica.fenced)? = Some(leader.clone());
+        tracing::warn!("The leader was fenced by the new leader <{leader}>");
+
+        Ok(Response::new(self.replica.status()?))
+    }
+}
+
+/// Follow the state stream of the leader until the standby is promoted; the
+/// stream is connected again if it's broken.
+pub async fn follow(controller: ControllerPtr, replica: ReplicaPtr) {
+    let Some(leader) = replica.leader.clone() else {
+        return;
+    };
+    let clock = controller.clock();
+
+    loop {
+        tokio::select! {
+            res = follow_leader(&controller, &replica, &leader) => {
+                if let Err(e) = res {
+                    tracing::warn!("Failed to follow the leader <{leader}>: {e}");
+                }
+                if let Err(e) = replica.set_synced(false) {
+                    tracing::warn!("Failed to update the replication status: {e}");
+                }
+            }
+            _ = replica.wait_promoted() => return,
+        }
+
+        tokio::select! {
+            _ = clock.sleep(RECONNECT_INTERVAL) => {}
+            _ = replica.wait_promoted() => return,
+        }
+    }
+}
+
+async fn follow_leader(
+    controller: &ControllerPtr,
+    replica: &Replica,
+    leader: &str,
+) -> Result<(), FlameError> {
+    let req = replica.authorized(StreamStateRequest {})?;
+    let mut stream = replica
+        .connect(leader)
+        .await?
+        .stream_state(req)
+        .await?
+        .into_inner();
+    tracing::info!("Following the state of the leader <{leader}>");
+
+    // The applications and sessions of the snapshot; the others of the
+    // standby were removed while it was not following the leader.
+    let mut snapshot = Some((HashSet::new(), HashSet::new()));
+    while let Some(delta) = stream.message().await? {
+        let delta = StateDelta::try_from(delta)?;
+        let mut synced = None;
+        match (&delta, snapshot.as_mut()) {
+            (StateDelta::Application(app), Some((apps, _))) => {
+                apps.insert(app.name.clone());
+            }
+            (StateDelta::Session(ssn), Some((_, ssns))) => {
+                ssns.insert(ssn.id.clone());
+            }
+            (StateDelta::Synced, _) => {
+                if let Some((apps, ssns)) = snapshot.take() {
+                    controller.prune_state(&apps, &ssns).await?;
+                }
+                synced = Some(true);
+            }
+            _ => {}
+        }
+
+        match controller.apply_state_delta(delta).await {
+            Ok(()) => {}
+            // The standby diverged from the leader; it's synced again by the
+            // snapshot of the next stream.
+            Err(e @ FlameError::Integrity(_)) => return Err(e),
+            Err(e) => {
+                tracing::warn!("Failed to apply the state delta of the leader <{leader}>: {e}")
+            }
+        }
+        replica.record_delta(controller.clock().utc_now(), synced)?;
+    }
+
+    Err(FlameError::Network(format!(
+        "the state stream of the leader <{leader}> was closed"
+    )))
+}
+
+impl From<StateDelta> for rpc::StateDelta {
+    fn from(delta: StateDelta) -> Self {
+        let delta = match delta {
+            StateDelta::Application(app) => Delta::Application(rpc::Application::from(&app)),
+            StateDelta::RemovedApplication(name) => Delta::RemovedApplication(name),
+            StateDelta::Session(ssn) => Delta::Session(rpc::Session::from(&ssn)),
+            StateDelta::RemovedSession(id) => Delta::RemovedSession(id),
+            StateDelta::Task(task) => Delta::Task(rpc::Task::from(&task)),
+            StateDelta::Synced => Delta::Synced(true),
+        };
+
+        rpc::StateDelta { delta: Some(delta) }
+    }
+}
+
+impl TryFrom<rpc::StateDelta> for StateDelta {
+    type Error = FlameError;
+
+    fn try_from(delta: rpc::StateDelta) -> Result<Self, Self::Error> {
+        let delta = delta.delta.ok_or(FlameError::InvalidConfig(
+            "state delta is empty".to_string(),
+        ))?;
+
+        Ok(match delta {
+            Delta::Application(app) => StateDelta::Application(Application::try_from(&app)?),
+            Delta::RemovedApplication(name) => StateDelta::RemovedApplication(name),
+            Delta::Session(ssn) => StateDelta::Session(Session::try_from(&ssn)?),
+            Delta::RemovedSession(id) => StateDelta::RemovedSession(id),
+            Delta::Task(task) => StateDelta::Task(Task::try_from(&task)?),
+            Delta::Synced(_) => StateDelta::Synced,
+        })
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use std::path::Path;
+
+    use common::ctx::FlameStandby;
+
+    use super::*;
+
+    fn cluster(standby: Option<&str>, token_file: Option<&Path>) -> FlameCluster {
+        FlameCluster {
+            endpoint: "http://flame-session-manager-1:8080".to_string(),
+            standby: standby.map(|leader| FlameStandby {
+                leader: leader.to_string(),
+            }),
+            replication: token_file.map(|token_file| FlameReplication {
+                token_file: token_file.to_string_lossy().to_string(),
+            }),
+            ..Default::default()
+        }
+    }
+
+    #[test]
+    fn test_replica_intercept() {
+        let leader = new_replica(&cluster(None, None));
+        assert!(!leader.is_standby());
+        assert!(leader.intercept(Request::new(())).is_ok());
+        assert_eq!(leader.status().unwrap().role, ReplicaRole::Leader as i32);
+
+        let standby = new_replica(&cluster(Some("http://flame-session-manager-0:8080"), None));
+        assert!(standby.is_standby());
+        let status = standby.intercept(Request::new(())).unwrap_err();
+        assert_eq!(status.code(), tonic::Code::Unavailable);
+        assert_eq!(
+            status.metadata().get(LEADER_METADATA).unwrap(),
+            "http://flame-session-manager-0:8080"
+        );
+
+        // The promoted standby serves the requests as the leader.
+        standby.promote();
+        assert!(!standby.is_standby());
+        assert!(standby.intercept(Request::new(())).is_ok());
+        assert_eq!(standby.status().unwrap().role, ReplicaRole::Leader as i32);
+
+        // The fenced leader redirects the requests to the new leader.
+        *leader.fenced.lock().unwrap() = Some("http://flame-session-manager-1:8080".to_string());
+        let status = leader.intercept(Request::new(())).unwrap_err();
+        assert_eq!(status.code(), tonic::Code::Unavailable);
+        assert_eq!(
+            status.metadata().get(LEADER_METADATA).unwrap(),
+            "http://flame-session-manager-1:8080"
+        );
+        let status = leader.status().unwrap();
+        assert_eq!(status.role, ReplicaRole::Fenced as i32);
+        assert_eq!(
+            status.leader.as_deref(),
+            Some("http://flame-session-manager-1:8080")
+        );
+    }
+
+    #[test]
+    fn test_replica_authorize() {
+        let tmp_dir = tempfile::tempdir().unwrap();
+        let token_file = tmp_dir.path().join("replication-token");
+        std::fs::write(&token_file, "s3cr3t\n").unwrap();
+
+        let standby = new_replica(&cluster(
+            Some("http://flame-session-manager-0:8080"),
+            Some(&token_file),
+        ));
+        let req = standby.authorized(StreamStateRequest {}).unwrap();
+        assert_eq!(
+            req.metadata().get(AUTHORIZATION_METADATA).unwrap(),
+            "Bearer s3cr3t"
+        );
+        assert!(standby.authorize(&req).is_ok());
+
+        // The calls without the token or with another token are rejected.
+        let status = standby
+            .authorize(&Request::new(StreamStateRequest {}))
+            .unwrap_err();
+        assert_eq!(status.code(), tonic::Code::Unauthenticated);
+        let mut req = Request::new(StreamStateRequest {});
+        req.metadata_mut()
+            .insert(AUTHORIZATION_METADATA, "Bearer s3cr3u".parse().unwrap());
+        assert!(standby.authorize(&req).is_err());
+
+        // All the calls are rejected without the token configured.
+        let leader = new_replica(&cluster(None, None));
+        let req = standby.authorized(StreamStateRequest {}).unwrap();
+        let status = leader.authorize(&req).unwrap_err();
+        assert_eq!(status.code(), tonic::Code::Unauthenticated);
+        assert!(leader.authorized(StreamStateRequest {}).is_err());
+
+        assert!(token_eq(b"s3cr3t", b"s3cr3t"));
+        assert!(!token_eq(b"s3cr3t", b"s3cr3"));
+    }
+
+    #[test]
+    fn test_state_delta_rpc() {
+        let task = Task {
+            id: 1,
+            ssn_id: "ssn-1".to_string(),
+            version: 2,
+            ..Default::default()
+        };
+
+        let delta = StateDelta::try_from(rpc::StateDelta::from(StateDelta::Task(task))).unwrap();
+        assert!(matches!(delta, StateDelta::Task(task) if task.id == 1 && task.version == 2));
+
+        let delta = StateDelta::try_from(rpc::StateDelta::from(StateDelta::Synced)).unwrap();
+        assert!(matches!(delta, StateDelta::Synced));
+
+        assert!(StateDelta::try_from(rpc::StateDelta { delta: None }).is_err());
+    }
+}
@@ -71,6 +71,15 @@ impl ConnectionStates for ClosedState {
         )))
     }
 
+    async fn notify_executors(&self, _executors: Vec<Executor>) -> Result<(), FlameError> {
+        let conn = lock_ptr!(self.connection)?;
+
+        Err(FlameError::InvalidState(format!(
+            "Cannot notify executors to node <{}>, connection is closed",
+            conn.node_name
+        )))
+    }
+
     fn state(&self) -> ConnectionState {
         ConnectionState::Closed
     }
@@ -70,6 +70,14 @@ impl ConnectionStates for ConnectedState {
         sender.send(executor.clone()).await
     }
 
+    async fn notify_executors(&self, executors: Vec<Executor>) -> Result<(), FlameError> {
+        let sender = {
+            let conn = lock_ptr!(self.connection)?;
+            conn.sender()
+        };
+        sender.send_all(executors).await
+    }
+
     fn state(&self) -> ConnectionState {
         ConnectionState::Connected
     }
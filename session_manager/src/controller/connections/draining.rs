@@ -92,6 +92,15 @@ impl ConnectionStates for DrainingState {
         )))
     }
 
+    async fn notify_executors(&self, _executors: Vec<Executor>) -> Result<(), FlameError> {
+        let conn = lock_ptr!(self.connection)?;
+
+        Err(FlameError::InvalidState(format!(
+            "Cannot notify executors to node <{}> in Draining state",
+            conn.node_name
+        )))
+    }
+
     fn state(&self) -> ConnectionState {
         ConnectionState::Draining
     }
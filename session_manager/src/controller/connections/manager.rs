@@ -22,6 +22,7 @@ use std::time::Duration;
 
 use tokio_util::sync::CancellationToken;
 
+use common::clock::{self, ClockPtr};
 use common::FlameError;
 use stdng::{lock_ptr, MutexPtr};
 
@@ -42,6 +43,8 @@ pub struct ConnectionManager<C: ConnectionCallbacks> {
     connections: MutexPtr<HashMap<String, NodeConnectionPtr>>,
     /// Drain timeout duration
     drain_timeout: Duration,
+    /// Clock of the drain timers
+    clock: ClockPtr,
     /// Callbacks for connection events
     callbacks: Arc<C>,
 }
@@ -51,6 +54,7 @@ impl<C: ConnectionCallbacks> Clone for ConnectionManager<C> {
         Self {
             connections: self.connections.clone(),
             drain_timeout: self.drain_timeout,
+            clock: self.clock.clone(),
             callbacks: self.callbacks.clone(),
         }
     }
@@ -64,9 +68,15 @@ impl<C: ConnectionCallbacks> ConnectionManager<C> {
 
     /// Creates a new ConnectionManager with custom drain timeout.
     pub fn with_timeout(callbacks: C, drain_timeout: Duration) -> Self {
+        Self::with_clock(callbacks, drain_timeout, clock::system())
+    }
+
+    /// Creates a new ConnectionManager whose drain timers run on the clock.
+    pub fn with_clock(callbacks: C, drain_timeout: Duration, clock: ClockPtr) -> Self {
         ConnectionManager {
             connections: Arc::new(Mutex::new(HashMap::new())),
             drain_timeout,
+            clock,
             callbacks: Arc::new(callbacks),
         }
     }
@@ -180,10 +190,11 @@ impl<C: ConnectionCallbacks> ConnectionManager<C> {
         let node_name_clone = node_name.to_string();
         let manager = self.clone();
         let conn_ptr_clone = conn_ptr;
+        let clock = self.clock.clone();
 
         tokio::spawn(async move {
             tokio::select! {
-                _ = tokio::time::sleep(timeout) => {
+                _ = clock.sleep(timeout) => {
                     // Timer expired, trigger shutdown via state machine
                     if let Err(e) = manager.handle_drain_timeout(&node_name_clone, conn_ptr_clone).await {
                         tracing::error!(
@@ -263,11 +274,54 @@ impl<C: ConnectionCallbacks> ConnectionManager<C> {
         let state_handler = from(conn_ptr)?;
         state_handler.notify_executor(executor).await
     }
+
+    /// Notifies the nodes about the executor updates, e.g. the executors bound
+    /// in a scheduling cycle; the connections are looked up once, and the
+    /// executors of a node are sent in one batch. The failed nodes are logged
+    /// and skipped, e.g. disconnected, so the other nodes are still notified.
+    pub async fn notify_executors(&self, executors: &[Executor]) -> Result<(), FlameError> {
+        let mut by_node: HashMap<&str, Vec<&Executor>> = HashMap::new();
+        for executor in executors {
+            by_node.entry(&executor.node).or_default().push(executor);
+        }
+
+        let conn_ptrs = {
+            let connections = lock_ptr!(self.connections)?;
+            by_node
+                .into_iter()
+                .map(|(node_name, executors)| {
+                    (node_name, connections.get(node_name).cloned(), executors)
+                })
+                .collect::<Vec<_>>()
+        };
+
+        for (node_name, conn_ptr, executors) in conn_ptrs {
+            let Some(conn_ptr) = conn_ptr else {
+                tracing::debug!("No connection for node <{node_name}>, skip its executors");
+                continue;
+            };
+
+            let res = match from(conn_ptr) {
+                Ok(state_handler) => {
+                    state_handler
+                        .notify_executors(executors.into_iter().cloned().collect())
+                        .await
+                }
+                Err(e) => Err(e),
+            };
+            if let Err(e) = res {
+                tracing::debug!("Failed to notify node <{node_name}> about executors: {e}");
+            }
+        }
+
+        Ok(())
+    }
 }
 
 #[cfg(test)]
 mod tests {
     use super::*;
+    use common::clock::VirtualClock;
     use std::sync::atomic::{AtomicUsize, Ordering};
 
     struct TestCallbacks {
@@ -358,4 +412,77 @@ mod tests {
         manager.connect("node1").await.unwrap();
         assert!(manager.is_connected("node1"));
     }
+
+    #[tokio::test]
+    async fn test_drain_timeout_with_virtual_clock() {
+        let callbacks = TestCallbacks::new();
+        let clock = VirtualClock::new_ptr();
+        let manager =
+            ConnectionManager::with_clock(callbacks, Duration::from_secs(30), clock.clone());
+
+        manager.connect("node1").await.unwrap();
+        manager.drain("node1").await.unwrap();
+
+        // The drain timer does not fire before the virtual time reaches it.
+        tokio::task::yield_now().await;
+        clock.advance(Duration::from_secs(29));
+        tokio::task::yield_now().await;
+        assert_eq!(manager.get_state("node1"), Some(ConnectionState::Draining));
+
+        clock.advance(Duration::from_secs(1));
+        for _ in 0..100 {
+            if manager.get_state("node1").is_none() {
+                break;
+            }
+            tokio::task::yield_now().await;
+        }
+        assert_eq!(manager.get_state("node1"), None);
+        assert_eq!(manager.callbacks.closed_count.load(Ordering::SeqCst), 1);
+    }
+
+    #[tokio::test]
+    async fn test_notify_executors() {
+        let callbacks = TestCallbacks::new();
+        let manager = ConnectionManager::with_timeout(callbacks, Duration::from_secs(10));
+
+        let (_sender1, receiver1) = manager.connect("node1").await.unwrap();
+        let (_sender2, receiver2) = manager.connect("node2").await.unwrap();
+
+        let executor = |id: &str, node: &str| Executor {
+            id: id.to_string(),
+            node: node.to_string(),
+            ..Default::default()
+        };
+        let ids =
+            |executors: Vec<Executor>| executors.into_iter().map(|e| e.id).collect::<Vec<String>>();
+        // The executors of the unknown node are skipped, and the executors of
+        // a node are sent in one batch.
+        let executors = vec![
+            executor("exec-1", "node1"),
+            executor("exec-2", "node2"),
+            executor("exec-3", "node3"),
+            executor("exec-4", "node1"),
+        ];
+        manager.notify_executors(&executors).await.unwrap();
+
+        assert_eq!(
+            ids(receiver1.recv().await.unwrap()),
+            vec!["exec-1", "exec-4"]
+        );
+        assert_eq!(ids(receiver2.recv().await.unwrap()), vec!["exec-2"]);
+
+        // The other nodes are still notified if a node fails, e.g. draining.
+        manager.drain("node1").await.unwrap();
+        let executors = vec![
+            executor("exec-5", "node1"),
+            executor("exec-6", "node2"),
+            executor("exec-7", "node2"),
+        ];
+        manager.notify_executors(&executors).await.unwrap();
+
+        assert_eq!(
+            ids(receiver2.recv().await.unwrap()),
+            vec!["exec-6", "exec-7"]
+        );
+    }
 }
@@ -90,6 +90,11 @@ pub trait ConnectionStates: Send + Sync + 'static {
     /// Invalid from: Draining, Closed
     async fn notify_executor(&self, executor: &Executor) -> Result<(), FlameError>;
 
+    /// Notify the node about the executor updates in one batch.
+    /// Valid from: Connected
+    /// Invalid from: Draining, Closed
+    async fn notify_executors(&self, executors: Vec<Executor>) -> Result<(), FlameError>;
+
     /// Get the current state.
     fn state(&self) -> ConnectionState;
 }
@@ -0,0 +1,131 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//! The dependencies between the tasks of a session, i.e. `depends_on`: a task
+//! is not launched until all of its parents succeeded, see
+//! `Session::pop_pending_task`, and it's failed if any of them failed. The
+//! parents must be created before the task, so the tasks are always a DAG.
+
+use std::collections::HashSet;
+
+use stdng::trace_fn;
+
+use common::apis::{SessionID, Task, TaskGID, TaskID, TaskState};
+use common::FlameError;
+
+use crate::controller::Controller;
+
+/// The pending tasks which depend on the failed task directly or through
+/// other tasks, in the order of their id.
+fn dependents(mut tasks: Vec<Task>, failed: TaskID) -> Vec<TaskID> {
+    // The parents are created before their children, so one pass in the
+    // order of task id finds all the descendants.
+    tasks.sort_by_key(|task| task.id);
+
+    let mut failed = HashSet::from([failed]);
+    let mut res = vec![];
+    for task in tasks {
+        if task.state == TaskState::Pending && task.depends_on.iter().any(|id| failed.contains(id))
+        {
+            failed.insert(task.id);
+            res.push(task.id);
+        }
+    }
+
+    res
+}
+
+impl Controller {
+    /// Check the parents of the new task in the session: they must exist,
+    /// and must not have failed.
+    pub fn check_dependencies(
+        &self,
+        ssn_id: &SessionID,
+        depends_on: &[TaskID],
+    ) -> Result<(), FlameError> {
+        for id in depends_on {
+            let parent = self.get_task(ssn_id.clone(), *id).map_err(|e| match e {
+                FlameError::NotFound(_) => {
+                    FlameError::InvalidConfig(format!("unknown parent task <{ssn_id}/{id}>"))
+                }
+                e => e,
+            })?;
+            if matches!(parent.state, TaskState::Failed | TaskState::Cancelled) {
+                return Err(FlameError::InvalidState(format!(
+                    "parent task <{ssn_id}/{id}> is {:?}",
+                    parent.state
+                )));
+            }
+        }
+
+        Ok(())
+    }
+
+    /// Fail the pending tasks which depend on the failed task, as they will
+    /// never be launched.
+    pub async fn fail_dependents(
+        &self,
+        ssn_id: SessionID,
+        failed: TaskID,
+    ) -> Result<(), FlameError> {
+        trace_fn!("Controller::fail_dependents");
+        let ssn_ptr = self.storage.get_session_ptr(ssn_id.clone())?;
+        for task_id in dependents(self.list_task(ssn_id.clone())?, failed) {
+            let task_ptr = self.storage.get_task_ptr(TaskGID {
+                ssn_id: ssn_id.clone(),
+                task_id,
+            })?;
+            self.storage
+                .update_task_state(
+                    ssn_ptr.clone(),
+                    task_ptr,
+                    TaskState::Failed,
+                    Some(format!("parent task <{ssn_id}/{failed}> failed")),
+                )
+                .await?;
+        }
+
+        Ok(())
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn task(id: TaskID, state: TaskState, depends_on: Vec<TaskID>) -> Task {
+        Task {
+            id,
+            ssn_id: "ssn-1".to_string(),
+            state,
+            depends_on,
+            ..Default::default()
+        }
+    }
+
+    #[test]
+    fn test_dependents() {
+        let tasks = vec![
+            task(5, TaskState::Pending, vec![4]),
+            task(1, TaskState::Failed, vec![]),
+            task(2, TaskState::Pending, vec![1]),
+            task(3, TaskState::Pending, vec![]),
+            // The completed task is not failed again.
+            task(4, TaskState::Succeed, vec![1]),
+            task(6, TaskState::Pending, vec![2, 3]),
+        ];
+
+        assert_eq!(dependents(tasks.clone(), 1), vec![2, 6]);
+        assert_eq!(dependents(tasks, 3), vec![6]);
+    }
+}
@@ -0,0 +1,213 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//! The events of a session, e.g. a task completed or an executor bound, for
+//! the dashboards and orchestrators to react to the changes without polling
+//! the session; the watcher tracks the states of the tasks and executors of
+//! the session, and the changes since the last call are the events.
+
+use std::collections::{HashMap, HashSet};
+
+use stdng::trace_fn;
+
+use common::apis::{ExecutorID, Session, SessionID, SessionState, Task, TaskID, TaskState};
+use common::FlameError;
+
+use crate::controller::Controller;
+use crate::model::Executor;
+
+/// A change of a session.
+#[derive(Clone, Debug, PartialEq)]
+pub enum SessionEvent {
+    TaskCreated(TaskID),
+    /// The task succeeded.
+    TaskCompleted(TaskID),
+    /// The task failed or was cancelled.
+    TaskFailed(TaskID),
+    ExecutorBound(ExecutorID),
+    ExecutorUnbound(ExecutorID),
+    /// The current state of the session on the first call, then the changes.
+    StateChanged(SessionState),
+}
+
+/// The states of a session seen by a watcher, so only the changes are
+/// returned by the next call.
+#[derive(Default)]
+pub struct SessionWatcher {
+    state: Option<SessionState>,
+    tasks: HashMap<TaskID, TaskState>,
+    executors: HashSet<ExecutorID>,
+}
+
+impl SessionWatcher {
+    /// The events of the session since the last call: the state of the
+    /// session, the tasks in the order of their id, then the executors; the
+    /// tasks and executors of the first call are seen, and not the events.
+    pub fn events(
+        &mut self,
+        ssn: &Session,
+        mut tasks: Vec<Task>,
+        executors: &[Executor],
+    ) -> Vec<SessionEvent> {
+        let mut events = vec![];
+        let first = self.state.is_none();
+
+        if self.state != Some(ssn.status.state) {
+            self.state = Some(ssn.status.state);
+            events.push(SessionEvent::StateChanged(ssn.status.state));
+        }
+
+        tasks.sort_by_key(|task| task.id);
+        for task in tasks {
+            let last = self.tasks.insert(task.id, task.state);
+            if first || last == Some(task.state) {
+                continue;
+            }
+            if last.is_none() {
+                events.push(SessionEvent::TaskCreated(task.id));
+            }
+            match task.state {
+                TaskState::Succeed => events.push(SessionEvent::TaskCompleted(task.id)),
+                TaskState::Failed | TaskState::Cancelled => {
+                    events.push(SessionEvent::TaskFailed(task.id))
+                }
+                TaskState::Pending | TaskState::Running => {}
+            }
+        }
+
+        let bound = executors
+            .iter()
+            .filter(|exe| exe.ssn_id.as_ref() == Some(&ssn.id))
+            .map(|exe| exe.id.clone())
+            .collect::<HashSet<_>>();
+        if !first {
+            let mut unbound = self.executors.difference(&bound).collect::<Vec<_>>();
+            unbound.sort();
+            events.extend(
+                unbound
+                    .into_iter()
+                    .map(|id| SessionEvent::ExecutorUnbound(id.clone())),
+            );
+            let mut new = bound.difference(&self.executors).collect::<Vec<_>>();
+            new.sort();
+            events.extend(
+                new.into_iter()
+                    .map(|id| SessionEvent::ExecutorBound(id.clone())),
+            );
+        }
+        self.executors = bound;
+
+        events
+    }
+
+    /// Whether no more events are expected, i.e. the session is closed and
+    /// its executors are unbound.
+    pub fn is_done(&self) -> bool {
+        self.state == Some(SessionState::Closed) && self.executors.is_empty()
+    }
+}
+
+impl Controller {
+    /// The events of the session since the last call of the watcher.
+    pub fn session_events(
+        &self,
+        ssn_id: SessionID,
+        watcher: &mut SessionWatcher,
+    ) -> Result<Vec<SessionEvent>, FlameError> {
+        trace_fn!("Controller::session_events");
+        let ssn = self.get_session(ssn_id.clone())?;
+        let tasks = self.list_task(ssn_id)?;
+        let executors = self.list_executor()?;
+
+        Ok(watcher.events(&ssn, tasks, &executors))
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use common::apis::SessionStatus;
+
+    use super::*;
+
+    fn session(state: SessionState) -> Session {
+        Session {
+            id: "ssn-1".to_string(),
+            status: SessionStatus { state },
+            ..Default::default()
+        }
+    }
+
+    fn task(id: TaskID, state: TaskState) -> Task {
+        Task {
+            id,
+            ssn_id: "ssn-1".to_string(),
+            state,
+            ..Default::default()
+        }
+    }
+
+    fn executor(id: &str, ssn_id: Option<&str>) -> Executor {
+        Executor {
+            id: id.to_string(),
+            ssn_id: ssn_id.map(str::to_string),
+            ..Default::default()
+        }
+    }
+
+    #[test]
+    fn test_session_events() {
+        let mut watcher = SessionWatcher::default();
+
+        // The tasks and executors of the first call are seen, not the events.
+        let events = watcher.events(
+            &session(SessionState::Open),
+            vec![task(1, TaskState::Succeed), task(2, TaskState::Running)],
+            &[executor("exe-1", Some("ssn-1")), executor("exe-2", None)],
+        );
+        assert_eq!(events, vec![SessionEvent::StateChanged(SessionState::Open)]);
+
+        let events = watcher.events(
+            &session(SessionState::Open),
+            vec![
+                task(3, TaskState::Pending),
+                task(2, TaskState::Failed),
+                task(1, TaskState::Succeed),
+                task(4, TaskState::Succeed),
+            ],
+            &[executor("exe-1", None), executor("exe-2", Some("ssn-1"))],
+        );
+        assert_eq!(
+            events,
+            vec![
+                SessionEvent::TaskFailed(2),
+                SessionEvent::TaskCreated(3),
+                // The task completed between two calls is created first.
+                SessionEvent::TaskCreated(4),
+                SessionEvent::TaskCompleted(4),
+                SessionEvent::ExecutorUnbound("exe-1".to_string()),
+                SessionEvent::ExecutorBound("exe-2".to_string()),
+            ]
+        );
+        assert!(!watcher.is_done());
+
+        let events = watcher.events(&session(SessionState::Closed), vec![], &[]);
+        assert_eq!(
+            events,
+            vec![
+                SessionEvent::StateChanged(SessionState::Closed),
+                SessionEvent::ExecutorUnbound("exe-2".to_string()),
+            ]
+        );
+        assert!(watcher.is_done());
+    }
+}
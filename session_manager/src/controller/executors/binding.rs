@@ -16,7 +16,8 @@ use stdng::{lock_ptr, logs::TraceFn, trace_fn, MutexPtr};
 use crate::controller::executors::States;
 use crate::model::ExecutorPtr;
 use crate::storage::StoragePtr;
-use common::apis::{ExecutorState, SessionPtr, Task, TaskOutput, TaskPtr, TaskResult};
+use common::apis::executor::{transit, ExecutorEvent};
+use common::apis::SessionPtr;
 use common::FlameError;
 
 pub struct BindingState {
@@ -26,22 +27,8 @@ pub struct BindingState {
 
 #[async_trait::async_trait]
 impl States for BindingState {
-    async fn register_executor(&self) -> Result<(), FlameError> {
-        trace_fn!("BindingState::register_executor");
-
-        Err(FlameError::InvalidState("Executor is binding".to_string()))
-    }
-
-    async fn release_executor(&self) -> Result<(), FlameError> {
-        trace_fn!("BindingState::release_executor");
-
-        Err(FlameError::InvalidState("Executor is binding".to_string()))
-    }
-
-    async fn unregister_executor(&self) -> Result<(), FlameError> {
-        trace_fn!("BindingState::unregister_executor");
-
-        Err(FlameError::InvalidState("Executor is binding".to_string()))
+    fn executor(&self) -> &ExecutorPtr {
+        &self.executor
     }
 
     async fn bind_session(&self, ssn_ptr: SessionPtr) -> Result<(), FlameError> {
@@ -54,7 +41,7 @@ impl States for BindingState {
 
         let mut e = lock_ptr!(self.executor)?;
         e.ssn_id = Some(ssn_id);
-        e.state = ExecutorState::Binding;
+        e.state = transit(e.state, ExecutorEvent::BindSession)?;
 
         Ok(())
     }
@@ -63,37 +50,8 @@ impl States for BindingState {
         trace_fn!("BindingState::bind_session");
 
         let mut e = lock_ptr!(self.executor)?;
-        e.state = ExecutorState::Bound;
+        e.state = transit(e.state, ExecutorEvent::BindSessionCompleted)?;
 
         Ok(())
     }
-
-    async fn unbind_executor(&self) -> Result<(), FlameError> {
-        trace_fn!("BindingState::unbind_executor");
-
-        Err(FlameError::InvalidState("Executor is binding".to_string()))
-    }
-
-    async fn unbind_executor_completed(&self) -> Result<(), FlameError> {
-        trace_fn!("BindingState::unbind_executor_completed");
-
-        Err(FlameError::InvalidState("Executor is binding".to_string()))
-    }
-
-    async fn launch_task(&self, _ssn: SessionPtr) -> Result<Option<Task>, FlameError> {
-        trace_fn!("BindingState::launch_task");
-
-        Err(FlameError::InvalidState("Executor is binding".to_string()))
-    }
-
-    async fn complete_task(
-        &self,
-        _ssn: SessionPtr,
-        _task: TaskPtr,
-        _: TaskResult,
-    ) -> Result<(), FlameError> {
-        trace_fn!("BindingState::complete_task");
-
-        Err(FlameError::InvalidState("Executor is binding".to_string()))
-    }
 }
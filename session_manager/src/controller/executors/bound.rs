@@ -19,9 +19,9 @@ use chrono::{DateTime, Duration, Utc};
 use stdng::{lock_ptr, logs::TraceFn, trace_fn, MutexPtr};
 
 use crate::model::ExecutorPtr;
-use common::apis::{
-    ExecutorState, SessionPtr, SessionState, Task, TaskOutput, TaskPtr, TaskResult, TaskState,
-};
+use common::apis::executor::{transit, ExecutorEvent};
+use common::apis::{tags_of, SessionPtr, SessionState, Task, TaskPtr, TaskResult, TaskState};
+use common::clock::ClockPtr;
 use common::FlameError;
 
 use crate::controller::executors::States;
@@ -34,54 +34,55 @@ pub struct BoundState {
 
 #[async_trait::async_trait]
 impl States for BoundState {
-    async fn register_executor(&self) -> Result<(), FlameError> {
-        trace_fn!("BoundState::register_executor");
-
-        Err(FlameError::InvalidState("Executor is bound".to_string()))
+    fn executor(&self) -> &ExecutorPtr {
+        &self.executor
     }
 
-    async fn release_executor(&self) -> Result<(), FlameError> {
-        trace_fn!("BoundState::release_executor");
-
-        Err(FlameError::InvalidState("Executor is bound".to_string()))
-    }
+    async fn unbind_executor(&self) -> Result<(), FlameError> {
+        trace_fn!("BoundState::unbind_session");
 
-    async fn unregister_executor(&self) -> Result<(), FlameError> {
-        trace_fn!("BoundState::unregister_executor");
+        let mut e = lock_ptr!(self.executor)?;
+        e.state = transit(e.state, ExecutorEvent::Unbind)?;
 
-        Err(FlameError::InvalidState("Executor is bound".to_string()))
+        Ok(())
     }
 
-    async fn bind_session(&self, _ssn_ptr: SessionPtr) -> Result<(), FlameError> {
-        trace_fn!("BoundState::bind_session");
-
-        Err(FlameError::InvalidState("Executor is bound".to_string()))
+    async fn launch_task(&self, ssn_ptr: SessionPtr) -> Result<Option<Task>, FlameError> {
+        trace_fn!("BoundState::launch_task");
+        self.launch(ssn_ptr, true).await
     }
 
-    async fn bind_session_completed(&self) -> Result<(), FlameError> {
-        trace_fn!("BoundState::bind_session_completed");
-
-        Err(FlameError::InvalidState("Executor is bound".to_string()))
+    async fn try_launch_task(&self, ssn_ptr: SessionPtr) -> Result<Option<Task>, FlameError> {
+        trace_fn!("BoundState::try_launch_task");
+        self.launch(ssn_ptr, false).await
     }
 
-    async fn unbind_executor(&self) -> Result<(), FlameError> {
-        trace_fn!("BoundState::unbind_session");
-
-        let mut e = lock_ptr!(self.executor)?;
-        e.state = ExecutorState::Unbinding;
+    async fn complete_task(
+        &self,
+        ssn_ptr: SessionPtr,
+        task_ptr: TaskPtr,
+        task_result: TaskResult,
+    ) -> Result<(), FlameError> {
+        trace_fn!("BoundState::complete_task");
 
-        Ok(())
-    }
+        self.storage
+            .update_task_result(ssn_ptr, task_ptr, task_result)
+            .await?;
 
-    async fn unbind_executor_completed(&self) -> Result<(), FlameError> {
-        trace_fn!("BoundState::unbind_executor_completed");
+        {
+            let mut e = lock_ptr!(self.executor)?;
+            e.state = transit(e.state, ExecutorEvent::CompleteTask)?;
+            e.task_id = None;
+        };
 
-        Err(FlameError::InvalidState("Executor is bound".to_string()))
+        Ok(())
     }
+}
 
-    async fn launch_task(&self, ssn_ptr: SessionPtr) -> Result<Option<Task>, FlameError> {
-        trace_fn!("BoundState::launch_task");
-
+impl BoundState {
+    /// Launch a pending task of the session; wait for one until the delay
+    /// release of the application if `wait`, or return `None` immediately.
+    async fn launch(&self, ssn_ptr: SessionPtr, wait: bool) -> Result<Option<Task>, FlameError> {
         tracing::debug!("Launching task for session");
 
         let app_name = {
@@ -100,15 +101,32 @@ impl States for BoundState {
             app_ptr.delay_release
         );
 
-        let (batch_index, batch_size) = {
+        let (batch_index, batch_size, node) = {
             let executor = lock_ptr!(self.executor)?;
             let ssn = lock_ptr!(ssn_ptr)?;
-            (executor.batch_index, ssn.batch_size.max(1))
+            (
+                executor.batch_index,
+                ssn.batch_size.max(1),
+                executor.node.clone(),
+            )
         };
-
-        let task_ptr =
-            WaitForTaskFuture::new(&ssn_ptr, app_ptr.delay_release, batch_index, batch_size)
-                .await?;
+        // The tagged tasks are only launched on the nodes advertising the tags.
+        let tags = self
+            .storage
+            .get_node(&node)?
+            .map(|node| tags_of(&node.info.labels))
+            .unwrap_or_default();
+
+        let task_ptr = WaitForTaskFuture::new(
+            &ssn_ptr,
+            self.storage.clock(),
+            app_ptr.delay_release,
+            batch_index,
+            batch_size,
+            tags,
+            wait,
+        )
+        .await?;
         tracing::debug!("Got task!");
 
         let (exec_id, host) = {
@@ -159,6 +177,7 @@ impl States for BoundState {
 
         {
             let mut e = lock_ptr!(self.executor)?;
+            e.state = transit(e.state, ExecutorEvent::LaunchTask)?;
             e.task_id = Some(task_id);
             e.ssn_id = Some(ssn_id);
         };
@@ -167,49 +186,38 @@ impl States for BoundState {
         let task = lock_ptr!(task_ptr)?;
         Ok(Some((*task).clone()))
     }
-
-    async fn complete_task(
-        &self,
-        ssn_ptr: SessionPtr,
-        task_ptr: TaskPtr,
-        task_result: TaskResult,
-    ) -> Result<(), FlameError> {
-        trace_fn!("BoundState::complete_task");
-
-        self.storage
-            .update_task_result(ssn_ptr, task_ptr, task_result)
-            .await?;
-
-        {
-            let mut e = lock_ptr!(self.executor)?;
-            e.task_id = None;
-        };
-
-        Ok(())
-    }
 }
 
 struct WaitForTaskFuture {
     ssn: SessionPtr,
+    clock: ClockPtr,
     delay_release: Duration,
     start_time: DateTime<Utc>,
     batch_index: u32,
     batch_size: u32,
+    tags: Vec<String>,
+    wait: bool,
 }
 
 impl WaitForTaskFuture {
     pub fn new(
         ssn: &SessionPtr,
+        clock: ClockPtr,
         delay_release: Duration,
         batch_index: Option<u32>,
         batch_size: u32,
+        tags: Vec<String>,
+        wait: bool,
     ) -> Self {
         Self {
             ssn: ssn.clone(),
+            start_time: clock.utc_now(),
+            clock,
             delay_release,
-            start_time: Utc::now(),
             batch_index: batch_index.unwrap_or(0),
             batch_size: batch_size.max(1),
+            tags,
+            wait,
         }
     }
 }
@@ -220,11 +228,12 @@ impl Future for WaitForTaskFuture {
     fn poll(self: Pin<&mut Self>, ctx: &mut Context<'_>) -> Poll<Self::Output> {
         let mut ssn = lock_ptr!(self.ssn)?;
 
-        match ssn.pop_pending_task(self.batch_index, self.batch_size) {
+        let now = self.clock.utc_now();
+        match ssn.pop_pending_task(self.batch_index, self.batch_size, &self.tags, now) {
             None => {
-                let now = Utc::now();
                 let duration = now.signed_duration_since(self.start_time);
-                if duration.num_seconds() > self.delay_release.num_seconds()
+                if !self.wait
+                    || duration.num_seconds() > self.delay_release.num_seconds()
                     || ssn.status.state == SessionState::Closed
                 {
                     Poll::Ready(Ok(None))
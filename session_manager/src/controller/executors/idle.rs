@@ -17,7 +17,8 @@ use crate::controller::executors::States;
 use crate::model::ExecutorPtr;
 use crate::storage::StoragePtr;
 
-use common::apis::{ExecutorState, SessionPtr, Task, TaskOutput, TaskPtr, TaskResult};
+use common::apis::executor::{transit, ExecutorEvent};
+use common::apis::SessionPtr;
 use common::FlameError;
 
 pub struct IdleState {
@@ -27,10 +28,8 @@ pub struct IdleState {
 
 #[async_trait::async_trait]
 impl States for IdleState {
-    async fn register_executor(&self) -> Result<(), FlameError> {
-        trace_fn!("IdleState::register_executor");
-
-        Err(FlameError::InvalidState("Executor is idle".to_string()))
+    fn executor(&self) -> &ExecutorPtr {
+        &self.executor
     }
 
     async fn bind_session(&self, ssn_ptr: SessionPtr) -> Result<(), FlameError> {
@@ -43,58 +42,17 @@ impl States for IdleState {
 
         let mut e = lock_ptr!(self.executor)?;
         e.ssn_id = Some(ssn_id);
-        e.state = ExecutorState::Binding;
+        e.state = transit(e.state, ExecutorEvent::BindSession)?;
 
         Ok(())
     }
 
-    async fn unregister_executor(&self) -> Result<(), FlameError> {
-        trace_fn!("IdleState::unregister_executor");
-
-        Err(FlameError::InvalidState("Executor is idle".to_string()))
-    }
-
     async fn release_executor(&self) -> Result<(), FlameError> {
         trace_fn!("IdleState::release_executor");
 
         let mut e = lock_ptr!(self.executor)?;
-        e.state = ExecutorState::Releasing;
+        e.state = transit(e.state, ExecutorEvent::Release)?;
 
         Ok(())
     }
-
-    async fn bind_session_completed(&self) -> Result<(), FlameError> {
-        trace_fn!("IdleState::bind_session_completed");
-
-        Err(FlameError::InvalidState("Executor is idle".to_string()))
-    }
-
-    async fn unbind_executor(&self) -> Result<(), FlameError> {
-        trace_fn!("IdleState::unbind_executor");
-
-        Err(FlameError::InvalidState("Executor is idle".to_string()))
-    }
-
-    async fn unbind_executor_completed(&self) -> Result<(), FlameError> {
-        trace_fn!("IdleState::unbind_executor_completed");
-
-        Err(FlameError::InvalidState("Executor is idle".to_string()))
-    }
-
-    async fn launch_task(&self, _ssn: SessionPtr) -> Result<Option<Task>, FlameError> {
-        trace_fn!("IdleState::launch_task");
-
-        Err(FlameError::InvalidState("Executor is idle".to_string()))
-    }
-
-    async fn complete_task(
-        &self,
-        _ssn: SessionPtr,
-        _task: TaskPtr,
-        _: TaskResult,
-    ) -> Result<(), FlameError> {
-        trace_fn!("IdleState::complete_task");
-
-        Err(FlameError::InvalidState("Executor is idle".to_string()))
-    }
 }
@@ -20,6 +20,7 @@ use crate::controller::executors::{
 use crate::storage::StoragePtr;
 
 use crate::model::ExecutorPtr;
+use common::apis::executor::{transit, ExecutorEvent};
 use common::apis::{ExecutorID, ExecutorState, SessionPtr, Task, TaskOutput, TaskPtr, TaskResult};
 use common::FlameError;
 use stdng::{lock_ptr, new_ptr, MutexPtr};
@@ -64,25 +65,64 @@ pub fn from(storage: StoragePtr, exe_ptr: ExecutorPtr) -> Result<Arc<dyn States>
     }
 }
 
+/// Reject the event which is not handled by the current state of the
+/// executor; the error of the transition table names the state and the event.
+fn reject<T>(exe_ptr: &ExecutorPtr, event: ExecutorEvent) -> Result<T, FlameError> {
+    let e = lock_ptr!(exe_ptr)?;
+    transit(e.state, event)?;
+
+    Err(FlameError::Internal(format!(
+        "event <{event}> is not handled in executor state <{}>",
+        e.state
+    )))
+}
+
+/// The states handle the events allowed by the transition table and move the
+/// executor by `transit`; the other events are rejected by default.
 #[async_trait::async_trait]
 pub trait States: Send + Sync + 'static {
-    async fn register_executor(&self) -> Result<(), FlameError>;
-    async fn release_executor(&self) -> Result<(), FlameError>;
-    async fn unregister_executor(&self) -> Result<(), FlameError>;
+    fn executor(&self) -> &ExecutorPtr;
 
-    async fn bind_session(&self, ssn: SessionPtr) -> Result<(), FlameError>;
-    async fn bind_session_completed(&self) -> Result<(), FlameError>;
+    async fn register_executor(&self) -> Result<(), FlameError> {
+        reject(self.executor(), ExecutorEvent::Register)
+    }
+    async fn release_executor(&self) -> Result<(), FlameError> {
+        reject(self.executor(), ExecutorEvent::Release)
+    }
+    async fn unregister_executor(&self) -> Result<(), FlameError> {
+        reject(self.executor(), ExecutorEvent::Unregister)
+    }
 
-    async fn unbind_executor(&self) -> Result<(), FlameError>;
-    async fn unbind_executor_completed(&self) -> Result<(), FlameError>;
+    async fn bind_session(&self, _ssn: SessionPtr) -> Result<(), FlameError> {
+        reject(self.executor(), ExecutorEvent::BindSession)
+    }
+    async fn bind_session_completed(&self) -> Result<(), FlameError> {
+        reject(self.executor(), ExecutorEvent::BindSessionCompleted)
+    }
 
-    async fn launch_task(&self, ssn: SessionPtr) -> Result<Option<Task>, FlameError>;
+    async fn unbind_executor(&self) -> Result<(), FlameError> {
+        reject(self.executor(), ExecutorEvent::Unbind)
+    }
+    async fn unbind_executor_completed(&self) -> Result<(), FlameError> {
+        reject(self.executor(), ExecutorEvent::UnbindCompleted)
+    }
+
+    async fn launch_task(&self, _ssn: SessionPtr) -> Result<Option<Task>, FlameError> {
+        reject(self.executor(), ExecutorEvent::LaunchTask)
+    }
+    /// Launch a pending task without waiting for one, e.g. piggybacked on the
+    /// completion of the previous task; only the bound executors launch tasks.
+    async fn try_launch_task(&self, _ssn: SessionPtr) -> Result<Option<Task>, FlameError> {
+        Ok(None)
+    }
     async fn complete_task(
         &self,
-        ssn: SessionPtr,
-        task: TaskPtr,
-        task_result: TaskResult,
-    ) -> Result<(), FlameError>;
+        _ssn: SessionPtr,
+        _task: TaskPtr,
+        _task_result: TaskResult,
+    ) -> Result<(), FlameError> {
+        reject(self.executor(), ExecutorEvent::CompleteTask)
+    }
 }
 
 #[cfg(test)]
@@ -134,12 +174,14 @@ mod tests {
                 schedule_interval: 1000,
                 executors: FlameExecutors {
                     shim: Shim::default(),
+                    ..Default::default()
                 },
                 tls: None,
                 limits: FlameLimits {
                     max_sessions: None,
                     max_executors: 10,
                 },
+                ..Default::default()
             },
             cache: None,
         };
@@ -266,6 +308,20 @@ mod tests {
             assert!(result.is_err());
             assert!(matches!(result, Err(FlameError::InvalidState(_))));
         }
+
+        #[tokio::test]
+        async fn test_try_launch_task_returns_none() {
+            let exe_ptr = create_test_executor("exe-1", ExecutorState::Void);
+            let state = VoidState {
+                storage: create_mock_storage().await,
+                executor: exe_ptr.clone(),
+            };
+
+            let ssn_ptr = new_ptr(common::apis::Session::default());
+            let result = state.try_launch_task(ssn_ptr).await;
+
+            assert!(matches!(result, Ok(None)));
+        }
     }
 
     mod idle_state_tests {
@@ -1035,4 +1091,61 @@ mod tests {
             assert_eq!(get_state(&exe_ptr).unwrap(), ExecutorState::Released);
         }
     }
+
+    mod transition_tests {
+        use super::*;
+        use common::apis::executor::TRANSITIONS;
+        use strum::IntoEnumIterator;
+
+        async fn fire(state: Arc<dyn States>, event: ExecutorEvent) -> Result<(), FlameError> {
+            let ssn_ptr = new_ptr(common::apis::Session::default());
+            match event {
+                ExecutorEvent::Register => state.register_executor().await,
+                ExecutorEvent::BindSession => state.bind_session(ssn_ptr).await,
+                ExecutorEvent::BindSessionCompleted => state.bind_session_completed().await,
+                ExecutorEvent::LaunchTask => state.launch_task(ssn_ptr).await.map(|_| ()),
+                ExecutorEvent::CompleteTask => {
+                    let task_ptr = new_ptr(common::apis::Task::default());
+                    state
+                        .complete_task(ssn_ptr, task_ptr, TaskResult::default())
+                        .await
+                }
+                ExecutorEvent::Unbind => state.unbind_executor().await,
+                ExecutorEvent::UnbindCompleted => state.unbind_executor_completed().await,
+                ExecutorEvent::Release => state.release_executor().await,
+                ExecutorEvent::Unregister => state.unregister_executor().await,
+            }
+        }
+
+        #[tokio::test]
+        async fn test_events_not_in_transitions_are_rejected() {
+            for state in [
+                ExecutorState::Void,
+                ExecutorState::Idle,
+                ExecutorState::Binding,
+                ExecutorState::Bound,
+                ExecutorState::Unbinding,
+                ExecutorState::Releasing,
+            ] {
+                for event in ExecutorEvent::iter() {
+                    if TRANSITIONS
+                        .iter()
+                        .any(|(from, ev, _)| *from == state && *ev == event)
+                    {
+                        continue;
+                    }
+
+                    let exe_ptr = create_test_executor("exe-1", state);
+                    let states = from(create_mock_storage().await, exe_ptr.clone()).unwrap();
+                    let result = fire(states, event).await;
+
+                    assert!(
+                        matches!(result, Err(FlameError::InvalidState(_))),
+                        "event <{event}> was not rejected in state <{state}>"
+                    );
+                    assert_eq!(get_state(&exe_ptr).unwrap(), state);
+                }
+            }
+        }
+    }
 }
@@ -17,7 +17,7 @@ use crate::controller::executors::States;
 use crate::model::ExecutorPtr;
 use crate::storage::StoragePtr;
 
-use common::apis::{ExecutorState, SessionPtr, Task, TaskOutput, TaskPtr, TaskResult};
+use common::apis::executor::{transit, ExecutorEvent};
 use common::FlameError;
 
 pub struct ReleasingState {
@@ -27,81 +27,16 @@ pub struct ReleasingState {
 
 #[async_trait::async_trait]
 impl States for ReleasingState {
-    async fn register_executor(&self) -> Result<(), FlameError> {
-        trace_fn!("ReleasingState::register_executor");
-
-        Err(FlameError::InvalidState(
-            "Executor is releasing".to_string(),
-        ))
-    }
-
-    async fn release_executor(&self) -> Result<(), FlameError> {
-        trace_fn!("ReleasingState::release_executor");
-
-        Err(FlameError::InvalidState(
-            "Executor is releasing".to_string(),
-        ))
+    fn executor(&self) -> &ExecutorPtr {
+        &self.executor
     }
 
     async fn unregister_executor(&self) -> Result<(), FlameError> {
         trace_fn!("ReleasingState::unregister_executor");
 
         let mut e = lock_ptr!(self.executor)?;
-        e.state = ExecutorState::Released;
+        e.state = transit(e.state, ExecutorEvent::Unregister)?;
 
         Ok(())
     }
-
-    async fn bind_session(&self, ssn_ptr: SessionPtr) -> Result<(), FlameError> {
-        trace_fn!("ReleasingState::bind_session");
-
-        Err(FlameError::InvalidState(
-            "Executor is releasing".to_string(),
-        ))
-    }
-
-    async fn bind_session_completed(&self) -> Result<(), FlameError> {
-        trace_fn!("ReleasingState::bind_session_completed");
-
-        Err(FlameError::InvalidState(
-            "Executor is releasing".to_string(),
-        ))
-    }
-
-    async fn unbind_executor(&self) -> Result<(), FlameError> {
-        trace_fn!("ReleasingState::unbind_executor");
-
-        Err(FlameError::InvalidState(
-            "Executor is releasing".to_string(),
-        ))
-    }
-
-    async fn unbind_executor_completed(&self) -> Result<(), FlameError> {
-        trace_fn!("ReleasingState::unbind_executor_completed");
-
-        Err(FlameError::InvalidState(
-            "Executor is releasing".to_string(),
-        ))
-    }
-
-    async fn launch_task(&self, _ssn: SessionPtr) -> Result<Option<Task>, FlameError> {
-        trace_fn!("ReleasingState::launch_task");
-
-        Err(FlameError::InvalidState(
-            "Executor is releasing".to_string(),
-        ))
-    }
-
-    async fn complete_task(
-        &self,
-        _ssn: SessionPtr,
-        _task: TaskPtr,
-        _: TaskResult,
-    ) -> Result<(), FlameError> {
-        trace_fn!("ReleasingState::complete_task");
-
-        Err(FlameError::InvalidState(
-            "Executor is releasing".to_string(),
-        ))
-    }
 }
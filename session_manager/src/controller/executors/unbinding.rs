@@ -16,7 +16,8 @@ use crate::storage::StoragePtr;
 use stdng::{lock_ptr, logs::TraceFn, trace_fn, MutexPtr};
 
 use crate::model::ExecutorPtr;
-use common::apis::{ExecutorState, SessionPtr, Task, TaskOutput, TaskPtr, TaskResult, TaskState};
+use common::apis::executor::{transit, ExecutorEvent};
+use common::apis::{SessionPtr, Task, TaskPtr, TaskResult};
 use common::FlameError;
 
 pub struct UnbindingState {
@@ -26,51 +27,15 @@ pub struct UnbindingState {
 
 #[async_trait::async_trait]
 impl States for UnbindingState {
-    async fn register_executor(&self) -> Result<(), FlameError> {
-        trace_fn!("UnbindingState::register_executor");
-
-        Err(FlameError::InvalidState(
-            "Executor is unbinding".to_string(),
-        ))
-    }
-
-    async fn release_executor(&self) -> Result<(), FlameError> {
-        trace_fn!("UnbindingState::release_executor");
-
-        Err(FlameError::InvalidState(
-            "Executor is unbinding".to_string(),
-        ))
-    }
-
-    async fn unregister_executor(&self) -> Result<(), FlameError> {
-        trace_fn!("UnbindingState::unregister_executor");
-
-        Err(FlameError::InvalidState(
-            "Executor is unbinding".to_string(),
-        ))
-    }
-
-    async fn bind_session(&self, _ssn_ptr: SessionPtr) -> Result<(), FlameError> {
-        trace_fn!("UnbindingState::bind_session");
-
-        Err(FlameError::InvalidState(
-            "Executor is unbinding".to_string(),
-        ))
-    }
-
-    async fn bind_session_completed(&self) -> Result<(), FlameError> {
-        trace_fn!("UnbindingState::bind_session_completed");
-
-        Err(FlameError::InvalidState(
-            "Executor is unbinding".to_string(),
-        ))
+    fn executor(&self) -> &ExecutorPtr {
+        &self.executor
     }
 
     async fn unbind_executor(&self) -> Result<(), FlameError> {
         trace_fn!("UnbindingState::unbind_session");
 
         let mut e = lock_ptr!(self.executor)?;
-        e.state = ExecutorState::Unbinding;
+        e.state = transit(e.state, ExecutorEvent::Unbind)?;
 
         Ok(())
     }
@@ -79,7 +44,7 @@ impl States for UnbindingState {
         trace_fn!("UnbindingState::unbind_session_completed");
 
         let mut e = lock_ptr!(self.executor)?;
-        e.state = ExecutorState::Idle;
+        e.state = transit(e.state, ExecutorEvent::UnbindCompleted)?;
         e.ssn_id = None;
         e.task_id = None;
 
@@ -89,6 +54,10 @@ impl States for UnbindingState {
     async fn launch_task(&self, _ssn: SessionPtr) -> Result<Option<Task>, FlameError> {
         trace_fn!("UnbindingState::launch_task");
 
+        // No more tasks are launched while unbinding.
+        let e = lock_ptr!(self.executor)?;
+        transit(e.state, ExecutorEvent::LaunchTask)?;
+
         Ok(None)
     }
 
@@ -106,6 +75,7 @@ impl States for UnbindingState {
 
         {
             let mut e = lock_ptr!(self.executor)?;
+            e.state = transit(e.state, ExecutorEvent::CompleteTask)?;
             e.task_id = None;
             e.ssn_id = None;
         };
@@ -16,7 +16,7 @@ use crate::storage::StoragePtr;
 use stdng::{lock_ptr, logs::TraceFn, trace_fn, MutexPtr};
 
 use crate::model::ExecutorPtr;
-use common::apis::{ExecutorState, SessionPtr, Task, TaskOutput, TaskPtr, TaskResult};
+use common::apis::executor::{transit, ExecutorEvent};
 use common::FlameError;
 
 pub struct VoidState {
@@ -26,64 +26,15 @@ pub struct VoidState {
 
 #[async_trait::async_trait]
 impl States for VoidState {
+    fn executor(&self) -> &ExecutorPtr {
+        &self.executor
+    }
+
     async fn register_executor(&self) -> Result<(), FlameError> {
         trace_fn!("VoidState::register_executor");
         let mut e = lock_ptr!(self.executor)?;
-        e.state = ExecutorState::Idle;
+        e.state = transit(e.state, ExecutorEvent::Register)?;
 
         Ok(())
     }
-
-    async fn release_executor(&self) -> Result<(), FlameError> {
-        trace_fn!("VoidState::release_executor");
-
-        Err(FlameError::InvalidState("Executor is void".to_string()))
-    }
-
-    async fn unregister_executor(&self) -> Result<(), FlameError> {
-        trace_fn!("VoidState::unregister_executor");
-
-        Err(FlameError::InvalidState("Executor is void".to_string()))
-    }
-
-    async fn bind_session(&self, _ssn_ptr: SessionPtr) -> Result<(), FlameError> {
-        trace_fn!("VoidState::bind_session");
-
-        Err(FlameError::InvalidState("Executor is void".to_string()))
-    }
-
-    async fn bind_session_completed(&self) -> Result<(), FlameError> {
-        trace_fn!("VoidState::bind_session_completed");
-
-        Err(FlameError::InvalidState("Executor is void".to_string()))
-    }
-
-    async fn unbind_executor(&self) -> Result<(), FlameError> {
-        trace_fn!("VoidState::unbind_executor");
-
-        Err(FlameError::InvalidState("Executor is void".to_string()))
-    }
-
-    async fn unbind_executor_completed(&self) -> Result<(), FlameError> {
-        trace_fn!("VoidState::unbind_executor_completed");
-
-        Err(FlameError::InvalidState("Executor is void".to_string()))
-    }
-
-    async fn launch_task(&self, _ssn: SessionPtr) -> Result<Option<Task>, FlameError> {
-        trace_fn!("VoidState::launch_task");
-
-        Err(FlameError::InvalidState("Executor is void".to_string()))
-    }
-
-    async fn complete_task(
-        &self,
-        ssn_ptr: SessionPtr,
-        task_ptr: TaskPtr,
-        task_result: TaskResult,
-    ) -> Result<(), FlameError> {
-        trace_fn!("VoidState::complete_task");
-
-        Err(FlameError::InvalidState("Executor is void".to_string()))
-    }
 }
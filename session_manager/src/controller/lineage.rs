@@ -0,0 +1,126 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//! The provenance of the tasks, i.e. the version of the application and the
+//! checksum of the input when a task was created; with the principal and the
+//! parents of the task, see `dependencies`, the lineage of a task traces how
+//! its output was produced.
+
+use std::collections::HashSet;
+
+use stdng::lock_ptr;
+
+use common::apis::checksum;
+use common::apis::{SessionID, Task, TaskAttributes, TaskID, TaskProvenance};
+use common::FlameError;
+
+use crate::controller::Controller;
+
+/// The task and the tasks it depends on directly or through other tasks, in
+/// the order of their id, i.e. the parents first.
+fn lineage(mut tasks: Vec<Task>, task_id: TaskID) -> Vec<Task> {
+    // The parents are created before their children, so one pass in the
+    // reverse order of task id finds all the ancestors.
+    tasks.sort_by_key(|task| std::cmp::Reverse(task.id));
+
+    let mut ancestors = HashSet::from([task_id]);
+    let mut res = vec![];
+    for task in tasks {
+        if ancestors.remove(&task.id) {
+            ancestors.extend(task.depends_on.iter().copied());
+            res.push(task);
+        }
+    }
+
+    res.reverse();
+    res
+}
+
+impl Controller {
+    /// The provenance of the new task in the session.
+    pub async fn provenance_of(
+        &self,
+        ssn_id: &SessionID,
+        attr: &TaskAttributes,
+    ) -> Result<TaskProvenance, FlameError> {
+        let application = {
+            let ssn_ptr = self.storage.get_session_ptr(ssn_id.clone())?;
+            let ssn = lock_ptr!(ssn_ptr)?;
+            ssn.application.clone()
+        };
+        // The version is unknown, i.e. zero, if the application was not found.
+        let application_version = match self.get_application(application).await {
+            Ok(app) => app.version,
+            Err(FlameError::NotFound(_)) => 0,
+            Err(e) => return Err(e),
+        };
+
+        Ok(TaskProvenance {
+            application_version,
+            input_checksum: attr.input.as_deref().map(checksum::checksum),
+        })
+    }
+
+    /// The lineage of the task in the session, without the inputs and the
+    /// outputs of the tasks.
+    pub fn get_task_lineage(
+        &self,
+        ssn_id: SessionID,
+        task_id: TaskID,
+    ) -> Result<Vec<Task>, FlameError> {
+        // The unknown task is reported instead of an empty lineage.
+        self.get_task(ssn_id.clone(), task_id)?;
+
+        let tasks = lineage(self.list_task(ssn_id)?, task_id)
+            .into_iter()
+            .map(|mut task| {
+                task.input = None;
+                task.output = None;
+                task
+            })
+            .collect();
+
+        Ok(tasks)
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn task(id: TaskID, depends_on: Vec<TaskID>) -> Task {
+        Task {
+            id,
+            ssn_id: "ssn-1".to_string(),
+            depends_on,
+            ..Default::default()
+        }
+    }
+
+    #[test]
+    fn test_lineage() {
+        let tasks = vec![
+            task(4, vec![2, 3]),
+            task(1, vec![]),
+            task(2, vec![1]),
+            task(3, vec![]),
+            task(5, vec![4]),
+            task(6, vec![1]),
+        ];
+
+        let ids = |tasks: Vec<Task>| tasks.iter().map(|t| t.id).collect::<Vec<_>>();
+        assert_eq!(ids(lineage(tasks.clone(), 5)), vec![1, 2, 3, 4, 5]);
+        assert_eq!(ids(lineage(tasks.clone(), 2)), vec![1, 2]);
+        assert_eq!(ids(lineage(tasks, 6)), vec![1, 6]);
+    }
+}
@@ -11,32 +11,345 @@ See the License for the specific language governing permissions and
 limitations under the License.
 */
 
-use std::collections::HashSet;
+use std::collections::{HashMap, HashSet};
 use std::future::Future;
 use std::pin::Pin;
+use std::sync::atomic::{AtomicU64, Ordering};
 use std::sync::Arc;
 use std::task::{Context, Poll};
+use std::time::{Duration, Instant};
 
 use common::apis::{
     Application, ApplicationAttributes, ApplicationID, CommonData, Event, EventOwner, ExecutorID,
-    ExecutorState, Node, NodeState, Session, SessionAttributes, SessionID, SessionPtr,
-    SessionState, Task, TaskGID, TaskID, TaskInput, TaskOutput, TaskPtr, TaskResult, TaskState,
+    ExecutorState, Node, NodeState, ResourceRequirement, Session, SessionAttributes, SessionID,
+    SessionPtr, SessionState, Task, TaskAttributes, TaskGID, TaskID, TaskOutput, TaskPtr,
+    TaskResult, TaskState, TASK_OUTPUT_REF_PREFIX,
 };
 
+use chrono::{DateTime, Utc};
+use common::clock::{self, ClockPtr};
 use common::FlameError;
 use stdng::{lock_ptr, logs::TraceFn, trace_fn, MutexPtr};
+use tokio::sync::broadcast::{self, error::RecvError};
 
 use crate::model::{
-    ConnectionCallbacks, ConnectionState, Executor, ExecutorFilter, ExecutorPtr, NodeConnectionPtr,
-    NodeConnectionReceiver, NodeConnectionSender, NodeInfoPtr, SessionInfoPtr, SnapShotPtr,
+    ConnectionCallbacks, ConnectionState, Exclusion, Executor, ExecutorFilter, ExecutorPtr,
+    NodeConnectionPtr, NodeConnectionReceiver, NodeConnectionSender, NodeInfoPtr, SessionInfoPtr,
+    SnapShotPtr, DEFAULT_DRAIN_TIMEOUT_SECS,
 };
-use crate::storage::StoragePtr;
+use crate::storage::{StateChange, StoragePtr};
 
 mod connections;
+mod dependencies;
+mod events;
 mod executors;
+mod lineage;
 mod nodes;
+mod replication;
 
 pub use connections::ConnectionManager;
+pub use events::{SessionEvent, SessionWatcher};
+pub use replication::{StateDelta, StateTracker};
+
+/// The order of the outputs of a session.
+#[derive(Clone, Copy, Debug, Default, PartialEq, Eq)]
+pub enum OutputOrder {
+    /// The order in which the tasks were submitted, i.e. task id.
+    #[default]
+    Submission,
+    /// The order in which the tasks were completed; the task id breaks the tie.
+    Completion,
+}
+
+/// A page of the outputs, and the token of the next page if any.
+#[derive(Clone, Debug, Default)]
+pub struct OutputPage {
+    pub tasks: Vec<Task>,
+    pub next_page_token: Option<String>,
+}
+
+/// The position of a task in the order; the page token is the key of the last
+/// task of the previous page, so the pages are stable while other tasks are
+/// completing. The tasks not completed yet are after the completed ones in
+/// the completion order.
+fn output_key(task: &Task, order: OutputOrder) -> (i64, TaskID) {
+    match order {
+        OutputOrder::Submission => (0, task.id),
+        OutputOrder::Completion => (
+            task.completion_time
+                .map(|t| t.timestamp_millis())
+                .unwrap_or(i64::MAX),
+            task.id,
+        ),
+    }
+}
+
+fn parse_page_token(token: &str) -> Result<(i64, TaskID), FlameError> {
+    token
+        .split_once(':')
+        .and_then(|(t, id)| Some((t.parse().ok()?, id.parse().ok()?)))
+        .ok_or(FlameError::InvalidConfig(format!(
+            "invalid page token <{token}>"
+        )))
+}
+
+/// The page of the outputs after the page token; the page ends before the
+/// first task not completed in the order, so the cursor never passes a task
+/// completed later, e.g. a slow task in the submission order. The next page
+/// token is set while there're more outputs or tasks to be completed.
+fn collate_outputs(
+    tasks: Vec<Task>,
+    order: OutputOrder,
+    page_token: Option<&str>,
+    page_size: Option<usize>,
+) -> Result<OutputPage, FlameError> {
+    let after = page_token
+        .filter(|t| !t.is_empty())
+        .map(parse_page_token)
+        .transpose()?;
+
+    let mut tasks: Vec<_> = tasks
+        .into_iter()
+        .map(|t| (output_key(&t, order), t))
+        .filter(|(key, _)| after.map_or(true, |after| *key > after))
+        .collect();
+    tasks.sort_by_key(|(key, _)| *key);
+
+    let completed = tasks.iter().take_while(|(_, t)| t.is_completed()).count();
+    let page_size = page_size
+        .filter(|n| *n > 0)
+        .unwrap_or(completed)
+        .min(completed);
+    let next_page_token = if tasks.len() > page_size {
+        // The first task has a key after (0, 0) in both orders.
+        let (t, id) = match page_size {
+            0 => after.unwrap_or((0, 0)),
+            n => tasks[n - 1].0,
+        };
+        Some(format!("{t}:{id}"))
+    } else {
+        None
+    };
+    tasks.truncate(page_size);
+
+    Ok(OutputPage {
+        tasks: tasks.into_iter().map(|(_, t)| t).collect(),
+        next_page_token,
+    })
+}
+
+/// The filter of listing the tasks of a session.
+#[derive(Clone, Debug, Default)]
+pub struct TaskFilter {
+    /// The states of the tasks; all the states if empty.
+    pub states: Vec<TaskState>,
+    /// The tasks created at or after the time.
+    pub created_after: Option<DateTime<Utc>>,
+    /// The tasks created before the time.
+    pub created_before: Option<DateTime<Utc>>,
+    /// The tasks with all the labels.
+    pub labels: Vec<String>,
+}
+
+impl TaskFilter {
+    fn matches(&self, task: &Task) -> bool {
+        (self.states.is_empty() || self.states.contains(&task.state))
+            && self.created_after.map_or(true, |t| task.creation_time >= t)
+            && self.created_before.map_or(true, |t| task.creation_time < t)
+            && self.labels.iter().all(|l| task.labels.contains(l))
+    }
+}
+
+/// The page of the tasks matching the filter in the order of task id; the
+/// page starts after the task `after`, i.e. the last task of the previous
+/// page, so the pages are stable while other tasks are created.
+fn page_tasks(
+    tasks: Vec<Task>,
+    filter: &TaskFilter,
+    after: Option<TaskID>,
+    page_size: Option<usize>,
+) -> Vec<Task> {
+    let mut tasks: Vec<_> = tasks
+        .into_iter()
+        .filter(|t| after.map_or(true, |after| t.id > after) && filter.matches(t))
+        .collect();
+    tasks.sort_by_key(|t| t.id);
+
+    if let Some(page_size) = page_size.filter(|n| *n > 0) {
+        tasks.truncate(page_size);
+    }
+
+    tasks
+}
+
+/// The filter of listing the sessions.
+#[derive(Clone, Debug, Default)]
+pub struct SessionFilter {
+    /// The states of the sessions; all the states if empty.
+    pub states: Vec<SessionState>,
+    /// The sessions of the application; all the applications if not set.
+    pub application: Option<String>,
+    /// The sessions created at or after the time.
+    pub created_after: Option<DateTime<Utc>>,
+    /// The sessions created before the time.
+    pub created_before: Option<DateTime<Utc>>,
+    /// The sessions with all the labels.
+    pub labels: Vec<String>,
+}
+
+impl SessionFilter {
+    fn matches(&self, ssn: &Session) -> bool {
+        (self.states.is_empty() || self.states.contains(&ssn.status.state))
+            && self
+                .application
+                .as_ref()
+                .map_or(true, |app| &ssn.application == app)
+            && self.created_after.map_or(true, |t| ssn.creation_time >= t)
+            && self.created_before.map_or(true, |t| ssn.creation_time < t)
+            && self.labels.iter().all(|l| ssn.labels.contains(l))
+    }
+}
+
+/// The page of the sessions matching the filter in the order of session id;
+/// the page starts after the session `after`, i.e. the last session of the
+/// previous page.
+fn page_sessions(
+    sessions: Vec<Session>,
+    filter: &SessionFilter,
+    after: Option<&str>,
+    page_size: Option<usize>,
+) -> Vec<Session> {
+    let mut sessions: Vec<_> = sessions
+        .into_iter()
+        .filter(|s| after.map_or(true, |after| s.id.as_str() > after) && filter.matches(s))
+        .collect();
+    sessions.sort_by(|a, b| a.id.cmp(&b.id));
+
+    if let Some(page_size) = page_size.filter(|n| *n > 0) {
+        sessions.truncate(page_size);
+    }
+
+    sessions
+}
+
+/// The summary of the tasks of a session, so the progress can be tracked
+/// without listing every task.
+#[derive(Clone, Debug, Default, PartialEq)]
+pub struct SessionStats {
+    pub pending: u32,
+    pub running: u32,
+    pub succeed: u32,
+    pub failed: u32,
+    pub cancelled: u32,
+    /// The completed tasks per minute over the window.
+    pub throughput: f64,
+    /// The estimated time to complete the remaining tasks by the throughput;
+    /// None if no task was completed in the window.
+    pub eta: Option<Duration>,
+    /// Whether the session is closed, so no more tasks are to come.
+    pub closed: bool,
+}
+
+fn summarize_tasks(tasks: &[Task], now: DateTime<Utc>, window: Duration) -> SessionStats {
+    let mut stats = SessionStats::default();
+    for task in tasks {
+        match task.state {
+            TaskState::Pending => stats.pending += 1,
+            TaskState::Running => stats.running += 1,
+            TaskState::Succeed => stats.succeed += 1,
+            TaskState::Failed => stats.failed += 1,
+            TaskState::Cancelled => stats.cancelled += 1,
+        }
+    }
+
+    // The window is shortened to the age of the session's first task, so
+    // a young session is not under-estimated.
+    let window = chrono::Duration::from_std(window).unwrap_or(chrono::Duration::MAX);
+    let window = tasks
+        .iter()
+        .map(|t| now - t.creation_time)
+        .max()
+        .map_or(window, |age| age.min(window));
+    let since = now - window;
+
+    let completed = tasks
+        .iter()
+        .filter_map(|t| t.completion_time)
+        .filter(|t| *t > since)
+        .count();
+
+    let secs = window.num_milliseconds() as f64 / 1000.0;
+    if completed == 0 || secs <= 0.0 {
+        return stats;
+    }
+
+    let remaining = (stats.pending + stats.running) as f64;
+    stats.throughput = completed as f64 * 60.0 / secs;
+    stats.eta = Some(Duration::from_secs_f64(remaining * secs / completed as f64));
+
+    stats
+}
+
+/// The pre-check of submitting the tasks to a session, so the clients do not
+/// upload the inputs of the tasks which are rejected or never scheduled.
+#[derive(Clone, Debug, Default, PartialEq)]
+pub struct SubmissionCheck {
+    /// Whether the tasks are accepted, e.g. the session is open.
+    pub accepted: bool,
+    /// Whether the executors of the session fit the ready nodes.
+    pub feasible: bool,
+    /// The reasons why the tasks are rejected or infeasible.
+    pub reasons: Vec<String>,
+    /// The slots of the ready nodes not allocated to the executors.
+    pub idle_slots: u32,
+    /// The slots of all the ready nodes.
+    pub total_slots: u32,
+}
+
+/// Check whether the executors of the session fit the ready nodes: each
+/// executor fits one node, and the executors of a batch fit all the nodes.
+fn check_feasibility(
+    ssn: &Session,
+    nodes: &[Node],
+    executors: &[Executor],
+    unit: &ResourceRequirement,
+) -> SubmissionCheck {
+    let mut check = SubmissionCheck {
+        accepted: true,
+        feasible: true,
+        ..Default::default()
+    };
+
+    let mut largest = 0;
+    for node in nodes.iter().filter(|n| n.state == NodeState::Ready) {
+        let slots = node.allocatable.to_slots(unit);
+        let allocated: u32 = executors
+            .iter()
+            .filter(|e| e.node == node.name)
+            .map(|e| e.slots)
+            .sum();
+
+        largest = largest.max(slots);
+        check.total_slots += slots;
+        check.idle_slots += slots.saturating_sub(allocated);
+    }
+
+    if largest < ssn.slots {
+        check.feasible = false;
+        check.reasons.push(format!(
+            "the executor of <{}> slots does not fit any ready node, the largest one has <{largest}> slots",
+            ssn.slots
+        ));
+    } else if check.total_slots < ssn.slots * ssn.batch_size.max(1) {
+        check.feasible = false;
+        check.reasons.push(format!(
+            "the batch of <{}> executors does not fit the ready nodes of <{}> slots",
+            ssn.batch_size, check.total_slots
+        ));
+    }
+
+    check
+}
 
 /// Callbacks for node connection lifecycle events.
 /// Implements the state machine transitions for node states.
@@ -89,9 +402,82 @@ impl ConnectionCallbacks for NodeCallbacks {
     }
 }
 
+/// The execution lease of a running task; the task is presumed dead if the
+/// lease is not renewed before it expires.
+struct TaskLease {
+    gid: TaskGID,
+    expire_at: Instant,
+}
+
+/// An executor bound to a session by the scheduler.
+#[derive(Clone, Debug)]
+pub struct SessionBind {
+    pub exec_id: ExecutorID,
+    pub ssn_id: SessionID,
+    pub batch_index: Option<u32>,
+    /// Why the scheduler selected the executor, recorded as an event of the
+    /// session.
+    pub rationale: Option<String>,
+}
+
+/// The watch of a task registered by `Controller::task_watch`; the changes
+/// of the task after its snapshot are returned in order of the calls.
+pub struct TaskWatch {
+    gid: TaskGID,
+    /// The last state of the task seen by the watcher.
+    state: TaskState,
+    storage: StoragePtr,
+    changes: broadcast::Receiver<StateChange>,
+}
+
+impl TaskWatch {
+    /// Wait for the next state of the task; the completed task is returned
+    /// on any change, as it's not changed anymore.
+    pub async fn changed(&mut self) -> Result<Task, FlameError> {
+        loop {
+            match self.changes.recv().await {
+                Ok(StateChange::Task(changed)) if changed == self.gid => {}
+                // The session of the task was removed.
+                Ok(StateChange::Session(id)) if id == self.gid.ssn_id => {}
+                Ok(_) => continue,
+                // Some changes were dropped, so the task is checked again.
+                Err(RecvError::Lagged(_)) => {}
+                Err(RecvError::Closed) => {
+                    return Err(FlameError::Internal(
+                        "the changes of the storage are closed".to_string(),
+                    ))
+                }
+            }
+
+            let task_ptr = self.storage.get_task_ptr(self.gid.clone())?;
+            let task = lock_ptr!(task_ptr)?;
+            if task.state != self.state || task.is_completed() {
+                self.state = task.state;
+                return Ok(task.clone());
+            }
+        }
+    }
+}
+
 pub struct Controller {
     storage: StoragePtr,
     connection_manager: ConnectionManager<NodeCallbacks>,
+    leases: MutexPtr<HashMap<ExecutorID, TaskLease>>,
+    clock: ClockPtr,
+    /// The sessions bound to the executors since started, e.g. for the bind
+    /// rate of the timeline.
+    binds: AtomicU64,
+    /// The sessions being drained before closed; their new tasks are rejected.
+    draining: MutexPtr<HashSet<SessionID>>,
+    /// The sequences of the current launches of the executors; the requests
+    /// of an earlier launch, e.g. replayed ones, are rejected.
+    launches: MutexPtr<HashMap<ExecutorID, u64>>,
+    /// The sequence of the next launch; it starts from the time when the
+    /// session manager started, so the sequences are not reused after restarts.
+    next_launch: AtomicU64,
+    /// The first sequence of this startup; the smaller ones were issued before
+    /// the restart, and are not in `launches`.
+    launch_base: u64,
 }
 
 pub type ControllerPtr = Arc<Controller>;
@@ -100,9 +486,22 @@ pub fn new_ptr(storage: StoragePtr) -> ControllerPtr {
     let callbacks = NodeCallbacks {
         storage: storage.clone(),
     };
+    let clock = storage.clock();
+    let next_launch = clock.utc_now().timestamp_micros().max(0) as u64;
     Arc::new(Controller {
         storage,
-        connection_manager: ConnectionManager::new(callbacks),
+        connection_manager: ConnectionManager::with_clock(
+            callbacks,
+            Duration::from_secs(DEFAULT_DRAIN_TIMEOUT_SECS),
+            clock.clone(),
+        ),
+        leases: stdng::new_ptr(HashMap::new()),
+        clock,
+        binds: AtomicU64::new(0),
+        draining: stdng::new_ptr(HashSet::new()),
+        launches: stdng::new_ptr(HashMap::new()),
+        next_launch: AtomicU64::new(next_launch),
+        launch_base: next_launch,
     })
 }
 
@@ -116,6 +515,16 @@ impl Controller {
         &self.storage
     }
 
+    /// Returns the clock shared with the storage.
+    pub fn clock(&self) -> ClockPtr {
+        self.clock.clone()
+    }
+
+    /// Returns the sessions bound to the executors since started.
+    pub fn bind_count(&self) -> u64 {
+        self.binds.load(Ordering::Relaxed)
+    }
+
     // ========================================================================
     // Node Management
     // ========================================================================
@@ -323,9 +732,38 @@ impl Controller {
         self.storage.open_session(id, spec).await
     }
 
-    pub async fn close_session(&self, id: SessionID) -> Result<Session, FlameError> {
+    pub async fn close_session(
+        &self,
+        id: SessionID,
+        version: Option<u32>,
+    ) -> Result<Session, FlameError> {
         trace_fn!("Controller::close_session");
-        self.storage.close_session(id).await
+        self.storage.close_session(id, version).await
+    }
+
+    /// Drain the session before closing it: the new tasks are rejected, and
+    /// the pending and running tasks are waited for up to the timeout; the
+    /// rest are cancelled by closing the session.
+    pub async fn drain_session(
+        &self,
+        id: SessionID,
+        version: Option<u32>,
+        timeout: Duration,
+    ) -> Result<Session, FlameError> {
+        trace_fn!("Controller::drain_session");
+        self.storage.get_session(id.clone())?;
+
+        lock_ptr!(self.draining)?.insert(id.clone());
+        let res = match self
+            .wait_for_tasks(id.clone(), |_| true, Some(timeout))
+            .await
+        {
+            Ok(_) => self.close_session(id.clone(), version).await,
+            Err(e) => Err(e),
+        };
+        lock_ptr!(self.draining)?.remove(&id);
+
+        res
     }
 
     pub fn get_session(&self, id: SessionID) -> Result<Session, FlameError> {
@@ -340,12 +778,46 @@ impl Controller {
         self.storage.list_session()
     }
 
+    /// List a page of the sessions matching the filter in the order of
+    /// session id.
+    pub fn list_session_page(
+        &self,
+        filter: &SessionFilter,
+        after: Option<&str>,
+        page_size: Option<usize>,
+    ) -> Result<Vec<Session>, FlameError> {
+        trace_fn!("Controller::list_session_page");
+        let sessions = self.storage.list_session()?;
+        Ok(page_sessions(sessions, filter, after, page_size))
+    }
+
     pub async fn create_task(
         &self,
         ssn_id: SessionID,
-        task_input: Option<TaskInput>,
+        mut attr: TaskAttributes,
     ) -> Result<Task, FlameError> {
-        self.storage.create_task(ssn_id, task_input).await
+        if lock_ptr!(self.draining)?.contains(&ssn_id) {
+            return Err(FlameError::InvalidState(format!(
+                "session <{ssn_id}> is draining"
+            )));
+        }
+        self.check_dependencies(&ssn_id, &attr.depends_on)?;
+        // The replicated task keeps the provenance recorded by the leader.
+        if attr.provenance.is_none() {
+            attr.provenance = Some(self.provenance_of(&ssn_id, &attr).await?);
+        }
+
+        let task = self.storage.create_task(ssn_id.clone(), attr).await?;
+        // A parent may fail while the task is created, i.e. after its
+        // dependents were failed; fail the task as them.
+        for id in &task.depends_on {
+            if self.get_task(ssn_id.clone(), *id)?.state == TaskState::Failed {
+                self.fail_dependents(ssn_id.clone(), *id).await?;
+                return self.get_task(ssn_id, task.id);
+            }
+        }
+
+        Ok(task)
     }
 
     pub fn get_task(&self, ssn_id: SessionID, id: TaskID) -> Result<Task, FlameError> {
@@ -356,6 +828,78 @@ impl Controller {
         self.storage.list_task(ssn_id)
     }
 
+    /// List a page of the tasks matching the filter in the order of task id.
+    pub fn list_task_page(
+        &self,
+        ssn_id: SessionID,
+        filter: &TaskFilter,
+        after: Option<TaskID>,
+        page_size: Option<usize>,
+    ) -> Result<Vec<Task>, FlameError> {
+        trace_fn!("Controller::list_task_page");
+        let tasks = self.storage.list_task(ssn_id)?;
+        Ok(page_tasks(tasks, filter, after, page_size))
+    }
+
+    /// Get a page of the outputs of the completed tasks in the session, in a
+    /// stable order so the consumers do not have to reorder them.
+    pub fn get_session_outputs(
+        &self,
+        ssn_id: SessionID,
+        order: OutputOrder,
+        page_token: Option<String>,
+        page_size: Option<usize>,
+    ) -> Result<OutputPage, FlameError> {
+        trace_fn!("Controller::get_session_outputs");
+        let tasks = self.storage.list_task(ssn_id)?;
+        collate_outputs(tasks, order, page_token.as_deref(), page_size)
+    }
+
+    /// Get the summary of the tasks in the session; the throughput and ETA
+    /// are estimated by the tasks completed in the last `window`.
+    pub fn get_session_stats(
+        &self,
+        ssn_id: SessionID,
+        window: Duration,
+    ) -> Result<SessionStats, FlameError> {
+        trace_fn!("Controller::get_session_stats");
+        let ssn = self.storage.get_session(ssn_id.clone())?;
+        let tasks = self.storage.list_task(ssn_id)?;
+
+        Ok(SessionStats {
+            closed: ssn.status.state == SessionState::Closed,
+            ..summarize_tasks(&tasks, self.clock.utc_now(), window)
+        })
+    }
+
+    /// Check whether the tasks of the session would be accepted and fit the
+    /// nodes, measured in the slots of `unit`.
+    pub fn check_submission(
+        &self,
+        ssn_id: SessionID,
+        unit: &ResourceRequirement,
+    ) -> Result<SubmissionCheck, FlameError> {
+        trace_fn!("Controller::check_submission");
+        let ssn = self.storage.get_session(ssn_id.clone())?;
+        let nodes = self.storage.list_node()?;
+        let executors = self.storage.list_executor(None)?;
+
+        let mut check = check_feasibility(&ssn, &nodes, &executors, unit);
+        if ssn.status.state != SessionState::Open {
+            check.accepted = false;
+            check
+                .reasons
+                .push(format!("session <{ssn_id}> is not open"));
+        } else if lock_ptr!(self.draining)?.contains(&ssn_id) {
+            check.accepted = false;
+            check
+                .reasons
+                .push(format!("session <{ssn_id}> is draining"));
+        }
+
+        Ok(check)
+    }
+
     pub async fn update_task_result(
         &self,
         ssn: SessionPtr,
@@ -466,9 +1010,10 @@ impl Controller {
         &self,
         name: String,
         attr: ApplicationAttributes,
+        version: Option<u32>,
     ) -> Result<(), FlameError> {
         trace_fn!("Controller::update_application");
-        self.storage.update_application(name, attr).await
+        self.storage.update_application(name, attr, version).await
     }
 
     pub async fn list_application(&self) -> Result<Vec<Application>, FlameError> {
@@ -478,11 +1023,126 @@ impl Controller {
 
     pub async fn watch_task(&self, gid: TaskGID) -> Result<Task, FlameError> {
         trace_fn!("Controller::watch_task");
-        let task_ptr = self.storage.get_task_ptr(gid)?;
-        WatchTaskFuture::new(self.storage.clone(), &task_ptr)?.await?;
+        let (task, mut watch) = self.task_watch(gid)?;
+        if task.is_completed() {
+            return Ok(task);
+        }
+
+        watch.changed().await
+    }
+
+    /// Register the watch of the task, then take its snapshot, so no change
+    /// after the snapshot is missed by the watch.
+    pub fn task_watch(&self, gid: TaskGID) -> Result<(Task, TaskWatch), FlameError> {
+        trace_fn!("Controller::task_watch");
+        let changes = self.storage.subscribe_changes();
+        let task = {
+            let task_ptr = self.storage.get_task_ptr(gid.clone())?;
+            let task = lock_ptr!(task_ptr)?;
+            task.clone()
+        };
+
+        let watch = TaskWatch {
+            gid,
+            state: task.state,
+            storage: self.storage.clone(),
+            changes,
+        };
+
+        Ok((task, watch))
+    }
+
+    /// Create a task and wait for its completion; the task is returned in its
+    /// current state if it's not completed before the timeout.
+    pub async fn run_task(
+        &self,
+        ssn_id: SessionID,
+        attr: TaskAttributes,
+        timeout: Option<Duration>,
+    ) -> Result<Task, FlameError> {
+        trace_fn!("Controller::run_task");
+        let task = self.create_task(ssn_id, attr).await?;
+        let gid = TaskGID {
+            ssn_id: task.ssn_id.clone(),
+            task_id: task.id,
+        };
+
+        let wait = async {
+            loop {
+                let task = self.watch_task(gid.clone()).await?;
+                if task.is_completed() {
+                    return Ok(task);
+                }
+            }
+        };
+
+        match timeout {
+            None => wait.await,
+            Some(timeout) => match clock::timeout(self.clock.as_ref(), timeout, wait).await {
+                Some(res) => res,
+                None => self.get_task(gid.ssn_id, gid.task_id),
+            },
+        }
+    }
+
+    /// Wait for all the tasks of the group in the session to be completed,
+    /// i.e. the barrier of the group; the tasks created into the group while
+    /// waiting are waited for too. The summary of the group is returned in
+    /// its current state if it's not completed before the timeout.
+    pub async fn wait_for_group(
+        &self,
+        ssn_id: SessionID,
+        group: &str,
+        timeout: Option<Duration>,
+    ) -> Result<SessionStats, FlameError> {
+        trace_fn!("Controller::wait_for_group");
+        let tasks = self
+            .wait_for_tasks(ssn_id, |t| t.group.as_deref() == Some(group), timeout)
+            .await?;
+
+        // Only the states of the tasks are summarized.
+        Ok(summarize_tasks(
+            &tasks,
+            self.clock.utc_now(),
+            Duration::ZERO,
+        ))
+    }
+
+    /// Wait for the tasks of the session matching the filter to be completed;
+    /// the tasks are returned in their current states after the timeout.
+    async fn wait_for_tasks<F>(
+        &self,
+        ssn_id: SessionID,
+        filter: F,
+        timeout: Option<Duration>,
+    ) -> Result<Vec<Task>, FlameError>
+    where
+        F: Fn(&Task) -> bool,
+    {
+        let tasks_of = || -> Result<Vec<Task>, FlameError> {
+            let tasks = self.storage.list_task(ssn_id.clone())?;
+            Ok(tasks.into_iter().filter(|t| filter(t)).collect())
+        };
+
+        let wait = async {
+            loop {
+                let tasks = tasks_of()?;
+                match tasks.iter().find(|t| !t.is_completed()) {
+                    Some(task) => {
+                        self.watch_task(task.gid()).await?;
+                    }
+                    None => return Ok(tasks),
+                }
+            }
+        };
 
-        let task = lock_ptr!(task_ptr)?;
-        Ok((*task).clone())
+        match timeout {
+            None => wait.await,
+            Some(timeout) => match clock::timeout(self.clock.as_ref(), timeout, wait).await {
+                Some(res) => res,
+                None => tasks_of(),
+            },
+        }
     }
 
     pub async fn wait_for_session(&self, id: ExecutorID) -> Result<Option<Session>, FlameError> {
@@ -507,33 +1167,83 @@ impl Controller {
         batch_index: Option<u32>,
     ) -> Result<(), FlameError> {
         trace_fn!("Controller::bind_session");
+        self.bind_sessions(vec![SessionBind {
+            exec_id: id,
+            ssn_id,
+            batch_index,
+            rationale: None,
+        }])
+        .await
+    }
 
-        let exe_ptr = self.storage.get_executor_ptr(id.clone())?;
-        let state = executors::from(self.storage.clone(), exe_ptr.clone())?;
+    /// Bind the executors to the sessions at once, e.g. the binds of a
+    /// scheduling cycle: the executors are written to the storage in one call,
+    /// and each node is notified once about its executors. The other binds are
+    /// applied if one of them fails, and the first error is returned.
+    pub async fn bind_sessions(&self, binds: Vec<SessionBind>) -> Result<(), FlameError> {
+        trace_fn!("Controller::bind_sessions");
+
+        let mut first_err = None;
+        let mut executors = Vec::with_capacity(binds.len());
+        let mut rationales = vec![];
+        for bind in binds {
+            let exec_id = bind.exec_id.clone();
+            let rationale = bind.rationale.clone();
+            match self.prepare_bind(bind).await {
+                Ok(executor) => {
+                    if let (Some(rationale), Some(ssn_id)) = (rationale, &executor.ssn_id) {
+                        rationales.push((
+                            ssn_id.clone(),
+                            format!(
+                                "executor <{}> on node <{}> is bound: {rationale}",
+                                executor.id, executor.node
+                            ),
+                        ));
+                    }
+                    executors.push(executor);
+                }
+                Err(e) => {
+                    tracing::warn!("Failed to bind executor <{exec_id}>: {e}");
+                    first_err.get_or_insert(e);
+                }
+            }
+        }
 
-        let ssn_ptr = self.storage.get_session_ptr(ssn_id)?;
-        state.bind_session(ssn_ptr).await?;
+        self.storage.update_executors(&executors).await?;
+        self.binds
+            .fetch_add(executors.len() as u64, Ordering::Relaxed);
+        self.connection_manager.notify_executors(&executors).await?;
+
+        // The binds are applied already; the session may be closed since.
+        for (ssn_id, message) in rationales {
+            let event = Event {
+                code: ExecutorState::Binding.into(),
+                message: Some(message),
+                creation_time: self.clock.utc_now(),
+            };
+            if let Err(e) = self
+                .record_event(EventOwner::session(ssn_id.clone()), event)
+                .await
+            {
+                tracing::warn!("Failed to record the bind of session <{ssn_id}>: {e}");
+            }
+        }
 
-        let executor = {
-            let mut exe = lock_ptr!(exe_ptr)?;
-            exe.batch_index = batch_index;
-            (*exe).clone()
-        };
-        self.storage.update_executor(&executor).await?;
+        first_err.map_or(Ok(()), Err)
+    }
 
-        if let Err(e) = self
-            .connection_manager
-            .notify_executor(&executor.node, &executor)
-            .await
-        {
-            tracing::debug!(
-                "Failed to notify node <{}> about executor binding: {}",
-                executor.node,
-                e
-            );
-        }
+    /// Bind the executor to the session in memory, and return the executor
+    /// to be written and notified.
+    async fn prepare_bind(&self, bind: SessionBind) -> Result<Executor, FlameError> {
+        let exe_ptr = self.storage.get_executor_ptr(bind.exec_id)?;
+        let state = executors::from(self.storage.clone(), exe_ptr.clone())?;
 
-        Ok(())
+        let ssn_ptr = self.storage.get_session_ptr(bind.ssn_id)?;
+        state.bind_session(ssn_ptr).await?;
+
+        let mut exe = lock_ptr!(exe_ptr)?;
+        exe.batch_index = bind.batch_index;
+        Ok((*exe).clone())
     }
 
     pub async fn bind_session_completed(&self, id: ExecutorID) -> Result<(), FlameError> {
@@ -567,6 +1277,17 @@ impl Controller {
 
     pub async fn launch_task(&self, id: ExecutorID) -> Result<Option<Task>, FlameError> {
         trace_fn!("Controller::launch_task");
+        self.launch(id, true).await
+    }
+
+    /// Launch the next pending task of the executor's session, or `None` if
+    /// there's no pending task right now.
+    pub async fn try_launch_task(&self, id: ExecutorID) -> Result<Option<Task>, FlameError> {
+        trace_fn!("Controller::try_launch_task");
+        self.launch(id, false).await
+    }
+
+    async fn launch(&self, id: ExecutorID, wait: bool) -> Result<Option<Task>, FlameError> {
         let exe_ptr = self.storage.get_executor_ptr(id)?;
         let state = executors::from(self.storage.clone(), exe_ptr.clone())?;
         let (ssn_id, task_id) = {
@@ -596,7 +1317,8 @@ impl Controller {
         let ssn_ptr = self.storage.get_session_ptr(ssn_id.clone());
 
         let result = match ssn_ptr {
-            Ok(ssn_ptr) => state.launch_task(ssn_ptr).await,
+            Ok(ssn_ptr) if wait => state.launch_task(ssn_ptr).await,
+            Ok(ssn_ptr) => state.try_launch_task(ssn_ptr).await,
             Err(FlameError::NotFound(msg)) => {
                 tracing::warn!(
                     "Session <{:?}> not found when launching task: {}",
@@ -626,12 +1348,18 @@ impl Controller {
         result
     }
 
+    /// Complete the task running on the executor; the sequence of its launch
+    /// is consumed after the completion is persisted, so the completion is
+    /// not replayed, and it's retried by the executor if not persisted.
     pub async fn complete_task(
         &self,
         id: ExecutorID,
         task_result: TaskResult,
+        sequence: Option<u64>,
     ) -> Result<(), FlameError> {
         trace_fn!("Controller::complete_task");
+        self.check_launch(&id, sequence)?;
+
         let exe_ptr = self.storage.get_executor_ptr(id.clone())?;
         let (ssn_id, task_id, host) = {
             let exe = lock_ptr!(exe_ptr)?;
@@ -650,6 +1378,7 @@ impl Controller {
             task_id,
         })?;
         let ssn_ptr = self.storage.get_session_ptr(ssn_id.clone())?;
+        let task_result = self.expand_output(&ssn_id, task_result)?;
 
         let msg = match task_result.state {
             TaskState::Failed => task_result.message,
@@ -670,9 +1399,19 @@ impl Controller {
             message: msg,
             ..task_result
         };
+        let failed = task_result.state == TaskState::Failed;
 
         let state = executors::from(self.storage.clone(), exe_ptr.clone())?;
         state.complete_task(ssn_ptr, task_ptr, task_result).await?;
+        self.consume_launch(&id, sequence)?;
+        if failed {
+            self.fail_dependents(ssn_id.clone(), task_id).await?;
+        }
+
+        {
+            let mut leases = lock_ptr!(self.leases)?;
+            leases.remove(&id);
+        }
 
         let executor = {
             let exe = lock_ptr!(exe_ptr)?;
@@ -695,59 +1434,307 @@ impl Controller {
         Ok(())
     }
 
-    pub async fn unbind_executor(&self, id: ExecutorID) -> Result<(), FlameError> {
-        trace_fn!("Controller::unbind_executor");
-        let exe_ptr = self.storage.get_executor_ptr(id.clone())?;
-        let state = executors::from(self.storage.clone(), exe_ptr.clone())?;
-        state.unbind_executor().await?;
-
-        let executor = {
-            let exe = lock_ptr!(exe_ptr)?;
-            (*exe).clone()
+    /// Copy the output of the task referenced by the result, i.e. a repeated
+    /// output deduplicated by the executor, so the clients get the bytes.
+    fn expand_output(
+        &self,
+        ssn_id: &SessionID,
+        task_result: TaskResult,
+    ) -> Result<TaskResult, FlameError> {
+        let Some(id) = task_result
+            .output_ref
+            .as_deref()
+            .and_then(|r| r.strip_prefix(TASK_OUTPUT_REF_PREFIX))
+        else {
+            return Ok(task_result);
         };
-        self.storage.update_executor(&executor).await?;
+        let id = id.parse::<TaskID>().map_err(|_| {
+            FlameError::InvalidState(format!("invalid output reference <task://{id}>"))
+        })?;
 
-        if let Err(e) = self
-            .connection_manager
-            .notify_executor(&executor.node, &executor)
-            .await
-        {
-            tracing::debug!(
-                "Failed to notify node <{}> about executor unbinding: {}",
-                executor.node,
-                e
-            );
+        let task = self.get_task(ssn_id.clone(), id)?;
+        if task.output.is_none() && task.output_ref.is_none() {
+            return Err(FlameError::InvalidState(format!(
+                "no output of the referenced task <{ssn_id}/{id}>"
+            )));
         }
 
+        Ok(TaskResult {
+            output: task.output,
+            output_ref: task.output_ref,
+            ..task_result
+        })
+    }
+
+    /// Renew the lease of the task running on the executor; the task must be
+    /// the one launched by the executor.
+    pub fn renew_task_lease(
+        &self,
+        id: ExecutorID,
+        gid: TaskGID,
+        duration: Duration,
+        sequence: Option<u64>,
+    ) -> Result<(), FlameError> {
+        trace_fn!("Controller::renew_task_lease");
+
+        self.check_launch(&id, sequence)?;
+        self.check_running_task(&id, &gid)?;
+
+        let mut leases = lock_ptr!(self.leases)?;
+        leases.insert(
+            id,
+            TaskLease {
+                gid,
+                expire_at: self.clock.now() + duration,
+            },
+        );
+
         Ok(())
     }
 
-    pub async fn unbind_executor_completed(&self, id: ExecutorID) -> Result<(), FlameError> {
-        trace_fn!("Controller::unbind_executor_completed");
-        let exe_ptr = self.storage.get_executor_ptr(id.clone())?;
-        let state = executors::from(self.storage.clone(), exe_ptr.clone())?;
+    /// Record the diagnostic annotation of the task running on the executor,
+    /// e.g. its stacks when it's slow, as an event of the task.
+    pub async fn annotate_task(
+        &self,
+        id: ExecutorID,
+        gid: TaskGID,
+        annotation: String,
+    ) -> Result<(), FlameError> {
+        trace_fn!("Controller::annotate_task");
 
-        state.unbind_executor_completed().await?;
+        self.check_running_task(&id, &gid)?;
 
-        let executor = {
-            let exe = lock_ptr!(exe_ptr)?;
-            (*exe).clone()
+        let task_ptr = self.storage.get_task_ptr(gid)?;
+        let (owner, state) = {
+            let task = lock_ptr!(task_ptr)?;
+            (EventOwner::from(&*task), task.state)
         };
-        self.storage.update_executor(&executor).await?;
 
-        if let Err(e) = self
-            .connection_manager
-            .notify_executor(&executor.node, &executor)
-            .await
-        {
-            tracing::debug!(
-                "Failed to notify node <{}> about unbind completion: {}",
-                executor.node,
-                e
-            );
-        }
+        self.record_event(
+            owner,
+            Event {
+                code: state.into(),
+                message: Some(annotation),
+                creation_time: self.clock.utc_now(),
+            },
+        )
+        .await
+    }
 
-        Ok(())
+    /// Start a launch of the executor, and return its sequence echoed by the
+    /// requests of the launched task.
+    pub fn start_launch(&self, id: ExecutorID) -> Result<u64, FlameError> {
+        let sequence = self.next_launch.fetch_add(1, Ordering::Relaxed);
+        lock_ptr!(self.launches)?.insert(id, sequence);
+
+        Ok(sequence)
+    }
+
+    /// Check the sequence of the request against the current launch of the
+    /// executor; the requests without sequence, e.g. from the older
+    /// executors, are passed only if the executor has no recorded launch. The
+    /// sequence issued before the restart is adopted as the current launch if
+    /// the executor is still running a task, so the in-flight requests of the
+    /// task are not rejected.
+    fn check_launch(&self, id: &ExecutorID, sequence: Option<u64>) -> Result<(), FlameError> {
+        let mut launches = lock_ptr!(self.launches)?;
+        let current = launches.get(id).copied();
+
+        match (current, sequence) {
+            (None, None) => Ok(()),
+            (Some(current), Some(sequence)) if current == sequence => Ok(()),
+            (Some(_), None) => Err(FlameError::InvalidState(format!(
+                "no sequence of executor <{id}> which has a launch"
+            ))),
+            (None, Some(sequence)) if sequence < self.launch_base => {
+                let exe_ptr = self.storage.get_executor_ptr(id.clone())?;
+                if lock_ptr!(exe_ptr)?.task_id.is_none() {
+                    return Err(FlameError::InvalidState(format!(
+                        "no task of the sequence <{sequence}> on executor <{id}>"
+                    )));
+                }
+                launches.insert(id.clone(), sequence);
+
+                Ok(())
+            }
+            (_, Some(sequence)) => Err(FlameError::InvalidState(format!(
+                "stale sequence <{sequence}> of executor <{id}>, e.g. a replayed request"
+            ))),
+        }
+    }
+
+    /// Consume the sequence of the launch, so its requests are rejected
+    /// later; the sequence of a newer launch is kept.
+    fn consume_launch(&self, id: &ExecutorID, sequence: Option<u64>) -> Result<(), FlameError> {
+        let Some(sequence) = sequence else {
+            return Ok(());
+        };
+
+        let mut launches = lock_ptr!(self.launches)?;
+        if launches.get(id) == Some(&sequence) {
+            launches.remove(id);
+        }
+
+        Ok(())
+    }
+
+    fn check_running_task(&self, id: &ExecutorID, gid: &TaskGID) -> Result<(), FlameError> {
+        let exe_ptr = self.storage.get_executor_ptr(id.clone())?;
+        let exe = lock_ptr!(exe_ptr)?;
+        if exe.ssn_id.as_ref() != Some(&gid.ssn_id) || exe.task_id != Some(gid.task_id) {
+            return Err(FlameError::InvalidState(format!(
+                "task <{}/{}> is not running on executor <{}>",
+                gid.ssn_id, gid.task_id, id
+            )));
+        }
+
+        Ok(())
+    }
+
+    /// Re-queue the tasks whose lease was expired, and detach them from the
+    /// executors; the result reported by the executor later is rejected.
+    pub async fn expire_task_leases(&self) -> Result<(), FlameError> {
+        trace_fn!("Controller::expire_task_leases");
+
+        let expired: Vec<(ExecutorID, TaskGID)> = {
+            let mut leases = lock_ptr!(self.leases)?;
+            let now = self.clock.now();
+            let ids: Vec<ExecutorID> = leases
+                .iter()
+                .filter(|(_, lease)| lease.expire_at <= now)
+                .map(|(id, _)| id.clone())
+                .collect();
+
+            ids.into_iter()
+                .filter_map(|id| leases.remove(&id).map(|lease| (id, lease.gid)))
+                .collect()
+        };
+
+        for (id, gid) in expired {
+            let Ok(exe_ptr) = self.storage.get_executor_ptr(id.clone()) else {
+                continue;
+            };
+
+            let executor = {
+                let mut exe = lock_ptr!(exe_ptr)?;
+                // The task was completed or re-queued by others.
+                if exe.ssn_id.as_ref() != Some(&gid.ssn_id) || exe.task_id != Some(gid.task_id) {
+                    continue;
+                }
+                exe.task_id = None;
+                (*exe).clone()
+            };
+
+            tracing::warn!(
+                "The lease of task <{}/{}> on executor <{}> was expired, re-queue it",
+                gid.ssn_id,
+                gid.task_id,
+                id
+            );
+
+            self.storage
+                .requeue_task(
+                    gid,
+                    format!("Task was re-queued as its lease on executor <{id}> was expired."),
+                )
+                .await?;
+            self.storage.update_executor(&executor).await?;
+        }
+
+        Ok(())
+    }
+
+    /// Unbind the executor from its session; the reason of the executor, e.g.
+    /// its service is unhealthy, is recorded as an event of the session.
+    pub async fn unbind_executor(
+        &self,
+        id: ExecutorID,
+        reason: Option<String>,
+    ) -> Result<(), FlameError> {
+        trace_fn!("Controller::unbind_executor");
+        let ssn_id = {
+            let exe_ptr = self.storage.get_executor_ptr(id.clone())?;
+            let exe = lock_ptr!(exe_ptr)?;
+            exe.ssn_id.clone()
+        };
+
+        self.unbind_executors(vec![id.clone()]).await?;
+
+        if let (Some(ssn_id), Some(reason)) = (ssn_id, reason) {
+            self.record_event(
+                EventOwner::session(ssn_id),
+                Event {
+                    code: ExecutorState::Unbinding.into(),
+                    message: Some(format!("executor <{id}> is unbound: {reason}")),
+                    creation_time: self.clock.utc_now(),
+                },
+            )
+            .await?;
+        }
+
+        Ok(())
+    }
+
+    /// Unbind the executors at once, e.g. the executors preempted in a
+    /// scheduling cycle, like `bind_sessions`.
+    pub async fn unbind_executors(&self, ids: Vec<ExecutorID>) -> Result<(), FlameError> {
+        trace_fn!("Controller::unbind_executors");
+
+        let mut first_err = None;
+        let mut executors = Vec::with_capacity(ids.len());
+        for id in ids {
+            match self.prepare_unbind(id.clone()).await {
+                Ok(executor) => executors.push(executor),
+                Err(e) => {
+                    tracing::warn!("Failed to unbind executor <{id}>: {e}");
+                    first_err.get_or_insert(e);
+                }
+            }
+        }
+
+        self.storage.update_executors(&executors).await?;
+        self.connection_manager.notify_executors(&executors).await?;
+
+        first_err.map_or(Ok(()), Err)
+    }
+
+    /// Unbind the executor in memory, and return the executor to be written
+    /// and notified.
+    async fn prepare_unbind(&self, id: ExecutorID) -> Result<Executor, FlameError> {
+        let exe_ptr = self.storage.get_executor_ptr(id)?;
+        let state = executors::from(self.storage.clone(), exe_ptr.clone())?;
+        state.unbind_executor().await?;
+
+        let exe = lock_ptr!(exe_ptr)?;
+        Ok((*exe).clone())
+    }
+
+    pub async fn unbind_executor_completed(&self, id: ExecutorID) -> Result<(), FlameError> {
+        trace_fn!("Controller::unbind_executor_completed");
+        let exe_ptr = self.storage.get_executor_ptr(id.clone())?;
+        let state = executors::from(self.storage.clone(), exe_ptr.clone())?;
+
+        state.unbind_executor_completed().await?;
+
+        let executor = {
+            let exe = lock_ptr!(exe_ptr)?;
+            (*exe).clone()
+        };
+        self.storage.update_executor(&executor).await?;
+
+        if let Err(e) = self
+            .connection_manager
+            .notify_executor(&executor.node, &executor)
+            .await
+        {
+            tracing::debug!(
+                "Failed to notify node <{}> about unbind completion: {}",
+                executor.node,
+                e
+            );
+        }
+
+        Ok(())
     }
 
     pub async fn release_executor(&self, id: ExecutorID) -> Result<(), FlameError> {
@@ -812,44 +1799,78 @@ impl Controller {
         trace_fn!("Controller::record_event");
         self.storage.record_event(owner, event).await
     }
-}
-
-struct WatchTaskFuture {
-    storage: StoragePtr,
-    current_state: TaskState,
-    task_gid: TaskGID,
-}
 
-impl WatchTaskFuture {
-    pub fn new(storage: StoragePtr, task_ptr: &TaskPtr) -> Result<Self, FlameError> {
-        let task_ptr = task_ptr.clone();
-        let task = lock_ptr!(task_ptr)?;
-
-        Ok(Self {
-            storage,
-            current_state: task.state,
-            task_gid: TaskGID {
-                ssn_id: task.ssn_id.clone(),
-                task_id: task.id,
-            },
-        })
+    pub async fn rotate_tenant_key(&self, tenant: &str) -> Result<u32, FlameError> {
+        trace_fn!("Controller::rotate_tenant_key");
+        self.storage.rotate_tenant_key(tenant).await
     }
-}
 
-impl Future for WatchTaskFuture {
-    type Output = Result<(), FlameError>;
+    /// Exclude the applications crashing on the executor from its
+    /// bindings; the application which starts crash-looping is recorded as
+    /// an event of the session whose service crashed last.
+    pub async fn exclude_applications(
+        &self,
+        id: &ExecutorID,
+        apps: Vec<Exclusion>,
+    ) -> Result<(), FlameError> {
+        trace_fn!("Controller::exclude_applications");
+        let crash_looping = self.storage.exclude_applications(id, apps)?;
+
+        for app in crash_looping {
+            let event = Event {
+                code: ExecutorState::Unbinding.into(),
+                message: Some(format!(
+                    "application <{}> is crash-looping on executor <{id}>, excluded until <{}>",
+                    app.application, app.until
+                )),
+                creation_time: self.clock.utc_now(),
+            };
+            // The session may be closed or deleted since the crash.
+            if let Err(e) = self
+                .record_event(EventOwner::session(app.session_id.clone()), event)
+                .await
+            {
+                tracing::warn!(
+                    "Failed to record the crash-looping of application <{}> in session <{}>: {e}",
+                    app.application,
+                    app.session_id
+                );
+            }
+        }
 
-    fn poll(self: Pin<&mut Self>, ctx: &mut Context<'_>) -> Poll<Self::Output> {
-        let task_ptr = self.storage.get_task_ptr(self.task_gid.clone())?;
+        Ok(())
+    }
 
-        let task = lock_ptr!(task_ptr)?;
-        // If the state of task was updated, return ready.
-        if self.current_state != task.state || task.is_completed() {
-            return Poll::Ready(Ok(()));
-        }
+    /// Boost the priority of the open session, and return the previous one;
+    /// the boost is audited by an event of the session.
+    pub async fn boost_session(
+        &self,
+        id: &SessionID,
+        priority: u32,
+        by: &str,
+    ) -> Result<u32, FlameError> {
+        trace_fn!("Controller::boost_session");
+        let previous = self.storage.boost_session(id, priority)?;
+
+        self.record_event(
+            EventOwner::session(id.clone()),
+            Event {
+                code: SessionState::Open.into(),
+                message: Some(format!(
+                    "priority is boosted from <{previous}> to <{priority}> by <{by}>"
+                )),
+                creation_time: self.clock.utc_now(),
+            },
+        )
+        .await?;
 
-        ctx.waker().wake_by_ref();
-        Poll::Pending
+        Ok(previous)
+    }
+
+    /// The priority of the session, e.g. given to the services by the tasks;
+    /// None if it's not boosted.
+    pub fn session_priority(&self, id: &SessionID) -> Result<Option<u32>, FlameError> {
+        self.storage.session_priority(id)
     }
 }
 
@@ -895,6 +1916,11 @@ mod tests {
 
     /// Creates a test storage with a unique SQLite database.
     async fn create_test_storage() -> StoragePtr {
+        create_test_storage_with_clock(common::clock::system()).await
+    }
+
+    /// Creates a test storage whose timers run on the clock.
+    async fn create_test_storage_with_clock(clock: ClockPtr) -> StoragePtr {
         let unique_id = format!(
             "{}_{:?}",
             chrono::Utc::now().timestamp_nanos_opt().unwrap_or(0),
@@ -915,17 +1941,21 @@ mod tests {
                 schedule_interval: 1000,
                 executors: FlameExecutors {
                     shim: Shim::default(),
+                    ..Default::default()
                 },
                 tls: None,
                 limits: FlameLimits {
                     max_sessions: None,
                     max_executors: 10,
                 },
+                ..Default::default()
             },
             cache: None,
         };
 
-        crate::storage::new_ptr(&ctx).await.unwrap()
+        crate::storage::new_ptr_with_clock(&ctx, clock)
+            .await
+            .unwrap()
     }
 
     /// Creates a test node.
@@ -944,6 +1974,7 @@ mod tests {
             info: NodeInfo {
                 arch: "x86_64".to_string(),
                 os: "linux".to_string(),
+                ..Default::default()
             },
         }
     }
@@ -1112,6 +2143,32 @@ mod tests {
             assert_eq!(stored_node.state, NodeState::Unknown);
         }
 
+        #[tokio::test]
+        async fn test_drain_node_times_out_by_virtual_clock() {
+            let clock = common::clock::VirtualClock::new_ptr();
+            let storage = create_test_storage_with_clock(clock.clone()).await;
+            let controller = new_ptr(storage.clone());
+
+            let node = create_test_node("drain-timeout-node");
+            controller.register_node(&node, &[]).await.unwrap();
+            controller.drain_node("drain-timeout-node").await.unwrap();
+            tokio::task::yield_now().await;
+
+            // The node is shut down once the drain timeout elapses, without
+            // waiting for the real time.
+            clock.advance(Duration::from_secs(DEFAULT_DRAIN_TIMEOUT_SECS));
+            for _ in 0..100 {
+                let stored_node = storage.get_node("drain-timeout-node").unwrap().unwrap();
+                if stored_node.state == NodeState::NotReady {
+                    break;
+                }
+                tokio::task::yield_now().await;
+            }
+
+            let stored_node = storage.get_node("drain-timeout-node").unwrap().unwrap();
+            assert_eq!(stored_node.state, NodeState::NotReady);
+        }
+
         #[tokio::test]
         async fn test_drain_nonexistent_node_succeeds() {
             let storage = create_test_storage().await;
@@ -1123,4 +2180,1161 @@ mod tests {
             assert!(result.is_ok());
         }
     }
+
+    // ========================================================================
+    // Controller::run_task Tests
+    // ========================================================================
+
+    mod run_task_tests {
+        use super::*;
+
+        #[tokio::test]
+        async fn test_run_task_returns_pending_task_on_timeout() {
+            let clock = common::clock::VirtualClock::new_ptr();
+            let storage = create_test_storage_with_clock(clock.clone()).await;
+            let controller = new_ptr(storage.clone());
+
+            storage
+                .create_session(SessionAttributes {
+                    id: "run-task-ssn".to_string(),
+                    application: "flmtest".to_string(),
+                    slots: 1,
+                    common_data: None,
+                    min_instances: 0,
+                    max_instances: None,
+                    batch_size: 1,
+                    content_type: None,
+                    client_host: None,
+                    labels: vec![],
+                    environments: HashMap::new(),
+                })
+                .await
+                .unwrap();
+
+            let run = {
+                let controller = controller.clone();
+                tokio::spawn(async move {
+                    controller
+                        .run_task(
+                            "run-task-ssn".to_string(),
+                            TaskAttributes::default(),
+                            Some(Duration::from_secs(10)),
+                        )
+                        .await
+                })
+            };
+
+            // No executor picks up the task, so it's still pending when the
+            // timeout is reached; the clock is advanced after the timeout
+            // started.
+            clock.wait_for_sleepers(1).await;
+            clock.advance(Duration::from_secs(10));
+
+            let task = run.await.unwrap().unwrap();
+            assert_eq!(task.ssn_id, "run-task-ssn");
+            assert_eq!(task.state, TaskState::Pending);
+        }
+    }
+
+    mod task_watch_tests {
+        use super::*;
+
+        #[tokio::test]
+        async fn test_task_watch() {
+            let storage = create_test_storage().await;
+            let controller = new_ptr(storage.clone());
+
+            storage
+                .create_session(SessionAttributes {
+                    id: "watch-ssn".to_string(),
+                    application: "flmtest".to_string(),
+                    slots: 1,
+                    ..Default::default()
+                })
+                .await
+                .unwrap();
+            let task = controller
+                .create_task("watch-ssn".to_string(), TaskAttributes::default())
+                .await
+                .unwrap();
+            let update = |state| {
+                controller.update_task_state(
+                    storage.get_session_ptr(task.ssn_id.clone()).unwrap(),
+                    storage.get_task_ptr(task.gid()).unwrap(),
+                    state,
+                    None,
+                )
+            };
+
+            let (snapshot, mut watch) = controller.task_watch(task.gid()).unwrap();
+            assert_eq!(snapshot.state, TaskState::Pending);
+
+            // The change right after the snapshot is not missed, though no
+            // one is waiting for it yet.
+            update(TaskState::Running).await.unwrap();
+            let task = watch.changed().await.unwrap();
+            assert_eq!(task.state, TaskState::Running);
+
+            update(TaskState::Succeed).await.unwrap();
+            let task = watch.changed().await.unwrap();
+            assert_eq!(task.state, TaskState::Succeed);
+
+            // The completed task is returned at once.
+            let task = controller.watch_task(task.gid()).await.unwrap();
+            assert_eq!(task.state, TaskState::Succeed);
+        }
+    }
+
+    mod wait_for_tasks_tests {
+        use super::*;
+
+        #[tokio::test]
+        async fn test_wait_for_group() {
+            let clock = common::clock::VirtualClock::new_ptr();
+            let storage = create_test_storage_with_clock(clock.clone()).await;
+            let controller = new_ptr(storage.clone());
+
+            storage
+                .create_session(SessionAttributes {
+                    id: "group-ssn".to_string(),
+                    application: "flmtest".to_string(),
+                    slots: 1,
+                    common_data: None,
+                    min_instances: 0,
+                    max_instances: None,
+                    batch_size: 1,
+                    content_type: None,
+                    client_host: None,
+                    labels: vec![],
+                    environments: HashMap::new(),
+                })
+                .await
+                .unwrap();
+
+            let mut extract = vec![];
+            for group in [Some("extract"), Some("extract"), None] {
+                let task = controller
+                    .create_task(
+                        "group-ssn".to_string(),
+                        TaskAttributes {
+                            group: group.map(str::to_string),
+                            ..Default::default()
+                        },
+                    )
+                    .await
+                    .unwrap();
+                if group.is_some() {
+                    extract.push(task);
+                }
+            }
+
+            // The group is not completed before the timeout.
+            let wait = {
+                let controller = controller.clone();
+                tokio::spawn(async move {
+                    controller
+                        .wait_for_group(
+                            "group-ssn".to_string(),
+                            "extract",
+                            Some(Duration::from_secs(10)),
+                        )
+                        .await
+                })
+            };
+            tokio::task::yield_now().await;
+            clock.advance(Duration::from_secs(10));
+            let stats = wait.await.unwrap().unwrap();
+            assert_eq!(stats.pending, 2);
+
+            // The task out of the group is not waited for.
+            let ssn_ptr = storage.get_session_ptr("group-ssn".to_string()).unwrap();
+            for task in &extract {
+                let task_ptr = storage.get_task_ptr(task.gid()).unwrap();
+                controller
+                    .update_task_state(ssn_ptr.clone(), task_ptr, TaskState::Succeed, None)
+                    .await
+                    .unwrap();
+            }
+            let stats = controller
+                .wait_for_group("group-ssn".to_string(), "extract", None)
+                .await
+                .unwrap();
+            assert_eq!(stats.pending, 0);
+            assert_eq!(stats.succeed, 2);
+
+            // The empty group is completed.
+            let stats = controller
+                .wait_for_group("group-ssn".to_string(), "load", None)
+                .await
+                .unwrap();
+            assert_eq!(stats.succeed, 0);
+        }
+
+        #[tokio::test]
+        async fn test_drain_session() {
+            let clock = common::clock::VirtualClock::new_ptr();
+            let storage = create_test_storage_with_clock(clock.clone()).await;
+            let controller = new_ptr(storage.clone());
+
+            storage
+                .create_session(SessionAttributes {
+                    id: "drain-ssn".to_string(),
+                    application: "flmtest".to_string(),
+                    slots: 1,
+                    common_data: None,
+                    min_instances: 0,
+                    max_instances: None,
+                    batch_size: 1,
+                    content_type: None,
+                    client_host: None,
+                    labels: vec![],
+                    environments: HashMap::new(),
+                })
+                .await
+                .unwrap();
+            let task = controller
+                .create_task("drain-ssn".to_string(), TaskAttributes::default())
+                .await
+                .unwrap();
+
+            let drain = {
+                let controller = controller.clone();
+                tokio::spawn(async move {
+                    controller
+                        .drain_session("drain-ssn".to_string(), None, Duration::from_secs(60))
+                        .await
+                })
+            };
+            tokio::task::yield_now().await;
+
+            // The new tasks are rejected while draining.
+            let res = controller
+                .create_task("drain-ssn".to_string(), TaskAttributes::default())
+                .await;
+            assert!(matches!(res, Err(FlameError::InvalidState(_))));
+
+            let ssn_ptr = storage.get_session_ptr("drain-ssn".to_string()).unwrap();
+            let task_ptr = storage.get_task_ptr(task.gid()).unwrap();
+            controller
+                .update_task_state(ssn_ptr, task_ptr, TaskState::Succeed, None)
+                .await
+                .unwrap();
+
+            let ssn = drain.await.unwrap().unwrap();
+            assert_eq!(ssn.status.state, SessionState::Closed);
+            assert!(lock_ptr!(controller.draining).unwrap().is_empty());
+        }
+
+        #[tokio::test]
+        async fn test_boost_session() {
+            let storage = create_test_storage().await;
+            let controller = new_ptr(storage.clone());
+            let id = "boost-ssn".to_string();
+
+            storage
+                .create_session(SessionAttributes {
+                    id: id.clone(),
+                    application: "flmtest".to_string(),
+                    slots: 1,
+                    common_data: None,
+                    min_instances: 0,
+                    max_instances: None,
+                    batch_size: 1,
+                    content_type: None,
+                    client_host: None,
+                    labels: vec![],
+                    environments: HashMap::new(),
+                })
+                .await
+                .unwrap();
+
+            assert_eq!(controller.session_priority(&id).unwrap(), None);
+            assert_eq!(controller.boost_session(&id, 10, "admin").await.unwrap(), 0);
+            assert_eq!(
+                controller.boost_session(&id, 20, "admin").await.unwrap(),
+                10
+            );
+            let ss = storage.snapshot().unwrap();
+            assert_eq!(ss.get_session(&id).unwrap().priority, 20);
+            assert_eq!(controller.session_priority(&id).unwrap(), Some(20));
+
+            // Zero removes the boost.
+            assert_eq!(controller.boost_session(&id, 0, "admin").await.unwrap(), 20);
+            let ss = storage.snapshot().unwrap();
+            assert_eq!(ss.get_session(&id).unwrap().priority, 0);
+            assert_eq!(controller.session_priority(&id).unwrap(), None);
+
+            // The boosts are audited by the events of the session.
+            let ssn = storage.get_session(id.clone()).unwrap();
+            assert_eq!(ssn.events.len(), 3);
+            assert_eq!(
+                ssn.events[0].message,
+                Some("priority is boosted from <0> to <10> by <admin>".to_string())
+            );
+
+            let res = controller
+                .boost_session(&"unknown".to_string(), 10, "admin")
+                .await;
+            assert!(matches!(res, Err(FlameError::NotFound(_))));
+
+            controller.close_session(id.clone(), None).await.unwrap();
+            let res = controller.boost_session(&id, 10, "admin").await;
+            assert!(matches!(res, Err(FlameError::InvalidState(_))));
+        }
+
+        #[tokio::test]
+        async fn test_exclude_crash_looping_application() {
+            let storage = create_test_storage().await;
+            let controller = new_ptr(storage.clone());
+            let id = "crash-ssn".to_string();
+
+            storage
+                .create_session(SessionAttributes {
+                    id: id.clone(),
+                    application: "flmtest".to_string(),
+                    slots: 1,
+                    ..Default::default()
+                })
+                .await
+                .unwrap();
+
+            let exclusion = |crash_looping| Exclusion {
+                application: "flmtest".to_string(),
+                until: chrono::Utc::now() + chrono::Duration::seconds(30),
+                crash_looping,
+                session_id: id.clone(),
+            };
+            let exe_id = "crash-exe".to_string();
+
+            // The backoff of the application is not an event.
+            controller
+                .exclude_applications(&exe_id, vec![exclusion(false)])
+                .await
+                .unwrap();
+            assert!(storage.get_session(id.clone()).unwrap().events.is_empty());
+
+            // It's recorded once when the application starts crash-looping.
+            for _ in 0..2 {
+                controller
+                    .exclude_applications(&exe_id, vec![exclusion(true)])
+                    .await
+                    .unwrap();
+            }
+            let ssn = storage.get_session(id.clone()).unwrap();
+            assert_eq!(ssn.events.len(), 1);
+            assert!(ssn.events[0]
+                .message
+                .as_ref()
+                .unwrap()
+                .starts_with("application <flmtest> is crash-looping on executor <crash-exe>"));
+
+            // The session of the crash may be deleted since then.
+            let mut unknown = exclusion(true);
+            unknown.application = "another".to_string();
+            unknown.session_id = "unknown".to_string();
+            controller
+                .exclude_applications(&exe_id, vec![unknown])
+                .await
+                .unwrap();
+        }
+
+        #[tokio::test]
+        async fn test_session_starvation() {
+            use common::clock::Clock;
+
+            let clock = common::clock::VirtualClock::new_ptr();
+            let storage = create_test_storage_with_clock(clock.clone()).await;
+            let controller = new_ptr(storage.clone());
+            let id = "starving-ssn".to_string();
+
+            storage
+                .create_session(SessionAttributes {
+                    id: id.clone(),
+                    application: "flmtest".to_string(),
+                    slots: 1,
+                    ..Default::default()
+                })
+                .await
+                .unwrap();
+            let starving_since = || {
+                let ss = storage.snapshot().unwrap();
+                ss.get_session(&id).unwrap().starving_since
+            };
+
+            // The session without pending tasks is not starving.
+            clock.advance(Duration::from_secs(600));
+            assert_eq!(starving_since(), None);
+
+            controller
+                .create_task(id.clone(), TaskAttributes::default())
+                .await
+                .unwrap();
+            let since = clock.utc_now();
+            assert_eq!(starving_since(), Some(since));
+            clock.advance(Duration::from_secs(60));
+            assert_eq!(starving_since(), Some(since));
+
+            // The starvation ends once an executor is bound to the session.
+            let exe = controller
+                .create_executor("starving-node".to_string(), id.clone(), None)
+                .await
+                .unwrap();
+            let exe_ptr = storage.get_executor_ptr(exe.id.clone()).unwrap();
+            lock_ptr!(exe_ptr).unwrap().ssn_id = Some(id.clone());
+            assert_eq!(starving_since(), None);
+
+            lock_ptr!(exe_ptr).unwrap().ssn_id = None;
+            assert_eq!(starving_since(), Some(clock.utc_now()));
+        }
+    }
+
+    mod launch_tests {
+        use super::*;
+
+        #[tokio::test]
+        async fn test_launch_sequence() {
+            let controller = new_ptr(create_test_storage().await);
+            let exe_id = "exe-1".to_string();
+
+            let first = controller.start_launch(exe_id.clone()).unwrap();
+            let second = controller.start_launch(exe_id.clone()).unwrap();
+            assert!(second > first);
+
+            // The requests of the earlier launch are rejected.
+            assert!(controller.check_launch(&exe_id, Some(first)).is_err());
+            assert!(controller.check_launch(&exe_id, Some(second)).is_ok());
+            // The sequence is required once the executor has a launch.
+            assert!(controller.check_launch(&exe_id, None).is_err());
+
+            // The stale sequence does not consume the current one.
+            controller.consume_launch(&exe_id, Some(first)).unwrap();
+            assert!(controller.check_launch(&exe_id, Some(second)).is_ok());
+
+            // The completion consumes the sequence, so it's not replayed.
+            controller.consume_launch(&exe_id, Some(second)).unwrap();
+            assert!(controller.check_launch(&exe_id, Some(second)).is_err());
+            assert!(controller.check_launch(&exe_id, None).is_ok());
+        }
+    }
+
+    mod task_lease_tests {
+        use super::*;
+
+        /// A session with a task running on an executor.
+        async fn running_task(controller: &ControllerPtr, storage: &StoragePtr) -> ExecutorID {
+            storage
+                .create_session(SessionAttributes {
+                    id: "lease-ssn".to_string(),
+                    application: "flmtest".to_string(),
+                    slots: 1,
+                    ..Default::default()
+                })
+                .await
+                .unwrap();
+            let task = controller
+                .create_task("lease-ssn".to_string(), TaskAttributes::default())
+                .await
+                .unwrap();
+            controller
+                .update_task_state(
+                    storage.get_session_ptr(task.ssn_id.clone()).unwrap(),
+                    storage.get_task_ptr(task.gid()).unwrap(),
+                    TaskState::Running,
+                    None,
+                )
+                .await
+                .unwrap();
+
+            let exe = controller
+                .create_executor("lease-node".to_string(), "lease-ssn".to_string(), None)
+                .await
+                .unwrap();
+            let exe_ptr = storage.get_executor_ptr(exe.id.clone()).unwrap();
+            {
+                let mut exe = lock_ptr!(exe_ptr).unwrap();
+                exe.ssn_id = Some(task.ssn_id.clone());
+                exe.task_id = Some(task.id);
+            }
+
+            exe.id
+        }
+
+        fn gid(task_id: TaskID) -> TaskGID {
+            TaskGID {
+                ssn_id: "lease-ssn".to_string(),
+                task_id,
+            }
+        }
+
+        #[tokio::test]
+        async fn test_renew_task_lease() {
+            let storage = create_test_storage().await;
+            let controller = new_ptr(storage.clone());
+            let exe_id = running_task(&controller, &storage).await;
+            let lease = Duration::from_secs(10);
+            let sequence = controller.start_launch(exe_id.clone()).unwrap();
+
+            controller
+                .renew_task_lease(exe_id.clone(), gid(1), lease, Some(sequence))
+                .unwrap();
+
+            // The lease of another task, of an earlier launch, or without the
+            // sequence of the launch, is rejected.
+            let res = controller.renew_task_lease(exe_id.clone(), gid(2), lease, Some(sequence));
+            assert!(matches!(res, Err(FlameError::InvalidState(_))));
+            let res = controller.renew_task_lease(exe_id.clone(), gid(1), lease, None);
+            assert!(matches!(res, Err(FlameError::InvalidState(_))));
+            let res =
+                controller.renew_task_lease(exe_id.clone(), gid(1), lease, Some(sequence + 1));
+            assert!(matches!(res, Err(FlameError::InvalidState(_))));
+            let res = controller.renew_task_lease("unknown".to_string(), gid(1), lease, None);
+            assert!(res.is_err());
+        }
+
+        #[tokio::test]
+        async fn test_renew_task_lease_after_restart() {
+            let clock = common::clock::VirtualClock::new_ptr();
+            let storage = create_test_storage_with_clock(clock.clone()).await;
+            let controller = new_ptr(storage.clone());
+            let exe_id = running_task(&controller, &storage).await;
+            let lease = Duration::from_secs(10);
+            let sequence = controller.start_launch(exe_id.clone()).unwrap();
+
+            // The sequence issued before the restart is adopted, as the
+            // executor is still running the task.
+            clock.advance(Duration::from_secs(1));
+            let controller = new_ptr(storage.clone());
+            controller
+                .renew_task_lease(exe_id.clone(), gid(1), lease, Some(sequence))
+                .unwrap();
+            let res = controller.renew_task_lease(exe_id.clone(), gid(1), lease, None);
+            assert!(matches!(res, Err(FlameError::InvalidState(_))));
+
+            // It's rejected once the task is detached from the executor.
+            clock.advance(Duration::from_secs(1));
+            let controller = new_ptr(storage.clone());
+            {
+                let exe_ptr = storage.get_executor_ptr(exe_id.clone()).unwrap();
+                lock_ptr!(exe_ptr).unwrap().task_id = None;
+            }
+            assert!(controller.check_launch(&exe_id, Some(sequence)).is_err());
+        }
+
+        #[tokio::test]
+        async fn test_complete_task_launch() {
+            let storage = create_test_storage().await;
+            let controller = new_ptr(storage.clone());
+            let exe_id = running_task(&controller, &storage).await;
+            let sequence = controller.start_launch(exe_id.clone()).unwrap();
+            let result = TaskResult {
+                state: TaskState::Succeed,
+                ..Default::default()
+            };
+
+            // The completion is not persisted by the void executor, so the
+            // sequence is kept for the retry.
+            let res = controller
+                .complete_task(exe_id.clone(), result.clone(), Some(sequence))
+                .await;
+            assert!(matches!(res, Err(FlameError::InvalidState(_))));
+            assert!(controller.check_launch(&exe_id, Some(sequence)).is_ok());
+
+            // The persisted completion consumes the sequence.
+            {
+                let exe_ptr = storage.get_executor_ptr(exe_id.clone()).unwrap();
+                lock_ptr!(exe_ptr).unwrap().state = ExecutorState::Bound;
+            }
+            controller
+                .complete_task(exe_id.clone(), result.clone(), Some(sequence))
+                .await
+                .unwrap();
+            let task = controller.get_task("lease-ssn".to_string(), 1).unwrap();
+            assert_eq!(task.state, TaskState::Succeed);
+            let res = controller
+                .complete_task(exe_id, result, Some(sequence))
+                .await;
+            assert!(matches!(res, Err(FlameError::InvalidState(_))));
+        }
+
+        #[tokio::test]
+        async fn test_unbind_executor_reason() {
+            let storage = create_test_storage().await;
+            let controller = new_ptr(storage.clone());
+            let exe_id = running_task(&controller, &storage).await;
+            {
+                let exe_ptr = storage.get_executor_ptr(exe_id.clone()).unwrap();
+                lock_ptr!(exe_ptr).unwrap().state = ExecutorState::Bound;
+            }
+
+            controller
+                .unbind_executor(exe_id.clone(), Some("health probe failed".to_string()))
+                .await
+                .unwrap();
+            assert_eq!(
+                controller.get_executor(exe_id.clone()).unwrap().state,
+                ExecutorState::Unbinding
+            );
+
+            // The reason is recorded as an event of the session.
+            let ssn = storage.get_session("lease-ssn".to_string()).unwrap();
+            assert_eq!(ssn.events.len(), 1);
+            assert_eq!(ssn.events[0].code, i32::from(ExecutorState::Unbinding));
+            assert_eq!(
+                ssn.events[0].message,
+                Some(format!(
+                    "executor <{exe_id}> is unbound: health probe failed"
+                ))
+            );
+        }
+
+        #[tokio::test]
+        async fn test_expire_task_leases() {
+            let clock = common::clock::VirtualClock::new_ptr();
+            let storage = create_test_storage_with_clock(clock.clone()).await;
+            let controller = new_ptr(storage.clone());
+            let exe_id = running_task(&controller, &storage).await;
+
+            controller
+                .renew_task_lease(exe_id.clone(), gid(1), Duration::from_secs(10), None)
+                .unwrap();
+
+            // The task keeps running before its lease expires.
+            clock.advance(Duration::from_secs(5));
+            controller.expire_task_leases().await.unwrap();
+            assert_eq!(
+                controller.get_executor(exe_id.clone()).unwrap().task_id,
+                Some(1)
+            );
+            let task = controller.get_task("lease-ssn".to_string(), 1).unwrap();
+            assert_eq!(task.state, TaskState::Running);
+
+            // The expired task is re-queued and detached from the executor.
+            clock.advance(Duration::from_secs(5));
+            controller.expire_task_leases().await.unwrap();
+            assert_eq!(
+                controller.get_executor(exe_id.clone()).unwrap().task_id,
+                None
+            );
+            let task = controller.get_task("lease-ssn".to_string(), 1).unwrap();
+            assert_eq!(task.state, TaskState::Pending);
+
+            // The lease is not renewed after the task was re-queued.
+            let res = controller.renew_task_lease(exe_id, gid(1), Duration::from_secs(10), None);
+            assert!(matches!(res, Err(FlameError::InvalidState(_))));
+        }
+    }
+
+    mod session_outputs_tests {
+        use super::*;
+
+        fn completed_task(id: TaskID, completed_at: i64) -> Task {
+            Task {
+                id,
+                ssn_id: "outputs-ssn".to_string(),
+                output: Some(TaskOutput::from(format!("output-{id}"))),
+                completion_time: chrono::DateTime::from_timestamp_millis(completed_at),
+                state: TaskState::Succeed,
+                ..Default::default()
+            }
+        }
+
+        fn ids(page: &OutputPage) -> Vec<TaskID> {
+            page.tasks.iter().map(|t| t.id).collect()
+        }
+
+        #[test]
+        fn test_collate_outputs_by_order() {
+            let tasks = vec![
+                completed_task(3, 1000),
+                completed_task(1, 3000),
+                Task {
+                    id: 4,
+                    state: TaskState::Running,
+                    ..Default::default()
+                },
+                completed_task(2, 1000),
+            ];
+
+            // The running task is to be completed, so there's a next page.
+            let page = collate_outputs(tasks.clone(), OutputOrder::Submission, None, None).unwrap();
+            assert_eq!(ids(&page), vec![1, 2, 3]);
+            assert_eq!(page.next_page_token.as_deref(), Some("0:3"));
+
+            // The task id breaks the tie of the completion time.
+            let page = collate_outputs(tasks, OutputOrder::Completion, None, None).unwrap();
+            assert_eq!(ids(&page), vec![2, 3, 1]);
+            assert_eq!(page.next_page_token.as_deref(), Some("3000:1"));
+        }
+
+        #[test]
+        fn test_collate_outputs_completed_late() {
+            let mut tasks = vec![
+                Task {
+                    id: 1,
+                    ssn_id: "outputs-ssn".to_string(),
+                    state: TaskState::Running,
+                    ..Default::default()
+                },
+                completed_task(2, 1000),
+            ];
+
+            // The page stops before the slow task instead of passing it.
+            let page = collate_outputs(tasks.clone(), OutputOrder::Submission, None, None).unwrap();
+            assert!(page.tasks.is_empty());
+            let token = page.next_page_token.unwrap();
+
+            tasks[0] = completed_task(1, 2000);
+            let page = collate_outputs(tasks.clone(), OutputOrder::Submission, Some(&token), None)
+                .unwrap();
+            assert_eq!(ids(&page), vec![1, 2]);
+            assert!(page.next_page_token.is_none());
+        }
+
+        #[test]
+        fn test_collate_outputs_by_page() {
+            let tasks: Vec<_> = (1..=5).map(|id| completed_task(id, 6000 - id)).collect();
+
+            let mut token = None;
+            let mut pages = vec![];
+            loop {
+                let page = collate_outputs(
+                    tasks.clone(),
+                    OutputOrder::Completion,
+                    token.as_deref(),
+                    Some(2),
+                )
+                .unwrap();
+                pages.push(ids(&page));
+                token = page.next_page_token;
+                if token.is_none() {
+                    break;
+                }
+            }
+            assert_eq!(pages, vec![vec![5, 4], vec![3, 2], vec![1]]);
+
+            assert!(matches!(
+                collate_outputs(tasks, OutputOrder::Submission, Some("abc"), None),
+                Err(FlameError::InvalidConfig(_))
+            ));
+        }
+    }
+
+    mod task_page_tests {
+        use super::*;
+
+        fn task(id: TaskID, state: TaskState, created: i64) -> Task {
+            Task {
+                id,
+                ssn_id: "page-ssn".to_string(),
+                creation_time: chrono::DateTime::from_timestamp(created, 0).unwrap(),
+                state,
+                ..Default::default()
+            }
+        }
+
+        fn ids(tasks: &[Task]) -> Vec<TaskID> {
+            tasks.iter().map(|t| t.id).collect()
+        }
+
+        #[test]
+        fn test_page_tasks() {
+            let tasks = vec![
+                task(3, TaskState::Running, 300),
+                task(1, TaskState::Succeed, 100),
+                task(5, TaskState::Failed, 500),
+                task(2, TaskState::Pending, 200),
+                task(4, TaskState::Succeed, 400),
+            ];
+
+            let filter = TaskFilter::default();
+            let mut pages = vec![];
+            let mut after = None;
+            loop {
+                let page = page_tasks(tasks.clone(), &filter, after, Some(2));
+                if page.is_empty() {
+                    break;
+                }
+                after = page.last().map(|t| t.id);
+                pages.push(ids(&page));
+            }
+            assert_eq!(pages, vec![vec![1, 2], vec![3, 4], vec![5]]);
+
+            let filter = TaskFilter {
+                states: vec![TaskState::Succeed, TaskState::Failed],
+                ..Default::default()
+            };
+            assert_eq!(
+                ids(&page_tasks(tasks.clone(), &filter, None, None)),
+                vec![1, 4, 5]
+            );
+
+            let mut labeled = tasks.clone();
+            labeled[0].labels = vec!["shard=1".to_string(), "trace-id=abc".to_string()];
+            labeled[1].labels = vec!["shard=2".to_string()];
+            let filter = TaskFilter {
+                labels: vec!["shard=1".to_string()],
+                ..Default::default()
+            };
+            assert_eq!(ids(&page_tasks(labeled, &filter, None, None)), vec![3]);
+
+            let filter = TaskFilter {
+                created_after: chrono::DateTime::from_timestamp(200, 0),
+                created_before: chrono::DateTime::from_timestamp(400, 0),
+                ..Default::default()
+            };
+            assert_eq!(ids(&page_tasks(tasks, &filter, None, None)), vec![2, 3]);
+        }
+    }
+
+    mod page_sessions_tests {
+        use super::*;
+
+        fn session(id: &str, app: &str, state: SessionState, created: i64) -> Session {
+            Session {
+                id: id.to_string(),
+                application: app.to_string(),
+                creation_time: chrono::DateTime::from_timestamp(created, 0).unwrap(),
+                status: common::apis::SessionStatus { state },
+                labels: vec![format!("app={app}")],
+                ..Default::default()
+            }
+        }
+
+        fn ids(sessions: &[Session]) -> Vec<&str> {
+            sessions.iter().map(|s| s.id.as_str()).collect()
+        }
+
+        #[test]
+        fn test_page_sessions() {
+            let sessions = vec![
+                session("ssn-c", "pi", SessionState::Open, 300),
+                session("ssn-a", "pi", SessionState::Closed, 100),
+                session("ssn-e", "flmexec", SessionState::Open, 500),
+                session("ssn-b", "flmexec", SessionState::Open, 200),
+                session("ssn-d", "pi", SessionState::Open, 400),
+            ];
+
+            let filter = SessionFilter::default();
+            let mut pages = vec![];
+            let mut after = None;
+            loop {
+                let page = page_sessions(sessions.clone(), &filter, after.as_deref(), Some(2));
+                if page.is_empty() {
+                    break;
+                }
+                after = page.last().map(|s| s.id.clone());
+                pages.push(ids(&page).join(","));
+            }
+            assert_eq!(pages, vec!["ssn-a,ssn-b", "ssn-c,ssn-d", "ssn-e"]);
+
+            let filter = SessionFilter {
+                states: vec![SessionState::Open],
+                application: Some("pi".to_string()),
+                ..Default::default()
+            };
+            let page = page_sessions(sessions.clone(), &filter, None, None);
+            assert_eq!(ids(&page), vec!["ssn-c", "ssn-d"]);
+
+            let filter = SessionFilter {
+                created_after: chrono::DateTime::from_timestamp(200, 0),
+                created_before: chrono::DateTime::from_timestamp(500, 0),
+                labels: vec!["app=flmexec".to_string()],
+                ..Default::default()
+            };
+            let page = page_sessions(sessions, &filter, None, None);
+            assert_eq!(ids(&page), vec!["ssn-b"]);
+        }
+    }
+
+    mod submission_check_tests {
+        use super::*;
+
+        fn node(name: &str, cpu: u64, state: NodeState) -> Node {
+            Node {
+                name: name.to_string(),
+                allocatable: ResourceRequirement {
+                    cpu,
+                    memory: cpu * 1024,
+                },
+                state,
+                ..Default::default()
+            }
+        }
+
+        fn executor(node: &str, slots: u32) -> Executor {
+            Executor {
+                node: node.to_string(),
+                slots,
+                ..Default::default()
+            }
+        }
+
+        #[test]
+        fn test_check_feasibility() {
+            let unit = ResourceRequirement {
+                cpu: 1,
+                memory: 1024,
+            };
+            let nodes = vec![
+                node("node-1", 4, NodeState::Ready),
+                node("node-2", 2, NodeState::Ready),
+                node("node-3", 8, NodeState::NotReady),
+            ];
+            let executors = vec![executor("node-1", 3), executor("node-3", 8)];
+
+            let ssn = Session {
+                slots: 4,
+                batch_size: 1,
+                ..Default::default()
+            };
+            let check = check_feasibility(&ssn, &nodes, &executors, &unit);
+            assert!(check.accepted && check.feasible);
+            assert_eq!((check.idle_slots, check.total_slots), (3, 6));
+
+            // The executor is larger than any ready node.
+            let ssn = Session {
+                slots: 5,
+                batch_size: 1,
+                ..Default::default()
+            };
+            let check = check_feasibility(&ssn, &nodes, &executors, &unit);
+            assert!(!check.feasible);
+            assert_eq!(check.reasons.len(), 1);
+
+            // The batch is larger than all the ready nodes.
+            let ssn = Session {
+                slots: 2,
+                batch_size: 4,
+                ..Default::default()
+            };
+            assert!(!check_feasibility(&ssn, &nodes, &executors, &unit).feasible);
+        }
+
+        #[tokio::test]
+        async fn test_check_submission() {
+            let storage = create_test_storage().await;
+            let controller = new_ptr(storage.clone());
+
+            storage
+                .create_session(SessionAttributes {
+                    id: "check-ssn".to_string(),
+                    application: "flmtest".to_string(),
+                    ..Default::default()
+                })
+                .await
+                .unwrap();
+
+            let unit = ResourceRequirement::default();
+            let check = controller
+                .check_submission("check-ssn".to_string(), &unit)
+                .unwrap();
+            assert!(check.accepted);
+            // No node is ready.
+            assert!(!check.feasible);
+
+            controller
+                .close_session("check-ssn".to_string(), None)
+                .await
+                .unwrap();
+            let check = controller
+                .check_submission("check-ssn".to_string(), &unit)
+                .unwrap();
+            assert!(!check.accepted);
+            assert!(check.reasons.iter().any(|r| r.contains("not open")));
+        }
+    }
+
+    mod bind_sessions_tests {
+        use super::*;
+
+        #[tokio::test]
+        async fn test_bind_sessions() {
+            let storage = create_test_storage().await;
+            let controller = new_ptr(storage.clone());
+
+            storage
+                .create_session(SessionAttributes {
+                    id: "bind-ssn".to_string(),
+                    application: "flmtest".to_string(),
+                    slots: 1,
+                    ..Default::default()
+                })
+                .await
+                .unwrap();
+
+            let mut binds = vec![];
+            for _ in 0..2 {
+                let exe = controller
+                    .create_executor("bind-node".to_string(), "bind-ssn".to_string(), None)
+                    .await
+                    .unwrap();
+                controller.register_executor(&exe).await.unwrap();
+                binds.push(SessionBind {
+                    exec_id: exe.id,
+                    ssn_id: "bind-ssn".to_string(),
+                    batch_index: None,
+                    rationale: Some("the test".to_string()),
+                });
+            }
+            binds.insert(
+                1,
+                SessionBind {
+                    exec_id: "unknown".to_string(),
+                    ssn_id: "bind-ssn".to_string(),
+                    batch_index: None,
+                    rationale: None,
+                },
+            );
+
+            // The other binds are applied if one of them fails.
+            let res = controller.bind_sessions(binds.clone()).await;
+            assert!(matches!(res, Err(FlameError::NotFound(_))));
+            assert_eq!(controller.bind_count(), 2);
+
+            for bind in [&binds[0], &binds[2]] {
+                let exe = controller.get_executor(bind.exec_id.clone()).unwrap();
+                assert_eq!(exe.state, ExecutorState::Binding);
+                assert_eq!(exe.ssn_id.as_deref(), Some("bind-ssn"));
+            }
+
+            // The rationale of each bind is recorded in the session.
+            let ssn = storage.get_session("bind-ssn".to_string()).unwrap();
+            let bound = ssn
+                .events
+                .iter()
+                .filter_map(|e| e.message.as_deref())
+                .filter(|m| m.ends_with("is bound: the test"))
+                .count();
+            assert_eq!(bound, 2);
+        }
+    }
+
+    mod expand_output_tests {
+        use super::*;
+
+        #[tokio::test]
+        async fn test_expand_output() {
+            let storage = create_test_storage().await;
+            let controller = new_ptr(storage.clone());
+
+            storage
+                .create_session(SessionAttributes {
+                    id: "dedup-ssn".to_string(),
+                    application: "flmtest".to_string(),
+                    slots: 1,
+                    ..Default::default()
+                })
+                .await
+                .unwrap();
+            for _ in 0..2 {
+                controller
+                    .create_task("dedup-ssn".to_string(), TaskAttributes::default())
+                    .await
+                    .unwrap();
+            }
+            let gid = TaskGID {
+                ssn_id: "dedup-ssn".to_string(),
+                task_id: 1,
+            };
+            storage
+                .update_task_result(
+                    storage.get_session_ptr("dedup-ssn".to_string()).unwrap(),
+                    storage.get_task_ptr(gid).unwrap(),
+                    TaskResult {
+                        state: TaskState::Succeed,
+                        output: Some(TaskOutput::from("result".to_string())),
+                        ..Default::default()
+                    },
+                )
+                .await
+                .unwrap();
+
+            let reference = |r: &str| TaskResult {
+                state: TaskState::Succeed,
+                output_ref: Some(r.to_string()),
+                ..Default::default()
+            };
+            let ssn_id = "dedup-ssn".to_string();
+
+            let result = controller
+                .expand_output(&ssn_id, reference("task://1"))
+                .unwrap();
+            assert_eq!(result.output, Some(TaskOutput::from("result".to_string())));
+            assert_eq!(result.output_ref, None);
+
+            // The reference to a task without output is rejected.
+            let res = controller.expand_output(&ssn_id, reference("task://2"));
+            assert!(matches!(res, Err(FlameError::InvalidState(_))));
+            let res = controller.expand_output(&ssn_id, reference("task://abc"));
+            assert!(matches!(res, Err(FlameError::InvalidState(_))));
+
+            // The other references are kept as is.
+            let result = controller
+                .expand_output(&ssn_id, reference("file://host/tmp/1"))
+                .unwrap();
+            assert_eq!(result.output_ref.as_deref(), Some("file://host/tmp/1"));
+        }
+    }
+
+    mod session_stats_tests {
+        use super::*;
+
+        fn task(id: TaskID, state: TaskState, created: i64, completed: Option<i64>) -> Task {
+            let now = chrono::DateTime::from_timestamp(3600, 0).unwrap();
+            Task {
+                id,
+                ssn_id: "stats-ssn".to_string(),
+                creation_time: now - chrono::Duration::seconds(created),
+                completion_time: completed.map(|c| now - chrono::Duration::seconds(c)),
+                state,
+                ..Default::default()
+            }
+        }
+
+        #[test]
+        fn test_summarize_tasks() {
+            let now = chrono::DateTime::from_timestamp(3600, 0).unwrap();
+            let tasks = vec![
+                task(1, TaskState::Succeed, 600, Some(500)),
+                task(2, TaskState::Succeed, 600, Some(100)),
+                task(3, TaskState::Failed, 600, Some(40)),
+                task(4, TaskState::Running, 600, None),
+                task(5, TaskState::Pending, 600, None),
+                task(6, TaskState::Pending, 600, None),
+            ];
+
+            let stats = summarize_tasks(&tasks, now, Duration::from_secs(120));
+            assert_eq!(
+                (stats.pending, stats.running, stats.succeed, stats.failed),
+                (2, 1, 2, 1)
+            );
+            // 2 tasks completed in the last 2 minutes.
+            assert_eq!(stats.throughput, 1.0);
+            assert_eq!(stats.eta, Some(Duration::from_secs(180)));
+
+            // No ETA without completed tasks in the window.
+            let stats = summarize_tasks(&tasks, now, Duration::from_secs(30));
+            assert_eq!(stats.throughput, 0.0);
+            assert_eq!(stats.eta, None);
+        }
+
+        #[test]
+        fn test_summarize_young_session() {
+            let now = chrono::DateTime::from_timestamp(3600, 0).unwrap();
+            let tasks = vec![
+                task(1, TaskState::Succeed, 30, Some(10)),
+                task(2, TaskState::Pending, 30, None),
+            ];
+
+            // The window is shortened to the 30s age of the session.
+            let stats = summarize_tasks(&tasks, now, Duration::from_secs(300));
+            assert_eq!(stats.throughput, 2.0);
+            assert_eq!(stats.eta, Some(Duration::from_secs(30)));
+
+            assert_eq!(
+                summarize_tasks(&[], now, Duration::from_secs(300)),
+                SessionStats::default()
+            );
+        }
+    }
 }
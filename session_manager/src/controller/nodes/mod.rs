@@ -127,12 +127,14 @@ mod tests {
                 schedule_interval: 1000,
                 executors: FlameExecutors {
                     shim: Shim::default(),
+                    ..Default::default()
                 },
                 tls: None,
                 limits: FlameLimits {
                     max_sessions: None,
                     max_executors: 10,
                 },
+                ..Default::default()
             },
             cache: None,
         };
@@ -319,6 +321,7 @@ mod tests {
                 info: NodeInfo {
                     arch: "x86_64".to_string(),
                     os: "linux".to_string(),
+                    ..Default::default()
                 },
             };
 
@@ -0,0 +1,468 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//! The replication of the state to the warm standby: the leader tracks the
+//! versions of the applications, sessions and tasks, and streams the changed
+//! ones as deltas as soon as they're changed in its storage; the standby
+//! applies the deltas to its own storage, so it takes over with all the tasks
+//! when it's promoted.
+//!
+//! The nodes and executors are not replicated; the executor managers register
+//! them again to the promoted standby.
+
+use std::collections::{HashMap, HashSet};
+
+use chrono::{DateTime, Utc};
+use stdng::trace_fn;
+use tokio::sync::broadcast;
+
+use common::apis::{
+    Application, ApplicationAttributes, Session, SessionAttributes, SessionID, SessionState,
+    SessionStatus, Task, TaskAttributes, TaskGID, TaskID, TaskResult, TaskState,
+};
+use common::FlameError;
+
+use crate::controller::Controller;
+use crate::storage::StateChange;
+
+/// A change of the state of the leader.
+#[derive(Clone, Debug)]
+pub enum StateDelta {
+    Application(Application),
+    RemovedApplication(String),
+    /// The session without its tasks, which are streamed as `Task`.
+    Session(Session),
+    RemovedSession(SessionID),
+    Task(Task),
+    /// All the state was streamed; the later deltas are the changes.
+    Synced,
+}
+
+/// The versions of the state streamed to a standby, so only the changes are
+/// streamed by the next call.
+#[derive(Default)]
+pub struct StateTracker {
+    applications: HashMap<String, (u32, DateTime<Utc>)>,
+    sessions: HashMap<SessionID, (u32, DateTime<Utc>)>,
+    tasks: HashMap<(SessionID, TaskID), u32>,
+    synced: bool,
+}
+
+impl StateTracker {
+    /// The deltas of the state since the last call: the applications, the
+    /// sessions, the tasks in the order of their id, the closed sessions, then
+    /// the removed sessions and applications; all the state and `Synced` on
+    /// the first call.
+    pub fn deltas(
+        &mut self,
+        applications: Vec<Application>,
+        sessions: Vec<Session>,
+        mut tasks: Vec<Task>,
+    ) -> Vec<StateDelta> {
+        let mut deltas = vec![];
+
+        let app_names = applications
+            .iter()
+            .map(|app| app.name.clone())
+            .collect::<HashSet<_>>();
+        for app in applications {
+            let version = (app.version, app.creation_time);
+            if self.applications.get(&app.name) != Some(&version) {
+                self.applications.insert(app.name.clone(), version);
+                deltas.push(StateDelta::Application(app));
+            }
+        }
+
+        let ssn_ids = sessions
+            .iter()
+            .map(|ssn| ssn.id.clone())
+            .collect::<HashSet<_>>();
+        // The closed sessions are streamed after their tasks, as no task is
+        // created in a closed session.
+        let mut closed_sessions = vec![];
+        for ssn in sessions {
+            let version = (ssn.version, ssn.creation_time);
+            match self.sessions.get(&ssn.id) {
+                Some(v) if *v == version => continue,
+                // The session was deleted and created again with the same id.
+                Some((_, creation_time)) if *creation_time != ssn.creation_time => {
+                    self.sessions.remove(&ssn.id);
+                    self.tasks.retain(|(ssn_id, _), _| *ssn_id != ssn.id);
+                    deltas.push(StateDelta::RemovedSession(ssn.id.clone()));
+                }
+                _ => {}
+            }
+
+            let is_new = self.sessions.insert(ssn.id.clone(), version).is_none();
+            if ssn.status.state != SessionState::Closed {
+                deltas.push(StateDelta::Session(ssn));
+                continue;
+            }
+            if is_new {
+                deltas.push(StateDelta::Session(Session {
+                    status: SessionStatus {
+                        state: SessionState::Open,
+                    },
+                    completion_time: None,
+                    ..ssn.clone()
+                }));
+            }
+            closed_sessions.push(StateDelta::Session(ssn));
+        }
+
+        tasks.sort_by(|a, b| a.ssn_id.cmp(&b.ssn_id).then(a.id.cmp(&b.id)));
+        for task in tasks {
+            let key = (task.ssn_id.clone(), task.id);
+            if self.tasks.get(&key) != Some(&task.version) {
+                self.tasks.insert(key, task.version);
+                deltas.push(StateDelta::Task(task));
+            }
+        }
+        deltas.extend(closed_sessions);
+
+        let removed_sessions = self
+            .sessions
+            .keys()
+            .filter(|id| !ssn_ids.contains(*id))
+            .cloned()
+            .collect::<Vec<_>>();
+        for id in removed_sessions {
+            self.sessions.remove(&id);
+            self.tasks.retain(|(ssn_id, _), _| *ssn_id != id);
+            deltas.push(StateDelta::RemovedSession(id));
+        }
+
+        let removed_applications = self
+            .applications
+            .keys()
+            .filter(|name| !app_names.contains(*name))
+            .cloned()
+            .collect::<Vec<_>>();
+        for name in removed_applications {
+            self.applications.remove(&name);
+            deltas.push(StateDelta::RemovedApplication(name));
+        }
+
+        if !self.synced {
+            self.synced = true;
+            deltas.push(StateDelta::Synced);
+        }
+
+        deltas
+    }
+}
+
+impl Controller {
+    /// The changes of the state after the call, so the deltas are streamed
+    /// when the state is changed instead of being polled.
+    pub fn subscribe_changes(&self) -> broadcast::Receiver<StateChange> {
+        self.storage.subscribe_changes()
+    }
+
+    /// The deltas of the state since the last call of the tracker; only the
+    /// tasks of the changed sessions are compared, or the tasks of all the
+    /// sessions if None, e.g. for the snapshot.
+    pub async fn state_deltas(
+        &self,
+        tracker: &mut StateTracker,
+        changed: Option<&HashSet<SessionID>>,
+    ) -> Result<Vec<StateDelta>, FlameError> {
+        trace_fn!("Controller::state_deltas");
+        let applications = self.list_application().await?;
+        let sessions = self.list_session()?;
+        let mut tasks = vec![];
+        for ssn in &sessions {
+            if changed.is_some_and(|changed| !changed.contains(&ssn.id)) {
+                continue;
+            }
+            tasks.extend(self.list_task(ssn.id.clone())?);
+        }
+
+        Ok(tracker.deltas(applications, sessions, tasks))
+    }
+
+    /// Apply a delta of the leader to the state of the standby.
+    pub async fn apply_state_delta(&self, delta: StateDelta) -> Result<(), FlameError> {
+        trace_fn!("Controller::apply_state_delta");
+        match delta {
+            StateDelta::Application(app) => {
+                let attr = ApplicationAttributes::from(&app);
+                match self.get_application(app.name.clone()).await {
+                    Ok(_) => self.update_application(app.name, attr, None).await,
+                    Err(FlameError::NotFound(_)) => self.register_application(app.name, attr).await,
+                    Err(e) => Err(e),
+                }
+            }
+            StateDelta::RemovedApplication(name) => match self.unregister_application(name).await {
+                Err(FlameError::NotFound(_)) => Ok(()),
+                res => res,
+            },
+            StateDelta::Session(ssn) => self.apply_session(ssn).await,
+            StateDelta::RemovedSession(id) => match self.delete_session(id).await {
+                Err(FlameError::NotFound(_)) => Ok(()),
+                res => res.map(|_| ()),
+            },
+            StateDelta::Task(task) => self.apply_task(task).await,
+            StateDelta::Synced => Ok(()),
+        }
+    }
+
+    /// Remove the applications and sessions of the standby which are not in
+    /// the state of the leader, e.g. removed while the standby was offline.
+    pub async fn prune_state(
+        &self,
+        applications: &HashSet<String>,
+        sessions: &HashSet<SessionID>,
+    ) -> Result<(), FlameError> {
+        trace_fn!("Controller::prune_state");
+        for ssn in self.list_session()? {
+            if !sessions.contains(&ssn.id) {
+                self.apply_state_delta(StateDelta::RemovedSession(ssn.id))
+                    .await?;
+            }
+        }
+        for app in self.list_application().await? {
+            if !applications.contains(&app.name) {
+                self.apply_state_delta(StateDelta::RemovedApplication(app.name))
+                    .await?;
+            }
+        }
+
+        Ok(())
+    }
+
+    /// Take over the tasks when the standby is promoted: the running tasks
+    /// are re-queued, as their executors are connected to the old leader.
+    pub async fn take_over(&self) -> Result<(), FlameError> {
+        trace_fn!("Controller::take_over");
+        for ssn in self.list_session()? {
+            for task in self.list_task(ssn.id.clone())? {
+                if task.state == TaskState::Running {
+                    self.storage
+                        .requeue_task(
+                            task.gid(),
+                            "Task was re-queued as the standby was promoted.".to_string(),
+                        )
+                        .await?;
+                }
+            }
+        }
+
+        Ok(())
+    }
+
+    async fn apply_session(&self, ssn: Session) -> Result<(), FlameError> {
+        let local = match self.get_session(ssn.id.clone()) {
+            Ok(local) => local,
+            Err(FlameError::NotFound(_)) => {
+                self.create_session(SessionAttributes::from(&ssn)).await?
+            }
+            Err(e) => return Err(e),
+        };
+
+        if ssn.status.state == SessionState::Closed && local.status.state == SessionState::Open {
+            self.close_session(ssn.id, None).await?;
+        }
+
+        Ok(())
+    }
+
+    async fn apply_task(&self, task: Task) -> Result<(), FlameError> {
+        let gid = task.gid();
+        let local = match self.get_task(gid.ssn_id.clone(), gid.task_id) {
+            Ok(local) => local,
+            Err(FlameError::NotFound(_)) => {
+                let local = self
+                    .create_task(task.ssn_id.clone(), TaskAttributes::from(&task))
+                    .await?;
+                if local.id != task.id {
+                    // The session diverged from the leader, e.g. a task was
+                    // missed; it's removed, so it's replicated again by the
+                    // snapshot of the next stream.
+                    self.delete_session(gid.ssn_id.clone()).await?;
+                    return Err(FlameError::Integrity(format!(
+                        "task <{gid}> of the leader was replicated as task <{}>",
+                        local.id
+                    )));
+                }
+                local
+            }
+            Err(e) => return Err(e),
+        };
+
+        if local.state == task.state {
+            return Ok(());
+        }
+
+        let gid = TaskGID {
+            ssn_id: local.ssn_id.clone(),
+            task_id: local.id,
+        };
+        let ssn_ptr = self.storage.get_session_ptr(gid.ssn_id.clone())?;
+        let task_ptr = self.storage.get_task_ptr(gid.clone())?;
+        match task.state {
+            state if state.is_terminal() => {
+                let result = TaskResult {
+                    state,
+                    output: task.output,
+                    message: None,
+                    output_ref: task.output_ref,
+                };
+                self.update_task_result(ssn_ptr, task_ptr, result).await
+            }
+            TaskState::Running => {
+                self.update_task_state(ssn_ptr, task_ptr, TaskState::Running, None)
+                    .await
+            }
+            _ => {
+                self.storage
+                    .requeue_task(gid, "Task was re-queued by the leader.".to_string())
+                    .await
+            }
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn task(ssn_id: &str, id: TaskID, version: u32) -> Task {
+        Task {
+            id,
+            ssn_id: ssn_id.to_string(),
+            version,
+            ..Default::default()
+        }
+    }
+
+    fn session(id: &str, version: u32, creation_time: i64) -> Session {
+        Session {
+            id: id.to_string(),
+            version,
+            creation_time: DateTime::from_timestamp(creation_time, 0).unwrap(),
+            ..Default::default()
+        }
+    }
+
+    fn names(deltas: &[StateDelta]) -> Vec<String> {
+        deltas
+            .iter()
+            .map(|delta| match delta {
+                StateDelta::Application(app) => format!("app/{}", app.name),
+                StateDelta::RemovedApplication(name) => format!("-app/{name}"),
+                StateDelta::Session(ssn) => format!("ssn/{}", ssn.id),
+                StateDelta::RemovedSession(id) => format!("-ssn/{id}"),
+                StateDelta::Task(task) => format!("task/{}/{}", task.ssn_id, task.id),
+                StateDelta::Synced => "synced".to_string(),
+            })
+            .collect()
+    }
+
+    #[test]
+    fn test_state_tracker_deltas() {
+        let mut tracker = StateTracker::default();
+        let app = Application {
+            name: "pi".to_string(),
+            ..Default::default()
+        };
+
+        // All the state is streamed first, the tasks in the order of id.
+        let deltas = tracker.deltas(
+            vec![app.clone()],
+            vec![session("ssn-1", 0, 1)],
+            vec![task("ssn-1", 2, 1), task("ssn-1", 1, 1)],
+        );
+        assert_eq!(
+            names(&deltas),
+            vec![
+                "app/pi",
+                "ssn/ssn-1",
+                "task/ssn-1/1",
+                "task/ssn-1/2",
+                "synced"
+            ]
+        );
+
+        // Only the changes are streamed later.
+        let deltas = tracker.deltas(
+            vec![app.clone()],
+            vec![session("ssn-1", 0, 1)],
+            vec![task("ssn-1", 1, 2), task("ssn-1", 2, 1)],
+        );
+        assert_eq!(names(&deltas), vec!["task/ssn-1/1"]);
+
+        let deltas = tracker.deltas(vec![app.clone()], vec![session("ssn-1", 0, 1)], vec![]);
+        assert!(deltas.is_empty());
+    }
+
+    #[test]
+    fn test_state_tracker_closed_session() {
+        let mut tracker = StateTracker::default();
+        let mut ssn = session("ssn-1", 1, 1);
+        ssn.status.state = SessionState::Closed;
+
+        // The new closed session is created as open, and closed after its tasks.
+        let deltas = tracker.deltas(vec![], vec![ssn.clone()], vec![task("ssn-1", 1, 1)]);
+        assert_eq!(
+            names(&deltas),
+            vec!["ssn/ssn-1", "task/ssn-1/1", "ssn/ssn-1", "synced"]
+        );
+        assert!(matches!(
+            &deltas[0],
+            StateDelta::Session(ssn) if ssn.status.state == SessionState::Open
+        ));
+        assert!(matches!(
+            &deltas[2],
+            StateDelta::Session(ssn) if ssn.status.state == SessionState::Closed
+        ));
+
+        // The known session is streamed once when it's closed.
+        let mut tracker = StateTracker::default();
+        tracker.deltas(vec![], vec![session("ssn-1", 0, 1)], vec![]);
+        let deltas = tracker.deltas(vec![], vec![ssn], vec![task("ssn-1", 1, 1)]);
+        assert_eq!(names(&deltas), vec!["task/ssn-1/1", "ssn/ssn-1"]);
+    }
+
+    #[test]
+    fn test_state_tracker_removed() {
+        let mut tracker = StateTracker::default();
+        let app = Application {
+            name: "pi".to_string(),
+            ..Default::default()
+        };
+        tracker.deltas(
+            vec![app],
+            vec![session("ssn-1", 0, 1), session("ssn-2", 0, 1)],
+            vec![task("ssn-1", 1, 1)],
+        );
+
+        // The session created again is removed first, and all its tasks are
+        // streamed again.
+        let deltas = tracker.deltas(
+            vec![],
+            vec![session("ssn-1", 0, 2)],
+            vec![task("ssn-1", 1, 1)],
+        );
+        assert_eq!(
+            names(&deltas),
+            vec![
+                "-ssn/ssn-1",
+                "ssn/ssn-1",
+                "task/ssn-1/1",
+                "-ssn/ssn-2",
+                "-app/pi"
+            ]
+        );
+    }
+}
@@ -0,0 +1,59 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+use common::apis::{Event, EventOwner, SessionID};
+use common::ctx::FlameChaos;
+use common::FlameError;
+
+use super::{EventManager, EventManagerPtr};
+
+/// The event manager dropping the events by the chaos of the cluster, e.g. to
+/// verify the clients do not depend on every event of the tasks.
+pub struct ChaosEventManager {
+    inner: EventManagerPtr,
+    chaos: FlameChaos,
+}
+
+impl ChaosEventManager {
+    pub fn new(inner: EventManagerPtr, chaos: FlameChaos) -> Self {
+        Self { inner, chaos }
+    }
+}
+
+impl EventManager for ChaosEventManager {
+    fn record_event(&self, owner: EventOwner, event: Event) -> Result<(), FlameError> {
+        if self.chaos.drop_event() {
+            tracing::warn!(
+                "Chaos: drop the event <{}> of task <{}/{}>",
+                event.code,
+                owner.session_id,
+                owner.task_id
+            );
+            return Ok(());
+        }
+
+        self.inner.record_event(owner, event)
+    }
+
+    fn find_events(&self, owner: EventOwner) -> Result<Vec<Event>, FlameError> {
+        self.inner.find_events(owner)
+    }
+
+    fn remove_events(&self, session_id: SessionID) -> Result<(), FlameError> {
+        self.inner.remove_events(session_id)
+    }
+
+    fn clear(&self) -> Result<(), FlameError> {
+        self.inner.clear()
+    }
+}
@@ -16,9 +16,11 @@ use std::sync::Arc;
 use common::apis::{Event, EventOwner, SessionID};
 use common::FlameError;
 
+mod chaos;
 mod fs;
 mod memory;
 
+pub use chaos::ChaosEventManager;
 pub use fs::FsEventManager;
 pub use memory::MemoryEventManager;
 
@@ -26,6 +26,7 @@ mod model;
 mod provider;
 pub mod scheduler;
 mod storage;
+mod timeline;
 
 #[derive(Parser)]
 #[command(name = "flame-session-manager")]
@@ -35,14 +36,20 @@ mod storage;
 struct Cli {
     #[arg(long)]
     config: Option<String>,
+    /// Inject the faults of the chaos configuration, only for the soak tests.
+    #[arg(long)]
+    unsafe_chaos: bool,
 }
 
 #[tokio::main]
 async fn main() -> Result<(), FlameError> {
     let _log_guard = common::init_logger(Some("fsm"))?;
+    common::crypto::init()?;
 
     let cli = Cli::parse();
     let ctx = FlameClusterContext::from_file(cli.config)?;
+    ctx.check_chaos(cli.unsafe_chaos)?;
+    scheduler::check_plugins(&ctx.cluster)?;
 
     tracing::info!("flame-session-manager is starting ...");
 
@@ -54,6 +61,9 @@ async fn main() -> Result<(), FlameError> {
     storage.load_data().await?;
 
     let controller = controller::new_ptr(storage.clone());
+    let journal = apiserver::journal::new_ptr(ctx.cluster.journal.clone());
+    let replica = apiserver::replication::new_replica(&ctx.cluster);
+    let timeline = timeline::new_ptr(ctx.cluster.timeline.clone())?;
     let build_runtime = |name: &str, threads: usize| -> Result<Runtime, FlameError> {
         Builder::new_multi_thread()
             .worker_threads(threads)
@@ -90,35 +100,52 @@ async fn main() -> Result<(), FlameError> {
     let scheduler_rt = build_runtime("scheduler", scheduler_threads)?;
     let provider_rt = build_runtime("provider", provider_threads)?;
 
-    // Start provider thread.
-    #[allow(clippy::let_underscore_future)]
+    // Start apiserver frontend thread.
     {
         let controller = controller.clone();
+        let journal = journal.clone();
+        let replica = replica.clone();
+        let timeline = timeline.clone();
         let ctx = ctx.clone();
-        let _ = provider_rt.spawn(async move {
-            let provider = provider::new("none", controller)?;
-            provider.run(ctx).await
+        let handler = frontend_rt.spawn(async move {
+            let apiserver = apiserver::new_frontend(controller, journal, replica, timeline);
+            apiserver.run(ctx).await
         });
-        // handlers.push(handler);
+        handlers.push(handler);
     }
 
-    // Start apiserver frontend thread.
+    // The standby only serves the replication until it's promoted.
+    if replica.is_standby() {
+        tracing::info!("flame-session-manager is a standby, waiting for the promotion ...");
+        tokio::select! {
+            _ = replica.wait_promoted() => {}
+            res = &mut handlers[0] => {
+                tracing::info!("Thread <0> exited with result: {res:?}");
+                return Ok(());
+            }
+        }
+    }
+
+    // Start provider thread.
+    #[allow(clippy::let_underscore_future)]
     {
         let controller = controller.clone();
         let ctx = ctx.clone();
-        let handler = frontend_rt.spawn(async move {
-            let apiserver = apiserver::new_frontend(controller);
-            apiserver.run(ctx).await
+        let _ = provider_rt.spawn(async move {
+            let provider = provider::new("none", controller)?;
+            provider.run(ctx).await
         });
-        handlers.push(handler);
+        // handlers.push(handler);
     }
 
     // Start apiserver backend thread.
     {
         let controller = controller.clone();
+        let journal = journal.clone();
+        let replica = replica.clone();
         let ctx = ctx.clone();
         let handler = backend_rt.spawn(async move {
-            let apiserver = apiserver::new_backend(controller);
+            let apiserver = apiserver::new_backend(controller, journal, replica);
             apiserver.run(ctx).await
         });
         handlers.push(handler);
@@ -135,17 +162,27 @@ async fn main() -> Result<(), FlameError> {
         handlers.push(handler);
     }
 
+    // Record the timeline of the scheduler metrics if enabled.
+    if timeline.is_enabled() {
+        let controller = controller.clone();
+        #[allow(clippy::let_underscore_future)]
+        let _ = scheduler_rt.spawn(async move { timeline.record(controller).await });
+    }
+
     tracing::info!("flame-session-manager started.");
 
-    // Register default applications.
-    #[allow(clippy::let_underscore_future)]
-    let _: JoinHandle<Result<(), FlameError>> = tokio::spawn(async move {
-        for (name, attr) in common::default_applications() {
-            controller.register_application(name, attr).await?;
-        }
+    // Register default applications; the promoted standby has the
+    // applications of the leader.
+    if ctx.cluster.standby.is_none() {
+        #[allow(clippy::let_underscore_future)]
+        let _: JoinHandle<Result<(), FlameError>> = tokio::spawn(async move {
+            for (name, attr) in common::default_applications() {
+                controller.register_application(name, attr).await?;
+            }
 
-        Ok(())
-    });
+            Ok(())
+        });
+    }
 
     let (res, idx, _) = select_all(handlers).await;
     tracing::info!("Thread <{idx}> exited with result: {res:?}");
@@ -67,10 +67,11 @@ limitations under the License.
 //!
 //! // Send executor updates (can be called from multiple tasks)
 //! sender.send(executor).await?;
+//! sender.send_all(executors).await?;
 //!
-//! // Receive executor updates (typically in a dedicated task)
-//! while let Some(executor) = receiver.recv().await {
-//!     // process executor
+//! // Receive executor updates in batches (typically in a dedicated task)
+//! while let Some(executors) = receiver.recv().await {
+//!     // process executors
 //! }
 //! ```
 
@@ -110,8 +111,8 @@ pub enum ConnectionState {
 pub struct NodeConnection {
     /// The node name this connection belongs to
     pub node_name: String,
-    /// Internal queue of executor updates (cloneable for async operations)
-    queue: AsyncQueue<Executor>,
+    /// Internal queue of the batches of executor updates (cloneable for async operations)
+    queue: AsyncQueue<Vec<Executor>>,
     /// Current connection state
     pub state: ConnectionState,
     /// Cancellation token for the drain timer (if running)
@@ -172,16 +173,21 @@ impl NodeConnection {
 /// Cloneable and safe to use across await points.
 #[derive(Clone)]
 pub struct NodeConnectionSender {
-    queue: AsyncQueue<Executor>,
+    queue: AsyncQueue<Vec<Executor>>,
     node_name: String,
 }
 
 impl NodeConnectionSender {
     /// Sends an executor update to the node.
     pub async fn send(&self, executor: Executor) -> Result<(), FlameError> {
-        self.queue.push(executor).await.map_err(|_| {
+        self.send_all(vec![executor]).await
+    }
+
+    /// Sends the executor updates to the node in one batch.
+    pub async fn send_all(&self, executors: Vec<Executor>) -> Result<(), FlameError> {
+        self.queue.push(executors).await.map_err(|_| {
             FlameError::Network(format!(
-                "Failed to send executor to node <{}>",
+                "Failed to send executors to node <{}>",
                 self.node_name
             ))
         })
@@ -194,14 +200,14 @@ impl NodeConnectionSender {
 /// Cloneable and safe to use across await points.
 #[derive(Clone)]
 pub struct NodeConnectionReceiver {
-    queue: AsyncQueue<Executor>,
+    queue: AsyncQueue<Vec<Executor>>,
 }
 
 impl NodeConnectionReceiver {
-    /// Receives an executor update from the connection.
+    /// Receives a batch of executor updates from the connection.
     ///
     /// Returns None if the queue is closed.
-    pub async fn recv(&self) -> Option<Executor> {
+    pub async fn recv(&self) -> Option<Vec<Executor>> {
         self.queue.pop().await
     }
 }
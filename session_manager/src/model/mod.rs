@@ -127,6 +127,24 @@ pub struct SessionInfo {
     pub min_instances: u32,
     pub max_instances: Option<u32>,
     pub batch_size: u32,
+    /// The priority boosted by the operators, e.g. by BoostSession; the
+    /// sessions of higher priority are scheduled first.
+    pub priority: u32,
+    /// Since when the open session has pending tasks but no executor, i.e.
+    /// it's starving; it's aged by the scheduler from then.
+    pub starving_since: Option<DateTime<Utc>>,
+}
+
+/// The application excluded from the bindings of an executor, e.g. it's
+/// backing off its crashes on the executor; it's bound again once the
+/// exclusion ends.
+#[derive(Clone, Debug, PartialEq)]
+pub struct Exclusion {
+    pub application: String,
+    pub until: DateTime<Utc>,
+    pub crash_looping: bool,
+    /// The session whose service crashed last.
+    pub session_id: SessionID,
 }
 
 #[derive(Clone, Debug, Default)]
@@ -139,6 +157,9 @@ pub struct ExecutorInfo {
     pub task_id: Option<TaskID>,
     pub ssn_id: Option<SessionID>,
     pub batch_index: Option<u32>,
+    /// The applications backing off or crash-looping on the executor, which
+    /// are not bound to it until the end of their exclusions.
+    pub excluded_applications: Vec<Exclusion>,
 
     pub creation_time: DateTime<Utc>,
     pub state: ExecutorState,
@@ -149,6 +170,8 @@ pub struct NodeInfo {
     pub name: String,
     pub allocatable: ResourceRequirement,
     pub state: NodeState,
+    /// The labels of the node, e.g. the instance type and zone.
+    pub labels: HashMap<String, String>,
 }
 
 #[derive(Clone, Debug, Default)]
@@ -171,6 +194,7 @@ impl From<&Node> for NodeInfo {
             name: node.name.clone(),
             allocatable: node.allocatable.clone(),
             state: node.state,
+            labels: node.info.labels.clone(),
         }
     }
 }
@@ -197,6 +221,7 @@ impl From<&Executor> for ExecutorInfo {
             task_id: exec.task_id,
             ssn_id: exec.ssn_id.clone(),
             batch_index: exec.batch_index,
+            excluded_applications: vec![],
             creation_time: exec.creation_time,
             state: exec.state,
         }
@@ -233,6 +258,10 @@ impl From<&Session> for SessionInfo {
             min_instances: ssn.min_instances,
             max_instances: ssn.max_instances,
             batch_size: ssn.batch_size.max(1),
+            // Set by the storage, which keeps the boosts of the sessions and
+            // the starvation of the sessions.
+            priority: 0,
+            starving_since: None,
         }
     }
 }
@@ -763,6 +792,7 @@ impl SnapShot {
             shim: exec.shim,
             ssn_id: exec.ssn_id.clone(),
             batch_index: exec.batch_index,
+            excluded_applications: exec.excluded_applications.clone(),
             creation_time: exec.creation_time,
             state,
         });
@@ -882,6 +912,7 @@ impl From<&Executor> for rpc::Executor {
         let metadata = Some(rpc::Metadata {
             id: e.id.clone(),
             name: e.id.clone(),
+            resource_version: None,
         });
 
         let spec = Some(rpc::ExecutorSpec {
@@ -924,6 +955,7 @@ mod tests {
             task_id: None,
             ssn_id: None,
             batch_index: None,
+            excluded_applications: vec![],
             creation_time: Utc::now(),
             state,
         })
@@ -942,6 +974,8 @@ mod tests {
             min_instances: 0,
             max_instances: None,
             batch_size: 1,
+            priority: 0,
+            starving_since: None,
         })
     }
 
@@ -59,7 +59,9 @@ impl Action for AllocateAction {
             nodes.len()
         );
 
+        // The preferred nodes, e.g. the cheaper ones, are tried first.
         let node_order_fn = node_order_fn(ctx);
+        nodes.sort_by(|a, b| node_order_fn.cmp(a, b));
 
         loop {
             if open_ssns.is_empty() {
@@ -18,8 +18,8 @@ use stdng::collections::{BinaryHeap, Cmp};
 use stdng::{logs::TraceFn, trace_fn};
 
 use crate::model::{
-    ExecutorInfoPtr, SessionInfoPtr, ALL_EXECUTOR, IDLE_EXECUTOR, OPEN_SESSION, UNBINDING_EXECUTOR,
-    VOID_EXECUTOR,
+    ExecutorInfoPtr, SessionInfoPtr, SnapShotPtr, ALL_EXECUTOR, ALL_NODE, IDLE_EXECUTOR,
+    OPEN_SESSION, UNBINDING_EXECUTOR, VOID_EXECUTOR,
 };
 use crate::scheduler::actions::{Action, ActionPtr};
 use crate::scheduler::plugins::ssn_order_fn;
@@ -44,6 +44,87 @@ impl DispatchAction {
     }
 }
 
+/// The free slots of the nodes, used to select the executors of a session: the
+/// executor on the node with more free slots is selected first, so the sessions
+/// are spread over the nodes by their free slots instead of the iteration order
+/// of maps.
+struct NodeWeights {
+    free_slots: HashMap<String, u32>,
+    /// The rotation among the nodes of the same free slots, continued from
+    /// the binds of the earlier cycles, so the ties are not always broken
+    /// towards the same node.
+    rotation: u64,
+}
+
+/// The selected executor with the rationale of the choice.
+struct Selection {
+    exec: ExecutorInfoPtr,
+    rationale: String,
+}
+
+impl NodeWeights {
+    fn new(ss: &SnapShotPtr, rotation: u64) -> Result<Self, FlameError> {
+        let mut free_slots: HashMap<String, u32> = ss
+            .find_nodes(ALL_NODE)?
+            .values()
+            .map(|node| (node.name.clone(), node.allocatable.to_slots(&ss.unit)))
+            .collect();
+
+        for exec in ss.find_executors(ALL_EXECUTOR)?.values() {
+            if exec.ssn_id.is_none() {
+                continue;
+            }
+            if let Some(free) = free_slots.get_mut(&exec.node) {
+                *free = free.saturating_sub(exec.slots);
+            }
+        }
+
+        Ok(Self {
+            free_slots,
+            rotation,
+        })
+    }
+
+    fn free_slots(&self, exec: &ExecutorInfoPtr) -> u32 {
+        self.free_slots.get(&exec.node).copied().unwrap_or_default()
+    }
+
+    /// Select the candidate on the node with the most free slots; the nodes
+    /// of the same free slots are taken in turn by the rotation, and the
+    /// executors of a node by their id, to keep the selection deterministic.
+    fn select(&mut self, candidates: Vec<ExecutorInfoPtr>) -> Option<Selection> {
+        let count = candidates.len();
+        let most = candidates.iter().map(|e| self.free_slots(e)).max()?;
+
+        let mut ties: Vec<ExecutorInfoPtr> = candidates
+            .into_iter()
+            .filter(|e| self.free_slots(e) == most)
+            .collect();
+        ties.sort_by(|e1, e2| e1.node.cmp(&e2.node).then_with(|| e1.id.cmp(&e2.id)));
+        ties.dedup_by(|e2, e1| e1.node == e2.node);
+
+        let nodes = ties.len();
+        let exec = ties.swap_remove((self.rotation % nodes as u64) as usize);
+        let mut rationale = format!(
+            "node <{}> has the most free slots <{most}> among {count} candidates",
+            exec.node
+        );
+        if nodes > 1 {
+            rationale.push_str(&format!(", in turn with {} other nodes", nodes - 1));
+            self.rotation += 1;
+        }
+
+        Some(Selection { exec, rationale })
+    }
+
+    /// The slots of the executor are used once it's bound to the session.
+    fn on_bind(&mut self, exec: &ExecutorInfoPtr) {
+        if let Some(free) = self.free_slots.get_mut(&exec.node) {
+            *free = free.saturating_sub(exec.slots);
+        }
+    }
+}
+
 #[async_trait::async_trait]
 impl Action for DispatchAction {
     async fn execute(&self, ctx: &mut Context) -> Result<(), FlameError> {
@@ -70,6 +151,8 @@ impl Action for DispatchAction {
             }
         }
 
+        let mut weights = NodeWeights::new(&ss, ctx.controller.bind_count())?;
+
         tracing::debug!("Open sessions: <{:?}>", open_ssns.len());
         tracing::debug!("Idle executors: <{:?}>", idle_executors.len());
         tracing::debug!("Void executors: <{:?}>", void_executors.len());
@@ -95,26 +178,28 @@ impl Action for DispatchAction {
             );
 
             // Allocate idle executors to underused sessions.
-            let mut exec: Option<ExecutorInfoPtr> = None;
-            for (_, e) in idle_executors.iter_mut() {
+            let mut candidates = vec![];
+            for e in idle_executors.values() {
                 if ctx.is_available(e, &ssn)? {
-                    exec = Some(e.clone());
-                    break;
+                    candidates.push(e.clone());
                 }
             }
 
-            if let Some(exec) = exec {
+            if let Some(Selection { exec, rationale }) = weights.select(candidates) {
                 let bound_count = bound_counts.entry(ssn.id.clone()).or_insert(0);
                 let batch_index = Self::next_batch_index(&ssn, *bound_count);
 
                 tracing::debug!(
-                    "Bind executor <{}> for session <{}> with batch_index={:?}.",
+                    "Bind executor <{}> for session <{}> with batch_index={:?}: {}.",
                     exec.id,
                     ssn.id,
-                    batch_index
+                    batch_index,
+                    rationale
                 );
-                ctx.bind_session(&exec, &ssn, batch_index).await?;
+                ctx.bind_session(&exec, &ssn, batch_index, rationale)
+                    .await?;
                 idle_executors.remove(&exec.id);
+                weights.on_bind(&exec);
                 *bound_count += 1;
 
                 open_ssns.push(ssn);
@@ -127,16 +212,20 @@ impl Action for DispatchAction {
             // * For unbinding executors, it means the executor is being unbound from a session.
             //   Pipeline it to the underused session to avoid over preemption.
             for exe_list in [&mut void_executors, &mut unbinding_executors] {
-                let mut exec = None;
-                for (_, e) in exe_list.iter_mut() {
+                let mut candidates = vec![];
+                for e in exe_list.values() {
                     if ctx.is_available(e, &ssn)? {
-                        exec = Some(e.clone());
-                        break;
+                        candidates.push(e.clone());
                     }
                 }
 
-                if let Some(exec) = exec {
-                    tracing::debug!("Pipeline executor <{}> for session <{}>.", exec.id, ssn.id);
+                if let Some(Selection { exec, rationale }) = weights.select(candidates) {
+                    tracing::debug!(
+                        "Pipeline executor <{}> for session <{}>: {}.",
+                        exec.id,
+                        ssn.id,
+                        rationale
+                    );
 
                     ctx.pipeline_session(&exec, &ssn).await?;
                     exe_list.remove(&exec.id);
@@ -147,6 +236,84 @@ impl Action for DispatchAction {
             }
         }
 
-        Ok(())
+        ctx.flush().await
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::model::ExecutorInfo;
+
+    fn new_executor(id: &str, node: &str) -> ExecutorInfoPtr {
+        Arc::new(ExecutorInfo {
+            id: id.to_string(),
+            node: node.to_string(),
+            slots: 1,
+            ..Default::default()
+        })
+    }
+
+    #[test]
+    fn test_select_by_free_slots() {
+        let mut weights = NodeWeights {
+            free_slots: HashMap::from([("node-1".to_string(), 2), ("node-2".to_string(), 1)]),
+            rotation: 0,
+        };
+        let candidates = vec![
+            new_executor("exec-3", "node-2"),
+            new_executor("exec-2", "node-1"),
+            new_executor("exec-1", "node-1"),
+        ];
+
+        // The node with more free slots is selected first, and the executors
+        // of a node by their id.
+        let selection = weights.select(candidates.clone()).unwrap();
+        assert_eq!(selection.exec.id, "exec-1");
+        weights.on_bind(&selection.exec);
+
+        let candidates: Vec<_> = candidates
+            .into_iter()
+            .filter(|e| e.id != selection.exec.id)
+            .collect();
+        let selection = weights.select(candidates).unwrap();
+        assert_eq!(selection.exec.id, "exec-2");
+        assert!(selection.rationale.contains("node-1"));
+
+        assert!(weights.select(vec![]).is_none());
+    }
+
+    #[test]
+    fn test_select_in_turn() {
+        let free_slots = HashMap::from([
+            ("node-1".to_string(), 2),
+            ("node-2".to_string(), 2),
+            ("node-3".to_string(), 1),
+        ]);
+        let candidates = vec![
+            new_executor("exec-1", "node-1"),
+            new_executor("exec-2", "node-1"),
+            new_executor("exec-3", "node-2"),
+            new_executor("exec-4", "node-3"),
+        ];
+
+        // The nodes of the same free slots are taken in turn.
+        let mut weights = NodeWeights {
+            free_slots: free_slots.clone(),
+            rotation: 0,
+        };
+        let selected: Vec<String> = (0..3)
+            .map(|_| weights.select(candidates.clone()).unwrap().exec.id.clone())
+            .collect();
+        assert_eq!(selected, vec!["exec-1", "exec-3", "exec-1"]);
+
+        // The rotation is continued from the earlier cycles.
+        let mut weights = NodeWeights {
+            free_slots,
+            rotation: 1,
+        };
+        let selection = weights.select(candidates).unwrap();
+        assert_eq!(selection.exec.id, "exec-3");
+        assert!(selection.rationale.contains("in turn with 1 other nodes"));
     }
 }
@@ -102,6 +102,8 @@ impl Action for ShuffleAction {
             }
         }
 
+        ctx.flush().await?;
+
         // Release Idle executors, so the resource can be reallocated.
         let idle_execs = ss.find_executors(IDLE_EXECUTOR)?;
         for exec in idle_execs.values() {
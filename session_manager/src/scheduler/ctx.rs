@@ -16,11 +16,12 @@ use std::sync::Arc;
 
 use stdng::collections;
 
-use crate::controller::ControllerPtr;
+use crate::controller::{ControllerPtr, SessionBind};
 use crate::model::{ExecutorInfo, ExecutorInfoPtr, NodeInfoPtr, SessionInfoPtr, SnapShotPtr};
 use crate::scheduler::actions::{ActionPtr, AllocateAction, DispatchAction, ShuffleAction};
 use crate::scheduler::plugins::{PluginManager, PluginManagerPtr};
-use common::apis::ExecutorState;
+use common::apis::{ExecutorID, ExecutorState};
+use common::ctx::FlameCluster;
 use common::FlameError;
 
 pub struct Context {
@@ -28,12 +29,19 @@ pub struct Context {
     pub controller: ControllerPtr,
     pub actions: Vec<ActionPtr>,
     pub plugins: PluginManagerPtr,
+    /// The binds of the cycle, applied at once by `flush`.
+    pub binds: Vec<SessionBind>,
+    /// The executors unbound in the cycle, applied at once by `flush`.
+    pub unbinds: Vec<ExecutorID>,
 }
 
 impl Context {
-    pub fn new(controller: ControllerPtr) -> Result<Self, FlameError> {
+    /// Create the context of a scheduling cycle with the scheduling policies
+    /// of the cluster, e.g. aging and cost.
+    pub fn new(controller: ControllerPtr, cluster: &FlameCluster) -> Result<Self, FlameError> {
         let snapshot = controller.snapshot()?;
-        let plugins = PluginManager::setup(&snapshot.clone())?;
+        let now = controller.clock().utc_now();
+        let plugins = PluginManager::setup_with_cluster(&snapshot.clone(), cluster, now)?;
 
         Ok(Context {
             snapshot,
@@ -44,6 +52,8 @@ impl Context {
                 AllocateAction::new_ptr(),
                 ShuffleAction::new_ptr(),
             ],
+            binds: vec![],
+            unbinds: vec![],
         })
     }
 
@@ -71,15 +81,21 @@ impl Context {
         self.plugins.is_available(exec, ssn)
     }
 
+    /// Bind the executor to the session in the snapshot; the bind is applied
+    /// to the controller by `flush` with the others of the cycle.
     pub async fn bind_session(
-        &self,
+        &mut self,
         exec: &ExecutorInfoPtr,
         ssn: &SessionInfoPtr,
         batch_index: Option<u32>,
+        rationale: String,
     ) -> Result<(), FlameError> {
-        self.controller
-            .bind_session(exec.id.clone(), ssn.id.clone(), batch_index)
-            .await?;
+        self.binds.push(SessionBind {
+            exec_id: exec.id.clone(),
+            ssn_id: ssn.id.clone(),
+            batch_index,
+            rationale: Some(rationale),
+        });
         self.plugins.on_session_bind(ssn.clone())?;
         self.snapshot
             .update_executor_state(exec.clone(), ExecutorState::Binding)?;
@@ -100,12 +116,14 @@ impl Context {
         Ok(())
     }
 
+    /// Unbind the executor in the snapshot; the unbind is applied to the
+    /// controller by `flush` with the others of the cycle.
     pub async fn unbind_session(
-        &self,
+        &mut self,
         exec: &ExecutorInfoPtr,
         ssn: &SessionInfoPtr,
     ) -> Result<(), FlameError> {
-        self.controller.unbind_executor(exec.id.clone()).await?;
+        self.unbinds.push(exec.id.clone());
         self.plugins.on_session_unbind(ssn.clone())?;
         self.snapshot
             .update_executor_state(exec.clone(), ExecutorState::Unbinding)?;
@@ -113,6 +131,24 @@ impl Context {
         Ok(())
     }
 
+    /// Apply the binds and unbinds of the cycle to the controller at once, so
+    /// the storage is written and each node is notified once per action
+    /// instead of once per executor.
+    pub async fn flush(&mut self) -> Result<(), FlameError> {
+        let binds = std::mem::take(&mut self.binds);
+        let unbinds = std::mem::take(&mut self.unbinds);
+        if !binds.is_empty() {
+            tracing::debug!("Bind <{}> executors of the cycle.", binds.len());
+            self.controller.bind_sessions(binds).await?;
+        }
+        if !unbinds.is_empty() {
+            tracing::debug!("Unbind <{}> executors of the cycle.", unbinds.len());
+            self.controller.unbind_executors(unbinds).await?;
+        }
+
+        Ok(())
+    }
+
     pub async fn release_executor(&self, exec: &ExecutorInfoPtr) -> Result<(), FlameError> {
         self.controller.release_executor(exec.id.clone()).await?;
 
@@ -27,6 +27,8 @@ mod ctx;
 mod plugins;
 pub mod statement;
 
+pub use plugins::check_plugins;
+
 pub fn new(controller: ControllerPtr) -> Arc<dyn FlameThread> {
     Arc::new(ScheduleRunner { controller })
 }
@@ -41,8 +43,9 @@ impl FlameThread for ScheduleRunner {
         let schedule_interval = flame_ctx.cluster.schedule_interval;
         tracing::info!("Scheduler started with interval: {}ms", schedule_interval);
 
+        let clock = self.controller.clock();
         loop {
-            let mut ctx = Context::new(self.controller.clone())?;
+            let mut ctx = Context::new(self.controller.clone(), &flame_ctx.cluster)?;
 
             for action in ctx.actions.clone() {
                 if let Err(e) = action.execute(&mut ctx).await {
@@ -50,8 +53,14 @@ impl FlameThread for ScheduleRunner {
                     break;
                 };
             }
+            // Apply the decisions made before a failed action.
+            if let Err(e) = ctx.flush().await {
+                tracing::error!("Failed to apply the scheduling decisions: {e}");
+            }
 
-            tokio::time::sleep(tokio::time::Duration::from_millis(schedule_interval)).await;
+            clock
+                .sleep(tokio::time::Duration::from_millis(schedule_interval))
+                .await;
         }
     }
 }
@@ -71,6 +80,7 @@ mod tests {
     use chrono::Utc;
     use common::apis::{
         Application, ApplicationAttributes, Node, NodeInfo, NodeState, ResourceRequirement, Shim,
+        TaskAttributes,
     };
     use common::ctx::FlameCluster;
     use common::ctx::FlameClusterContext;
@@ -111,6 +121,7 @@ mod tests {
             info: NodeInfo {
                 arch: "x86_64".to_string(),
                 os: "linux".to_string(),
+                ..Default::default()
             },
             state: NodeState::Ready,
         }
@@ -187,10 +198,16 @@ mod tests {
                 min_instances: 0,
                 max_instances: None,
                 batch_size: 1,
+                content_type: None,
+                client_host: None,
+                labels: vec![],
+                environments: HashMap::new(),
             }))?;
 
         for _ in 0..task_num {
-            tokio_test::block_on(controller.create_task(ssn_1.id.clone(), None))?;
+            tokio_test::block_on(
+                controller.create_task(ssn_1.id.clone(), TaskAttributes::default()),
+            )?;
         }
 
         for i in 0..10 {
@@ -202,6 +219,8 @@ mod tests {
                 controller: controller.clone(),
                 plugins,
                 actions: vec![],
+                binds: vec![],
+                unbinds: vec![],
             };
 
             let dispatch = DispatchAction::new_ptr();
@@ -0,0 +1,246 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//! The priority aging to avoid starvation: the open sessions with pending
+//! tasks but no executor gain effective priority by the aging curve of the
+//! cluster since they started to starve, so they're ordered before the others
+//! and are always underused.
+//! The priority boosted by the operators, i.e. BoostSession, is added to the
+//! aged one, so a stuck session is expedited even without aging.
+
+use std::cmp::Ordering;
+use std::collections::{HashMap, HashSet};
+
+use chrono::{DateTime, Utc};
+
+use common::apis::{SessionID, TaskState};
+use common::ctx::{AgingCurve, FlameAging};
+use common::FlameError;
+
+use crate::model::{NodeInfoPtr, SessionInfo, SessionInfoPtr, SnapShot, OPEN_SESSION};
+use crate::scheduler::plugins::{Plugin, PluginPtr};
+
+pub struct AgingPlugin {
+    aging: FlameAging,
+    now: DateTime<Utc>,
+    priorities: HashMap<SessionID, u32>,
+    promoted: HashSet<SessionID>,
+}
+
+impl AgingPlugin {
+    pub fn new_ptr(aging: FlameAging, now: DateTime<Utc>) -> PluginPtr {
+        Box::new(AgingPlugin {
+            aging,
+            now,
+            priorities: HashMap::new(),
+            promoted: HashSet::new(),
+        })
+    }
+
+    fn priority(&self, ssn: &SessionInfo) -> u32 {
+        self.priorities.get(&ssn.id).copied().unwrap_or(0)
+    }
+
+    fn promote(&mut self, ssn: &SessionInfoPtr) {
+        let Some(priority) = self.priorities.get(&ssn.id) else {
+            return;
+        };
+        // Only the promotions by aging are counted, not by the boosts.
+        if *priority <= ssn.priority {
+            return;
+        }
+
+        if self.promoted.insert(ssn.id.clone()) {
+            tracing::info!(
+                "Session <{}> was promoted by aging with priority <{}>.",
+                ssn.id,
+                priority
+            );
+        }
+    }
+}
+
+impl Plugin for AgingPlugin {
+    fn setup(&mut self, ss: &SnapShot) -> Result<(), FlameError> {
+        self.priorities.clear();
+        self.promoted.clear();
+
+        for ssn in ss.find_sessions(OPEN_SESSION)?.values() {
+            let pending = ssn.tasks_status.get(&TaskState::Pending).copied();
+            if pending.unwrap_or(0) == 0 {
+                continue;
+            }
+
+            let mut priority = ssn.priority;
+            let starving_since = ssn
+                .starving_since
+                .filter(|_| self.aging.curve != AgingCurve::None);
+            if let Some(since) = starving_since {
+                let waiting = (self.now - since).num_seconds().max(0) as u64;
+                let aged = self.aging.priority(waiting);
+                if aged > 0 {
+                    tracing::debug!(
+                        "Session <{}> has been starving for {}s, aged to priority <{}>.",
+                        ssn.id,
+                        waiting,
+                        aged
+                    );
+                }
+                priority = priority.saturating_add(aged);
+            }
+            if priority > 0 {
+                self.priorities.insert(ssn.id.clone(), priority);
+            }
+        }
+
+        Ok(())
+    }
+
+    fn ssn_order_fn(&self, s1: &SessionInfo, s2: &SessionInfo) -> Option<Ordering> {
+        match self.priority(s1).cmp(&self.priority(s2)) {
+            Ordering::Equal => None,
+            order => Some(order),
+        }
+    }
+
+    fn is_underused(&self, ssn: &SessionInfoPtr) -> Option<bool> {
+        self.priorities.contains_key(&ssn.id).then_some(true)
+    }
+
+    fn on_pipeline_executor(&mut self, _node: NodeInfoPtr, ssn: SessionInfoPtr) {
+        self.promote(&ssn);
+    }
+
+    fn on_session_bind(&mut self, ssn: SessionInfoPtr) {
+        self.promote(&ssn);
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use std::sync::Arc;
+
+    use chrono::Duration;
+    use common::apis::{ResourceRequirement, SessionState};
+
+    use super::*;
+
+    fn create_test_session(id: &str, waiting: i64, now: DateTime<Utc>) -> SessionInfoPtr {
+        Arc::new(SessionInfo {
+            id: id.to_string(),
+            application: "test-app".to_string(),
+            slots: 1,
+            tasks_status: HashMap::from([(TaskState::Pending, 1)]),
+            creation_time: now - Duration::days(1),
+            completion_time: None,
+            state: SessionState::Open,
+            min_instances: 0,
+            max_instances: None,
+            batch_size: 1,
+            priority: 0,
+            starving_since: Some(now - Duration::seconds(waiting)),
+        })
+    }
+
+    #[test]
+    fn test_aged_sessions_are_ordered_first() {
+        let now = Utc::now();
+        let ss = SnapShot::new(ResourceRequirement {
+            cpu: 1,
+            memory: 1024,
+        });
+        let old = create_test_session("ssn-old", 600, now);
+        let new = create_test_session("ssn-new", 10, now);
+        ss.add_session(old.clone()).unwrap();
+        ss.add_session(new.clone()).unwrap();
+
+        let aging = FlameAging {
+            curve: AgingCurve::Linear,
+            interval: 60,
+            max_priority: 5,
+        };
+        let mut plugin = AgingPlugin::new_ptr(aging, now);
+        plugin.setup(&ss).unwrap();
+
+        assert_eq!(plugin.ssn_order_fn(&old, &new), Some(Ordering::Greater));
+        assert_eq!(plugin.ssn_order_fn(&new, &old), Some(Ordering::Less));
+        assert_eq!(plugin.is_underused(&old), Some(true));
+        assert_eq!(plugin.is_underused(&new), None);
+
+        // The session with an executor is not aged, however old it is.
+        let mut served = (*old).clone();
+        served.starving_since = None;
+        let served = Arc::new(served);
+        let ss = SnapShot::new(ResourceRequirement {
+            cpu: 1,
+            memory: 1024,
+        });
+        ss.add_session(served.clone()).unwrap();
+        ss.add_session(new.clone()).unwrap();
+        plugin.setup(&ss).unwrap();
+        assert_eq!(plugin.ssn_order_fn(&served, &new), None);
+        assert_eq!(plugin.is_underused(&served), None);
+    }
+
+    #[test]
+    fn test_no_aging_by_default() {
+        let now = Utc::now();
+        let ss = SnapShot::new(ResourceRequirement {
+            cpu: 1,
+            memory: 1024,
+        });
+        let old = create_test_session("ssn-old", 3600, now);
+        let new = create_test_session("ssn-new", 0, now);
+        ss.add_session(old.clone()).unwrap();
+        ss.add_session(new.clone()).unwrap();
+
+        let mut plugin = AgingPlugin::new_ptr(FlameAging::default(), now);
+        plugin.setup(&ss).unwrap();
+
+        assert_eq!(plugin.ssn_order_fn(&old, &new), None);
+        assert_eq!(plugin.is_underused(&old), None);
+    }
+
+    #[test]
+    fn test_boosted_sessions_are_ordered_first() {
+        let now = Utc::now();
+        let ss = SnapShot::new(ResourceRequirement {
+            cpu: 1,
+            memory: 1024,
+        });
+        let old = create_test_session("ssn-old", 600, now);
+        let mut boosted = (*create_test_session("ssn-boosted", 10, now)).clone();
+        boosted.priority = 10;
+        let boosted = Arc::new(boosted);
+        ss.add_session(old.clone()).unwrap();
+        ss.add_session(boosted.clone()).unwrap();
+
+        // The boost is honored without aging.
+        let mut plugin = AgingPlugin::new_ptr(FlameAging::default(), now);
+        plugin.setup(&ss).unwrap();
+        assert_eq!(plugin.ssn_order_fn(&boosted, &old), Some(Ordering::Greater));
+        assert_eq!(plugin.is_underused(&boosted), Some(true));
+        assert_eq!(plugin.is_underused(&old), None);
+
+        // The boost is added to the aged priority.
+        let aging = FlameAging {
+            curve: AgingCurve::Linear,
+            interval: 60,
+            max_priority: 5,
+        };
+        let mut plugin = AgingPlugin::new_ptr(aging, now);
+        plugin.setup(&ss).unwrap();
+        assert_eq!(plugin.ssn_order_fn(&boosted, &old), Some(Ordering::Greater));
+        assert_eq!(plugin.is_underused(&old), Some(true));
+    }
+}
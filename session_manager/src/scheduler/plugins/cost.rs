@@ -0,0 +1,329 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//! The cost-aware scheduling by the cloud metadata labels of the nodes and
+//! the price table of the cluster: the cheaper nodes (spot, smaller instance
+//! types) are ordered first for the best-effort sessions, and the idle
+//! on-demand slots are reserved for the guaranteed sessions, i.e. the
+//! sessions with `min_instances`, until their minimum is allocated.
+
+use std::cmp::Ordering;
+use std::collections::HashMap;
+
+use common::apis::{ResourceRequirement, SessionID};
+use common::ctx::FlameCost;
+use common::FlameError;
+
+use crate::model::{
+    NodeInfo, NodeInfoPtr, SessionInfoPtr, SnapShot, ALL_EXECUTOR, ALL_NODE, OPEN_SESSION,
+};
+use crate::scheduler::plugins::{Plugin, PluginPtr};
+
+struct NodeCost {
+    price: f64,
+    spot: bool,
+    idle: u32,
+}
+
+#[derive(Default)]
+struct Guarantee {
+    desired: u32,
+    allocated: u32,
+}
+
+impl Guarantee {
+    fn remaining(&self) -> u32 {
+        self.desired.saturating_sub(self.allocated)
+    }
+}
+
+pub struct CostPlugin {
+    cost: Option<FlameCost>,
+    unit: ResourceRequirement,
+    node_map: HashMap<String, NodeCost>,
+    guarantees: HashMap<SessionID, Guarantee>,
+}
+
+impl CostPlugin {
+    pub fn new_ptr(cost: Option<FlameCost>) -> PluginPtr {
+        Box::new(CostPlugin {
+            cost,
+            unit: ResourceRequirement::default(),
+            node_map: HashMap::new(),
+            guarantees: HashMap::new(),
+        })
+    }
+
+    fn on_demand_idle(&self) -> u32 {
+        self.node_map
+            .values()
+            .filter(|node| !node.spot)
+            .map(|node| node.idle)
+            .sum()
+    }
+
+    /// The on-demand slots reserved for the guaranteed sessions except `ssn`.
+    fn reserved(&self, ssn: &SessionID) -> u32 {
+        self.guarantees
+            .iter()
+            .filter(|(id, _)| *id != ssn)
+            .map(|(_, guarantee)| guarantee.remaining())
+            .sum()
+    }
+
+    fn remaining(&self, ssn: &SessionID) -> u32 {
+        self.guarantees
+            .get(ssn)
+            .map(Guarantee::remaining)
+            .unwrap_or(0)
+    }
+}
+
+impl Plugin for CostPlugin {
+    fn setup(&mut self, ss: &SnapShot) -> Result<(), FlameError> {
+        self.node_map.clear();
+        self.guarantees.clear();
+
+        let Some(cost) = &self.cost else {
+            return Ok(());
+        };
+        self.unit = ss.unit.clone();
+
+        for node in ss.find_nodes(ALL_NODE)?.values() {
+            self.node_map.insert(
+                node.name.clone(),
+                NodeCost {
+                    price: cost.price(&node.labels),
+                    spot: FlameCost::is_spot(&node.labels),
+                    idle: node.allocatable.to_slots(&self.unit),
+                },
+            );
+        }
+
+        for ssn in ss.find_sessions(OPEN_SESSION)?.values() {
+            if ssn.min_instances > 0 {
+                self.guarantees.insert(
+                    ssn.id.clone(),
+                    Guarantee {
+                        desired: ssn.min_instances * ssn.slots,
+                        allocated: 0,
+                    },
+                );
+            }
+        }
+
+        for exe in ss.find_executors(ALL_EXECUTOR)?.values() {
+            if let Some(node) = self.node_map.get_mut(&exe.node) {
+                node.idle = node.idle.saturating_sub(exe.slots);
+            }
+
+            if let Some(guarantee) = exe
+                .ssn_id
+                .as_ref()
+                .and_then(|ssn_id| self.guarantees.get_mut(ssn_id))
+            {
+                guarantee.allocated += exe.slots;
+            }
+        }
+
+        tracing::debug!(
+            "Cost: {} on-demand idle slots, {} reserved for {} guaranteed sessions.",
+            self.on_demand_idle(),
+            self.guarantees
+                .values()
+                .map(Guarantee::remaining)
+                .sum::<u32>(),
+            self.guarantees.len()
+        );
+
+        Ok(())
+    }
+
+    fn node_order_fn(&self, n1: &NodeInfo, n2: &NodeInfo) -> Option<Ordering> {
+        let n1 = self.node_map.get(&n1.name)?;
+        let n2 = self.node_map.get(&n2.name)?;
+
+        // The cheaper node is preferred, i.e. ordered first.
+        match n1.price.partial_cmp(&n2.price) {
+            Some(Ordering::Equal) | None => None,
+            order => order,
+        }
+    }
+
+    fn is_allocatable(&self, node: &NodeInfoPtr, ssn: &SessionInfoPtr) -> Option<bool> {
+        let node = self.node_map.get(&node.name)?;
+
+        if self.remaining(&ssn.id) > 0 {
+            // The guaranteed session only falls back to the spot nodes if
+            // there's not enough on-demand capacity.
+            if node.spot && self.on_demand_idle() >= ssn.slots {
+                return Some(false);
+            }
+            return None;
+        }
+
+        if !node.spot {
+            let reserved = self.reserved(&ssn.id);
+            if self.on_demand_idle() < reserved + ssn.slots {
+                tracing::debug!(
+                    "Session <{}> is not allocatable on on-demand nodes: {} slots are reserved.",
+                    ssn.id,
+                    reserved
+                );
+                return Some(false);
+            }
+        }
+
+        None
+    }
+
+    fn on_pipeline_executor(&mut self, node: NodeInfoPtr, ssn: SessionInfoPtr) {
+        if let Some(node) = self.node_map.get_mut(&node.name) {
+            node.idle = node.idle.saturating_sub(ssn.slots);
+        }
+        if let Some(guarantee) = self.guarantees.get_mut(&ssn.id) {
+            guarantee.allocated += ssn.slots;
+        }
+    }
+
+    fn on_discard_executor(&mut self, node: NodeInfoPtr, ssn: SessionInfoPtr) {
+        if let Some(node) = self.node_map.get_mut(&node.name) {
+            node.idle += ssn.slots;
+        }
+        if let Some(guarantee) = self.guarantees.get_mut(&ssn.id) {
+            guarantee.allocated = guarantee.allocated.saturating_sub(ssn.slots);
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use std::sync::Arc;
+
+    use chrono::Utc;
+    use common::apis::{NodeState, SessionState, TaskState, LABEL_INSTANCE_TYPE, LABEL_SPOT};
+
+    use super::*;
+    use crate::model::SessionInfo;
+
+    fn create_test_node(name: &str, instance_type: &str, spot: bool) -> NodeInfoPtr {
+        Arc::new(NodeInfo {
+            name: name.to_string(),
+            allocatable: ResourceRequirement {
+                cpu: 2,
+                memory: 2048,
+            },
+            state: NodeState::Ready,
+            labels: HashMap::from([
+                (LABEL_INSTANCE_TYPE.to_string(), instance_type.to_string()),
+                (LABEL_SPOT.to_string(), spot.to_string()),
+            ]),
+        })
+    }
+
+    fn create_test_session(id: &str, min_instances: u32) -> SessionInfoPtr {
+        Arc::new(SessionInfo {
+            id: id.to_string(),
+            application: "test-app".to_string(),
+            slots: 1,
+            tasks_status: HashMap::from([(TaskState::Pending, 4)]),
+            creation_time: Utc::now(),
+            completion_time: None,
+            state: SessionState::Open,
+            min_instances,
+            max_instances: None,
+            batch_size: 1,
+            priority: 0,
+            starving_since: None,
+        })
+    }
+
+    fn test_cost() -> FlameCost {
+        FlameCost {
+            prices: HashMap::from([
+                ("m5.large".to_string(), 0.1),
+                ("m5.xlarge".to_string(), 0.2),
+            ]),
+            default_price: 1.0,
+            spot_discount: 0.3,
+        }
+    }
+
+    fn test_snapshot() -> SnapShot {
+        SnapShot::new(ResourceRequirement {
+            cpu: 1,
+            memory: 1024,
+        })
+    }
+
+    #[test]
+    fn test_cheaper_nodes_are_ordered_first() {
+        let ss = test_snapshot();
+        let spot = create_test_node("spot", "m5.xlarge", true);
+        let small = create_test_node("small", "m5.large", false);
+        let large = create_test_node("large", "m5.xlarge", false);
+        let unknown = create_test_node("unknown", "c5.metal", false);
+        for node in [&spot, &small, &large, &unknown] {
+            ss.add_node(node.clone()).unwrap();
+        }
+
+        let mut plugin = CostPlugin::new_ptr(Some(test_cost()));
+        plugin.setup(&ss).unwrap();
+
+        assert_eq!(plugin.node_order_fn(&spot, &small), Some(Ordering::Less));
+        assert_eq!(plugin.node_order_fn(&small, &large), Some(Ordering::Less));
+        assert_eq!(
+            plugin.node_order_fn(&unknown, &large),
+            Some(Ordering::Greater)
+        );
+        assert_eq!(plugin.node_order_fn(&large, &large), None);
+
+        // No opinion without the price table.
+        let mut plugin = CostPlugin::new_ptr(None);
+        plugin.setup(&ss).unwrap();
+        assert_eq!(plugin.node_order_fn(&spot, &small), None);
+    }
+
+    #[test]
+    fn test_on_demand_is_reserved_for_guaranteed() {
+        let ss = test_snapshot();
+        let spot = create_test_node("spot", "m5.large", true);
+        let on_demand = create_test_node("on-demand", "m5.large", false);
+        ss.add_node(spot.clone()).unwrap();
+        ss.add_node(on_demand.clone()).unwrap();
+
+        let guaranteed = create_test_session("guaranteed", 1);
+        let best_effort = create_test_session("best-effort", 0);
+        ss.add_session(guaranteed.clone()).unwrap();
+        ss.add_session(best_effort.clone()).unwrap();
+
+        let mut plugin = CostPlugin::new_ptr(Some(test_cost()));
+        plugin.setup(&ss).unwrap();
+
+        // The guaranteed session stays on the on-demand node.
+        assert_eq!(plugin.is_allocatable(&spot, &guaranteed), Some(false));
+        assert_eq!(plugin.is_allocatable(&on_demand, &guaranteed), None);
+
+        // One of the two on-demand slots is reserved.
+        assert_eq!(plugin.is_allocatable(&spot, &best_effort), None);
+        assert_eq!(plugin.is_allocatable(&on_demand, &best_effort), None);
+        plugin.on_pipeline_executor(on_demand.clone(), best_effort.clone());
+        assert_eq!(plugin.is_allocatable(&on_demand, &best_effort), Some(false));
+
+        // The reservation is released once the minimum is allocated.
+        plugin.on_pipeline_executor(on_demand.clone(), guaranteed.clone());
+        plugin.on_discard_executor(on_demand.clone(), best_effort.clone());
+        assert_eq!(plugin.is_allocatable(&on_demand, &best_effort), None);
+        assert_eq!(plugin.is_allocatable(&spot, &guaranteed), None);
+    }
+}
@@ -0,0 +1,29 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//! The custom plugins built into the session manager, e.g. the placement
+//! tweaks of a platform, without forking the scheduler. To register a plugin,
+//! implement `Plugin` in a module next to this one, and add its name and
+//! builder here, e.g.
+//!
+//!   pub const CUSTOM_PLUGINS: &[(&str, PluginBuilder)] =
+//!       &[("rack-spread", |_, _| RackSpread::new_ptr())];
+//!
+//! The custom plugins are consulted after the built-in plugins, unless
+//! `cluster.plugins` is configured; their names must not be the ones of the
+//! built-in plugins, which is checked at startup.
+
+use crate::scheduler::plugins::PluginBuilder;
+
+/// The custom plugins in their default order.
+pub const CUSTOM_PLUGINS: &[(&str, PluginBuilder)] = &[];
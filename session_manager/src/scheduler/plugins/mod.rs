@@ -11,21 +11,43 @@ See the License for the specific language governing permissions and
 limitations under the License.
 */
 
+//! The plugins of the scheduler, which order and filter the sessions, nodes
+//! and executors for the actions. The plugins are looked up by name in the
+//! registry, i.e. the built-in plugins then the custom plugins of `custom`,
+//! and enabled by `cluster.plugins` in order; e.g.
+//!
+//!   cluster:
+//!     plugins: ["aging", "fairshare", "shim", "gang", "rack-spread"]
+//!
+//! For each scheduling cycle, the plugins are built by their `PluginBuilder`
+//! with the cluster and the time of the cycle, then `Plugin::setup` with the
+//! snapshot; the order and filter fns are consulted by the actions, and the
+//! event callbacks are called as the statements are committed. The plugins
+//! are dropped after the cycle, so they keep no state between the cycles.
+
 use std::cmp::Ordering;
-use std::collections::HashMap;
+use std::collections::{HashMap, HashSet};
 use std::sync::Arc;
 
+use chrono::{DateTime, Utc};
 use stdng::collections;
 use stdng::{lock_ptr, new_ptr, MutexPtr};
 
 use crate::model::{ExecutorInfoPtr, NodeInfo, NodeInfoPtr, SessionInfo, SessionInfoPtr, SnapShot};
+use crate::scheduler::plugins::aging::AgingPlugin;
+use crate::scheduler::plugins::cost::CostPlugin;
+use crate::scheduler::plugins::custom::CUSTOM_PLUGINS;
 use crate::scheduler::plugins::fairshare::FairShare;
 use crate::scheduler::plugins::gang::GangPlugin;
 use crate::scheduler::plugins::shim::ShimPlugin;
 use crate::scheduler::Context;
 
+use common::ctx::FlameCluster;
 use common::FlameError;
 
+mod aging;
+mod cost;
+mod custom;
 mod fairshare;
 mod gang;
 mod shim;
@@ -33,6 +55,70 @@ mod shim;
 pub type PluginPtr = Box<dyn Plugin>;
 pub type PluginManagerPtr = Arc<PluginManager>;
 
+/// The constructor of a plugin for a scheduling cycle by the cluster and the
+/// time of the cycle.
+pub type PluginBuilder = fn(&FlameCluster, DateTime<Utc>) -> PluginPtr;
+
+/// The built-in plugins in their default order; e.g. the aging plugin orders
+/// the sessions and the cost plugin orders the nodes before fairshare.
+const BUILTIN_PLUGINS: &[(&str, PluginBuilder)] = &[
+    ("aging", |cluster, now| {
+        AgingPlugin::new_ptr(cluster.aging.clone(), now)
+    }),
+    ("cost", |cluster, _| {
+        CostPlugin::new_ptr(cluster.cost.clone())
+    }),
+    ("fairshare", |_, _| FairShare::new_ptr()),
+    ("shim", |_, _| ShimPlugin::new_ptr()),
+    ("gang", |_, _| GangPlugin::new_ptr()),
+];
+
+/// The builders of the plugins enabled by the cluster, in order.
+fn enabled_plugins(
+    cluster: &FlameCluster,
+) -> Result<Vec<(&'static str, PluginBuilder)>, FlameError> {
+    let registry = BUILTIN_PLUGINS.iter().chain(CUSTOM_PLUGINS.iter());
+    let Some(names) = &cluster.plugins else {
+        return Ok(registry.copied().collect());
+    };
+
+    names
+        .iter()
+        .map(|name| {
+            registry
+                .clone()
+                .find(|(n, _)| *n == name.as_str())
+                .copied()
+                .ok_or(FlameError::InvalidConfig(format!(
+                    "unknown scheduler plugin <{name}>"
+                )))
+        })
+        .collect()
+}
+
+/// Check the plugins of the cluster at startup instead of the first
+/// scheduling cycle: they must be registered, and not duplicated.
+pub fn check_plugins(cluster: &FlameCluster) -> Result<(), FlameError> {
+    let mut registered = HashSet::new();
+    for (name, _) in BUILTIN_PLUGINS.iter().chain(CUSTOM_PLUGINS.iter()) {
+        if !registered.insert(*name) {
+            return Err(FlameError::InvalidConfig(format!(
+                "duplicated scheduler plugin <{name}>"
+            )));
+        }
+    }
+    let mut enabled = HashSet::new();
+    for name in cluster.plugins.iter().flatten() {
+        if !enabled.insert(name) {
+            return Err(FlameError::InvalidConfig(format!(
+                "scheduler plugin <{name}> is enabled twice"
+            )));
+        }
+    }
+
+    enabled_plugins(cluster).map(|_| ())
+}
+
 /// Plugin trait for scheduler plugins.
 ///
 /// # Stale Data Limitation
@@ -102,23 +188,39 @@ pub trait Plugin: Send + Sync + 'static {
 }
 
 pub struct PluginManager {
-    pub plugins: MutexPtr<HashMap<String, PluginPtr>>,
+    /// The plugins by name, which are consulted in order, see
+    /// `BUILTIN_PLUGINS`.
+    pub plugins: MutexPtr<Vec<(String, PluginPtr)>>,
+    /// The time of the scheduling cycle, e.g. to expire the exclusions of
+    /// the executors.
+    now: DateTime<Utc>,
 }
 
 impl PluginManager {
     pub fn setup(ss: &SnapShot) -> Result<PluginManagerPtr, FlameError> {
-        let mut plugins = HashMap::from([
-            ("fairshare".to_string(), FairShare::new_ptr()),
-            ("shim".to_string(), ShimPlugin::new_ptr()),
-            ("gang".to_string(), GangPlugin::new_ptr()),
-        ]);
-
-        for plugin in plugins.values_mut() {
+        Self::setup_with_cluster(ss, &FlameCluster::default(), Utc::now())
+    }
+
+    /// Set up the plugins with the aging policy and price table of the
+    /// cluster; `now` is the time of the scheduling cycle to age the waiting
+    /// sessions.
+    pub fn setup_with_cluster(
+        ss: &SnapShot,
+        cluster: &FlameCluster,
+        now: DateTime<Utc>,
+    ) -> Result<PluginManagerPtr, FlameError> {
+        let mut plugins = enabled_plugins(cluster)?
+            .into_iter()
+            .map(|(name, builder)| (name.to_string(), builder(cluster, now)))
+            .collect::<Vec<_>>();
+
+        for (_, plugin) in plugins.iter_mut() {
             plugin.setup(ss)?;
         }
 
         Ok(Arc::new(PluginManager {
             plugins: new_ptr(plugins),
+            now,
         }))
     }
 
@@ -126,16 +228,16 @@ impl PluginManager {
         let plugins = lock_ptr!(self.plugins)?;
 
         Ok(plugins
-            .values()
-            .any(|plugin| plugin.is_underused(ssn).unwrap_or(false)))
+            .iter()
+            .any(|(_, plugin)| plugin.is_underused(ssn).unwrap_or(false)))
     }
 
     pub fn is_preemptible(&self, ssn: &SessionInfoPtr) -> Result<bool, FlameError> {
         let plugins = lock_ptr!(self.plugins)?;
 
         Ok(plugins
-            .values()
-            .all(|plugin| plugin.is_preemptible(ssn).unwrap_or(false)))
+            .iter()
+            .all(|(_, plugin)| plugin.is_preemptible(ssn).unwrap_or(false)))
     }
 
     /// Check if an executor is available for a session.
@@ -152,6 +254,22 @@ impl PluginManager {
         exec: &ExecutorInfoPtr,
         ssn: &SessionInfoPtr,
     ) -> Result<bool, FlameError> {
+        // The application crash-looping on the executor is not bound to it
+        // until the end of its exclusion.
+        if exec
+            .excluded_applications
+            .iter()
+            .any(|e| e.application == ssn.application && e.until > self.now)
+        {
+            tracing::debug!(
+                "Executor <{}> excludes application <{}> of session <{}>",
+                exec.id,
+                ssn.application,
+                ssn.id
+            );
+            return Ok(false);
+        }
+
         let plugins = lock_ptr!(self.plugins)?;
 
         for (name, plugin) in plugins.iter() {
@@ -191,16 +309,16 @@ impl PluginManager {
         let plugins = lock_ptr!(self.plugins)?;
 
         Ok(plugins
-            .values()
-            .all(|plugin| plugin.is_allocatable(node, ssn).unwrap_or(true)))
+            .iter()
+            .all(|(_, plugin)| plugin.is_allocatable(node, ssn).unwrap_or(true)))
     }
 
     pub fn is_reclaimable(&self, exec: &ExecutorInfoPtr) -> Result<bool, FlameError> {
         let plugins = lock_ptr!(self.plugins)?;
 
         Ok(plugins
-            .values()
-            .all(|plugin| plugin.is_reclaimable(exec).unwrap_or(true)))
+            .iter()
+            .all(|(_, plugin)| plugin.is_reclaimable(exec).unwrap_or(true)))
     }
 
     pub fn on_create_executor(
@@ -210,7 +328,7 @@ impl PluginManager {
     ) -> Result<(), FlameError> {
         let mut plugins = lock_ptr!(self.plugins)?;
 
-        for plugin in plugins.values_mut() {
+        for (_, plugin) in plugins.iter_mut() {
             plugin.on_create_executor(node.clone(), ssn.clone());
         }
 
@@ -220,7 +338,7 @@ impl PluginManager {
     pub fn on_session_bind(&self, ssn: SessionInfoPtr) -> Result<(), FlameError> {
         let mut plugins = lock_ptr!(self.plugins)?;
 
-        for plugin in plugins.values_mut() {
+        for (_, plugin) in plugins.iter_mut() {
             plugin.on_session_bind(ssn.clone());
         }
 
@@ -230,7 +348,7 @@ impl PluginManager {
     pub fn on_session_unbind(&self, ssn: SessionInfoPtr) -> Result<(), FlameError> {
         let mut plugins = lock_ptr!(self.plugins)?;
 
-        for plugin in plugins.values_mut() {
+        for (_, plugin) in plugins.iter_mut() {
             plugin.on_session_unbind(ssn.clone());
         }
         Ok(())
@@ -240,8 +358,8 @@ impl PluginManager {
         let plugins = lock_ptr!(self.plugins)?;
 
         Ok(plugins
-            .values()
-            .all(|plugin| plugin.is_ready(ssn).unwrap_or(true)))
+            .iter()
+            .all(|(_, plugin)| plugin.is_ready(ssn).unwrap_or(true)))
     }
 
     pub fn on_pipeline_executor(
@@ -251,7 +369,7 @@ impl PluginManager {
     ) -> Result<(), FlameError> {
         let mut plugins = lock_ptr!(self.plugins)?;
 
-        for plugin in plugins.values_mut() {
+        for (_, plugin) in plugins.iter_mut() {
             plugin.on_pipeline_executor(node.clone(), ssn.clone());
         }
 
@@ -265,7 +383,7 @@ impl PluginManager {
     ) -> Result<(), FlameError> {
         let mut plugins = lock_ptr!(self.plugins)?;
 
-        for plugin in plugins.values_mut() {
+        for (_, plugin) in plugins.iter_mut() {
             plugin.on_discard_executor(node.clone(), ssn.clone());
         }
 
@@ -274,7 +392,7 @@ impl PluginManager {
 
     pub fn ssn_order_fn(&self, t1: &SessionInfoPtr, t2: &SessionInfoPtr) -> Ordering {
         if let Ok(plugins) = lock_ptr!(self.plugins) {
-            for plugin in plugins.values() {
+            for (_, plugin) in plugins.iter() {
                 if let Some(order) = plugin.ssn_order_fn(t1, t2) {
                     if order != Ordering::Equal {
                         return order;
@@ -288,7 +406,7 @@ impl PluginManager {
 
     pub fn node_order_fn(&self, t1: &NodeInfoPtr, t2: &NodeInfoPtr) -> Ordering {
         if let Ok(plugins) = lock_ptr!(self.plugins) {
-            for plugin in plugins.values() {
+            for (_, plugin) in plugins.iter() {
                 if let Some(order) = plugin.node_order_fn(t1, t2) {
                     if order != Ordering::Equal {
                         return order;
@@ -385,7 +503,7 @@ impl collections::Cmp<SessionInfoPtr> for SsnOrderFn {
 #[cfg(test)]
 mod tests {
     use super::*;
-    use crate::model::{ExecutorInfo, SessionInfo};
+    use crate::model::{Exclusion, ExecutorInfo, SessionInfo};
     use chrono::Utc;
     use common::apis::{ExecutorState, ResourceRequirement, SessionState, Shim, TaskState};
     use std::collections::HashMap;
@@ -403,6 +521,8 @@ mod tests {
             min_instances: 0,
             max_instances: None,
             batch_size: 1,
+            priority: 0,
+            starving_since: None,
         })
     }
 
@@ -420,6 +540,7 @@ mod tests {
             task_id: None,
             ssn_id: None,
             batch_index: None,
+            excluded_applications: vec![],
             creation_time: Utc::now(),
             state: ExecutorState::Idle,
         })
@@ -461,6 +582,41 @@ mod tests {
         );
     }
 
+    /// Test that is_available returns false for the excluded application.
+    #[test]
+    fn test_is_available_excluded_application() {
+        let ss = SnapShot::new(ResourceRequirement {
+            cpu: 1,
+            memory: 1024,
+        });
+        let now = Utc::now();
+        let pm = PluginManager::setup_with_cluster(&ss, &FlameCluster::default(), now).unwrap();
+
+        let ssn = create_test_session("ssn-1", 2);
+        let exec = Arc::new(ExecutorInfo {
+            excluded_applications: vec![Exclusion {
+                application: "test-app".to_string(),
+                until: now + chrono::Duration::seconds(30),
+                crash_looping: true,
+                session_id: "ssn-0".to_string(),
+            }],
+            ..(*create_test_executor("exec-1", 2)).clone()
+        });
+
+        assert!(
+            !pm.is_available(&exec, &ssn).unwrap(),
+            "Executor should not be available for the crash-looping application"
+        );
+
+        // The application is bound again once the exclusion ends.
+        let later = now + chrono::Duration::seconds(30);
+        let pm = PluginManager::setup_with_cluster(&ss, &FlameCluster::default(), later).unwrap();
+        assert!(
+            pm.is_available(&exec, &ssn).unwrap(),
+            "Executor should be available after the exclusion"
+        );
+    }
+
     /// Test find_available_executors filters correctly based on slots.
     #[test]
     fn test_find_available_executors_filters_by_slots() {
@@ -552,4 +708,32 @@ mod tests {
         //
         // This is a known limitation documented in the Plugin trait.
     }
+
+    /// Test that the plugins are enabled by the cluster in order.
+    #[test]
+    fn test_enabled_plugins() {
+        let names = |cluster: &FlameCluster| {
+            enabled_plugins(cluster)
+                .unwrap()
+                .into_iter()
+                .map(|(name, _)| name)
+                .collect::<Vec<_>>()
+        };
+
+        let mut cluster = FlameCluster::default();
+        assert!(check_plugins(&cluster).is_ok());
+        assert_eq!(
+            names(&cluster),
+            vec!["aging", "cost", "fairshare", "shim", "gang"]
+        );
+
+        cluster.plugins = Some(vec!["shim".to_string(), "fairshare".to_string()]);
+        assert!(check_plugins(&cluster).is_ok());
+        assert_eq!(names(&cluster), vec!["shim", "fairshare"]);
+
+        cluster.plugins = Some(vec!["shim".to_string(), "rack-spread".to_string()]);
+        assert!(check_plugins(&cluster).is_err());
+        cluster.plugins = Some(vec!["shim".to_string(), "shim".to_string()]);
+        assert!(check_plugins(&cluster).is_err());
+    }
 }
@@ -0,0 +1,240 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//! The engine encrypting the inputs and outputs of the tasks by the keys of
+//! their tenants before they're persisted by another engine, see `kms`. The
+//! tenant of a task is the tenant claim of its principal.
+
+use std::sync::Arc;
+
+use async_trait::async_trait;
+
+use crate::model::Executor;
+use crate::storage::kms::{self, KeyProviderPtr};
+use crate::FlameError;
+use common::apis::principal::DEFAULT_TENANT;
+use common::apis::{
+    Application, ApplicationAttributes, ApplicationID, ExecutorID, ExecutorState, Node, Principal,
+    Session, SessionAttributes, SessionID, Task, TaskAttributes, TaskGID, TaskResult, TaskState,
+};
+
+use super::{Engine, EnginePtr};
+
+pub struct EncryptedEngine {
+    inner: EnginePtr,
+    kms: KeyProviderPtr,
+}
+
+impl EncryptedEngine {
+    pub fn new_ptr(inner: EnginePtr, kms: KeyProviderPtr) -> EnginePtr {
+        Arc::new(Self { inner, kms })
+    }
+
+    /// Decrypt the input and output of the task read from the engine.
+    async fn open(&self, mut task: Task) -> Result<Task, FlameError> {
+        let tenant = tenant_of(&task.principal).to_string();
+        if let Some(input) = task.input.take() {
+            task.input = Some(kms::open(self.kms.as_ref(), &tenant, &task.ssn_id, input).await?);
+        }
+        if let Some(output) = task.output.take() {
+            task.output = Some(kms::open(self.kms.as_ref(), &tenant, &task.ssn_id, output).await?);
+        }
+
+        Ok(task)
+    }
+}
+
+fn tenant_of(principal: &Option<Principal>) -> &str {
+    principal.as_ref().map_or(DEFAULT_TENANT, Principal::tenant)
+}
+
+#[async_trait]
+impl Engine for EncryptedEngine {
+    async fn register_application(
+        &self,
+        name: String,
+        attr: ApplicationAttributes,
+    ) -> Result<Application, FlameError> {
+        self.inner.register_application(name, attr).await
+    }
+
+    async fn unregister_application(&self, id: String) -> Result<(), FlameError> {
+        self.inner.unregister_application(id).await
+    }
+
+    async fn update_application(
+        &self,
+        id: String,
+        attr: ApplicationAttributes,
+        version: Option<u32>,
+    ) -> Result<Application, FlameError> {
+        self.inner.update_application(id, attr, version).await
+    }
+
+    async fn get_application(&self, id: ApplicationID) -> Result<Application, FlameError> {
+        self.inner.get_application(id).await
+    }
+
+    async fn find_application(&self) -> Result<Vec<Application>, FlameError> {
+        self.inner.find_application().await
+    }
+
+    async fn create_session(&self, attr: SessionAttributes) -> Result<Session, FlameError> {
+        self.inner.create_session(attr).await
+    }
+
+    async fn get_session(&self, id: SessionID) -> Result<Session, FlameError> {
+        self.inner.get_session(id).await
+    }
+
+    async fn open_session(
+        &self,
+        id: SessionID,
+        spec: Option<SessionAttributes>,
+    ) -> Result<Session, FlameError> {
+        self.inner.open_session(id, spec).await
+    }
+
+    async fn close_session(&self, id: SessionID) -> Result<Session, FlameError> {
+        self.inner.close_session(id).await
+    }
+
+    async fn delete_session(&self, id: SessionID) -> Result<Session, FlameError> {
+        self.inner.delete_session(id).await
+    }
+
+    async fn find_session(&self) -> Result<Vec<Session>, FlameError> {
+        self.inner.find_session().await
+    }
+
+    async fn create_task(
+        &self,
+        ssn_id: SessionID,
+        mut attr: TaskAttributes,
+    ) -> Result<Task, FlameError> {
+        if let Some(input) = attr.input.take() {
+            let tenant = tenant_of(&attr.principal);
+            attr.input = Some(kms::seal(self.kms.as_ref(), tenant, &ssn_id, &input).await?);
+        }
+
+        let task = self.inner.create_task(ssn_id, attr).await?;
+        self.open(task).await
+    }
+
+    async fn get_task(&self, gid: TaskGID) -> Result<Task, FlameError> {
+        let task = self.inner.get_task(gid).await?;
+        self.open(task).await
+    }
+
+    async fn retry_task(&self, gid: TaskGID) -> Result<Task, FlameError> {
+        let task = self.inner.retry_task(gid).await?;
+        self.open(task).await
+    }
+
+    async fn delete_task(&self, gid: TaskGID) -> Result<Task, FlameError> {
+        let task = self.inner.delete_task(gid).await?;
+        self.open(task).await
+    }
+
+    async fn update_task_state(
+        &self,
+        gid: TaskGID,
+        task_state: TaskState,
+        message: Option<String>,
+    ) -> Result<Task, FlameError> {
+        let task = self
+            .inner
+            .update_task_state(gid, task_state, message)
+            .await?;
+        self.open(task).await
+    }
+
+    async fn update_task_result(
+        &self,
+        gid: TaskGID,
+        mut task_result: TaskResult,
+    ) -> Result<Task, FlameError> {
+        if let Some(output) = task_result.output.take() {
+            // The result has no principal, so it's encrypted for the tenant
+            // of the task.
+            let task = self.inner.get_task(gid.clone()).await?;
+            let tenant = tenant_of(&task.principal);
+            task_result.output =
+                Some(kms::seal(self.kms.as_ref(), tenant, &gid.ssn_id, &output).await?);
+        }
+
+        let task = self.inner.update_task_result(gid, task_result).await?;
+        self.open(task).await
+    }
+
+    async fn find_tasks(&self, ssn_id: SessionID) -> Result<Vec<Task>, FlameError> {
+        let mut tasks = vec![];
+        for task in self.inner.find_tasks(ssn_id).await? {
+            tasks.push(self.open(task).await?);
+        }
+
+        Ok(tasks)
+    }
+
+    async fn create_node(&self, node: &Node) -> Result<Node, FlameError> {
+        self.inner.create_node(node).await
+    }
+
+    async fn get_node(&self, name: &str) -> Result<Option<Node>, FlameError> {
+        self.inner.get_node(name).await
+    }
+
+    async fn update_node(&self, node: &Node) -> Result<Node, FlameError> {
+        self.inner.update_node(node).await
+    }
+
+    async fn delete_node(&self, name: &str) -> Result<(), FlameError> {
+        self.inner.delete_node(name).await
+    }
+
+    async fn find_nodes(&self) -> Result<Vec<Node>, FlameError> {
+        self.inner.find_nodes().await
+    }
+
+    async fn create_executor(&self, executor: &Executor) -> Result<Executor, FlameError> {
+        self.inner.create_executor(executor).await
+    }
+
+    async fn get_executor(&self, id: &ExecutorID) -> Result<Option<Executor>, FlameError> {
+        self.inner.get_executor(id).await
+    }
+
+    async fn update_executor(&self, executor: &Executor) -> Result<Executor, FlameError> {
+        self.inner.update_executor(executor).await
+    }
+
+    async fn update_executors(&self, executors: &[Executor]) -> Result<Vec<Executor>, FlameError> {
+        self.inner.update_executors(executors).await
+    }
+
+    async fn update_executor_state(
+        &self,
+        id: &ExecutorID,
+        state: ExecutorState,
+    ) -> Result<Executor, FlameError> {
+        self.inner.update_executor_state(id, state).await
+    }
+
+    async fn delete_executor(&self, id: &ExecutorID) -> Result<(), FlameError> {
+        self.inner.delete_executor(id).await
+    }
+
+    async fn find_executors(&self, node: Option<&str>) -> Result<Vec<Executor>, FlameError> {
+        self.inner.find_executors(node).await
+    }
+}
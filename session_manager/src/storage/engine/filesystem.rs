@@ -52,13 +52,13 @@ use serde::{Deserialize, Serialize};
 use common::apis::{
     Application, ApplicationAttributes, ApplicationID, ApplicationSchema, ApplicationState,
     ExecutorID, ExecutorState, Node, NodeInfo, NodeState, ResourceRequirement, Session,
-    SessionAttributes, SessionID, SessionState, SessionStatus, Shim, Task, TaskGID, TaskID,
-    TaskInput, TaskOutput, TaskResult, TaskState,
+    SessionAttributes, SessionID, SessionState, SessionStatus, Shim, Task, TaskAttributes, TaskGID,
+    TaskID, TaskOutput, TaskResult, TaskState,
 };
 use common::{FlameError, FLAME_HOME};
 
 use crate::model::Executor;
-use crate::storage::engine::{Engine, EnginePtr};
+use crate::storage::engine::{check_version, Engine, EnginePtr};
 
 /// Task metadata stored in tasks.bin with fixed-size records.
 ///
@@ -102,6 +102,14 @@ struct SessionMetadata {
     pub max_instances: Option<u32>,
     #[serde(default = "default_batch_size")]
     pub batch_size: u32,
+    #[serde(default)]
+    pub content_type: Option<String>,
+    #[serde(default)]
+    pub client_host: Option<String>,
+    #[serde(default)]
+    pub labels: Vec<String>,
+    #[serde(default)]
+    pub environments: HashMap<String, String>,
     pub common_data_len: u64,
 }
 
@@ -136,6 +144,8 @@ struct ApplicationSchemaMetadata {
     pub input: Option<String>,
     pub output: Option<String>,
     pub common_data: Option<String>,
+    #[serde(default)]
+    pub content_types: Vec<String>,
 }
 
 #[derive(Serialize, Deserialize, Debug, Clone)]
@@ -148,6 +158,8 @@ struct NodeMetadata {
     pub allocatable_memory: u64,
     pub info_arch: String,
     pub info_os: String,
+    #[serde(default)]
+    pub info_labels: HashMap<String, String>,
     pub creation_time: i64,
     pub last_heartbeat: i64,
 }
@@ -332,6 +344,73 @@ impl FilesystemEngine {
         self.base_path.join("sessions").join(session_id)
     }
 
+    /// The reference of the output of a task, which is kept out of the task
+    /// metadata so the records stay fixed-size.
+    fn output_ref_path(&self, session_id: &str, task_id: TaskID) -> PathBuf {
+        self.session_path(session_id)
+            .join("output_refs")
+            .join(task_id.to_string())
+    }
+
+    /// The principal of a task in JSON, which is kept out of the task metadata
+    /// as the output reference.
+    fn principal_path(&self, session_id: &str, task_id: TaskID) -> PathBuf {
+        self.session_path(session_id)
+            .join("principals")
+            .join(task_id.to_string())
+    }
+
+    /// The tags of a task in JSON, which are kept out of the task metadata as
+    /// the principal.
+    fn tags_path(&self, session_id: &str, task_id: TaskID) -> PathBuf {
+        self.session_path(session_id)
+            .join("tags")
+            .join(task_id.to_string())
+    }
+
+    /// The labels of a task in JSON, like the tags.
+    fn labels_path(&self, session_id: &str, task_id: TaskID) -> PathBuf {
+        self.session_path(session_id)
+            .join("labels")
+            .join(task_id.to_string())
+    }
+
+    /// The deadline of a task in milliseconds since the epoch.
+    fn deadline_path(&self, session_id: &str, task_id: TaskID) -> PathBuf {
+        self.session_path(session_id)
+            .join("deadlines")
+            .join(task_id.to_string())
+    }
+
+    /// The time before which a task is not launched, in milliseconds since
+    /// the epoch.
+    fn run_at_path(&self, session_id: &str, task_id: TaskID) -> PathBuf {
+        self.session_path(session_id)
+            .join("run_ats")
+            .join(task_id.to_string())
+    }
+
+    /// The ids of the parents of a task in JSON, like the tags.
+    fn depends_on_path(&self, session_id: &str, task_id: TaskID) -> PathBuf {
+        self.session_path(session_id)
+            .join("dependencies")
+            .join(task_id.to_string())
+    }
+
+    /// The provenance of a task in JSON, like the principal.
+    fn provenance_path(&self, session_id: &str, task_id: TaskID) -> PathBuf {
+        self.session_path(session_id)
+            .join("provenances")
+            .join(task_id.to_string())
+    }
+
+    /// The group of a task in the session.
+    fn group_path(&self, session_id: &str, task_id: TaskID) -> PathBuf {
+        self.session_path(session_id)
+            .join("groups")
+            .join(task_id.to_string())
+    }
+
     fn application_path(&self, app_name: &str) -> PathBuf {
         self.base_path.join("applications").join(app_name)
     }
@@ -715,6 +794,42 @@ impl FilesystemEngine {
             None
         };
 
+        let output_ref = if output.is_none() && meta.completion_time > 0 {
+            std::fs::read_to_string(self.output_ref_path(session_id, meta.id as TaskID)).ok()
+        } else {
+            None
+        };
+
+        let principal = std::fs::read_to_string(self.principal_path(session_id, meta.id as TaskID))
+            .ok()
+            .and_then(|principal| serde_json::from_str(&principal).ok());
+        let tags = std::fs::read_to_string(self.tags_path(session_id, meta.id as TaskID))
+            .ok()
+            .and_then(|tags| serde_json::from_str(&tags).ok())
+            .unwrap_or_default();
+        let deadline = std::fs::read_to_string(self.deadline_path(session_id, meta.id as TaskID))
+            .ok()
+            .and_then(|deadline| deadline.trim().parse::<i64>().ok())
+            .and_then(DateTime::from_timestamp_millis);
+        let group = std::fs::read_to_string(self.group_path(session_id, meta.id as TaskID)).ok();
+        let run_at = std::fs::read_to_string(self.run_at_path(session_id, meta.id as TaskID))
+            .ok()
+            .and_then(|run_at| run_at.trim().parse::<i64>().ok())
+            .and_then(DateTime::from_timestamp_millis);
+        let labels = std::fs::read_to_string(self.labels_path(session_id, meta.id as TaskID))
+            .ok()
+            .and_then(|labels| serde_json::from_str(&labels).ok())
+            .unwrap_or_default();
+        let depends_on =
+            std::fs::read_to_string(self.depends_on_path(session_id, meta.id as TaskID))
+                .ok()
+                .and_then(|depends_on| serde_json::from_str(&depends_on).ok())
+                .unwrap_or_default();
+        let provenance =
+            std::fs::read_to_string(self.provenance_path(session_id, meta.id as TaskID))
+                .ok()
+                .and_then(|provenance| serde_json::from_str(&provenance).ok());
+
         let state = TaskState::try_from(meta.state as i32)?;
         let completion_time = if meta.completion_time > 0 {
             DateTime::from_timestamp(meta.completion_time, 0)
@@ -728,6 +843,15 @@ impl FilesystemEngine {
             version: meta.version,
             input,
             output,
+            output_ref,
+            principal,
+            tags,
+            deadline,
+            group,
+            labels,
+            run_at,
+            depends_on,
+            provenance,
             creation_time: DateTime::from_timestamp(meta.creation_time, 0)
                 .ok_or_else(|| FlameError::Storage("Invalid creation time".to_string()))?,
             completion_time,
@@ -760,6 +884,10 @@ impl FilesystemEngine {
             min_instances: meta.min_instances,
             max_instances: meta.max_instances,
             batch_size: meta.batch_size.max(1),
+            content_type: meta.content_type.clone(),
+            client_host: meta.client_host.clone(),
+            labels: meta.labels.clone(),
+            environments: meta.environments.clone(),
         })
     }
 
@@ -770,6 +898,7 @@ impl FilesystemEngine {
             input: s.input.clone(),
             output: s.output.clone(),
             common_data: s.common_data.clone(),
+            content_types: s.content_types.clone(),
         });
 
         Ok(Application {
@@ -844,6 +973,7 @@ impl Engine for FilesystemEngine {
             input: s.input,
             output: s.output,
             common_data: s.common_data,
+            content_types: s.content_types,
         });
 
         let meta = ApplicationMetadata {
@@ -899,10 +1029,12 @@ impl Engine for FilesystemEngine {
         &self,
         name: String,
         attr: ApplicationAttributes,
+        version: Option<u32>,
     ) -> Result<Application, FlameError> {
         let _guard = lock_app!(self)?;
 
         let mut meta = self.read_application_metadata(&name)?;
+        check_version(&format!("application <{name}>"), meta.version, version)?;
 
         let sessions_dir = self.base_path.join("sessions");
         if let Ok(entries) = fs::read_dir(&sessions_dir) {
@@ -923,6 +1055,7 @@ impl Engine for FilesystemEngine {
             input: s.input,
             output: s.output,
             common_data: s.common_data,
+            content_types: s.content_types,
         });
 
         meta.version += 1;
@@ -1002,6 +1135,10 @@ impl Engine for FilesystemEngine {
             min_instances: attr.min_instances,
             max_instances: attr.max_instances,
             batch_size: attr.batch_size.max(1),
+            content_type: attr.content_type.clone(),
+            client_host: attr.client_host.clone(),
+            labels: attr.labels.clone(),
+            environments: attr.environments.clone(),
             common_data_len,
         };
 
@@ -1191,8 +1328,20 @@ impl Engine for FilesystemEngine {
     async fn create_task(
         &self,
         ssn_id: SessionID,
-        input: Option<TaskInput>,
+        attr: TaskAttributes,
     ) -> Result<Task, FlameError> {
+        let TaskAttributes {
+            input,
+            principal,
+            tags,
+            deadline,
+            group,
+            labels,
+            run_at,
+            depends_on,
+            provenance,
+        } = attr;
+
         let ssn_meta = self.read_session_metadata(&ssn_id)?;
         if ssn_meta.state != SessionState::Open as i32 {
             return Err(FlameError::InvalidState(
@@ -1227,6 +1376,98 @@ impl Engine for FilesystemEngine {
 
         meta.checksum = calculate_checksum(&meta);
 
+        // The principal is written before the metadata, so the task is never
+        // visible without it.
+        if let Some(ref principal) = principal {
+            let path = self.principal_path(&ssn_id, task_id as TaskID);
+            if let Some(parent) = path.parent() {
+                std::fs::create_dir_all(parent).map_err(|e| {
+                    FlameError::Storage(format!("Failed to create principals dir: {e}"))
+                })?;
+            }
+            let data = serde_json::to_string(principal)
+                .map_err(|e| FlameError::Storage(format!("Failed to encode principal: {e}")))?;
+            std::fs::write(&path, data)
+                .map_err(|e| FlameError::Storage(format!("Failed to write principal: {e}")))?;
+        }
+        if !tags.is_empty() {
+            let path = self.tags_path(&ssn_id, task_id as TaskID);
+            if let Some(parent) = path.parent() {
+                std::fs::create_dir_all(parent)
+                    .map_err(|e| FlameError::Storage(format!("Failed to create tags dir: {e}")))?;
+            }
+            let data = serde_json::to_string(&tags)
+                .map_err(|e| FlameError::Storage(format!("Failed to encode tags: {e}")))?;
+            std::fs::write(&path, data)
+                .map_err(|e| FlameError::Storage(format!("Failed to write tags: {e}")))?;
+        }
+        if let Some(deadline) = deadline {
+            let path = self.deadline_path(&ssn_id, task_id as TaskID);
+            if let Some(parent) = path.parent() {
+                std::fs::create_dir_all(parent).map_err(|e| {
+                    FlameError::Storage(format!("Failed to create deadlines dir: {e}"))
+                })?;
+            }
+            std::fs::write(&path, deadline.timestamp_millis().to_string())
+                .map_err(|e| FlameError::Storage(format!("Failed to write deadline: {e}")))?;
+        }
+        if let Some(run_at) = run_at {
+            let path = self.run_at_path(&ssn_id, task_id as TaskID);
+            if let Some(parent) = path.parent() {
+                std::fs::create_dir_all(parent).map_err(|e| {
+                    FlameError::Storage(format!("Failed to create run_ats dir: {e}"))
+                })?;
+            }
+            std::fs::write(&path, run_at.timestamp_millis().to_string())
+                .map_err(|e| FlameError::Storage(format!("Failed to write run_at: {e}")))?;
+        }
+        if let Some(ref group) = group {
+            let path = self.group_path(&ssn_id, task_id as TaskID);
+            if let Some(parent) = path.parent() {
+                std::fs::create_dir_all(parent).map_err(|e| {
+                    FlameError::Storage(format!("Failed to create groups dir: {e}"))
+                })?;
+            }
+            std::fs::write(&path, group)
+                .map_err(|e| FlameError::Storage(format!("Failed to write group: {e}")))?;
+        }
+        if !labels.is_empty() {
+            let path = self.labels_path(&ssn_id, task_id as TaskID);
+            if let Some(parent) = path.parent() {
+                std::fs::create_dir_all(parent).map_err(|e| {
+                    FlameError::Storage(format!("Failed to create labels dir: {e}"))
+                })?;
+            }
+            let data = serde_json::to_string(&labels)
+                .map_err(|e| FlameError::Storage(format!("Failed to encode labels: {e}")))?;
+            std::fs::write(&path, data)
+                .map_err(|e| FlameError::Storage(format!("Failed to write labels: {e}")))?;
+        }
+        if !depends_on.is_empty() {
+            let path = self.depends_on_path(&ssn_id, task_id as TaskID);
+            if let Some(parent) = path.parent() {
+                std::fs::create_dir_all(parent).map_err(|e| {
+                    FlameError::Storage(format!("Failed to create dependencies dir: {e}"))
+                })?;
+            }
+            let data = serde_json::to_string(&depends_on)
+                .map_err(|e| FlameError::Storage(format!("Failed to encode dependencies: {e}")))?;
+            std::fs::write(&path, data)
+                .map_err(|e| FlameError::Storage(format!("Failed to write dependencies: {e}")))?;
+        }
+        if let Some(ref provenance) = provenance {
+            let path = self.provenance_path(&ssn_id, task_id as TaskID);
+            if let Some(parent) = path.parent() {
+                std::fs::create_dir_all(parent).map_err(|e| {
+                    FlameError::Storage(format!("Failed to create provenances dir: {e}"))
+                })?;
+            }
+            let data = serde_json::to_string(provenance)
+                .map_err(|e| FlameError::Storage(format!("Failed to encode provenance: {e}")))?;
+            std::fs::write(&path, data)
+                .map_err(|e| FlameError::Storage(format!("Failed to write provenance: {e}")))?;
+        }
+
         self.write_task_metadata(&ssn_id, &meta)?;
 
         self.task_from_metadata(&ssn_id, &meta)
@@ -1288,6 +1529,17 @@ impl Engine for FilesystemEngine {
             meta.output_len = output.len() as u64;
         }
 
+        if let Some(ref output_ref) = task_result.output_ref {
+            let path = self.output_ref_path(&gid.ssn_id, gid.task_id);
+            if let Some(parent) = path.parent() {
+                std::fs::create_dir_all(parent).map_err(|e| {
+                    FlameError::Storage(format!("Failed to create output refs directory: {e}"))
+                })?;
+            }
+            std::fs::write(&path, output_ref)
+                .map_err(|e| FlameError::Storage(format!("Failed to write output ref: {e}")))?;
+        }
+
         meta.state = task_result.state as u8;
         meta.version += 1;
 
@@ -1331,6 +1583,7 @@ impl Engine for FilesystemEngine {
             allocatable_memory: node.allocatable.memory,
             info_arch: node.info.arch.clone(),
             info_os: node.info.os.clone(),
+            info_labels: node.info.labels.clone(),
             creation_time: now,
             last_heartbeat: now,
         };
@@ -1357,6 +1610,7 @@ impl Engine for FilesystemEngine {
                 info: NodeInfo {
                     arch: meta.info_arch,
                     os: meta.info_os,
+                    labels: meta.info_labels,
                 },
             })),
             Err(FlameError::NotFound(_)) => Ok(None),
@@ -1382,6 +1636,7 @@ impl Engine for FilesystemEngine {
             allocatable_memory: node.allocatable.memory,
             info_arch: node.info.arch.clone(),
             info_os: node.info.os.clone(),
+            info_labels: node.info.labels.clone(),
             creation_time,
             last_heartbeat: Utc::now().timestamp(),
         };
@@ -1432,6 +1687,7 @@ impl Engine for FilesystemEngine {
                         info: NodeInfo {
                             arch: meta.info_arch,
                             os: meta.info_os,
+                            labels: meta.info_labels,
                         },
                     });
                 }
@@ -1513,6 +1769,15 @@ impl Engine for FilesystemEngine {
         Ok(executor.clone())
     }
 
+    async fn update_executors(&self, executors: &[Executor]) -> Result<Vec<Executor>, FlameError> {
+        let mut updated = Vec::with_capacity(executors.len());
+        for executor in executors {
+            updated.push(self.update_executor(executor).await?);
+        }
+
+        Ok(updated)
+    }
+
     async fn update_executor_state(
         &self,
         id: &ExecutorID,
@@ -1763,7 +2028,7 @@ mod tests {
             ..attr
         };
         let app3 = engine
-            .update_application("test-app".to_string(), updated_attr)
+            .update_application("test-app".to_string(), updated_attr, Some(app2.version))
             .await
             .unwrap();
         assert_eq!(app3.description, Some("Updated description".to_string()));
@@ -1813,6 +2078,10 @@ mod tests {
             min_instances: 0,
             max_instances: None,
             batch_size: 1,
+            content_type: None,
+            client_host: None,
+            labels: vec![],
+            environments: HashMap::new(),
         };
 
         let session = engine.create_session(ssn_attr).await.unwrap();
@@ -1877,13 +2146,23 @@ mod tests {
             min_instances: 0,
             max_instances: None,
             batch_size: 1,
+            content_type: None,
+            client_host: None,
+            labels: vec![],
+            environments: HashMap::new(),
         };
         engine.create_session(ssn_attr).await.unwrap();
 
         // Create task with input
         let input = Bytes::from("test input data");
         let task = engine
-            .create_task("test-session".to_string(), Some(input.clone()))
+            .create_task(
+                "test-session".to_string(),
+                TaskAttributes {
+                    input: Some(input.clone()),
+                    ..Default::default()
+                },
+            )
             .await
             .unwrap();
         assert_eq!(task.id, 1);
@@ -1911,6 +2190,7 @@ mod tests {
             state: TaskState::Succeed,
             output: Some(output.clone()),
             message: None,
+            output_ref: None,
         };
         let task4 = engine
             .update_task_result(gid.clone(), result)
@@ -1925,7 +2205,7 @@ mod tests {
 
         // Create another task
         let task5 = engine
-            .create_task("test-session".to_string(), None)
+            .create_task("test-session".to_string(), TaskAttributes::default())
             .await
             .unwrap();
         assert_eq!(task5.id, 2);
@@ -1940,6 +2220,30 @@ mod tests {
             .await
             .unwrap();
 
+        // Complete the third task with the reference of its output
+        engine
+            .create_task("test-session".to_string(), TaskAttributes::default())
+            .await
+            .unwrap();
+        let gid3 = TaskGID {
+            ssn_id: "test-session".to_string(),
+            task_id: 3,
+        };
+        let output_ref = "file://localhost/tmp/flame/results/test-session/3".to_string();
+        let result = TaskResult {
+            state: TaskState::Succeed,
+            output: None,
+            message: None,
+            output_ref: Some(output_ref.clone()),
+        };
+        engine
+            .update_task_result(gid3.clone(), result)
+            .await
+            .unwrap();
+        let task6 = engine.get_task(gid3).await.unwrap();
+        assert_eq!(task6.output, None);
+        assert_eq!(task6.output_ref, Some(output_ref));
+
         // Now we can close the session
         let closed = engine
             .close_session("test-session".to_string())
@@ -2012,6 +2316,10 @@ mod tests {
             min_instances: 0,
             max_instances: None,
             batch_size: 1,
+            content_type: None,
+            client_host: None,
+            labels: vec![],
+            environments: HashMap::new(),
         };
 
         engine.create_session(ssn_attr.clone()).await.unwrap();
@@ -2054,17 +2362,21 @@ mod tests {
             min_instances: 0,
             max_instances: None,
             batch_size: 1,
+            content_type: None,
+            client_host: None,
+            labels: vec![],
+            environments: HashMap::new(),
         };
         engine.create_session(ssn_attr).await.unwrap();
 
         let task1 = engine
-            .create_task("test-session".to_string(), None)
+            .create_task("test-session".to_string(), TaskAttributes::default())
             .await
             .unwrap();
         assert_eq!(task1.state, TaskState::Pending);
 
         let task2 = engine
-            .create_task("test-session".to_string(), None)
+            .create_task("test-session".to_string(), TaskAttributes::default())
             .await
             .unwrap();
         assert_eq!(task2.state, TaskState::Pending);
@@ -2113,11 +2425,15 @@ mod tests {
             min_instances: 0,
             max_instances: None,
             batch_size: 1,
+            content_type: None,
+            client_host: None,
+            labels: vec![],
+            environments: HashMap::new(),
         };
         engine.create_session(ssn_attr).await.unwrap();
 
         let task = engine
-            .create_task("test-session".to_string(), None)
+            .create_task("test-session".to_string(), TaskAttributes::default())
             .await
             .unwrap();
 
@@ -2148,6 +2464,7 @@ mod tests {
             info: NodeInfo {
                 arch: "x86_64".to_string(),
                 os: "linux".to_string(),
+                ..Default::default()
             },
         };
 
@@ -2191,6 +2508,7 @@ mod tests {
             info: NodeInfo {
                 arch: "x86_64".to_string(),
                 os: "linux".to_string(),
+                ..Default::default()
             },
         };
         engine.create_node(&node).await.unwrap();
@@ -2255,6 +2573,7 @@ mod tests {
             info: NodeInfo {
                 arch: "x86_64".to_string(),
                 os: "linux".to_string(),
+                ..Default::default()
             },
         };
         engine.create_node(&node).await.unwrap();
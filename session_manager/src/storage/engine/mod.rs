@@ -19,15 +19,18 @@ use crate::model::Executor;
 use crate::FlameError;
 use common::apis::{
     Application, ApplicationAttributes, ApplicationID, CommonData, Event, ExecutorID,
-    ExecutorState, Node, Session, SessionAttributes, SessionID, Task, TaskGID, TaskInput,
+    ExecutorState, Node, Session, SessionAttributes, SessionID, Task, TaskAttributes, TaskGID,
     TaskOutput, TaskResult, TaskState,
 };
 
+mod encrypted;
 mod filesystem;
 mod none;
 mod sqlite;
 pub mod types;
 
+pub use encrypted::EncryptedEngine;
+
 #[cfg(test)]
 pub use sqlite::SqliteEngine;
 
@@ -42,10 +45,13 @@ pub trait Engine: Send + Sync + 'static {
         attr: ApplicationAttributes,
     ) -> Result<Application, FlameError>;
     async fn unregister_application(&self, id: String) -> Result<(), FlameError>;
+    /// Update the application; if `version` is set, the application is updated
+    /// only if its current version matches, otherwise VersionMismatch.
     async fn update_application(
         &self,
         id: String,
         attr: ApplicationAttributes,
+        version: Option<u32>,
     ) -> Result<Application, FlameError>;
     async fn get_application(&self, id: ApplicationID) -> Result<Application, FlameError>;
     async fn find_application(&self) -> Result<Vec<Application>, FlameError>;
@@ -66,7 +72,7 @@ pub trait Engine: Send + Sync + 'static {
     async fn create_task(
         &self,
         ssn_id: SessionID,
-        task_input: Option<TaskInput>,
+        attr: TaskAttributes,
     ) -> Result<Task, FlameError>;
 
     async fn get_task(&self, gid: TaskGID) -> Result<Task, FlameError>;
@@ -101,6 +107,9 @@ pub trait Engine: Send + Sync + 'static {
     async fn create_executor(&self, executor: &Executor) -> Result<Executor, FlameError>;
     async fn get_executor(&self, id: &ExecutorID) -> Result<Option<Executor>, FlameError>;
     async fn update_executor(&self, executor: &Executor) -> Result<Executor, FlameError>;
+    /// Update the executors at once, e.g. the executors bound in a scheduling
+    /// cycle; the engine may write them in one transaction.
+    async fn update_executors(&self, executors: &[Executor]) -> Result<Vec<Executor>, FlameError>;
     async fn update_executor_state(
         &self,
         id: &ExecutorID,
@@ -110,6 +119,16 @@ pub trait Engine: Send + Sync + 'static {
     async fn find_executors(&self, node: Option<&str>) -> Result<Vec<Executor>, FlameError>;
 }
 
+/// Check the current version of the object against the expected one, if any.
+pub fn check_version(object: &str, current: u32, expected: Option<u32>) -> Result<(), FlameError> {
+    match expected {
+        Some(expected) if expected != current => Err(FlameError::VersionMismatch(format!(
+            "{object} was modified: expected version {expected}, current version {current}"
+        ))),
+        _ => Ok(()),
+    }
+}
+
 /// Connect to a storage engine based on the URL scheme.
 ///
 /// Supported URL schemes:
@@ -38,11 +38,11 @@ use crate::model::Executor;
 use crate::FlameError;
 use common::apis::{
     Application, ApplicationAttributes, ApplicationID, ExecutorID, ExecutorState, Node, Session,
-    SessionAttributes, SessionID, SessionState, SessionStatus, Task, TaskGID, TaskID, TaskInput,
-    TaskOutput, TaskResult, TaskState,
+    SessionAttributes, SessionID, SessionState, SessionStatus, Task, TaskAttributes, TaskGID,
+    TaskID, TaskOutput, TaskResult, TaskState,
 };
 
-use super::{Engine, EnginePtr};
+use super::{check_version, Engine, EnginePtr};
 
 /// None Storage Engine - stores nothing, only allocates task IDs.
 ///
@@ -134,11 +134,13 @@ impl Engine for NoneEngine {
         &self,
         id: String,
         attr: ApplicationAttributes,
+        version: Option<u32>,
     ) -> Result<Application, FlameError> {
         let mut apps = lock_ptr!(self.applications)?;
         let app = apps
             .get(&id)
             .ok_or_else(|| FlameError::NotFound(format!("application <{}>", id)))?;
+        check_version(&format!("application <{id}>"), app.version, version)?;
 
         let updated = Application {
             name: id.clone(),
@@ -188,6 +190,10 @@ impl Engine for NoneEngine {
             min_instances: attr.min_instances,
             max_instances: attr.max_instances,
             batch_size: attr.batch_size.max(1),
+            content_type: attr.content_type,
+            client_host: attr.client_host,
+            labels: attr.labels,
+            environments: attr.environments,
             status: SessionStatus {
                 state: SessionState::Open,
             },
@@ -233,7 +239,7 @@ impl Engine for NoneEngine {
     async fn create_task(
         &self,
         ssn_id: SessionID,
-        task_input: Option<TaskInput>,
+        attr: TaskAttributes,
     ) -> Result<Task, FlameError> {
         let task_id = self.next_task_id(&ssn_id)?;
 
@@ -244,8 +250,17 @@ impl Engine for NoneEngine {
             state: TaskState::Pending,
             creation_time: Utc::now(),
             completion_time: None,
-            input: task_input,
+            input: attr.input,
             output: None,
+            output_ref: None,
+            principal: attr.principal,
+            tags: attr.tags,
+            deadline: attr.deadline,
+            group: attr.group,
+            labels: attr.labels,
+            run_at: attr.run_at,
+            depends_on: attr.depends_on,
+            provenance: attr.provenance,
             events: vec![],
         })
     }
@@ -319,6 +334,10 @@ impl Engine for NoneEngine {
         Ok(executor.clone())
     }
 
+    async fn update_executors(&self, executors: &[Executor]) -> Result<Vec<Executor>, FlameError> {
+        Ok(executors.to_vec())
+    }
+
     async fn update_executor_state(
         &self,
         id: &ExecutorID,
@@ -352,6 +371,10 @@ mod tests {
             min_instances: 1,
             max_instances: None,
             batch_size: 1,
+            content_type: None,
+            client_host: None,
+            labels: vec![],
+            environments: HashMap::new(),
         };
 
         let session = engine.create_session(attr).await.unwrap();
@@ -389,23 +412,27 @@ mod tests {
             min_instances: 1,
             max_instances: None,
             batch_size: 1,
+            content_type: None,
+            client_host: None,
+            labels: vec![],
+            environments: HashMap::new(),
         };
         engine.create_session(attr).await.unwrap();
 
         let task1 = engine
-            .create_task("test-session".to_string(), None)
+            .create_task("test-session".to_string(), TaskAttributes::default())
             .await
             .unwrap();
         assert_eq!(task1.id, 1);
 
         let task2 = engine
-            .create_task("test-session".to_string(), None)
+            .create_task("test-session".to_string(), TaskAttributes::default())
             .await
             .unwrap();
         assert_eq!(task2.id, 2);
 
         let task3 = engine
-            .create_task("test-session".to_string(), None)
+            .create_task("test-session".to_string(), TaskAttributes::default())
             .await
             .unwrap();
         assert_eq!(task3.id, 3);
@@ -423,6 +450,10 @@ mod tests {
             min_instances: 1,
             max_instances: None,
             batch_size: 1,
+            content_type: None,
+            client_host: None,
+            labels: vec![],
+            environments: HashMap::new(),
         };
         engine.create_session(attr1).await.unwrap();
 
@@ -434,23 +465,27 @@ mod tests {
             min_instances: 1,
             max_instances: None,
             batch_size: 1,
+            content_type: None,
+            client_host: None,
+            labels: vec![],
+            environments: HashMap::new(),
         };
         engine.create_session(attr2).await.unwrap();
 
         let task1_s1 = engine
-            .create_task("session-1".to_string(), None)
+            .create_task("session-1".to_string(), TaskAttributes::default())
             .await
             .unwrap();
         assert_eq!(task1_s1.id, 1);
 
         let task1_s2 = engine
-            .create_task("session-2".to_string(), None)
+            .create_task("session-2".to_string(), TaskAttributes::default())
             .await
             .unwrap();
         assert_eq!(task1_s2.id, 1);
 
         let task2_s1 = engine
-            .create_task("session-1".to_string(), None)
+            .create_task("session-1".to_string(), TaskAttributes::default())
             .await
             .unwrap();
         assert_eq!(task2_s1.id, 2);
@@ -468,11 +503,15 @@ mod tests {
             min_instances: 1,
             max_instances: None,
             batch_size: 1,
+            content_type: None,
+            client_host: None,
+            labels: vec![],
+            environments: HashMap::new(),
         };
         engine.create_session(attr.clone()).await.unwrap();
 
         let task1 = engine
-            .create_task("test-session".to_string(), None)
+            .create_task("test-session".to_string(), TaskAttributes::default())
             .await
             .unwrap();
         assert_eq!(task1.id, 1);
@@ -483,7 +522,7 @@ mod tests {
         engine.create_session(attr).await.unwrap();
 
         let task_new = engine
-            .create_task("test-session".to_string(), None)
+            .create_task("test-session".to_string(), TaskAttributes::default())
             .await
             .unwrap();
         assert_eq!(task_new.id, 1);
@@ -33,7 +33,7 @@ use common::{
     apis::{
         Application, ApplicationAttributes, ApplicationID, ApplicationSchema, ApplicationState,
         CommonData, Event, ExecutorID, ExecutorState, Node, Session, SessionAttributes, SessionID,
-        SessionState, SessionStatus, Shim, Task, TaskGID, TaskID, TaskInput, TaskOutput,
+        SessionState, SessionStatus, Shim, Task, TaskAttributes, TaskGID, TaskID, TaskOutput,
         TaskResult, TaskState, DEFAULT_DELAY_RELEASE, DEFAULT_MAX_INSTANCES,
     },
     FlameError,
@@ -44,7 +44,7 @@ use crate::storage::engine::types::{
     AppSchemaDao, ApplicationDao, EventDao, ExecutorDao, NodeDao, SessionDao, TaskDao,
 };
 
-use crate::storage::engine::{Engine, EnginePtr};
+use crate::storage::engine::{check_version, Engine, EnginePtr};
 
 const SQLITE_SQL: &str = "migrations/sqlite";
 
@@ -196,7 +196,7 @@ impl SqliteEngine {
         attr: SessionAttributes,
     ) -> Result<Session, FlameError> {
         let common_data: Option<Vec<u8>> = attr.common_data.map(Bytes::into);
-        let sql = r#"INSERT INTO sessions (id, application, slots, common_data, creation_time, state, min_instances, max_instances)
+        let sql = r#"INSERT INTO sessions (id, application, slots, common_data, creation_time, state, min_instances, max_instances, content_type, client_host, labels, environments)
             VALUES (
                 ?,
                 (SELECT name FROM applications WHERE name=? AND state=?),
@@ -205,6 +205,10 @@ impl SqliteEngine {
                 ?,
                 ?,
                 ?,
+                ?,
+                ?,
+                ?,
+                ?,
                 ?
             )
             RETURNING *"#;
@@ -218,6 +222,10 @@ impl SqliteEngine {
             .bind(SessionState::Open as i32)
             .bind(attr.min_instances as i64)
             .bind(attr.max_instances.map(|v| v as i64))
+            .bind(attr.content_type)
+            .bind(attr.client_host)
+            .bind((!attr.labels.is_empty()).then_some(Json(attr.labels)))
+            .bind((!attr.environments.is_empty()).then_some(Json(attr.environments)))
             .fetch_one(&mut *tx)
             .await
             .map_err(|e| FlameError::Storage(e.to_string()))?;
@@ -290,6 +298,7 @@ impl Engine for SqliteEngine {
         &self,
         name: String,
         attr: ApplicationAttributes,
+        version: Option<u32>,
     ) -> Result<Application, FlameError> {
         trace_fn!("Sqlite::update_application");
 
@@ -321,10 +330,12 @@ impl Engine for SqliteEngine {
                         delay_release=?,
                         url=?,
                         version=version+1
-                    WHERE name=?
+                    WHERE name=? AND version=COALESCE(?, version)
                     RETURNING *"#;
 
-        let app: ApplicationDao = sqlx::query_as(sql)
+        // The version is compared by the update itself, so a concurrent
+        // update in between can't be overwritten.
+        let app: Option<ApplicationDao> = sqlx::query_as(sql)
             .bind(schema)
             .bind(attr.description)
             .bind(Json(attr.labels))
@@ -335,11 +346,29 @@ impl Engine for SqliteEngine {
             .bind(attr.max_instances)
             .bind(attr.delay_release.num_seconds())
             .bind(attr.url)
-            .bind(name)
-            .fetch_one(&mut *tx)
+            .bind(&name)
+            .bind(version)
+            .fetch_optional(&mut *tx)
             .await
             .map_err(|e| FlameError::Storage(format!("failed to update application: {e}")))?;
 
+        let Some(app) = app else {
+            let current: Option<u32> =
+                sqlx::query_scalar("SELECT version FROM applications WHERE name=?")
+                    .bind(&name)
+                    .fetch_optional(&mut *tx)
+                    .await
+                    .map_err(|e| {
+                        FlameError::Storage(format!("failed to get application version: {e}"))
+                    })?;
+            let current = current.ok_or(FlameError::NotFound(format!("application <{name}>")))?;
+            check_version(&format!("application <{name}>"), current, version)?;
+
+            return Err(FlameError::Storage(format!(
+                "failed to update application <{name}>"
+            )));
+        };
+
         tx.commit()
             .await
             .map_err(|e| FlameError::Storage(format!("failed to commit TX: {e}")))?;
@@ -603,8 +632,20 @@ impl Engine for SqliteEngine {
     async fn create_task(
         &self,
         ssn_id: SessionID,
-        input: Option<TaskInput>,
+        attr: TaskAttributes,
     ) -> Result<Task, FlameError> {
+        let TaskAttributes {
+            input,
+            principal,
+            tags,
+            deadline,
+            group,
+            labels,
+            run_at,
+            depends_on,
+            provenance,
+        } = attr;
+
         let mut tx = self
             .pool
             .begin()
@@ -612,12 +653,20 @@ impl Engine for SqliteEngine {
             .map_err(|e| FlameError::Storage(e.to_string()))?;
 
         let input: Option<Vec<u8>> = input.map(Bytes::into);
-        let sql = r#"INSERT INTO tasks (id, ssn_id, input, creation_time, state)
+        let sql = r#"INSERT INTO tasks (id, ssn_id, input, principal, tags, deadline, group_name, labels, run_at, depends_on, provenance, creation_time, state)
             VALUES (
                 COALESCE((SELECT MAX(id)+1 FROM tasks WHERE ssn_id=?), 1),
                 (SELECT id FROM sessions WHERE id=? AND state=?),
                 ?,
                 ?,
+                ?,
+                ?,
+                ?,
+                ?,
+                ?,
+                ?,
+                ?,
+                ?,
                 ?)
             RETURNING *"#;
         let task: TaskDao = sqlx::query_as(sql)
@@ -625,6 +674,14 @@ impl Engine for SqliteEngine {
             .bind(ssn_id)
             .bind(SessionState::Open as i32)
             .bind(input)
+            .bind(principal.map(Json))
+            .bind((!tags.is_empty()).then_some(Json(tags)))
+            .bind(deadline.map(|d| d.timestamp_millis()))
+            .bind(group)
+            .bind((!labels.is_empty()).then_some(Json(labels)))
+            .bind(run_at.map(|t| t.timestamp_millis()))
+            .bind((!depends_on.is_empty()).then_some(Json(depends_on)))
+            .bind(provenance.map(Json))
             .bind(Utc::now().timestamp())
             .bind(TaskState::Pending as i32)
             .fetch_one(&mut *tx)
@@ -765,12 +822,13 @@ impl Engine for SqliteEngine {
             }
         };
 
-        let sql = r#"UPDATE tasks SET state=?, completion_time=?, output=?, version=version+1 WHERE id=? AND ssn_id=? RETURNING *"#;
+        let sql = r#"UPDATE tasks SET state=?, completion_time=?, output=?, output_ref=?, version=version+1 WHERE id=? AND ssn_id=? RETURNING *"#;
 
         let task: TaskDao = sqlx::query_as(sql)
             .bind::<i32>(task_result.state.into())
             .bind(completion_time)
             .bind::<Option<Vec<u8>>>(task_result.output.map(Bytes::into))
+            .bind(task_result.output_ref)
             .bind(gid.task_id)
             .bind(gid.ssn_id)
             .fetch_one(&mut *tx)
@@ -825,8 +883,8 @@ impl Engine for SqliteEngine {
         let now = Utc::now().timestamp();
         let sql = r#"INSERT INTO nodes 
             (name, state, capacity_cpu, capacity_memory, allocatable_cpu, allocatable_memory, 
-             info_arch, info_os, creation_time, last_heartbeat)
-            VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+             info_arch, info_os, info_labels, creation_time, last_heartbeat)
+            VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
             RETURNING *"#;
 
         let dao: NodeDao = sqlx::query_as(sql)
@@ -838,6 +896,7 @@ impl Engine for SqliteEngine {
             .bind(node.allocatable.memory as i64)
             .bind(&node.info.arch)
             .bind(&node.info.os)
+            .bind(Json(&node.info.labels))
             .bind(now)
             .bind(now)
             .fetch_one(&mut *tx)
@@ -887,7 +946,7 @@ impl Engine for SqliteEngine {
         let sql = r#"UPDATE nodes 
             SET state=?, capacity_cpu=?, capacity_memory=?, 
                 allocatable_cpu=?, allocatable_memory=?,
-                info_arch=?, info_os=?, last_heartbeat=?
+                info_arch=?, info_os=?, info_labels=?, last_heartbeat=?
             WHERE name=?
             RETURNING *"#;
 
@@ -899,6 +958,7 @@ impl Engine for SqliteEngine {
             .bind(node.allocatable.memory as i64)
             .bind(&node.info.arch)
             .bind(&node.info.os)
+            .bind(Json(&node.info.labels))
             .bind(Utc::now().timestamp())
             .bind(&node.name)
             .fetch_one(&mut *tx)
@@ -1025,6 +1085,17 @@ impl Engine for SqliteEngine {
     async fn update_executor(&self, executor: &Executor) -> Result<Executor, FlameError> {
         trace_fn!("Sqlite::update_executor");
 
+        let mut executors = self
+            .update_executors(std::slice::from_ref(executor))
+            .await?;
+        executors
+            .pop()
+            .ok_or(FlameError::Storage("failed to update executor".to_string()))
+    }
+
+    async fn update_executors(&self, executors: &[Executor]) -> Result<Vec<Executor>, FlameError> {
+        trace_fn!("Sqlite::update_executors");
+
         let mut tx = self
             .pool
             .begin()
@@ -1037,25 +1108,29 @@ impl Engine for SqliteEngine {
             WHERE id=?
             RETURNING *"#;
 
-        let dao: ExecutorDao = sqlx::query_as(sql)
-            .bind(&executor.node)
-            .bind(executor.resreq.cpu as i64)
-            .bind(executor.resreq.memory as i64)
-            .bind(executor.slots as i64)
-            .bind(i32::from(executor.shim))
-            .bind(executor.task_id)
-            .bind(&executor.ssn_id)
-            .bind(i32::from(executor.state))
-            .bind(&executor.id)
-            .fetch_one(&mut *tx)
-            .await
-            .map_err(|e| FlameError::Storage(format!("failed to update executor: {e}")))?;
+        let mut updated = Vec::with_capacity(executors.len());
+        for executor in executors {
+            let dao: ExecutorDao = sqlx::query_as(sql)
+                .bind(&executor.node)
+                .bind(executor.resreq.cpu as i64)
+                .bind(executor.resreq.memory as i64)
+                .bind(executor.slots as i64)
+                .bind(i32::from(executor.shim))
+                .bind(executor.task_id)
+                .bind(&executor.ssn_id)
+                .bind(i32::from(executor.state))
+                .bind(&executor.id)
+                .fetch_one(&mut *tx)
+                .await
+                .map_err(|e| FlameError::Storage(format!("failed to update executor: {e}")))?;
+            updated.push(dao.try_into()?);
+        }
 
         tx.commit()
             .await
             .map_err(|e| FlameError::Storage(e.to_string()))?;
 
-        dao.try_into()
+        Ok(updated)
     }
 
     async fn update_executor_state(
@@ -1170,12 +1245,17 @@ mod tests {
             min_instances: 0,
             max_instances: None,
             batch_size: 1,
+            content_type: None,
+            client_host: None,
+            labels: vec![],
+            environments: HashMap::new(),
         }))?;
         assert_eq!(ssn_1.id, ssn_1_id);
         assert_eq!(ssn_1.application, "flmexec");
         assert_eq!(ssn_1.status.state, SessionState::Open);
 
-        let task_1_1 = tokio_test::block_on(storage.create_task(ssn_1.id.clone(), None))?;
+        let task_1_1 =
+            tokio_test::block_on(storage.create_task(ssn_1.id.clone(), TaskAttributes::default()))?;
         assert_eq!(task_1_1.id, 1);
         let tasks = tokio_test::block_on(storage.find_tasks(ssn_1.id.clone()))?;
         assert_eq!(tasks.len(), 1);
@@ -1230,8 +1310,10 @@ mod tests {
                 schema: None,
                 url: None,
             },
+            Some(app_1.version),
         ))?;
         assert_eq!(app_2.name, "flmexec");
+        assert_eq!(app_2.version, app_1.version + 1);
         assert_eq!(
             app_2.description,
             Some("This is my agent for testing.".to_string())
@@ -1251,6 +1333,21 @@ mod tests {
         assert_eq!(app_2.delay_release, Duration::seconds(0));
         assert!(app_2.schema.is_none());
 
+        // The update with the stale version is rejected.
+        let attr = common::default_applications()
+            .remove("flmexec")
+            .ok_or(FlameError::NotFound("flmexec".to_string()))?;
+        let res = tokio_test::block_on(storage.update_application(
+            "flmexec".to_string(),
+            attr,
+            Some(app_1.version),
+        ));
+        assert!(matches!(res, Err(FlameError::VersionMismatch(_))));
+
+        let app_3 = tokio_test::block_on(storage.get_application("flmexec".to_string()))?;
+        assert_eq!(app_3.version, app_2.version);
+        assert_eq!(app_3.command, Some("run-agent".to_string()));
+
         Ok(())
     }
 
@@ -1273,12 +1370,17 @@ mod tests {
             min_instances: 0,
             max_instances: None,
             batch_size: 1,
+            content_type: None,
+            client_host: None,
+            labels: vec![],
+            environments: HashMap::new(),
         }))?;
         assert_eq!(ssn_1.id, ssn_1_id);
         assert_eq!(ssn_1.application, "flmexec");
         assert_eq!(ssn_1.status.state, SessionState::Open);
 
-        let task_1_1 = tokio_test::block_on(storage.create_task(ssn_1.id, None))?;
+        let task_1_1 =
+            tokio_test::block_on(storage.create_task(ssn_1.id, TaskAttributes::default()))?;
         assert_eq!(task_1_1.id, 1);
         let res = tokio_test::block_on(storage.unregister_application("flmexec".to_string()));
         assert!(res.is_err());
@@ -1340,6 +1442,7 @@ mod tests {
                         input: Some(string_schema.to_string()),
                         output: Some(string_schema.to_string()),
                         common_data: None,
+                        content_types: vec![],
                     }),
                     url: None,
                 },
@@ -1537,6 +1640,7 @@ mod tests {
                 schema: None,
                 url: Some(test_url.clone()),
             },
+            None,
         ))?;
 
         // Verify update including URL
@@ -1579,16 +1683,22 @@ mod tests {
             min_instances: 0,
             max_instances: None,
             batch_size: 1,
+            content_type: None,
+            client_host: None,
+            labels: vec![],
+            environments: HashMap::new(),
         }))?;
 
         assert_eq!(ssn_1.id, ssn_1_id);
         assert_eq!(ssn_1.application, "flmexec");
         assert_eq!(ssn_1.status.state, SessionState::Open);
 
-        let task_1_1 = tokio_test::block_on(storage.create_task(ssn_1.id.clone(), None))?;
+        let task_1_1 =
+            tokio_test::block_on(storage.create_task(ssn_1.id.clone(), TaskAttributes::default()))?;
         assert_eq!(task_1_1.id, 1);
 
-        let task_1_2 = tokio_test::block_on(storage.create_task(ssn_1.id.clone(), None))?;
+        let task_1_2 =
+            tokio_test::block_on(storage.create_task(ssn_1.id.clone(), TaskAttributes::default()))?;
         assert_eq!(task_1_2.id, 2);
 
         let task_list = tokio_test::block_on(storage.find_tasks(ssn_1.id))?;
@@ -1631,16 +1741,22 @@ mod tests {
             min_instances: 0,
             max_instances: None,
             batch_size: 1,
+            content_type: None,
+            client_host: None,
+            labels: vec![],
+            environments: HashMap::new(),
         }))?;
 
         assert_eq!(ssn_1.id, ssn_1_id);
         assert_eq!(ssn_1.application, "flmexec");
         assert_eq!(ssn_1.status.state, SessionState::Open);
 
-        let task_1_1 = tokio_test::block_on(storage.create_task(ssn_1.id.clone(), None))?;
+        let task_1_1 =
+            tokio_test::block_on(storage.create_task(ssn_1.id.clone(), TaskAttributes::default()))?;
         assert_eq!(task_1_1.id, 1);
 
-        let task_1_2 = tokio_test::block_on(storage.create_task(ssn_1.id.clone(), None))?;
+        let task_1_2 =
+            tokio_test::block_on(storage.create_task(ssn_1.id.clone(), TaskAttributes::default()))?;
         assert_eq!(task_1_2.id, 2);
 
         let task_1_1 = tokio_test::block_on(storage.update_task_state(
@@ -1666,16 +1782,22 @@ mod tests {
             min_instances: 0,
             max_instances: None,
             batch_size: 1,
+            content_type: None,
+            client_host: None,
+            labels: vec![],
+            environments: HashMap::new(),
         }))?;
 
         assert_eq!(ssn_2.id, ssn_2_id);
         assert_eq!(ssn_2.application, "flmping");
         assert_eq!(ssn_2.status.state, SessionState::Open);
 
-        let task_2_1 = tokio_test::block_on(storage.create_task(ssn_2.id.clone(), None))?;
+        let task_2_1 =
+            tokio_test::block_on(storage.create_task(ssn_2.id.clone(), TaskAttributes::default()))?;
         assert_eq!(task_2_1.id, 1);
 
-        let task_2_2 = tokio_test::block_on(storage.create_task(ssn_2.id.clone(), None))?;
+        let task_2_2 =
+            tokio_test::block_on(storage.create_task(ssn_2.id.clone(), TaskAttributes::default()))?;
         assert_eq!(task_2_2.id, 2);
 
         let task_2_1 = tokio_test::block_on(storage.update_task_state(
@@ -1719,16 +1841,22 @@ mod tests {
             min_instances: 0,
             max_instances: None,
             batch_size: 1,
+            content_type: None,
+            client_host: None,
+            labels: vec![],
+            environments: HashMap::new(),
         }))?;
 
         assert_eq!(ssn_1.id, ssn_1_id);
         assert_eq!(ssn_1.application, "flmexec");
         assert_eq!(ssn_1.status.state, SessionState::Open);
 
-        let task_1_1 = tokio_test::block_on(storage.create_task(ssn_1.id.clone(), None))?;
+        let task_1_1 =
+            tokio_test::block_on(storage.create_task(ssn_1.id.clone(), TaskAttributes::default()))?;
         assert_eq!(task_1_1.id, 1);
 
-        let task_1_2 = tokio_test::block_on(storage.create_task(ssn_1.id, None))?;
+        let task_1_2 =
+            tokio_test::block_on(storage.create_task(ssn_1.id, TaskAttributes::default()))?;
         assert_eq!(task_1_2.id, 2);
 
         let ssn_1 = tokio_test::block_on(storage.close_session(ssn_1_id.clone()))?;
@@ -1759,11 +1887,16 @@ mod tests {
             min_instances: 0,
             max_instances: None,
             batch_size: 1,
+            content_type: None,
+            client_host: None,
+            labels: vec![],
+            environments: HashMap::new(),
         }))?;
 
         assert_eq!(ssn_1.status.state, SessionState::Open);
 
-        let task_1_1 = tokio_test::block_on(storage.create_task(ssn_1.id.clone(), None))?;
+        let task_1_1 =
+            tokio_test::block_on(storage.create_task(ssn_1.id.clone(), TaskAttributes::default()))?;
         assert_eq!(task_1_1.state, TaskState::Pending);
 
         tokio_test::block_on(storage.update_task_state(task_1_1.gid(), TaskState::Running, None))?;
@@ -1791,13 +1924,18 @@ mod tests {
             min_instances: 0,
             max_instances: None,
             batch_size: 1,
+            content_type: None,
+            client_host: None,
+            labels: vec![],
+            environments: HashMap::new(),
         }))?;
 
         assert_eq!(ssn_1.id, ssn_1_id);
         assert_eq!(ssn_1.application, "flmexec");
         assert_eq!(ssn_1.status.state, SessionState::Open);
 
-        let task_1_1 = tokio_test::block_on(storage.create_task(ssn_1.id, None))?;
+        let task_1_1 =
+            tokio_test::block_on(storage.create_task(ssn_1.id, TaskAttributes::default()))?;
         assert_eq!(task_1_1.id, 1);
 
         let task_1_1 = tokio_test::block_on(storage.update_task_state(
@@ -1810,7 +1948,7 @@ mod tests {
         let ssn_1 = tokio_test::block_on(storage.close_session(ssn_1_id.clone()))?;
         assert_eq!(ssn_1.status.state, SessionState::Closed);
 
-        let res = tokio_test::block_on(storage.create_task(ssn_1.id, None));
+        let res = tokio_test::block_on(storage.create_task(ssn_1.id, TaskAttributes::default()));
         assert!(res.is_err());
 
         Ok(())
@@ -1832,13 +1970,18 @@ mod tests {
             min_instances: 0,
             max_instances: None,
             batch_size: 1,
+            content_type: None,
+            client_host: None,
+            labels: vec![],
+            environments: HashMap::new(),
         }))?;
 
         assert_eq!(ssn_1.id, ssn_1_id);
         assert_eq!(ssn_1.application, "flmexec");
         assert_eq!(ssn_1.status.state, SessionState::Open);
 
-        let task_1_1 = tokio_test::block_on(storage.create_task(ssn_1.id.clone(), None))?;
+        let task_1_1 =
+            tokio_test::block_on(storage.create_task(ssn_1.id.clone(), TaskAttributes::default()))?;
         assert_eq!(task_1_1.id, 1);
 
         // It should be failed because the session is open and there are open tasks
@@ -20,7 +20,7 @@ use crate::FlameError;
 use bytes::Bytes;
 use common::apis::{
     Application, ApplicationSchema, ApplicationState, ExecutorState, Node, NodeInfo, NodeState,
-    ResourceRequirement, Session, SessionStatus, Shim, Task,
+    Principal, ResourceRequirement, Session, SessionStatus, Shim, Task, TaskProvenance,
 };
 use common::apis::{ApplicationID, Event, ExecutorID, SessionID, TaskID};
 
@@ -40,6 +40,8 @@ pub struct AppSchemaDao {
     pub input: Option<String>,
     pub output: Option<String>,
     pub common_data: Option<String>,
+    #[serde(default)]
+    pub content_types: Vec<String>,
 }
 
 #[derive(Clone, FromRow, Debug)]
@@ -77,6 +79,10 @@ pub struct SessionDao {
     pub min_instances: i64,
     pub max_instances: Option<i64>,
     pub batch_size: i64,
+    pub content_type: Option<String>,
+    pub client_host: Option<String>,
+    pub labels: Option<Json<Vec<String>>>,
+    pub environments: Option<Json<HashMap<String, String>>>,
 }
 
 #[derive(Clone, FromRow, Debug)]
@@ -86,6 +92,16 @@ pub struct TaskDao {
     pub version: u32,
     pub input: Option<Vec<u8>>,
     pub output: Option<Vec<u8>>,
+    pub output_ref: Option<String>,
+    pub principal: Option<Json<Principal>>,
+    pub tags: Option<Json<Vec<String>>>,
+    pub deadline: Option<i64>,
+    /// The group of the task; `group` is a keyword of SQL.
+    pub group_name: Option<String>,
+    pub labels: Option<Json<Vec<String>>>,
+    pub run_at: Option<i64>,
+    pub depends_on: Option<Json<Vec<TaskID>>>,
+    pub provenance: Option<Json<TaskProvenance>>,
 
     pub creation_time: i64,
     pub completion_time: Option<i64>,
@@ -109,6 +125,7 @@ pub struct NodeDao {
     // Node info
     pub info_arch: String,
     pub info_os: String,
+    pub info_labels: Option<Json<HashMap<String, String>>>,
 
     pub creation_time: i64,
     pub last_heartbeat: i64,
@@ -161,6 +178,10 @@ impl TryFrom<&SessionDao> for Session {
             min_instances: ssn.min_instances as u32,
             max_instances: ssn.max_instances.map(|v| v as u32),
             batch_size: ssn.batch_size.max(1) as u32,
+            content_type: ssn.content_type.clone(),
+            client_host: ssn.client_host.clone(),
+            labels: ssn.labels.clone().map(|l| l.0).unwrap_or_default(),
+            environments: ssn.environments.clone().map(|e| e.0).unwrap_or_default(),
         })
     }
 }
@@ -183,6 +204,17 @@ impl TryFrom<&TaskDao> for Task {
             version: task.version,
             input: task.input.clone().map(Bytes::from),
             output: task.output.clone().map(Bytes::from),
+            output_ref: task.output_ref.clone(),
+            principal: task.principal.clone().map(|p| p.0),
+            tags: task.tags.clone().map(|t| t.0).unwrap_or_default(),
+            deadline: task
+                .deadline
+                .and_then(DateTime::<Utc>::from_timestamp_millis),
+            group: task.group_name.clone(),
+            labels: task.labels.clone().map(|l| l.0).unwrap_or_default(),
+            run_at: task.run_at.and_then(DateTime::<Utc>::from_timestamp_millis),
+            depends_on: task.depends_on.clone().map(|d| d.0).unwrap_or_default(),
+            provenance: task.provenance.clone().map(|p| p.0),
 
             creation_time: DateTime::<Utc>::from_timestamp(task.creation_time, 0)
                 .ok_or(FlameError::Storage("invalid creation time".to_string()))?,
@@ -256,6 +288,7 @@ impl From<ApplicationSchema> for AppSchemaDao {
             input: schema.input,
             output: schema.output,
             common_data: schema.common_data,
+            content_types: schema.content_types,
         }
     }
 }
@@ -266,6 +299,7 @@ impl From<AppSchemaDao> for ApplicationSchema {
             input: schema.input,
             output: schema.output,
             common_data: schema.common_data,
+            content_types: schema.content_types,
         }
     }
 }
@@ -303,6 +337,11 @@ impl TryFrom<&NodeDao> for Node {
             info: NodeInfo {
                 arch: dao.info_arch.clone(),
                 os: dao.info_os.clone(),
+                labels: dao
+                    .info_labels
+                    .clone()
+                    .map(|labels| labels.0)
+                    .unwrap_or_default(),
             },
         })
     }
@@ -327,6 +366,7 @@ impl From<&Node> for NodeDao {
             allocatable_memory: node.allocatable.memory as i64,
             info_arch: node.info.arch.clone(),
             info_os: node.info.os.clone(),
+            info_labels: Some(Json(node.info.labels.clone())),
             creation_time: Utc::now().timestamp(),
             last_heartbeat: Utc::now().timestamp(),
         }
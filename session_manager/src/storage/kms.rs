@@ -0,0 +1,351 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//! The keys of the tenants to encrypt their persisted task data, i.e. the
+//! inputs and outputs, by AES-256-GCM; each tenant has its own keys, so the
+//! backup of the storage exposes no data without the keys of its tenant.
+//!
+//! The keys are versioned: the data is encrypted by the current key of its
+//! tenant, and the version is kept with the data, so the data written before
+//! a rotation is still decrypted by the previous keys. The data is not
+//! re-encrypted by the rotation.
+//!
+//! The keys are provided by a KMS, e.g. `file:///var/lib/flame/keys` keeps the
+//! keys of a tenant in `<dir>/<tenant>/<version>.key`, which must not be
+//! backed up with the storage.
+
+use std::collections::HashMap;
+use std::fs;
+use std::path::PathBuf;
+use std::sync::{Arc, Mutex};
+
+use async_trait::async_trait;
+use bytes::Bytes;
+// The same AEAD by the FIPS-validated module of AWS-LC with the `fips`
+// feature, whose API is the one of ring.
+#[cfg(feature = "fips")]
+use aws_lc_rs as aead_provider;
+#[cfg(not(feature = "fips"))]
+use ring as aead_provider;
+
+use aead_provider::aead::{Aad, LessSafeKey, Nonce, UnboundKey, AES_256_GCM, NONCE_LEN};
+use aead_provider::rand::{SecureRandom, SystemRandom};
+
+use common::ctx::FlameEncryption;
+use common::FlameError;
+use stdng::lock_ptr;
+
+/// The prefix of the encrypted data; the data without it is read as is, e.g.
+/// written before the encryption was enabled.
+const ENVELOPE_MAGIC: &[u8] = b"FLMENC1";
+const KEY_LEN: usize = 32;
+
+pub type KeyProviderPtr = Arc<dyn KeyProvider>;
+
+/// A version of the key of a tenant.
+#[derive(Clone)]
+pub struct DataKey {
+    pub version: u32,
+    material: [u8; KEY_LEN],
+}
+
+impl DataKey {
+    pub fn new(version: u32, material: [u8; KEY_LEN]) -> Self {
+        Self { version, material }
+    }
+}
+
+/// The KMS of the keys of the tenants.
+#[async_trait]
+pub trait KeyProvider: Send + Sync + 'static {
+    /// The current key of the tenant; it's created for the new tenant.
+    async fn current_key(&self, tenant: &str) -> Result<DataKey, FlameError>;
+    /// The key of the tenant by its version, e.g. of the data written before
+    /// a rotation.
+    async fn get_key(&self, tenant: &str, version: u32) -> Result<DataKey, FlameError>;
+    /// Create a new version of the key of the tenant for the data written
+    /// after; the previous versions are kept for the data written before.
+    async fn rotate_key(&self, tenant: &str) -> Result<u32, FlameError>;
+}
+
+pub fn new_ptr(conf: &FlameEncryption) -> Result<KeyProviderPtr, FlameError> {
+    match conf.kms.strip_prefix("file://") {
+        Some(root) => Ok(Arc::new(FileKeyProvider::new(root)?)),
+        None => Err(FlameError::InvalidConfig(format!(
+            "unsupported encryption.kms <{}>",
+            conf.kms
+        ))),
+    }
+}
+
+/// Encrypt the data of the tenant by its current key; the data is bound to
+/// the scope, e.g. the session, so it can not be moved to another one.
+pub async fn seal(
+    kms: &dyn KeyProvider,
+    tenant: &str,
+    scope: &str,
+    data: &[u8],
+) -> Result<Bytes, FlameError> {
+    let key = kms.current_key(tenant).await?;
+    let mut nonce = [0u8; NONCE_LEN];
+    SystemRandom::new()
+        .fill(&mut nonce)
+        .map_err(|_| FlameError::Internal("failed to generate the nonce".to_string()))?;
+
+    let mut sealed = data.to_vec();
+    aead_key(&key)?
+        .seal_in_place_append_tag(
+            Nonce::assume_unique_for_key(nonce),
+            Aad::from(aad(tenant, scope)),
+            &mut sealed,
+        )
+        .map_err(|_| FlameError::Internal(format!("failed to encrypt the data of <{tenant}>")))?;
+
+    let mut envelope =
+        Vec::with_capacity(ENVELOPE_MAGIC.len() + 1 + tenant.len() + 4 + NONCE_LEN + sealed.len());
+    envelope.extend_from_slice(ENVELOPE_MAGIC);
+    envelope.push(tenant.len() as u8);
+    envelope.extend_from_slice(tenant.as_bytes());
+    envelope.extend_from_slice(&key.version.to_be_bytes());
+    envelope.extend_from_slice(&nonce);
+    envelope.extend_from_slice(&sealed);
+
+    Ok(Bytes::from(envelope))
+}
+
+/// Decrypt the data of the tenant in the scope; the data of another tenant,
+/// or another scope, is rejected.
+pub async fn open(
+    kms: &dyn KeyProvider,
+    tenant: &str,
+    scope: &str,
+    data: Bytes,
+) -> Result<Bytes, FlameError> {
+    let Some(envelope) = data.strip_prefix(ENVELOPE_MAGIC) else {
+        return Ok(data);
+    };
+    let corrupted = || FlameError::Integrity(format!("corrupted encrypted data of <{tenant}>"));
+
+    let (&len, envelope) = envelope.split_first().ok_or_else(corrupted)?;
+    if envelope.len() < len as usize + 4 + NONCE_LEN {
+        return Err(corrupted());
+    }
+    let (owner, envelope) = envelope.split_at(len as usize);
+    if owner != tenant.as_bytes() {
+        return Err(FlameError::Integrity(format!(
+            "the data of tenant <{}> is read by <{tenant}>",
+            String::from_utf8_lossy(owner)
+        )));
+    }
+    let (version, envelope) = envelope.split_at(4);
+    let (nonce, sealed) = envelope.split_at(NONCE_LEN);
+
+    let version = u32::from_be_bytes(version.try_into().map_err(|_| corrupted())?);
+    let key = kms.get_key(tenant, version).await?;
+    let nonce = Nonce::try_assume_unique_for_key(nonce).map_err(|_| corrupted())?;
+
+    let mut sealed = sealed.to_vec();
+    let plain = aead_key(&key)?
+        .open_in_place(nonce, Aad::from(aad(tenant, scope)), &mut sealed)
+        .map_err(|_| {
+            FlameError::Integrity(format!(
+                "failed to decrypt the data of <{tenant}> by key <{version}>"
+            ))
+        })?;
+
+    Ok(Bytes::copy_from_slice(plain))
+}
+
+fn aad(tenant: &str, scope: &str) -> Vec<u8> {
+    format!("{tenant}/{scope}").into_bytes()
+}
+
+fn aead_key(key: &DataKey) -> Result<LessSafeKey, FlameError> {
+    let key = UnboundKey::new(&AES_256_GCM, &key.material)
+        .map_err(|_| FlameError::Internal("invalid encryption key".to_string()))?;
+    Ok(LessSafeKey::new(key))
+}
+
+/// The tenant is a directory of the keys, so only the plain names are valid.
+fn check_tenant(tenant: &str) -> Result<(), FlameError> {
+    let valid = !tenant.is_empty()
+        && tenant.len() <= u8::MAX as usize
+        && tenant
+            .chars()
+            .all(|c| c.is_ascii_alphanumeric() || matches!(c, '-' | '_' | '.'))
+        && !tenant.starts_with('.');
+    if !valid {
+        return Err(FlameError::InvalidConfig(format!(
+            "invalid tenant <{tenant}>"
+        )));
+    }
+
+    Ok(())
+}
+
+/// The keys in the local directory, e.g. a mounted secret volume.
+struct FileKeyProvider {
+    root: PathBuf,
+    /// The keys read or created, by the tenant and the version.
+    keys: Mutex<HashMap<String, Vec<DataKey>>>,
+}
+
+impl FileKeyProvider {
+    fn new(root: &str) -> Result<Self, FlameError> {
+        fs::create_dir_all(root).map_err(|e| {
+            FlameError::InvalidConfig(format!("failed to create the key directory <{root}>: {e}"))
+        })?;
+
+        Ok(Self {
+            root: PathBuf::from(root),
+            keys: Mutex::new(HashMap::new()),
+        })
+    }
+
+    /// The keys of the tenant in the order of their versions; the first key
+    /// is created for the new tenant.
+    fn load(&self, tenant: &str) -> Result<Vec<DataKey>, FlameError> {
+        check_tenant(tenant)?;
+        let mut keys = lock_ptr!(self.keys)?;
+        if let Some(keys) = keys.get(tenant) {
+            return Ok(keys.clone());
+        }
+
+        let dir = self.root.join(tenant);
+        let mut loaded = vec![];
+        if dir.is_dir() {
+            let entries = fs::read_dir(&dir).map_err(|e| FlameError::Storage(e.to_string()))?;
+            for entry in entries {
+                let path = entry
+                    .map_err(|e| FlameError::Storage(e.to_string()))?
+                    .path();
+                let Some(version) = path
+                    .file_name()
+                    .and_then(|name| name.to_str())
+                    .and_then(|name| name.strip_suffix(".key"))
+                    .and_then(|version| version.parse::<u32>().ok())
+                else {
+                    continue;
+                };
+                let material = fs::read(&path)
+                    .map_err(|e| FlameError::Storage(e.to_string()))?
+                    .try_into()
+                    .map_err(|_| FlameError::Integrity(format!("invalid key <{path:?}>")))?;
+                loaded.push(DataKey::new(version, material));
+            }
+            loaded.sort_by_key(|key| key.version);
+        }
+        if loaded.is_empty() {
+            loaded.push(self.create(tenant, 1)?);
+        }
+
+        keys.insert(tenant.to_string(), loaded.clone());
+        Ok(loaded)
+    }
+
+    fn create(&self, tenant: &str, version: u32) -> Result<DataKey, FlameError> {
+        let mut material = [0u8; KEY_LEN];
+        SystemRandom::new()
+            .fill(&mut material)
+            .map_err(|_| FlameError::Internal("failed to generate the key".to_string()))?;
+
+        let dir = self.root.join(tenant);
+        fs::create_dir_all(&dir).map_err(|e| FlameError::Storage(e.to_string()))?;
+        let path = dir.join(format!("{version}.key"));
+        // The existing key is never overwritten, or its data is lost.
+        let mut options = fs::OpenOptions::new();
+        options.write(true).create_new(true);
+        #[cfg(unix)]
+        std::os::unix::fs::OpenOptionsExt::mode(&mut options, 0o600);
+        let mut file = options.open(&path).map_err(|e| {
+            FlameError::Storage(format!("failed to create the key <{path:?}>: {e}"))
+        })?;
+        std::io::Write::write_all(&mut file, &material)
+            .map_err(|e| FlameError::Storage(e.to_string()))?;
+
+        tracing::info!("The key <{version}> of tenant <{tenant}> was created.");
+        Ok(DataKey::new(version, material))
+    }
+}
+
+#[async_trait]
+impl KeyProvider for FileKeyProvider {
+    async fn current_key(&self, tenant: &str) -> Result<DataKey, FlameError> {
+        self.load(tenant)?
+            .pop()
+            .ok_or(FlameError::NotFound(format!("key of tenant <{tenant}>")))
+    }
+
+    async fn get_key(&self, tenant: &str, version: u32) -> Result<DataKey, FlameError> {
+        self.load(tenant)?
+            .into_iter()
+            .find(|key| key.version == version)
+            .ok_or(FlameError::NotFound(format!(
+                "key <{version}> of tenant <{tenant}>"
+            )))
+    }
+
+    async fn rotate_key(&self, tenant: &str) -> Result<u32, FlameError> {
+        let current = self.current_key(tenant).await?;
+        let key = self.create(tenant, current.version + 1)?;
+        let version = key.version;
+
+        let mut keys = lock_ptr!(self.keys)?;
+        keys.entry(tenant.to_string()).or_default().push(key);
+
+        Ok(version)
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[tokio::test]
+    async fn test_file_key_provider() {
+        let tmp_dir = tempfile::tempdir().unwrap();
+        let kms = FileKeyProvider::new(tmp_dir.path().to_str().unwrap()).unwrap();
+
+        let sealed = seal(&kms, "acme", "ssn-1", b"secret").await.unwrap();
+        assert!(!sealed.windows(6).any(|w| w == b"secret"));
+        assert_eq!(
+            open(&kms, "acme", "ssn-1", sealed.clone()).await.unwrap(),
+            Bytes::from("secret")
+        );
+
+        // The data of another tenant or session is rejected.
+        assert!(matches!(
+            open(&kms, "globex", "ssn-1", sealed.clone()).await,
+            Err(FlameError::Integrity(_))
+        ));
+        assert!(open(&kms, "acme", "ssn-2", sealed.clone()).await.is_err());
+
+        // The data before the rotation is decrypted by the previous key.
+        assert_eq!(kms.rotate_key("acme").await.unwrap(), 2);
+        assert_eq!(kms.current_key("acme").await.unwrap().version, 2);
+        let reloaded = FileKeyProvider::new(tmp_dir.path().to_str().unwrap()).unwrap();
+        assert_eq!(
+            open(&reloaded, "acme", "ssn-1", sealed).await.unwrap(),
+            Bytes::from("secret")
+        );
+
+        // The plain data is read as is.
+        assert_eq!(
+            open(&kms, "acme", "ssn-1", Bytes::from("plain"))
+                .await
+                .unwrap(),
+            Bytes::from("plain")
+        );
+        assert!(kms.current_key("../acme").await.is_err());
+    }
+}
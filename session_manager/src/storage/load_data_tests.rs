@@ -33,12 +33,14 @@ mod tests {
                 schedule_interval: 1000,
                 executors: FlameExecutors {
                     shim: Shim::default(),
+                    ..Default::default()
                 },
                 tls: None,
                 limits: FlameLimits {
                     max_sessions: None,
                     max_executors: 10,
                 },
+                ..Default::default()
             },
             cache: None,
         }
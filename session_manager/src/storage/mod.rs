@@ -11,10 +11,11 @@ See the License for the specific language governing permissions and
 limitations under the License.
 */
 
-use chrono::Utc;
-use std::collections::HashMap;
+use chrono::{DateTime, Utc};
+use std::collections::{HashMap, HashSet};
 use std::ops::Deref;
 use std::sync::Arc;
+use tokio::sync::broadcast;
 use uuid::Uuid;
 
 use stdng::{lock_ptr, logs::TraceFn, trace_fn, MutexPtr};
@@ -22,24 +23,40 @@ use stdng::{lock_ptr, logs::TraceFn, trace_fn, MutexPtr};
 use common::apis::{
     Application, ApplicationAttributes, ApplicationID, ApplicationPtr, CommonData, Event,
     EventOwner, ExecutorID, ExecutorState, Node, NodePtr, ResourceRequirement, Session,
-    SessionAttributes, SessionID, SessionPtr, SessionState, Shim, Task, TaskGID, TaskID, TaskInput,
-    TaskOutput, TaskPtr, TaskResult, TaskState,
+    SessionAttributes, SessionID, SessionPtr, SessionState, Shim, Task, TaskAttributes, TaskGID,
+    TaskID, TaskOutput, TaskPtr, TaskResult, TaskState,
 };
+use common::clock::{self, ClockPtr};
 use common::ctx::FlameClusterContext;
 use common::FlameError;
 
 use crate::model::{
-    AppInfo, Executor, ExecutorFilter, ExecutorInfo, ExecutorPtr, NodeInfo, NodeInfoPtr,
+    AppInfo, Exclusion, Executor, ExecutorFilter, ExecutorInfo, ExecutorPtr, NodeInfo, NodeInfoPtr,
     SessionInfo, SessionInfoPtr, SnapShot, SnapShotPtr,
 };
 
-use crate::events::{EventManagerPtr, FsEventManager, MemoryEventManager};
-use crate::storage::engine::EnginePtr;
+use crate::events::{ChaosEventManager, EventManagerPtr, FsEventManager, MemoryEventManager};
+use crate::storage::engine::{EncryptedEngine, EnginePtr};
+use crate::storage::kms::KeyProviderPtr;
 
 mod engine;
+mod kms;
 
 pub type StoragePtr = Arc<Storage>;
 
+/// The changes buffered for a subscriber; it resyncs all the state if it
+/// falls behind.
+const STATE_CHANGE_CAPACITY: usize = 4096;
+
+/// A change of the applications, sessions or tasks of the storage, e.g. for
+/// the replication and the watchers of the tasks.
+#[derive(Clone, Debug, PartialEq, Eq)]
+pub enum StateChange {
+    Application(String),
+    Session(SessionID),
+    Task(TaskGID),
+}
+
 #[derive(Clone)]
 pub struct Storage {
     context: FlameClusterContext,
@@ -48,27 +65,70 @@ pub struct Storage {
     executors: MutexPtr<HashMap<ExecutorID, ExecutorPtr>>,
     nodes: MutexPtr<HashMap<String, NodePtr>>,
     applications: MutexPtr<HashMap<String, ApplicationPtr>>,
+    /// The priorities of the sessions boosted by the operators, which are
+    /// kept until the session is deleted or the session manager restarts.
+    boosts: MutexPtr<HashMap<SessionID, u32>>,
+    /// Since when the open sessions with pending tasks have no executor, as
+    /// seen by the snapshots of the scheduler, to age them from then.
+    starvation: MutexPtr<HashMap<SessionID, DateTime<Utc>>>,
+    /// The applications crashing on the executors, as reported by the
+    /// executors on binding; they're not bound to the executors.
+    exclusions: MutexPtr<HashMap<ExecutorID, Vec<Exclusion>>>,
     event_manager: EventManagerPtr,
+    /// The keys of the tenants, if the task data is encrypted.
+    kms: Option<KeyProviderPtr>,
     max_sessions: Option<usize>,
+    clock: ClockPtr,
+    changes: broadcast::Sender<StateChange>,
 }
 
 pub async fn new_ptr(config: &FlameClusterContext) -> Result<StoragePtr, FlameError> {
+    new_ptr_with_clock(config, clock::system()).await
+}
+
+/// Create the storage with the given clock, e.g. a virtual clock of tests.
+pub async fn new_ptr_with_clock(
+    config: &FlameClusterContext,
+    clock: ClockPtr,
+) -> Result<StoragePtr, FlameError> {
     let event_manager: EventManagerPtr = if config.cluster.storage == "none" {
         Arc::new(MemoryEventManager::new())
     } else {
         let events_path = derive_events_path(&config.cluster.storage);
         Arc::new(FsEventManager::new(&events_path)?)
     };
+    let event_manager: EventManagerPtr = match &config.cluster.chaos {
+        Some(chaos) => Arc::new(ChaosEventManager::new(event_manager, chaos.clone())),
+        None => event_manager,
+    };
+
+    let engine = engine::connect(&config.cluster.storage).await?;
+    let kms = config
+        .cluster
+        .encryption
+        .as_ref()
+        .map(kms::new_ptr)
+        .transpose()?;
+    let engine = match &kms {
+        Some(kms) => EncryptedEngine::new_ptr(engine, kms.clone()),
+        None => engine,
+    };
 
     Ok(Arc::new(Storage {
         context: config.clone(),
-        engine: engine::connect(&config.cluster.storage).await?,
+        engine,
         sessions: stdng::new_ptr(HashMap::new()),
         executors: stdng::new_ptr(HashMap::new()),
         nodes: stdng::new_ptr(HashMap::new()),
         applications: stdng::new_ptr(HashMap::new()),
+        boosts: stdng::new_ptr(HashMap::new()),
+        starvation: stdng::new_ptr(HashMap::new()),
+        exclusions: stdng::new_ptr(HashMap::new()),
         event_manager,
+        kms,
         max_sessions: config.cluster.limits.max_sessions,
+        clock,
+        changes: broadcast::channel(STATE_CHANGE_CAPACITY).0,
     }))
 }
 
@@ -84,6 +144,21 @@ fn derive_events_path(storage_url: &str) -> String {
 }
 
 impl Storage {
+    /// The clock of the timeouts, leases and timestamps of the objects.
+    pub fn clock(&self) -> ClockPtr {
+        self.clock.clone()
+    }
+
+    /// The changes of the state after the call.
+    pub fn subscribe_changes(&self) -> broadcast::Receiver<StateChange> {
+        self.changes.subscribe()
+    }
+
+    fn notify(&self, change: StateChange) {
+        // It fails only if there's no subscriber.
+        let _ = self.changes.send(change);
+    }
+
     pub fn snapshot(&self) -> Result<SnapShotPtr, FlameError> {
         let res = SnapShot::new(self.context.cluster.slot.clone());
 
@@ -97,18 +172,50 @@ impl Storage {
             }
         }
 
+        let served = {
+            let exe_map = lock_ptr!(self.executors)?;
+            let mut served = HashSet::new();
+            for exe in exe_map.values() {
+                if let Some(ssn_id) = &lock_ptr!(exe)?.ssn_id {
+                    served.insert(ssn_id.clone());
+                }
+            }
+            served
+        };
+
         {
             let ssn_map = lock_ptr!(self.sessions)?;
+            let boosts = lock_ptr!(self.boosts)?;
+            let mut starvation = lock_ptr!(self.starvation)?;
             tracing::debug!("There are {} sessions in snapshot.", ssn_map.len());
+            let now = self.clock.utc_now();
             for ssn in ssn_map.deref().values() {
                 let ssn = lock_ptr!(ssn)?;
-                let info = SessionInfo::from(&(*ssn));
+                let mut info = SessionInfo::from(&(*ssn));
+                info.priority = boosts.get(&ssn.id).copied().unwrap_or(0);
+                // The scheduled tasks and the tasks waiting for their parents
+                // are not the demand of the session until they can be
+                // launched, so no executors are allocated for them.
+                let waiting = ssn.waiting_tasks(now) as i32;
+                if let Some(pending) = info.tasks_status.get_mut(&TaskState::Pending) {
+                    *pending -= waiting;
+                }
+                let pending = info.tasks_status.get(&TaskState::Pending).copied();
+                let starving = info.state == SessionState::Open
+                    && pending.unwrap_or(0) > 0
+                    && !served.contains(&ssn.id);
+                if starving {
+                    info.starving_since = Some(*starvation.entry(ssn.id.clone()).or_insert(now));
+                } else {
+                    starvation.remove(&ssn.id);
+                }
                 res.add_session(Arc::new(info))?;
             }
         }
 
         {
             let exe_map = lock_ptr!(self.executors)?;
+            let exclusions = lock_ptr!(self.exclusions)?;
             tracing::debug!("There are {} executors in snapshot.", exe_map.len());
             for exe in exe_map.deref().values() {
                 let exe = lock_ptr!(exe)?;
@@ -118,7 +225,8 @@ impl Storage {
                     exe.state,
                     exe.ssn_id
                 );
-                let info = ExecutorInfo::from(&(*exe));
+                let mut info = ExecutorInfo::from(&(*exe));
+                info.excluded_applications = exclusions.get(&exe.id).cloned().unwrap_or_default();
                 res.add_executor(Arc::new(info))?;
             }
         }
@@ -416,6 +524,7 @@ impl Storage {
                                 let _ = ssn.update_task(&task);
                             }
                         }
+                        self.notify(StateChange::Task(task.gid()));
                         tracing::info!(
                             "Retried task {} for session {} due to executor {} cleanup",
                             task_id,
@@ -455,13 +564,18 @@ impl Storage {
             let mut ssn_map = lock_ptr!(self.sessions)?;
             ssn_map.insert(ssn.id.clone(), SessionPtr::new(ssn.clone().into()));
         }
+        self.notify(StateChange::Session(ssn.id.clone()));
 
         self.evict_sessions()?;
 
         Ok(ssn)
     }
 
-    pub async fn close_session(&self, id: SessionID) -> Result<Session, FlameError> {
+    pub async fn close_session(
+        &self,
+        id: SessionID,
+        version: Option<u32>,
+    ) -> Result<Session, FlameError> {
         trace_fn!("Storage::close_session");
 
         let ssn_ptr = {
@@ -474,8 +588,9 @@ impl Storage {
 
         let result_ssn = {
             let mut ssn = lock_ptr!(ssn_ptr)?;
+            engine::check_version(&format!("session <{id}>"), ssn.version, version)?;
             ssn.status.state = SessionState::Closed;
-            ssn.completion_time = Some(Utc::now());
+            ssn.completion_time = Some(self.clock.utc_now());
             ssn.version += 1;
             ssn.clone()
         };
@@ -485,6 +600,7 @@ impl Storage {
                 return Err(e);
             }
         }
+        self.notify(StateChange::Session(id));
 
         self.evict_sessions()?;
 
@@ -492,9 +608,18 @@ impl Storage {
     }
 
     pub fn get_session(&self, id: SessionID) -> Result<Session, FlameError> {
-        let ssn_ptr = self.get_session_ptr(id)?;
-        let ssn = lock_ptr!(ssn_ptr)?;
-        Ok(ssn.clone())
+        let ssn_ptr = self.get_session_ptr(id.clone())?;
+        let mut ssn = {
+            let ssn = lock_ptr!(ssn_ptr)?;
+            ssn.clone()
+        };
+        // The session has no event storage until its first event.
+        ssn.events = self
+            .event_manager
+            .find_events(EventOwner::session(id))
+            .unwrap_or_default();
+
+        Ok(ssn)
     }
 
     pub fn get_session_ptr(&self, id: SessionID) -> Result<SessionPtr, FlameError> {
@@ -542,6 +667,7 @@ impl Storage {
             let mut ssn_map = lock_ptr!(self.sessions)?;
             ssn_map.insert(ssn.id.clone(), SessionPtr::new(ssn.clone().into()));
         }
+        self.notify(StateChange::Session(ssn.id.clone()));
 
         self.evict_sessions()?;
 
@@ -583,6 +709,9 @@ impl Storage {
             let mut ssn_map = lock_ptr!(self.sessions)?;
             ssn_map.remove(&id);
         }
+        lock_ptr!(self.boosts)?.remove(&id);
+        lock_ptr!(self.starvation)?.remove(&id);
+        self.notify(StateChange::Session(id.clone()));
 
         self.event_manager.remove_events(id)?;
 
@@ -662,21 +791,24 @@ impl Storage {
     pub async fn create_task(
         &self,
         ssn_id: SessionID,
-        task_input: Option<TaskInput>,
+        attr: TaskAttributes,
     ) -> Result<Task, FlameError> {
         trace_fn!("Storage::create_task");
-        let task = self.engine.create_task(ssn_id.clone(), task_input).await?;
+        let task = self.engine.create_task(ssn_id.clone(), attr).await?;
 
-        let ssn = self.get_session_ptr(ssn_id.clone())?;
-        let mut ssn = lock_ptr!(ssn)?;
-        ssn.update_task(&task)?;
+        {
+            let ssn = self.get_session_ptr(ssn_id.clone())?;
+            let mut ssn = lock_ptr!(ssn)?;
+            ssn.update_task(&task)?;
+        }
+        self.notify(StateChange::Task(task.gid()));
 
         self.event_manager.record_event(
             EventOwner::from(&task),
             Event {
                 code: task.state.into(),
                 message: Some(format!("Task was created with state <{:?}>", task.state)),
-                creation_time: Utc::now(),
+                creation_time: self.clock.utc_now(),
             },
         )?;
 
@@ -739,6 +871,7 @@ impl Storage {
         let _unused = lock_ptr!(self.sessions)?;
 
         app_map.insert(app.name.clone(), stdng::new_ptr(app.clone()));
+        self.notify(StateChange::Application(app.name));
 
         Ok(())
     }
@@ -760,6 +893,7 @@ impl Storage {
                 }
             });
         }
+        self.notify(StateChange::Application(name));
 
         Ok(())
     }
@@ -768,11 +902,16 @@ impl Storage {
         &self,
         name: String,
         attr: ApplicationAttributes,
+        version: Option<u32>,
     ) -> Result<(), FlameError> {
-        let app = self.engine.update_application(name.clone(), attr).await?;
+        let app = self
+            .engine
+            .update_application(name.clone(), attr, version)
+            .await?;
 
         let mut app_map = lock_ptr!(self.applications)?;
         app_map.insert(name.clone(), stdng::new_ptr(app.clone()));
+        self.notify(StateChange::Application(name));
 
         Ok(())
     }
@@ -815,15 +954,44 @@ impl Storage {
             Err(e) => return Err(e),
         };
 
-        let mut ssn_ptr = lock_ptr!(ssn)?;
-        ssn_ptr.update_task(&updated_task)?;
+        {
+            let mut ssn_ptr = lock_ptr!(ssn)?;
+            ssn_ptr.update_task(&updated_task)?;
+        }
+        self.notify(StateChange::Task(gid));
 
         self.event_manager.record_event(
             EventOwner::from(updated_task.gid()),
             Event {
                 code: task_state.into(),
                 message: Some(format!("Task state was updated to <{:?}>", task_state)),
-                creation_time: Utc::now(),
+                creation_time: self.clock.utc_now(),
+            },
+        )?;
+
+        Ok(())
+    }
+
+    /// Re-queue the running task, e.g. its lease was expired, so it will be
+    /// launched by another executor.
+    pub async fn requeue_task(&self, gid: TaskGID, message: String) -> Result<(), FlameError> {
+        trace_fn!("Storage::requeue_task");
+
+        let task = self.engine.retry_task(gid.clone()).await?;
+
+        let ssn_ptr = self.get_session_ptr(gid.ssn_id.clone())?;
+        {
+            let mut ssn = lock_ptr!(ssn_ptr)?;
+            ssn.update_task(&task)?;
+        }
+        self.notify(StateChange::Task(gid));
+
+        self.event_manager.record_event(
+            EventOwner::from(task.gid()),
+            Event {
+                code: task.state.into(),
+                message: Some(message),
+                creation_time: self.clock.utc_now(),
             },
         )?;
 
@@ -851,6 +1019,7 @@ impl Storage {
         let task_state = task_result.state;
         let task_message = task_result.message.clone();
         let task_output = task_result.output.clone();
+        let task_output_ref = task_result.output_ref.clone();
 
         let updated_task = match self
             .engine
@@ -862,15 +1031,19 @@ impl Storage {
                 let mut task_ptr = lock_ptr!(task)?;
                 task_ptr.state = task_state;
                 task_ptr.version += 1;
-                task_ptr.completion_time = Some(Utc::now());
+                task_ptr.completion_time = Some(self.clock.utc_now());
                 task_ptr.output = task_output;
+                task_ptr.output_ref = task_output_ref;
                 task_ptr.clone()
             }
             Err(e) => return Err(e),
         };
 
-        let mut ssn_ptr = lock_ptr!(ssn)?;
-        ssn_ptr.update_task(&updated_task)?;
+        {
+            let mut ssn_ptr = lock_ptr!(ssn)?;
+            ssn_ptr.update_task(&updated_task)?;
+        }
+        self.notify(StateChange::Task(gid));
 
         let event_message = match task_state {
             TaskState::Failed => {
@@ -884,7 +1057,7 @@ impl Storage {
             Event {
                 code: updated_task.state.into(),
                 message: Some(event_message),
-                creation_time: Utc::now(),
+                creation_time: self.clock.utc_now(),
             },
         )?;
 
@@ -917,7 +1090,7 @@ impl Storage {
             task_id: None,
             ssn_id: None,
             batch_index,
-            creation_time: Utc::now(),
+            creation_time: self.clock.utc_now(),
             state: ExecutorState::Void,
         };
 
@@ -954,16 +1127,113 @@ impl Storage {
         Ok(())
     }
 
+    /// Update the executors at once, e.g. the executors bound in a scheduling
+    /// cycle, so the engine and the cache are locked once for all of them.
+    pub async fn update_executors(&self, executors: &[Executor]) -> Result<(), FlameError> {
+        trace_fn!("Storage::update_executors");
+        if executors.is_empty() {
+            return Ok(());
+        }
+        self.engine.update_executors(executors).await?;
+
+        let exe_map = lock_ptr!(self.executors)?;
+        for executor in executors {
+            if let Some(exe_ptr) = exe_map.get(&executor.id) {
+                let mut exe = lock_ptr!(exe_ptr)?;
+                exe.state = executor.state;
+                exe.task_id = executor.task_id;
+                exe.ssn_id = executor.ssn_id.clone();
+            }
+        }
+
+        Ok(())
+    }
+
     pub async fn delete_executor(&self, id: ExecutorID) -> Result<(), FlameError> {
         trace_fn!("Storage::delete_executor");
         self.engine.delete_executor(&id).await?;
 
         let mut exe_map = lock_ptr!(self.executors)?;
         exe_map.remove(&id);
+        lock_ptr!(self.exclusions)?.remove(&id);
 
         Ok(())
     }
 
+    /// Exclude the applications from the bindings of the executor, e.g. they
+    /// are crash-looping on it; no application clears the exclusions. The
+    /// applications which start crash-looping by the exclusions are returned.
+    pub fn exclude_applications(
+        &self,
+        id: &ExecutorID,
+        apps: Vec<Exclusion>,
+    ) -> Result<Vec<Exclusion>, FlameError> {
+        trace_fn!("Storage::exclude_applications");
+        let mut exclusions = lock_ptr!(self.exclusions)?;
+        let previous = match apps.is_empty() {
+            true => exclusions.remove(id),
+            false => exclusions.insert(id.clone(), apps.clone()),
+        }
+        .unwrap_or_default();
+
+        Ok(apps
+            .into_iter()
+            .filter(|app| {
+                app.crash_looping
+                    && !previous
+                        .iter()
+                        .any(|p| p.application == app.application && p.crash_looping)
+            })
+            .collect())
+    }
+
+    /// Rotate the key of the tenant; the new task data of the tenant is
+    /// encrypted by the new version of the key, which is returned.
+    pub async fn rotate_tenant_key(&self, tenant: &str) -> Result<u32, FlameError> {
+        trace_fn!("Storage::rotate_tenant_key");
+        let kms = self.kms.as_ref().ok_or(FlameError::InvalidState(
+            "the encryption is not enabled".to_string(),
+        ))?;
+        let version = kms.rotate_key(tenant).await?;
+        tracing::info!("The key of tenant <{tenant}> was rotated to <{version}>.");
+
+        Ok(version)
+    }
+
+    /// Boost the priority of the open session, which is scheduled by the
+    /// next cycle; zero removes the boost. The previous priority is returned.
+    pub fn boost_session(&self, id: &SessionID, priority: u32) -> Result<u32, FlameError> {
+        trace_fn!("Storage::boost_session");
+        {
+            let ssn_map = lock_ptr!(self.sessions)?;
+            let ssn_ptr = ssn_map
+                .get(id)
+                .ok_or_else(|| FlameError::NotFound(format!("session <{id}>")))?;
+            let ssn = lock_ptr!(ssn_ptr)?;
+            if ssn.status.state != SessionState::Open {
+                return Err(FlameError::InvalidState(format!(
+                    "session <{id}> is not open"
+                )));
+            }
+        }
+
+        let mut boosts = lock_ptr!(self.boosts)?;
+        let previous = match priority {
+            0 => boosts.remove(id),
+            _ => boosts.insert(id.clone(), priority),
+        };
+
+        Ok(previous.unwrap_or(0))
+    }
+
+    /// The priority of the session boosted by the operators; None if it's not
+    /// boosted.
+    pub fn session_priority(&self, id: &SessionID) -> Result<Option<u32>, FlameError> {
+        let boosts = lock_ptr!(self.boosts)?;
+
+        Ok(boosts.get(id).copied())
+    }
+
     pub async fn record_event(&self, owner: EventOwner, event: Event) -> Result<(), FlameError> {
         trace_fn!("Storage::record_event");
         self.event_manager.record_event(owner, event)
@@ -19,7 +19,9 @@ limitations under the License.
 
 #[cfg(test)]
 mod tests {
-    use common::apis::{Node, NodeInfo, NodeState, ResourceRequirement};
+    use std::collections::HashMap;
+
+    use common::apis::{Node, NodeInfo, NodeState, ResourceRequirement, LABEL_ZONE};
 
     /// Test that node status can be properly constructed from node data.
     /// This verifies the fix in stream_handler.rs where heartbeats now include
@@ -41,6 +43,7 @@ mod tests {
             info: NodeInfo {
                 arch: "x86_64".to_string(),
                 os: "linux".to_string(),
+                ..Default::default()
             },
         };
 
@@ -75,6 +78,7 @@ mod tests {
             info: NodeInfo {
                 arch: "aarch64".to_string(),
                 os: "linux".to_string(),
+                ..Default::default()
             },
         };
 
@@ -129,6 +133,7 @@ mod tests {
             info: NodeInfo {
                 arch: "x86_64".to_string(),
                 os: "linux".to_string(),
+                ..Default::default()
             },
         };
 
@@ -161,6 +166,7 @@ mod tests {
             info: NodeInfo {
                 arch: "x86_64".to_string(),
                 os: "linux".to_string(),
+                ..Default::default()
             },
         };
 
@@ -196,12 +202,21 @@ mod tests {
             state: NodeState::Ready,
             capacity: ResourceRequirement::default(),
             allocatable: ResourceRequirement::default(),
-            info: NodeInfo::default(),
+            info: NodeInfo {
+                labels: HashMap::from([(LABEL_ZONE.to_string(), "us-east-1a".to_string())]),
+                ..Default::default()
+            },
         };
 
         // Refresh should update capacity, allocatable, and info
         node.refresh();
 
+        // The labels are kept by refresh, e.g. from the cloud metadata
+        assert_eq!(
+            node.info.labels.get(LABEL_ZONE).map(String::as_str),
+            Some("us-east-1a")
+        );
+
         // After refresh, capacity should have non-zero values (from system)
         // Note: In test environment, these might be actual system values
         // We just verify the refresh doesn't panic and sets some values
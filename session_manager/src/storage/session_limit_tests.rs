@@ -13,6 +13,8 @@ limitations under the License.
 
 #[cfg(test)]
 mod tests {
+    use std::collections::HashMap;
+
     use crate::storage;
     use common::apis::{SessionAttributes, SessionState};
     use common::ctx::{FlameCluster, FlameClusterContext, FlameLimits};
@@ -46,12 +48,19 @@ mod tests {
                 min_instances: 1,
                 max_instances: None,
                 batch_size: 1,
+                content_type: None,
+                client_host: None,
+                labels: vec![],
+                environments: HashMap::new(),
             };
             storage.create_session(attr).await.unwrap();
         }
 
         for i in 0..3 {
-            storage.close_session(format!("ssn-{}", i)).await.unwrap();
+            storage
+                .close_session(format!("ssn-{}", i), None)
+                .await
+                .unwrap();
         }
 
         let sessions = storage.list_session().unwrap();
@@ -72,6 +81,10 @@ mod tests {
                 min_instances: 1,
                 max_instances: None,
                 batch_size: 1,
+                content_type: None,
+                client_host: None,
+                labels: vec![],
+                environments: HashMap::new(),
             };
             storage.create_session(attr).await.unwrap();
         }
@@ -79,7 +92,10 @@ mod tests {
         let sessions_before = storage.list_session().unwrap();
         assert_eq!(sessions_before.len(), 3);
 
-        storage.close_session("ssn-0".to_string()).await.unwrap();
+        storage
+            .close_session("ssn-0".to_string(), None)
+            .await
+            .unwrap();
 
         let sessions_after = storage.list_session().unwrap();
         assert_eq!(sessions_after.len(), 2);
@@ -102,11 +118,18 @@ mod tests {
                 min_instances: 1,
                 max_instances: None,
                 batch_size: 1,
+                content_type: None,
+                client_host: None,
+                labels: vec![],
+                environments: HashMap::new(),
             };
             storage.create_session(attr).await.unwrap();
         }
 
-        storage.close_session("ssn-0".to_string()).await.unwrap();
+        storage
+            .close_session("ssn-0".to_string(), None)
+            .await
+            .unwrap();
 
         let sessions = storage.list_session().unwrap();
         assert_eq!(sessions.len(), 3);
@@ -126,11 +149,18 @@ mod tests {
                 min_instances: 1,
                 max_instances: None,
                 batch_size: 1,
+                content_type: None,
+                client_host: None,
+                labels: vec![],
+                environments: HashMap::new(),
             };
             storage.create_session(attr).await.unwrap();
         }
 
-        storage.close_session("ssn-1".to_string()).await.unwrap();
+        storage
+            .close_session("ssn-1".to_string(), None)
+            .await
+            .unwrap();
 
         let sessions = storage.list_session().unwrap();
         assert_eq!(sessions.len(), 2);
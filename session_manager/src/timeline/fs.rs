@@ -0,0 +1,121 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+use std::fs::{self, OpenOptions};
+use std::io::Write;
+use std::path::PathBuf;
+
+use chrono::{DateTime, NaiveDate, Utc};
+use stdng::{lock_ptr, new_ptr, MutexPtr};
+
+use common::FlameError;
+
+use super::{Sample, TimelineStore};
+
+/// The samples are appended to a JSON Lines file per day, e.g.
+/// `<path>/2025-01-01.jsonl`, so the compaction removes the whole files.
+pub struct FsTimelineStore {
+    path: PathBuf,
+    // Serialize the writers of the files.
+    lock: MutexPtr<()>,
+}
+
+const SUFFIX: &str = ".jsonl";
+
+impl FsTimelineStore {
+    pub fn new(path: &str) -> Result<Self, FlameError> {
+        fs::create_dir_all(path)?;
+
+        Ok(Self {
+            path: PathBuf::from(path),
+            lock: new_ptr(()),
+        })
+    }
+
+    fn file_of(&self, day: NaiveDate) -> PathBuf {
+        self.path
+            .join(format!("{}{SUFFIX}", day.format("%Y-%m-%d")))
+    }
+
+    fn days(&self) -> Result<Vec<NaiveDate>, FlameError> {
+        let mut days = vec![];
+        for entry in fs::read_dir(&self.path)? {
+            let name = entry?.file_name();
+            let day = name
+                .to_str()
+                .and_then(|name| name.strip_suffix(SUFFIX))
+                .and_then(|day| NaiveDate::parse_from_str(day, "%Y-%m-%d").ok());
+            // Skip the files not written by the store.
+            if let Some(day) = day {
+                days.push(day);
+            }
+        }
+        days.sort();
+
+        Ok(days)
+    }
+}
+
+impl TimelineStore for FsTimelineStore {
+    fn append(&self, sample: &Sample) -> Result<(), FlameError> {
+        let _lock = lock_ptr!(self.lock)?;
+
+        let mut line =
+            serde_json::to_string(sample).map_err(|e| FlameError::Internal(e.to_string()))?;
+        line.push('\n');
+
+        let mut file = OpenOptions::new()
+            .create(true)
+            .append(true)
+            .open(self.file_of(sample.timestamp.date_naive()))?;
+        file.write_all(line.as_bytes())?;
+
+        Ok(())
+    }
+
+    fn query(&self, since: DateTime<Utc>, until: DateTime<Utc>) -> Result<Vec<Sample>, FlameError> {
+        let _lock = lock_ptr!(self.lock)?;
+
+        let mut samples = vec![];
+        for day in self.days()? {
+            if day < since.date_naive() || day > until.date_naive() {
+                continue;
+            }
+
+            let content = fs::read_to_string(self.file_of(day))?;
+            for line in content.lines() {
+                // A partial line is left by a crash of the session manager.
+                let Ok(sample) = serde_json::from_str::<Sample>(line) else {
+                    continue;
+                };
+                if sample.timestamp >= since && sample.timestamp < until {
+                    samples.push(sample);
+                }
+            }
+        }
+
+        Ok(samples)
+    }
+
+    fn compact(&self, before: DateTime<Utc>) -> Result<(), FlameError> {
+        let _lock = lock_ptr!(self.lock)?;
+
+        for day in self.days()? {
+            if day < before.date_naive() {
+                fs::remove_file(self.file_of(day))?;
+            }
+        }
+
+        Ok(())
+    }
+}
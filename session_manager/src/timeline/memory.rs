@@ -0,0 +1,61 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+use std::collections::VecDeque;
+
+use chrono::{DateTime, Utc};
+use stdng::{lock_ptr, new_ptr, MutexPtr};
+
+use common::FlameError;
+
+use super::{Sample, TimelineStore};
+
+pub struct MemoryTimelineStore {
+    samples: MutexPtr<VecDeque<Sample>>,
+}
+
+impl MemoryTimelineStore {
+    pub fn new() -> Self {
+        Self {
+            samples: new_ptr(VecDeque::new()),
+        }
+    }
+}
+
+impl TimelineStore for MemoryTimelineStore {
+    fn append(&self, sample: &Sample) -> Result<(), FlameError> {
+        let mut samples = lock_ptr!(self.samples)?;
+        samples.push_back(sample.clone());
+
+        Ok(())
+    }
+
+    fn query(&self, since: DateTime<Utc>, until: DateTime<Utc>) -> Result<Vec<Sample>, FlameError> {
+        let samples = lock_ptr!(self.samples)?;
+
+        Ok(samples
+            .iter()
+            .filter(|s| s.timestamp >= since && s.timestamp < until)
+            .cloned()
+            .collect())
+    }
+
+    fn compact(&self, before: DateTime<Utc>) -> Result<(), FlameError> {
+        let mut samples = lock_ptr!(self.samples)?;
+        while samples.front().is_some_and(|s| s.timestamp < before) {
+            samples.pop_front();
+        }
+
+        Ok(())
+    }
+}
@@ -0,0 +1,284 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//! The opt-in time series of the scheduler metrics, e.g. the queue depth, the
+//! bind rate and the executors, sampled periodically into a pluggable store,
+//! so what the cluster was doing during an incident is reconstructed later
+//! by `flmctl debug timeline`.
+
+use std::sync::Arc;
+use std::time::Duration;
+
+use chrono::{DateTime, Utc};
+use serde_derive::{Deserialize, Serialize};
+use stdng::lock_ptr;
+
+use common::apis::{ExecutorState, SessionState, TaskState};
+use common::ctx::FlameTimeline;
+use common::FlameError;
+
+use crate::controller::ControllerPtr;
+use crate::model::SnapShot;
+
+mod fs;
+mod memory;
+
+pub use fs::FsTimelineStore;
+pub use memory::MemoryTimelineStore;
+
+/// The metrics of the scheduler at a time.
+#[derive(Clone, Debug, Default, PartialEq, Serialize, Deserialize)]
+pub struct Sample {
+    pub timestamp: DateTime<Utc>,
+    pub pending_tasks: u64,
+    pub running_tasks: u64,
+    pub open_sessions: u64,
+    pub executors: u64,
+    pub idle_executors: u64,
+    /// The sessions bound to the executors since the last sample.
+    pub binds: u64,
+}
+
+pub trait TimelineStore: Send + Sync {
+    fn append(&self, sample: &Sample) -> Result<(), FlameError>;
+    /// The samples in `[since, until)` in the order of time.
+    fn query(&self, since: DateTime<Utc>, until: DateTime<Utc>) -> Result<Vec<Sample>, FlameError>;
+    /// Drop the samples before the time; the stores may keep some of them,
+    /// e.g. the samples of the same file.
+    fn compact(&self, before: DateTime<Utc>) -> Result<(), FlameError>;
+}
+
+pub type TimelineStorePtr = Arc<dyn TimelineStore>;
+
+pub struct Timeline {
+    conf: Option<FlameTimeline>,
+    store: Option<TimelineStorePtr>,
+}
+
+pub type TimelinePtr = Arc<Timeline>;
+
+pub fn new_ptr(conf: Option<FlameTimeline>) -> Result<TimelinePtr, FlameError> {
+    let store: Option<TimelineStorePtr> = match &conf {
+        None => None,
+        Some(conf) if conf.storage == "memory" => {
+            tracing::warn!(
+                "The timeline is kept in memory, its samples are lost on the restart of the session manager."
+            );
+            Some(Arc::new(MemoryTimelineStore::new()))
+        }
+        Some(conf) => match conf.storage.strip_prefix("file://") {
+            Some(dir) => Some(Arc::new(FsTimelineStore::new(dir)?)),
+            None => {
+                return Err(FlameError::InvalidConfig(format!(
+                    "invalid timeline.storage <{}>",
+                    conf.storage
+                )))
+            }
+        },
+    };
+
+    Ok(Arc::new(Timeline { conf, store }))
+}
+
+impl Timeline {
+    pub fn is_enabled(&self) -> bool {
+        self.store.is_some()
+    }
+
+    /// The interval in seconds between two samples; zero if not enabled.
+    pub fn interval(&self) -> u64 {
+        self.conf.as_ref().map(|conf| conf.interval).unwrap_or(0)
+    }
+
+    pub fn query(
+        &self,
+        since: DateTime<Utc>,
+        until: DateTime<Utc>,
+    ) -> Result<Vec<Sample>, FlameError> {
+        match &self.store {
+            Some(store) => store.query(since, until),
+            None => Ok(vec![]),
+        }
+    }
+
+    /// Sample the metrics of the scheduler periodically until the session
+    /// manager exits; the samples beyond the retention are dropped.
+    pub async fn record(&self, controller: ControllerPtr) {
+        let (Some(conf), Some(store)) = (&self.conf, &self.store) else {
+            return;
+        };
+
+        let clock = controller.clock();
+        let mut binds = controller.bind_count();
+        loop {
+            clock.sleep(Duration::from_secs(conf.interval)).await;
+
+            let now = clock.utc_now();
+            let bind_count = controller.bind_count();
+            let sample = controller
+                .snapshot()
+                .and_then(|snapshot| sample_of(&snapshot, now, bind_count - binds));
+            binds = bind_count;
+
+            // The timeline is best-effort, it never fails the scheduler.
+            let res = sample
+                .and_then(|sample| store.append(&sample))
+                .and_then(|_| {
+                    store.compact(now - chrono::Duration::seconds(conf.retention as i64))
+                });
+            if let Err(e) = res {
+                tracing::warn!("Failed to record the timeline: {e}");
+            }
+        }
+    }
+}
+
+fn sample_of(snapshot: &SnapShot, now: DateTime<Utc>, binds: u64) -> Result<Sample, FlameError> {
+    let mut sample = Sample {
+        timestamp: now,
+        binds,
+        ..Default::default()
+    };
+
+    {
+        let ssn_index = lock_ptr!(snapshot.ssn_index)?;
+        for ssn in ssn_index
+            .get(&SessionState::Open)
+            .into_iter()
+            .flat_map(|ssns| ssns.values())
+        {
+            sample.open_sessions += 1;
+            let count = |state| ssn.tasks_status.get(&state).copied().unwrap_or(0).max(0) as u64;
+            sample.pending_tasks += count(TaskState::Pending);
+            sample.running_tasks += count(TaskState::Running);
+        }
+    }
+
+    {
+        let executors = lock_ptr!(snapshot.executors)?;
+        sample.executors = executors.len() as u64;
+        let exec_index = lock_ptr!(snapshot.exec_index)?;
+        sample.idle_executors = exec_index
+            .get(&ExecutorState::Idle)
+            .map(|execs| execs.len() as u64)
+            .unwrap_or(0);
+    }
+
+    Ok(sample)
+}
+
+#[cfg(test)]
+mod tests {
+    use std::collections::HashMap;
+
+    use common::apis::ResourceRequirement;
+
+    use super::*;
+    use crate::model::{ExecutorInfo, SessionInfo};
+
+    fn sample(secs: i64) -> Sample {
+        Sample {
+            timestamp: DateTime::from_timestamp(secs, 0).unwrap(),
+            pending_tasks: secs as u64,
+            ..Default::default()
+        }
+    }
+
+    fn test_timeline_store_impl(store: &dyn TimelineStore) {
+        let day = 24 * 3600;
+        for secs in [10, 20, day + 10, 2 * day + 10] {
+            store.append(&sample(secs)).unwrap();
+        }
+
+        let samples = store
+            .query(
+                DateTime::from_timestamp(15, 0).unwrap(),
+                DateTime::from_timestamp(2 * day + 10, 0).unwrap(),
+            )
+            .unwrap();
+        assert_eq!(samples, vec![sample(20), sample(day + 10)]);
+
+        store
+            .compact(DateTime::from_timestamp(day + 20, 0).unwrap())
+            .unwrap();
+        let samples = store
+            .query(
+                DateTime::from_timestamp(0, 0).unwrap(),
+                DateTime::from_timestamp(3 * day, 0).unwrap(),
+            )
+            .unwrap();
+        assert!(!samples.contains(&sample(10)));
+        assert!(samples.contains(&sample(2 * day + 10)));
+    }
+
+    #[test]
+    fn test_memory_timeline_store() {
+        let store = MemoryTimelineStore::new();
+        test_timeline_store_impl(&store);
+    }
+
+    #[test]
+    fn test_fs_timeline_store() {
+        let temp_dir = tempfile::tempdir().unwrap();
+        let store = FsTimelineStore::new(temp_dir.path().to_str().unwrap()).unwrap();
+        test_timeline_store_impl(&store);
+    }
+
+    #[test]
+    fn test_sample_of_snapshot() {
+        let snapshot = SnapShot::new(ResourceRequirement::default());
+        snapshot
+            .add_session(Arc::new(SessionInfo {
+                id: "ssn-1".to_string(),
+                tasks_status: HashMap::from([(TaskState::Pending, 3), (TaskState::Running, 2)]),
+                state: SessionState::Open,
+                ..Default::default()
+            }))
+            .unwrap();
+        snapshot
+            .add_session(Arc::new(SessionInfo {
+                id: "ssn-2".to_string(),
+                tasks_status: HashMap::from([(TaskState::Pending, 5)]),
+                state: SessionState::Closed,
+                ..Default::default()
+            }))
+            .unwrap();
+        for (id, state) in [
+            ("exec-1", ExecutorState::Idle),
+            ("exec-2", ExecutorState::Bound),
+        ] {
+            snapshot
+                .add_executor(Arc::new(ExecutorInfo {
+                    id: id.to_string(),
+                    state,
+                    ..Default::default()
+                }))
+                .unwrap();
+        }
+
+        let now = Utc::now();
+        let sample = sample_of(&snapshot, now, 4).unwrap();
+        assert_eq!(
+            sample,
+            Sample {
+                timestamp: now,
+                pending_tasks: 3,
+                running_tasks: 2,
+                open_sessions: 1,
+                executors: 2,
+                idle_executors: 1,
+                binds: 4,
+            }
+        );
+    }
+}
@@ -0,0 +1,176 @@
+/*
+Copyright 2025 The Flame Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//! The checksums of task inputs and outputs, in the format of
+//! `<algorithm>:<hex digest>`, e.g. `xxh3:9a3d2f...`; it's shared by the
+//! session manager, the executor manager and the SDK, so they compute the
+//! same checksums.
+
+use sha2::{Digest, Sha256};
+use xxhash_rust::xxh3::{Xxh3, xxh3_64};
+
+use crate::Error;
+
+const XXH3: &str = "xxh3";
+const SHA256: &str = "sha256";
+
+#[derive(Clone, Copy, Debug, Default, PartialEq, Eq)]
+pub enum ChecksumAlgorithm {
+    #[cfg_attr(not(feature = "fips"), default)]
+    Xxh3,
+    /// The default of the FIPS builds, as xxh3 is not an approved algorithm.
+    #[cfg_attr(feature = "fips", default)]
+    Sha256,
+}
+
+/// Compute the checksum of the data by the default algorithm.
+pub fn checksum(data: &[u8]) -> String {
+    checksum_with(ChecksumAlgorithm::default(), data)
+}
+
+pub fn checksum_with(algo: ChecksumAlgorithm, data: &[u8]) -> String {
+    match algo {
+        ChecksumAlgorithm::Xxh3 => format!("{XXH3}:{:016x}", xxh3_64(data)),
+        ChecksumAlgorithm::Sha256 => {
+            let digest = Sha256::digest(data);
+            let hex: String = digest.iter().map(|b| format!("{b:02x}")).collect();
+            format!("{SHA256}:{hex}")
+        }
+    }
+}
+
+/// The checksum of the data written in chunks, e.g. the input of a task
+/// uploaded by `Session::upload_task`; it's the same as the checksum of the
+/// whole data.
+pub struct Hasher {
+    inner: HasherInner,
+}
+
+enum HasherInner {
+    Xxh3(Box<Xxh3>),
+    Sha256(Sha256),
+}
+
+impl Default for Hasher {
+    fn default() -> Self {
+        Self::new(ChecksumAlgorithm::default())
+    }
+}
+
+impl Hasher {
+    pub fn new(algo: ChecksumAlgorithm) -> Self {
+        let inner = match algo {
+            ChecksumAlgorithm::Xxh3 => HasherInner::Xxh3(Box::default()),
+            ChecksumAlgorithm::Sha256 => HasherInner::Sha256(Sha256::new()),
+        };
+
+        Self { inner }
+    }
+
+    pub fn update(&mut self, data: &[u8]) {
+        match &mut self.inner {
+            HasherInner::Xxh3(hasher) => hasher.update(data),
+            HasherInner::Sha256(hasher) => hasher.update(data),
+        }
+    }
+
+    pub fn finish(self) -> String {
+        match self.inner {
+            HasherInner::Xxh3(hasher) => format!("{XXH3}:{:016x}", hasher.digest()),
+            HasherInner::Sha256(hasher) => {
+                let hex: String = hasher
+                    .finalize()
+                    .iter()
+                    .map(|b| format!("{b:02x}"))
+                    .collect();
+                format!("{SHA256}:{hex}")
+            }
+        }
+    }
+}
+
+/// The algorithm of the checksum, e.g. to verify the data received in chunks
+/// by a `Hasher`.
+pub fn algorithm_of(name: &str, checksum: &str) -> Result<ChecksumAlgorithm, Error> {
+    let (algo, _) = checksum.split_once(':').ok_or(Error::Integrity(format!(
+        "invalid checksum <{checksum}> of {name}"
+    )))?;
+
+    match algo {
+        XXH3 => Ok(ChecksumAlgorithm::Xxh3),
+        SHA256 => Ok(ChecksumAlgorithm::Sha256),
+        _ => Err(Error::Integrity(format!(
+            "unsupported checksum algorithm <{algo}> of {name}"
+        ))),
+    }
+}
+
+/// Verify the data against the expected checksum; the data without checksum,
+/// e.g. from an older client, is passed.
+pub fn verify(name: &str, data: Option<&[u8]>, expected: Option<&str>) -> Result<(), Error> {
+    let Some(expected) = expected else {
+        return Ok(());
+    };
+
+    let algo = algorithm_of(name, expected)?;
+    let actual = checksum_with(algo, data.unwrap_or_default());
+    if actual != expected {
+        return Err(Error::Integrity(format!(
+            "checksum mismatch of {name}: expected <{expected}>, got <{actual}>"
+        )));
+    }
+
+    Ok(())
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_checksum_verify() {
+        let data = b"hello flame";
+
+        for algo in [ChecksumAlgorithm::Xxh3, ChecksumAlgorithm::Sha256] {
+            let sum = checksum_with(algo, data);
+            assert!(verify("input", Some(data), Some(&sum)).is_ok());
+            assert!(matches!(
+                verify("input", Some(b"hello world"), Some(&sum)),
+                Err(Error::Integrity(_))
+            ));
+        }
+
+        if cfg!(feature = "fips") {
+            assert!(checksum(data).starts_with("sha256:"));
+        } else {
+            assert!(checksum(data).starts_with("xxh3:"));
+        }
+        assert!(verify("input", Some(data), None).is_ok());
+        assert!(verify("input", None, Some(&checksum(&[]))).is_ok());
+        assert!(verify("input", Some(data), Some("md5:abc")).is_err());
+        assert!(verify("input", Some(data), Some("abc")).is_err());
+    }
+
+    #[test]
+    fn test_checksum_in_chunks() {
+        let data = b"hello flame, in chunks";
+
+        for algo in [ChecksumAlgorithm::Xxh3, ChecksumAlgorithm::Sha256] {
+            let mut hasher = Hasher::new(algo);
+            for chunk in data.chunks(5) {
+                hasher.update(chunk);
+            }
+            assert_eq!(hasher.finish(), checksum_with(algo, data));
+        }
+    }
+}
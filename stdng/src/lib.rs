@@ -15,6 +15,7 @@ use std::sync::{Arc, Mutex};
 
 use thiserror::Error;
 
+pub mod checksum;
 pub mod collections;
 pub mod logs;
 pub mod rand;
@@ -29,6 +30,9 @@ pub enum Error {
 
     #[error("{0}")]
     Network(String),
+
+    #[error("{0}")]
+    Integrity(String),
 }
 
 pub type MutexPtr<T> = Arc<Mutex<T>>;
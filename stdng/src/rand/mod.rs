@@ -20,3 +20,13 @@ pub fn short_name() -> String {
         .map(char::from)
         .collect()
 }
+
+/// Whether an event of the probability happens, e.g. 0.1 for 10%.
+pub fn hit(probability: f64) -> bool {
+    rand::rng().random_bool(probability.clamp(0.0, 1.0))
+}
+
+/// A random number in `0..=max`.
+pub fn up_to(max: u64) -> u64 {
+    rand::rng().random_range(0..=max)
+}